@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// ============================================================================
+// Adaptive Poll Interval (backoff on slow interface queries)
+// ============================================================================
+
+const (
+	// adaptiveStretchRatio stretches the interval once a query takes this share of it
+	adaptiveStretchRatio = 0.8
+	// adaptiveTargetRatio is the share of the interval a query may take after stretching
+	adaptiveTargetRatio = 0.5
+	// adaptiveRecoverySamples is the number of samples whose slowest query decides a shrink
+	adaptiveRecoverySamples = 10
+)
+
+// AdaptiveInterval stretches the poll interval while the router answers slowly
+//
+// An interface query that takes most of the interval makes the next tick wait
+// for it, so samples bunch up and rates are computed over uneven intervals.
+// The interval is stretched to a multiple of POLL_INTERVAL at which a query
+// takes at most half of it (up to POLL_MAX_INTERVAL), and shrunk again once
+// the slowest of the last adaptiveRecoverySamples queries fits a shorter one.
+// Only used by the monitoring loop goroutine.
+type AdaptiveInterval struct {
+	base    time.Duration // POLL_INTERVAL
+	max     time.Duration // POLL_MAX_INTERVAL
+	current time.Duration
+
+	slowest time.Duration // Slowest query since the last recovery check
+	samples int
+}
+
+// NewAdaptiveInterval creates an adaptive interval starting at base
+func NewAdaptiveInterval(base, maxInterval time.Duration) *AdaptiveInterval {
+	return &AdaptiveInterval{base: base, max: maxInterval, current: base}
+}
+
+// Interval returns the current poll interval
+func (a *AdaptiveInterval) Interval() time.Duration {
+	return a.current
+}
+
+// Observe records the duration of one interface query and reports whether the interval changed
+func (a *AdaptiveInterval) Observe(duration time.Duration) bool {
+	a.slowest = max(a.slowest, duration)
+	a.samples++
+
+	if float64(duration) > adaptiveStretchRatio*float64(a.current) {
+		next := a.fit(duration)
+		if next <= a.current {
+			return false // Already at POLL_MAX_INTERVAL
+		}
+		logWarn("Monitor", "Interface query took %v of the %v poll interval, polling every %v",
+			duration.Round(time.Millisecond), a.current, next)
+		a.current = next
+		a.slowest, a.samples = 0, 0
+		return true
+	}
+
+	if a.samples < adaptiveRecoverySamples {
+		return false
+	}
+	slowest := a.slowest
+	next := a.fit(slowest)
+	a.slowest, a.samples = 0, 0
+	if next >= a.current {
+		return false
+	}
+	if next == a.base {
+		logInfo("Monitor", "Interface queries take up to %v again, back to the %v poll interval", slowest.Round(time.Millisecond), next)
+	} else {
+		logInfo("Monitor", "Interface queries take up to %v, polling every %v", slowest.Round(time.Millisecond), next)
+	}
+	a.current = next
+	return true
+}
+
+// fit returns the shortest multiple of the base interval a query of duration fits in (capped at max)
+func (a *AdaptiveInterval) fit(duration time.Duration) time.Duration {
+	steps := max(math.Ceil(float64(duration)/adaptiveTargetRatio/float64(a.base)), 1)
+	return min(time.Duration(steps)*a.base, max(a.max, a.base))
+}
+
+// newPollBackoff returns the adaptive interval of a configuration (nil if POLL_ADAPTIVE=false)
+func newPollBackoff(config *Config) *AdaptiveInterval {
+	if !config.PollAdaptive {
+		return nil
+	}
+	maxInterval := config.PollMaxInterval
+	if maxInterval == 0 {
+		maxInterval = 10 * config.PollInterval
+	}
+	return NewAdaptiveInterval(config.PollInterval, maxInterval)
+}
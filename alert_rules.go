@@ -0,0 +1,394 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Prometheus / vmalert Rule Export (alert-rules command)
+// ============================================================================
+
+// alertRule is one alerting rule of the generated file
+type alertRule struct {
+	Name        string
+	Expr        string
+	For         time.Duration
+	Labels      [][2]string // Ordered name/value pairs
+	Annotations [][2]string
+}
+
+// rateSelector is one series selector holding a user-perspective direction
+type rateSelector struct {
+	metric   string // rx or tx
+	matchers string // Interface matchers, without braces ("" = all interfaces)
+}
+
+// alertRuleGenerator translates the local alert configuration into PromQL
+//
+// Rules read the series this tool exports: with source "vm" the window
+// averages pushed to VictoriaMetrics (interval label of VM_INTERVAL), with
+// source "scrape" the current rates of /metrics. Thresholds are converted to
+// METRIC_UNIT, upload/download are mapped to RX/TX through the uplink
+// interfaces like the local engine does, and EXTRA_LABELS scope every selector
+// so rules of several monitors can share one vmalert.
+type alertRuleGenerator struct {
+	config   *Config
+	source   string
+	isUplink func(string) bool
+	names    []string // Monitored interfaces and groups
+	scale    float64  // bits/s -> metric unit
+}
+
+// newAlertRuleGenerator creates a generator for source "vm" or "scrape"
+func newAlertRuleGenerator(config *Config, source string, isUplink func(string) bool) *alertRuleGenerator {
+	names := append([]string{}, config.Interfaces...)
+	for _, group := range config.Groups {
+		names = append(names, group.Name)
+	}
+	scale := 1.0 / 8
+	if config.MetricUnit == "bits" {
+		scale = 1
+	}
+	return &alertRuleGenerator{config: config, source: source, isUplink: isUplink, names: names, scale: scale}
+}
+
+// Rules returns the rules for the configured alerts, the monitor and the router
+func (g *alertRuleGenerator) Rules() []alertRule {
+	var rules []alertRule
+	if alerts := g.config.Alerts; alerts != nil {
+		thresholds, _ := parseAlertRules(alerts.Thresholds) // Validated with the config
+		for _, rule := range thresholds {
+			rules = append(rules, g.thresholdRules(rule)...)
+		}
+		if len(alerts.ZeroInterfaces) > 0 {
+			rules = append(rules, g.zeroRule(alerts))
+		}
+		if len(alerts.AnomalyInterfaces) > 0 {
+			for _, direction := range []string{"upload", "download"} {
+				rules = append(rules, g.anomalyRules(alerts, direction)...)
+			}
+		}
+		if len(alerts.NetwatchHosts) > 0 {
+			rules = append(rules, g.netwatchRule(alerts))
+		}
+	}
+	if g.config.Events != nil {
+		rules = append(rules, g.interfaceDownRule())
+	}
+	return append(rules, g.deviceDownRules()...)
+}
+
+// thresholdRules renders an ALERT_THRESHOLDS rule (one rule per RX/TX selector)
+func (g *alertRuleGenerator) thresholdRules(rule AlertRule) []alertRule {
+	op, relation, name := ">", "above", "MikrotikRateAboveThreshold"
+	if !rule.Above {
+		op, relation, name = "<", "below", "MikrotikRateBelowThreshold"
+	}
+
+	var rules []alertRule
+	for _, selector := range g.directionSelectors([]string{rule.Interface}, rule.Direction) {
+		rules = append(rules, alertRule{
+			Name: name,
+			Expr: fmt.Sprintf("%s %s %s", g.rateSeries(selector, "avg"), op, formatPromNumber(rule.Threshold*g.scale)),
+			Labels: [][2]string{
+				{"severity", "warning"},
+				{"kind", AlertThreshold},
+				{"direction", rule.Direction},
+			},
+			Annotations: [][2]string{
+				{"summary", fmt.Sprintf("{{ $labels.interface }} %s %s %s", rule.Direction, relation, formatAlertRate(rule.Threshold))},
+				{"description", fmt.Sprintf("Current %s rate: {{ $value | humanize }}%s (rule %s)", rule.Direction, g.unitSuffix(), rule)},
+			},
+		})
+	}
+	return rules
+}
+
+// zeroRule renders ALERT_ZERO_INTERFACES: no traffic in either direction for ALERT_ZERO_DURATION
+func (g *alertRuleGenerator) zeroRule(alerts *AlertsConfig) alertRule {
+	matchers := interfaceMatchers(alerts.ZeroInterfaces, "=~")
+	// Window peaks: a window with any traffic is not zero
+	rx := g.rateSeries(rateSelector{metric: "rx", matchers: matchers}, "peak")
+	tx := g.rateSeries(rateSelector{metric: "tx", matchers: matchers}, "peak")
+	return alertRule{
+		Name:   "MikrotikZeroTraffic",
+		Expr:   fmt.Sprintf("%s + %s == 0", rx, tx),
+		For:    alerts.ZeroDuration,
+		Labels: [][2]string{{"severity", "warning"}, {"kind", AlertZero}},
+		Annotations: [][2]string{
+			{"summary", fmt.Sprintf("{{ $labels.interface }} has passed no traffic for %v", alerts.ZeroDuration)},
+		},
+	}
+}
+
+// anomalyRules renders ALERT_ANOMALY_INTERFACES for one direction
+// The rolling window is ALERT_ANOMALY_WINDOW samples of POLL_INTERVAL.
+func (g *alertRuleGenerator) anomalyRules(alerts *AlertsConfig, direction string) []alertRule {
+	window := formatPromDuration(time.Duration(alerts.AnomalyWindow) * g.config.PollInterval)
+	sigma := formatPromNumber(alerts.AnomalySigma)
+
+	var rules []alertRule
+	for _, selector := range g.directionSelectors(alerts.AnomalyInterfaces, direction) {
+		series := g.rateSeries(selector, "avg")
+		rules = append(rules, alertRule{
+			Name: "MikrotikRateAnomaly",
+			Expr: fmt.Sprintf("abs(%s - avg_over_time(%s[%s])) > %s * stddev_over_time(%s[%s])",
+				series, series, window, sigma, series, window),
+			Labels: [][2]string{
+				{"severity", "info"},
+				{"kind", AlertAnomaly},
+				{"direction", direction},
+			},
+			Annotations: [][2]string{
+				{"summary", fmt.Sprintf("{{ $labels.interface }} %s rate is more than %s sigma from its %s average", direction, sigma, window)},
+				{"description", fmt.Sprintf("Deviation: {{ $value | humanize }}%s", g.unitSuffix())},
+			},
+		})
+	}
+	return rules
+}
+
+// netwatchRule renders ALERT_NETWATCH_HOSTS (entries match by host or name)
+func (g *alertRuleGenerator) netwatchRule(alerts *AlertsConfig) alertRule {
+	metric := g.config.MetricPrefix + "netwatch_up"
+	expr := fmt.Sprintf("%s == 0", g.selector(metric, ""))
+	if pattern := namesPattern(alerts.NetwatchHosts); pattern != "" {
+		expr = fmt.Sprintf("%s == 0 or %s == 0",
+			g.selector(metric, fmt.Sprintf("host=~%s", strconv.Quote(pattern))),
+			g.selector(metric, fmt.Sprintf("name=~%s", strconv.Quote(pattern))))
+	}
+	return alertRule{
+		Name:   "MikrotikNetwatchDown",
+		Expr:   expr,
+		Labels: [][2]string{{"severity", "critical"}, {"kind", AlertNetwatch}},
+		Annotations: [][2]string{
+			{"summary", "Netwatch host {{ $labels.host }} is down"},
+		},
+	}
+}
+
+// interfaceDownRule fires for monitored interfaces that are not running (EVENTS_ENABLED)
+func (g *alertRuleGenerator) interfaceDownRule() alertRule {
+	return alertRule{
+		Name:   "MikrotikInterfaceDown",
+		Expr:   fmt.Sprintf("%s == 0", g.selector(g.config.MetricPrefix+"interface_up", "")),
+		Labels: [][2]string{{"severity", "critical"}, {"kind", "interface_down"}},
+		Annotations: [][2]string{
+			{"summary", "{{ $labels.interface }} is down or disabled"},
+		},
+	}
+}
+
+// deviceDownRules fire when the monitor stops reporting or stops sampling the router
+func (g *alertRuleGenerator) deviceDownRules() []alertRule {
+	// Telemetry is pushed every VM_INTERVAL; scrapes are assumed to be at least once a minute
+	window := 5 * time.Minute
+	if vm := g.config.VictoriaMetrics; g.source == "vm" && vm != nil {
+		window = max(window, 5*vm.Interval)
+	}
+	duration := formatPromDuration(window)
+
+	return []alertRule{
+		{
+			Name:   "MikrotikMonitorDown",
+			Expr:   fmt.Sprintf("absent_over_time(%s[%s])", g.selector(g.config.MetricPrefix+"monitor_uptime_seconds", ""), duration),
+			Labels: [][2]string{{"severity", "critical"}, {"kind", "monitor_down"}},
+			Annotations: [][2]string{
+				{"summary", fmt.Sprintf("The traffic monitor has not reported for %s", duration)},
+			},
+		},
+		{
+			Name:   "MikrotikRouterUnreachable",
+			Expr:   fmt.Sprintf("increase(%s[%s]) == 0", g.selector(g.config.MetricPrefix+"monitor_samples_total", ""), duration),
+			Labels: [][2]string{{"severity", "critical"}, {"kind", "device_down"}},
+			Annotations: [][2]string{
+				{"summary", fmt.Sprintf("No interface sample from the router for %s", duration)},
+				{"description", fmt.Sprintf("The monitor is running but its router queries fail (%s:%s)", g.config.Host, g.config.Port)},
+			},
+		},
+	}
+}
+
+// directionSelectors returns the RX/TX selectors of a direction for interfaces ("*" = all)
+// Upload is RX on LAN interfaces and TX on uplinks; download the opposite.
+func (g *alertRuleGenerator) directionSelectors(names []string, direction string) []rateSelector {
+	all := containsString(names, "*")
+	if all {
+		names = g.names
+	}
+
+	var lan, uplinks []string
+	for _, name := range names {
+		if g.isUplink(name) {
+			uplinks = append(uplinks, name)
+		} else {
+			lan = append(lan, name)
+		}
+	}
+	lanMetric, uplinkMetric := "rx", "tx"
+	if direction == "download" {
+		lanMetric, uplinkMetric = "tx", "rx"
+	}
+
+	var selectors []rateSelector
+	switch {
+	case all && len(uplinks) == 0:
+		selectors = append(selectors, rateSelector{metric: lanMetric})
+	case all:
+		// Interfaces added later are treated as LAN interfaces, like locally
+		selectors = append(selectors, rateSelector{lanMetric, interfaceMatchers(uplinks, "!~")})
+	case len(lan) > 0:
+		selectors = append(selectors, rateSelector{lanMetric, interfaceMatchers(lan, "=~")})
+	}
+	if len(uplinks) > 0 {
+		selectors = append(selectors, rateSelector{uplinkMetric, interfaceMatchers(uplinks, "=~")})
+	}
+	return selectors
+}
+
+// rateSeries returns the rate selector of the source
+// With source "vm", stat picks the window series (avg or peak).
+func (g *alertRuleGenerator) rateSeries(selector rateSelector, stat string) string {
+	metric := fmt.Sprintf("%sinterface_%s_rate", g.config.MetricPrefix, selector.metric)
+	matchers := selector.matchers
+	if g.source == "vm" {
+		metric += "_" + stat
+		interval := fmt.Sprintf("interval=%q", intervalLabel(g.config.VictoriaMetrics.Interval))
+		if matchers == "" {
+			matchers = interval
+		} else {
+			matchers += "," + interval
+		}
+	}
+	return g.selector(metric, matchers)
+}
+
+// selector adds EXTRA_LABELS to the matchers of a metric
+func (g *alertRuleGenerator) selector(metric, matchers string) string {
+	if extra := formatExtraLabels(g.config.ExtraLabels); extra != "" {
+		if matchers == "" {
+			matchers = extra
+		} else {
+			matchers += "," + extra
+		}
+	}
+	if matchers == "" {
+		return metric
+	}
+	return metric + "{" + matchers + "}"
+}
+
+// unitSuffix is the unit of rate values in annotations
+func (g *alertRuleGenerator) unitSuffix() string {
+	if g.config.MetricUnit == "bits" {
+		return "bit/s"
+	}
+	return "B/s"
+}
+
+// interfaceMatchers matches the interface label against names ("" for "*")
+func interfaceMatchers(names []string, op string) string {
+	pattern := namesPattern(names)
+	if pattern == "" {
+		return ""
+	}
+	if len(names) == 1 {
+		// Exact match for a single interface
+		exact := map[string]string{"=~": "=", "!~": "!="}[op]
+		return fmt.Sprintf("interface%s%s", exact, strconv.Quote(names[0]))
+	}
+	return fmt.Sprintf("interface%s%s", op, strconv.Quote(pattern))
+}
+
+// namesPattern returns a regular expression matching any of names ("" if names contains "*")
+func namesPattern(names []string) string {
+	if containsString(names, "*") {
+		return ""
+	}
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	return strings.Join(quoted, "|")
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// formatPromNumber renders a float without exponent ("100000000", "2.5")
+func formatPromNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// formatPromDuration renders a duration in Prometheus syntax ("30s", "5m", "1h30m")
+func formatPromDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d <= 0 {
+		return "0s"
+	}
+	var out string
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{{"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}} {
+		if n := d / unit.size; n > 0 {
+			out += fmt.Sprintf("%d%s", n, unit.suffix)
+			d -= n * unit.size
+		}
+	}
+	return out
+}
+
+// writeAlertRules writes rules as a Prometheus/vmalert rule file with one group
+func writeAlertRules(w io.Writer, group string, interval time.Duration, rules []alertRule, header string) {
+	fmt.Fprintf(w, "# %s\n", header)
+	fmt.Fprintln(w, "groups:")
+	fmt.Fprintf(w, "  - name: %s\n", yamlScalar(group))
+	if interval > 0 {
+		fmt.Fprintf(w, "    interval: %s\n", formatPromDuration(interval))
+	}
+	fmt.Fprintln(w, "    rules:")
+	for _, rule := range rules {
+		fmt.Fprintf(w, "      - alert: %s\n", rule.Name)
+		fmt.Fprintf(w, "        expr: %s\n", yamlScalar(rule.Expr))
+		if rule.For > 0 {
+			fmt.Fprintf(w, "        for: %s\n", formatPromDuration(rule.For))
+		}
+		for _, section := range []struct {
+			name  string
+			pairs [][2]string
+		}{{"labels", rule.Labels}, {"annotations", rule.Annotations}} {
+			if len(section.pairs) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "        %s:\n", section.name)
+			for _, pair := range section.pairs {
+				fmt.Fprintf(w, "          %s: %s\n", pair[0], yamlScalar(pair[1]))
+			}
+		}
+	}
+}
+
+// plainYAML matches strings that need no quoting in YAML
+var plainYAML = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// yamlScalar renders a YAML scalar, single-quoted unless plain
+// Single quotes only need ' doubled, so PromQL and templates stay readable.
+func yamlScalar(value string) string {
+	if plainYAML.MatchString(value) && value != "true" && value != "false" && value != "null" {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ============================================================================
+// Rate Alerts (thresholds, drop to zero, anomalies) -> Webhook
+// ============================================================================
+
+// Alert kinds
+const (
+	AlertThreshold = "threshold" // Rate crossed a configured limit
+	AlertZero      = "zero"      // Interface stopped passing traffic
+	AlertAnomaly   = "anomaly"   // Rate deviates N sigma from its rolling average
+	AlertNetwatch  = "netwatch"  // Netwatch host on the router went down
+)
+
+// Alert statuses
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// Alert is one notification; rates are in bits/s like the configured thresholds
+type Alert struct {
+	Time      time.Time `json:"time"`
+	Interface string    `json:"interface"`
+	Host      string    `json:"host,omitempty"` // Netwatch: checked host (Interface is empty)
+	Label     string    `json:"label,omitempty"`
+	Kind      string    `json:"kind"`                // threshold, zero, anomaly or netwatch
+	Direction string    `json:"direction,omitempty"` // upload or download (empty for zero)
+	Status    string    `json:"status"`              // firing or resolved
+	Value     float64   `json:"value"`               // Current rate (bits/s)
+	Threshold float64   `json:"threshold,omitempty"` // Threshold rules: limit (bits/s)
+	Mean      float64   `json:"mean,omitempty"`      // Anomaly: rolling average (bits/s)
+	StdDev    float64   `json:"stddev,omitempty"`    // Anomaly: rolling standard deviation (bits/s)
+	Message   string    `json:"message"`
+
+	targets []string // Rule targets (empty: ALERT_TARGETS)
+}
+
+// AlertRule is a rate threshold on one interface (or "*" for every interface)
+type AlertRule struct {
+	Interface string   // Interface name or "*"
+	Direction string   // upload or download
+	Above     bool     // true: fire above Threshold, false: fire below
+	Threshold float64  // bits/s
+	Targets   []string // Notification targets (empty: ALERT_TARGETS)
+}
+
+// String renders the rule in ALERT_THRESHOLDS syntax
+func (r AlertRule) String() string {
+	op := "<"
+	if r.Above {
+		op = ">"
+	}
+	rule := fmt.Sprintf("%s:%s%s%s", r.Interface, r.Direction, op, formatLinkSpeed(r.Threshold))
+	if len(r.Targets) > 0 {
+		rule += "@" + strings.Join(r.Targets, "+")
+	}
+	return rule
+}
+
+// parseAlertRules parses "ether1:download>800M,*:upload>100M@telegram+slack" (bits/s with k/M/G suffix)
+// The optional @target+target suffix sends the rule's alerts to those targets only.
+func parseAlertRules(value string) ([]AlertRule, error) {
+	var rules []AlertRule
+	for _, entry := range parseCommaSeparated(value, "") {
+		entry, targets, _ := strings.Cut(entry, "@")
+		name, condition, ok := strings.Cut(entry, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid rule %q (expected interface:direction>rate)", entry)
+		}
+
+		rule := AlertRule{Interface: name}
+		direction, limit, above := strings.Cut(condition, ">")
+		if !above {
+			var below bool
+			direction, limit, below = strings.Cut(condition, "<")
+			if !below {
+				return nil, fmt.Errorf("invalid rule %q (missing > or <)", entry)
+			}
+		}
+		rule.Above = above
+		rule.Direction = strings.ToLower(strings.TrimSpace(direction))
+		if rule.Direction != "upload" && rule.Direction != "download" {
+			return nil, fmt.Errorf("invalid rule %q (direction must be upload or download)", entry)
+		}
+		threshold, err := parseRate(limit)
+		if err != nil || threshold <= 0 {
+			return nil, fmt.Errorf("invalid rule %q (bad rate %q)", entry, limit)
+		}
+		rule.Threshold = threshold
+		if targets != "" {
+			rule.Targets = strings.Split(strings.ToLower(targets), "+")
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// alertState tracks one alert key (interface + kind + direction) for deduplication
+type alertState struct {
+	firing   bool      // Condition currently active
+	notified bool      // A firing notification was sent for the current activation
+	lastSent time.Time // Last firing notification (cooldown)
+}
+
+// rollingWindow keeps the last N values for mean/standard deviation
+type rollingWindow struct {
+	values     []float64
+	next       int
+	sum, sumSq float64
+}
+
+// add appends a value, evicting the oldest when full
+func (w *rollingWindow) add(value float64, size int) {
+	if len(w.values) < size {
+		w.values = append(w.values, value)
+	} else {
+		old := w.values[w.next]
+		w.sum -= old
+		w.sumSq -= old * old
+		w.values[w.next] = value
+		w.next = (w.next + 1) % size
+	}
+	w.sum += value
+	w.sumSq += value * value
+}
+
+// stats returns the mean and standard deviation of the window
+func (w *rollingWindow) stats() (mean, sd float64) {
+	n := len(w.values)
+	if n == 0 {
+		return 0, 0
+	}
+	return w.sum / float64(n), stddev(w.sum, w.sumSq, n)
+}
+
+// AlertEngine evaluates every sample against the alert rules and hands
+// notifications to the webhook and the chat notifiers
+//
+// A notification is sent when a condition becomes active (deduplicated while it
+// stays active) and, if a firing notification went out, when it clears. A key
+// that fired within ALERT_COOLDOWN is not notified again, so a flapping rate
+// doesn't flood the receiver.
+type AlertEngine struct {
+	config   *AlertsConfig
+	rules    []AlertRule
+	zero     map[string]bool // Interfaces watched for zero traffic ("*" = all)
+	anomaly  map[string]bool // Interfaces watched for anomalies ("*" = all)
+	netwatch map[string]bool // Netwatch hosts or names watched for down ("*" = all)
+	isUplink func(string) bool
+	labels   func(string) string
+
+	webhook   *alertWebhook // nil without ALERT_WEBHOOK_URL
+	notifiers *Notifiers    // Chat notifiers (nil if none configured)
+
+	states      map[string]*alertState
+	windows     map[string]*rollingWindow // interface/direction -> recent rates (bits/s)
+	lastTraffic map[string]time.Time      // Last sample with traffic per interface
+}
+
+// NewAlertEngine creates an alert engine; isUplink and labels come from the user configuration
+func NewAlertEngine(config *AlertsConfig, notifiers *Notifiers, isUplink func(string) bool, labels func(string) string) *AlertEngine {
+	logInfo("Alerts", "Alerting initialized (thresholds: %q, zero: %v, anomaly: %v at %.1f sigma, netwatch: %v, cooldown: %v)",
+		config.Thresholds, config.ZeroInterfaces, config.AnomalyInterfaces, config.AnomalySigma, config.NetwatchHosts, config.Cooldown)
+
+	// Rules and template were validated with the config
+	rules, _ := parseAlertRules(config.Thresholds)
+	var webhook *alertWebhook
+	if config.WebhookURL != "" {
+		webhook = newAlertWebhook(config)
+	}
+	return &AlertEngine{
+		config:      config,
+		rules:       rules,
+		zero:        toSet(config.ZeroInterfaces),
+		anomaly:     toSet(config.AnomalyInterfaces),
+		netwatch:    toSet(config.NetwatchHosts),
+		isUplink:    isUplink,
+		labels:      labels,
+		webhook:     webhook,
+		notifiers:   notifiers,
+		states:      make(map[string]*alertState),
+		windows:     make(map[string]*rollingWindow),
+		lastTraffic: make(map[string]time.Time),
+	}
+}
+
+// Evaluate checks one sample of every interface
+func (e *AlertEngine) Evaluate(now time.Time, stats map[string]*RateInfo) {
+	for name, info := range stats {
+		// Rates in bits/s, in the user perspective like the thresholds
+		upload, download := info.RxRate*8, info.TxRate*8
+		if e.isUplink(name) {
+			upload, download = info.TxRate*8, info.RxRate*8
+		}
+		rates := map[string]float64{"upload": upload, "download": download}
+
+		for _, rule := range e.rules {
+			if rule.Interface != "*" && rule.Interface != name {
+				continue
+			}
+			value := rates[rule.Direction]
+			active := value > rule.Threshold
+			relation := "above"
+			if !rule.Above {
+				active = value < rule.Threshold
+				relation = "below"
+			}
+			e.update(now, name+"/"+rule.String(), active, Alert{
+				Interface: name,
+				Kind:      AlertThreshold,
+				Direction: rule.Direction,
+				Value:     value,
+				Threshold: rule.Threshold,
+				Message:   fmt.Sprintf("%s %s %s %s (%s)", name, rule.Direction, relation, formatAlertRate(rule.Threshold), formatAlertRate(value)),
+				targets:   rule.Targets,
+			})
+		}
+
+		if e.zero["*"] || e.zero[name] {
+			e.evaluateZero(now, name, upload+download)
+		}
+
+		if e.anomaly["*"] || e.anomaly[name] {
+			for _, direction := range []string{"upload", "download"} {
+				e.evaluateAnomaly(now, name, direction, rates[direction])
+			}
+		}
+	}
+}
+
+// EvaluateNetwatch checks the latest netwatch states (nil before the first poll)
+// Called with every sample, so the alert follows the netwatch poll interval.
+func (e *AlertEngine) EvaluateNetwatch(now time.Time, snapshot *NetwatchSnapshot) {
+	if snapshot == nil || len(e.netwatch) == 0 {
+		return
+	}
+	for _, entry := range snapshot.Entries {
+		if !e.netwatch["*"] && !e.netwatch[entry.Host] && !e.netwatch[entry.Name] {
+			continue
+		}
+		message := netwatchDisplayName(entry) + " is down"
+		if !entry.Since.IsZero() {
+			message += " since " + entry.Since.Format("15:04:05")
+		}
+		e.update(now, "netwatch/"+entry.Host, entry.Status == "down", Alert{
+			Host:    entry.Host,
+			Label:   entry.Name,
+			Kind:    AlertNetwatch,
+			Message: message,
+		})
+	}
+}
+
+// evaluateZero fires when an interface that had traffic passes none for ALERT_ZERO_DURATION
+func (e *AlertEngine) evaluateZero(now time.Time, name string, total float64) {
+	if total > 0 {
+		e.lastTraffic[name] = now
+	}
+	last, seen := e.lastTraffic[name]
+	active := seen && total == 0 && now.Sub(last) >= e.config.ZeroDuration
+	e.update(now, name+"/zero", active, Alert{
+		Interface: name,
+		Kind:      AlertZero,
+		Value:     total,
+		Message:   fmt.Sprintf("%s has passed no traffic since %s", name, last.Format("15:04:05")),
+	})
+}
+
+// evaluateAnomaly compares a rate with the rolling window before adding it
+// The check starts once half of the window is filled.
+func (e *AlertEngine) evaluateAnomaly(now time.Time, name, direction string, value float64) {
+	key := name + "/" + direction
+	window, ok := e.windows[key]
+	if !ok {
+		window = &rollingWindow{}
+		e.windows[key] = window
+	}
+
+	mean, sd := window.stats()
+	ready := len(window.values) >= e.config.AnomalyWindow/2 && sd > 0
+	active := ready && math.Abs(value-mean) > e.config.AnomalySigma*sd
+	window.add(value, e.config.AnomalyWindow)
+
+	if !ready {
+		return
+	}
+	e.update(now, key+"/anomaly", active, Alert{
+		Interface: name,
+		Kind:      AlertAnomaly,
+		Direction: direction,
+		Value:     value,
+		Mean:      mean,
+		StdDev:    sd,
+		Message: fmt.Sprintf("%s %s %s is %.1f sigma from its average %s", name, direction,
+			formatAlertRate(value), math.Abs(value-mean)/sd, formatAlertRate(mean)),
+	})
+}
+
+// formatAlertRate renders a rate in bits/s for messages ("812.40Mbps")
+func formatAlertRate(bits float64) string {
+	return formatNumeric(bits/8, "bps", "auto") + "bps"
+}
+
+// update applies deduplication and cooldown to a condition and notifies on transitions
+// key identifies the condition (interface, kind, direction and rule).
+func (e *AlertEngine) update(now time.Time, key string, active bool, alert Alert) {
+	state, ok := e.states[key]
+	if !ok {
+		state = &alertState{}
+		e.states[key] = state
+	}
+
+	switch {
+	case active && !state.firing:
+		state.firing = true
+		if !state.lastSent.IsZero() && now.Sub(state.lastSent) < e.config.Cooldown {
+			logDebug("Alerts", "Suppressed (cooldown): %s", alert.Message)
+			return
+		}
+		state.notified = true
+		state.lastSent = now
+		alert.Status = AlertFiring
+	case !active && state.firing:
+		state.firing = false
+		if !state.notified {
+			return // The firing notification was suppressed, so is the resolution
+		}
+		state.notified = false
+		alert.Status = AlertResolved
+		switch alert.Kind {
+		case AlertZero:
+			alert.Message = alert.Interface + " passes traffic again"
+		case AlertNetwatch:
+			alert.Message = netwatchDisplayName(NetwatchEntry{Host: alert.Host, Name: alert.Label}) + " is up again"
+		default:
+			alert.Message = "resolved: " + alert.Message
+		}
+	default:
+		return // No transition (deduplicated)
+	}
+
+	alert.Time = now
+	if alert.Interface != "" {
+		alert.Label = e.labels(alert.Interface)
+	}
+	if alert.Status == AlertFiring {
+		logWarn("Alerts", "%s", alert.Message)
+	} else {
+		logInfo("Alerts", "%s", alert.Message)
+	}
+	e.send(alert)
+}
+
+// send delivers an alert to its targets (the rule's, else ALERT_TARGETS, else everything configured)
+func (e *AlertEngine) send(alert Alert) {
+	targets := alert.targets
+	if len(targets) == 0 {
+		targets = e.config.Targets
+	}
+	if e.webhook != nil && (len(targets) == 0 || toSet(targets)["webhook"]) {
+		e.webhook.Send(alert)
+	}
+	e.notifiers.Send(targets, alertNotification(alert))
+}
+
+// alertNotification renders an alert as a chat message
+func alertNotification(alert Alert) Notification {
+	subject := alert.Interface
+	if alert.Kind == AlertNetwatch {
+		subject = alert.Host
+	}
+	title := "🔴 Alert: " + subject
+	if alert.Status == AlertResolved {
+		title = "✅ Resolved: " + subject
+	}
+	if alert.Label != "" && alert.Label != subject {
+		title += " (" + alert.Label + ")"
+	}
+	return Notification{Title: title, Text: alert.Message}
+}
+
+// defaultAlertTemplate is the webhook body when ALERT_WEBHOOK_TEMPLATE is not set
+const defaultAlertTemplate = "{{json .}}"
+
+// alertWebhook sends alerts to ALERT_WEBHOOK_URL using the configured method and body template
+type alertWebhook struct {
+	config   *AlertsConfig
+	template *template.Template
+	client   *http.Client
+}
+
+// newAlertWebhook creates a webhook sender
+func newAlertWebhook(config *AlertsConfig) *alertWebhook {
+	tmpl, _ := parseAlertTemplate(config.WebhookTemplate)
+	return &alertWebhook{
+		config:   config,
+		template: tmpl,
+		client:   &http.Client{Timeout: config.WebhookTimeout},
+	}
+}
+
+// parseAlertTemplate compiles a body template
+// {{json .}} renders the whole alert as JSON, {{rate .Value}} a rate such as "812.40Mbps".
+func parseAlertTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultAlertTemplate
+	}
+	return template.New("alert").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+		"rate": formatAlertRate,
+	}).Parse(text)
+}
+
+// Send delivers one alert in the background so a slow receiver never delays sampling
+func (h *alertWebhook) Send(alert Alert) {
+	go func() {
+		if err := h.post(alert); err != nil {
+			logError("Alerts", "Webhook for %s %s failed: %v", alert.Interface, alert.Kind, err)
+		}
+	}()
+}
+
+// post renders the body and sends the request
+func (h *alertWebhook) post(alert Alert) error {
+	var body bytes.Buffer
+	if err := h.template.Execute(&body, alert); err != nil {
+		return fmt.Errorf("render template: %w", err)
+	}
+
+	req, err := http.NewRequest(h.config.WebhookMethod, h.config.WebhookURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", h.config.WebhookContentType)
+	req.Header.Set("User-Agent", "mikrotik-interface-stats/"+Version)
+	for k, v := range h.config.WebhookHeaders {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Management Session Audit (/user/active and login log messages)
+// ============================================================================
+
+// ManagementSession is one logged-in management user (/user/active)
+type ManagementSession struct {
+	User    string    `json:"user"`
+	Address string    `json:"address,omitempty"` // Empty for console sessions
+	Via     string    `json:"via"`               // winbox, ssh, telnet, api, web, console...
+	Group   string    `json:"group,omitempty"`
+	Since   time.Time `json:"since"` // Login time (zero if unknown)
+}
+
+// AuthEvent is a login, logout or login failure read from the router log
+type AuthEvent struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"` // login, logout or failure
+	User    string    `json:"user"`
+	Address string    `json:"address,omitempty"`
+	Via     string    `json:"via,omitempty"`
+	Message string    `json:"message"`
+}
+
+// FailureSource counts the login failures from one address in the failure window
+type FailureSource struct {
+	Address string    `json:"address"`
+	Count   int       `json:"count"`
+	Last    time.Time `json:"last"`
+}
+
+// AuditSnapshot is the result of one collection cycle
+type AuditSnapshot struct {
+	Timestamp time.Time           `json:"timestamp"`
+	Sessions  []ManagementSession `json:"sessions"` // Sorted by login time
+	Events    []AuthEvent         `json:"events"`   // Newest first
+
+	Window      float64         `json:"window"`       // Failure window (seconds)
+	Failures    int             `json:"failures"`     // Login failures in the window
+	SourceCount int             `json:"source_count"` // Distinct failing addresses in the window
+	Sources     []FailureSource `json:"sources"`      // Top failing addresses in the window, most failures first
+
+	LoginsTotal   map[string]uint64 `json:"logins_total"`   // Logins seen since start, by service
+	FailuresTotal map[string]uint64 `json:"failures_total"` // Login failures seen since start, by service
+}
+
+const (
+	maxAuditEvents   = 100   // Events kept for the web UI
+	maxAuditFailures = 10000 // Failures kept for the window counts (bounds memory during an attack)
+	maxAuditSources  = 20    // Addresses listed in a snapshot
+)
+
+// authMessagePattern matches the RouterOS login messages:
+//
+//	login failure for user admin from 192.0.2.7 via ssh
+//	user admin logged in from 192.0.2.7 via winbox
+//	user admin logged out from 192.0.2.7 via winbox
+//	user admin logged in via local
+var authMessagePattern = regexp.MustCompile(`^(?:login failure for user (\S+)|user (\S+) logged (in|out))(?: from (\S+))?(?: via (\S+))?`)
+
+// auditFailure is one login failure inside the failure window
+type auditFailure struct {
+	time    time.Time
+	address string
+}
+
+// AuditCollector polls the active management sessions and the router log
+//
+// The log is read in full each poll (it is a ring buffer of a few hundred lines
+// in memory) and only entries with a higher .id than the last poll are new, so
+// failures are counted once. Entries already in the buffer at startup fill the
+// event list and the failure window, but not the totals.
+type AuditCollector struct {
+	client RouterClient
+	config *AuditConfig
+
+	// Only used by the collector goroutine
+	lastID    uint64
+	started   bool
+	events    []AuthEvent    // Newest last
+	failures  []auditFailure // Oldest first
+	logins    map[string]uint64
+	failTotal map[string]uint64
+
+	latest   *AuditSnapshot
+	latestMu sync.RWMutex
+}
+
+// NewAuditCollector creates a new management session audit collector
+func NewAuditCollector(client RouterClient, config *AuditConfig) *AuditCollector {
+	logInfo("Audit", "Management session audit initialized (interval: %v, log topics: %v, failure window: %v)",
+		config.Interval, config.LogTopics, config.FailureWindow)
+
+	return &AuditCollector{
+		client:    client,
+		config:    config,
+		logins:    make(map[string]uint64),
+		failTotal: make(map[string]uint64),
+	}
+}
+
+// Collect reads the active sessions and the new login messages
+func (a *AuditCollector) Collect(ctx context.Context, now time.Time) (*AuditSnapshot, error) {
+	results, errs := RunAll(ctx, a.client,
+		[]string{"/user/active/print", "=.proplist=name,address,via,group,when"},
+		[]string{"/log/print", "=.proplist=.id,time,topics,message"},
+	)
+	if errs[0] != nil {
+		return nil, fmt.Errorf("active users: %w", errs[0])
+	}
+	if errs[1] != nil {
+		return nil, fmt.Errorf("log: %w", errs[1])
+	}
+
+	sessions := make([]ManagementSession, 0, len(results[0]))
+	for _, row := range results[0] {
+		sessions = append(sessions, ManagementSession{
+			User:    row["name"],
+			Address: row["address"],
+			Via:     row["via"],
+			Group:   row["group"],
+			Since:   parseRouterOSTime(row["when"], now.Location()),
+		})
+	}
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].Since.Before(sessions[j].Since) })
+
+	a.readLog(results[1], now)
+
+	snapshot := &AuditSnapshot{
+		Timestamp:     now,
+		Sessions:      sessions,
+		Events:        make([]AuthEvent, 0, len(a.events)),
+		Window:        a.config.FailureWindow.Seconds(),
+		LoginsTotal:   make(map[string]uint64, len(a.logins)),
+		FailuresTotal: make(map[string]uint64, len(a.failTotal)),
+	}
+	for i := len(a.events) - 1; i >= 0; i-- {
+		snapshot.Events = append(snapshot.Events, a.events[i])
+	}
+	for via, count := range a.logins {
+		snapshot.LoginsTotal[via] = count
+	}
+	for via, count := range a.failTotal {
+		snapshot.FailuresTotal[via] = count
+	}
+
+	// Failures per address in the window
+	cutoff := now.Add(-a.config.FailureWindow)
+	start := sort.Search(len(a.failures), func(i int) bool { return !a.failures[i].time.Before(cutoff) })
+	a.failures = a.failures[start:]
+	sources := make(map[string]*FailureSource)
+	for _, failure := range a.failures {
+		source, ok := sources[failure.address]
+		if !ok {
+			source = &FailureSource{Address: failure.address}
+			sources[failure.address] = source
+		}
+		source.Count++
+		if failure.time.After(source.Last) {
+			source.Last = failure.time
+		}
+	}
+	snapshot.Failures = len(a.failures)
+	snapshot.SourceCount = len(sources)
+	for _, source := range sources {
+		snapshot.Sources = append(snapshot.Sources, *source)
+	}
+	sort.Slice(snapshot.Sources, func(i, j int) bool {
+		if snapshot.Sources[i].Count != snapshot.Sources[j].Count {
+			return snapshot.Sources[i].Count > snapshot.Sources[j].Count
+		}
+		return snapshot.Sources[i].Address < snapshot.Sources[j].Address
+	})
+	if len(snapshot.Sources) > maxAuditSources {
+		snapshot.Sources = snapshot.Sources[:maxAuditSources]
+	}
+
+	a.latestMu.Lock()
+	a.latest = snapshot
+	a.latestMu.Unlock()
+
+	return snapshot, nil
+}
+
+// readLog records the login messages of the log entries not seen yet
+func (a *AuditCollector) readLog(rows []map[string]string, now time.Time) {
+	// IDs restart when the router reboots or the log is cleared
+	var highest uint64
+	for _, row := range rows {
+		highest = max(highest, parseLogID(row[".id"]))
+	}
+	if highest < a.lastID {
+		logInfo("Audit", "Router log was reset, reading it from the start")
+		a.lastID = 0
+	}
+
+	history := !a.started
+	a.started = true
+	newFailures := make(map[string]int)
+	for _, row := range rows {
+		id := parseLogID(row[".id"])
+		if id <= a.lastID {
+			continue
+		}
+		if !a.topicSelected(row["topics"]) {
+			continue
+		}
+		event, ok := parseAuthMessage(row["message"])
+		if !ok {
+			continue
+		}
+		event.Time = parseLogTime(row["time"], now)
+
+		a.events = append(a.events, event)
+		switch event.Kind {
+		case "failure":
+			a.failures = append(a.failures, auditFailure{time: event.Time, address: event.Address})
+			if !history {
+				a.failTotal[event.Via]++
+				newFailures[event.Address]++
+			}
+		case "login":
+			if !history {
+				a.logins[event.Via]++
+				logInfo("Audit", "%s", event.Message)
+			}
+		}
+	}
+	a.lastID = max(a.lastID, highest)
+
+	if len(a.events) > maxAuditEvents {
+		a.events = append([]AuthEvent(nil), a.events[len(a.events)-maxAuditEvents:]...)
+	}
+	if len(a.failures) > maxAuditFailures {
+		a.failures = append([]auditFailure(nil), a.failures[len(a.failures)-maxAuditFailures:]...)
+	}
+	// Log entries are in order, but a time without a date may be parsed on the wrong side of midnight
+	sort.SliceStable(a.failures, func(i, j int) bool { return a.failures[i].time.Before(a.failures[j].time) })
+
+	if len(newFailures) > 0 {
+		addresses := make([]string, 0, len(newFailures))
+		total := 0
+		for address, count := range newFailures {
+			addresses = append(addresses, fmt.Sprintf("%s (%d)", address, count))
+			total += count
+		}
+		sort.Strings(addresses)
+		logWarn("Audit", "%d login failures from %s", total, strings.Join(addresses, ", "))
+	}
+}
+
+// topicSelected reports whether a log entry has one of the AUDIT_LOG_TOPICS
+func (a *AuditCollector) topicSelected(topics string) bool {
+	for _, topic := range strings.Split(topics, ",") {
+		for _, selected := range a.config.LogTopics {
+			if topic == selected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Latest returns the most recent snapshot (nil before the first collection)
+func (a *AuditCollector) Latest() *AuditSnapshot {
+	a.latestMu.RLock()
+	defer a.latestMu.RUnlock()
+	return a.latest
+}
+
+// parseAuthMessage converts a login log message to an event
+func parseAuthMessage(message string) (AuthEvent, bool) {
+	match := authMessagePattern.FindStringSubmatch(message)
+	if match == nil {
+		return AuthEvent{}, false
+	}
+
+	event := AuthEvent{Address: match[4], Via: match[5], Message: message}
+	switch {
+	case match[1] != "":
+		event.Kind = "failure"
+		event.User = match[1]
+	case match[3] == "in":
+		event.Kind = "login"
+		event.User = match[2]
+	default:
+		event.Kind = "logout"
+		event.User = match[2]
+	}
+	if event.Via == "" {
+		event.Via = "unknown"
+	}
+	return event, true
+}
+
+// parseLogID converts a log entry ID ("*1A3") to a number (0 if invalid)
+func parseLogID(id string) uint64 {
+	value, err := strconv.ParseUint(strings.TrimPrefix(id, "*"), 16, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// parseLogTime parses the time of a log entry in the router's clock
+// Entries of today only have a time ("15:04:05"); older ones add the date without
+// the year ("jan/02 15:04:05", "01-02 15:04:05") or with it. Falls back to now.
+// The router's clock is assumed to run in now's zone (TIMEZONE).
+func parseLogTime(value string, now time.Time) time.Time {
+	loc := now.Location()
+	if t := parseRouterOSTime(value, loc); !t.IsZero() {
+		return t
+	}
+	if t, err := time.ParseInLocation("15:04:05", value, loc); err == nil {
+		return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+	}
+	for _, layout := range []string{"Jan/02 15:04:05", "01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			t = t.AddDate(now.Year(), 0, 0)
+			if t.After(now.AddDate(0, 0, 1)) {
+				t = t.AddDate(-1, 0, 0) // Last year's entry
+			}
+			return t
+		}
+	}
+	return now
+}
+
+// auditMetrics renders management sessions and login counters in Prometheus text format
+// Samples carry the snapshot timestamp when withTimestamp is set (VictoriaMetrics push).
+func auditMetrics(snapshot *AuditSnapshot, withTimestamp bool) string {
+	timestamp := ""
+	if withTimestamp {
+		timestamp = fmt.Sprintf(" %d", snapshot.Timestamp.UnixMilli())
+	}
+
+	sessions := make(map[string]int)
+	for _, session := range snapshot.Sessions {
+		sessions[session.Via]++
+	}
+
+	var out strings.Builder
+	fmt.Fprintln(&out, "# HELP mikrotik_management_sessions Logged-in management users per service")
+	fmt.Fprintln(&out, "# TYPE mikrotik_management_sessions gauge")
+	for _, via := range sortedKeys(sessions) {
+		fmt.Fprintf(&out, "mikrotik_management_sessions{via=\"%s\"} %d%s\n", escapeLabelValue(via), sessions[via], timestamp)
+	}
+	if len(snapshot.LoginsTotal) > 0 {
+		fmt.Fprintln(&out, "# HELP mikrotik_management_logins_total Logins seen in the router log since the monitor started")
+		fmt.Fprintln(&out, "# TYPE mikrotik_management_logins_total counter")
+		for _, via := range sortedKeys(snapshot.LoginsTotal) {
+			fmt.Fprintf(&out, "mikrotik_management_logins_total{via=\"%s\"} %d%s\n", escapeLabelValue(via), snapshot.LoginsTotal[via], timestamp)
+		}
+	}
+	if len(snapshot.FailuresTotal) > 0 {
+		fmt.Fprintln(&out, "# HELP mikrotik_login_failures_total Login failures seen in the router log since the monitor started")
+		fmt.Fprintln(&out, "# TYPE mikrotik_login_failures_total counter")
+		for _, via := range sortedKeys(snapshot.FailuresTotal) {
+			fmt.Fprintf(&out, "mikrotik_login_failures_total{via=\"%s\"} %d%s\n", escapeLabelValue(via), snapshot.FailuresTotal[via], timestamp)
+		}
+	}
+	fmt.Fprintln(&out, "# HELP mikrotik_login_failures_recent Login failures in the failure window")
+	fmt.Fprintln(&out, "# TYPE mikrotik_login_failures_recent gauge")
+	fmt.Fprintf(&out, "mikrotik_login_failures_recent %d%s\n", snapshot.Failures, timestamp)
+	fmt.Fprintln(&out, "# HELP mikrotik_login_failure_sources Addresses with login failures in the failure window")
+	fmt.Fprintln(&out, "# TYPE mikrotik_login_failure_sources gauge")
+	fmt.Fprintf(&out, "mikrotik_login_failure_sources %d%s\n", snapshot.SourceCount, timestamp)
+	return out.String()
+}
+
+// sortedKeys returns the keys of a per-service count map in order
+func sortedKeys[V int | uint64](counts map[string]V) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Web Authentication
+// ============================================================================
+
+const sessionCookieName = "mikrotik_session"
+
+// WebAuth implements optional authentication for the web server
+// WEB_AUTH_USER and the API tokens see everything; web users (see WebUser) log in
+// the same way but only see their interfaces, read-only.
+// API tokens issued through /api/admin/tokens are limited to their scopes.
+// Accepted credentials (any one is sufficient):
+//   - Session cookie issued by POST /api/login
+//   - Authorization: Bearer <token> (static or issued API tokens)
+//   - ?token=<token> query parameter (for WebSocket clients that cannot set headers)
+//   - HTTP basic auth (browsers prompt automatically on 401)
+type WebAuth struct {
+	username   string
+	password   string
+	tokens     map[string]bool // SHA-256 of the static API tokens (hashToken)
+	sessionTTL time.Duration
+	cookiePath string             // Base path of the web UI
+	users      *UserConfigManager // Web users
+
+	sessions   map[string]webSession // Session ID -> session
+	sessionsMu sync.Mutex
+}
+
+// webSession is a login session
+type webSession struct {
+	expiry time.Time
+	user   string // Web user name ("" for WEB_AUTH_USER)
+}
+
+// NewWebAuth creates the authenticator from web config (nil if auth is disabled)
+func NewWebAuth(config *WebConfig, users *UserConfigManager) *WebAuth {
+	if config.AuthUser == "" && len(config.AuthTokens) == 0 {
+		if users != nil && len(users.WebUsers()) > 0 {
+			logWarn("Web", "Web users are configured but authentication is disabled: everyone sees all interfaces")
+		}
+		return nil
+	}
+
+	logInfo("Web", "Authentication enabled (basic auth: %v, api tokens: %d)",
+		config.AuthUser != "", len(config.AuthTokens))
+
+	return &WebAuth{
+		username:   config.AuthUser,
+		password:   config.AuthPass,
+		tokens:     hashTokens(config.AuthTokens),
+		sessionTTL: config.SessionTTL,
+		cookiePath: config.BasePath + "/",
+		users:      users,
+		sessions:   make(map[string]webSession),
+	}
+}
+
+// Middleware rejects unauthenticated requests with 401, and requests outside
+// a web user's read-only view with 403
+// Login is always reachable so the session cookie can be obtained,
+// and health probes so orchestrators do not need credentials
+func (a *WebAuth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/login" || r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		id := a.authenticate(r)
+		if id == nil {
+			rw.Header().Set("WWW-Authenticate", `Basic realm="Mikrotik Interface Monitor"`)
+			http.Error(rw, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !id.allows(r) {
+			http.Error(rw, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(rw, withIdentity(r, id))
+	})
+}
+
+// authenticate checks all supported credential types (nil if none is valid)
+func (a *WebAuth) authenticate(r *http.Request) *webIdentity {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if session, ok := a.validSession(cookie.Value); ok {
+			if session.user == "" {
+				return adminIdentity
+			}
+			if id := a.userIdentity(session.user); id != nil {
+				return id
+			}
+		}
+	}
+
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		if id := a.tokenIdentity(strings.TrimPrefix(header, "Bearer ")); id != nil {
+			return id
+		}
+	}
+
+	if token := r.URL.Query().Get("token"); token != "" {
+		if id := a.tokenIdentity(token); id != nil {
+			return id
+		}
+	}
+
+	if user, pass, ok := r.BasicAuth(); ok {
+		if a.validCredentials(user, pass) {
+			return adminIdentity
+		}
+		if a.validUser(user, pass) {
+			return a.userIdentity(user)
+		}
+	}
+
+	return nil
+}
+
+// tokenIdentity returns the identity of a static or issued API token (nil if unknown)
+func (a *WebAuth) tokenIdentity(token string) *webIdentity {
+	if a.validToken(token) {
+		return adminIdentity
+	}
+	if token == "" {
+		return nil
+	}
+	if _, issued, ok := a.users.FindAPIToken(hashToken(token)); ok {
+		return issued.identity()
+	}
+	return nil
+}
+
+// validUser checks the password of a web user
+func (a *WebAuth) validUser(user, pass string) bool {
+	account, ok := a.users.WebUser(user)
+	return ok && account.checkPassword(pass)
+}
+
+// userIdentity returns the identity of a web user (nil if the user was deleted)
+func (a *WebAuth) userIdentity(user string) *webIdentity {
+	account, ok := a.users.WebUser(user)
+	if !ok {
+		return nil
+	}
+	return &webIdentity{user: user, interfaces: toSet(account.Interfaces), scopes: webUserScopes}
+}
+
+// validCredentials compares username/password in constant time
+func (a *WebAuth) validCredentials(user, pass string) bool {
+	if a.username == "" {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(a.username)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(a.password)) == 1
+	return userOK && passOK
+}
+
+// hashTokens returns the set of the hashes of tokens
+func hashTokens(tokens []string) map[string]bool {
+	hashes := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		hashes[hashToken(token)] = true
+	}
+	return hashes
+}
+
+// validToken checks a static API token
+// The lookup is by hash, like issued tokens, so its timing reveals nothing about the token.
+func (a *WebAuth) validToken(token string) bool {
+	return token != "" && a.tokens[hashToken(token)]
+}
+
+// validSession checks a session ID and drops it if expired
+func (a *WebAuth) validSession(id string) (webSession, bool) {
+	a.sessionsMu.Lock()
+	defer a.sessionsMu.Unlock()
+
+	session, ok := a.sessions[id]
+	if !ok {
+		return webSession{}, false
+	}
+	if time.Now().After(session.expiry) {
+		delete(a.sessions, id)
+		return webSession{}, false
+	}
+	return session, true
+}
+
+// newSession creates a random session ID with the configured TTL for a user ("" for WEB_AUTH_USER)
+func (a *WebAuth) newSession(user string) (string, time.Time, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	id := hex.EncodeToString(buf)
+	expiry := time.Now().Add(a.sessionTTL)
+
+	a.sessionsMu.Lock()
+	defer a.sessionsMu.Unlock()
+
+	// Opportunistically purge expired sessions
+	now := time.Now()
+	for sid, session := range a.sessions {
+		if now.After(session.expiry) {
+			delete(a.sessions, sid)
+		}
+	}
+	a.sessions[id] = webSession{expiry: expiry, user: user}
+
+	return id, expiry, nil
+}
+
+// handleLogin issues a session cookie for valid username/password (POST JSON body)
+func (a *WebAuth) handleLogin(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(rw, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	var user string
+	switch {
+	case a.validCredentials(body.Username, body.Password):
+	case a.validUser(body.Username, body.Password):
+		user = body.Username
+	default:
+		logWarn("Web", "Failed login attempt for user %q from %s", body.Username, r.RemoteAddr)
+		http.Error(rw, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
+
+	id, expiry, err := a.newSession(user)
+	if err != nil {
+		http.Error(rw, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    id,
+		Path:     a.cookiePath,
+		Expires:  expiry,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		Secure:   isSecureRequest(r),
+	})
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(map[string]string{"status": "ok", "expires": expiry.Format(time.RFC3339)})
+}
+
+// handleLogout invalidates the current session cookie
+func (a *WebAuth) handleLogout(rw http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		a.sessionsMu.Lock()
+		delete(a.sessions, cookie.Value)
+		a.sessionsMu.Unlock()
+	}
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:   sessionCookieName,
+		Value:  "",
+		Path:   a.cookiePath,
+		MaxAge: -1,
+	})
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(map[string]string{"status": "ok"})
+}
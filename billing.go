@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Burstable Billing (monthly per-customer exports)
+// ============================================================================
+
+// billingExportSchedule writes last month's export at 01:00 on the 1st (local time)
+const billingExportSchedule = "0 1 1 * *"
+
+// BillingSeries is the stored traffic of one interface over a billing month (RX/TX naming)
+type BillingSeries struct {
+	RxBytes, TxBytes float64           // Counter increase over the month
+	Rx, Tx           map[int64]float64 // 5-minute averages (bytes/s) by Unix timestamp
+}
+
+// BillingCustomer is the usage of one customer in a billing month (upload/download perspective)
+type BillingCustomer struct {
+	Customer           string   `json:"customer"`
+	Interfaces         []string `json:"interfaces"`
+	Download           float64  `json:"download"`            // bytes
+	Upload             float64  `json:"upload"`              // bytes
+	DownloadPercentile float64  `json:"download_percentile"` // bytes/s, Nth percentile of 5-minute averages
+	UploadPercentile   float64  `json:"upload_percentile"`
+	Billable           float64  `json:"billable"`      // Higher of the two percentiles (bytes/s)
+	DownloadPeak       float64  `json:"download_peak"` // Highest 5-minute average (bytes/s)
+	UploadPeak         float64  `json:"upload_peak"`
+	Samples            int      `json:"samples"` // 5-minute averages in the month
+}
+
+// BillingReport is the billing export of one calendar month
+type BillingReport struct {
+	Month      string            `json:"month"` // 2025-11
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Complete   bool              `json:"complete"` // false for the current month (month to date)
+	Percentile float64           `json:"percentile"`
+	Generated  time.Time         `json:"generated"`
+	Customers  []BillingCustomer `json:"customers"`
+}
+
+// BillingGenerator builds per-customer billing reports from the stored history
+//
+// Each customer is a set of interfaces or interface groups. Its download is
+// the TX of downlinks plus the RX of uplinks (and the other way round for the
+// upload), summed per 5-minute average before the percentile is taken, so a
+// customer on several VLANs is billed on their combined traffic. Reports of
+// complete months are cached; with BILLING_DIR, last month's report is written
+// as CSV and JSON on the 1st (and on startup if missing).
+type BillingGenerator struct {
+	config    *BillingConfig
+	location  *time.Location // TIMEZONE: billing months start at its midnight
+	customers []InterfaceGroup
+	vm        *VMClient
+	isUplink  func(string) bool
+
+	cache map[string]*BillingReport // Complete months
+	mu    sync.Mutex
+}
+
+// NewBillingGenerator creates a billing generator; isUplink comes from the user configuration
+// Months are calendar months in location (the TIMEZONE zone).
+func NewBillingGenerator(config *BillingConfig, location *time.Location, vm *VMClient, isUplink func(string) bool) *BillingGenerator {
+	customers := parseInterfaceGroups(config.Customers) // Validated with the config
+	export := "no export files"
+	if config.Dir != "" {
+		export = "exports to " + config.Dir
+	}
+	logInfo("Billing", "Billing initialized (%d customers, %gth percentile, %s)", len(customers), config.Percentile, export)
+
+	return &BillingGenerator{
+		config:    config,
+		location:  location,
+		customers: customers,
+		vm:        vm,
+		isUplink:  isUplink,
+		cache:     make(map[string]*BillingReport),
+	}
+}
+
+// billingMonth returns the calendar month containing t (in t's zone)
+func billingMonth(t time.Time) (start, end time.Time) {
+	start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
+// Report returns the billing report of the month containing month
+// The current month is reported up to now and never cached.
+func (g *BillingGenerator) Report(month time.Time) (*BillingReport, error) {
+	now := time.Now()
+	start, end := billingMonth(month.In(g.location))
+	if start.After(now) {
+		return nil, fmt.Errorf("month %s has not started", start.Format("2006-01"))
+	}
+	key := start.Format("2006-01")
+	complete := !end.After(now)
+
+	g.mu.Lock()
+	cached := g.cache[key]
+	g.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	until := end
+	if !complete {
+		until = now.Truncate(time.Minute)
+	}
+	var names []string
+	for _, customer := range g.customers {
+		names = append(names, customer.Members...)
+	}
+	series, err := g.vm.QueryBilling(names, start, until)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BillingReport{
+		Month:      key,
+		Start:      start,
+		End:        end,
+		Complete:   complete,
+		Percentile: g.config.Percentile,
+		Generated:  now,
+		Customers:  make([]BillingCustomer, 0, len(g.customers)),
+	}
+	for _, customer := range g.customers {
+		report.Customers = append(report.Customers, g.customer(customer, series))
+	}
+	sort.Slice(report.Customers, func(i, j int) bool {
+		return report.Customers[i].Customer < report.Customers[j].Customer
+	})
+
+	if complete {
+		g.mu.Lock()
+		g.cache[key] = report
+		g.mu.Unlock()
+	}
+	return report, nil
+}
+
+// customer sums the traffic of a customer's interfaces, oriented as download/upload
+func (g *BillingGenerator) customer(customer InterfaceGroup, series map[string]*BillingSeries) BillingCustomer {
+	usage := BillingCustomer{Customer: customer.Name, Interfaces: customer.Members}
+	download := make(map[int64]float64)
+	upload := make(map[int64]float64)
+	for _, name := range customer.Members {
+		s := series[name]
+		if s == nil {
+			continue
+		}
+		// Uplink: RX = download; downlink: TX = download
+		down, up, downBytes, upBytes := s.Tx, s.Rx, s.TxBytes, s.RxBytes
+		if g.isUplink(name) {
+			down, up, downBytes, upBytes = s.Rx, s.Tx, s.RxBytes, s.TxBytes
+		}
+		usage.Download += downBytes
+		usage.Upload += upBytes
+		for ts, value := range down {
+			download[ts] += value
+		}
+		for ts, value := range up {
+			upload[ts] += value
+		}
+	}
+
+	downloads := make([]float64, 0, len(download))
+	for _, value := range download {
+		downloads = append(downloads, value)
+		usage.DownloadPeak = max(usage.DownloadPeak, value)
+	}
+	uploads := make([]float64, 0, len(upload))
+	for _, value := range upload {
+		uploads = append(uploads, value)
+		usage.UploadPeak = max(usage.UploadPeak, value)
+	}
+	usage.DownloadPercentile = percentile(downloads, g.config.Percentile)
+	usage.UploadPercentile = percentile(uploads, g.config.Percentile)
+	usage.Billable = max(usage.DownloadPercentile, usage.UploadPercentile)
+	usage.Samples = max(len(downloads), len(uploads))
+	return usage
+}
+
+// CSV renders one line per customer; rates in bits/s
+func (r *BillingReport) CSV() string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write([]string{"customer", "interfaces", "month", "download_bytes", "upload_bytes",
+		"download_percentile_bps", "upload_percentile_bps", "billable_bps",
+		"download_peak_bps", "upload_peak_bps", "samples"})
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 0, 64) }
+	for _, c := range r.Customers {
+		w.Write([]string{c.Customer, strings.Join(c.Interfaces, "+"), r.Month, f(c.Download), f(c.Upload),
+			f(c.DownloadPercentile * 8), f(c.UploadPercentile * 8), f(c.Billable * 8),
+			f(c.DownloadPeak * 8), f(c.UploadPeak * 8), strconv.Itoa(c.Samples)})
+	}
+	w.Flush()
+	return b.String()
+}
+
+// Run writes last month's export to BILLING_DIR on the 1st until ctx is cancelled
+// A missing export of last month is written right away (monitor down on the 1st).
+func (g *BillingGenerator) Run(ctx context.Context) {
+	if g.config.Dir == "" {
+		return
+	}
+	schedule, _ := parseCron(billingExportSchedule)
+
+	lastMonth := func(now time.Time) time.Time {
+		start, _ := billingMonth(now)
+		return start.AddDate(0, -1, 0)
+	}
+	if month := lastMonth(time.Now().In(g.location)); !g.exported(month) {
+		g.export(month)
+	}
+
+	for {
+		next := schedule.Next(time.Now().In(g.location))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			g.export(lastMonth(now.In(g.location)))
+		}
+	}
+}
+
+// exportPath returns the export file of a month without extension
+func (g *BillingGenerator) exportPath(month time.Time) string {
+	return filepath.Join(g.config.Dir, "billing-"+month.Format("2006-01"))
+}
+
+// exported reports whether the export of a month was written
+func (g *BillingGenerator) exported(month time.Time) bool {
+	_, err := os.Stat(g.exportPath(month) + ".csv")
+	return err == nil
+}
+
+// export writes the report of a month as CSV and JSON
+func (g *BillingGenerator) export(month time.Time) {
+	report, err := g.Report(month)
+	if err != nil {
+		logError("Billing", "Failed to build the %s billing report: %v", month.Format("2006-01"), err)
+		return
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		logError("Billing", "Failed to encode the %s billing report: %v", report.Month, err)
+		return
+	}
+
+	base := g.exportPath(month)
+	if err := os.MkdirAll(g.config.Dir, 0755); err != nil {
+		logError("Billing", "Failed to create %s: %v", g.config.Dir, err)
+		return
+	}
+	if err := os.WriteFile(base+".json", append(data, '\n'), 0644); err != nil {
+		logError("Billing", "Failed to write %s.json: %v", base, err)
+		return
+	}
+	if err := os.WriteFile(base+".csv", []byte(report.CSV()), 0644); err != nil {
+		logError("Billing", "Failed to write %s.csv: %v", base, err)
+		return
+	}
+	logInfo("Billing", "Billing report %s written to %s.{csv,json} (%d customers)", report.Month, base, len(report.Customers))
+}
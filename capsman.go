@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/firadio/golang-mikrotik-interface-stats/pkg/routeros"
+)
+
+// ============================================================================
+// CAPsMAN Collector (per-AP and per-SSID WiFi stats)
+// ============================================================================
+
+// CAPsMANGroup is the traffic of the clients of one SSID on one AP radio
+// Rates are in the AP perspective (RX = uploaded by clients, TX = downloaded).
+type CAPsMANGroup struct {
+	AP       string  `json:"ap"`       // Radio interface (master of the SSID's virtual interfaces)
+	Identity string  `json:"identity"` // System identity of the CAP ("" if unknown)
+	SSID     string  `json:"ssid"`
+	Clients  int     `json:"clients"`
+	RxRate   float64 `json:"rx_rate"` // bytes/s
+	TxRate   float64 `json:"tx_rate"` // bytes/s
+	Signal   float64 `json:"signal"`  // Average client signal strength (dBm, 0 if unknown)
+}
+
+// CAPsMANSnapshot is the result of one collection cycle
+type CAPsMANSnapshot struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Groups    []CAPsMANGroup `json:"groups"` // Sorted by AP and SSID
+}
+
+// capsmanMenus are the CAPsMAN menus of RouterOS v6 (and v7 with the legacy
+// wireless package) and of the v7 wifi package, tried in this order
+var capsmanMenus = []string{"/caps-man", "/interface/wifi"}
+
+// CAPsMANCollector polls the CAPsMAN registration table and sums client traffic per AP and SSID
+//
+// With local forwarding, client traffic never passes the manager, so the
+// interface counters of CAP interfaces stay at zero. The byte counters of the
+// registration table are reported by the CAPs in both forwarding modes, so the
+// rates are computed from them: per client between two polls, then summed. A
+// client that roams or reconnects starts over and is counted from its next poll.
+type CAPsMANCollector struct {
+	client RouterClient
+	config *CAPsMANConfig
+
+	menu     int                  // Index in capsmanMenus (only used by the collector goroutine)
+	counters map[string][2]uint64 // interface/MAC -> rx, tx bytes at the last poll
+	lastPoll time.Time
+
+	latest   *CAPsMANSnapshot
+	latestMu sync.RWMutex
+}
+
+// NewCAPsMANCollector creates a new CAPsMAN collector
+func NewCAPsMANCollector(client RouterClient, config *CAPsMANConfig) *CAPsMANCollector {
+	logInfo("CAPsMAN", "CAPsMAN collector initialized (interval: %v)", config.Interval)
+
+	return &CAPsMANCollector{
+		client: client,
+		config: config,
+	}
+}
+
+// Collect queries the registration table and computes the per-AP/SSID rates
+// The first poll only records the counters and returns a nil snapshot.
+func (c *CAPsMANCollector) Collect(ctx context.Context, now time.Time) (*CAPsMANSnapshot, error) {
+	results, errs := c.query(ctx)
+	for errors.Is(errs[0], routeros.ErrNoSuchCommand) && c.menu+1 < len(capsmanMenus) {
+		c.menu++
+		logInfo("CAPsMAN", "Using %s", capsmanMenus[c.menu])
+		results, errs = c.query(ctx)
+	}
+	if errs[0] != nil {
+		return nil, fmt.Errorf("registration table: %w", errs[0])
+	}
+	if errs[1] != nil {
+		logWarn("CAPsMAN", "%s/print failed, SSIDs are grouped by their own interface: %v", capsmanMenus[c.menu], errs[1])
+	}
+
+	// Virtual SSID interfaces belong to the radio (master) interface
+	masters := make(map[string]string)
+	for _, row := range results[1] {
+		if master := row["master-interface"]; master != "" && master != "none" {
+			masters[row["name"]] = master
+		}
+	}
+	identities := make(map[string]string)
+	if len(results) > 2 && errs[2] == nil {
+		for _, row := range results[2] {
+			identities[row["interface"]] = row["remote-cap-identity"]
+		}
+	}
+
+	elapsed := now.Sub(c.lastPoll).Seconds()
+	first := c.lastPoll.IsZero()
+	counters := make(map[string][2]uint64, len(results[0]))
+	groups := make(map[string]*CAPsMANGroup)
+	signals := make(map[string]int) // Clients with a signal reading per group
+
+	for _, row := range results[0] {
+		iface := row["interface"]
+		ap := iface
+		if master, ok := masters[iface]; ok {
+			ap = master
+		}
+		key := ap + "\x00" + row["ssid"]
+		group, ok := groups[key]
+		if !ok {
+			group = &CAPsMANGroup{AP: ap, Identity: identities[ap], SSID: row["ssid"]}
+			groups[key] = group
+		}
+		group.Clients++
+
+		signal := row["rx-signal"]
+		if signal == "" {
+			signal = row["signal"] // wifi package
+		}
+		if value, err := strconv.ParseFloat(signal, 64); err == nil {
+			group.Signal += value
+			signals[key]++
+		}
+
+		// bytes=tx,rx as seen by the AP
+		tx, rx, ok := parseCounterPair(row["bytes"])
+		if !ok {
+			continue
+		}
+		client := iface + "/" + row["mac-address"]
+		counters[client] = [2]uint64{rx, tx}
+		if previous, seen := c.counters[client]; seen && elapsed > 0 && rx >= previous[0] && tx >= previous[1] {
+			group.RxRate += float64(rx-previous[0]) / elapsed
+			group.TxRate += float64(tx-previous[1]) / elapsed
+		}
+	}
+	c.counters = counters
+	c.lastPoll = now
+	if first {
+		return nil, nil
+	}
+
+	snapshot := &CAPsMANSnapshot{Timestamp: now, Groups: make([]CAPsMANGroup, 0, len(groups))}
+	for key, group := range groups {
+		if n := signals[key]; n > 0 {
+			group.Signal /= float64(n)
+		}
+		snapshot.Groups = append(snapshot.Groups, *group)
+	}
+	sort.Slice(snapshot.Groups, func(i, j int) bool {
+		if snapshot.Groups[i].AP != snapshot.Groups[j].AP {
+			return snapshot.Groups[i].AP < snapshot.Groups[j].AP
+		}
+		return snapshot.Groups[i].SSID < snapshot.Groups[j].SSID
+	})
+
+	c.latestMu.Lock()
+	c.latest = snapshot
+	c.latestMu.Unlock()
+
+	return snapshot, nil
+}
+
+// query runs the registration table, interface and (v6) radio queries pipelined
+func (c *CAPsMANCollector) query(ctx context.Context) ([][]map[string]string, []error) {
+	menu := capsmanMenus[c.menu]
+	commands := [][]string{
+		{menu + "/registration-table/print", "=.proplist=interface,ssid,mac-address,bytes,rx-signal,signal"},
+		{menu + "/print", "=.proplist=name,master-interface"},
+	}
+	if menu == "/caps-man" {
+		commands[1][0] = "/caps-man/interface/print"
+		commands = append(commands, []string{"/caps-man/radio/print", "=.proplist=interface,remote-cap-identity"})
+	}
+	return RunAll(ctx, c.client, commands...)
+}
+
+// Latest returns the most recent snapshot (nil before the second collection)
+func (c *CAPsMANCollector) Latest() *CAPsMANSnapshot {
+	c.latestMu.RLock()
+	defer c.latestMu.RUnlock()
+	return c.latest
+}
+
+// parseCounterPair parses a RouterOS counter pair such as bytes=1234,5678
+func parseCounterPair(value string) (first, second uint64, ok bool) {
+	firstText, secondText, found := strings.Cut(value, ",")
+	if !found {
+		return 0, 0, false
+	}
+	first, err1 := strconv.ParseUint(firstText, 10, 64)
+	second, err2 := strconv.ParseUint(secondText, 10, 64)
+	return first, second, err1 == nil && err2 == nil
+}
+
+// capsmanMetrics renders CAPsMAN groups in Prometheus text format
+// Rates are multiplied by rateScale; samples carry the snapshot timestamp when withTimestamp is set.
+func capsmanMetrics(snapshot *CAPsMANSnapshot, rateScale float64, withTimestamp bool) string {
+	timestamp := ""
+	if withTimestamp {
+		timestamp = fmt.Sprintf(" %d", snapshot.Timestamp.UnixMilli())
+	}
+
+	var clients, rx, tx, signal strings.Builder
+	for _, group := range snapshot.Groups {
+		series := fmt.Sprintf("ap=\"%s\",ssid=\"%s\"", escapeLabelValue(group.AP), escapeLabelValue(group.SSID))
+		if group.Identity != "" {
+			series += fmt.Sprintf(",identity=\"%s\"", escapeLabelValue(group.Identity))
+		}
+		fmt.Fprintf(&clients, "mikrotik_capsman_clients{%s} %d%s\n", series, group.Clients, timestamp)
+		fmt.Fprintf(&rx, "mikrotik_capsman_rx_rate{%s} %.2f%s\n", series, group.RxRate*rateScale, timestamp)
+		fmt.Fprintf(&tx, "mikrotik_capsman_tx_rate{%s} %.2f%s\n", series, group.TxRate*rateScale, timestamp)
+		if group.Signal != 0 {
+			fmt.Fprintf(&signal, "mikrotik_capsman_signal_dbm{%s} %.1f%s\n", series, group.Signal, timestamp)
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintln(&out, "# HELP mikrotik_capsman_clients Registered WiFi clients per AP and SSID")
+	fmt.Fprintln(&out, "# TYPE mikrotik_capsman_clients gauge")
+	fmt.Fprint(&out, clients.String())
+	fmt.Fprintln(&out, "# HELP mikrotik_capsman_rx_rate Traffic received from the clients of an AP and SSID per second")
+	fmt.Fprintln(&out, "# TYPE mikrotik_capsman_rx_rate gauge")
+	fmt.Fprint(&out, rx.String())
+	fmt.Fprintln(&out, "# HELP mikrotik_capsman_tx_rate Traffic sent to the clients of an AP and SSID per second")
+	fmt.Fprintln(&out, "# TYPE mikrotik_capsman_tx_rate gauge")
+	fmt.Fprint(&out, tx.String())
+	if signal.Len() > 0 {
+		fmt.Fprintln(&out, "# HELP mikrotik_capsman_signal_dbm Average client signal strength per AP and SSID")
+		fmt.Fprintln(&out, "# TYPE mikrotik_capsman_signal_dbm gauge")
+		fmt.Fprint(&out, signal.String())
+	}
+	return out.String()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// ============================================================================
+// Change Filter (skip output of unchanged rates)
+// ============================================================================
+
+// emittedRates are the rates of an interface at its last emitted sample
+type emittedRates struct {
+	rx, tx float64
+	at     time.Time
+}
+
+// changeFilter decides which interfaces changed enough since they were last emitted
+//
+// Mostly idle interfaces report the same few bytes per second sample after
+// sample; with a filter the log and WebSocket outputs only emit them when a
+// rate moves by more than the configured delta, plus a heartbeat so consumers
+// can tell an idle interface from a stopped monitor. A new interface is always
+// emitted. Only used by the goroutine of its output.
+type changeFilter struct {
+	config *ChangeFilterConfig
+	last   map[string]emittedRates
+}
+
+// newChangeFilter creates a change filter (nil if the output emits every sample)
+func newChangeFilter(config *ChangeFilterConfig) *changeFilter {
+	if config == nil {
+		return nil
+	}
+	return &changeFilter{config: config, last: make(map[string]emittedRates)}
+}
+
+// changed reports whether an interface should be emitted at timestamp
+// The rates are remembered as emitted when it returns true.
+func (f *changeFilter) changed(name string, info *RateInfo, timestamp time.Time) bool {
+	if !f.due(name, info, timestamp) {
+		return false
+	}
+	f.last[name] = emittedRates{rx: info.RxRate, tx: info.TxRate, at: timestamp}
+	return true
+}
+
+// due reports whether an interface is new, moved or reached its heartbeat
+func (f *changeFilter) due(name string, info *RateInfo, timestamp time.Time) bool {
+	last, ok := f.last[name]
+	return !ok || f.moved(last.rx, info.RxRate) || f.moved(last.tx, info.TxRate) ||
+		(f.config.Heartbeat > 0 && timestamp.Sub(last.at) >= f.config.Heartbeat)
+}
+
+// moved reports whether a rate differs from the emitted one by more than the delta
+func (f *changeFilter) moved(emitted, rate float64) bool {
+	diff := math.Abs(rate - emitted)
+	return diff > f.config.Delta*emitted && diff > f.config.MinChangeRate
+}
+
+// anyChanged reports whether any interface of a sample should be emitted and,
+// if so, remembers all of them as emitted (for outputs sending whole samples)
+func (f *changeFilter) anyChanged(stats map[string]*RateInfo, timestamp time.Time) bool {
+	changed := false
+	for name, info := range stats {
+		if f.due(name, info, timestamp) {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return false
+	}
+
+	clear(f.last)
+	for name, info := range stats {
+		f.last[name] = emittedRates{rx: info.RxRate, tx: info.TxRate, at: timestamp}
+	}
+	return true
+}
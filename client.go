@@ -1,215 +1,191 @@
 package main
 
 import (
-	"crypto/md5"
-	"encoding/hex"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
-	"log"
 	"net"
-	"strings"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
+
+	"github.com/firadio/golang-mikrotik-interface-stats/pkg/routeros"
 )
 
 // Mikrotik API Client
-// Implements the Mikrotik RouterOS API protocol
-// Reference: https://help.mikrotik.com/docs/display/ROS/API
-//
-// Protocol uses length-encoded words with MD5 challenge-response authentication
-// Supports both old API (with challenge) and new API (direct password)
-
-// MikrotikClient represents a connection to a Mikrotik router
-type MikrotikClient struct {
-	conn net.Conn // TCP connection to Mikrotik API
+// The RouterOS API protocol itself lives in pkg/routeros so other programs can
+// import it; this file selects and configures the transport.
+
+// RouterClient abstracts the transport used to talk to RouterOS
+// Implemented by MikrotikClient (binary API, port 8728), RESTClient (RouterOS v7 REST)
+// and SNMPClient (SNMPv2c fallback, interface counters only)
+// Implementations must be safe for concurrent use by multiple collectors
+type RouterClient interface {
+	GetInterfaceStats(ctx context.Context, interfaces []string) ([]InterfaceStats, error) // Query interface counters
+	Run(ctx context.Context, words ...string) ([]map[string]string, error)                // Run an API-style command
+	Close() error                                                                         // Close the connection
 }
 
-// NewMikrotikClient creates a new Mikrotik API client and performs login
-func NewMikrotikClient(config *Config) (*MikrotikClient, error) {
-	address := net.JoinHostPort(config.Host, config.Port)
-	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+// NewRouterClient creates a client for the configured transport
+// In read-only mode (default) commands are checked against the allowlist.
+func NewRouterClient(ctx context.Context, config *Config) (RouterClient, error) {
+	var client RouterClient
+	var err error
+	switch {
+	case config.Mock != nil:
+		client, err = NewMockClient(ctx, config)
+	case config.Transport == "rest":
+		client, err = NewRESTClient(ctx, config)
+	case config.Transport == "snmp":
+		client, err = NewSNMPClient(ctx, config)
+	default:
+		client, err = NewMikrotikClient(ctx, config)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect: %w", err)
+		return nil, err
 	}
 
-	client := &MikrotikClient{conn: conn}
-
-	// Login
-	if err := client.login(config.Username, config.Password); err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to login: %w", err)
+	if config.ReadOnly {
+		return newCommandGuard(client, config.AllowedCommands, controlCommands(config)), nil
 	}
-
 	return client, nil
 }
 
-// Close closes the connection to the Mikrotik router
-func (c *MikrotikClient) Close() error {
-	return c.conn.Close()
+// MikrotikClient is a binary API connection (pkg/routeros) configured from Config
+// Safe for concurrent use: commands from different collectors are pipelined on
+// the shared connection instead of waiting for each other's round-trips.
+type MikrotikClient struct {
+	*routeros.Client
 }
 
-// writeWord writes a word to the Mikrotik API using their length encoding
-func (c *MikrotikClient) writeWord(w string) error {
-	length := len(w)
-	var lengthBytes []byte
-
-	if length < 0x80 {
-		lengthBytes = []byte{byte(length)}
-	} else if length < 0x4000 {
-		lengthBytes = []byte{byte(length>>8) | 0x80, byte(length)}
-	} else if length < 0x200000 {
-		lengthBytes = []byte{byte(length>>16) | 0xC0, byte(length >> 8), byte(length)}
-	} else if length < 0x10000000 {
-		lengthBytes = []byte{byte(length>>24) | 0xE0, byte(length >> 16), byte(length >> 8), byte(length)}
-	} else {
-		lengthBytes = []byte{0xF0, byte(length >> 24), byte(length >> 16), byte(length >> 8), byte(length)}
-	}
-
-	if _, err := c.conn.Write(lengthBytes); err != nil {
-		return err
-	}
-	if _, err := c.conn.Write([]byte(w)); err != nil {
-		return err
-	}
-	return nil
+// loginMethods maps MIKROTIK_LOGIN to the routeros login variants
+var loginMethods = map[string]routeros.LoginMethod{
+	"auto":      routeros.LoginAuto,
+	"plain":     routeros.LoginPlain,
+	"challenge": routeros.LoginChallenge,
 }
 
-// readWord reads a word from the Mikrotik API using their length encoding
-func (c *MikrotikClient) readWord() (string, error) {
-	c.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
-
-	firstByte := make([]byte, 1)
-	if _, err := io.ReadFull(c.conn, firstByte); err != nil {
-		return "", err
+// NewMikrotikClient creates a new Mikrotik API client and performs login
+func NewMikrotikClient(ctx context.Context, config *Config) (*MikrotikClient, error) {
+	host, port, err := resolveRouterAddress(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
 	}
 
-	var length int
-	b := firstByte[0]
-
-	if (b & 0x80) == 0 {
-		length = int(b)
-	} else if (b & 0xC0) == 0x80 {
-		secondByte := make([]byte, 1)
-		if _, err := io.ReadFull(c.conn, secondByte); err != nil {
-			return "", err
-		}
-		length = ((int(b) & ^0x80) << 8) + int(secondByte[0])
-	} else if (b & 0xE0) == 0xC0 {
-		bytes := make([]byte, 2)
-		if _, err := io.ReadFull(c.conn, bytes); err != nil {
-			return "", err
-		}
-		length = ((int(b) & ^0xC0) << 16) + (int(bytes[0]) << 8) + int(bytes[1])
-	} else if (b & 0xF0) == 0xE0 {
-		bytes := make([]byte, 3)
-		if _, err := io.ReadFull(c.conn, bytes); err != nil {
-			return "", err
-		}
-		length = ((int(b) & ^0xE0) << 24) + (int(bytes[0]) << 16) + (int(bytes[1]) << 8) + int(bytes[2])
-	} else if (b & 0xF8) == 0xF0 {
-		bytes := make([]byte, 4)
-		if _, err := io.ReadFull(c.conn, bytes); err != nil {
-			return "", err
-		}
-		length = (int(bytes[0]) << 24) + (int(bytes[1]) << 16) + (int(bytes[2]) << 8) + int(bytes[3])
+	options := routeros.Options{
+		Username: config.Username,
+		Password: config.Password,
+		Timeout:  config.CommandTimeout,
+		Login:    loginMethods[config.LoginMethod],
+		Debugf: func(format string, args ...any) {
+			logDebug("Client", format, args...)
+		},
+		Record: sessionRecording(config),
 	}
-
-	if length == 0 {
-		return "", nil
+	// api-ssl: the router needs a certificate assigned to the service (/ip service set api-ssl certificate=...)
+	if config.TLS {
+		options.TLSConfig = &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: config.TLSInsecure,
+		}
 	}
 
-	data := make([]byte, length)
-	if _, err := io.ReadFull(c.conn, data); err != nil {
-		return "", err
+	client, err := routeros.Dial(ctx, net.JoinHostPort(host, port), options)
+	if err != nil {
+		return nil, err
 	}
-
-	return string(data), nil
+	return &MikrotikClient{Client: client}, nil
 }
 
-// sendCommand sends a command to the Mikrotik API
-func (c *MikrotikClient) sendCommand(words ...string) error {
-	for _, word := range words {
-		if err := c.writeWord(word); err != nil {
-			return err
-		}
+// sessionRecording returns the transcript file of a new connection (nil without MIKROTIK_RECORD)
+func sessionRecording(config *Config) io.Writer {
+	if config.Record == "" {
+		return nil
 	}
-	return c.writeWord("")
+	return &sessionFile{dir: config.Record}
 }
 
-// readResponse reads a response from the Mikrotik API
-func (c *MikrotikClient) readResponse() ([]map[string]string, error) {
-	var result []map[string]string
-	currentItem := make(map[string]string)
-	debug := false // Set to true for debugging
-
-	for {
-		word, err := c.readWord()
-		if err != nil {
-			if debug {
-				log.Printf("DEBUG readResponse: error reading word: %v", err)
-			}
-			return nil, err
-		}
-
-		if debug {
-			log.Printf("DEBUG readResponse: word='%s'", word)
-		}
+// sessionFile is a transcript in MIKROTIK_RECORD, created on the first write so
+// that connection attempts failing before the login leave no file behind
+type sessionFile struct {
+	dir  string
+	file *os.File
+}
 
-		// Empty word is just a sentence delimiter in Mikrotik API, not end of response
-		if word == "" {
-			continue
+func (f *sessionFile) Write(p []byte) (int, error) {
+	if f.file == nil {
+		if err := os.MkdirAll(f.dir, 0o700); err != nil {
+			logWarn("Client", "Cannot record the API session: %v", err)
+			return 0, err
 		}
-
-		if strings.HasPrefix(word, "!done") {
-			if len(currentItem) > 0 {
-				result = append(result, currentItem)
-			}
-			break
-		} else if strings.HasPrefix(word, "!trap") || strings.HasPrefix(word, "!fatal") {
-			return nil, fmt.Errorf("error response: %s", word)
-		} else if strings.HasPrefix(word, "!re") {
-			if len(currentItem) > 0 {
-				result = append(result, currentItem)
-				currentItem = make(map[string]string)
-			}
-		} else if strings.HasPrefix(word, "=") {
-			parts := strings.SplitN(word[1:], "=", 2)
-			if len(parts) == 2 {
-				currentItem[parts[0]] = parts[1]
-			}
+		path := filepath.Join(f.dir, "routeros-"+time.Now().Format("20060102-150405.000")+".txt")
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			logWarn("Client", "Cannot record the API session: %v", err)
+			return 0, err
 		}
+		logInfo("Client", "Recording the API session to %s", path)
+		f.file = file
 	}
-
-	return result, nil
+	return f.file.Write(p)
 }
 
-// login performs authentication with the Mikrotik router
-func (c *MikrotikClient) login(username, password string) error {
-	// Send login command
-	if err := c.sendCommand("/login", "=name="+username, "=password="+password); err != nil {
-		return err
+func (f *sessionFile) Close() error {
+	if f.file == nil {
+		return nil
 	}
+	return f.file.Close()
+}
 
-	// Read response
-	responses, err := c.readResponse()
-	if err != nil {
-		return err
+// routerVersion returns the RouterOS version detected by a client (zero if unknown or SNMP)
+func routerVersion(client RouterClient) routeros.Version {
+	switch c := client.(type) {
+	case *commandGuard:
+		return routerVersion(c.RouterClient)
+	case *MikrotikClient:
+		return c.Version()
+	case *RESTClient:
+		return c.version
 	}
+	return routeros.Version{}
+}
 
-	// Check for challenge (old API)
-	if len(responses) > 0 {
-		if challenge, ok := responses[0]["ret"]; ok {
-			// Old API with challenge
-			hash := md5.Sum([]byte("\x00" + password + challenge))
-			hashedPassword := hex.EncodeToString(hash[:])
-
-			if err := c.sendCommand("/login", "=name="+username, "=response=00"+hashedPassword); err != nil {
-				return err
-			}
+// checkRouterVersion logs the detected version and warns about versions the transport handles poorly
+func checkRouterVersion(config *Config, version routeros.Version) {
+	if !version.Known() {
+		logDebug("Client", "RouterOS version unknown (the user may lack the read policy)")
+		return
+	}
+	logInfo("Client", "RouterOS %s", version)
+
+	switch {
+	case !version.AtLeast(6, 0):
+		logWarn("Client", "RouterOS %s is not supported (6.0 or newer required), some collectors may fail", version)
+	case config.Transport == "rest" && !version.AtLeast(7, 1):
+		logWarn("Client", "RouterOS %s has no REST API (7.1 or newer required), use MIKROTIK_TRANSPORT=api", version)
+	case config.Transport == "api" && !version.PlainLogin():
+		logWarn("Client", "RouterOS %s uses the legacy challenge login; consider upgrading to 6.43 or newer", version)
+	}
+}
 
-			_, err := c.readResponse()
-			return err
-		}
+// RunAll runs several commands concurrently and returns their results in order
+// On the binary API the commands are pipelined over one connection, so the total
+// time is about one round-trip instead of one per command (REST issues parallel requests).
+func RunAll(ctx context.Context, client RouterClient, commands ...[]string) ([][]map[string]string, []error) {
+	rows := make([][]map[string]string, len(commands))
+	errs := make([]error, len(commands))
+
+	var wg sync.WaitGroup
+	for i, cmd := range commands {
+		wg.Add(1)
+		go func(i int, cmd []string) {
+			defer wg.Done()
+			rows[i], errs[i] = client.Run(ctx, cmd...)
+		}(i, cmd)
 	}
+	wg.Wait()
 
-	return nil
+	return rows, errs
 }
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Client Directory (DHCP leases + ARP table)
+// ============================================================================
+
+// Client is a host on the router's networks, identified by IP address
+type Client struct {
+	Address   string `json:"address"`
+	Name      string `json:"name,omitempty"` // Best label: comment, hostname or MAC
+	MAC       string `json:"mac,omitempty"`
+	HostName  string `json:"host_name,omitempty"` // DHCP client hostname
+	Comment   string `json:"comment,omitempty"`   // Lease or ARP comment set on the router
+	Interface string `json:"interface,omitempty"` // ARP interface
+	Status    string `json:"status,omitempty"`    // DHCP lease status (bound, waiting, ...)
+	Source    string `json:"source"`              // "dhcp", "arp" or "dhcp+arp"
+}
+
+// label returns the best human-readable name: comment, then hostname, then MAC
+func (c *Client) label() string {
+	switch {
+	case c.Comment != "":
+		return c.Comment
+	case c.HostName != "":
+		return c.HostName
+	default:
+		return c.MAC
+	}
+}
+
+// ClientCollector polls DHCP leases and the ARP table and keeps an IP -> client directory
+// used to put names on raw addresses in the traffic views (top talkers, flows).
+type ClientCollector struct {
+	client RouterClient
+	config *ClientsConfig
+
+	clients   map[string]*Client // IP -> client
+	updated   time.Time
+	clientsMu sync.RWMutex
+}
+
+// NewClientCollector creates a new client directory collector
+func NewClientCollector(client RouterClient, config *ClientsConfig) *ClientCollector {
+	logInfo("Clients", "Client collector initialized (interval: %v)", config.Interval)
+
+	return &ClientCollector{
+		client:  client,
+		config:  config,
+		clients: make(map[string]*Client),
+	}
+}
+
+// Collect refreshes the directory from /ip/dhcp-server/lease and /ip/arp
+func (c *ClientCollector) Collect(ctx context.Context) error {
+	// Both queries are pipelined on the connection
+	results, errs := RunAll(ctx, c.client,
+		[]string{
+			"/ip/dhcp-server/lease/print",
+			"=.proplist=address,active-address,mac-address,active-mac-address,host-name,comment,status",
+		},
+		[]string{
+			"/ip/arp/print",
+			"=.proplist=address,mac-address,interface,comment,complete",
+		},
+	)
+	for i, menu := range []string{"dhcp leases", "arp"} {
+		if errs[i] != nil {
+			return fmt.Errorf("%s: %w", menu, errs[i])
+		}
+	}
+	leases, arp := results[0], results[1]
+
+	// Bound leases are active clients; other (static) leases only name hosts found in ARP
+	clients := make(map[string]*Client, len(leases)+len(arp))
+	inactive := make(map[string]*Client)
+	for _, lease := range leases {
+		// Dynamic leases only have active-*; static leases keep address/mac-address
+		address := firstNonEmpty(lease["active-address"], lease["address"])
+		if address == "" {
+			continue
+		}
+		target := clients
+		if lease["status"] != "bound" {
+			target = inactive
+		}
+		target[address] = &Client{
+			Address:  address,
+			MAC:      firstNonEmpty(lease["active-mac-address"], lease["mac-address"]),
+			HostName: lease["host-name"],
+			Comment:  lease["comment"],
+			Status:   lease["status"],
+			Source:   "dhcp",
+		}
+	}
+
+	for _, entry := range arp {
+		address := entry["address"]
+		if address == "" || entry["complete"] == "false" {
+			continue
+		}
+		client, ok := clients[address]
+		if !ok {
+			client, ok = inactive[address]
+			if ok {
+				clients[address] = client
+			}
+		}
+		if !ok {
+			clients[address] = &Client{
+				Address:   address,
+				MAC:       entry["mac-address"],
+				Comment:   entry["comment"],
+				Interface: entry["interface"],
+				Source:    "arp",
+			}
+			continue
+		}
+		client.Source = "dhcp+arp"
+		client.Interface = entry["interface"]
+		if client.MAC == "" {
+			client.MAC = entry["mac-address"]
+		}
+		if client.Comment == "" {
+			client.Comment = entry["comment"]
+		}
+	}
+
+	for _, client := range clients {
+		client.Name = client.label()
+	}
+
+	c.clientsMu.Lock()
+	c.clients = clients
+	c.updated = time.Now()
+	c.clientsMu.Unlock()
+
+	logDebug("Clients", "%d clients (%d leases, %d ARP entries)", len(clients), len(leases), len(arp))
+	return nil
+}
+
+// Lookup returns the client with an IP address (nil if unknown or the collector is disabled)
+func (c *ClientCollector) Lookup(address string) *Client {
+	if c == nil {
+		return nil
+	}
+	c.clientsMu.RLock()
+	defer c.clientsMu.RUnlock()
+	return c.clients[address]
+}
+
+// Name returns the label of an IP address ("" if unknown or the collector is disabled)
+func (c *ClientCollector) Name(address string) string {
+	if client := c.Lookup(address); client != nil {
+		return client.Name
+	}
+	return ""
+}
+
+// List returns all clients sorted by address, and the time of the last refresh
+func (c *ClientCollector) List() ([]Client, time.Time) {
+	c.clientsMu.RLock()
+	defer c.clientsMu.RUnlock()
+
+	clients := make([]Client, 0, len(c.clients))
+	for _, client := range c.clients {
+		clients = append(clients, *client)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return compareAddresses(clients[i].Address, clients[j].Address) < 0
+	})
+	return clients, c.updated
+}
+
+// compareAddresses orders IP addresses numerically (IPv4 before IPv6), other strings lexically
+func compareAddresses(a, b string) int {
+	addrA, errA := netip.ParseAddr(a)
+	addrB, errB := netip.ParseAddr(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return addrA.Compare(addrB)
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"time"
+)
+
+// ============================================================================
+// Clock Watch (wall-clock steps of the monitor host)
+// ============================================================================
+//
+// Rates are computed on the monotonic clock, which NTP cannot step, but sample
+// timestamps, window boundaries and report periods follow the wall clock. The
+// watch compares both clocks between samples: a difference is the wall clock
+// being stepped (or drifting, where the monotonic clock is not slewed).
+
+// clockJumpThreshold is the smallest step between two samples reported as a jump
+const clockJumpThreshold = time.Second
+
+// clockWatch tracks the wall clock against the monotonic clock (sampling loop only)
+type clockWatch struct {
+	start time.Time // First sample (with a monotonic reading)
+	last  time.Time // Previous sample
+}
+
+// Observe records a sample time read with time.Now() and returns how far the
+// wall clock was stepped since the previous sample (0 below clockJumpThreshold)
+// and its total adjustment since the first sample (wall minus monotonic elapsed time).
+func (w *clockWatch) Observe(now time.Time) (jump, skew time.Duration) {
+	if w.start.IsZero() {
+		w.start, w.last = now, now
+		return 0, 0
+	}
+	step := wallElapsed(w.last, now) - now.Sub(w.last)
+	w.last = now
+	if step >= clockJumpThreshold || step <= -clockJumpThreshold {
+		jump = step
+	}
+	return jump, wallElapsed(w.start, now) - now.Sub(w.start)
+}
+
+// wallElapsed returns the wall-clock time between two readings (Sub alone uses the monotonic clock)
+func wallElapsed(from, to time.Time) time.Duration {
+	return to.Round(0).Sub(from.Round(0))
+}
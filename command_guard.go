@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ============================================================================
+// Read-Only Command Guard
+// ============================================================================
+
+// ErrCommandNotAllowed is returned for commands rejected by the read-only guard
+var ErrCommandNotAllowed = errors.New("command not allowed in read-only mode")
+
+// defaultAllowedMenus are the RouterOS menus the collectors read from
+// Extra menus can be added with MIKROTIK_ALLOWED_COMMANDS.
+var defaultAllowedMenus = []string{
+	"/interface",
+	"/queue",
+	"/ppp/active",
+	"/ip/hotspot/active",
+	"/ip/dhcp-server/lease",
+	"/ip/arp",
+	"/caps-man",
+	"/user/active",
+	"/log",
+	"/system/resource",
+	"/system/health",
+	"/system/identity",
+	"/tool", // /tool/torch; other /tool menus are limited to the read-only verbs
+}
+
+// readOnlyCommands are top-level commands (no menu) that never change router state
+var readOnlyCommands = map[string]bool{
+	"/ping": true, // Latency probes
+}
+
+// readOnlyVerbs are the command verbs that never change router state
+var readOnlyVerbs = map[string]bool{
+	"print":           true,
+	"getall":          true,
+	"monitor":         true,
+	"monitor-traffic": true,
+	"torch":           true, // /tool/torch only samples traffic
+}
+
+// commandGuard wraps a RouterClient and rejects commands outside the allowlist
+//
+// A command is allowed when its menu (the path without the verb) is, or is below,
+// an allowed menu and its verb is read-only: "/interface/ethernet/print" passes,
+// "/interface/set" and "/system/reboot" don't. This keeps the tool safe to run
+// with router credentials broader than the "read" policy. The explicitly enabled
+// controllers add the exact write commands they need (see controlCommands).
+type commandGuard struct {
+	RouterClient
+	menus  []string
+	writes map[string]bool // Write commands allowed as a whole ("/queue/simple/set")
+}
+
+// newCommandGuard wraps client with the default allowlist plus extra menus and write commands
+func newCommandGuard(client RouterClient, extra, writes []string) *commandGuard {
+	menus := append([]string{}, defaultAllowedMenus...)
+	for _, menu := range extra {
+		menus = append(menus, "/"+strings.Trim(menu, "/"))
+	}
+	return &commandGuard{RouterClient: client, menus: menus, writes: toSet(writes)}
+}
+
+// controlCommands returns the write commands of the enabled controllers (none in dry run)
+func controlCommands(config *Config) []string {
+	var commands []string
+	if config.Shaper != nil && !config.Shaper.DryRun {
+		commands = append(commands, "/queue/simple/set")
+	}
+	if config.Schedule != nil && !config.Schedule.DryRun {
+		commands = append(commands, "/interface/set")
+	}
+	return commands
+}
+
+// Run runs the command if the allowlist permits it
+func (g *commandGuard) Run(ctx context.Context, words ...string) ([]map[string]string, error) {
+	if len(words) == 0 {
+		return nil, fmt.Errorf("%w: empty command", ErrCommandNotAllowed)
+	}
+	if !g.allowed(words[0]) {
+		logWarn("Client", "Rejected command %s (read-only mode)", words[0])
+		return nil, fmt.Errorf("%w: %s", ErrCommandNotAllowed, words[0])
+	}
+	return g.RouterClient.Run(ctx, words...)
+}
+
+// allowed reports whether a command path ("/menu/sub/verb") is permitted
+func (g *commandGuard) allowed(command string) bool {
+	if readOnlyCommands[command] || g.writes[command] {
+		return true
+	}
+	i := strings.LastIndex(command, "/")
+	if i <= 0 || !strings.HasPrefix(command, "/") {
+		return false
+	}
+	menu, verb := command[:i], command[i+1:]
+	if !readOnlyVerbs[verb] {
+		return false
+	}
+	for _, allowed := range g.menus {
+		if menu == allowed || strings.HasPrefix(menu, allowed+"/") {
+			return true
+		}
+	}
+	return false
+}
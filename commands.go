@@ -0,0 +1,618 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/firadio/golang-mikrotik-interface-stats/pkg/routeros"
+)
+
+// ============================================================================
+// Subcommands
+// ============================================================================
+
+// command is a CLI subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int // Returns the process exit code
+}
+
+// commands lists all subcommands (the first one is the default)
+var commands []command
+
+func init() {
+	commands = []command{
+		{"run", "Run the monitor (default)", cmdRun},
+		{"check-config", "Validate the configuration and print the effective settings", cmdCheckConfig},
+		{"list-interfaces", "Connect to the router and list its interfaces", cmdListInterfaces},
+		{"snapshot", "Sample rates once and print them as JSON", cmdSnapshot},
+		{"replay", "Replay a recorded API session (MIKROTIK_RECORD) through the client", cmdReplay},
+		{"import", "Backfill history from CSV or Prometheus text into VictoriaMetrics", cmdImport},
+		{"alert-rules", "Generate a Prometheus/vmalert rules file from the alert settings", cmdAlertRules},
+		{"service", "Install, remove or run as a Windows service", cmdService},
+		{"version", "Print version information", cmdVersion},
+		{"help", "Show this help", cmdHelp},
+	}
+}
+
+// runCommand dispatches os.Args to a subcommand
+// Without a subcommand (or with only flags, e.g. --env=prod.env) the monitor runs
+func runCommand(args []string) int {
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd.run(args)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", name)
+	printUsage(os.Stderr)
+	return 2
+}
+
+// newFlagSet creates a flag set with the common -env flag
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	envFile := fs.String("env", ".env", "Path to the .env file")
+	return fs, envFile
+}
+
+// printUsage prints the list of subcommands
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Mikrotik Interface Traffic Monitor %s\n\n", Version)
+	fmt.Fprintf(w, "Usage: %s [command] [--env=FILE] [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for command flags.\n", os.Args[0])
+}
+
+// cmdRun runs the monitoring daemon
+func cmdRun(args []string) int {
+	fs, envFile := newFlagSet("run")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// Cancel in-flight router commands and stop the monitoring loop on Ctrl+C / SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Closing the console window (Windows) also stops the monitor, after outputs are flushed
+	done := watchConsoleClose(stop)
+	defer done()
+
+	return runMonitor(ctx, *envFile)
+}
+
+// runMonitor runs the monitoring daemon until ctx is cancelled (also used by the Windows service)
+func runMonitor(ctx context.Context, envFile string) int {
+	// Load configuration from .env file and environment variables
+	config, err := LoadConfig(envFile)
+	if err != nil {
+		logFatal("", "Failed to load config: %v", err)
+	}
+
+	// Route all diagnostic messages through slog with the configured level and format
+	setupLogging(config.LogLevel, config.LogFormat)
+
+	// Print startup information
+	printStartupInfo(config)
+
+	// Establish connection to Mikrotik router via API
+	conn, err := NewRouterClient(ctx, config)
+	if errors.Is(err, routeros.ErrAuthFailed) {
+		logFatal("", "Mikrotik rejected the login, check MIKROTIK_USERNAME and MIKROTIK_PASSWORD: %v", err)
+	}
+	if err != nil {
+		logFatal("", "Failed to connect to Mikrotik: %v", err)
+	}
+
+	// Record query latency/errors and reconnect after connection failures
+	telemetry := NewTelemetry()
+	client := newInstrumentedClient(conn, config, telemetry)
+	defer client.Close()
+
+	logInfo("", "Connected to Mikrotik at %s (transport: %s)", net.JoinHostPort(config.Host, config.Port), config.Transport)
+	checkRouterVersion(config, routerVersion(conn))
+
+	// Create and start monitoring loop
+	monitor := NewMonitor(client, config, telemetry)
+
+	// Apply .env changes and SIGHUP reloads without dropping the stats window or WebSocket clients
+	go NewConfigReloader(envFile, config.ReloadInterval, monitor.Reload).Run(ctx)
+
+	if err := monitor.Start(ctx); err != nil {
+		logError("", "Monitor error: %v", err)
+		return 1
+	}
+
+	logInfo("", "Shutting down")
+	return 0
+}
+
+// cmdCheckConfig validates the configuration and prints the effective settings
+func cmdCheckConfig(args []string) int {
+	fs, envFile := newFlagSet("check-config")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	config, err := LoadConfig(*envFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration invalid: %v\n", err)
+		return 1
+	}
+
+	printConfig(os.Stdout, "", reflect.ValueOf(config).Elem())
+	fmt.Println()
+	fmt.Println("Configuration OK")
+	return 0
+}
+
+// cmdListInterfaces connects to the router and lists all interfaces
+func cmdListInterfaces(args []string) int {
+	fs, envFile := newFlagSet("list-interfaces")
+	asJSON := fs.Bool("json", false, "Print JSON instead of a table")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	config, err := LoadConfig(*envFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration invalid: %v\n", err)
+		return 1
+	}
+	setupLogging(config.LogLevel, config.LogFormat)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := NewRouterClient(ctx, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to Mikrotik: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+
+	infos, err := queryInterfaceInfo(ctx, client, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list interfaces: %v\n", err)
+		return 1
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(infos)
+		return 0
+	}
+
+	// Monitored interfaces are marked with *
+	monitored := toSet(config.Interfaces)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tNAME\tTYPE\tMTU\tSPEED\tRUNNING\tDISABLED\tMAC\tCOMMENT")
+	for _, info := range infos {
+		mark := ""
+		if monitored[info.Name] {
+			mark = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%v\t%v\t%s\t%s\n",
+			mark, info.Name, info.Type, info.MTU, info.Speed, info.Running, info.Disabled, info.MACAddress, info.Comment)
+	}
+	tw.Flush()
+	return 0
+}
+
+// cmdSnapshot samples the counters twice and prints per-interface rates as JSON
+// Intended for cron jobs and scripts that don't need the full daemon
+func cmdSnapshot(args []string) int {
+	fs, envFile := newFlagSet("snapshot")
+	interval := fs.Duration("interval", time.Second, "Time between the two samples")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "interval must be positive")
+		return 2
+	}
+
+	config, err := LoadConfig(*envFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration invalid: %v\n", err)
+		return 1
+	}
+	setupLogging(config.LogLevel, config.LogFormat)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := NewRouterClient(ctx, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to Mikrotik: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+
+	// Labels and uplinks saved via the web UI are honored, but never written
+	userConfig := newMemoryUserConfigManager(config.UplinkInterfaces)
+	userConfig.filePath = filepath.Join(defaultDataDir, userConfigFileName)
+	if err := userConfig.Load(); err != nil && !os.IsNotExist(err) {
+		logWarn("UserConfig", "Failed to load config: %v", err)
+	}
+
+	first, err := client.GetInterfaceStats(ctx, config.Interfaces)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to query interfaces: %v\n", err)
+		return 1
+	}
+	start := time.Now()
+
+	select {
+	case <-ctx.Done():
+		return 1
+	case <-time.After(*interval):
+	}
+
+	second, err := client.GetInterfaceStats(ctx, config.Interfaces)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to query interfaces: %v\n", err)
+		return 1
+	}
+	now := time.Now()
+
+	snapshot := buildSnapshot(
+		appendGroupStats(first, config.Groups),
+		appendGroupStats(second, config.Groups),
+		now.Sub(start), now, userConfig,
+	)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snapshot); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write snapshot: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// SnapshotRate is the rate of one interface in a snapshot (bytes/second)
+type SnapshotRate struct {
+	Label        string  `json:"label,omitempty"`
+	RxRate       float64 `json:"rx_rate"`
+	TxRate       float64 `json:"tx_rate"`
+	UploadRate   float64 `json:"upload_rate"`
+	DownloadRate float64 `json:"download_rate"`
+}
+
+// Snapshot is the JSON document printed by the snapshot command
+// The layout follows the WebSocket stats message
+type Snapshot struct {
+	Timestamp       string                   `json:"timestamp"`
+	IntervalSeconds float64                  `json:"interval_seconds"`
+	Interfaces      map[string]*SnapshotRate `json:"interfaces"`
+}
+
+// buildSnapshot computes rates between two counter samples
+// Interfaces missing from either sample are omitted
+func buildSnapshot(first, second []InterfaceStats, elapsed time.Duration, now time.Time, userConfig *UserConfigManager) *Snapshot {
+	seconds := elapsed.Seconds()
+	snapshot := &Snapshot{
+		Timestamp:       now.Format(time.RFC3339),
+		IntervalSeconds: seconds,
+		Interfaces:      make(map[string]*SnapshotRate, len(second)),
+	}
+
+	previous := make(map[string]InterfaceStats, len(first))
+	for _, stat := range first {
+		previous[stat.Name] = stat
+	}
+
+	for _, stat := range second {
+		prev, ok := previous[stat.Name]
+		if !ok {
+			continue
+		}
+
+		rate := &SnapshotRate{
+			Label:  userConfig.GetInterfaceLabel(stat.Name),
+			RxRate: float64(stat.RxByte-prev.RxByte) / seconds,
+			TxRate: float64(stat.TxByte-prev.TxByte) / seconds,
+		}
+
+		// Convert RX/TX to Upload/Download based on interface type
+		if userConfig.IsUplink(stat.Name) {
+			rate.UploadRate, rate.DownloadRate = rate.TxRate, rate.RxRate
+		} else {
+			rate.UploadRate, rate.DownloadRate = rate.RxRate, rate.TxRate
+		}
+		snapshot.Interfaces[stat.Name] = rate
+	}
+
+	return snapshot
+}
+
+// cmdAlertRules prints the ALERT_* settings as Prometheus/vmalert alerting rules
+// Uplinks saved via the web UI are honored, like in the snapshot command.
+func cmdAlertRules(args []string) int {
+	fs, envFile := newFlagSet("alert-rules")
+	source := fs.String("source", "", "Series the rules read: vm (pushed window averages) or scrape (/metrics); default: vm when VictoriaMetrics is enabled")
+	group := fs.String("group", "mikrotik-interface-stats", "Rule group name")
+	output := fs.String("o", "", "Write the rules to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	config, err := LoadConfig(*envFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration invalid: %v\n", err)
+		return 1
+	}
+
+	if *source == "" {
+		*source = "scrape"
+		if config.VictoriaMetrics != nil {
+			*source = "vm"
+		}
+	}
+	switch *source {
+	case "vm":
+		if config.VictoriaMetrics == nil {
+			fmt.Fprintln(os.Stderr, "-source=vm requires VM_ENABLED=true (the rules read the pushed window series)")
+			return 2
+		}
+	case "scrape":
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid -source: %s (must be vm or scrape)\n", *source)
+		return 2
+	}
+
+	userConfig := newMemoryUserConfigManager(config.UplinkInterfaces)
+	userConfig.filePath = filepath.Join(defaultDataDir, userConfigFileName)
+	if err := userConfig.Load(); err != nil && !os.IsNotExist(err) {
+		logWarn("UserConfig", "Failed to load config: %v", err)
+	}
+
+	generator := newAlertRuleGenerator(config, *source, userConfig.IsUplink)
+	rules := generator.Rules()
+	if config.Alerts == nil {
+		fmt.Fprintln(os.Stderr, "ALERTS_ENABLED=false: only the monitor and router rules are generated")
+	}
+
+	var interval time.Duration
+	if *source == "vm" {
+		interval = config.VictoriaMetrics.Interval
+	}
+	header := fmt.Sprintf("Generated by mikrotik-stats %s alert-rules (source: %s) on %s; edit the .env file and regenerate",
+		Version, *source, time.Now().Format(time.RFC3339))
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create %s: %v\n", *output, err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+	writeAlertRules(w, *group, interval, rules, header)
+
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %d rules to %s\n", len(rules), *output)
+	}
+	return 0
+}
+
+// ReplayResult is the outcome of one replayed command
+type ReplayResult struct {
+	Command []string            `json:"command"`
+	Rows    []map[string]string `json:"rows"`
+	Error   string              `json:"error,omitempty"`
+}
+
+// cmdReplay plays a session transcript back through the binary API client
+// The client logs in and issues the recorded commands again, so the replies of
+// the user's router go through the same parser; no configuration is needed.
+func cmdReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print JSON instead of text")
+	debug := fs.Bool("debug", false, "Print every word read by the parser")
+	timeout := fs.Duration("timeout", 2*time.Second, "How long to wait for the replies of a command")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s replay [flags] FILE\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open recording: %v\n", err)
+		return 1
+	}
+	conn, err := routeros.Replay(file)
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid recording: %v\n", err)
+		return 1
+	}
+
+	// Log in the way the recorded client did; redacted passwords are not compared
+	options := routeros.Options{Timeout: *timeout}
+	for _, words := range conn.Commands() {
+		if words[0] != "/login" {
+			break
+		}
+		if len(words) == 1 {
+			options.Login = routeros.LoginChallenge
+		}
+		for _, word := range words[1:] {
+			if name, ok := strings.CutPrefix(word, "=name="); ok {
+				options.Username = name
+			}
+		}
+	}
+	if *debug {
+		options.Debugf = func(format string, args ...any) {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := routeros.NewClient(ctx, conn, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Login failed: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+	if !*asJSON {
+		fmt.Printf("Logged in as %q (RouterOS %s, %s)\n", options.Username, client.Version(), client.BoardName())
+	}
+
+	var results []ReplayResult
+	failed := false
+	for _, words := range conn.Pending() {
+		rows, err := client.Run(ctx, words...)
+		result := ReplayResult{Command: words, Rows: rows}
+		if err != nil {
+			result.Error = err.Error()
+			// A trap is a regular reply; anything else means the stream did not parse
+			var trap *routeros.TrapError
+			failed = failed || !errors.As(err, &trap)
+		}
+		results = append(results, result)
+
+		if !*asJSON {
+			printReplayResult(os.Stdout, result)
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// printReplayResult prints a replayed command and its rows (attributes sorted by name)
+func printReplayResult(w io.Writer, result ReplayResult) {
+	fmt.Fprintf(w, "\n> %s\n", strings.Join(result.Command, " "))
+	for _, row := range result.Rows {
+		keys := make([]string, 0, len(row))
+		for key := range row {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fields := make([]string, len(keys))
+		for i, key := range keys {
+			fields[i] = fmt.Sprintf("%s=%q", key, row[key])
+		}
+		fmt.Fprintf(w, "  %s\n", strings.Join(fields, " "))
+	}
+	if result.Error != "" {
+		fmt.Fprintf(w, "  error: %s\n", result.Error)
+	}
+}
+
+// cmdVersion prints version information
+func cmdVersion(args []string) int {
+	fmt.Printf("Mikrotik Interface Traffic Monitor %s (%s, %s/%s)\n", Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return 0
+}
+
+// cmdHelp prints usage
+func cmdHelp(args []string) int {
+	printUsage(os.Stdout)
+	return 0
+}
+
+// printConfig prints a config struct as "Field = value" lines
+// Nested feature configs are printed as sections (or "disabled" when nil); secrets are masked
+func printConfig(w io.Writer, indent string, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if value.Kind() == reflect.Ptr && value.Type().Elem().Kind() == reflect.Struct {
+			if value.IsNil() {
+				fmt.Fprintf(w, "%s%s: disabled\n", indent, field.Name)
+				continue
+			}
+			fmt.Fprintf(w, "%s%s:\n", indent, field.Name)
+			printConfig(w, indent+"  ", value.Elem())
+			continue
+		}
+
+		fmt.Fprintf(w, "%s%s = %s\n", indent, field.Name, formatConfigValue(field.Name, value))
+	}
+}
+
+// formatConfigValue renders one config value, masking passwords, tokens, SNMP communities and header values
+func formatConfigValue(name string, value reflect.Value) string {
+	secret := strings.Contains(name, "Pass") || strings.Contains(name, "Token") || strings.Contains(name, "Community") || strings.HasSuffix(name, "Headers")
+
+	switch value.Kind() {
+	case reflect.String:
+		if secret && value.String() != "" {
+			return "********"
+		}
+		return fmt.Sprintf("%q", value.String())
+	case reflect.Map:
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		parts := make([]string, 0, len(keys))
+		for _, key := range keys {
+			v := fmt.Sprint(value.MapIndex(key).Interface())
+			if secret {
+				v = "********"
+			}
+			parts = append(parts, fmt.Sprintf("%v=%s", key.Interface(), v))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case reflect.Slice:
+		if secret && value.Len() > 0 {
+			return fmt.Sprintf("[%d masked]", value.Len())
+		}
+	}
+
+	if d, ok := value.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return fmt.Sprintf("%v", value.Interface())
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ============================================================================
+// Push Compression (gzip / zstd request bodies)
+// ============================================================================
+//
+// VictoriaMetrics decodes Content-Encoding: gzip and zstd on its import
+// endpoints. gzip comes from the standard library, zstd from
+// github.com/klauspost/compress (the encoder VictoriaMetrics itself uses).
+
+// zstdEncoder compresses whole bodies with EncodeAll, which is safe for concurrent use
+// An empty body still gets a frame, as decoders reject empty input.
+var zstdEncoder = sync.OnceValue(func() *zstd.Encoder {
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithZeroFrames(true))
+	if err != nil {
+		panic(err) // Only invalid options fail
+	}
+	return encoder
+})
+
+// compressBody compresses a push body with VM_COMPRESSION ("gzip" or "zstd")
+func compressBody(data []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "gzip":
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "zstd":
+		return zstdEncoder().EncodeAll(data, nil), nil
+	default:
+		return data, nil
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/rand"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// testMetricText returns n lines of VictoriaMetrics import text
+func testMetricText(n int) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, "mikrotik_interface_rx_rate_avg{interface=\"ether%d\",interval=\"10s\",router=\"192.168.88.1\"} %d.%02d 17000000%05d\n",
+			i%24, i*7919%100000, i%100, i)
+	}
+	return buf.Bytes()
+}
+
+func TestCompressBody(t *testing.T) {
+	random := make([]byte, 100<<10)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+
+	decode := map[string]func([]byte) ([]byte, error){
+		"gzip": func(body []byte) ([]byte, error) {
+			reader, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			return io.ReadAll(reader)
+		},
+		"zstd": func(body []byte) ([]byte, error) { return decoder.DecodeAll(body, nil) },
+	}
+
+	for _, encoding := range []string{"gzip", "zstd"} {
+		for _, src := range [][]byte{nil, []byte("ether1"), testMetricText(5000), random} {
+			body, err := compressBody(src, encoding)
+			if err != nil {
+				t.Fatalf("%s: %v", encoding, err)
+			}
+			decoded, err := decode[encoding](body)
+			if err != nil || !bytes.Equal(decoded, src) {
+				t.Errorf("%s round trip of %d bytes: got %d bytes, error %v", encoding, len(src), len(decoded), err)
+			}
+		}
+
+		metrics := testMetricText(2000)
+		if body, _ := compressBody(metrics, encoding); len(body) > len(metrics)/5 {
+			t.Errorf("%s: metric text compressed to %d of %d bytes, want at most a fifth", encoding, len(body), len(metrics))
+		}
+	}
+
+	metrics := testMetricText(100)
+	if plain, _ := compressBody(metrics, ""); !strings.HasPrefix(string(plain), "mikrotik_") || len(plain) != len(metrics) {
+		t.Error("uncompressed body was modified")
+	}
+}
+
+func TestCompressBodyZstdCLI(t *testing.T) {
+	path, err := exec.LookPath("zstd")
+	if err != nil {
+		t.Skip("zstd command not installed")
+	}
+
+	for _, src := range [][]byte{nil, testMetricText(3000), bytes.Repeat([]byte{'x'}, 200000)} {
+		body, _ := compressBody(src, "zstd")
+		cmd := exec.Command(path, "-d", "-c", "-q")
+		cmd.Stdin = bytes.NewReader(body)
+		decoded, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("zstd -d (%d bytes): %v", len(src), err)
+		}
+		if !bytes.Equal(decoded, src) {
+			t.Errorf("zstd -d: got %d bytes, want %d", len(decoded), len(src))
+		}
+	}
+}
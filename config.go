@@ -3,7 +3,11 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"log/slog"
+	"net"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -12,22 +16,107 @@ import (
 // Config holds application configuration
 type Config struct {
 	// Mikrotik connection settings
-	Host     string // Mikrotik router hostname/IP
-	Port     string // Mikrotik API port
+	Host     string // Mikrotik router hostname/IP (IPv6 literals without brackets)
+	Port     string // Mikrotik API port (default: 8728, 8729 with TLS, 443 for REST)
 	Username string // Authentication username
 	Password string // Authentication password
 
+	CredentialSource string // Where the password came from: env, file, stdin or keyring
+
+	Mock *MockConfig // Simulated router instead of a real one (nil if MIKROTIK_MOCK is off)
+
+	// Transport settings
+	Transport     string // "api" (binary API, default), "rest" (RouterOS v7 REST) or "snmp" (SNMPv2c fallback)
+	RESTInsecure  bool   // Skip TLS certificate verification for REST (self-signed router certs)
+	TLS           bool   // Use the api-ssl service (api transport)
+	TLSInsecure   bool   // Skip certificate verification for api-ssl
+	SRV           bool   // Discover host and port from the DNS SRV record of Host
+	LoginMethod   string // api transport login: "auto" (default), "plain" (6.43+) or "challenge" (before 6.43)
+	Record        string // Directory receiving a transcript of every API session (api transport, "" = off)
+	SNMPCommunity string // SNMPv2c community (snmp transport only)
+	SNMPPort      string // SNMP agent UDP port (snmp transport only)
+
+	CommandTimeout time.Duration // Per-command deadline for router queries (default: 10s)
+
+	ReadOnly        bool     // Only run read-only commands from the allowlist (default: true)
+	AllowedCommands []string // Extra menus allowed in read-only mode (e.g. /ip/address)
+
 	// Monitoring settings
-	Interfaces       []string // List of interfaces to monitor
-	UplinkInterfaces []string // Uplink interfaces (WAN ports) for RX/TX interpretation
-	StatsWindowSize  int      // Statistics window size in seconds (default 10, max 60)
-	Debug            bool     // Enable debug output (show API commands)
+	Interfaces       []string          // List of interfaces to monitor
+	UplinkInterfaces []string          // Uplink interfaces (WAN ports) for RX/TX interpretation
+	Groups           []InterfaceGroup  // Virtual interfaces aggregating several monitored interfaces
+	PollInterval     time.Duration     // Interval between interface counter samples (default 1s)
+	PollAlign        bool              // Sample on wall-clock multiples of PollInterval
+	PollAdaptive     bool              // Stretch the interval while interface queries are slow (default: true)
+	PollMaxInterval  time.Duration     // Longest stretched interval (0 = 10x PollInterval)
+	StatsWindowSize  int               // Statistics window size in samples (default 10, max 60; seconds at the default interval)
+	Debug            bool              // Shortcut for LOG_LEVEL=debug (show API commands)
+	LogLevel         slog.Level        // Minimum level of diagnostic messages (LOG_LEVEL)
+	LogFormat        string            // Diagnostic message format: "text" or "json" (LOG_FORMAT)
+	OutputTimeout    time.Duration     // Max time to wait for outputs per sample (default: 5s)
+	DisabledOutputs  []string          // Outputs paused at runtime through the admin API (sorted)
+	ReloadInterval   time.Duration     // How often the env file is checked for changes (0 = SIGHUP only)
+	ExtraLabels      map[string]string // Static labels (router=, site=, ...) on metrics and logs
+	MetricPrefix     string            // Prometheus metric name prefix (default "mikrotik_")
+	MetricUnit       string            // Rate unit of Prometheus metrics: "bytes" (bytes/s, default) or "bits" (bits/s)
+	Timezone         string            // Zone of window boundaries, report periods and printed times: IANA name, "UTC" or "Local" (default)
+	Location         *time.Location    // Timezone loaded (the host zone for "Local")
+	Container        bool              // Running in a container: JSON logs on stdout, probes on :8080 by default
+	ProbeListenAddr  string            // Separate /healthz and /readyz listener (PROBE_LISTEN_ADDR, "" = off)
 
 	// Optional output features (nil if disabled)
-	Terminal        *TerminalConfig // Terminal interactive display
-	Log             *LogConfig      // Structured logging
-	Web             *WebConfig      // Web service
-	VictoriaMetrics *VMConfig       // VictoriaMetrics integration
+	Terminal        *TerminalConfig    // Terminal interactive display
+	Log             *LogConfig         // Structured logging
+	Web             *WebConfig         // Web service
+	VictoriaMetrics *VMConfig          // VictoriaMetrics integration
+	Retention       *RetentionConfig   // VictoriaMetrics history rollups and raw data retention
+	OTLP            *OTLPConfig        // OpenTelemetry (OTLP/HTTP) export
+	Pushgateway     *PushgatewayConfig // Prometheus Pushgateway (or vmagent) push
+	Stream          *StreamConfig      // Per-second samples to Kafka or NATS
+	RRD             *RRDConfig         // RRDtool updates or MRTG logs per interface
+	Zabbix          *ZabbixConfig      // Zabbix trapper items via the sender protocol
+
+	// Optional collectors (nil if disabled)
+	Sessions   *SessionsConfig   // PPP/hotspot active session stats
+	Health     *HealthConfig     // Router CPU/memory/temperature metrics
+	LinkSpeed  *LinkSpeedConfig  // Negotiated link speed for utilization
+	Members    *MembersConfig    // Member ports of monitored bridges and bonds
+	Trunks     *TrunksConfig     // VLAN parents and per-trunk utilization
+	Events     *EventsConfig     // Interface up/down/flap events
+	TopTalkers *TopTalkersConfig // Busiest hosts per interface (torch)
+	Ping       *PingConfig       // Latency/loss probes (RouterOS ping)
+	CAPsMAN    *CAPsMANConfig    // Per-AP/SSID WiFi traffic (CAPsMAN registration table)
+	Netwatch   *NetwatchConfig   // Reachability checks run by the router (/tool/netwatch)
+	Audit      *AuditConfig      // Management sessions and login failures (/user/active, /log)
+	Flows      *FlowConfig       // NetFlow/IPFIX receiver
+	Clients    *ClientsConfig    // DHCP lease / ARP client names
+	Alerts     *AlertsConfig     // Rate threshold / anomaly webhooks
+	Notify     *NotifyConfig     // Telegram/Slack/Discord notifiers and daily summary
+	Reports    *ReportConfig     // Scheduled usage reports from VM history
+
+	// Optional analytics (nil if disabled)
+	Percentile *PercentileConfig // 95th percentile (burstable billing)
+	State      *StateConfig      // Counters and accumulators persisted across restarts
+	Topology   *TopologyConfig   // Site/router WAN roll-ups
+	Billing    *BillingConfig    // Monthly per-customer billing exports from VM history
+
+	// Router control (nil if disabled): these change router configuration
+	Shaper   *ShaperConfig   // Queue limits from traffic quotas (/queue/simple)
+	Schedule *ScheduleConfig // Interface enable/disable windows (/interface)
+}
+
+// InterfaceGroup defines a virtual interface whose counters are the sum of its members
+type InterfaceGroup struct {
+	Name    string   // Virtual interface name (e.g., "customers")
+	Members []string // Monitored interfaces to sum (e.g., vlan2622, vlan2624)
+}
+
+// MockConfig holds the simulated router used for demos and integration tests
+// Interfaces get sine-wave traffic, or counters replayed from a recorded trace.
+type MockConfig struct {
+	Enabled bool          // Use the simulated router (no connection to MIKROTIK_HOST)
+	Trace   string        // CSV trace to replay (time,interface,rx-byte,tx-byte; "" = sine waves)
+	Period  time.Duration // Sine-wave period (default: 10m)
 }
 
 // TerminalConfig holds terminal output configuration
@@ -36,16 +125,67 @@ type TerminalConfig struct {
 	Mode      string // "refresh" (like top) or "append" (like tail -f)
 	RateUnit  string // "auto", "bps", "Bps"
 	RateScale string // "auto", "k", "M", "G"
+
+	ExtraStats bool // Show min and standard deviation columns (refresh mode)
+
+	Highlight     string  // Highlight threshold as configured (e.g. "100M", in bits/s)
+	HighlightRate float64 // Parsed threshold in bytes/s (0 = disabled)
+
+	Color     string // "auto" (off with NO_COLOR, TERM=dumb or redirected output), "always" or "never"
+	ColorWarn string // Yellow from this utilization ("70%") or rate ("500M" bits/s), "" = no level
+	ColorCrit string // Red from this utilization or rate, "" = no level
+
+	Sparklines bool // Show upload/download mini-graphs of the stats window (refresh mode)
+
+	Columns   []string // Table columns in display order (empty = default layout)
+	NameWidth int      // Interface name column width (0 = fit the longest name)
+
+	Sort  string // Initial sort: "name", "upload", "download" or a column name
+	Total bool   // Show a TOTAL row summing all interfaces
+
+	Smoothing *SmoothingConfig // Show smoothed current rates (nil = measured rates)
 }
 
 // LogConfig holds structured logging configuration
 type LogConfig struct {
 	Enabled   bool   // Enable structured logging
-	Output    string // "stdout" or "file"
+	Output    string // "stdout", "file" or "syslog"
 	File      string // File path if Output="file"
 	Format    string // "json" or "text"
 	RateUnit  string // "auto", "bps", "Bps"
 	RateScale string // "auto", "k", "M", "G"
+
+	// Rotation (only when Output="file")
+	MaxSizeMB  int  // Rotate when the file exceeds this size (0 = never)
+	MaxBackups int  // Rotated files to keep (0 = unlimited)
+	MaxAgeDays int  // Delete rotated files older than this (0 = keep)
+	Compress   bool // Gzip rotated files
+
+	// Syslog (only when Output="syslog")
+	SyslogNetwork  string // "udp", "tcp" or "unix"
+	SyslogAddress  string // host:port or socket path
+	SyslogFacility string // e.g. "daemon", "local0"
+	SyslogSeverity string // Severity of sample records, e.g. "info"
+	SyslogTag      string // APP-NAME field
+
+	Changes   *ChangeFilterConfig // Only log interfaces whose rates changed (nil = every sample)
+	Smoothing *SmoothingConfig    // Log smoothed rates (nil = measured rates)
+}
+
+// ChangeFilterConfig suppresses output of samples whose rates barely changed
+// A rate counts as changed when it differs from the last emitted one by more than
+// Delta (a fraction of it) and by more than MinChangeRate.
+type ChangeFilterConfig struct {
+	Delta         float64       // Relative change, e.g. 0.1 for 10%
+	MinChange     string        // Smallest absolute change as a rate string (e.g. "10k" bits/s)
+	MinChangeRate float64       // Parsed MinChange in bytes/s
+	Heartbeat     time.Duration // Emit unchanged rates at least this often (0 = never)
+}
+
+// SmoothingConfig replaces the current rates of an output with a moving average
+type SmoothingConfig struct {
+	Mode  string  // "ewma" (exponentially weighted moving average)
+	Alpha float64 // Weight of the newest sample (0-1]; lower is smoother
 }
 
 // WebConfig holds web service configuration
@@ -55,6 +195,34 @@ type WebConfig struct {
 	EnableRealtime bool   // Enable WebSocket real-time push
 	EnableAPI      bool   // Enable REST API
 	EnableStatic   bool   // Enable static file serving
+
+	// Authentication (disabled when neither user nor tokens are set)
+	AuthUser   string        // Basic auth / login username
+	AuthPass   string        // Basic auth / login password
+	AuthTokens []string      // Static API tokens (Authorization: Bearer or ?token=)
+	SessionTTL time.Duration // Login session lifetime
+
+	// Reverse proxy / cross-origin access
+	BasePath       string   // URL prefix the UI and API are served under ("" = root, e.g. "/mikrotik")
+	CORSOrigins    []string // Origins allowed to call the API from browsers ("*" = any)
+	TrustedProxies []string // Proxy IPs/CIDRs whose X-Forwarded-* headers are honored
+
+	Compression bool // gzip/deflate responses for clients that accept it
+
+	// Request handling
+	AccessLog bool // Log every request (method, path, status, duration, client IP)
+	RateLimit int  // Requests per minute per client IP (0 = unlimited)
+	RateBurst int  // Requests a client may make at once before the limit applies
+
+	// Dashboard display defaults (each browser can override them on the settings page)
+	Theme        string // "dark", "light" or "auto" (follow the operating system)
+	DefaultRange string // History page range: 1h, 6h, 24h, 7d or 30d
+	DefaultUnit  string // Rate unit: "bits" (Mbps) or "bytes" (MB/s)
+
+	Changes   *ChangeFilterConfig // Only push WebSocket updates when a rate changed (nil = every sample)
+	Smoothing *SmoothingConfig    // Push smoothed rates over the WebSocket (nil = measured rates)
+
+	AdminEnabled bool // Serve /api/admin/config, /api/admin/users and /api/admin/tokens (requires authentication)
 }
 
 // VMConfig holds VictoriaMetrics configuration
@@ -64,16 +232,307 @@ type VMConfig struct {
 	Interval   time.Duration // Data aggregation interval (default: 10s)
 	Timeout    time.Duration // HTTP request timeout
 	RetryCount int           // Number of retries on failure
+	QueueSize  int           // Max batches buffered for delivery (oldest dropped when full)
+	BatchSize  int           // Max queued batches sent in one request
+
+	Protocol       string // "import" (/api/v1/import/prometheus text, default) or "remote_write" (snappy protobuf)
+	RemoteWriteURL string // Remote write endpoint (default: URL + /api/v1/write)
+	Compression    string // Import body encoding: "none" (default), "gzip" or "zstd"
+
+	HistogramBuckets string // Rate histogram bucket bounds in Mbps: "1,10,100,1000" (empty = no histograms)
+
+	// Authentication (vmauth, multi-tenant gateways)
+	AuthUser    string            // Basic auth username
+	AuthPass    string            // Basic auth password
+	AuthToken   string            // Bearer token (takes precedence over basic auth)
+	AuthHeaders map[string]string // Extra headers, e.g. X-Scope-OrgID
+
+	SpoolDir      string // Directory for undeliverable batches (empty = spool disabled)
+	SpoolMaxBytes int64  // Max total spool size
 }
 
-// LoadConfig loads configuration from .env file and environment variables
-func LoadConfig() (*Config, error) {
-	// Check for custom env file from command line
-	envFile := ".env"
-	if len(os.Args) > 1 && strings.HasPrefix(os.Args[1], "--env=") {
-		envFile = strings.TrimPrefix(os.Args[1], "--env=")
-	}
+// RetentionConfig holds the history retention tiers stored in VictoriaMetrics
+// Aggregation windows (VM_INTERVAL) are rolled up into coarser series, and each
+// tier is deleted past its own horizon to keep storage bounded.
+type RetentionConfig struct {
+	Enabled         bool          // Enable rollups and retention
+	RollupStep      time.Duration // Rollup bucket size (default: 5m, stored as interval="300s")
+	RawRetention    time.Duration // How long aggregation windows are kept (default: 168h)
+	RollupRetention time.Duration // How long rollups are kept (0 = until VictoriaMetrics' -retentionPeriod)
+	Interval        time.Duration // How often new rollups are written (default: 10m)
+	DeleteAuthKey   string        // VictoriaMetrics -deleteAuthKey, if set
+}
+
+// OTLPConfig holds OpenTelemetry exporter configuration
+type OTLPConfig struct {
+	Enabled            bool              // Enable OTLP export
+	Endpoint           string            // Collector base URL (metrics are posted to Endpoint + /v1/metrics)
+	Protocol           string            // "http/protobuf" (default), "http/json" or "grpc"
+	Interval           time.Duration     // Data aggregation interval (default: 10s)
+	Timeout            time.Duration     // HTTP request timeout
+	Headers            map[string]string // Extra request headers (e.g. authentication)
+	ResourceAttributes map[string]string // Extra resource attributes (e.g. deployment.environment)
+}
+
+// PushgatewayConfig holds Prometheus Pushgateway output configuration
+type PushgatewayConfig struct {
+	Enabled      bool              // Enable Pushgateway output
+	URL          string            // Pushgateway (or vmagent/VictoriaMetrics) base URL
+	Mode         string            // "pushgateway" (PUT without timestamps) or "vmagent" (import API with timestamps)
+	Job          string            // job label of the grouping key (default: mikrotik-stats)
+	Grouping     map[string]string // Extra grouping key labels (instance=MIKROTIK_HOST and EXTRA_LABELS are always included)
+	Interval     time.Duration     // Data aggregation interval (default: 10s)
+	Timeout      time.Duration     // HTTP request timeout
+	Headers      map[string]string // Extra request headers (e.g. authentication)
+	DeleteOnStop bool              // Delete the group on shutdown so the last values do not linger
+}
+
+// RRDConfig holds RRDtool / MRTG log output configuration
+type RRDConfig struct {
+	Enabled  bool          // Enable RRD output
+	Format   string        // "rrdtool" (rrdtool update) or "mrtg" (MRTG .log files)
+	Dir      string        // Directory of the per-interface files
+	Interval time.Duration // Step of the RRDs / MRTG rows (default: 5m)
+	RRDTool  string        // rrdtool binary
+	Daemon   string        // rrdcached address passed as --daemon ("" = update the files directly)
+	DSIn     string        // Data source of RX (default: ds0 as MRTG; Cacti uses traffic_in)
+	DSOut    string        // Data source of TX (default: ds1)
+	Create   bool          // Create missing RRDs with the MRTG layout
+}
+
+// ZabbixConfig holds Zabbix sender output configuration
+type ZabbixConfig struct {
+	Enabled  bool              // Enable Zabbix output
+	Server   string            // Zabbix server or proxy trapper address (host:port)
+	Host     string            // Host name template of the items ({router}, {interface}, {label})
+	Hosts    map[string]string // Per-interface host name templates (interface -> host)
+	Key      string            // Item key template ({router}, {interface}, {label}, {metric})
+	Keys     map[string]string // Per-metric key templates (ZABBIX_KEY_<METRIC>)
+	Metrics  []string          // Values sent per interface (see zabbixMetrics)
+	Interval time.Duration     // Data aggregation interval (default: 60s)
+	Timeout  time.Duration     // Connection timeout
+}
+
+// StreamConfig holds Kafka/NATS streaming output configuration
+type StreamConfig struct {
+	Enabled   bool          // Enable streaming output
+	Transport string        // "kafka" or "nats"
+	Brokers   []string      // Kafka bootstrap brokers (host:port) or NATS servers (nats://[user:pass@]host:port)
+	Topic     string        // Kafka topic or NATS subject ({router} and {interface} are replaced)
+	Format    string        // Message encoding: "json" or "avro" (single-object encoding)
+	TLS       bool          // Connect with TLS
+	ClientID  string        // Kafka client.id / NATS connection name
+	KafkaAcks int           // Kafka acknowledgements: 1 (leader) or -1 (all in-sync replicas)
+	Timeout   time.Duration // Connect and request timeout
+	QueueSize int           // Samples kept while the broker is unreachable (oldest dropped first)
+}
+
+// SessionsConfig holds PPP/hotspot session collector configuration
+type SessionsConfig struct {
+	Enabled  bool          // Enable session collector
+	Interval time.Duration // Polling interval (default: 5s)
+	PPP      bool          // Poll /ppp/active
+	Hotspot  bool          // Poll /ip/hotspot/active
+}
+
+// HealthConfig holds router health (system resource) collector configuration
+type HealthConfig struct {
+	Enabled  bool          // Enable system resource collector
+	Interval time.Duration // Polling interval (default: 30s)
+}
+
+// LinkSpeedConfig holds link speed (utilization) collector configuration
+type LinkSpeedConfig struct {
+	Enabled   bool              // Enable link speed collector
+	Interval  time.Duration     // Polling interval (default: 60s)
+	Overrides map[string]string // Interface -> speed ("100M", "1G"), wins over the router's value
+}
+
+// MembersConfig holds bridge/bond member port discovery configuration
+type MembersConfig struct {
+	Enabled  bool          // Also monitor the member ports of monitored bridges and bonds
+	Interval time.Duration // How often the members are rediscovered (default: 5m)
+}
+
+// TrunksConfig holds VLAN trunk (VLAN -> parent interface) tracking configuration
+type TrunksConfig struct {
+	Enabled  bool          // Sum the monitored VLANs per parent interface
+	Interval time.Duration // How often the VLAN parents are rediscovered (default: 5m)
+}
+
+// TopTalkersConfig holds top talkers (torch) collector configuration
+type TopTalkersConfig struct {
+	Enabled    bool          // Enable top talkers collector
+	Interfaces []string      // Interfaces to run torch on (default: INTERFACES)
+	Interval   time.Duration // Time between torch runs (default: 30s)
+	Duration   time.Duration // Length of each torch run (default: 3s)
+	Limit      int           // Hosts kept per list (default: 10, max: 100)
+}
+
+// PingConfig holds latency probe (RouterOS ping) configuration
+type PingConfig struct {
+	Enabled        bool          // Enable latency probes
+	Targets        []string      // Addresses pinged from the router (e.g. the upstream gateway, 1.1.1.1)
+	Interval       time.Duration // Time between ping runs (default: 30s)
+	Count          int           // Echo requests per target and run (default: 5)
+	PacketInterval time.Duration // Time between echo requests (default: 1s)
+}
 
+// CAPsMANConfig holds CAPsMAN (per-AP/SSID WiFi) collector configuration
+type CAPsMANConfig struct {
+	Enabled  bool          // Enable CAPsMAN collector
+	Interval time.Duration // Polling interval (default: 10s)
+}
+
+// NetwatchConfig holds netwatch collector configuration
+type NetwatchConfig struct {
+	Enabled  bool          // Enable netwatch collector
+	Interval time.Duration // Polling interval (default: 10s)
+}
+
+// AuditConfig holds management session audit configuration
+type AuditConfig struct {
+	Enabled       bool          // Enable audit collector
+	Interval      time.Duration // Polling interval (default: 30s)
+	LogTopics     []string      // Log topics searched for login messages (default: system)
+	FailureWindow time.Duration // Window for the recent login failure counts (default: 15m)
+}
+
+// ClientsConfig holds DHCP lease / ARP client directory configuration
+type ClientsConfig struct {
+	Enabled  bool          // Enable client collector
+	Interval time.Duration // Polling interval (default: 60s)
+}
+
+// FlowConfig holds NetFlow/IPFIX receiver configuration
+type FlowConfig struct {
+	Enabled          bool          // Enable flow receiver
+	Listen           string        // UDP listen address (default: :2055)
+	Window           time.Duration // Aggregation window (default: 60s)
+	Top              int           // Conversations kept per window (default: 20)
+	MaxConversations int           // Conversations tracked per window; further ones are dropped (default: 10000)
+	SamplingRate     int           // Multiplier for sampled v9/IPFIX exports (default: 1)
+	AllowedExporters []string      // Accept exports only from these IPs (empty = any)
+}
+
+// EventsConfig holds interface state change event configuration
+type EventsConfig struct {
+	Enabled        bool          // Enable state change detection
+	WebhookURL     string        // POST each event as JSON here (optional)
+	WebhookTimeout time.Duration // Webhook request timeout (default: 5s)
+}
+
+// AlertsConfig holds rate alert (threshold, zero traffic, anomaly) webhook configuration
+type AlertsConfig struct {
+	Enabled            bool              // Enable alerting
+	WebhookURL         string            // Receiver URL
+	WebhookMethod      string            // HTTP method (default: POST)
+	WebhookTemplate    string            // Go text/template for the body (default: the alert as JSON)
+	WebhookContentType string            // Content-Type header (default: application/json)
+	WebhookHeaders     map[string]string // Extra request headers (e.g., Authorization)
+	WebhookTimeout     time.Duration     // Webhook request timeout (default: 5s)
+	Targets            []string          // Default targets: webhook, telegram, slack, discord (empty: all configured)
+	Thresholds         string            // Rate rules: "ether1:download>800M,*:upload<1M@telegram"
+	ZeroInterfaces     []string          // Alert when these interfaces stop passing traffic ("*" = all)
+	ZeroDuration       time.Duration     // How long traffic must be zero (default: 30s)
+	AnomalyInterfaces  []string          // Alert on rates far from the rolling average ("*" = all)
+	AnomalySigma       float64           // Standard deviations that count as an anomaly (default: 3)
+	AnomalyWindow      int               // Rolling window in samples (default: 300)
+	NetwatchHosts      []string          // Alert when these netwatch hosts or names go down ("*" = all, needs NETWATCH_ENABLED)
+	Cooldown           time.Duration     // Minimum time between notifications of one alert (default: 5m)
+}
+
+// NotifyConfig holds chat notifier (Telegram, Slack, Discord) and daily summary configuration
+type NotifyConfig struct {
+	TelegramToken     string        // Bot token from @BotFather
+	TelegramChatID    string        // Chat, group or channel ID
+	TelegramAPIURL    string        // Bot API base URL (default: https://api.telegram.org)
+	SlackWebhookURL   string        // Slack incoming webhook
+	DiscordWebhookURL string        // Discord channel webhook
+	Timeout           time.Duration // Request timeout (default: 10s)
+
+	SummaryEnabled bool     // Post a daily traffic summary
+	SummaryTime    string   // Local time of day, "HH:MM" (default: 00:00)
+	SummaryHour    int      // Parsed SummaryTime
+	SummaryMinute  int      // Parsed SummaryTime
+	SummaryTargets []string // Notifiers receiving the summary (default: all)
+}
+
+// ReportConfig holds scheduled usage report configuration
+type ReportConfig struct {
+	Enabled    bool     // Enable scheduled reports (requires VictoriaMetrics)
+	Schedule   string   // Cron expression (default: "0 8 * * *", daily at 08:00)
+	Period     string   // Period covered: day (yesterday), week (last 7 days) or month (last calendar month)
+	Interfaces []string // Interfaces to report (empty: all stored)
+	Percentile float64  // Percentile of 5-minute averages (default: 95)
+	Targets    []string // telegram, slack, discord, email, file (default: everything configured)
+	Dir        string   // Directory for text/CSV reports ("file" target)
+
+	// Email ("email" target)
+	EmailTo      []string
+	SMTPHost     string
+	SMTPPort     int // Default: 587 (STARTTLS when offered)
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+}
+
+// PercentileConfig holds percentile (burstable billing) configuration
+type PercentileConfig struct {
+	Enabled        bool          // Enable percentile tracking
+	Percentile     float64       // Percentile to compute (default: 95)
+	SampleInterval time.Duration // Averaging bucket size (default: 5m)
+	Window         string        // "month" (calendar month) or a rolling duration such as "720h"
+	WindowDuration time.Duration // Parsed rolling window (0 for "month")
+}
+
+// StateConfig holds the persisted monitor state configuration
+type StateConfig struct {
+	Enabled  bool          // Persist state (default: true)
+	File     string        // State file (default: data/state.json)
+	Interval time.Duration // How often the state is saved (default: 1m, also saved on shutdown)
+	MaxAge   time.Duration // Older state is ignored on startup (default: 24h)
+}
+
+// BillingConfig holds the per-customer billing configuration
+type BillingConfig struct {
+	Enabled    bool    // Enable /api/billing (requires VictoriaMetrics)
+	Customers  string  // Customer -> interfaces: "acme=vlan100+vlan101,globex=vlan200"
+	Percentile float64 // Percentile of 5-minute averages (default: 95)
+	Dir        string  // Monthly CSV/JSON exports (empty: /api/billing only)
+}
+
+// ShaperConfig holds the traffic shaper (queue limit controller) configuration
+type ShaperConfig struct {
+	Enabled   bool          // Enable the controller (default: false)
+	DryRun    bool          // Log and audit actions without changing queues (default: true)
+	Interval  time.Duration // How often policies are evaluated (default: 1m)
+	Policies  string        // Quota rules: "vlan2622:month>500G=customer-a:10M/10M"
+	StateFile string        // Usage and caps across restarts (default: data/shaper.json)
+	AuditFile string        // JSON lines audit trail (default: data/shaper-audit.jsonl)
+}
+
+// ScheduleConfig holds the interface schedule (enable/disable windows) configuration
+type ScheduleConfig struct {
+	Enabled   bool          // Enable the scheduler (default: false)
+	DryRun    bool          // Log and audit actions without changing interfaces (default: true)
+	Interval  time.Duration // How often the windows are checked (default: 30s)
+	Windows   string        // "vlan-guest=0 23 * * * until 0 7 * * *;..."
+	StateFile string        // Overrides across restarts (default: data/schedule.json)
+	AuditFile string        // JSON lines audit trail (default: data/schedule-audit.jsonl)
+}
+
+// TopologyConfig holds the site/router model of this monitor
+// Site and router are added to ExtraLabels, so every series carries them.
+type TopologyConfig struct {
+	Enabled       bool     // Enable WAN roll-ups
+	Site          string   // Site name (default: EXTRA_LABELS site)
+	Router        string   // Router name (default: EXTRA_LABELS router, else MIKROTIK_HOST)
+	WANInterfaces []string // Interfaces (or groups) summed as WAN traffic (default: uplink interfaces)
+}
+
+// LoadConfig loads configuration from .env file and environment variables
+func LoadConfig(envFile string) (*Config, error) {
 	// Load .env file if present (optional)
 	loadEnvFile(envFile)
 
@@ -89,7 +548,36 @@ func LoadConfig() (*Config, error) {
 	loadTerminalConfig(config)
 	loadLogConfig(config)
 	loadWebConfig(config)
+	loadProbeConfig(config)
 	loadVMConfig(config)
+	loadRetentionConfig(config)
+	loadOTLPConfig(config)
+	loadPushgatewayConfig(config)
+	loadStreamConfig(config)
+	loadRRDConfig(config)
+	loadZabbixConfig(config)
+	loadSessionsConfig(config)
+	loadHealthConfig(config)
+	loadLinkSpeedConfig(config)
+	loadMembersConfig(config)
+	loadTrunksConfig(config)
+	loadEventsConfig(config)
+	loadTopTalkersConfig(config)
+	loadPingConfig(config)
+	loadCAPsMANConfig(config)
+	loadNetwatchConfig(config)
+	loadAuditConfig(config)
+	loadFlowConfig(config)
+	loadClientsConfig(config)
+	loadNotifyConfig(config)
+	loadAlertsConfig(config)
+	loadReportConfig(config)
+	loadPercentileConfig(config)
+	loadBillingConfig(config)
+	loadStateConfig(config)
+	loadTopologyConfig(config)
+	loadShaperConfig(config)
+	loadScheduleConfig(config)
 
 	// Validate configuration
 	if err := config.Validate(); err != nil {
@@ -101,23 +589,97 @@ func LoadConfig() (*Config, error) {
 
 // loadCoreConfig loads required core configuration
 func loadCoreConfig(config *Config) error {
-	config.Host = os.Getenv("MIKROTIK_HOST")
+	config.Host = normalizeRouterHost(os.Getenv("MIKROTIK_HOST"))
 	config.Port = os.Getenv("MIKROTIK_PORT")
 	config.Username = os.Getenv("MIKROTIK_USERNAME")
-	config.Password = os.Getenv("MIKROTIK_PASSWORD")
+	loadMockConfig(config)
+
+	// The simulated router needs no address or credentials
+	if config.Mock != nil {
+		if config.Host == "" {
+			config.Host = "mock"
+		}
+		if config.Username == "" {
+			config.Username = "admin"
+		}
+	}
+
+	if config.Host == "" || config.Username == "" {
+		return fmt.Errorf("missing required environment variables: MIKROTIK_HOST, MIKROTIK_USERNAME")
+	}
+
+	password, source, err := loadPassword(config.Username)
+	if err != nil {
+		return err
+	}
+	if password == "" && config.Mock == nil {
+		return fmt.Errorf("missing router password: set MIKROTIK_PASSWORD, MIKROTIK_PASSWORD_FILE or MIKROTIK_PASSWORD_KEYRING=true")
+	}
+	config.Password = password
+	config.CredentialSource = source
 
-	if config.Host == "" || config.Port == "" || config.Username == "" || config.Password == "" {
-		return fmt.Errorf("missing required environment variables: MIKROTIK_HOST, MIKROTIK_PORT, MIKROTIK_USERNAME, MIKROTIK_PASSWORD")
+	config.Transport = getEnvOrDefault("MIKROTIK_TRANSPORT", "api")
+	config.RESTInsecure = parseBool(os.Getenv("MIKROTIK_REST_INSECURE"), false)
+	config.TLS = parseBool(os.Getenv("MIKROTIK_TLS"), false)
+	config.TLSInsecure = parseBool(os.Getenv("MIKROTIK_TLS_INSECURE"), false)
+	config.SRV = parseBool(os.Getenv("MIKROTIK_SRV"), false)
+	config.LoginMethod = getEnvOrDefault("MIKROTIK_LOGIN", "auto")
+	config.Record = os.Getenv("MIKROTIK_RECORD")
+	if config.Port == "" {
+		config.Port = defaultRouterPort(config.Transport, config.TLS)
 	}
+	config.SNMPCommunity = getEnvOrDefault("SNMP_COMMUNITY", "public")
+	config.SNMPPort = getEnvOrDefault("SNMP_PORT", "161")
+	config.CommandTimeout = parseDuration(os.Getenv("MIKROTIK_TIMEOUT"), 10*time.Second)
+	config.ReadOnly = parseBool(os.Getenv("MIKROTIK_READ_ONLY"), true)
+	config.AllowedCommands = parseCommaSeparated(os.Getenv("MIKROTIK_ALLOWED_COMMANDS"), "")
 
 	config.Interfaces = parseCommaSeparated(os.Getenv("INTERFACES"), "vlan2622,vlan2624")
 	config.UplinkInterfaces = parseCommaSeparated(os.Getenv("UPLINK_INTERFACES"), "")
+	config.Groups = parseInterfaceGroups(os.Getenv("INTERFACE_GROUPS"))
+	config.PollInterval = parseDuration(os.Getenv("POLL_INTERVAL"), time.Second)
+	config.PollAlign = parseBool(os.Getenv("POLL_ALIGN"), false)
+	config.PollAdaptive = parseBool(os.Getenv("POLL_ADAPTIVE"), true)
+	config.PollMaxInterval = parseDuration(os.Getenv("POLL_MAX_INTERVAL"), 0)
 	config.StatsWindowSize = parseIntWithDefault(os.Getenv("STATS_WINDOW_SIZE"), 10, 1, 60)
 	config.Debug = parseBool(os.Getenv("DEBUG"), false)
+	config.Container = runningInContainer()
+	config.LogFormat = getEnvOrDefault("LOG_FORMAT", defaultLogFormat(config.Container))
+
+	level, err := parseLogLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		return err
+	}
+	if config.Debug {
+		level = slog.LevelDebug
+	}
+	config.LogLevel = level
+	config.OutputTimeout = parseDuration(os.Getenv("OUTPUT_TIMEOUT"), 5*time.Second)
+	config.ReloadInterval = parseDuration(os.Getenv("CONFIG_RELOAD_INTERVAL"), 5*time.Second)
+	config.ExtraLabels = parseKeyValuePairs(os.Getenv("EXTRA_LABELS"))
+	config.MetricPrefix = getEnvOrDefault("METRIC_PREFIX", "mikrotik_")
+	config.MetricUnit = strings.ToLower(getEnvOrDefault("METRIC_UNIT", "bytes"))
+	config.Timezone = getEnvOrDefault("TIMEZONE", "Local")
+	config.Location = loadLocation(config.Timezone)
 
 	return nil
 }
 
+// loadMockConfig loads the simulated router configuration
+func loadMockConfig(config *Config) {
+	enabled := parseBool(os.Getenv("MIKROTIK_MOCK"), false)
+	if !enabled {
+		config.Mock = nil
+		return
+	}
+
+	config.Mock = &MockConfig{
+		Enabled: true,
+		Trace:   os.Getenv("MIKROTIK_MOCK_TRACE"),
+		Period:  parseDuration(os.Getenv("MIKROTIK_MOCK_PERIOD"), 10*time.Minute),
+	}
+}
+
 // loadTerminalConfig loads terminal output configuration
 func loadTerminalConfig(config *Config) {
 	enabled := parseBool(os.Getenv("TERMINAL_ENABLED"), false)
@@ -131,6 +693,26 @@ func loadTerminalConfig(config *Config) {
 		Mode:      getEnvOrDefault("TERMINAL_MODE", "refresh"),
 		RateUnit:  getEnvOrDefault("TERMINAL_RATE_UNIT", "auto"),
 		RateScale: getEnvOrDefault("TERMINAL_RATE_SCALE", "auto"),
+
+		ExtraStats: parseBool(os.Getenv("TERMINAL_EXTRA_STATS"), false),
+
+		Highlight:  os.Getenv("TERMINAL_HIGHLIGHT_RATE"),
+		Sparklines: parseBool(os.Getenv("TERMINAL_SPARKLINES"), false),
+
+		Color:     strings.ToLower(getEnvOrDefault("TERMINAL_COLOR", "auto")),
+		ColorWarn: getEnvOrDefault("TERMINAL_COLOR_WARN", "70%"),
+		ColorCrit: getEnvOrDefault("TERMINAL_COLOR_CRIT", "90%"),
+
+		Columns:   parseCommaSeparated(strings.ToLower(os.Getenv("TERMINAL_COLUMNS")), ""),
+		NameWidth: parseIntWithDefault(os.Getenv("TERMINAL_NAME_WIDTH"), 0, 0, 64),
+
+		Sort:  strings.ToLower(getEnvOrDefault("TERMINAL_SORT", "name")),
+		Total: parseBool(os.Getenv("TERMINAL_TOTAL"), true),
+
+		Smoothing: loadSmoothingConfig("TERMINAL"),
+	}
+	if bits, err := parseRate(config.Terminal.Highlight); err == nil {
+		config.Terminal.HighlightRate = bits / 8
 	}
 }
 
@@ -146,10 +728,98 @@ func loadLogConfig(config *Config) {
 		Enabled:   true,
 		Output:    getEnvOrDefault("LOG_OUTPUT", "stdout"),
 		File:      getEnvOrDefault("LOG_FILE", "/var/log/mikrotik-stats.log"),
-		Format:    getEnvOrDefault("LOG_FORMAT", "text"),
+		Format:    getEnvOrDefault("LOG_FORMAT", defaultLogFormat(config.Container)),
 		RateUnit:  getEnvOrDefault("LOG_RATE_UNIT", "auto"),
 		RateScale: getEnvOrDefault("LOG_RATE_SCALE", "auto"),
+
+		MaxSizeMB:  parseIntWithDefault(os.Getenv("LOG_MAX_SIZE_MB"), 100, 0, 1<<20),
+		MaxBackups: parseIntWithDefault(os.Getenv("LOG_MAX_BACKUPS"), 7, 0, 10000),
+		MaxAgeDays: parseIntWithDefault(os.Getenv("LOG_MAX_AGE_DAYS"), 30, 0, 3650),
+		Compress:   parseBool(os.Getenv("LOG_COMPRESS"), true),
+
+		SyslogNetwork:  getEnvOrDefault("LOG_SYSLOG_NETWORK", "udp"),
+		SyslogFacility: strings.ToLower(getEnvOrDefault("LOG_SYSLOG_FACILITY", "daemon")),
+		SyslogSeverity: strings.ToLower(getEnvOrDefault("LOG_SYSLOG_SEVERITY", "info")),
+		SyslogTag:      getEnvOrDefault("LOG_SYSLOG_TAG", "mikrotik-stats"),
+	}
+
+	defaultAddress := "localhost:514"
+	if config.Log.SyslogNetwork == "unix" {
+		defaultAddress = "/dev/log"
+	}
+	config.Log.SyslogAddress = getEnvOrDefault("LOG_SYSLOG_ADDRESS", defaultAddress)
+	config.Log.Changes = loadChangeFilterConfig("LOG")
+	config.Log.Smoothing = loadSmoothingConfig("LOG")
+}
+
+// loadChangeFilterConfig loads the <prefix>_CHANGE_* settings of an output (nil unless a delta is set)
+func loadChangeFilterConfig(prefix string) *ChangeFilterConfig {
+	delta, err := strconv.ParseFloat(os.Getenv(prefix+"_CHANGE_DELTA"), 64)
+	if err != nil || delta <= 0 {
+		return nil
+	}
+
+	changes := &ChangeFilterConfig{
+		Delta:     delta / 100,
+		MinChange: getEnvOrDefault(prefix+"_CHANGE_MIN_RATE", "10k"),
+		Heartbeat: parseDuration(os.Getenv(prefix+"_HEARTBEAT"), time.Minute),
+	}
+	if bits, err := parseRate(changes.MinChange); err == nil {
+		changes.MinChangeRate = bits / 8
+	}
+	return changes
+}
+
+// validate checks the <prefix>_CHANGE_* settings (nil = disabled, always valid)
+func (c *ChangeFilterConfig) validate(prefix string) error {
+	if c == nil {
+		return nil
+	}
+	if _, err := parseRate(c.MinChange); err != nil {
+		return fmt.Errorf("invalid %s_CHANGE_MIN_RATE: %s (e.g. '10k' or '1M' bits/s)", prefix, c.MinChange)
+	}
+	if c.Heartbeat < 0 {
+		return fmt.Errorf("%s_HEARTBEAT must not be negative", prefix)
+	}
+	return nil
+}
+
+// loadSmoothingConfig loads the <prefix>_SMOOTHING settings of an output (nil for "none")
+func loadSmoothingConfig(prefix string) *SmoothingConfig {
+	mode := strings.ToLower(getEnvOrDefault(prefix+"_SMOOTHING", "none"))
+	if mode == "none" {
+		return nil
 	}
+	alpha, err := strconv.ParseFloat(getEnvOrDefault(prefix+"_SMOOTHING_ALPHA", "0.3"), 64)
+	if err != nil {
+		alpha = -1 // Reported by validate
+	}
+	return &SmoothingConfig{Mode: mode, Alpha: alpha}
+}
+
+// validate checks the <prefix>_SMOOTHING settings (nil = disabled, always valid)
+func (c *SmoothingConfig) validate(prefix string) error {
+	if c == nil {
+		return nil
+	}
+	if c.Mode != "ewma" {
+		return fmt.Errorf("invalid %s_SMOOTHING: %s (must be 'none' or 'ewma')", prefix, c.Mode)
+	}
+	if c.Alpha <= 0 || c.Alpha > 1 {
+		return fmt.Errorf("invalid %s_SMOOTHING_ALPHA: must be greater than 0 and at most 1 (e.g. 0.3)", prefix)
+	}
+	return nil
+}
+
+// loadProbeConfig loads the separate health probe listener
+// In a container without the web UI, probes default to :8080 so orchestrators
+// can check the same port whether or not the UI is enabled.
+func loadProbeConfig(config *Config) {
+	defaultAddr := ""
+	if config.Container && config.Web == nil {
+		defaultAddr = ":8080"
+	}
+	config.ProbeListenAddr = getEnvOrDefault("PROBE_LISTEN_ADDR", defaultAddr)
 }
 
 // loadWebConfig loads web service configuration
@@ -166,7 +836,37 @@ func loadWebConfig(config *Config) {
 		EnableRealtime: parseBool(os.Getenv("WEB_ENABLE_REALTIME"), true),
 		EnableAPI:      parseBool(os.Getenv("WEB_ENABLE_API"), true),
 		EnableStatic:   parseBool(os.Getenv("WEB_ENABLE_STATIC"), true),
+		AuthUser:       os.Getenv("WEB_AUTH_USER"),
+		AuthPass:       os.Getenv("WEB_AUTH_PASS"),
+		AuthTokens:     parseCommaSeparated(os.Getenv("WEB_AUTH_TOKENS"), ""),
+		SessionTTL:     parseDuration(os.Getenv("WEB_SESSION_TTL"), 24*time.Hour),
+
+		BasePath:       normalizeBasePath(os.Getenv("WEB_BASE_PATH")),
+		CORSOrigins:    parseCommaSeparated(os.Getenv("WEB_CORS_ORIGINS"), ""),
+		TrustedProxies: parseCommaSeparated(os.Getenv("WEB_TRUSTED_PROXIES"), "127.0.0.1,::1"),
+		Compression:    parseBool(os.Getenv("WEB_COMPRESSION"), true),
+		AdminEnabled:   parseBool(os.Getenv("WEB_ADMIN_ENABLED"), false),
+
+		Theme:        strings.ToLower(getEnvOrDefault("WEB_THEME", "dark")),
+		DefaultRange: getEnvOrDefault("WEB_DEFAULT_RANGE", "24h"),
+		DefaultUnit:  strings.ToLower(getEnvOrDefault("WEB_DEFAULT_UNIT", "bits")),
+
+		AccessLog: parseBool(os.Getenv("WEB_ACCESS_LOG"), false),
+		RateLimit: parseIntWithDefault(os.Getenv("WEB_RATE_LIMIT"), 0, 0, 1000000),
+		RateBurst: parseIntWithDefault(os.Getenv("WEB_RATE_BURST"), 30, 1, 100000),
+
+		Changes:   loadChangeFilterConfig("WEB"),
+		Smoothing: loadSmoothingConfig("WEB"),
+	}
+}
+
+// normalizeBasePath converts "mikrotik/", "/mikrotik/" etc. to "/mikrotik" ("" for the root)
+func normalizeBasePath(value string) string {
+	value = strings.Trim(strings.TrimSpace(value), "/")
+	if value == "" {
+		return ""
 	}
+	return "/" + value
 }
 
 // loadVMConfig loads VictoriaMetrics configuration
@@ -183,105 +883,1512 @@ func loadVMConfig(config *Config) {
 		Interval:   parseDuration(os.Getenv("VM_INTERVAL"), 10*time.Second),
 		Timeout:    parseDuration(os.Getenv("VM_TIMEOUT"), 5*time.Second),
 		RetryCount: parseIntWithDefault(os.Getenv("VM_RETRY_COUNT"), 3, 0, 10),
+		QueueSize:  parseIntWithDefault(os.Getenv("VM_QUEUE_SIZE"), 100, 1, 100000),
+		BatchSize:  parseIntWithDefault(os.Getenv("VM_BATCH_SIZE"), 20, 1, 1000),
+
+		Protocol:       getEnvOrDefault("VM_PROTOCOL", "import"),
+		RemoteWriteURL: os.Getenv("VM_REMOTE_WRITE_URL"),
+		Compression:    getEnvOrDefault("VM_COMPRESSION", "none"),
+
+		HistogramBuckets: os.Getenv("VM_HISTOGRAM_BUCKETS"),
+
+		AuthUser:    os.Getenv("VM_AUTH_USER"),
+		AuthPass:    os.Getenv("VM_AUTH_PASS"),
+		AuthToken:   os.Getenv("VM_AUTH_TOKEN"),
+		AuthHeaders: parseKeyValuePairs(os.Getenv("VM_AUTH_HEADERS")),
+
+		SpoolMaxBytes: int64(parseIntWithDefault(os.Getenv("VM_SPOOL_MAX_MB"), 512, 1, 1<<20)) << 20,
+	}
+	if config.VictoriaMetrics.RemoteWriteURL == "" {
+		config.VictoriaMetrics.RemoteWriteURL = strings.TrimSuffix(config.VictoriaMetrics.URL, "/") + "/api/v1/write"
+	}
+	if parseBool(os.Getenv("VM_SPOOL_ENABLED"), true) {
+		config.VictoriaMetrics.SpoolDir = getEnvOrDefault("VM_SPOOL_DIR", filepath.Join(defaultDataDir, "spool"))
 	}
 }
 
-// Validate validates the configuration
-func (c *Config) Validate() error {
-	// Check for output conflicts: Terminal + Log(stdout) will cause display issues
-	if c.Terminal != nil && c.Terminal.Enabled && c.Log != nil && c.Log.Enabled && c.Log.Output == "stdout" {
-		return fmt.Errorf("TERMINAL_ENABLED and LOG_ENABLED with LOG_OUTPUT=stdout cannot both be true (output conflict)")
+// loadRetentionConfig loads the history retention configuration
+func loadRetentionConfig(config *Config) {
+	enabled := parseBool(os.Getenv("RETENTION_ENABLED"), false)
+	if !enabled {
+		config.Retention = nil
+		return
 	}
 
-	// Validate terminal config
-	if c.Terminal != nil {
-		if c.Terminal.Mode != "refresh" && c.Terminal.Mode != "append" {
-			return fmt.Errorf("invalid TERMINAL_MODE: %s (must be 'refresh' or 'append')", c.Terminal.Mode)
-		}
+	config.Retention = &RetentionConfig{
+		Enabled:         true,
+		RollupStep:      parseDuration(os.Getenv("RETENTION_ROLLUP_STEP"), 5*time.Minute),
+		RawRetention:    parseDuration(os.Getenv("RETENTION_RAW"), 168*time.Hour),
+		RollupRetention: parseDuration(os.Getenv("RETENTION_ROLLUP"), 0),
+		Interval:        parseDuration(os.Getenv("RETENTION_INTERVAL"), 10*time.Minute),
+		DeleteAuthKey:   os.Getenv("RETENTION_DELETE_AUTH_KEY"),
 	}
+}
 
-	// Validate log config
-	if c.Log != nil {
-		if c.Log.Output != "stdout" && c.Log.Output != "file" {
-			return fmt.Errorf("invalid LOG_OUTPUT: %s (must be 'stdout' or 'file')", c.Log.Output)
-		}
-		if c.Log.Output == "file" && c.Log.File == "" {
-			return fmt.Errorf("LOG_FILE must be specified when LOG_OUTPUT=file")
-		}
-		if c.Log.Format != "json" && c.Log.Format != "text" {
-			return fmt.Errorf("invalid LOG_FORMAT: %s (must be 'json' or 'text')", c.Log.Format)
-		}
+// loadOTLPConfig loads OpenTelemetry exporter configuration
+// Standard OTEL_EXPORTER_OTLP_* and OTEL_RESOURCE_ATTRIBUTES variables are used as fallbacks
+func loadOTLPConfig(config *Config) {
+	enabled := parseBool(os.Getenv("OTLP_ENABLED"), false)
+	if !enabled {
+		config.OTLP = nil
+		return
 	}
 
-	// Validate web config
-	if c.Web != nil {
-		// At least one web feature must be enabled
-		if !c.Web.EnableRealtime && !c.Web.EnableAPI && !c.Web.EnableStatic {
-			return fmt.Errorf("at least one web feature must be enabled (WEB_ENABLE_REALTIME, WEB_ENABLE_API, or WEB_ENABLE_STATIC)")
-		}
+	// Collectors listen for OTLP/HTTP on 4318 and OTLP/gRPC on 4317
+	protocol := getEnvOrDefault("OTLP_PROTOCOL", getEnvOrDefault("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf"))
+	defaultEndpoint := "http://localhost:4318"
+	if protocol == "grpc" {
+		defaultEndpoint = "http://localhost:4317"
 	}
 
-	// Validate VM config
-	if c.VictoriaMetrics != nil {
-		if c.VictoriaMetrics.URL == "" {
-			return fmt.Errorf("VM_URL must be specified when VM_ENABLED=true")
-		}
-		if c.VictoriaMetrics.Interval < 1*time.Second {
-			return fmt.Errorf("VM_INTERVAL must be at least 1 second")
-		}
-	}
+	config.OTLP = &OTLPConfig{
+		Enabled:  true,
+		Endpoint: getEnvOrDefault("OTLP_ENDPOINT", getEnvOrDefault("OTEL_EXPORTER_OTLP_ENDPOINT", defaultEndpoint)),
+		Protocol: protocol,
+		Interval: parseDuration(os.Getenv("OTLP_INTERVAL"), 10*time.Second),
+		Timeout:  parseDuration(os.Getenv("OTLP_TIMEOUT"), 5*time.Second),
 
-	return nil
+		Headers:            parseKeyValuePairs(getEnvOrDefault("OTLP_HEADERS", os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))),
+		ResourceAttributes: parseKeyValuePairs(getEnvOrDefault("OTLP_RESOURCE_ATTRIBUTES", os.Getenv("OTEL_RESOURCE_ATTRIBUTES"))),
+	}
 }
 
-// ============================================================================
-// Helper Functions
-// ============================================================================
+// loadPushgatewayConfig loads Prometheus Pushgateway output configuration
+func loadPushgatewayConfig(config *Config) {
+	enabled := parseBool(os.Getenv("PUSHGATEWAY_ENABLED"), false)
+	if !enabled {
+		config.Pushgateway = nil
+		return
+	}
 
-// loadEnvFile loads environment variables from a file
-func loadEnvFile(filename string) {
-	file, err := os.Open(filename)
-	if err != nil {
-		fmt.Printf("[Config] No %s file found (optional)\n", filename)
-		return // File doesn't exist, use environment variables only
+	config.Pushgateway = &PushgatewayConfig{
+		Enabled:      true,
+		URL:          getEnvOrDefault("PUSHGATEWAY_URL", "http://localhost:9091"),
+		Mode:         strings.ToLower(getEnvOrDefault("PUSHGATEWAY_MODE", "pushgateway")),
+		Job:          getEnvOrDefault("PUSHGATEWAY_JOB", "mikrotik-stats"),
+		Grouping:     parseKeyValuePairs(os.Getenv("PUSHGATEWAY_GROUPING")),
+		Interval:     parseDuration(os.Getenv("PUSHGATEWAY_INTERVAL"), 10*time.Second),
+		Timeout:      parseDuration(os.Getenv("PUSHGATEWAY_TIMEOUT"), 5*time.Second),
+		Headers:      parseKeyValuePairs(os.Getenv("PUSHGATEWAY_HEADERS")),
+		DeleteOnStop: parseBool(os.Getenv("PUSHGATEWAY_DELETE_ON_STOP"), false),
 	}
-	defer file.Close()
-	fmt.Printf("[Config] Loading configuration from: %s\n", filename)
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
+// loadRRDConfig loads RRDtool / MRTG log output configuration
+func loadRRDConfig(config *Config) {
+	enabled := parseBool(os.Getenv("RRD_ENABLED"), false)
+	if !enabled {
+		config.RRD = nil
+		return
+	}
 
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			// Only set if not already in environment
-			if os.Getenv(key) == "" {
-				os.Setenv(key, value)
-			}
-		}
+	config.RRD = &RRDConfig{
+		Enabled:  true,
+		Format:   strings.ToLower(getEnvOrDefault("RRD_FORMAT", "rrdtool")),
+		Dir:      getEnvOrDefault("RRD_DIR", "rrd"),
+		Interval: parseDuration(os.Getenv("RRD_INTERVAL"), 5*time.Minute),
+		RRDTool:  getEnvOrDefault("RRD_RRDTOOL", "rrdtool"),
+		Daemon:   os.Getenv("RRD_DAEMON"),
+		DSIn:     getEnvOrDefault("RRD_DS_IN", "ds0"),
+		DSOut:    getEnvOrDefault("RRD_DS_OUT", "ds1"),
+		Create:   parseBool(os.Getenv("RRD_CREATE"), true),
 	}
 }
 
-// getEnvOrDefault returns environment variable value or default
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// loadZabbixConfig loads Zabbix sender output configuration
+func loadZabbixConfig(config *Config) {
+	enabled := parseBool(os.Getenv("ZABBIX_ENABLED"), false)
+	if !enabled {
+		config.Zabbix = nil
+		return
 	}
-	return defaultValue
-}
 
-// parseCommaSeparated parses a comma-separated string into a slice
-func parseCommaSeparated(value, defaultValue string) []string {
-	if value == "" {
-		value = defaultValue
+	// Per-metric keys have their own variables, as item keys often contain commas
+	keys := make(map[string]string)
+	for _, metric := range zabbixMetricNames() {
+		if key := os.Getenv("ZABBIX_KEY_" + strings.ToUpper(metric)); key != "" {
+			keys[metric] = key
+		}
 	}
-	if value == "" {
-		return []string{}
+
+	config.Zabbix = &ZabbixConfig{
+		Enabled:  true,
+		Server:   getEnvOrDefault("ZABBIX_SERVER", "localhost:10051"),
+		Host:     getEnvOrDefault("ZABBIX_HOST", "{router}"),
+		Hosts:    parseKeyValuePairs(os.Getenv("ZABBIX_HOSTS")),
+		Key:      getEnvOrDefault("ZABBIX_KEY", "mikrotik.if.{metric}[{interface}]"),
+		Keys:     keys,
+		Metrics:  parseCommaSeparated(strings.ToLower(os.Getenv("ZABBIX_METRICS")), "rx_rate,tx_rate"),
+		Interval: parseDuration(os.Getenv("ZABBIX_INTERVAL"), 60*time.Second),
+		Timeout:  parseDuration(os.Getenv("ZABBIX_TIMEOUT"), 5*time.Second),
+	}
+}
+
+// loadStreamConfig loads Kafka/NATS streaming output configuration
+func loadStreamConfig(config *Config) {
+	enabled := parseBool(os.Getenv("STREAM_ENABLED"), false)
+	if !enabled {
+		config.Stream = nil
+		return
+	}
+
+	transport := strings.ToLower(getEnvOrDefault("STREAM_TRANSPORT", "kafka"))
+	defaultBrokers, defaultTopic := "localhost:9092", "mikrotik.interface.samples"
+	if transport == "nats" {
+		defaultBrokers, defaultTopic = "nats://localhost:4222", "mikrotik.samples.{router}.{interface}"
+	}
+
+	acks := strings.ToLower(getEnvOrDefault("STREAM_KAFKA_ACKS", "1"))
+	if acks == "all" {
+		acks = "-1"
+	}
+	kafkaAcks, err := strconv.Atoi(acks)
+	if err != nil {
+		kafkaAcks = 0 // Rejected by Validate
+	}
+
+	config.Stream = &StreamConfig{
+		Enabled:   true,
+		Transport: transport,
+		Brokers:   parseCommaSeparated(os.Getenv("STREAM_BROKERS"), defaultBrokers),
+		Topic:     getEnvOrDefault("STREAM_TOPIC", defaultTopic),
+		Format:    strings.ToLower(getEnvOrDefault("STREAM_FORMAT", "json")),
+		TLS:       parseBool(os.Getenv("STREAM_TLS"), false),
+		ClientID:  getEnvOrDefault("STREAM_CLIENT_ID", "mikrotik-stats"),
+		KafkaAcks: kafkaAcks,
+		Timeout:   parseDuration(os.Getenv("STREAM_TIMEOUT"), 5*time.Second),
+		QueueSize: parseIntWithDefault(os.Getenv("STREAM_QUEUE_SIZE"), 600, 1, 86400),
+	}
+}
+
+// loadSessionsConfig loads PPP/hotspot session collector configuration
+func loadSessionsConfig(config *Config) {
+	enabled := parseBool(os.Getenv("SESSIONS_ENABLED"), false)
+	if !enabled {
+		config.Sessions = nil
+		return
+	}
+
+	config.Sessions = &SessionsConfig{
+		Enabled:  true,
+		Interval: parseDuration(os.Getenv("SESSIONS_INTERVAL"), 5*time.Second),
+		PPP:      parseBool(os.Getenv("SESSIONS_PPP"), true),
+		Hotspot:  parseBool(os.Getenv("SESSIONS_HOTSPOT"), true),
+	}
+}
+
+// loadHealthConfig loads router health collector configuration
+func loadHealthConfig(config *Config) {
+	enabled := parseBool(os.Getenv("HEALTH_ENABLED"), false)
+	if !enabled {
+		config.Health = nil
+		return
+	}
+
+	config.Health = &HealthConfig{
+		Enabled:  true,
+		Interval: parseDuration(os.Getenv("HEALTH_INTERVAL"), 30*time.Second),
+	}
+}
+
+// loadLinkSpeedConfig loads link speed collector configuration
+func loadLinkSpeedConfig(config *Config) {
+	enabled := parseBool(os.Getenv("LINK_SPEED_ENABLED"), true)
+	if !enabled {
+		config.LinkSpeed = nil
+		return
+	}
+
+	config.LinkSpeed = &LinkSpeedConfig{
+		Enabled:   true,
+		Interval:  parseDuration(os.Getenv("LINK_SPEED_INTERVAL"), 60*time.Second),
+		Overrides: parseKeyValuePairs(os.Getenv("LINK_SPEEDS")),
+	}
+}
+
+// loadMembersConfig loads bridge/bond member port discovery configuration
+func loadMembersConfig(config *Config) {
+	enabled := parseBool(os.Getenv("INTERFACE_MEMBERS_ENABLED"), false)
+	if !enabled {
+		config.Members = nil
+		return
+	}
+
+	config.Members = &MembersConfig{
+		Enabled:  true,
+		Interval: parseDuration(os.Getenv("INTERFACE_MEMBERS_INTERVAL"), 5*time.Minute),
+	}
+}
+
+// loadTrunksConfig loads VLAN trunk tracking configuration
+func loadTrunksConfig(config *Config) {
+	enabled := parseBool(os.Getenv("TRUNKS_ENABLED"), false)
+	if !enabled {
+		config.Trunks = nil
+		return
+	}
+
+	config.Trunks = &TrunksConfig{
+		Enabled:  true,
+		Interval: parseDuration(os.Getenv("TRUNKS_INTERVAL"), 5*time.Minute),
+	}
+}
+
+// loadTopTalkersConfig loads top talkers (torch) collector configuration
+// Must run after the interface list is loaded (it is the default interface set)
+func loadTopTalkersConfig(config *Config) {
+	enabled := parseBool(os.Getenv("TOPTALKERS_ENABLED"), false)
+	if !enabled {
+		config.TopTalkers = nil
+		return
+	}
+
+	interfaces := parseCommaSeparated(os.Getenv("TOPTALKERS_INTERFACES"), "")
+	if len(interfaces) == 0 {
+		interfaces = config.Interfaces
+	}
+
+	config.TopTalkers = &TopTalkersConfig{
+		Enabled:    true,
+		Interfaces: interfaces,
+		Interval:   parseDuration(os.Getenv("TOPTALKERS_INTERVAL"), 30*time.Second),
+		Duration:   parseDuration(os.Getenv("TOPTALKERS_DURATION"), 3*time.Second),
+		Limit:      parseIntWithDefault(os.Getenv("TOPTALKERS_LIMIT"), 10, 1, 100),
+	}
+}
+
+// loadPingConfig loads latency probe configuration
+func loadPingConfig(config *Config) {
+	enabled := parseBool(os.Getenv("PING_ENABLED"), false)
+	if !enabled {
+		config.Ping = nil
+		return
+	}
+
+	config.Ping = &PingConfig{
+		Enabled:        true,
+		Targets:        parseCommaSeparated(os.Getenv("PING_TARGETS"), ""),
+		Interval:       parseDuration(os.Getenv("PING_INTERVAL"), 30*time.Second),
+		Count:          parseIntWithDefault(os.Getenv("PING_COUNT"), 5, 1, 100),
+		PacketInterval: parseDuration(os.Getenv("PING_PACKET_INTERVAL"), 1*time.Second),
+	}
+}
+
+// loadCAPsMANConfig loads CAPsMAN collector configuration
+func loadCAPsMANConfig(config *Config) {
+	enabled := parseBool(os.Getenv("CAPSMAN_ENABLED"), false)
+	if !enabled {
+		config.CAPsMAN = nil
+		return
+	}
+
+	config.CAPsMAN = &CAPsMANConfig{
+		Enabled:  true,
+		Interval: parseDuration(os.Getenv("CAPSMAN_INTERVAL"), 10*time.Second),
+	}
+}
+
+// loadNetwatchConfig loads netwatch collector configuration
+func loadNetwatchConfig(config *Config) {
+	enabled := parseBool(os.Getenv("NETWATCH_ENABLED"), false)
+	if !enabled {
+		config.Netwatch = nil
+		return
+	}
+
+	config.Netwatch = &NetwatchConfig{
+		Enabled:  true,
+		Interval: parseDuration(os.Getenv("NETWATCH_INTERVAL"), 10*time.Second),
+	}
+}
+
+// loadAuditConfig loads management session audit configuration
+func loadAuditConfig(config *Config) {
+	enabled := parseBool(os.Getenv("AUDIT_ENABLED"), false)
+	if !enabled {
+		config.Audit = nil
+		return
+	}
+
+	config.Audit = &AuditConfig{
+		Enabled:       true,
+		Interval:      parseDuration(os.Getenv("AUDIT_INTERVAL"), 30*time.Second),
+		LogTopics:     parseCommaSeparated(strings.ToLower(os.Getenv("AUDIT_LOG_TOPICS")), "system"),
+		FailureWindow: parseDuration(os.Getenv("AUDIT_FAILURE_WINDOW"), 15*time.Minute),
+	}
+}
+
+// loadClientsConfig loads DHCP lease / ARP client directory configuration
+func loadClientsConfig(config *Config) {
+	enabled := parseBool(os.Getenv("CLIENTS_ENABLED"), false)
+	if !enabled {
+		config.Clients = nil
+		return
+	}
+
+	config.Clients = &ClientsConfig{
+		Enabled:  true,
+		Interval: parseDuration(os.Getenv("CLIENTS_INTERVAL"), 60*time.Second),
+	}
+}
+
+// loadFlowConfig loads NetFlow/IPFIX receiver configuration
+func loadFlowConfig(config *Config) {
+	enabled := parseBool(os.Getenv("FLOW_ENABLED"), false)
+	if !enabled {
+		config.Flows = nil
+		return
+	}
+
+	config.Flows = &FlowConfig{
+		Enabled:          true,
+		Listen:           getEnvOrDefault("FLOW_LISTEN", ":2055"),
+		Window:           parseDuration(os.Getenv("FLOW_WINDOW"), 60*time.Second),
+		Top:              parseIntWithDefault(os.Getenv("FLOW_TOP"), 20, 1, 1000),
+		MaxConversations: parseIntWithDefault(os.Getenv("FLOW_MAX_CONVERSATIONS"), 10000, 100, 1000000),
+		SamplingRate:     parseIntWithDefault(os.Getenv("FLOW_SAMPLING_RATE"), 1, 1, 65535),
+		AllowedExporters: parseCommaSeparated(os.Getenv("FLOW_ALLOWED_EXPORTERS"), ""),
+	}
+}
+
+// loadEventsConfig loads interface state change event configuration
+func loadEventsConfig(config *Config) {
+	enabled := parseBool(os.Getenv("EVENTS_ENABLED"), true)
+	if !enabled {
+		config.Events = nil
+		return
+	}
+
+	config.Events = &EventsConfig{
+		Enabled:        true,
+		WebhookURL:     os.Getenv("EVENTS_WEBHOOK_URL"),
+		WebhookTimeout: parseDuration(os.Getenv("EVENTS_WEBHOOK_TIMEOUT"), 5*time.Second),
+	}
+}
+
+// loadNotifyConfig loads chat notifier configuration (nil if no service is configured)
+func loadNotifyConfig(config *Config) {
+	notify := &NotifyConfig{
+		TelegramToken:     os.Getenv("TELEGRAM_BOT_TOKEN"),
+		TelegramChatID:    os.Getenv("TELEGRAM_CHAT_ID"),
+		TelegramAPIURL:    getEnvOrDefault("TELEGRAM_API_URL", "https://api.telegram.org"),
+		SlackWebhookURL:   os.Getenv("SLACK_WEBHOOK_URL"),
+		DiscordWebhookURL: os.Getenv("DISCORD_WEBHOOK_URL"),
+		Timeout:           parseDuration(os.Getenv("NOTIFY_TIMEOUT"), 10*time.Second),
+		SummaryEnabled:    parseBool(os.Getenv("SUMMARY_ENABLED"), false),
+		SummaryTime:       getEnvOrDefault("SUMMARY_TIME", "00:00"),
+		SummaryTargets:    parseCommaSeparated(strings.ToLower(os.Getenv("SUMMARY_TARGETS")), ""),
+	}
+	if notify.TelegramToken == "" && notify.SlackWebhookURL == "" && notify.DiscordWebhookURL == "" {
+		config.Notify = nil
+		if notify.SummaryEnabled {
+			logWarn("Config", "SUMMARY_ENABLED=true ignored: no TELEGRAM_BOT_TOKEN, SLACK_WEBHOOK_URL or DISCORD_WEBHOOK_URL")
+		}
+		return
+	}
+
+	if t, err := time.Parse("15:04", notify.SummaryTime); err == nil {
+		notify.SummaryHour, notify.SummaryMinute = t.Hour(), t.Minute()
+	} else {
+		notify.SummaryHour = -1 // Reported by Validate
+	}
+	config.Notify = notify
+}
+
+// notifyTargets returns the names of the configured chat notifiers
+func (c *NotifyConfig) notifyTargets() map[string]bool {
+	targets := make(map[string]bool)
+	if c == nil {
+		return targets
+	}
+	targets["telegram"] = c.TelegramToken != ""
+	targets["slack"] = c.SlackWebhookURL != ""
+	targets["discord"] = c.DiscordWebhookURL != ""
+	return targets
+}
+
+// loadReportConfig loads scheduled usage report configuration
+func loadReportConfig(config *Config) {
+	enabled := parseBool(os.Getenv("REPORT_ENABLED"), false)
+	if !enabled {
+		config.Reports = nil
+		return
+	}
+
+	pct, err := strconv.ParseFloat(os.Getenv("REPORT_PERCENTILE"), 64)
+	if err != nil {
+		pct = 95
+	}
+
+	reports := &ReportConfig{
+		Enabled:      true,
+		Schedule:     getEnvOrDefault("REPORT_SCHEDULE", "0 8 * * *"),
+		Period:       strings.ToLower(getEnvOrDefault("REPORT_PERIOD", "day")),
+		Interfaces:   parseCommaSeparated(os.Getenv("REPORT_INTERFACES"), ""),
+		Percentile:   pct,
+		Targets:      parseCommaSeparated(strings.ToLower(os.Getenv("REPORT_TARGETS")), ""),
+		Dir:          os.Getenv("REPORT_DIR"),
+		EmailTo:      parseCommaSeparated(os.Getenv("REPORT_EMAIL_TO"), ""),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     parseIntWithDefault(os.Getenv("SMTP_PORT"), 587, 1, 65535),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:     os.Getenv("SMTP_FROM"),
+	}
+
+	// Default: every configured target
+	if len(reports.Targets) == 0 {
+		for name, ok := range config.Notify.notifyTargets() {
+			if ok {
+				reports.Targets = append(reports.Targets, name)
+			}
+		}
+		sort.Strings(reports.Targets)
+		if len(reports.EmailTo) > 0 {
+			reports.Targets = append(reports.Targets, "email")
+		}
+		if reports.Dir != "" {
+			reports.Targets = append(reports.Targets, "file")
+		}
+	}
+	config.Reports = reports
+}
+
+// loadAlertsConfig loads rate alert webhook configuration
+func loadAlertsConfig(config *Config) {
+	enabled := parseBool(os.Getenv("ALERTS_ENABLED"), false)
+	if !enabled {
+		config.Alerts = nil
+		return
+	}
+
+	sigma, err := strconv.ParseFloat(os.Getenv("ALERT_ANOMALY_SIGMA"), 64)
+	if err != nil {
+		sigma = 3
+	}
+
+	config.Alerts = &AlertsConfig{
+		Enabled:            true,
+		WebhookURL:         os.Getenv("ALERT_WEBHOOK_URL"),
+		WebhookMethod:      strings.ToUpper(getEnvOrDefault("ALERT_WEBHOOK_METHOD", "POST")),
+		WebhookTemplate:    os.Getenv("ALERT_WEBHOOK_TEMPLATE"),
+		WebhookContentType: getEnvOrDefault("ALERT_WEBHOOK_CONTENT_TYPE", "application/json"),
+		WebhookHeaders:     parseKeyValuePairs(os.Getenv("ALERT_WEBHOOK_HEADERS")),
+		WebhookTimeout:     parseDuration(os.Getenv("ALERT_WEBHOOK_TIMEOUT"), 5*time.Second),
+		Targets:            parseCommaSeparated(strings.ToLower(os.Getenv("ALERT_TARGETS")), ""),
+		Thresholds:         os.Getenv("ALERT_THRESHOLDS"),
+		ZeroInterfaces:     parseCommaSeparated(os.Getenv("ALERT_ZERO_INTERFACES"), ""),
+		ZeroDuration:       parseDuration(os.Getenv("ALERT_ZERO_DURATION"), 30*time.Second),
+		AnomalyInterfaces:  parseCommaSeparated(os.Getenv("ALERT_ANOMALY_INTERFACES"), ""),
+		AnomalySigma:       sigma,
+		AnomalyWindow:      parseIntWithDefault(os.Getenv("ALERT_ANOMALY_WINDOW"), 300, 10, 100000),
+		NetwatchHosts:      parseCommaSeparated(os.Getenv("ALERT_NETWATCH_HOSTS"), ""),
+		Cooldown:           parseDuration(os.Getenv("ALERT_COOLDOWN"), 5*time.Minute),
+	}
+}
+
+// loadPercentileConfig loads percentile (burstable billing) configuration
+func loadPercentileConfig(config *Config) {
+	enabled := parseBool(os.Getenv("PERCENTILE_ENABLED"), false)
+	if !enabled {
+		config.Percentile = nil
+		return
+	}
+
+	pct, err := strconv.ParseFloat(os.Getenv("PERCENTILE"), 64)
+	if err != nil {
+		pct = 95
+	}
+
+	window := getEnvOrDefault("PERCENTILE_WINDOW", "month")
+	var windowDuration time.Duration
+	if window != "month" {
+		windowDuration = parseDuration(window, 0)
+	}
+
+	config.Percentile = &PercentileConfig{
+		Enabled:        true,
+		Percentile:     pct,
+		SampleInterval: parseDuration(os.Getenv("PERCENTILE_SAMPLE_INTERVAL"), 5*time.Minute),
+		Window:         window,
+		WindowDuration: windowDuration,
+	}
+}
+
+// loadStateConfig loads the persisted monitor state configuration
+func loadStateConfig(config *Config) {
+	enabled := parseBool(os.Getenv("STATE_PERSIST_ENABLED"), true)
+	if !enabled {
+		config.State = nil
+		return
+	}
+
+	config.State = &StateConfig{
+		Enabled:  true,
+		File:     getEnvOrDefault("STATE_FILE", filepath.Join(defaultDataDir, "state.json")),
+		Interval: parseDuration(os.Getenv("STATE_PERSIST_INTERVAL"), time.Minute),
+		MaxAge:   parseDuration(os.Getenv("STATE_MAX_AGE"), 24*time.Hour),
+	}
+}
+
+// loadBillingConfig loads the per-customer billing configuration
+func loadBillingConfig(config *Config) {
+	enabled := parseBool(os.Getenv("BILLING_ENABLED"), false)
+	if !enabled {
+		config.Billing = nil
+		return
+	}
+
+	pct, err := strconv.ParseFloat(os.Getenv("BILLING_PERCENTILE"), 64)
+	if err != nil {
+		pct = 95
+	}
+	config.Billing = &BillingConfig{
+		Enabled:    true,
+		Customers:  os.Getenv("BILLING_CUSTOMERS"),
+		Percentile: pct,
+		Dir:        os.Getenv("BILLING_DIR"),
+	}
+}
+
+// loadShaperConfig loads the traffic shaper configuration
+func loadShaperConfig(config *Config) {
+	enabled := parseBool(os.Getenv("SHAPER_ENABLED"), false)
+	if !enabled {
+		config.Shaper = nil
+		return
+	}
+
+	config.Shaper = &ShaperConfig{
+		Enabled:   true,
+		DryRun:    parseBool(os.Getenv("SHAPER_DRY_RUN"), true),
+		Interval:  parseDuration(os.Getenv("SHAPER_INTERVAL"), time.Minute),
+		Policies:  os.Getenv("SHAPER_POLICIES"),
+		StateFile: getEnvOrDefault("SHAPER_STATE_FILE", filepath.Join(defaultDataDir, "shaper.json")),
+		AuditFile: getEnvOrDefault("SHAPER_AUDIT_FILE", filepath.Join(defaultDataDir, "shaper-audit.jsonl")),
+	}
+}
+
+// loadScheduleConfig loads the interface schedule configuration
+func loadScheduleConfig(config *Config) {
+	enabled := parseBool(os.Getenv("INTERFACE_SCHEDULE_ENABLED"), false)
+	if !enabled {
+		config.Schedule = nil
+		return
+	}
+
+	config.Schedule = &ScheduleConfig{
+		Enabled:   true,
+		DryRun:    parseBool(os.Getenv("INTERFACE_SCHEDULE_DRY_RUN"), true),
+		Interval:  parseDuration(os.Getenv("INTERFACE_SCHEDULE_INTERVAL"), 30*time.Second),
+		Windows:   os.Getenv("INTERFACE_SCHEDULE_WINDOWS"),
+		StateFile: getEnvOrDefault("INTERFACE_SCHEDULE_STATE_FILE", filepath.Join(defaultDataDir, "schedule.json")),
+		AuditFile: getEnvOrDefault("INTERFACE_SCHEDULE_AUDIT_FILE", filepath.Join(defaultDataDir, "schedule-audit.jsonl")),
+	}
+}
+
+// loadTopologyConfig loads the site/router model and adds its labels to ExtraLabels
+func loadTopologyConfig(config *Config) {
+	enabled := parseBool(os.Getenv("TOPOLOGY_ENABLED"), false)
+	if !enabled {
+		config.Topology = nil
+		return
+	}
+
+	router := config.ExtraLabels["router"]
+	if router == "" {
+		router = config.Host
+	}
+	config.Topology = &TopologyConfig{
+		Enabled:       true,
+		Site:          getEnvOrDefault("TOPOLOGY_SITE", config.ExtraLabels["site"]),
+		Router:        getEnvOrDefault("TOPOLOGY_ROUTER", router),
+		WANInterfaces: parseCommaSeparated(os.Getenv("TOPOLOGY_WAN_INTERFACES"), ""),
+	}
+
+	// A conflicting EXTRA_LABELS value is kept and reported by Validate
+	if config.ExtraLabels == nil {
+		config.ExtraLabels = make(map[string]string)
+	}
+	if _, ok := config.ExtraLabels["site"]; !ok && config.Topology.Site != "" {
+		config.ExtraLabels["site"] = config.Topology.Site
+	}
+	if _, ok := config.ExtraLabels["router"]; !ok && config.Topology.Router != "" {
+		config.ExtraLabels["router"] = config.Topology.Router
+	}
+}
+
+// Validate validates the configuration
+func (c *Config) Validate() error {
+	// Check for output conflicts: Terminal + Log(stdout) will cause display issues
+	if c.Terminal != nil && c.Terminal.Enabled && c.Log != nil && c.Log.Enabled && c.Log.Output == "stdout" {
+		return fmt.Errorf("TERMINAL_ENABLED and LOG_ENABLED with LOG_OUTPUT=stdout cannot both be true (output conflict)")
+	}
+
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		return fmt.Errorf("invalid LOG_FORMAT: %s (must be 'json' or 'text')", c.LogFormat)
+	}
+
+	// Validate transport
+	if c.Transport != "api" && c.Transport != "rest" && c.Transport != "snmp" {
+		return fmt.Errorf("invalid MIKROTIK_TRANSPORT: %s (must be 'api', 'rest' or 'snmp')", c.Transport)
+	}
+	if c.LoginMethod != "auto" && c.LoginMethod != "plain" && c.LoginMethod != "challenge" {
+		return fmt.Errorf("invalid MIKROTIK_LOGIN: %s (must be 'auto', 'plain' or 'challenge')", c.LoginMethod)
+	}
+
+	// Validate router address
+	if err := validateRouterHost(c.Host); err != nil {
+		return fmt.Errorf("invalid MIKROTIK_HOST: %v", err)
+	}
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid MIKROTIK_PORT: %s (must be 1-65535)", c.Port)
+	}
+	if c.SRV && c.Transport == "snmp" {
+		return fmt.Errorf("MIKROTIK_SRV requires MIKROTIK_TRANSPORT=api or rest")
+	}
+	if c.Record != "" && c.Transport != "api" {
+		return fmt.Errorf("MIKROTIK_RECORD requires MIKROTIK_TRANSPORT=api")
+	}
+	if c.Mock != nil {
+		if c.Transport != "api" || c.SRV {
+			return fmt.Errorf("MIKROTIK_MOCK requires MIKROTIK_TRANSPORT=api and MIKROTIK_SRV=false")
+		}
+		if c.Mock.Period < time.Second {
+			return fmt.Errorf("invalid MIKROTIK_MOCK_PERIOD: %v (must be at least 1s)", c.Mock.Period)
+		}
+		if c.Mock.Trace != "" {
+			if _, err := os.Stat(c.Mock.Trace); err != nil {
+				return fmt.Errorf("invalid MIKROTIK_MOCK_TRACE: %v", err)
+			}
+		}
+	}
+
+	for _, menu := range c.AllowedCommands {
+		if !strings.HasPrefix(menu, "/") {
+			return fmt.Errorf("invalid MIKROTIK_ALLOWED_COMMANDS entry: %s (must be a menu path like /ip/address)", menu)
+		}
+	}
+
+	// SNMP only provides interface counters
+	if c.Transport == "snmp" && (c.Sessions != nil || c.Health != nil || c.TopTalkers != nil || c.Clients != nil || c.Ping != nil || c.CAPsMAN != nil || c.Netwatch != nil || c.Audit != nil) {
+		return fmt.Errorf("SESSIONS_ENABLED, HEALTH_ENABLED, TOPTALKERS_ENABLED, CLIENTS_ENABLED, PING_ENABLED, CAPSMAN_ENABLED, NETWATCH_ENABLED and AUDIT_ENABLED require MIKROTIK_TRANSPORT=api or rest")
+	}
+
+	if c.PollInterval < time.Second || c.PollInterval > time.Minute {
+		return fmt.Errorf("invalid POLL_INTERVAL: %v (must be between 1s and 60s)", c.PollInterval)
+	}
+	if c.PollAdaptive && c.PollMaxInterval != 0 && (c.PollMaxInterval < c.PollInterval || c.PollMaxInterval > 10*time.Minute) {
+		return fmt.Errorf("invalid POLL_MAX_INTERVAL: %v (must be between POLL_INTERVAL and 10m)", c.PollMaxInterval)
+	}
+	if c.StatsWindowSize < 1 || c.StatsWindowSize > 60 {
+		return fmt.Errorf("invalid STATS_WINDOW_SIZE: %d (must be 1-60)", c.StatsWindowSize)
+	}
+	if c.OutputTimeout <= 0 {
+		return fmt.Errorf("OUTPUT_TIMEOUT must be positive")
+	}
+	if c.ReloadInterval < 0 {
+		return fmt.Errorf("CONFIG_RELOAD_INTERVAL must not be negative (0 disables watching)")
+	}
+	if c.ProbeListenAddr != "" {
+		if _, _, err := net.SplitHostPort(c.ProbeListenAddr); err != nil {
+			return fmt.Errorf("invalid PROBE_LISTEN_ADDR: %s (expected host:port or :port)", c.ProbeListenAddr)
+		}
+		if c.Web != nil && c.ProbeListenAddr == c.Web.ListenAddr {
+			return fmt.Errorf("PROBE_LISTEN_ADDR must differ from WEB_LISTEN_ADDR (the web server already serves /healthz)")
+		}
+	}
+
+	// Validate extra labels (Prometheus label name syntax, no clash with built-in labels)
+	for name := range c.ExtraLabels {
+		if !isValidLabelName(name) {
+			return fmt.Errorf("invalid EXTRA_LABELS: %q is not a valid label name", name)
+		}
+		switch name {
+		case "interface", "interval", "direction", "percentile", "window", "type", "user", "address", "sensor":
+			return fmt.Errorf("invalid EXTRA_LABELS: %q is reserved", name)
+		}
+	}
+
+	// Validate metric naming (the prefix must itself be a valid metric name)
+	if !isValidLabelName(c.MetricPrefix) {
+		return fmt.Errorf("invalid METRIC_PREFIX: %q (letters, digits and underscores, e.g. 'rtr1_')", c.MetricPrefix)
+	}
+	if c.MetricUnit != "bytes" && c.MetricUnit != "bits" {
+		return fmt.Errorf("invalid METRIC_UNIT: %s (must be 'bytes' or 'bits')", c.MetricUnit)
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("invalid TIMEZONE: %s (IANA zone such as 'Europe/Berlin', 'UTC' or 'Local')", c.Timezone)
+	}
+
+	// Validate interface groups
+	monitored := toSet(c.Interfaces)
+	groupNames := make(map[string]bool, len(c.Groups))
+	for _, group := range c.Groups {
+		if len(group.Members) == 0 {
+			return fmt.Errorf("invalid INTERFACE_GROUPS: group %q has no members", group.Name)
+		}
+		if monitored[group.Name] || groupNames[group.Name] {
+			return fmt.Errorf("invalid INTERFACE_GROUPS: duplicate name %q", group.Name)
+		}
+		groupNames[group.Name] = true
+		for _, member := range group.Members {
+			if !monitored[member] {
+				return fmt.Errorf("invalid INTERFACE_GROUPS: member %q of group %q is not in INTERFACES", member, group.Name)
+			}
+		}
+	}
+
+	// Validate billing config
+	if c.Billing != nil {
+		if c.VictoriaMetrics == nil {
+			return fmt.Errorf("BILLING_ENABLED=true requires VM_ENABLED=true (bills are built from the stored history)")
+		}
+		if c.Billing.Percentile <= 0 || c.Billing.Percentile > 100 {
+			return fmt.Errorf("BILLING_PERCENTILE must be between 0 and 100")
+		}
+		customers := parseInterfaceGroups(c.Billing.Customers)
+		if len(customers) == 0 {
+			return fmt.Errorf("BILLING_ENABLED=true requires BILLING_CUSTOMERS")
+		}
+		seen := make(map[string]bool, len(customers))
+		for _, customer := range customers {
+			if customer.Name == "" || len(customer.Members) == 0 {
+				return fmt.Errorf("invalid BILLING_CUSTOMERS: %q has no interfaces (expected customer=iface+iface)", customer.Name)
+			}
+			if seen[customer.Name] {
+				return fmt.Errorf("invalid BILLING_CUSTOMERS: customer %q is listed twice", customer.Name)
+			}
+			seen[customer.Name] = true
+			for _, member := range customer.Members {
+				if !monitored[member] && !groupNames[member] {
+					return fmt.Errorf("invalid BILLING_CUSTOMERS: %q is not in INTERFACES or INTERFACE_GROUPS", member)
+				}
+			}
+		}
+	}
+
+	// Validate shaper config
+	if c.Shaper != nil {
+		if c.Transport == "snmp" {
+			return fmt.Errorf("SHAPER_ENABLED requires MIKROTIK_TRANSPORT=api or rest")
+		}
+		if c.Shaper.Interval < 10*time.Second {
+			return fmt.Errorf("SHAPER_INTERVAL must be at least 10s")
+		}
+		policies, err := parseShaperPolicies(c.Shaper.Policies)
+		if err != nil {
+			return fmt.Errorf("invalid SHAPER_POLICIES: %v", err)
+		}
+		if len(policies) == 0 {
+			return fmt.Errorf("SHAPER_ENABLED=true requires SHAPER_POLICIES")
+		}
+		queues := make(map[string]bool, len(policies))
+		for _, policy := range policies {
+			if !monitored[policy.Interface] && !groupNames[policy.Interface] {
+				return fmt.Errorf("invalid SHAPER_POLICIES: %q is not in INTERFACES or INTERFACE_GROUPS", policy.Interface)
+			}
+			if queues[policy.Queue] {
+				return fmt.Errorf("invalid SHAPER_POLICIES: queue %q is used by more than one policy", policy.Queue)
+			}
+			queues[policy.Queue] = true
+		}
+	}
+
+	// Validate interface schedule config
+	if c.Schedule != nil {
+		if c.Transport == "snmp" {
+			return fmt.Errorf("INTERFACE_SCHEDULE_ENABLED requires MIKROTIK_TRANSPORT=api or rest")
+		}
+		if c.Schedule.Interval < 5*time.Second || c.Schedule.Interval > time.Minute {
+			return fmt.Errorf("INTERFACE_SCHEDULE_INTERVAL must be between 5s and 1m (windows are set to the minute)")
+		}
+		windows, err := parseScheduleWindows(c.Schedule.Windows)
+		if err != nil {
+			return fmt.Errorf("invalid INTERFACE_SCHEDULE_WINDOWS: %v", err)
+		}
+		if len(windows) == 0 {
+			return fmt.Errorf("INTERFACE_SCHEDULE_ENABLED=true requires INTERFACE_SCHEDULE_WINDOWS")
+		}
+		for _, window := range windows {
+			if groupNames[window.Interface] {
+				return fmt.Errorf("invalid INTERFACE_SCHEDULE_WINDOWS: %q is an interface group, not a router interface", window.Interface)
+			}
+		}
+	}
+
+	// Validate topology config
+	if c.Topology != nil {
+		if c.Topology.Site == "" {
+			return fmt.Errorf("TOPOLOGY_ENABLED=true requires TOPOLOGY_SITE (or a site in EXTRA_LABELS)")
+		}
+		if c.Topology.Router == "" {
+			return fmt.Errorf("TOPOLOGY_ENABLED=true requires TOPOLOGY_ROUTER")
+		}
+		for label, value := range map[string]string{"site": c.Topology.Site, "router": c.Topology.Router} {
+			if c.ExtraLabels[label] != value {
+				return fmt.Errorf("EXTRA_LABELS %s=%q conflicts with TOPOLOGY_%s=%q", label, c.ExtraLabels[label], strings.ToUpper(label), value)
+			}
+		}
+		for _, name := range c.Topology.WANInterfaces {
+			if !monitored[name] && !groupNames[name] {
+				return fmt.Errorf("invalid TOPOLOGY_WAN_INTERFACES: %q is not in INTERFACES or INTERFACE_GROUPS", name)
+			}
+		}
+	}
+
+	// Validate terminal config
+	if c.Terminal != nil {
+		if c.Terminal.Mode != "refresh" && c.Terminal.Mode != "append" {
+			return fmt.Errorf("invalid TERMINAL_MODE: %s (must be 'refresh' or 'append')", c.Terminal.Mode)
+		}
+		if _, err := parseRate(c.Terminal.Highlight); err != nil {
+			return fmt.Errorf("invalid TERMINAL_HIGHLIGHT_RATE: %s (e.g. '800M' or '1.5G' bits/s)", c.Terminal.Highlight)
+		}
+		if c.Terminal.Color != "auto" && c.Terminal.Color != "always" && c.Terminal.Color != "never" {
+			return fmt.Errorf("invalid TERMINAL_COLOR: %s (must be 'auto', 'always' or 'never')", c.Terminal.Color)
+		}
+		warn, err := parseColorThreshold(c.Terminal.ColorWarn)
+		if err != nil {
+			return fmt.Errorf("invalid TERMINAL_COLOR_WARN: %s (a utilization like '70%%' or a rate like '500M' bits/s)", c.Terminal.ColorWarn)
+		}
+		crit, err := parseColorThreshold(c.Terminal.ColorCrit)
+		if err != nil {
+			return fmt.Errorf("invalid TERMINAL_COLOR_CRIT: %s (a utilization like '90%%' or a rate like '800M' bits/s)", c.Terminal.ColorCrit)
+		}
+		if warn.set() && crit.set() {
+			if (warn.ratio > 0) != (crit.ratio > 0) {
+				return fmt.Errorf("TERMINAL_COLOR_WARN and TERMINAL_COLOR_CRIT must both be utilizations (%%) or both rates")
+			}
+			if warn.ratio > crit.ratio || warn.rate > crit.rate {
+				return fmt.Errorf("TERMINAL_COLOR_WARN (%s) must not be above TERMINAL_COLOR_CRIT (%s)", c.Terminal.ColorWarn, c.Terminal.ColorCrit)
+			}
+		}
+		for _, column := range c.Terminal.Columns {
+			if findTerminalColumn(column) < 0 {
+				return fmt.Errorf("invalid TERMINAL_COLUMNS entry: %s (available: %s)", column, strings.Join(terminalColumnKeys(), ", "))
+			}
+		}
+		if _, _, ok := parseTerminalSort(c.Terminal.Sort); !ok {
+			return fmt.Errorf("invalid TERMINAL_SORT: %s (must be 'name', 'upload', 'download' or a column name)", c.Terminal.Sort)
+		}
+		if c.Terminal.NameWidth > 0 && c.Terminal.NameWidth < 4 {
+			return fmt.Errorf("invalid TERMINAL_NAME_WIDTH: %d (must be 0 for auto or at least 4)", c.Terminal.NameWidth)
+		}
+		if err := c.Terminal.Smoothing.validate("TERMINAL"); err != nil {
+			return err
+		}
+	}
+
+	// Validate log config
+	if c.Log != nil {
+		if c.Log.Output != "stdout" && c.Log.Output != "file" && c.Log.Output != "syslog" {
+			return fmt.Errorf("invalid LOG_OUTPUT: %s (must be 'stdout', 'file' or 'syslog')", c.Log.Output)
+		}
+		if c.Log.Output == "syslog" {
+			if c.Log.SyslogNetwork != "udp" && c.Log.SyslogNetwork != "tcp" && c.Log.SyslogNetwork != "unix" {
+				return fmt.Errorf("invalid LOG_SYSLOG_NETWORK: %s (must be 'udp', 'tcp' or 'unix')", c.Log.SyslogNetwork)
+			}
+			if _, ok := syslogFacilities[c.Log.SyslogFacility]; !ok {
+				return fmt.Errorf("invalid LOG_SYSLOG_FACILITY: %s (e.g. 'daemon', 'user', 'local0'..'local7')", c.Log.SyslogFacility)
+			}
+			if _, ok := syslogSeverities[c.Log.SyslogSeverity]; !ok {
+				return fmt.Errorf("invalid LOG_SYSLOG_SEVERITY: %s (e.g. 'info', 'notice', 'warning')", c.Log.SyslogSeverity)
+			}
+		}
+		if c.Log.Output == "file" && c.Log.File == "" {
+			return fmt.Errorf("LOG_FILE must be specified when LOG_OUTPUT=file")
+		}
+		if c.Log.Format != "json" && c.Log.Format != "text" {
+			return fmt.Errorf("invalid LOG_FORMAT: %s (must be 'json' or 'text')", c.Log.Format)
+		}
+		if err := c.Log.Changes.validate("LOG"); err != nil {
+			return err
+		}
+		if err := c.Log.Smoothing.validate("LOG"); err != nil {
+			return err
+		}
+	}
+
+	// Validate web config
+	if c.Web != nil {
+		// At least one web feature must be enabled
+		if !c.Web.EnableRealtime && !c.Web.EnableAPI && !c.Web.EnableStatic {
+			return fmt.Errorf("at least one web feature must be enabled (WEB_ENABLE_REALTIME, WEB_ENABLE_API, or WEB_ENABLE_STATIC)")
+		}
+		if (c.Web.AuthUser == "") != (c.Web.AuthPass == "") {
+			return fmt.Errorf("WEB_AUTH_USER and WEB_AUTH_PASS must be set together")
+		}
+		if c.Web.AdminEnabled && c.Web.AuthUser == "" && len(c.Web.AuthTokens) == 0 {
+			return fmt.Errorf("WEB_ADMIN_ENABLED requires WEB_AUTH_USER/WEB_AUTH_PASS or WEB_AUTH_TOKENS")
+		}
+		if _, err := parseCIDRs(c.Web.TrustedProxies); err != nil {
+			return fmt.Errorf("invalid WEB_TRUSTED_PROXIES: %v", err)
+		}
+		switch c.Web.Theme {
+		case "dark", "light", "auto":
+		default:
+			return fmt.Errorf("invalid WEB_THEME: %s (must be 'dark', 'light' or 'auto')", c.Web.Theme)
+		}
+		switch c.Web.DefaultRange {
+		case "1h", "6h", "24h", "7d", "30d":
+		default:
+			return fmt.Errorf("invalid WEB_DEFAULT_RANGE: %s (must be 1h, 6h, 24h, 7d or 30d)", c.Web.DefaultRange)
+		}
+		if c.Web.DefaultUnit != "bits" && c.Web.DefaultUnit != "bytes" {
+			return fmt.Errorf("invalid WEB_DEFAULT_UNIT: %s (must be 'bits' or 'bytes')", c.Web.DefaultUnit)
+		}
+		for _, origin := range c.Web.CORSOrigins {
+			if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+				return fmt.Errorf("invalid WEB_CORS_ORIGINS entry: %s (e.g. 'https://grafana.example.com' or '*')", origin)
+			}
+		}
+		if err := c.Web.Changes.validate("WEB"); err != nil {
+			return err
+		}
+		if err := c.Web.Smoothing.validate("WEB"); err != nil {
+			return err
+		}
+	}
+
+	// Validate VM config
+	if c.VictoriaMetrics != nil {
+		if c.VictoriaMetrics.URL == "" {
+			return fmt.Errorf("VM_URL must be specified when VM_ENABLED=true")
+		}
+		if c.VictoriaMetrics.Interval < 1*time.Second {
+			return fmt.Errorf("VM_INTERVAL must be at least 1 second")
+		}
+		if (c.VictoriaMetrics.AuthUser == "") != (c.VictoriaMetrics.AuthPass == "") {
+			return fmt.Errorf("VM_AUTH_USER and VM_AUTH_PASS must be set together")
+		}
+		if c.VictoriaMetrics.Protocol != "import" && c.VictoriaMetrics.Protocol != "remote_write" {
+			return fmt.Errorf("invalid VM_PROTOCOL: %s (must be 'import' or 'remote_write')", c.VictoriaMetrics.Protocol)
+		}
+		switch c.VictoriaMetrics.Compression {
+		case "none":
+		case "gzip", "zstd":
+			if c.VictoriaMetrics.Protocol == "remote_write" {
+				return fmt.Errorf("VM_COMPRESSION=%s only applies to VM_PROTOCOL=import (remote_write is always snappy-compressed)", c.VictoriaMetrics.Compression)
+			}
+		default:
+			return fmt.Errorf("invalid VM_COMPRESSION: %s (must be 'none', 'gzip' or 'zstd')", c.VictoriaMetrics.Compression)
+		}
+		if _, err := parseHistogramBuckets(c.VictoriaMetrics.HistogramBuckets); err != nil {
+			return fmt.Errorf("invalid VM_HISTOGRAM_BUCKETS: %v", err)
+		}
+	}
+
+	// Validate retention config
+	if c.Retention != nil {
+		if c.VictoriaMetrics == nil {
+			return fmt.Errorf("RETENTION_ENABLED=true requires VM_ENABLED=true (the tiers are stored in VictoriaMetrics)")
+		}
+		step, interval := c.Retention.RollupStep, c.VictoriaMetrics.Interval
+		if step <= interval || step%interval != 0 || step%time.Second != 0 {
+			return fmt.Errorf("invalid RETENTION_ROLLUP_STEP: %v (must be a multiple of VM_INTERVAL %v, in whole seconds)", step, interval)
+		}
+		if c.Retention.RawRetention < 24*time.Hour {
+			return fmt.Errorf("RETENTION_RAW must be at least 24h")
+		}
+		if c.Retention.RollupRetention != 0 && c.Retention.RollupRetention <= c.Retention.RawRetention {
+			return fmt.Errorf("RETENTION_ROLLUP must be 0 (keep) or longer than RETENTION_RAW")
+		}
+		if c.Retention.Interval < time.Minute || c.Retention.Interval >= c.Retention.RawRetention/2 {
+			return fmt.Errorf("RETENTION_INTERVAL must be at least 1m and less than half of RETENTION_RAW")
+		}
+	}
+
+	// Validate OTLP config
+	if c.OTLP != nil {
+		switch c.OTLP.Protocol {
+		case "http/protobuf", "http/json", "grpc":
+		default:
+			return fmt.Errorf("invalid OTLP_PROTOCOL: %s (must be 'http/protobuf', 'http/json' or 'grpc')", c.OTLP.Protocol)
+		}
+		if !strings.HasPrefix(c.OTLP.Endpoint, "http://") && !strings.HasPrefix(c.OTLP.Endpoint, "https://") {
+			return fmt.Errorf("invalid OTLP_ENDPOINT: %s (must be an http:// or https:// URL)", c.OTLP.Endpoint)
+		}
+		if c.OTLP.Interval < 1*time.Second {
+			return fmt.Errorf("OTLP_INTERVAL must be at least 1 second")
+		}
+	}
+
+	// Validate Pushgateway config
+	if c.Pushgateway != nil {
+		if !strings.HasPrefix(c.Pushgateway.URL, "http://") && !strings.HasPrefix(c.Pushgateway.URL, "https://") {
+			return fmt.Errorf("invalid PUSHGATEWAY_URL: %s (must be an http:// or https:// URL)", c.Pushgateway.URL)
+		}
+		if c.Pushgateway.Mode != "pushgateway" && c.Pushgateway.Mode != "vmagent" {
+			return fmt.Errorf("invalid PUSHGATEWAY_MODE: %s (must be 'pushgateway' or 'vmagent')", c.Pushgateway.Mode)
+		}
+		if c.Pushgateway.Job == "" {
+			return fmt.Errorf("PUSHGATEWAY_JOB must not be empty")
+		}
+		for name := range c.Pushgateway.Grouping {
+			if !isValidLabelName(name) || name == "job" {
+				return fmt.Errorf("invalid PUSHGATEWAY_GROUPING label: %s (use PUSHGATEWAY_JOB for the job)", name)
+			}
+		}
+		if c.Pushgateway.Interval < 1*time.Second {
+			return fmt.Errorf("PUSHGATEWAY_INTERVAL must be at least 1 second")
+		}
+		if c.Pushgateway.DeleteOnStop && c.Pushgateway.Mode != "pushgateway" {
+			return fmt.Errorf("PUSHGATEWAY_DELETE_ON_STOP requires PUSHGATEWAY_MODE=pushgateway")
+		}
+	}
+
+	// Validate streaming config
+	if c.Stream != nil {
+		if c.Stream.Transport != "kafka" && c.Stream.Transport != "nats" {
+			return fmt.Errorf("invalid STREAM_TRANSPORT: %s (must be 'kafka' or 'nats')", c.Stream.Transport)
+		}
+		if len(c.Stream.Brokers) == 0 {
+			return fmt.Errorf("STREAM_BROKERS is empty")
+		}
+		if c.Stream.Transport == "kafka" {
+			for _, broker := range c.Stream.Brokers {
+				if _, _, err := net.SplitHostPort(broker); err != nil {
+					return fmt.Errorf("invalid STREAM_BROKERS entry: %s (expected host:port)", broker)
+				}
+			}
+			if c.Stream.KafkaAcks != 1 && c.Stream.KafkaAcks != -1 {
+				return fmt.Errorf("invalid STREAM_KAFKA_ACKS (must be 1 or all)")
+			}
+		}
+		if c.Stream.Topic == "" || strings.ContainsAny(c.Stream.Topic, " \t\r\n") {
+			return fmt.Errorf("invalid STREAM_TOPIC: %q", c.Stream.Topic)
+		}
+		if c.Stream.Format != "json" && c.Stream.Format != "avro" {
+			return fmt.Errorf("invalid STREAM_FORMAT: %s (must be 'json' or 'avro')", c.Stream.Format)
+		}
+		if c.Stream.Timeout <= 0 {
+			return fmt.Errorf("STREAM_TIMEOUT must be positive")
+		}
+	}
+
+	// Validate RRD config
+	if c.RRD != nil {
+		switch c.RRD.Format {
+		case "rrdtool":
+			if c.RRD.Interval < time.Second || c.RRD.Interval%time.Second != 0 {
+				return fmt.Errorf("invalid RRD_INTERVAL: %v (must be whole seconds)", c.RRD.Interval)
+			}
+			for name, ds := range map[string]string{"RRD_DS_IN": c.RRD.DSIn, "RRD_DS_OUT": c.RRD.DSOut} {
+				if !validDSName(ds) {
+					return fmt.Errorf("invalid %s: %q (1-19 letters, digits or underscores)", name, ds)
+				}
+			}
+			if c.RRD.DSIn == c.RRD.DSOut {
+				return fmt.Errorf("RRD_DS_IN and RRD_DS_OUT must differ")
+			}
+		case "mrtg":
+			if c.RRD.Interval != 5*time.Minute {
+				return fmt.Errorf("RRD_FORMAT=mrtg requires RRD_INTERVAL=5m (MRTG logs have 5-minute rows)")
+			}
+		default:
+			return fmt.Errorf("invalid RRD_FORMAT: %s (must be 'rrdtool' or 'mrtg')", c.RRD.Format)
+		}
+		if c.RRD.Dir == "" {
+			return fmt.Errorf("RRD_DIR is empty")
+		}
+	}
+
+	// Validate Zabbix config
+	if c.Zabbix != nil {
+		if _, _, err := net.SplitHostPort(c.Zabbix.Server); err != nil {
+			return fmt.Errorf("invalid ZABBIX_SERVER: %s (must be host:port)", c.Zabbix.Server)
+		}
+		if c.Zabbix.Host == "" {
+			return fmt.Errorf("ZABBIX_HOST is empty")
+		}
+		if len(c.Zabbix.Metrics) == 0 {
+			return fmt.Errorf("ZABBIX_METRICS is empty")
+		}
+		known := toSet(zabbixMetricNames())
+		for _, metric := range c.Zabbix.Metrics {
+			if !known[metric] {
+				return fmt.Errorf("invalid ZABBIX_METRICS entry: %s (must be one of %s)", metric, strings.Join(zabbixMetricNames(), ", "))
+			}
+			key := c.Zabbix.Key
+			if custom, ok := c.Zabbix.Keys[metric]; ok {
+				key = custom
+			} else if !strings.Contains(key, "{metric}") && len(c.Zabbix.Metrics) > 1 {
+				return fmt.Errorf("ZABBIX_KEY must contain {metric} unless ZABBIX_KEY_%s is set", strings.ToUpper(metric))
+			}
+			if key == "" {
+				return fmt.Errorf("empty Zabbix item key for %s", metric)
+			}
+		}
+		if c.Zabbix.Interval < 1*time.Second {
+			return fmt.Errorf("ZABBIX_INTERVAL must be at least 1 second")
+		}
+		if c.Zabbix.Timeout <= 0 {
+			return fmt.Errorf("ZABBIX_TIMEOUT must be positive")
+		}
+	}
+
+	// Validate sessions config
+	if c.Sessions != nil {
+		if !c.Sessions.PPP && !c.Sessions.Hotspot {
+			return fmt.Errorf("at least one session source must be enabled (SESSIONS_PPP or SESSIONS_HOTSPOT)")
+		}
+		if c.Sessions.Interval < 1*time.Second {
+			return fmt.Errorf("SESSIONS_INTERVAL must be at least 1 second")
+		}
+	}
+
+	// Validate top talkers config
+	if c.TopTalkers != nil {
+		if len(c.TopTalkers.Interfaces) == 0 {
+			return fmt.Errorf("TOPTALKERS_INTERFACES is empty")
+		}
+		if c.TopTalkers.Duration < 1*time.Second {
+			return fmt.Errorf("TOPTALKERS_DURATION must be at least 1 second")
+		}
+		// Torch blocks for the whole duration, so the command must not time out first
+		if c.TopTalkers.Duration >= c.CommandTimeout {
+			return fmt.Errorf("TOPTALKERS_DURATION (%v) must be shorter than MIKROTIK_TIMEOUT (%v)", c.TopTalkers.Duration, c.CommandTimeout)
+		}
+		if c.TopTalkers.Interval <= c.TopTalkers.Duration {
+			return fmt.Errorf("TOPTALKERS_INTERVAL must be longer than TOPTALKERS_DURATION")
+		}
+	}
+
+	// Validate latency probe config
+	if c.Ping != nil {
+		if len(c.Ping.Targets) == 0 {
+			return fmt.Errorf("PING_TARGETS is empty")
+		}
+		for _, target := range c.Ping.Targets {
+			if strings.ContainsAny(target, " =/") {
+				return fmt.Errorf("invalid PING_TARGETS entry: %s (must be an address or host name)", target)
+			}
+		}
+		if c.Ping.PacketInterval < 10*time.Millisecond || c.Ping.PacketInterval > 5*time.Second {
+			return fmt.Errorf("invalid PING_PACKET_INTERVAL: %v (must be between 10ms and 5s)", c.Ping.PacketInterval)
+		}
+		// Ping blocks until the last reply (or its timeout), so the command must not time out first
+		run := time.Duration(c.Ping.Count) * c.Ping.PacketInterval
+		if run >= c.CommandTimeout {
+			return fmt.Errorf("PING_COUNT x PING_PACKET_INTERVAL (%v) must be shorter than MIKROTIK_TIMEOUT (%v)", run, c.CommandTimeout)
+		}
+		if c.Ping.Interval <= run {
+			return fmt.Errorf("PING_INTERVAL must be longer than PING_COUNT x PING_PACKET_INTERVAL (%v)", run)
+		}
+	}
+
+	// Validate CAPsMAN config
+	if c.CAPsMAN != nil && c.CAPsMAN.Interval < 1*time.Second {
+		return fmt.Errorf("CAPSMAN_INTERVAL must be at least 1 second")
+	}
+
+	// Validate netwatch config
+	if c.Netwatch != nil && c.Netwatch.Interval < 1*time.Second {
+		return fmt.Errorf("NETWATCH_INTERVAL must be at least 1 second")
+	}
+
+	// Validate audit config
+	if c.Audit != nil {
+		if c.Audit.Interval < 1*time.Second {
+			return fmt.Errorf("AUDIT_INTERVAL must be at least 1 second")
+		}
+		if len(c.Audit.LogTopics) == 0 {
+			return fmt.Errorf("AUDIT_LOG_TOPICS must not be empty")
+		}
+		if c.Audit.FailureWindow < c.Audit.Interval {
+			return fmt.Errorf("AUDIT_FAILURE_WINDOW must be at least AUDIT_INTERVAL")
+		}
+	}
+
+	// Validate client directory config
+	if c.Clients != nil && c.Clients.Interval < 5*time.Second {
+		return fmt.Errorf("CLIENTS_INTERVAL must be at least 5 seconds")
+	}
+
+	// Validate flow receiver config
+	if c.Flows != nil {
+		if _, _, err := net.SplitHostPort(c.Flows.Listen); err != nil {
+			return fmt.Errorf("invalid FLOW_LISTEN: %s (expected host:port or :port)", c.Flows.Listen)
+		}
+		if c.Flows.Window < 10*time.Second {
+			return fmt.Errorf("FLOW_WINDOW must be at least 10 seconds")
+		}
+		for _, exporter := range c.Flows.AllowedExporters {
+			if net.ParseIP(exporter) == nil {
+				return fmt.Errorf("invalid FLOW_ALLOWED_EXPORTERS entry: %s (must be an IP address)", exporter)
+			}
+		}
+	}
+
+	// Validate percentile config
+	if c.Percentile != nil {
+		if c.Percentile.Percentile <= 0 || c.Percentile.Percentile > 100 {
+			return fmt.Errorf("PERCENTILE must be between 0 and 100")
+		}
+		if c.Percentile.SampleInterval < 1*time.Second {
+			return fmt.Errorf("PERCENTILE_SAMPLE_INTERVAL must be at least 1 second")
+		}
+		if c.Percentile.Window != "month" && c.Percentile.WindowDuration <= c.Percentile.SampleInterval {
+			return fmt.Errorf("invalid PERCENTILE_WINDOW: %s (must be 'month' or a duration longer than PERCENTILE_SAMPLE_INTERVAL)", c.Percentile.Window)
+		}
+	}
+
+	if c.State != nil {
+		if c.State.Interval < 1*time.Second {
+			return fmt.Errorf("STATE_PERSIST_INTERVAL must be at least 1 second")
+		}
+		if c.State.MaxAge <= 0 {
+			return fmt.Errorf("STATE_MAX_AGE must be positive")
+		}
+	}
+
+	// Validate health config
+	if c.Health != nil && c.Health.Interval < 1*time.Second {
+		return fmt.Errorf("HEALTH_INTERVAL must be at least 1 second")
+	}
+
+	// Validate events config
+	if c.Events != nil && c.Events.WebhookURL != "" {
+		if !strings.HasPrefix(c.Events.WebhookURL, "http://") && !strings.HasPrefix(c.Events.WebhookURL, "https://") {
+			return fmt.Errorf("invalid EVENTS_WEBHOOK_URL: %s (must be an http:// or https:// URL)", c.Events.WebhookURL)
+		}
+	}
+
+	// Validate notifier config
+	if c.Notify != nil {
+		if c.Notify.TelegramToken != "" && c.Notify.TelegramChatID == "" {
+			return fmt.Errorf("TELEGRAM_CHAT_ID must be specified with TELEGRAM_BOT_TOKEN")
+		}
+		for name, value := range map[string]string{
+			"TELEGRAM_API_URL":    c.Notify.TelegramAPIURL,
+			"SLACK_WEBHOOK_URL":   c.Notify.SlackWebhookURL,
+			"DISCORD_WEBHOOK_URL": c.Notify.DiscordWebhookURL,
+		} {
+			if value != "" && !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+				return fmt.Errorf("invalid %s: %s (must be an http:// or https:// URL)", name, value)
+			}
+		}
+		if c.Notify.SummaryEnabled && c.Notify.SummaryHour < 0 {
+			return fmt.Errorf("invalid SUMMARY_TIME: %s (use HH:MM, e.g. 08:00)", c.Notify.SummaryTime)
+		}
+		available := c.Notify.notifyTargets()
+		for _, target := range c.Notify.SummaryTargets {
+			if !available[target] {
+				return fmt.Errorf("invalid SUMMARY_TARGETS entry: %s (not configured; use telegram, slack or discord)", target)
+			}
+		}
+	}
+
+	// Validate report config
+	if c.Reports != nil {
+		if c.VictoriaMetrics == nil {
+			return fmt.Errorf("REPORT_ENABLED=true requires VM_ENABLED=true (reports are built from the stored history)")
+		}
+		schedule, err := parseCron(c.Reports.Schedule)
+		if err != nil {
+			return fmt.Errorf("invalid REPORT_SCHEDULE: %s (%v)", c.Reports.Schedule, err)
+		}
+		if schedule.Next(time.Now()).IsZero() {
+			return fmt.Errorf("invalid REPORT_SCHEDULE: %s (never matches)", c.Reports.Schedule)
+		}
+		if c.Reports.Period != "day" && c.Reports.Period != "week" && c.Reports.Period != "month" {
+			return fmt.Errorf("invalid REPORT_PERIOD: %s (must be 'day', 'week' or 'month')", c.Reports.Period)
+		}
+		if c.Reports.Percentile <= 0 || c.Reports.Percentile > 100 {
+			return fmt.Errorf("REPORT_PERCENTILE must be between 0 and 100")
+		}
+		if len(c.Reports.Targets) == 0 {
+			return fmt.Errorf("REPORT_ENABLED=true requires a target: a chat notifier, REPORT_EMAIL_TO or REPORT_DIR")
+		}
+		available := c.Notify.notifyTargets()
+		for _, target := range c.Reports.Targets {
+			switch {
+			case target == "file":
+				if c.Reports.Dir == "" {
+					return fmt.Errorf("REPORT_DIR must be specified for the file report target")
+				}
+			case target == "email":
+				if len(c.Reports.EmailTo) == 0 || c.Reports.SMTPHost == "" || c.Reports.SMTPFrom == "" {
+					return fmt.Errorf("the email report target requires REPORT_EMAIL_TO, SMTP_HOST and SMTP_FROM")
+				}
+			case !available[target]:
+				return fmt.Errorf("invalid REPORT_TARGETS entry: %s (not configured; use telegram, slack, discord, email or file)", target)
+			}
+		}
+	}
+
+	// Validate alerts config
+	if c.Alerts != nil {
+		if c.Alerts.WebhookURL == "" && c.Notify == nil {
+			return fmt.Errorf("ALERTS_ENABLED=true requires ALERT_WEBHOOK_URL or a chat notifier (TELEGRAM_BOT_TOKEN, SLACK_WEBHOOK_URL, DISCORD_WEBHOOK_URL)")
+		}
+		if c.Alerts.WebhookURL != "" && !strings.HasPrefix(c.Alerts.WebhookURL, "http://") && !strings.HasPrefix(c.Alerts.WebhookURL, "https://") {
+			return fmt.Errorf("invalid ALERT_WEBHOOK_URL: %s (must be an http:// or https:// URL)", c.Alerts.WebhookURL)
+		}
+		switch c.Alerts.WebhookMethod {
+		case "POST", "PUT", "PATCH", "GET":
+		default:
+			return fmt.Errorf("invalid ALERT_WEBHOOK_METHOD: %s (must be POST, PUT, PATCH or GET)", c.Alerts.WebhookMethod)
+		}
+		if _, err := parseAlertTemplate(c.Alerts.WebhookTemplate); err != nil {
+			return fmt.Errorf("invalid ALERT_WEBHOOK_TEMPLATE: %v", err)
+		}
+		rules, err := parseAlertRules(c.Alerts.Thresholds)
+		if err != nil {
+			return fmt.Errorf("invalid ALERT_THRESHOLDS: %v", err)
+		}
+		targets := c.Notify.notifyTargets()
+		targets["webhook"] = c.Alerts.WebhookURL != ""
+		for _, target := range c.Alerts.Targets {
+			if !targets[target] {
+				return fmt.Errorf("invalid ALERT_TARGETS entry: %s (not configured; use webhook, telegram, slack or discord)", target)
+			}
+		}
+		for _, rule := range rules {
+			for _, target := range rule.Targets {
+				if !targets[target] {
+					return fmt.Errorf("invalid ALERT_THRESHOLDS rule %s: target %s is not configured", rule, target)
+				}
+			}
+		}
+		if len(rules) == 0 && len(c.Alerts.ZeroInterfaces) == 0 && len(c.Alerts.AnomalyInterfaces) == 0 && len(c.Alerts.NetwatchHosts) == 0 {
+			return fmt.Errorf("ALERTS_ENABLED=true requires ALERT_THRESHOLDS, ALERT_ZERO_INTERFACES, ALERT_ANOMALY_INTERFACES or ALERT_NETWATCH_HOSTS")
+		}
+		if len(c.Alerts.NetwatchHosts) > 0 && c.Netwatch == nil {
+			return fmt.Errorf("ALERT_NETWATCH_HOSTS requires NETWATCH_ENABLED=true")
+		}
+		if c.Alerts.AnomalySigma <= 0 {
+			return fmt.Errorf("ALERT_ANOMALY_SIGMA must be greater than 0")
+		}
+	}
+
+	// Validate link speed config
+	if c.LinkSpeed != nil {
+		if c.LinkSpeed.Interval < 1*time.Second {
+			return fmt.Errorf("LINK_SPEED_INTERVAL must be at least 1 second")
+		}
+		for name, value := range c.LinkSpeed.Overrides {
+			if speed, err := parseRate(value); err != nil || speed <= 0 {
+				return fmt.Errorf("invalid LINK_SPEEDS entry: %s=%s (use a speed like 100M or 1G)", name, value)
+			}
+		}
+	}
+
+	if c.Members != nil {
+		if c.Transport == "snmp" {
+			return fmt.Errorf("INTERFACE_MEMBERS_ENABLED requires MIKROTIK_TRANSPORT=api or rest")
+		}
+		if c.Members.Interval < 10*time.Second {
+			return fmt.Errorf("INTERFACE_MEMBERS_INTERVAL must be at least 10s")
+		}
+	}
+
+	if c.Trunks != nil {
+		if c.Transport == "snmp" {
+			return fmt.Errorf("TRUNKS_ENABLED requires MIKROTIK_TRANSPORT=api or rest")
+		}
+		if c.Trunks.Interval < 10*time.Second {
+			return fmt.Errorf("TRUNKS_INTERVAL must be at least 10s")
+		}
+	}
+
+	return nil
+}
+
+// ============================================================================
+// Helper Functions
+// ============================================================================
+
+// loadEnvFile loads environment variables from a file
+//
+// Values taken from the file are remembered, so loading it again (configuration
+// reload) updates them and removes deleted keys, while variables set in the real
+// environment still take precedence.
+func loadEnvFile(filename string) {
+	if filename == "" {
+		return // --env="": environment variables only
+	}
+
+	// Logging is not set up yet; in a container these lines would break the JSON log stream.
+	// They go to stderr so commands printing JSON or rule files keep stdout clean.
+	quiet := runningInContainer()
+	file, err := os.Open(filename)
+	if err != nil {
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "[Config] No %s file found (optional)\n", filename)
+		}
+		return // File doesn't exist, use environment variables only
+	}
+	defer file.Close()
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "[Config] Loading configuration from: %s\n", filename)
+	}
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 {
+			key := strings.TrimSpace(parts[0])
+			value := strings.TrimSpace(parts[1])
+			seen[key] = true
+			// Only set if not already in environment (or set from this file before)
+			current := os.Getenv(key)
+			if previous, ok := envFileValues[key]; current == "" || (ok && current == previous) {
+				os.Setenv(key, value)
+				envFileValues[key] = value
+			}
+		}
+	}
+
+	// Keys removed from the file since the last load
+	for key, value := range envFileValues {
+		if !seen[key] && os.Getenv(key) == value {
+			os.Unsetenv(key)
+			delete(envFileValues, key)
+		}
+	}
+}
+
+// envFileValues are the variables set by loadEnvFile
+var envFileValues = make(map[string]string)
+
+// runningInContainer reports whether the process runs in a container
+// "container" is set by Podman, systemd-nspawn and LXC (and by the Dockerfile in
+// DEPLOYMENT.md), KUBERNETES_SERVICE_HOST by Kubernetes in every pod.
+func runningInContainer() bool {
+	return os.Getenv("container") != "" || os.Getenv("KUBERNETES_SERVICE_HOST") != ""
+}
+
+// defaultLogFormat is the LOG_FORMAT default: json for log collectors in a container
+func defaultLogFormat(container bool) string {
+	if container {
+		return "json"
+	}
+	return "text"
+}
+
+// getEnvOrDefault returns environment variable value or default
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// parseCommaSeparated parses a comma-separated string into a slice
+func parseCommaSeparated(value, defaultValue string) []string {
+	if value == "" {
+		value = defaultValue
+	}
+	if value == "" {
+		return []string{}
 	}
 
 	parts := strings.Split(value, ",")
@@ -294,6 +2401,50 @@ func parseCommaSeparated(value, defaultValue string) []string {
 	return result
 }
 
+// parseInterfaceGroups parses "name=member+member,name=member+member"
+func parseInterfaceGroups(value string) []InterfaceGroup {
+	var groups []InterfaceGroup
+	for _, entry := range parseCommaSeparated(value, "") {
+		parts := strings.SplitN(entry, "=", 2)
+		group := InterfaceGroup{Name: strings.TrimSpace(parts[0])}
+		if len(parts) == 2 {
+			for _, member := range strings.Split(parts[1], "+") {
+				if trimmed := strings.TrimSpace(member); trimmed != "" {
+					group.Members = append(group.Members, trimmed)
+				}
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// parseKeyValuePairs parses "key=value,key=value" into a map (entries without '=' are ignored)
+func parseKeyValuePairs(value string) map[string]string {
+	pairs := make(map[string]string)
+	for _, entry := range parseCommaSeparated(value, "") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) != "" {
+			pairs[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return pairs
+}
+
+// isValidLabelName checks Prometheus label name syntax: [a-zA-Z_][a-zA-Z0-9_]*
+func isValidLabelName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") {
+		return false
+	}
+	for i, c := range name {
+		letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
 // parseIntWithDefault parses an integer with min/max bounds
 func parseIntWithDefault(value string, defaultValue, min, max int) int {
 	if value == "" {
@@ -322,6 +2473,34 @@ func parseBool(value string, defaultValue bool) bool {
 	return value == "true" || value == "1"
 }
 
+// parseRate parses a rate in bits/s with an optional SI suffix (e.g. "500k", "100M", "1.5G")
+// An empty value parses as 0
+func parseRate(value string) (float64, error) {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "bps")
+	if value == "" {
+		return 0, nil
+	}
+
+	multiplier := 1.0
+	switch value[len(value)-1] {
+	case 'k', 'K':
+		multiplier = 1e3
+	case 'M':
+		multiplier = 1e6
+	case 'G':
+		multiplier = 1e9
+	}
+	if multiplier != 1 {
+		value = value[:len(value)-1]
+	}
+
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 {
+		return 0, fmt.Errorf("invalid rate: %s", value)
+	}
+	return rate * multiplier, nil
+}
+
 // parseDuration parses a duration value
 func parseDuration(value string, defaultValue time.Duration) time.Duration {
 	if value == "" {
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Interface State Changes (up/down/disabled/flap events)
+// ============================================================================
+
+// InterfaceState is the link state reported alongside the counters on /interface/print
+type InterfaceState struct {
+	Running   bool   // Link is up
+	Disabled  bool   // Administratively disabled
+	LinkDowns uint64 // Link-down counter since boot (catches flaps shorter than the poll interval)
+}
+
+// Up reports whether the interface is enabled and running
+func (s InterfaceState) Up() bool {
+	return s.Running && !s.Disabled
+}
+
+// upValue returns the mikrotik_interface_up gauge value (1 = up, 0 = down or disabled)
+func (s InterfaceState) upValue() int {
+	if s.Up() {
+		return 1
+	}
+	return 0
+}
+
+// String returns "up", "down" or "disabled"
+func (s InterfaceState) String() string {
+	switch {
+	case s.Disabled:
+		return "disabled"
+	case s.Running:
+		return "up"
+	default:
+		return "down"
+	}
+}
+
+// Interface event types
+const (
+	EventUp       = "up"       // Link came up
+	EventDown     = "down"     // Link went down
+	EventDisabled = "disabled" // Interface was disabled
+	EventEnabled  = "enabled"  // Interface was enabled
+	EventFlap     = "flap"     // Link went down and up again between two polls
+)
+
+// InterfaceEvent is a state transition of one interface
+type InterfaceEvent struct {
+	Time      time.Time `json:"time"`
+	Interface string    `json:"interface"`
+	Label     string    `json:"label,omitempty"` // User-defined label (if any)
+	Event     string    `json:"event"`           // up, down, disabled, enabled or flap
+	State     string    `json:"state"`           // State after the event: up, down or disabled
+	Previous  string    `json:"previous"`        // State before the event
+	LinkDowns uint64    `json:"link_downs"`      // Router link-down counter
+}
+
+// maxRecentEvents is the number of events kept for /api/events
+const maxRecentEvents = 100
+
+// InterfaceEventTracker detects state transitions from successive samples
+// and hands each event to the registered handlers (log, WebSocket, webhook)
+type InterfaceEventTracker struct {
+	last     map[string]InterfaceState // Only touched by the monitoring loop
+	labels   func(string) string       // Custom label of an interface ("" if none)
+	handlers []func(InterfaceEvent)
+
+	recent   []InterfaceEvent // Newest last
+	recentMu sync.RWMutex
+}
+
+// NewInterfaceEventTracker creates a tracker that logs every event
+// labels resolves the user-defined label attached to each event.
+func NewInterfaceEventTracker(labels func(string) string) *InterfaceEventTracker {
+	t := &InterfaceEventTracker{last: make(map[string]InterfaceState), labels: labels}
+	t.OnEvent(logInterfaceEvent)
+	return t
+}
+
+// OnEvent registers a handler called for every event (from the monitoring loop)
+func (t *InterfaceEventTracker) OnEvent(handler func(InterfaceEvent)) {
+	t.handlers = append(t.handlers, handler)
+}
+
+// Update compares the sample with the previous one and dispatches the resulting events
+// The first sample of an interface only sets the baseline.
+func (t *InterfaceEventTracker) Update(now time.Time, stats []InterfaceStats) {
+	for _, stat := range stats {
+		if stat.State == nil {
+			continue // Transport without state (SNMP) or virtual group
+		}
+		state := *stat.State
+		prev, ok := t.last[stat.Name]
+		t.last[stat.Name] = state
+		if !ok {
+			if !state.Up() {
+				logWarn("Events", "%s is %s", stat.Name, state)
+			}
+			continue
+		}
+
+		for _, event := range stateEvents(prev, state) {
+			t.dispatch(InterfaceEvent{
+				Time:      now,
+				Interface: stat.Name,
+				Label:     t.labels(stat.Name),
+				Event:     event,
+				State:     state.String(),
+				Previous:  prev.String(),
+				LinkDowns: state.LinkDowns,
+			})
+		}
+	}
+}
+
+// stateEvents returns the events between two states of an interface
+func stateEvents(prev, cur InterfaceState) []string {
+	var events []string
+	switch {
+	case !prev.Disabled && cur.Disabled:
+		events = append(events, EventDisabled)
+	case prev.Disabled && !cur.Disabled:
+		events = append(events, EventEnabled)
+	}
+	if cur.Disabled {
+		return events
+	}
+
+	switch {
+	case prev.Running && !cur.Running:
+		events = append(events, EventDown)
+	case !prev.Running && cur.Running:
+		events = append(events, EventUp)
+	case cur.Running && cur.LinkDowns > prev.LinkDowns:
+		// Running in both samples, but the link went down in between
+		events = append(events, EventFlap)
+	}
+	return events
+}
+
+// dispatch records an event and calls the handlers
+func (t *InterfaceEventTracker) dispatch(event InterfaceEvent) {
+	t.recentMu.Lock()
+	t.recent = append(t.recent, event)
+	if len(t.recent) > maxRecentEvents {
+		t.recent = t.recent[len(t.recent)-maxRecentEvents:]
+	}
+	t.recentMu.Unlock()
+
+	for _, handler := range t.handlers {
+		handler(event)
+	}
+}
+
+// Recent returns the latest events, newest first
+func (t *InterfaceEventTracker) Recent() []InterfaceEvent {
+	t.recentMu.RLock()
+	defer t.recentMu.RUnlock()
+
+	events := make([]InterfaceEvent, len(t.recent))
+	for i, event := range t.recent {
+		events[len(t.recent)-1-i] = event
+	}
+	return events
+}
+
+// logInterfaceEvent logs an event (down and flap as warnings)
+func logInterfaceEvent(event InterfaceEvent) {
+	switch event.Event {
+	case EventDown, EventFlap:
+		logWarn("Events", "%s: %s (%s -> %s, link-downs: %d)", event.Interface, event.Event, event.Previous, event.State, event.LinkDowns)
+	default:
+		logInfo("Events", "%s: %s (%s -> %s)", event.Interface, event.Event, event.Previous, event.State)
+	}
+}
+
+// eventWebhook posts events as JSON to EVENTS_WEBHOOK_URL
+type eventWebhook struct {
+	url    string
+	client *http.Client
+}
+
+// newEventWebhook creates a webhook sender
+func newEventWebhook(config *EventsConfig) *eventWebhook {
+	logInfo("Events", "Interface events are posted to %s", config.WebhookURL)
+	return &eventWebhook{url: config.WebhookURL, client: &http.Client{Timeout: config.WebhookTimeout}}
+}
+
+// Send posts one event in the background so a slow receiver never delays sampling
+func (h *eventWebhook) Send(event InterfaceEvent) {
+	go func() {
+		if err := h.post(event); err != nil {
+			logError("Events", "Webhook for %s %s failed: %v", event.Interface, event.Event, err)
+		}
+	}()
+}
+
+// post sends the event and checks the response status
+func (h *eventWebhook) post(event InterfaceEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mikrotik-interface-stats/"+Version)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
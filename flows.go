@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Flow Collector (NetFlow / IPFIX receiver)
+// ============================================================================
+
+// flowProtocols names the common IP protocols in the API and metrics
+var flowProtocols = map[uint8]string{
+	1:  "icmp",
+	6:  "tcp",
+	17: "udp",
+	47: "gre",
+	50: "esp",
+	58: "ipv6-icmp",
+}
+
+// flowProtocolName returns the protocol name, or its number if unnamed
+func flowProtocolName(protocol uint8) string {
+	if name, ok := flowProtocols[protocol]; ok {
+		return name
+	}
+	return strconv.Itoa(int(protocol))
+}
+
+// flowConversationKey identifies a conversation: address pair and protocol
+type flowConversationKey struct {
+	src, dst netip.Addr
+	protocol uint8
+}
+
+// flowCounters accumulates bytes, packets and flow records
+type flowCounters struct {
+	Bytes   uint64
+	Packets uint64
+	Flows   uint64
+}
+
+// FlowConversation is one conversation of a completed window
+type FlowConversation struct {
+	Source      string  `json:"source"`
+	Destination string  `json:"destination"`
+	Protocol    string  `json:"protocol"`
+	Bytes       uint64  `json:"bytes"`
+	Packets     uint64  `json:"packets"`
+	Flows       uint64  `json:"flows"`
+	Rate        float64 `json:"rate"` // bytes/s averaged over the window
+
+	// Client names from DHCP/ARP (filled in by the API when CLIENTS_ENABLED)
+	SourceName      string `json:"source_name,omitempty"`
+	DestinationName string `json:"destination_name,omitempty"`
+}
+
+// FlowExporterStats are the counters of one exporting router since startup
+type FlowExporterStats struct {
+	Datagrams uint64         `json:"datagrams"`
+	Records   uint64         `json:"records"`
+	Bytes     uint64         `json:"bytes"`
+	Errors    uint64         `json:"errors"`   // Undecodable datagrams or data sets
+	Versions  map[int]uint64 `json:"versions"` // Export version -> datagrams
+	LastSeen  time.Time      `json:"last_seen"`
+}
+
+// FlowSnapshot is the result of one completed aggregation window
+type FlowSnapshot struct {
+	Start         time.Time          `json:"start"`
+	End           time.Time          `json:"end"`
+	Conversations []FlowConversation `json:"conversations"` // Busiest first, at most FLOW_TOP
+	Total         int                `json:"total"`         // Conversations seen in the window
+	Dropped       uint64             `json:"dropped"`       // Records not tracked (FLOW_MAX_CONVERSATIONS reached)
+}
+
+// FlowCollector receives NetFlow v5/v9 and IPFIX exports over UDP and aggregates
+// them per conversation over fixed windows
+//
+// Configure the router with /ip/traffic-flow and a target pointing at FLOW_LISTEN.
+// Only the latest completed window is kept; its busiest conversations are served
+// on /api/flows and pushed to VictoriaMetrics, exporter counters on /metrics.
+type FlowCollector struct {
+	config   *FlowConfig
+	decoder  *flowDecoder
+	allowed  map[string]bool // Exporter IPs (nil = any)
+	sampling uint64          // Multiplier for sampled exports without a rate in the packet
+
+	mu            sync.Mutex
+	windowStart   time.Time
+	conversations map[flowConversationKey]*flowCounters
+	dropped       uint64
+	exporters     map[string]*FlowExporterStats
+
+	latest   *FlowSnapshot
+	latestMu sync.RWMutex
+}
+
+// NewFlowCollector creates a flow collector (call Run to start listening)
+func NewFlowCollector(config *FlowConfig) *FlowCollector {
+	logInfo("Flows", "Flow collector initialized (listen: %s, window: %v, top: %d)", config.Listen, config.Window, config.Top)
+
+	f := &FlowCollector{
+		config:        config,
+		decoder:       newFlowDecoder(),
+		sampling:      uint64(config.SamplingRate),
+		windowStart:   time.Now(),
+		conversations: make(map[flowConversationKey]*flowCounters),
+		exporters:     make(map[string]*FlowExporterStats),
+	}
+	if len(config.AllowedExporters) > 0 {
+		f.allowed = make(map[string]bool)
+		for _, exporter := range config.AllowedExporters {
+			f.allowed[net.ParseIP(exporter).String()] = true // Same form as the sender address
+		}
+	}
+	return f
+}
+
+// Run receives exports and completes a window every FLOW_WINDOW until ctx is cancelled
+// onWindow is called with each completed window (from the rotation goroutine).
+func (f *FlowCollector) Run(ctx context.Context, onWindow func(*FlowSnapshot)) error {
+	go func() {
+		ticker := time.NewTicker(f.config.Window)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				onWindow(f.Rotate(now))
+			}
+		}
+	}()
+
+	return f.serve(ctx)
+}
+
+// serve receives export datagrams until ctx is cancelled
+func (f *FlowCollector) serve(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", f.config.Listen)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", f.config.Listen, err)
+	}
+	logInfo("Flows", "Listening for NetFlow/IPFIX on %s", conn.LocalAddr())
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	// Largest possible UDP payload
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			logWarn("Flows", "Receive error: %v", err)
+			continue
+		}
+
+		exporter := addr.String()
+		if udp, ok := addr.(*net.UDPAddr); ok {
+			exporter = udp.IP.String()
+		}
+		if f.allowed != nil && !f.allowed[exporter] {
+			logDebug("Flows", "Ignoring datagram from %s (not in FLOW_ALLOWED_EXPORTERS)", exporter)
+			continue
+		}
+		f.handleDatagram(time.Now(), exporter, buf[:n])
+	}
+}
+
+// handleDatagram decodes one datagram and adds its records to the current window
+func (f *FlowCollector) handleDatagram(now time.Time, exporter string, data []byte) {
+	version, records, err := f.decoder.Decode(now, exporter, data)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats, ok := f.exporters[exporter]
+	if !ok {
+		logInfo("Flows", "First export from %s (version %d)", exporter, version)
+		stats = &FlowExporterStats{Versions: make(map[int]uint64)}
+		f.exporters[exporter] = stats
+	}
+	stats.Datagrams++
+	stats.Versions[version]++
+	stats.LastSeen = now
+	if err != nil {
+		stats.Errors++
+		// Data before the first template is expected right after startup
+		if errors.Is(err, errFlowTemplateMissing) {
+			logDebug("Flows", "Datagram from %s: %v", exporter, err)
+		} else {
+			logWarn("Flows", "Datagram from %s: %v", exporter, err)
+		}
+	}
+
+	// v5 carries its own sampling rate; v9/IPFIX exports use FLOW_SAMPLING_RATE
+	scale := uint64(1)
+	if version != 5 {
+		scale = f.sampling
+	}
+
+	for _, record := range records {
+		bytes, packets := record.Bytes*scale, record.Packets*scale
+		stats.Records++
+		stats.Bytes += bytes
+		if !record.SrcAddr.IsValid() || !record.DstAddr.IsValid() {
+			continue // Template without address fields
+		}
+
+		key := flowConversationKey{src: record.SrcAddr, dst: record.DstAddr, protocol: record.Protocol}
+		counters, ok := f.conversations[key]
+		if !ok {
+			if len(f.conversations) >= f.config.MaxConversations {
+				f.dropped++
+				continue
+			}
+			counters = &flowCounters{}
+			f.conversations[key] = counters
+		}
+		counters.Bytes += bytes
+		counters.Packets += packets
+		counters.Flows++
+	}
+}
+
+// Rotate completes the current window and starts a new one
+// It returns the snapshot of the completed window.
+func (f *FlowCollector) Rotate(now time.Time) *FlowSnapshot {
+	f.mu.Lock()
+	start := f.windowStart
+	conversations, dropped := f.conversations, f.dropped
+	f.windowStart = now
+	f.conversations = make(map[flowConversationKey]*flowCounters)
+	f.dropped = 0
+	f.mu.Unlock()
+
+	if dropped > 0 {
+		logWarn("Flows", "Dropped %d flow records in the last window (FLOW_MAX_CONVERSATIONS=%d reached)", dropped, f.config.MaxConversations)
+	}
+
+	seconds := now.Sub(start).Seconds()
+	ranked := make([]FlowConversation, 0, len(conversations))
+	for key, counters := range conversations {
+		conversation := FlowConversation{
+			Source:      key.src.String(),
+			Destination: key.dst.String(),
+			Protocol:    flowProtocolName(key.protocol),
+			Bytes:       counters.Bytes,
+			Packets:     counters.Packets,
+			Flows:       counters.Flows,
+		}
+		if seconds > 0 {
+			conversation.Rate = float64(counters.Bytes) / seconds
+		}
+		ranked = append(ranked, conversation)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Bytes != ranked[j].Bytes {
+			return ranked[i].Bytes > ranked[j].Bytes
+		}
+		if ranked[i].Source != ranked[j].Source {
+			return ranked[i].Source < ranked[j].Source
+		}
+		return ranked[i].Destination < ranked[j].Destination
+	})
+	if len(ranked) > f.config.Top {
+		ranked = ranked[:f.config.Top]
+	}
+
+	snapshot := &FlowSnapshot{
+		Start:         start,
+		End:           now,
+		Conversations: ranked,
+		Total:         len(conversations),
+		Dropped:       dropped,
+	}
+
+	f.latestMu.Lock()
+	f.latest = snapshot
+	f.latestMu.Unlock()
+
+	return snapshot
+}
+
+// Latest returns the most recent completed window (nil before the first one)
+func (f *FlowCollector) Latest() *FlowSnapshot {
+	f.latestMu.RLock()
+	defer f.latestMu.RUnlock()
+	return f.latest
+}
+
+// flowExporterMetrics renders the exporter counters in Prometheus text format
+func flowExporterMetrics(exporters map[string]FlowExporterStats) string {
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var datagrams, records, bytes, errs strings.Builder
+	for _, name := range names {
+		stats := exporters[name]
+		series := fmt.Sprintf("exporter=\"%s\"", escapeLabelValue(name))
+		fmt.Fprintf(&datagrams, "mikrotik_flow_datagrams_total{%s} %d\n", series, stats.Datagrams)
+		fmt.Fprintf(&records, "mikrotik_flow_records_total{%s} %d\n", series, stats.Records)
+		fmt.Fprintf(&bytes, "mikrotik_flow_bytes_total{%s} %d\n", series, stats.Bytes)
+		fmt.Fprintf(&errs, "mikrotik_flow_errors_total{%s} %d\n", series, stats.Errors)
+	}
+
+	var out strings.Builder
+	fmt.Fprintln(&out, "# HELP mikrotik_flow_datagrams_total NetFlow/IPFIX datagrams received")
+	fmt.Fprintln(&out, "# TYPE mikrotik_flow_datagrams_total counter")
+	fmt.Fprint(&out, datagrams.String())
+	fmt.Fprintln(&out, "# HELP mikrotik_flow_records_total Flow records decoded")
+	fmt.Fprintln(&out, "# TYPE mikrotik_flow_records_total counter")
+	fmt.Fprint(&out, records.String())
+	fmt.Fprintln(&out, "# HELP mikrotik_flow_bytes_total Bytes reported in flow records (scaled by the sampling rate)")
+	fmt.Fprintln(&out, "# TYPE mikrotik_flow_bytes_total counter")
+	fmt.Fprint(&out, bytes.String())
+	fmt.Fprintln(&out, "# HELP mikrotik_flow_errors_total Datagrams or data sets that could not be decoded")
+	fmt.Fprintln(&out, "# TYPE mikrotik_flow_errors_total counter")
+	fmt.Fprint(&out, errs.String())
+	return out.String()
+}
+
+// Exporters returns a copy of the per-exporter counters
+func (f *FlowCollector) Exporters() map[string]FlowExporterStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	exporters := make(map[string]FlowExporterStats, len(f.exporters))
+	for exporter, stats := range f.exporters {
+		versions := make(map[int]uint64, len(stats.Versions))
+		for version, count := range stats.Versions {
+			versions[version] = count
+		}
+		copied := *stats
+		copied.Versions = versions
+		exporters[exporter] = copied
+	}
+	return exporters
+}
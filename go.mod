@@ -1,5 +1,10 @@
 module github.com/firadio/golang-mikrotik-interface-stats
 
-go 1.21
+go 1.24
 
-require github.com/gorilla/websocket v1.5.3
+require (
+	github.com/golang/snappy v1.0.0
+	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.17.11
+	google.golang.org/protobuf v1.36.1
+)
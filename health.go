@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/firadio/golang-mikrotik-interface-stats/pkg/routeros"
+)
+
+// ============================================================================
+// System Resource Collector (router health)
+// ============================================================================
+
+// SystemResource holds router health metrics from /system/resource and /system/health
+type SystemResource struct {
+	Timestamp    time.Time          `json:"timestamp"`
+	CPULoad      float64            `json:"cpu_load"`       // Percent (0-100)
+	FreeMemory   uint64             `json:"free_memory"`    // Bytes
+	TotalMemory  uint64             `json:"total_memory"`   // Bytes
+	Uptime       int64              `json:"uptime_seconds"` // Seconds since router boot
+	Version      string             `json:"version"`        // RouterOS version string
+	BoardName    string             `json:"board_name"`     // Hardware model
+	Temperatures map[string]float64 `json:"temperatures"`   // Sensor name -> degrees Celsius
+	Voltages     map[string]float64 `json:"voltages"`       // Sensor name -> volts
+}
+
+// SystemResourceCollector polls /system/resource/print and /system/health/print
+type SystemResourceCollector struct {
+	client RouterClient
+	config *HealthConfig
+
+	noHealth atomic.Bool // /system/health does not exist on this router (e.g. CHR)
+
+	latest   *SystemResource
+	latestMu sync.RWMutex
+}
+
+// NewSystemResourceCollector creates a new router health collector
+func NewSystemResourceCollector(client RouterClient, config *HealthConfig) *SystemResourceCollector {
+	logInfo("Health", "System resource collector initialized (interval: %v)", config.Interval)
+
+	return &SystemResourceCollector{
+		client: client,
+		config: config,
+	}
+}
+
+// Collect queries the router for resource and health values
+func (s *SystemResourceCollector) Collect(ctx context.Context, now time.Time) (*SystemResource, error) {
+	// Both queries are pipelined on the connection
+	commands := [][]string{{"/system/resource/print"}}
+	if !s.noHealth.Load() {
+		commands = append(commands, []string{"/system/health/print"})
+	}
+	results, errs := RunAll(ctx, s.client, commands...)
+	rows, err := results[0], errs[0]
+	if err != nil {
+		return nil, fmt.Errorf("system resource: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("system resource: empty response")
+	}
+
+	res := &SystemResource{
+		Timestamp:    now,
+		Temperatures: make(map[string]float64),
+		Voltages:     make(map[string]float64),
+	}
+	resource := rows[0]
+	res.CPULoad, _ = strconv.ParseFloat(resource["cpu-load"], 64)
+	res.FreeMemory, _ = strconv.ParseUint(resource["free-memory"], 10, 64)
+	res.TotalMemory, _ = strconv.ParseUint(resource["total-memory"], 10, 64)
+	res.Uptime = int64(parseRouterOSDuration(resource["uptime"]).Seconds())
+	res.Version = resource["version"]
+	res.BoardName = resource["board-name"]
+
+	// Health is not available on all hardware (e.g. CHR), so failures are non-fatal
+	if len(commands) > 1 {
+		healthRows, err := results[1], errs[1]
+		switch {
+		case errors.Is(err, routeros.ErrNoSuchCommand):
+			s.noHealth.Store(true)
+			logInfo("Health", "/system/health is not available on this router, only /system/resource is queried")
+		case err != nil:
+			logWarn("Health", "/system/health/print failed: %v", err)
+		default:
+			parseHealthRows(healthRows, res)
+		}
+	}
+
+	s.latestMu.Lock()
+	s.latest = res
+	s.latestMu.Unlock()
+
+	return res, nil
+}
+
+// Latest returns the most recent health snapshot (nil before the first collection)
+func (s *SystemResourceCollector) Latest() *SystemResource {
+	s.latestMu.RLock()
+	defer s.latestMu.RUnlock()
+	return s.latest
+}
+
+// parseHealthRows extracts temperatures and voltages from /system/health/print
+//
+// RouterOS v7 returns one row per sensor:  name=cpu-temperature value=45 type=C
+// RouterOS v6 returns a single row:        temperature=45 voltage=24.1 cpu-temperature=50
+func parseHealthRows(rows []map[string]string, res *SystemResource) {
+	for _, row := range rows {
+		if name, ok := row["name"]; ok {
+			value, err := strconv.ParseFloat(row["value"], 64)
+			if err != nil {
+				continue
+			}
+			switch row["type"] {
+			case "C":
+				res.Temperatures[name] = value
+			case "V":
+				res.Voltages[name] = value
+			}
+			continue
+		}
+
+		for key, raw := range row {
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				continue
+			}
+			switch {
+			case strings.HasSuffix(key, "temperature"):
+				res.Temperatures[key] = value
+			case strings.HasSuffix(key, "voltage"):
+				res.Voltages[key] = value
+			}
+		}
+	}
+}
+
+// parseRouterOSDuration parses RouterOS duration strings like "1w2d03:04:05" or "3h4m5s"
+func parseRouterOSDuration(value string) time.Duration {
+	var total time.Duration
+	var num int64
+	units := map[byte]time.Duration{
+		'w': 7 * 24 * time.Hour,
+		'd': 24 * time.Hour,
+		'h': time.Hour,
+		'm': time.Minute,
+		's': time.Second,
+	}
+
+	// Handle "hh:mm:ss" suffix used by RouterOS v6
+	if idx := strings.LastIndexAny(value, "wd"); strings.Contains(value, ":") {
+		clock := value[idx+1:]
+		value = value[:idx+1]
+		parts := strings.Split(clock, ":")
+		if len(parts) == 3 {
+			h, _ := strconv.Atoi(parts[0])
+			m, _ := strconv.Atoi(parts[1])
+			s, _ := strconv.Atoi(parts[2])
+			total += time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+		}
+	}
+
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		if ch >= '0' && ch <= '9' {
+			num = num*10 + int64(ch-'0')
+			continue
+		}
+		if unit, ok := units[ch]; ok {
+			total += time.Duration(num) * unit
+		}
+		num = 0
+	}
+
+	return total
+}
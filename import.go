@@ -0,0 +1,516 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// History Import (backfill from CSV or another exporter's series)
+// ============================================================================
+
+const (
+	// importBatchBytes is the payload size at which imported windows are pushed
+	importBatchBytes = 4 << 20
+	// importSourceLabel marks imported series, so their counters never meet the live ones
+	importSourceLabel = `source="import"`
+)
+
+// importRow is one reading of an interface: byte counters, or rates in bytes/s
+type importRow struct {
+	at     time.Time
+	name   string
+	rx, tx float64
+}
+
+// importSegment is a span of time with constant rates (bytes/s)
+type importSegment struct {
+	start, end time.Time
+	rx, tx     float64
+}
+
+// cmdImport backfills history into VictoriaMetrics
+//
+// The readings are turned into the window series the monitor pushes itself
+// (rates, peaks, coverage and byte counters) with their original timestamps,
+// so the dashboard, history API, usage reports and billing cover the months
+// before the switch. Imported series carry source="import"; their counters
+// start at zero and are summed with the live ones by the volume queries.
+func cmdImport(args []string) int {
+	fs, envFile := newFlagSet("import")
+	format := fs.String("format", "csv", "Input format: csv (time,interface,rx,tx) or prometheus (text exposition with timestamps)")
+	columns := fs.String("columns", "counters", "CSV rx/tx columns: counters (bytes since boot) or rates")
+	unit := fs.String("unit", "bytes", "Unit of CSV rates: bytes or bits (per second)")
+	rxMetric := fs.String("rx-metric", "ifHCInOctets", "Prometheus counter of received bytes")
+	txMetric := fs.String("tx-metric", "ifHCOutOctets", "Prometheus counter of sent bytes")
+	interfaceLabel := fs.String("interface-label", "ifName", "Prometheus label holding the interface name")
+	match := fs.String("match", "", "Only read Prometheus series with these labels (e.g. instance=10.0.0.1,job=snmp)")
+	maxGap := fs.Duration("max-gap", 15*time.Minute, "Readings further apart are a gap in the history instead of an average")
+	before := fs.String("before", "", "Drop readings at or after this time (Unix seconds or RFC3339), e.g. when the monitor started")
+	dryRun := fs.Bool("dry-run", false, "Read and convert the input, but push nothing")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s import [flags] FILE...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *format != "csv" && *format != "prometheus" {
+		fmt.Fprintf(os.Stderr, "Invalid -format: %s (must be csv or prometheus)\n", *format)
+		return 2
+	}
+	if *columns != "counters" && *columns != "rates" {
+		fmt.Fprintf(os.Stderr, "Invalid -columns: %s (must be counters or rates)\n", *columns)
+		return 2
+	}
+	if *unit != "bytes" && *unit != "bits" {
+		fmt.Fprintf(os.Stderr, "Invalid -unit: %s (must be bytes or bits)\n", *unit)
+		return 2
+	}
+	var cutoff time.Time
+	if *before != "" {
+		seconds, err := parseImportTime(*before)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -before: %v\n", err)
+			return 2
+		}
+		cutoff = seconds
+	}
+
+	config, err := LoadConfig(*envFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration invalid: %v\n", err)
+		return 1
+	}
+	if config.VictoriaMetrics == nil {
+		fmt.Fprintln(os.Stderr, "import requires VM_ENABLED=true (history is stored in VictoriaMetrics)")
+		return 2
+	}
+
+	var rows []importRow
+	for _, path := range fs.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open %s: %v\n", path, err)
+			return 1
+		}
+		var read []importRow
+		if *format == "csv" {
+			read, err = readImportCSV(file)
+		} else {
+			read, err = readImportPrometheus(file, *rxMetric, *txMetric, *interfaceLabel, parseKeyValuePairs(*match))
+		}
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "Read %d readings from %s\n", len(read), path)
+		rows = append(rows, read...)
+	}
+
+	scale := 1.0
+	if *columns == "rates" && *unit == "bits" {
+		scale = 1.0 / 8
+	}
+	segments := importSegments(rows, *columns == "rates", scale, *maxGap, cutoff)
+	if len(segments) == 0 {
+		fmt.Fprintln(os.Stderr, "No history to import (need at least two readings per interface)")
+		return 1
+	}
+
+	userConfig := newMemoryUserConfigManager(config.UplinkInterfaces)
+	userConfig.filePath = filepath.Join(defaultDataDir, userConfigFileName)
+	if err := userConfig.Load(); err != nil && !os.IsNotExist(err) {
+		logWarn("UserConfig", "Failed to load config: %v", err)
+	}
+
+	vmConfig := *config.VictoriaMetrics
+	vmConfig.SpoolDir = "" // Batches are pushed synchronously, a failed import is simply run again
+	vm := NewVMClient(&vmConfig, config.ExtraLabels)
+	defer vm.Close()
+	vm.metricPrefix = config.MetricPrefix
+	vm.rateScale = rateScale(config.MetricUnit)
+
+	importer := &historyImporter{
+		vm:         vm,
+		userConfig: userConfig,
+		sample:     config.PollInterval,
+		location:   config.Location,
+		dryRun:     *dryRun,
+	}
+	// Windows past the raw horizon would be trimmed; with retention they are written as rollups only
+	importer.tiers = []importTier{{size: config.VictoriaMetrics.Interval}}
+	if config.Retention != nil {
+		horizon := alignTime(time.Now().Add(-config.Retention.RawRetention), config.Retention.RollupStep)
+		importer.tiers = []importTier{
+			{size: config.VictoriaMetrics.Interval, from: horizon},
+			{size: config.Retention.RollupStep},
+		}
+	}
+
+	if err := importer.Import(segments); err != nil {
+		fmt.Fprintf(os.Stderr, "Import failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseImportTime parses Unix seconds or an RFC 3339 time
+func parseImportTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(seconds*1e9)), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// importCSVColumns are the accepted header names of each CSV column
+// rx-byte and tx-byte read the traffic traces of MIKROTIK_MOCK_TRACE.
+var importCSVColumns = map[string][]string{
+	"time":      {"time", "timestamp"},
+	"interface": {"interface", "name"},
+	"rx":        {"rx", "rx-byte", "rx_bytes"},
+	"tx":        {"tx", "tx-byte", "tx_bytes"},
+}
+
+// readImportCSV reads "time,interface,rx,tx" rows (header required, columns in any order)
+func readImportCSV(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	index := make(map[string]int)
+	for _, column := range []string{"time", "interface", "rx", "tx"} {
+		for i, field := range header {
+			for _, name := range importCSVColumns[column] {
+				if strings.EqualFold(strings.TrimSpace(field), name) {
+					index[column] = i
+				}
+			}
+		}
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("header has no %s column (expected time,interface,rx,tx)", column)
+		}
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		at, err := parseImportTime(record[index["time"]])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid time %q", line, record[index["time"]])
+		}
+		rx, err1 := strconv.ParseFloat(strings.TrimSpace(record[index["rx"]]), 64)
+		tx, err2 := strconv.ParseFloat(strings.TrimSpace(record[index["tx"]]), 64)
+		if err1 != nil || err2 != nil || rx < 0 || tx < 0 {
+			return nil, fmt.Errorf("line %d: invalid rx/tx values", line)
+		}
+		rows = append(rows, importRow{at: at, name: strings.TrimSpace(record[index["interface"]]), rx: rx, tx: tx})
+	}
+}
+
+// readImportPrometheus reads the RX/TX counters of text exposition lines with timestamps
+// (e.g. VictoriaMetrics' /api/v1/export/prometheus of an SNMP exporter). Other
+// metrics are skipped; readings missing either direction are dropped.
+func readImportPrometheus(r io.Reader, rxMetric, txMetric, interfaceLabel string, match map[string]string) ([]importRow, error) {
+	type reading struct {
+		rx, tx       float64
+		hasRx, hasTx bool
+	}
+	readings := make(map[string]map[int64]*reading) // Interface -> timestamp (ms)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if name := text[:strings.IndexAny(text+" ", "{ ")]; name != rxMetric && name != txMetric {
+			continue
+		}
+		sample, err := parsePromLine(text, time.Time{})
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if sample.timestamp == (time.Time{}).UnixMilli() {
+			return nil, fmt.Errorf("line %d: missing timestamp", line)
+		}
+
+		labels := make(map[string]string, len(sample.labels))
+		for _, label := range sample.labels {
+			labels[label[0]] = label[1]
+		}
+		matched := true
+		for key, value := range match {
+			matched = matched && labels[key] == value
+		}
+		name := labels[interfaceLabel]
+		if !matched || name == "" {
+			continue
+		}
+
+		if readings[name] == nil {
+			readings[name] = make(map[int64]*reading)
+		}
+		entry := readings[name][sample.timestamp]
+		if entry == nil {
+			entry = &reading{}
+			readings[name][sample.timestamp] = entry
+		}
+		if labels["__name__"] == rxMetric {
+			entry.rx, entry.hasRx = sample.value, true
+		} else {
+			entry.tx, entry.hasTx = sample.value, true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var rows []importRow
+	for name, byTime := range readings {
+		for ts, entry := range byTime {
+			if entry.hasRx && entry.hasTx {
+				rows = append(rows, importRow{at: time.UnixMilli(ts), name: name, rx: entry.rx, tx: entry.tx})
+			}
+		}
+	}
+	return rows, nil
+}
+
+// importSegments turns the readings of each interface into spans of constant rates
+// Counter readings give the average rate between two readings; a rate reading
+// covers the time since the previous one. Spans longer than maxGap, counters
+// going backwards (reboots) and readings at or after cutoff are left out.
+func importSegments(rows []importRow, rates bool, scale float64, maxGap time.Duration, cutoff time.Time) map[string][]importSegment {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].name != rows[j].name {
+			return rows[i].name < rows[j].name
+		}
+		return rows[i].at.Before(rows[j].at)
+	})
+
+	segments := make(map[string][]importSegment)
+	for i := 1; i < len(rows); i++ {
+		prev, row := rows[i-1], rows[i]
+		elapsed := row.at.Sub(prev.at)
+		if prev.name != row.name || elapsed <= 0 || elapsed > maxGap {
+			continue
+		}
+		if !cutoff.IsZero() && !row.at.Before(cutoff) {
+			continue
+		}
+		segment := importSegment{start: prev.at, end: row.at, rx: row.rx * scale, tx: row.tx * scale}
+		if !rates {
+			if row.rx < prev.rx || row.tx < prev.tx {
+				continue // Counter reset
+			}
+			segment.rx = (row.rx - prev.rx) / elapsed.Seconds()
+			segment.tx = (row.tx - prev.tx) / elapsed.Seconds()
+		}
+		segments[row.name] = append(segments[row.name], segment)
+	}
+	return segments
+}
+
+// importTier is one window size written by the importer
+type importTier struct {
+	size time.Duration
+	from time.Time // Only windows starting at or after this time (zero = all)
+}
+
+// importWindow accumulates the spans falling into one window of one interface
+type importWindow struct {
+	start            time.Time
+	covered          float64 // Seconds
+	rxSum, txSum     float64 // Rate x seconds
+	rxSumSq, txSumSq float64
+	rxPeak, txPeak   float64
+	rxMin, txMin     float64
+	rxBytes, txBytes float64 // Imported counters at the last covered moment
+}
+
+// historyImporter renders spans as window series and pushes them in batches
+type historyImporter struct {
+	vm         *VMClient
+	userConfig *UserConfigManager
+	sample     time.Duration  // POLL_INTERVAL: one sample per interval, as the monitor counts them
+	location   *time.Location // TIMEZONE: windows start on its boundaries, as the monitor's do
+	tiers      []importTier
+	dryRun     bool
+
+	batch   strings.Builder
+	windows int
+	pushed  int
+}
+
+// Import writes the windows of every interface's spans
+func (h *historyImporter) Import(segments map[string][]importSegment) error {
+	names := make([]string, 0, len(segments))
+	for name := range segments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		spans := segments[name]
+		var rxBytes, txBytes float64
+		for _, span := range spans {
+			rxBytes += span.rx * span.end.Sub(span.start).Seconds()
+			txBytes += span.tx * span.end.Sub(span.start).Seconds()
+		}
+		for _, tier := range h.tiers {
+			if err := h.writeTier(name, spans, tier); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s to %s, %s received, %s sent\n", name,
+			spans[0].start.Format(time.RFC3339), spans[len(spans)-1].end.Format(time.RFC3339),
+			formatByteCount(uint64(rxBytes)), formatByteCount(uint64(txBytes)))
+	}
+	if err := h.flush(); err != nil {
+		return err
+	}
+
+	if h.dryRun {
+		fmt.Fprintf(os.Stderr, "Dry run: %d windows of %d interfaces converted, nothing pushed\n", h.windows, len(names))
+	} else {
+		fmt.Fprintf(os.Stderr, "Imported %d windows of %d interfaces in %d requests\n", h.windows, len(names), h.pushed)
+	}
+	return nil
+}
+
+// writeTier splits the spans of one interface into windows of a tier
+func (h *historyImporter) writeTier(name string, spans []importSegment, tier importTier) error {
+	var current *importWindow
+	var rxBytes, txBytes float64
+	for _, span := range spans {
+		for t := span.start; t.Before(span.end); {
+			start := truncateIn(t, tier.size, h.location)
+			end := start.Add(tier.size)
+			if end.After(span.end) {
+				end = span.end
+			}
+			seconds := end.Sub(t).Seconds()
+			rxBytes += span.rx * seconds
+			txBytes += span.tx * seconds
+			t = end
+			if start.Before(tier.from) {
+				continue
+			}
+
+			if current != nil && !current.start.Equal(start) {
+				if err := h.add(name, current, tier.size); err != nil {
+					return err
+				}
+				current = nil
+			}
+			if current == nil {
+				current = &importWindow{start: start, rxMin: span.rx, txMin: span.tx}
+			}
+			current.covered += seconds
+			current.rxSum += span.rx * seconds
+			current.txSum += span.tx * seconds
+			current.rxSumSq += span.rx * span.rx * seconds
+			current.txSumSq += span.tx * span.tx * seconds
+			current.rxPeak, current.txPeak = max(current.rxPeak, span.rx), max(current.txPeak, span.tx)
+			current.rxMin, current.txMin = min(current.rxMin, span.rx), min(current.txMin, span.tx)
+			current.rxBytes, current.txBytes = rxBytes, txBytes
+		}
+	}
+	if current != nil {
+		return h.add(name, current, tier.size)
+	}
+	return nil
+}
+
+// add renders one window and pushes the batch once it is large enough
+// The rates inside a span are unknown, so peak and minimum are those of the
+// span averages; the sample count is the covered time in poll intervals.
+func (h *historyImporter) add(name string, w *importWindow, size time.Duration) error {
+	count := max(int(math.Round(w.covered/h.sample.Seconds())), 1)
+	perSample := float64(count) / w.covered
+	window := &AggregationWindow{
+		StartTime: w.start,
+		EndTime:   w.start.Add(size),
+		Interval:  size,
+		Expected:  int(size / h.sample),
+		Interfaces: map[string]*WindowStats{name: {
+			RxSum:   w.rxSum * perSample,
+			TxSum:   w.txSum * perSample,
+			RxSumSq: w.rxSumSq * perSample,
+			TxSumSq: w.txSumSq * perSample,
+			RxPeak:  w.rxPeak,
+			TxPeak:  w.txPeak,
+			RxMin:   w.rxMin,
+			TxMin:   w.txMin,
+			Count:   count,
+			RxBytes: uint64(math.Round(w.rxBytes)),
+			TxBytes: uint64(math.Round(w.txBytes)),
+		}},
+	}
+	h.batch.WriteString(windowMetrics(window, h.vm.rateScale, h.userConfig))
+	h.windows++
+	if h.batch.Len() >= importBatchBytes {
+		return h.flush()
+	}
+	return nil
+}
+
+// flush pushes the rendered windows, retrying like the live pushes
+func (h *historyImporter) flush() error {
+	if h.batch.Len() == 0 {
+		return nil
+	}
+	labels := importSourceLabel
+	if h.vm.extraLabels != "" {
+		labels = h.vm.extraLabels + "," + labels
+	}
+	metrics := injectLabels(renameMetrics(h.batch.String(), h.vm.metricPrefix), labels)
+	h.batch.Reset()
+	if h.dryRun {
+		return nil
+	}
+
+	var err error
+	for attempt := 0; attempt <= h.vm.config.RetryCount; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		if err = h.vm.sendToVM(metrics, time.Now()); err == nil {
+			h.pushed++
+			if h.pushed%10 == 0 {
+				fmt.Fprintf(os.Stderr, "Pushed %d requests (%d windows)\n", h.pushed, h.windows)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("push to VictoriaMetrics: %w", err)
+}
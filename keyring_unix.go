@@ -0,0 +1,40 @@
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keyringPassword reads a password from the OS keyring
+//
+// macOS:  security add-generic-password -s mikrotik-stats -a admin -w
+// Linux:  secret-tool store --label="Mikrotik" service mikrotik-stats username admin
+func keyringPassword(service, username string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-a", username, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", service, "username", username)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if msg := strings.TrimSpace(string(exitErr.Stderr)); msg != "" {
+				return "", fmt.Errorf("%s: %s", cmd.Args[0], msg)
+			}
+			return "", fmt.Errorf("no password stored")
+		}
+		return "", err
+	}
+
+	password := strings.TrimRight(string(out), "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("no password stored")
+	}
+	return password, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var (
+	advapi32     = syscall.NewLazyDLL("advapi32.dll")
+	procCredRead = advapi32.NewProc("CredReadW")
+	procCredFree = advapi32.NewProc("CredFree")
+)
+
+const credTypeGeneric = 1
+
+// credential mirrors the leading fields of CREDENTIALW
+type credential struct {
+	flags              uint32
+	credType           uint32
+	targetName         *uint16
+	comment            *uint16
+	lastWritten        syscall.Filetime
+	credentialBlobSize uint32
+	credentialBlob     *byte
+	persist            uint32
+	attributeCount     uint32
+	attributes         uintptr
+	targetAlias        *uint16
+	userName           *uint16
+}
+
+// keyringPassword reads a generic credential from Windows Credential Manager
+//
+//	cmdkey /generic:mikrotik-stats /user:admin /pass
+func keyringPassword(service, username string) (string, error) {
+	target, err := syscall.UTF16PtrFromString(service)
+	if err != nil {
+		return "", err
+	}
+
+	var cred *credential
+	if r, _, err := procCredRead.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred))); r == 0 {
+		return "", fmt.Errorf("CredRead %s: %w", service, err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	if cred.userName != nil {
+		if stored := utf16PtrToString(cred.userName); stored != "" && stored != username {
+			return "", fmt.Errorf("credential %s belongs to user %q", service, stored)
+		}
+	}
+	if cred.credentialBlobSize == 0 {
+		return "", fmt.Errorf("no password stored")
+	}
+
+	// cmdkey and the Credential Manager UI store the password as UTF-16LE
+	blob := unsafe.Slice(cred.credentialBlob, cred.credentialBlobSize)
+	chars := make([]uint16, len(blob)/2)
+	for i := range chars {
+		chars[i] = uint16(blob[2*i]) | uint16(blob[2*i+1])<<8
+	}
+	return string(utf16.Decode(chars)), nil
+}
+
+// utf16PtrToString converts a NUL-terminated UTF-16 string
+func utf16PtrToString(p *uint16) string {
+	var chars []uint16
+	for ptr := unsafe.Pointer(p); ; ptr = unsafe.Add(ptr, 2) {
+		c := *(*uint16)(ptr)
+		if c == 0 {
+			break
+		}
+		chars = append(chars, c)
+	}
+	return string(utf16.Decode(chars))
+}
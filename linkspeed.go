@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ============================================================================
+// Link Speed Collector (utilization = rate / link speed)
+// ============================================================================
+
+// LinkSpeedCollector tracks the negotiated link speed of the monitored interfaces
+//
+// Ethernet and SFP ports report their negotiated rate on /interface/ethernet/monitor;
+// the configured speed from /interface/ethernet/print is used while the link is
+// down or on routers that don't report a rate. Other interface types (VLANs,
+// PPPoE, groups) only have a speed when it is set in LINK_SPEEDS, e.g. the
+// contracted bandwidth of a WAN link.
+type LinkSpeedCollector struct {
+	client     RouterClient
+	config     *LinkSpeedConfig
+	interfaces []string
+	extra      func() []string // Other interfaces to query, e.g. VLAN trunks (nil = none)
+	query      bool            // False for SNMP (no ethernet menus); only LINK_SPEEDS apply
+
+	speeds   map[string]float64 // Interface -> bits/s
+	speedsMu sync.RWMutex
+}
+
+// NewLinkSpeedCollector creates a link speed collector with the configured overrides applied
+func NewLinkSpeedCollector(client RouterClient, config *LinkSpeedConfig, interfaces []string, transport string) *LinkSpeedCollector {
+	logInfo("LinkSpeed", "Link speed collector initialized (interval: %v, %d overrides)", config.Interval, len(config.Overrides))
+
+	l := &LinkSpeedCollector{
+		client:     client,
+		config:     config,
+		interfaces: interfaces,
+		query:      transport != "snmp",
+		speeds:     make(map[string]float64),
+	}
+	for name, value := range config.Overrides {
+		l.speeds[name], _ = parseRate(value)
+	}
+	return l
+}
+
+// SetInterfaces replaces the monitored interfaces (configuration reload); speeds refresh on the next poll
+func (l *LinkSpeedCollector) SetInterfaces(interfaces []string) {
+	l.speedsMu.Lock()
+	defer l.speedsMu.Unlock()
+	l.interfaces = interfaces
+}
+
+// Collect refreshes the speeds of the monitored ethernet interfaces
+func (l *LinkSpeedCollector) Collect(ctx context.Context) error {
+	if !l.query {
+		return nil
+	}
+
+	l.speedsMu.RLock()
+	wanted := toSet(l.interfaces)
+	l.speedsMu.RUnlock()
+	if l.extra != nil {
+		for _, name := range l.extra() {
+			wanted[name] = true
+		}
+	}
+	rows, err := l.client.Run(ctx, "/interface/ethernet/print", "=.proplist=name,speed")
+	if err != nil {
+		return fmt.Errorf("ethernet interfaces: %w", err)
+	}
+
+	speeds := make(map[string]float64)
+	var ports []string
+	for _, row := range rows {
+		name := row["name"]
+		if !wanted[name] {
+			continue
+		}
+		ports = append(ports, name)
+		if speed := parseLinkSpeed(row["speed"]); speed > 0 {
+			speeds[name] = speed
+		}
+	}
+
+	// Negotiated rate; "once" returns a single reading instead of streaming
+	if len(ports) > 0 {
+		rows, err := l.client.Run(ctx, "/interface/ethernet/monitor", "=numbers="+strings.Join(ports, ","), "=once=")
+		if err != nil {
+			logWarn("LinkSpeed", "/interface/ethernet/monitor failed, using configured speeds: %v", err)
+		}
+		for _, row := range rows {
+			if speed := parseLinkSpeed(row["rate"]); speed > 0 && row["status"] == "link-ok" {
+				speeds[row["name"]] = speed
+			}
+		}
+	}
+
+	// LINK_SPEEDS overrides whatever the router reports
+	for name, value := range l.config.Overrides {
+		speeds[name], _ = parseRate(value)
+	}
+
+	l.speedsMu.Lock()
+	for name, speed := range speeds {
+		if old := l.speeds[name]; old != speed {
+			logInfo("LinkSpeed", "%s: %s", name, formatLinkSpeed(speed))
+		}
+	}
+	l.speeds = speeds
+	l.speedsMu.Unlock()
+
+	return nil
+}
+
+// Speed returns the link speed of an interface in bits/s (0 if unknown)
+func (l *LinkSpeedCollector) Speed(name string) float64 {
+	l.speedsMu.RLock()
+	defer l.speedsMu.RUnlock()
+	return l.speeds[name]
+}
+
+// parseLinkSpeed converts a RouterOS rate or speed value to bits/s (0 if unknown)
+// Accepts "1Gbps", "100Mbps", "2.5Gbps" and v7 speed names such as "10G-baseSR-LR" or "1G-baseT-full".
+func parseLinkSpeed(value string) float64 {
+	if i := strings.Index(value, "-"); i > 0 {
+		value = value[:i]
+	}
+	speed, err := parseRate(value)
+	if err != nil {
+		return 0
+	}
+	return speed
+}
+
+// formatLinkSpeed renders a link speed for display ("1G", "100M", "2.5G")
+func formatLinkSpeed(bits float64) string {
+	switch {
+	case bits <= 0:
+		return "unknown"
+	case bits >= 1e9:
+		return fmt.Sprintf("%gG", bits/1e9)
+	case bits >= 1e6:
+		return fmt.Sprintf("%gM", bits/1e6)
+	default:
+		return fmt.Sprintf("%gk", bits/1e3)
+	}
+}
+
+// utilization returns rate (bytes/s) as a fraction of the link speed (bits/s), 0 if the speed is unknown
+func utilization(rate, linkSpeed float64) float64 {
+	if linkSpeed <= 0 {
+		return 0
+	}
+	return rate * 8 / linkSpeed
+}
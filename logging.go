@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// ============================================================================
+// Diagnostic Logging (log/slog)
+// ============================================================================
+//
+// All diagnostic messages go through slog with a level and a component field:
+//
+//   time=... level=WARN msg="Retry attempt 1/3" component=VM
+//   {"time":"...","level":"WARN","msg":"Retry attempt 1/3","component":"VM"}
+//
+// LOG_LEVEL selects the minimum level and LOG_FORMAT the handler (text or json).
+// Messages are written to stderr so they never mix with LOG_OUTPUT=stdout samples.
+
+// logLevel is the minimum level of the default logger (adjustable at runtime)
+var logLevel = new(slog.LevelVar)
+
+// serviceLogHandler replaces stderr when set (the event log of a Windows service)
+var serviceLogHandler func(level slog.Leveler) slog.Handler
+
+// setupLogging installs the default slog logger
+// The standard log package is redirected to it, so third-party output is captured too
+func setupLogging(level slog.Level, format string) {
+	logLevel.Set(level)
+	if serviceLogHandler != nil {
+		slog.SetDefault(slog.New(serviceLogHandler(logLevel)))
+		return
+	}
+	slog.SetDefault(slog.New(newLogHandler(diagnosticOutput(), format, logLevel)))
+}
+
+// diagnosticOutput is stderr, or stdout in a container where stdout is the collected log stream
+func diagnosticOutput() io.Writer {
+	if runningInContainer() {
+		return os.Stdout
+	}
+	return os.Stderr
+}
+
+// newLogHandler creates a text or JSON slog handler
+func newLogHandler(w io.Writer, format string, level slog.Leveler) slog.Handler {
+	options := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		return slog.NewJSONHandler(w, options)
+	}
+	return slog.NewTextHandler(w, options)
+}
+
+// parseLogLevel converts debug/info/warn/error to a slog level
+func parseLogLevel(value string) (slog.Level, error) {
+	switch strings.ToLower(value) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("invalid LOG_LEVEL: %s (must be 'debug', 'info', 'warn' or 'error')", value)
+	}
+}
+
+// logAt formats and logs a message for a component
+// Formatting is skipped entirely when the level is disabled
+func logAt(level slog.Level, component, format string, args ...any) {
+	logger := slog.Default()
+	if !logger.Enabled(context.Background(), level) {
+		return
+	}
+
+	msg := fmt.Sprintf(format, args...)
+	if component == "" {
+		logger.Log(context.Background(), level, msg)
+		return
+	}
+	logger.Log(context.Background(), level, msg, "component", component)
+}
+
+// logDebug logs verbose diagnostics (API words, queries)
+func logDebug(component, format string, args ...any) {
+	logAt(slog.LevelDebug, component, format, args...)
+}
+
+// logInfo logs normal operational messages
+func logInfo(component, format string, args ...any) {
+	logAt(slog.LevelInfo, component, format, args...)
+}
+
+// logWarn logs recoverable problems
+func logWarn(component, format string, args ...any) {
+	logAt(slog.LevelWarn, component, format, args...)
+}
+
+// logError logs failed operations
+func logError(component, format string, args ...any) {
+	logAt(slog.LevelError, component, format, args...)
+}
+
+// logFatal logs an error and exits
+func logFatal(component, format string, args ...any) {
+	logAt(slog.LevelError, component, format, args...)
+	os.Exit(1)
+}
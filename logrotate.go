@@ -0,0 +1,209 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Log File Rotation
+// ============================================================================
+
+// backupTimeFormat is embedded in rotated file names (sortable, no colons for Windows)
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotatingFile is an append-only log file that rotates when it exceeds maxSize
+//
+// Rotated files are renamed to <name>-<time><ext> (e.g. stats-2024-01-02T15-04-05.000.log),
+// optionally gzip-compressed, and pruned by count (maxBackups) and age (maxAge).
+// Compression and pruning run in the background so writes never wait for them.
+type rotatingFile struct {
+	path       string
+	maxSize    int64         // Rotate when the file would exceed this size (0 = never)
+	maxBackups int           // Rotated files to keep (0 = unlimited)
+	maxAge     time.Duration // Delete rotated files older than this (0 = keep)
+	compress   bool          // Gzip rotated files
+
+	file *os.File
+	size int64
+	mu   sync.Mutex
+
+	cleanupMu sync.Mutex // Serializes background compression/pruning
+}
+
+// openRotatingFile opens (or creates) the log file in append mode
+func openRotatingFile(config *LogConfig) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       config.File,
+		maxSize:    int64(config.MaxSizeMB) << 20,
+		maxBackups: config.MaxBackups,
+		maxAge:     time.Duration(config.MaxAgeDays) * 24 * time.Hour,
+		compress:   config.Compress,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	// Apply retention to backups left by previous runs
+	go r.cleanup()
+	return r, nil
+}
+
+// open opens the current log file and records its size
+func (r *rotatingFile) open() error {
+	if dir := filepath.Dir(r.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if the file would grow past maxSize
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			// Keep logging to the current file rather than losing records
+			logWarn("Log", "Failed to rotate %s: %v", r.path, err)
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file to a timestamped backup and starts a new one
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(r.path)
+	backup := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(r.path, ext), time.Now().Format(backupTimeFormat), ext)
+	renameErr := os.Rename(r.path, backup)
+
+	// Reopen even if the rename failed, so Write always has a file
+	if err := r.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+
+	go r.cleanup()
+	return nil
+}
+
+// Close closes the current file
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// cleanup compresses new backups and deletes backups beyond maxBackups or older than maxAge
+func (r *rotatingFile) cleanup() {
+	r.cleanupMu.Lock()
+	defer r.cleanupMu.Unlock()
+
+	backups := r.backups()
+
+	// Newest first: keep the first maxBackups within maxAge
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	cutoff := time.Now().Add(-r.maxAge)
+	for i, path := range backups {
+		expired := r.maxBackups > 0 && i >= r.maxBackups
+		if r.maxAge > 0 {
+			if info, err := os.Stat(path); err == nil && info.ModTime().Before(cutoff) {
+				expired = true
+			}
+		}
+
+		if expired {
+			if err := os.Remove(path); err != nil {
+				logWarn("Log", "Failed to remove old log %s: %v", path, err)
+			}
+			continue
+		}
+
+		if r.compress && !strings.HasSuffix(path, ".gz") {
+			if err := gzipFile(path); err != nil {
+				logWarn("Log", "Failed to compress %s: %v", path, err)
+			}
+		}
+	}
+}
+
+// backups lists rotated files (compressed or not) belonging to this log
+func (r *rotatingFile) backups() []string {
+	ext := filepath.Ext(r.path)
+	prefix := strings.TrimSuffix(r.path, ext) + "-"
+
+	matches, _ := filepath.Glob(prefix + "*" + ext + "*")
+	backups := matches[:0]
+	for _, path := range matches {
+		stamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(path, prefix), ".gz"), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, path)
+		}
+	}
+	return backups
+}
+
+// gzipFile compresses path to path.gz and removes the original
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		zw.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	src.Close()
+	return os.Remove(path)
+}
@@ -2,8 +2,9 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"os"
 	"strings"
+	"time"
 )
 
 const (
@@ -17,44 +18,33 @@ const (
 func main() {
 	// Enable ANSI escape sequences on Windows for color/cursor control
 	if err := enableANSI(); err != nil {
-		log.Printf("Warning: Failed to enable ANSI support: %v", err)
+		logWarn("", "Failed to enable ANSI support: %v", err)
 	}
 
-	// Load configuration from .env file and environment variables
-	config, err := LoadConfig()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
-	}
-
-	// Print startup information
-	printStartupInfo(config)
-
-	// Establish connection to Mikrotik router via API
-	client, err := NewMikrotikClient(config)
-	if err != nil {
-		log.Fatalf("Failed to connect to Mikrotik: %v", err)
-	}
-	defer client.Close()
-
-	log.Printf("Connected to Mikrotik at %s:%s", config.Host, config.Port)
-
-	// Create and start monitoring loop
-	monitor := NewMonitor(client, config)
-	if err := monitor.Start(); err != nil {
-		log.Fatalf("Monitor error: %v", err)
-	}
+	os.Exit(runCommand(os.Args[1:]))
 }
 
 // printStartupInfo prints application startup information
 func printStartupInfo(config *Config) {
-	log.Println("========================================")
-	log.Printf("Mikrotik Interface Traffic Monitor %s", Version)
-	log.Println("========================================")
-	log.Printf("Monitoring %d interface(s): %s", len(config.Interfaces), strings.Join(config.Interfaces, ", "))
+	logInfo("", "========================================")
+	logInfo("", "Mikrotik Interface Traffic Monitor %s", Version)
+	logInfo("", "========================================")
+	logInfo("", "Monitoring %d interface(s): %s", len(config.Interfaces), strings.Join(config.Interfaces, ", "))
+	for _, group := range config.Groups {
+		logInfo("", "Interface group %s = %s", group.Name, strings.Join(group.Members, " + "))
+	}
+	if len(config.ExtraLabels) > 0 {
+		logInfo("", "Extra labels: %s", formatExtraLabels(config.ExtraLabels))
+	}
+	logInfo("", "Timezone: %s (%s)", config.Location, time.Now().In(config.Location).Format("MST, UTC-07:00"))
 
 	// Print enabled features
 	var features []string
 
+	if config.Mock != nil {
+		features = append(features, "Simulated router (MIKROTIK_MOCK)")
+	}
+
 	if config.Terminal != nil {
 		features = append(features, fmt.Sprintf("Terminal (%s mode)", config.Terminal.Mode))
 	}
@@ -78,22 +68,104 @@ func printStartupInfo(config *Config) {
 			strings.Join(webFeatures, "+"), config.Web.ListenAddr))
 	}
 
+	if config.ProbeListenAddr != "" {
+		features = append(features, fmt.Sprintf("Health probes (%s)", config.ProbeListenAddr))
+	}
+
 	if config.VictoriaMetrics != nil {
 		features = append(features, fmt.Sprintf("VictoriaMetrics (%v interval)", config.VictoriaMetrics.Interval))
 	}
 
+	if config.Retention != nil {
+		features = append(features, fmt.Sprintf("Retention (%v rollups, raw data kept %v)", config.Retention.RollupStep, config.Retention.RawRetention))
+	}
+
+	if config.OTLP != nil {
+		features = append(features, fmt.Sprintf("OpenTelemetry (%s, %v interval)", config.OTLP.Endpoint, config.OTLP.Interval))
+	}
+
+	if config.Pushgateway != nil {
+		features = append(features, fmt.Sprintf("Pushgateway (%s mode, %s, %v interval)", config.Pushgateway.Mode, config.Pushgateway.URL, config.Pushgateway.Interval))
+	}
+
+	if config.Stream != nil {
+		features = append(features, fmt.Sprintf("Stream (%s %s, %s)", config.Stream.Transport, config.Stream.Topic, config.Stream.Format))
+	}
+
+	if config.RRD != nil {
+		features = append(features, fmt.Sprintf("RRD (%s, %s, %v step)", config.RRD.Format, config.RRD.Dir, config.RRD.Interval))
+	}
+	if config.Zabbix != nil {
+		features = append(features, fmt.Sprintf("Zabbix (%s, %v interval)", config.Zabbix.Server, config.Zabbix.Interval))
+	}
+
+	if config.Sessions != nil {
+		var sources []string
+		if config.Sessions.PPP {
+			sources = append(sources, "ppp")
+		}
+		if config.Sessions.Hotspot {
+			sources = append(sources, "hotspot")
+		}
+		features = append(features, fmt.Sprintf("Sessions (%s every %v)", strings.Join(sources, "+"), config.Sessions.Interval))
+	}
+
+	if config.Ping != nil {
+		features = append(features, fmt.Sprintf("Ping (%s every %v)", strings.Join(config.Ping.Targets, ","), config.Ping.Interval))
+	}
+
+	if config.CAPsMAN != nil {
+		features = append(features, fmt.Sprintf("CAPsMAN (every %v)", config.CAPsMAN.Interval))
+	}
+
+	if config.Netwatch != nil {
+		features = append(features, fmt.Sprintf("Netwatch (every %v)", config.Netwatch.Interval))
+	}
+	if config.Audit != nil {
+		features = append(features, fmt.Sprintf("Session audit (every %v)", config.Audit.Interval))
+	}
+	if config.Shaper != nil {
+		mode := "enforcing"
+		if config.Shaper.DryRun {
+			mode = "dry run"
+		}
+		features = append(features, fmt.Sprintf("Traffic shaper (%s, every %v)", mode, config.Shaper.Interval))
+	}
+	if config.Schedule != nil {
+		mode := "enforcing"
+		if config.Schedule.DryRun {
+			mode = "dry run"
+		}
+		features = append(features, fmt.Sprintf("Interface schedule (%s, every %v)", mode, config.Schedule.Interval))
+	}
+	if config.Billing != nil {
+		features = append(features, fmt.Sprintf("Billing (p%g, %d customers)", config.Billing.Percentile, len(parseInterfaceGroups(config.Billing.Customers))))
+	}
+	if config.Topology != nil {
+		features = append(features, fmt.Sprintf("Topology (site %s, router %s)", config.Topology.Site, config.Topology.Router))
+	}
+
+	if config.Health != nil {
+		features = append(features, fmt.Sprintf("Health (every %v)", config.Health.Interval))
+	}
+
+	if config.Percentile != nil {
+		features = append(features, fmt.Sprintf("Percentile (p%g of %v samples per %s)",
+			config.Percentile.Percentile, config.Percentile.SampleInterval, config.Percentile.Window))
+	}
+
 	if len(features) == 0 {
-		log.Println("Enabled Features: None (running in silent mode)")
-		log.Println("")
-		log.Println("💡 Tip: Enable features via environment variables:")
-		log.Println("  - TERMINAL_ENABLED=true  (interactive display)")
-		log.Println("  - LOG_ENABLED=true       (structured logging)")
-		log.Println("  - WEB_ENABLED=true       (web interface)")
-		log.Println("  - VM_ENABLED=true        (metrics storage)")
-		log.Println("")
+		logInfo("", "Enabled Features: None (running in silent mode)")
+		logInfo("", "")
+		logInfo("", "💡 Tip: Enable features via environment variables:")
+		logInfo("", "  - TERMINAL_ENABLED=true  (interactive display)")
+		logInfo("", "  - LOG_ENABLED=true       (structured logging)")
+		logInfo("", "  - WEB_ENABLED=true       (web interface)")
+		logInfo("", "  - VM_ENABLED=true        (metrics storage)")
+		logInfo("", "")
 	} else {
-		log.Printf("Enabled Features: %s", strings.Join(features, ", "))
+		logInfo("", "Enabled Features: %s", strings.Join(features, ", "))
 	}
 
-	log.Println("========================================")
+	logInfo("", "========================================")
 }
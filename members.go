@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ============================================================================
+// Member Port Discovery (bridges and bonds)
+// ============================================================================
+
+// MemberDiscovery finds the member ports of the monitored bridges and bonds
+//
+// Bridge ports come from /interface/bridge/port (disabled ports are skipped),
+// bond slaves from /interface/bonding. The members are sampled like the
+// configured interfaces and shown under their parent in the web UI, with the
+// sum of their rates next to the parent's own so the two can be compared: a
+// bridge counts the traffic it routes, not what its ports switch among
+// themselves. Members are rediscovered every INTERFACE_MEMBERS_INTERVAL.
+type MemberDiscovery struct {
+	client  RouterClient
+	config  *MembersConfig
+	parents []string // Monitored interfaces
+
+	members map[string][]string // Parent -> member ports (sorted)
+	changed bool                // Members differ from the last Take
+	mu      sync.Mutex
+}
+
+// NewMemberDiscovery creates the member port discovery for the monitored interfaces
+func NewMemberDiscovery(client RouterClient, config *MembersConfig, interfaces []string) *MemberDiscovery {
+	logInfo("Members", "Bridge/bond member discovery initialized (interval: %v)", config.Interval)
+	return &MemberDiscovery{
+		client:  client,
+		config:  config,
+		parents: interfaces,
+		members: make(map[string][]string),
+	}
+}
+
+// SetInterfaces replaces the monitored interfaces (configuration reload)
+// Members of interfaces no longer monitored are dropped right away; new ones are found on the next poll.
+func (d *MemberDiscovery) SetInterfaces(interfaces []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.parents = interfaces
+	keep := toSet(interfaces)
+	for parent := range d.members {
+		if !keep[parent] {
+			delete(d.members, parent)
+			d.changed = true
+		}
+	}
+}
+
+// Collect reads the bridge ports and bond slaves of the monitored interfaces
+func (d *MemberDiscovery) Collect(ctx context.Context) error {
+	d.mu.Lock()
+	wanted := toSet(d.parents)
+	d.mu.Unlock()
+
+	members := make(map[string][]string)
+	rows, err := d.client.Run(ctx, "/interface/bridge/port/print", "=.proplist=interface,bridge,disabled")
+	if err != nil {
+		return fmt.Errorf("bridge ports: %w", err)
+	}
+	for _, row := range rows {
+		if wanted[row["bridge"]] && row["disabled"] != "true" && row["interface"] != "" {
+			members[row["bridge"]] = append(members[row["bridge"]], row["interface"])
+		}
+	}
+
+	rows, err = d.client.Run(ctx, "/interface/bonding/print", "=.proplist=name,slaves")
+	if err != nil {
+		return fmt.Errorf("bonding interfaces: %w", err)
+	}
+	for _, row := range rows {
+		if !wanted[row["name"]] {
+			continue
+		}
+		for _, slave := range strings.Split(row["slaves"], ",") {
+			if slave = strings.TrimSpace(slave); slave != "" {
+				members[row["name"]] = append(members[row["name"]], slave)
+			}
+		}
+	}
+	for _, ports := range members {
+		sort.Strings(ports)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if reflect.DeepEqual(members, d.members) {
+		return nil
+	}
+	for parent, ports := range members {
+		if !reflect.DeepEqual(ports, d.members[parent]) {
+			logInfo("Members", "%s: %s", parent, strings.Join(ports, ", "))
+		}
+	}
+	d.members = members
+	d.changed = true
+	return nil
+}
+
+// Take returns the members by parent if they changed since the last call (sampling loop)
+func (d *MemberDiscovery) Take() (map[string][]string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.changed {
+		return nil, false
+	}
+	d.changed = false
+	members := make(map[string][]string, len(d.members))
+	for parent, ports := range d.members {
+		members[parent] = append([]string(nil), ports...)
+	}
+	return members, true
+}
+
+// memberParents maps each member port to its parent
+func memberParents(members map[string][]string) map[string]string {
+	parents := make(map[string]string)
+	for parent, ports := range members {
+		for _, port := range ports {
+			parents[port] = parent
+		}
+	}
+	return parents
+}
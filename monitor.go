@@ -1,68 +1,331 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
 	"time"
+
+	"github.com/firadio/golang-mikrotik-interface-stats/pkg/routeros"
 )
 
 // Monitor handles traffic monitoring and rate calculation
 type Monitor struct {
-	client           *MikrotikClient           // Mikrotik API client
-	rateMap          map[string]*InterfaceRate // Interface rate tracking state
-	interval         time.Duration             // Monitoring interval (1 second)
-	interfaces       []string                  // List of interfaces to monitor
-	uplinkInterfaces map[string]bool           // Uplink interface set
-	debug            bool                      // Enable debug logging
-	statsWindowSize  int                       // Statistics window size in seconds
+	client          RouterClient              // Mikrotik API client (binary API or REST)
+	rateMap         map[string]*InterfaceRate // Interface rate tracking state
+	interval        time.Duration             // Monitoring interval (POLL_INTERVAL, default 1 second)
+	align           bool                      // Sample on wall-clock multiples of interval (POLL_ALIGN)
+	location        *time.Location            // TIMEZONE: zone of output timestamps and boundaries
+	backoff         *AdaptiveInterval         // Interval stretched while queries are slow (nil if POLL_ADAPTIVE=false)
+	queryDuration   time.Duration             // Round trip of the last successful interface query
+	lastSample      time.Time                 // Start of the last sample (monotonic)
+	memberPorts     []string                  // Discovered member ports sampled besides interfaces
+	clock           clockWatch                // Wall-clock steps between samples
+	interfaces      []string                  // List of interfaces to monitor
+	groups          []InterfaceGroup          // Virtual interfaces (summed members)
+	userConfig      *UserConfigManager        // Labels and uplink classification (shared with outputs)
+	statsWindowSize int                       // Statistics window size in samples
+	sparklines      bool                      // Attach rate history to RateInfo (terminal sparklines)
+
+	// Sampling outcome for health probes and internal metrics
+	status    *MonitorStatus
+	probes    *ProbeServer // Separate /healthz and /readyz listener (nil if PROBE_LISTEN_ADDR is unset)
+	telemetry *Telemetry
+	notifier  *systemdNotifier // sd_notify readiness/watchdog (nil if not under systemd)
+
+	// Registered outputs (terminal, log, web, VM, OTLP) receive every sample
+	outputs *OutputManager
 
 	// Optional output components (nil if disabled)
-	terminalWriter *TerminalOutput     // Terminal output
-	logWriter      *StructuredLogger   // Structured log output
-	webServer      *WebServer          // Web server
-	vmClient       *VMClient           // VictoriaMetrics client
+	terminalWriter *TerminalOutput       // Terminal output
+	logWriter      *StructuredLogger     // Structured log output
+	webServer      *WebServer            // Web server
+	vmClient       *VMClient             // VictoriaMetrics client
 	aggregator     *TimeWindowAggregator // Time window aggregator
+	percentile     *PercentileTracker    // 95th percentile tracker
+
+	// Optional collectors (nil if disabled)
+	sessionCollector *SessionCollector        // PPP/hotspot session stats
+	healthCollector  *SystemResourceCollector // Router CPU/memory/temperature
+	ping             *PingCollector           // Latency probes (nil if disabled)
+	capsman          *CAPsMANCollector        // Per-AP/SSID WiFi traffic (nil if disabled)
+	netwatch         *NetwatchCollector       // Router netwatch states (nil if disabled)
+	audit            *AuditCollector          // Management sessions and login failures (nil if disabled)
+	linkSpeeds       *LinkSpeedCollector      // Link speeds for utilization
+	members          *MemberDiscovery         // Bridge/bond member ports (nil if disabled)
+	trunks           *TrunkTracker            // VLAN trunk roll-ups (nil if disabled)
+	events           *InterfaceEventTracker   // Up/down/flap detection (nil if disabled)
+	topTalkers       *TopTalkersCollector     // Torch top talkers (nil if disabled)
+	flows            *FlowCollector           // NetFlow/IPFIX receiver (nil if disabled)
+	clients          *ClientCollector         // DHCP/ARP client names (nil if disabled)
+	alerts           *AlertEngine             // Threshold/zero/anomaly webhooks (nil if disabled)
+	notifiers        *Notifiers               // Telegram/Slack/Discord (nil if none configured)
+	summary          *TrafficSummary          // Daily chat summary (nil if disabled)
+	topology         *TopologyTracker         // Site/router WAN roll-ups (nil if disabled)
+	shaper           *ShaperController        // Queue limits from traffic quotas (nil if disabled)
+	schedule         *InterfaceScheduler      // Interface enable/disable windows (nil if disabled)
+	reports          *ReportGenerator         // Scheduled usage reports (nil if disabled)
+	billing          *BillingGenerator        // Per-customer billing reports (nil if disabled)
+	retention        *RetentionManager        // History rollups and retention (nil if disabled)
+
+	// Counters and accumulators persisted across restarts (nil if disabled)
+	state  *StateStore
+	resume *monitorState // Loaded state, applied with the first sample
+
+	config   *Config      // Running configuration (updated by reloads)
+	loaded   *Config      // Configuration as loaded from the environment (before admin overrides)
+	configMu sync.RWMutex // Guards config and loaded for the admin API
+	reloads  chan *Config // Reloaded configurations waiting for the monitoring loop
 }
 
 // NewMonitor creates a new traffic monitor with appropriate output handlers
-func NewMonitor(client *MikrotikClient, config *Config) *Monitor {
+func NewMonitor(client RouterClient, config *Config, telemetry *Telemetry) *Monitor {
+	// Initialize user configuration (labels, uplinks) shared by all outputs
+	userConfig, err := NewUserConfigManager(config.UplinkInterfaces)
+	if err != nil {
+		logWarn("UserConfig", "%v (settings will not be persisted)", err)
+		userConfig = newMemoryUserConfigManager(config.UplinkInterfaces)
+	}
+
+	// Settings changed through the admin API take precedence over the env file
+	loaded := config
+	overrides := userConfig.ConfigOverrides()
+	config = overrides.Apply(loaded)
+
 	m := &Monitor{
-		client:           client,
-		rateMap:          make(map[string]*InterfaceRate),
-		interval:         1 * time.Second,
-		interfaces:       config.Interfaces,
-		uplinkInterfaces: toSet(config.UplinkInterfaces),
-		debug:            config.Debug,
-		statsWindowSize:  config.StatsWindowSize,
+		client:          client,
+		rateMap:         make(map[string]*InterfaceRate),
+		interval:        config.PollInterval,
+		align:           config.PollAlign,
+		location:        config.Location,
+		backoff:         newPollBackoff(config),
+		interfaces:      config.Interfaces,
+		groups:          config.Groups,
+		userConfig:      userConfig,
+		statsWindowSize: config.StatsWindowSize,
+		status:          NewMonitorStatus(),
+		telemetry:       telemetry,
+		notifier:        newSystemdNotifier(),
+		config:          config,
+		loaded:          loaded,
+		reloads:         make(chan *Config, 1),
+	}
+
+	// Initialize percentile tracker if enabled (BEFORE terminal and web server, which display it)
+	if config.Percentile != nil {
+		m.percentile = NewPercentileTracker(config.Percentile, config.Location)
 	}
 
 	// Initialize terminal output if enabled
 	if config.Terminal != nil {
-		refreshMode := config.Terminal.Mode == "refresh"
-		m.terminalWriter = NewTerminalOutput(
-			refreshMode,
-			config.Terminal.RateUnit,
-			config.Terminal.RateScale,
-			config.UplinkInterfaces,
-			config.StatsWindowSize,
-		)
+		m.terminalWriter = NewTerminalOutput(config.Terminal, config.Groups, m.userConfig, m.percentile, config.StatsWindowSize)
+		// History is attached in refresh mode so sparklines can be toggled with 'g'
+		m.sparklines = config.Terminal.Mode == "refresh"
 	}
 
 	// Initialize log output if enabled
 	if config.Log != nil {
-		m.logWriter = NewStructuredLogger(config.Log, config.UplinkInterfaces)
+		m.logWriter = NewStructuredLogger(config.Log, m.userConfig, config.ExtraLabels)
 	}
 
 	// Initialize VictoriaMetrics if enabled (BEFORE web server to ensure vmClient is available)
 	if config.VictoriaMetrics != nil {
-		m.vmClient = NewVMClient(config.VictoriaMetrics)
-		m.aggregator = NewTimeWindowAggregator(config.VictoriaMetrics.Interval)
+		m.vmClient = NewVMClient(config.VictoriaMetrics, config.ExtraLabels)
+		m.vmClient.userConfig = m.userConfig
+		m.vmClient.metricPrefix = config.MetricPrefix
+		m.vmClient.rateScale = rateScale(config.MetricUnit)
+		m.aggregator = NewTimeWindowAggregator(config.VictoriaMetrics.Interval, config.PollInterval)
+		m.aggregator.histogramBuckets, _ = parseHistogramBuckets(config.VictoriaMetrics.HistogramBuckets) // Validated with the config
+		if config.Retention != nil {
+			m.retention = NewRetentionManager(config.Retention, m.vmClient)
+		}
+	}
+
+	// Initialize session collector if enabled (BEFORE web server to expose /api/sessions)
+	if config.Sessions != nil {
+		m.sessionCollector = NewSessionCollector(client, config.Sessions)
+	}
+
+	// Initialize health collector if enabled (BEFORE web server to expose /api/system)
+	if config.Health != nil {
+		m.healthCollector = NewSystemResourceCollector(client, config.Health)
+	}
+
+	// Initialize latency probes if enabled (BEFORE web server to expose /api/ping)
+	if config.Ping != nil {
+		m.ping = NewPingCollector(client, config.Ping)
+	}
+
+	// Initialize CAPsMAN collector if enabled (BEFORE web server to expose /api/capsman)
+	if config.CAPsMAN != nil {
+		m.capsman = NewCAPsMANCollector(client, config.CAPsMAN)
+	}
+
+	// Initialize netwatch collector if enabled (BEFORE web server to expose /api/netwatch)
+	if config.Netwatch != nil {
+		m.netwatch = NewNetwatchCollector(client, config.Netwatch)
+	}
+
+	// Initialize management session audit if enabled (BEFORE web server to expose /api/audit)
+	if config.Audit != nil {
+		m.audit = NewAuditCollector(client, config.Audit)
+	}
+
+	// Initialize link speed collector if enabled
+	if config.LinkSpeed != nil {
+		m.linkSpeeds = NewLinkSpeedCollector(client, config.LinkSpeed, config.Interfaces, config.Transport)
+	}
+
+	// Initialize member port discovery if enabled
+	if config.Members != nil {
+		m.members = NewMemberDiscovery(client, config.Members, config.Interfaces)
+	}
+
+	// Initialize VLAN trunk tracking if enabled (AFTER link speeds, trunk ports are added to their query)
+	if config.Trunks != nil {
+		var speed func(string) float64
+		if m.linkSpeeds != nil {
+			speed = m.linkSpeeds.Speed
+		}
+		m.trunks = NewTrunkTracker(client, config.Trunks, speed)
+		if m.linkSpeeds != nil {
+			m.linkSpeeds.extra = m.trunks.Trunks
+		}
+	}
+
+	// Initialize top talkers collector if enabled (BEFORE web server to expose /api/toptalkers)
+	if config.TopTalkers != nil {
+		m.topTalkers = NewTopTalkersCollector(client, config.TopTalkers)
+	}
+
+	// Initialize client directory if enabled (BEFORE web server to expose /api/clients)
+	if config.Clients != nil {
+		m.clients = NewClientCollector(client, config.Clients)
+	}
+
+	// Initialize flow receiver if enabled (BEFORE web server to expose /api/flows)
+	if config.Flows != nil {
+		m.flows = NewFlowCollector(config.Flows)
+	}
+
+	// Initialize interface state events if enabled
+	if config.Events != nil {
+		m.events = NewInterfaceEventTracker(m.userConfig.CustomLabel)
+		if config.Events.WebhookURL != "" {
+			m.events.OnEvent(newEventWebhook(config.Events).Send)
+		}
+	}
+
+	// Initialize chat notifiers if any service is configured
+	if config.Notify != nil {
+		m.notifiers = NewNotifiers(config.Notify)
+		if config.Notify.SummaryEnabled {
+			m.summary = NewTrafficSummary(config.Notify, config.Location, m.notifiers, m.userConfig.IsUplink, m.userConfig.CustomLabel)
+		}
+	}
+
+	// Initialize scheduled reports if enabled (AFTER VictoriaMetrics, they read the stored history)
+	if config.Reports != nil {
+		m.reports = NewReportGenerator(config.Reports, config.Location, m.vmClient, m.notifiers, m.userConfig.IsUplink, m.userConfig.CustomLabel)
+	}
+
+	// Initialize billing if enabled (AFTER VictoriaMetrics, bills are built from the stored history)
+	if config.Billing != nil {
+		m.billing = NewBillingGenerator(config.Billing, config.Location, m.vmClient, m.userConfig.IsUplink)
+	}
+
+	// Initialize rate alerts if enabled
+	if config.Alerts != nil {
+		m.alerts = NewAlertEngine(config.Alerts, m.notifiers, m.userConfig.IsUplink, m.userConfig.CustomLabel)
+	}
+
+	// Initialize topology roll-ups if enabled (AFTER VictoriaMetrics, the site view reads the other routers)
+	if config.Topology != nil {
+		m.topology = NewTopologyTracker(config.Topology, m.userConfig.GetUplinkInterfaces, m.vmClient)
+	}
+
+	// Initialize the traffic shaper if enabled (BEFORE web server to expose /api/shaper)
+	if config.Shaper != nil {
+		m.shaper = NewShaperController(client, config.Shaper, config.Location)
+	}
+
+	// Initialize the interface schedule if enabled (BEFORE web server to expose /api/schedule)
+	if config.Schedule != nil {
+		m.schedule = NewInterfaceScheduler(client, config.Schedule, config.Location)
+	}
+
+	// Load the saved state if enabled (AFTER the summary and percentile tracker it restores)
+	if config.State != nil {
+		m.state = NewStateStore(config.State)
+		m.resume = m.state.Load(time.Now())
 	}
 
 	// Initialize web server if enabled (AFTER VictoriaMetrics to get vmClient)
 	if config.Web != nil {
-		m.webServer = NewWebServer(config.Web, config.UplinkInterfaces, m.vmClient)
+		m.webServer = NewWebServer(config, client, m.userConfig, m.vmClient, m.sessionCollector, m.healthCollector, m.percentile, m.status, m.telemetry)
+		m.webServer.topTalkers = m.topTalkers
+		m.webServer.flows = m.flows
+		m.webServer.hosts = m.clients
+		m.webServer.ping = m.ping
+		m.webServer.capsman = m.capsman
+		m.webServer.netwatch = m.netwatch
+		m.webServer.audit = m.audit
+		m.webServer.topology = m.topology
+		m.webServer.trunks = m.trunks
+		m.webServer.shaper = m.shaper
+		m.webServer.schedule = m.schedule
+		m.webServer.billing = m.billing
+		if config.Web.AdminEnabled {
+			m.webServer.admin = m
+		}
+		if m.events != nil {
+			m.webServer.events = m.events
+			m.events.OnEvent(m.webServer.BroadcastEvent)
+		}
+	}
+
+	if config.ProbeListenAddr != "" {
+		m.probes = NewProbeServer(config.ProbeListenAddr, m.status, m.vmClient)
 	}
 
+	// Self-telemetry reports VM delivery and WebSocket state when enabled
+	m.telemetry.vm = m.vmClient
+	m.telemetry.web = m.webServer
+
+	// Register outputs in display order
+	m.outputs = NewOutputManager(config.OutputTimeout)
+	if m.terminalWriter != nil {
+		m.outputs.Register("terminal", m.terminalWriter)
+	}
+	if m.logWriter != nil {
+		m.outputs.Register("log", m.logWriter)
+	}
+	if m.webServer != nil {
+		m.outputs.Register("web", m.webServer)
+	}
+	if m.vmClient != nil {
+		m.outputs.Register("victoriametrics", NewVMOutput(m.vmClient, m.aggregator))
+	}
+	if config.OTLP != nil {
+		m.outputs.Register("otlp", NewOTLPOutput(config.OTLP, config.PollInterval, config.Host, config.ExtraLabels))
+	}
+	if config.Pushgateway != nil {
+		m.outputs.Register("pushgateway", NewPushgatewayOutput(config.Pushgateway, config.PollInterval, config.Host, config.ExtraLabels,
+			m.userConfig, config.MetricPrefix, rateScale(config.MetricUnit)))
+	}
+	if config.Stream != nil {
+		m.outputs.Register("stream", NewStreamOutput(config.Stream, config.Host, config.ExtraLabels, m.userConfig))
+	}
+	if config.RRD != nil {
+		m.outputs.Register("rrd", NewRRDOutput(config.RRD, config.PollInterval))
+	}
+	if config.Zabbix != nil {
+		m.outputs.Register("zabbix", NewZabbixOutput(config.Zabbix, config.PollInterval, config.Host, m.userConfig, rateScale(config.MetricUnit)))
+	}
+	m.outputs.SetPaused(config.DisabledOutputs)
+
 	return m
 }
 
@@ -76,51 +339,232 @@ func toSet(list []string) map[string]bool {
 }
 
 // Start begins the monitoring loop
-// Queries interfaces every second and calculates rates
-func (m *Monitor) Start() error {
-	// Use ticker for precise 1-second intervals
+// Queries interfaces every POLL_INTERVAL and calculates rates until ctx is cancelled
+func (m *Monitor) Start(ctx context.Context) error {
+	// Use ticker for precise intervals
 	ticker := time.NewTicker(m.interval)
 	defer ticker.Stop()
 
+	// Probes answer from the start; liveness allows time for the first sample
+	if m.probes != nil {
+		m.probes.Start()
+		defer m.probes.Stop()
+	}
+
 	// Initialize rate tracking with first stats
-	if err := m.initializeRates(); err != nil {
-		log.Printf("Warning: Failed to get initial stats: %v", err)
+	if err := m.initializeRates(ctx); err != nil {
+		logWarn("Monitor", "Failed to get initial stats: %v", err)
 	}
 
-	// Start web server if enabled
+	// Start web server if enabled (stopped by outputs.Close)
 	if m.webServer != nil {
 		if err := m.webServer.Start(); err != nil {
-			log.Printf("Warning: Failed to start web server: %v", err)
+			logWarn("Monitor", "Failed to start web server: %v", err)
 		}
-		defer m.webServer.Stop()
 	}
 
-	// Write header for terminal/log output
-	if m.terminalWriter != nil {
-		m.terminalWriter.WriteHeader()
+	// Write headers and close all outputs on shutdown
+	m.outputs.WriteHeader()
+	defer m.outputs.Close()
+
+	// Start optional collectors on their own schedules (the client is safe for concurrent use)
+	if m.sessionCollector != nil {
+		go m.runCollector(ctx, "Sessions", m.sessionCollector.config.Interval, m.collectSessions)
 	}
-	if m.logWriter != nil {
-		m.logWriter.WriteHeader()
+	if m.healthCollector != nil {
+		go m.runCollector(ctx, "Health", m.healthCollector.config.Interval, m.collectHealth)
+	}
+	if m.ping != nil {
+		go m.runCollector(ctx, "Ping", m.ping.config.Interval, m.collectPing)
+	}
+	if m.capsman != nil {
+		go m.runCollector(ctx, "CAPsMAN", m.capsman.config.Interval, m.collectCAPsMAN)
+	}
+	if m.netwatch != nil {
+		go m.runCollector(ctx, "Netwatch", m.netwatch.config.Interval, m.collectNetwatch)
+	}
+	if m.audit != nil {
+		go m.runCollector(ctx, "Audit", m.audit.config.Interval, m.collectAudit)
+	}
+	if m.linkSpeeds != nil {
+		go m.runCollector(ctx, "LinkSpeed", m.linkSpeeds.config.Interval, m.linkSpeeds.Collect)
+	}
+	if m.members != nil {
+		go m.runCollector(ctx, "Members", m.members.config.Interval, m.members.Collect)
+	}
+	if m.trunks != nil {
+		go m.runCollector(ctx, "Trunks", m.trunks.config.Interval, m.trunks.Collect)
+	}
+	if m.topTalkers != nil {
+		go m.runCollector(ctx, "TopTalkers", m.topTalkers.config.Interval, m.topTalkers.Collect)
+	}
+	if m.clients != nil {
+		go m.runCollector(ctx, "Clients", m.clients.config.Interval, m.clients.Collect)
+	}
+	if m.flows != nil {
+		go func() {
+			if err := m.flows.Run(ctx, m.sendFlowMetrics); err != nil {
+				logError("Flows", "Flow receiver stopped: %v", err)
+			}
+		}()
+	}
+	if m.summary != nil {
+		go m.summary.Run(ctx)
+	}
+	if m.shaper != nil {
+		go m.runCollector(ctx, "Shaper", m.shaper.config.Interval, m.shaper.Enforce)
+	}
+	if m.schedule != nil {
+		go m.runCollector(ctx, "Schedule", m.schedule.config.Interval, m.schedule.Enforce)
+	}
+	if m.reports != nil {
+		go m.reports.Run(ctx)
+	}
+	if m.billing != nil {
+		go m.billing.Run(ctx)
+	}
+	if m.retention != nil {
+		go m.retention.Run(ctx)
+	}
+	if m.vmClient != nil {
+		go m.runCollector(ctx, "Telemetry", m.vmClient.config.Interval, m.pushTelemetry)
+		if m.topology != nil {
+			go m.runCollector(ctx, "Topology", m.vmClient.config.Interval, m.pushTopology)
+		}
+		if m.trunks != nil {
+			go m.runCollector(ctx, "Trunks", m.vmClient.config.Interval, m.pushTrunks)
+		}
+	}
+
+	// A nil channel never fires, so saving is off without a ticker
+	var saveTick <-chan time.Time
+	if m.state != nil {
+		saveTicker := time.NewTicker(m.state.config.Interval)
+		defer saveTicker.Stop()
+		saveTick = saveTicker.C
+	}
+
+	// With POLL_ALIGN the ticker is (re)started on a wall-clock boundary
+	restartTicker := func() bool {
+		interval := m.pollInterval()
+		if m.align && !sleepContext(ctx, alignDelay(time.Now(), interval, m.location)) {
+			return false
+		}
+		ticker.Reset(interval)
+		select {
+		case <-ticker.C: // Drop a tick queued before the reset
+		default:
+		}
+		return true
+	}
+	if m.align {
+		restartTicker()
 	}
 
 	// Main monitoring loop
-	for range ticker.C {
-		if err := m.updateAndDisplay(); err != nil {
-			log.Printf("Error in monitoring loop: %v", err)
+	for {
+		select {
+		case <-ctx.Done():
+			m.notifier.Stopping()
+			m.saveState(time.Now(), true)
+			return nil
+		case config := <-m.reloads:
+			m.applyConfig(config)
+			restartTicker()
+			continue
+		case now := <-saveTick:
+			m.saveState(now, false)
+			continue
+		case <-ticker.C:
 		}
+
+		// A tick queued while the previous sample was slow would follow it almost
+		// immediately; the rate over such a short interval is mostly jitter
+		if since := time.Since(m.lastSample); since < m.pollInterval()/2 {
+			logDebug("Monitor", "Skipping tick %v after the previous sample", since.Round(time.Millisecond))
+			continue
+		}
+
+		if err := m.updateAndDisplay(ctx); err != nil {
+			logError("Monitor", "Error in monitoring loop: %v", err)
+		} else if m.backoff != nil && m.backoff.Observe(m.queryDuration) {
+			// Windows expect fewer samples while polling is stretched
+			m.outputs.SetSampleInterval(time.Now(), m.pollInterval())
+			restartTicker()
+		}
+		m.telemetry.RecordPollInterval(m.pollInterval(), m.interval)
+
+		// The loop is alive as long as it keeps completing iterations (even failed ones)
+		m.notifier.Watchdog(time.Now())
 	}
+}
 
-	return nil
+// pollInterval returns the current sampling interval (POLL_INTERVAL unless stretched)
+func (m *Monitor) pollInterval() time.Duration {
+	if m.backoff != nil {
+		return m.backoff.Interval()
+	}
+	return m.interval
+}
+
+// alignDelay returns the time until the next wall-clock multiple of interval (counted from midnight in loc)
+func alignDelay(now time.Time, interval time.Duration, loc *time.Location) time.Duration {
+	return truncateIn(now, interval, loc).Add(interval).Sub(now)
+}
+
+// sleepContext waits for d, returning false if ctx is cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// sampleTime returns when counters queried between start and now were read
+// The router reads them somewhere during the round trip; the midpoint cancels
+// out most of the latency jitter between samples. The monotonic clock is kept.
+func sampleTime(start, now time.Time) time.Time {
+	return start.Add(now.Sub(start) / 2)
+}
+
+// runCollector runs a collector immediately and then on every interval until ctx is cancelled
+func (m *Monitor) runCollector(ctx context.Context, name string, interval time.Duration, collect func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := collect(ctx); err != nil && ctx.Err() == nil {
+			// Retrying cannot help when the router lacks the menu or the user the permission
+			if errors.Is(err, routeros.ErrNoSuchCommand) || errors.Is(err, routeros.ErrPermissionDenied) || errors.Is(err, ErrCommandNotAllowed) {
+				logError(name, "Collector stopped: %v", err)
+				return
+			}
+			logError(name, "Collection error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 // initializeRates fetches initial statistics to establish baseline
-func (m *Monitor) initializeRates() error {
-	stats, err := m.client.GetInterfaceStats(m.interfaces, m.debug)
+func (m *Monitor) initializeRates(ctx context.Context) error {
+	start := time.Now()
+	m.lastSample = start
+	stats, err := m.client.GetInterfaceStats(ctx, m.polledInterfaces())
 	if err != nil {
 		return err
 	}
+	stats = appendGroupStats(stats, m.groups)
 
-	now := time.Now()
+	now := sampleTime(start, time.Now())
 	for _, stat := range stats {
 		m.rateMap[stat.Name] = &InterfaceRate{
 			Name:       stat.Name,
@@ -131,59 +575,277 @@ func (m *Monitor) initializeRates() error {
 			RxHistory:  make([]float64, m.statsWindowSize),
 		}
 	}
+	if m.resume != nil {
+		m.resumeState(stats, now)
+	}
 
 	return nil
 }
 
 // updateAndDisplay fetches new stats, calculates rates, and displays results
-func (m *Monitor) updateAndDisplay() error {
-	stats, err := m.client.GetInterfaceStats(m.interfaces, m.debug)
+func (m *Monitor) updateAndDisplay(ctx context.Context) error {
+	m.applyMembers()
+	start := time.Now()
+	m.lastSample = start
+	stats, err := m.client.GetInterfaceStats(ctx, m.polledInterfaces())
+	end := time.Now()
+	sampled := sampleTime(start, end)
+	m.observeClock(sampled)
+
+	// Rates use the monotonic sample time; outputs get the wall-clock timestamp
+	// in the TIMEZONE zone, on the interval boundary when samples are aligned
+	now := sampled.In(m.location)
+	if m.align {
+		now = now.Round(m.pollInterval())
+	}
 	if err != nil {
+		m.status.RecordError(now, err)
 		return err
 	}
+	m.queryDuration = end.Sub(start)
+	m.status.RecordSuccess(now)
+	m.telemetry.RecordSample(now)
+	m.notifier.Ready()
 
 	if len(stats) == 0 {
 		return nil // No matching interfaces
 	}
+	if m.events != nil {
+		m.events.Update(now, stats)
+	}
+	stats = appendGroupStats(stats, m.groups)
 
-	now := time.Now()
+	// The router was unreachable at startup: resume from the first successful sample
+	if m.resume != nil {
+		m.resumeState(stats, sampled)
+	}
 
 	// Check if we need to calculate statistics (only for terminal/log output)
 	needStats := m.terminalWriter != nil || m.logWriter != nil
-	rateInfoMap := m.calculateRates(stats, now, needStats)
+	rateInfoMap := m.calculateRates(stats, sampled, needStats)
 
 	if len(rateInfoMap) == 0 {
 		return nil
 	}
 
-	// 1. Terminal output (if enabled)
-	if m.terminalWriter != nil {
-		m.terminalWriter.WriteStats(now, rateInfoMap)
+	// Percentile buckets are updated first so the terminal summary is current
+	if m.percentile != nil && m.percentile.Add(now, rateInfoMap) && m.vmClient != nil {
+		if err := m.vmClient.SendPercentileMetrics(m.percentile.Results(), m.percentile.config, now); err != nil {
+			logError("VM", "Failed to send percentile metrics: %v", err)
+		}
+	}
+	// A billing month ended: its final figures are stored at its last second
+	if m.percentile != nil {
+		if results, end, ok := m.percentile.TakeClosedMonth(); ok && m.vmClient != nil {
+			if err := m.vmClient.SendPercentileMetrics(results, m.percentile.config, end.Add(-time.Second)); err != nil {
+				logError("VM", "Failed to send the final percentile metrics: %v", err)
+			}
+		}
 	}
 
-	// 2. Structured log output (if enabled)
-	if m.logWriter != nil {
-		m.logWriter.WriteStats(now, rateInfoMap)
+	if m.alerts != nil {
+		m.alerts.Evaluate(now, rateInfoMap)
+		if m.netwatch != nil {
+			m.alerts.EvaluateNetwatch(now, m.netwatch.Latest())
+		}
+	}
+	if m.summary != nil {
+		m.summary.Add(now, rateInfoMap)
+	}
+	if m.topology != nil {
+		m.topology.Add(now, rateInfoMap)
+	}
+	if m.trunks != nil {
+		m.trunks.Add(now, rateInfoMap)
+	}
+	if m.shaper != nil {
+		m.shaper.Add(now, rateInfoMap)
+	}
+
+	// Fan out to all outputs (terminal, log, WebSocket, VictoriaMetrics, OTLP)
+	m.outputs.WriteStats(now, rateInfoMap)
+
+	return nil
+}
+
+// polledInterfaces returns the interfaces to sample: the monitored ones and the discovered member ports
+func (m *Monitor) polledInterfaces() []string {
+	if len(m.memberPorts) == 0 {
+		return m.interfaces
+	}
+	polled := append([]string(nil), m.interfaces...)
+	monitored := toSet(m.interfaces)
+	for _, port := range m.memberPorts {
+		if !monitored[port] {
+			polled = append(polled, port)
+		}
+	}
+	return polled
+}
+
+// applyMembers starts sampling newly discovered member ports and forgets removed ones
+func (m *Monitor) applyMembers() {
+	if m.members == nil {
+		return
+	}
+	members, changed := m.members.Take()
+	if !changed {
+		return
 	}
 
-	// 3. WebSocket push (if enabled)
+	var ports []string
+	current := make(map[string]bool)
+	for _, list := range members {
+		for _, port := range list {
+			if !current[port] {
+				current[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+	sort.Strings(ports)
+
+	monitored := toSet(m.interfaces)
+	for _, port := range m.memberPorts {
+		if !current[port] && !monitored[port] {
+			delete(m.rateMap, port)
+		}
+	}
+	m.memberPorts = ports
+
+	if m.linkSpeeds != nil {
+		m.linkSpeeds.SetInterfaces(m.polledInterfaces())
+	}
 	if m.webServer != nil {
-		m.webServer.BroadcastStats(now, rateInfoMap)
+		m.webServer.SetMembers(members)
+	}
+}
+
+// observeClock reports wall-clock steps between samples (NTP corrections, manual changes)
+// Rates use the monotonic clock and are unaffected; timestamps follow the new time,
+// so after a step back the window outputs drop samples until it reaches their window.
+func (m *Monitor) observeClock(sampled time.Time) {
+	jump, skew := m.clock.Observe(sampled)
+	m.telemetry.RecordClock(jump, skew)
+	if jump > 0 {
+		logWarn("Monitor", "Wall clock jumped forward by %v; the skipped windows have no samples", jump.Round(time.Millisecond))
+	} else if jump < 0 {
+		logWarn("Monitor", "Wall clock jumped back by %v; windows already written are not overwritten, their samples are dropped",
+			(-jump).Round(time.Millisecond))
+	}
+}
+
+// sendFlowMetrics pushes the busiest conversations of a completed flow window to VM
+func (m *Monitor) sendFlowMetrics(snapshot *FlowSnapshot) {
+	if m.vmClient == nil {
+		return
+	}
+	if err := m.vmClient.SendFlowMetrics(snapshot); err != nil {
+		logError("VM", "Failed to send flow metrics: %v", err)
+	}
+}
+
+// pushTelemetry pushes the monitor's own metrics to VM
+func (m *Monitor) pushTelemetry(ctx context.Context) error {
+	return m.vmClient.SendTelemetryMetrics(m.telemetry.Metrics(time.Now()), time.Now())
+}
+
+// pushTopology pushes the router WAN roll-up of the last VM interval
+func (m *Monitor) pushTopology(ctx context.Context) error {
+	return m.vmClient.SendTopologyMetrics(m.topology.flushWindow(), time.Now())
+}
+
+// pushTrunks pushes the VLAN trunk roll-ups of the last VM interval
+func (m *Monitor) pushTrunks(ctx context.Context) error {
+	return m.vmClient.SendTrunkMetrics(m.trunks.flushWindow(), time.Now())
+}
+
+// collectSessions polls active PPP/hotspot sessions and pushes them to VM
+func (m *Monitor) collectSessions(ctx context.Context) error {
+	snapshot, err := m.sessionCollector.Collect(ctx, time.Now())
+	if err != nil {
+		return err
 	}
 
-	// 4. VictoriaMetrics aggregation (if enabled)
-	if m.aggregator != nil {
-		for ifaceName, rateInfo := range rateInfoMap {
-			m.aggregator.AddSample(now, ifaceName, rateInfo.RxRate, rateInfo.TxRate)
+	if m.vmClient != nil {
+		if err := m.vmClient.SendSessionMetrics(snapshot); err != nil {
+			logError("VM", "Failed to send session metrics: %v", err)
 		}
+	}
 
-		// Check for completed windows and send to VM
-		if windows := m.aggregator.GetCompletedWindows(); len(windows) > 0 {
-			for _, window := range windows {
-				if err := m.vmClient.SendMetrics(window); err != nil {
-					log.Printf("[VM] Failed to send metrics: %v", err)
-				}
-			}
+	return nil
+}
+
+// collectHealth polls router health metrics and pushes them to VM
+func (m *Monitor) collectHealth(ctx context.Context) error {
+	res, err := m.healthCollector.Collect(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if m.vmClient != nil {
+		if err := m.vmClient.SendHealthMetrics(res); err != nil {
+			logError("VM", "Failed to send health metrics: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// collectPing probes the latency targets and pushes the results to VM
+func (m *Monitor) collectPing(ctx context.Context) error {
+	snapshot, err := m.ping.Collect(ctx, time.Now())
+	if snapshot != nil && m.vmClient != nil {
+		if err := m.vmClient.SendPingMetrics(snapshot); err != nil {
+			logError("VM", "Failed to send ping metrics: %v", err)
+		}
+	}
+	return err
+}
+
+// collectCAPsMAN polls the CAPsMAN registration table and pushes the per-AP/SSID rates to VM
+func (m *Monitor) collectCAPsMAN(ctx context.Context) error {
+	snapshot, err := m.capsman.Collect(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if m.vmClient != nil {
+		if err := m.vmClient.SendCAPsMANMetrics(snapshot); err != nil {
+			logError("VM", "Failed to send CAPsMAN metrics: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// collectNetwatch polls the netwatch states and pushes them to VM
+// Alerts are evaluated by the monitoring loop, which owns the alert engine.
+func (m *Monitor) collectNetwatch(ctx context.Context) error {
+	snapshot, err := m.netwatch.Collect(ctx, time.Now().In(m.location))
+	if err != nil {
+		return err
+	}
+
+	if m.vmClient != nil {
+		if err := m.vmClient.SendNetwatchMetrics(snapshot); err != nil {
+			logError("VM", "Failed to send netwatch metrics: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// collectAudit polls the management sessions and login messages and pushes them to VM
+func (m *Monitor) collectAudit(ctx context.Context) error {
+	snapshot, err := m.audit.Collect(ctx, time.Now().In(m.location))
+	if err != nil {
+		return err
+	}
+
+	if m.vmClient != nil {
+		if err := m.vmClient.SendAuditMetrics(snapshot); err != nil {
+			logError("VM", "Failed to send audit metrics: %v", err)
 		}
 	}
 
@@ -210,31 +872,49 @@ func (m *Monitor) calculateRates(stats []InterfaceStats, now time.Time, needStat
 			continue
 		}
 
-		// Calculate time delta
+		// Calculate time delta (monotonic, wall-clock steps do not affect it)
 		timeDiff := now.Sub(prev.LastTime).Seconds()
 		if timeDiff <= 0 {
 			continue
 		}
 
+		// Lower counters mean the router rebooted or they were reset; the wrapped
+		// difference would be an absurd rate, so the sample only restarts the baseline
+		if stat.RxByte < prev.LastRxByte || stat.TxByte < prev.LastTxByte {
+			logWarn("Monitor", "%s: counters went backwards (router rebooted or counters reset), skipping one sample", stat.Name)
+			prev.LastRxByte, prev.LastTxByte, prev.LastTime = stat.RxByte, stat.TxByte, now
+			continue
+		}
+
 		// Calculate instantaneous rates (bytes/second)
 		rxRate := float64(stat.RxByte-prev.LastRxByte) / timeDiff
 		txRate := float64(stat.TxByte-prev.LastTxByte) / timeDiff
 
-		var txAvg, txPeak, rxAvg, rxPeak float64
+		var txAvg, txPeak, txMin, txStdDev, rxAvg, rxPeak, rxMin, rxStdDev float64
 
 		// Only calculate statistics if needed (for terminal/log output)
 		if needStats {
+			// A late sample covers several intervals and fills as many slots, so the
+			// window stays evenly spaced and its average time-weighted
+			interval := m.pollInterval().Seconds()
+			slots := min(max(int(math.Round(timeDiff/interval)), 1), m.statsWindowSize)
+			if slots > 1 {
+				logDebug("Monitor", "%s: sample %.2fs late, spread over %d intervals", stat.Name, timeDiff-interval, slots)
+			}
+
 			// Update ring buffer with new rates
-			prev.TxHistory[prev.HistoryIndex] = txRate
-			prev.RxHistory[prev.HistoryIndex] = rxRate
-			prev.HistoryIndex = (prev.HistoryIndex + 1) % m.statsWindowSize
-			if prev.HistoryCount < m.statsWindowSize {
-				prev.HistoryCount++
+			for i := 0; i < slots; i++ {
+				prev.TxHistory[prev.HistoryIndex] = txRate
+				prev.RxHistory[prev.HistoryIndex] = rxRate
+				prev.HistoryIndex = (prev.HistoryIndex + 1) % m.statsWindowSize
+				if prev.HistoryCount < m.statsWindowSize {
+					prev.HistoryCount++
+				}
 			}
 
 			// Calculate statistics from history
-			txAvg, txPeak = m.calculateStats(prev.TxHistory, prev.HistoryCount)
-			rxAvg, rxPeak = m.calculateStats(prev.RxHistory, prev.HistoryCount)
+			txAvg, txPeak, txMin, txStdDev = m.calculateStats(prev.TxHistory, prev.HistoryCount)
+			rxAvg, rxPeak, rxMin, rxStdDev = m.calculateStats(prev.RxHistory, prev.HistoryCount)
 		}
 
 		// Update baseline for next iteration
@@ -251,28 +931,58 @@ func (m *Monitor) calculateRates(stats []InterfaceStats, now time.Time, needStat
 			TxAvg:         txAvg,
 			RxPeak:        rxPeak,
 			TxPeak:        txPeak,
+			RxMin:         rxMin,
+			TxMin:         txMin,
+			RxStdDev:      rxStdDev,
+			TxStdDev:      txStdDev,
+			RxBytes:       stat.RxByte,
+			TxBytes:       stat.TxByte,
+			State:         stat.State,
+		}
+		if m.linkSpeeds != nil {
+			info := rateInfoMap[stat.Name]
+			info.LinkSpeed = m.linkSpeeds.Speed(stat.Name)
+			info.RxUtilization = utilization(rxRate, info.LinkSpeed)
+			info.TxUtilization = utilization(txRate, info.LinkSpeed)
+		}
+		if needStats && m.sparklines {
+			rateInfoMap[stat.Name].RxHistory = prev.chronological(prev.RxHistory)
+			rateInfoMap[stat.Name].TxHistory = prev.chronological(prev.TxHistory)
 		}
 	}
 
 	return rateInfoMap
 }
 
-// calculateStats computes average and peak from a history buffer
-func (m *Monitor) calculateStats(history []float64, count int) (avg float64, peak float64) {
+// calculateStats computes average, peak, min and standard deviation from a history buffer
+func (m *Monitor) calculateStats(history []float64, count int) (avg, peak, min, stddev float64) {
 	if count == 0 {
-		return 0, 0
+		return 0, 0, 0, 0
 	}
 
 	var sum float64
 	peak = history[0]
+	min = history[0]
 
 	for i := 0; i < count; i++ {
 		sum += history[i]
 		if history[i] > peak {
 			peak = history[i]
 		}
+		if history[i] < min {
+			min = history[i]
+		}
 	}
 
 	avg = sum / float64(count)
-	return avg, peak
+
+	// Population standard deviation over the window
+	var variance float64
+	for i := 0; i < count; i++ {
+		diff := history[i] - avg
+		variance += diff * diff
+	}
+	stddev = math.Sqrt(variance / float64(count))
+
+	return avg, peak, min, stddev
 }
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// ============================================================================
+// NetFlow v5 / v9 / IPFIX Decoder
+// ============================================================================
+//
+// RouterOS /ip/traffic-flow exports v1, v5, v9 and IPFIX (v10). v5 records have
+// a fixed layout; v9 and IPFIX data records are described by templates the
+// exporter sends periodically, so data arriving before its template is dropped
+// (counted as missing template) until the template shows up. Templates that are
+// not refreshed expire, and the cache is capped so exporters cannot grow it
+// without bound.
+
+// flowRecord is the part of a flow record the collector aggregates
+type flowRecord struct {
+	SrcAddr  netip.Addr
+	DstAddr  netip.Addr
+	Protocol uint8
+	SrcPort  uint16
+	DstPort  uint16
+	Bytes    uint64
+	Packets  uint64
+}
+
+// Information element IDs (shared by NetFlow v9 and IPFIX)
+const (
+	flowFieldBytes    = 1  // IN_BYTES / octetDeltaCount
+	flowFieldPackets  = 2  // IN_PKTS / packetDeltaCount
+	flowFieldProtocol = 4  // PROTOCOL / protocolIdentifier
+	flowFieldSrcPort  = 7  // L4_SRC_PORT / sourceTransportPort
+	flowFieldSrcAddr  = 8  // IPV4_SRC_ADDR / sourceIPv4Address
+	flowFieldDstPort  = 11 // L4_DST_PORT / destinationTransportPort
+	flowFieldDstAddr  = 12 // IPV4_DST_ADDR / destinationIPv4Address
+	flowFieldSrcAddr6 = 27 // IPV6_SRC_ADDR / sourceIPv6Address
+	flowFieldDstAddr6 = 28 // IPV6_DST_ADDR / destinationIPv6Address
+)
+
+// ipfixVariableLength marks a variable-length IPFIX field in a template
+const ipfixVariableLength = 0xffff
+
+// errFlowTemplateMissing is returned for v9/IPFIX data sets whose template hasn't been received yet
+var errFlowTemplateMissing = errors.New("template not received yet")
+
+const (
+	// flowTemplateTTL expires templates an exporter stopped refreshing
+	// (twice RouterOS' default v9/IPFIX template timeout of 30 minutes)
+	flowTemplateTTL = time.Hour
+	// maxFlowTemplates caps the templates kept across all exporters
+	maxFlowTemplates = 4096
+)
+
+// flowTemplateKey identifies a template: exporter, source ID / observation domain and template ID
+type flowTemplateKey struct {
+	exporter string
+	domain   uint32
+	id       uint16
+}
+
+// flowTemplateField is one field of a template (enterprise fields are skipped by length only)
+type flowTemplateField struct {
+	id         uint16
+	length     uint16
+	enterprise bool
+}
+
+// flowTemplate is a stored template and when the exporter last sent it
+type flowTemplate struct {
+	fields  []flowTemplateField
+	updated time.Time
+}
+
+// flowDecoder decodes export packets and keeps the v9/IPFIX templates of every exporter
+// Not safe for concurrent use; the collector decodes from a single goroutine.
+type flowDecoder struct {
+	templates    map[flowTemplateKey]*flowTemplate
+	maxTemplates int       // Templates kept at most (default: maxFlowTemplates)
+	swept        time.Time // Last removal of expired templates
+	now          time.Time // Receive time of the packet being decoded
+}
+
+// newFlowDecoder creates a decoder with an empty template cache
+func newFlowDecoder() *flowDecoder {
+	return &flowDecoder{
+		templates:    make(map[flowTemplateKey]*flowTemplate),
+		maxTemplates: maxFlowTemplates,
+	}
+}
+
+// Decode parses one export packet received from exporter at now and returns its version and flow records
+// Records decoded before an error are still returned.
+func (d *flowDecoder) Decode(now time.Time, exporter string, data []byte) (int, []flowRecord, error) {
+	if len(data) < 2 {
+		return 0, nil, fmt.Errorf("packet too short (%d bytes)", len(data))
+	}
+
+	d.now = now
+	if now.Sub(d.swept) >= flowTemplateTTL {
+		d.expireTemplates()
+	}
+
+	version := int(binary.BigEndian.Uint16(data))
+	var records []flowRecord
+	var err error
+	switch version {
+	case 5:
+		records, err = decodeNetFlowV5(data)
+	case 9:
+		records, err = d.decodeNetFlowV9(exporter, data)
+	case 10:
+		records, err = d.decodeIPFIX(exporter, data)
+	default:
+		err = fmt.Errorf("unsupported version %d", version)
+	}
+	return version, records, err
+}
+
+// decodeNetFlowV5 parses a v5 packet (24-byte header, 48-byte records)
+// The header's sampling interval, if set, scales bytes and packets back up.
+func decodeNetFlowV5(data []byte) ([]flowRecord, error) {
+	const headerLen, recordLen = 24, 48
+	if len(data) < headerLen {
+		return nil, fmt.Errorf("v5 header too short (%d bytes)", len(data))
+	}
+
+	count := int(binary.BigEndian.Uint16(data[2:]))
+	if len(data) < headerLen+count*recordLen {
+		return nil, fmt.Errorf("v5 packet truncated (%d records, %d bytes)", count, len(data))
+	}
+
+	// Low 14 bits: sampling interval (0 = not sampled)
+	sampling := uint64(binary.BigEndian.Uint16(data[22:]) & 0x3fff)
+	if sampling == 0 {
+		sampling = 1
+	}
+
+	records := make([]flowRecord, 0, count)
+	for i := 0; i < count; i++ {
+		r := data[headerLen+i*recordLen:]
+		records = append(records, flowRecord{
+			SrcAddr:  netip.AddrFrom4([4]byte(r[0:4])),
+			DstAddr:  netip.AddrFrom4([4]byte(r[4:8])),
+			Packets:  uint64(binary.BigEndian.Uint32(r[16:])) * sampling,
+			Bytes:    uint64(binary.BigEndian.Uint32(r[20:])) * sampling,
+			SrcPort:  binary.BigEndian.Uint16(r[32:]),
+			DstPort:  binary.BigEndian.Uint16(r[34:]),
+			Protocol: r[38],
+		})
+	}
+	return records, nil
+}
+
+// decodeNetFlowV9 parses a v9 packet (20-byte header followed by flowsets)
+// Flowset 0 carries templates, 1 options templates (ignored), >= 256 data records.
+func (d *flowDecoder) decodeNetFlowV9(exporter string, data []byte) ([]flowRecord, error) {
+	const headerLen = 20
+	if len(data) < headerLen {
+		return nil, fmt.Errorf("v9 header too short (%d bytes)", len(data))
+	}
+	sourceID := binary.BigEndian.Uint32(data[16:])
+
+	var records []flowRecord
+	var firstErr error
+	for rest := data[headerLen:]; len(rest) >= 4; {
+		setID := binary.BigEndian.Uint16(rest)
+		setLen := int(binary.BigEndian.Uint16(rest[2:]))
+		if setLen < 4 || setLen > len(rest) {
+			return records, fmt.Errorf("v9 flowset %d has invalid length %d", setID, setLen)
+		}
+		body := rest[4:setLen]
+		rest = rest[setLen:]
+
+		switch {
+		case setID == 0:
+			if err := d.parseTemplates(exporter, sourceID, body, false); err != nil {
+				return records, fmt.Errorf("v9 template: %w", err)
+			}
+		case setID >= 256:
+			decoded, err := d.decodeDataSet(flowTemplateKey{exporter, sourceID, setID}, body, false)
+			records = append(records, decoded...)
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("v9 data flowset %d: %w", setID, err)
+			}
+		}
+	}
+	return records, firstErr
+}
+
+// decodeIPFIX parses an IPFIX message (16-byte header followed by sets)
+// Set 2 carries templates, 3 options templates (ignored), >= 256 data records.
+func (d *flowDecoder) decodeIPFIX(exporter string, data []byte) ([]flowRecord, error) {
+	const headerLen = 16
+	if len(data) < headerLen {
+		return nil, fmt.Errorf("IPFIX header too short (%d bytes)", len(data))
+	}
+	length := int(binary.BigEndian.Uint16(data[2:]))
+	if length < headerLen || length > len(data) {
+		return nil, fmt.Errorf("IPFIX message has invalid length %d", length)
+	}
+	domain := binary.BigEndian.Uint32(data[12:])
+
+	var records []flowRecord
+	var firstErr error
+	for rest := data[headerLen:length]; len(rest) >= 4; {
+		setID := binary.BigEndian.Uint16(rest)
+		setLen := int(binary.BigEndian.Uint16(rest[2:]))
+		if setLen < 4 || setLen > len(rest) {
+			return records, fmt.Errorf("IPFIX set %d has invalid length %d", setID, setLen)
+		}
+		body := rest[4:setLen]
+		rest = rest[setLen:]
+
+		switch {
+		case setID == 2:
+			if err := d.parseTemplates(exporter, domain, body, true); err != nil {
+				return records, fmt.Errorf("IPFIX template: %w", err)
+			}
+		case setID >= 256:
+			decoded, err := d.decodeDataSet(flowTemplateKey{exporter, domain, setID}, body, true)
+			records = append(records, decoded...)
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("IPFIX data set %d: %w", setID, err)
+			}
+		}
+	}
+	return records, firstErr
+}
+
+// parseTemplates stores every template record of a template set
+// IPFIX field specifiers with the enterprise bit carry a 4-byte enterprise number.
+func (d *flowDecoder) parseTemplates(exporter string, domain uint32, body []byte, ipfix bool) error {
+	for len(body) >= 4 {
+		id := binary.BigEndian.Uint16(body)
+		count := int(binary.BigEndian.Uint16(body[2:]))
+		body = body[4:]
+		if ipfix && id == 2 && count == 0 {
+			// RFC 7011 section 8.1: withdraws every template of the observation domain
+			for key := range d.templates {
+				if key.exporter == exporter && key.domain == domain {
+					delete(d.templates, key)
+				}
+			}
+			continue
+		}
+		if id < 256 {
+			return fmt.Errorf("invalid template ID %d", id)
+		}
+
+		fields := make([]flowTemplateField, 0, count)
+		for i := 0; i < count; i++ {
+			if len(body) < 4 {
+				return fmt.Errorf("template %d truncated", id)
+			}
+			field := flowTemplateField{
+				id:     binary.BigEndian.Uint16(body),
+				length: binary.BigEndian.Uint16(body[2:]),
+			}
+			body = body[4:]
+			if ipfix && field.id&0x8000 != 0 {
+				if len(body) < 4 {
+					return fmt.Errorf("template %d truncated", id)
+				}
+				field.id &= 0x7fff
+				field.enterprise = true
+				body = body[4:]
+			}
+			fields = append(fields, field)
+		}
+
+		// A template with no fields withdraws it
+		key := flowTemplateKey{exporter, domain, id}
+		if count == 0 {
+			delete(d.templates, key)
+			continue
+		}
+		if err := d.storeTemplate(key, fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeTemplate adds or refreshes a template
+// When the cache is full, expired templates are removed first; new templates are
+// refused if that frees nothing, while known ones are still refreshed.
+func (d *flowDecoder) storeTemplate(key flowTemplateKey, fields []flowTemplateField) error {
+	if _, ok := d.templates[key]; !ok && len(d.templates) >= d.maxTemplates {
+		d.expireTemplates()
+		if len(d.templates) >= d.maxTemplates {
+			return fmt.Errorf("template cache full (%d templates), template %d dropped", len(d.templates), key.id)
+		}
+	}
+	d.templates[key] = &flowTemplate{fields: fields, updated: d.now}
+	return nil
+}
+
+// expireTemplates removes the templates not refreshed within flowTemplateTTL
+func (d *flowDecoder) expireTemplates() {
+	for key, template := range d.templates {
+		if d.now.Sub(template.updated) >= flowTemplateTTL {
+			delete(d.templates, key)
+		}
+	}
+	d.swept = d.now
+}
+
+// decodeDataSet decodes the records of a data set with its template
+// Trailing bytes shorter than a record are padding.
+func (d *flowDecoder) decodeDataSet(key flowTemplateKey, body []byte, ipfix bool) ([]flowRecord, error) {
+	template, ok := d.templates[key]
+	if !ok || d.now.Sub(template.updated) >= flowTemplateTTL {
+		return nil, errFlowTemplateMissing
+	}
+	fields := template.fields
+
+	var records []flowRecord
+	for len(body) > 0 {
+		record, n, err := decodeDataRecord(fields, body, ipfix)
+		if err != nil {
+			// Not enough data left for another record: padding
+			if len(records) > 0 || len(body) < 4 {
+				break
+			}
+			return nil, err
+		}
+		records = append(records, record)
+		body = body[n:]
+	}
+	return records, nil
+}
+
+// decodeDataRecord decodes one data record and returns it with its length in bytes
+func decodeDataRecord(fields []flowTemplateField, data []byte, ipfix bool) (flowRecord, int, error) {
+	var record flowRecord
+	offset := 0
+	for _, field := range fields {
+		length := int(field.length)
+		if ipfix && field.length == ipfixVariableLength {
+			// RFC 7011 section 7: 1-byte length, or 255 followed by a 2-byte length
+			if offset >= len(data) {
+				return record, 0, fmt.Errorf("record truncated")
+			}
+			length = int(data[offset])
+			offset++
+			if length == 255 {
+				if offset+2 > len(data) {
+					return record, 0, fmt.Errorf("record truncated")
+				}
+				length = int(binary.BigEndian.Uint16(data[offset:]))
+				offset += 2
+			}
+		}
+		if length == 0 && !ipfix {
+			return record, 0, fmt.Errorf("zero-length field %d", field.id)
+		}
+		if offset+length > len(data) {
+			return record, 0, fmt.Errorf("record truncated")
+		}
+		value := data[offset : offset+length]
+		offset += length
+
+		if !field.enterprise {
+			setFlowField(&record, field.id, value)
+		}
+	}
+	if offset == 0 {
+		return record, 0, fmt.Errorf("empty template")
+	}
+	return record, offset, nil
+}
+
+// setFlowField stores a known information element in the record (unknown fields are ignored)
+func setFlowField(record *flowRecord, id uint16, value []byte) {
+	switch id {
+	case flowFieldBytes:
+		record.Bytes = flowUint(value)
+	case flowFieldPackets:
+		record.Packets = flowUint(value)
+	case flowFieldProtocol:
+		record.Protocol = uint8(flowUint(value))
+	case flowFieldSrcPort:
+		record.SrcPort = uint16(flowUint(value))
+	case flowFieldDstPort:
+		record.DstPort = uint16(flowUint(value))
+	case flowFieldSrcAddr, flowFieldSrcAddr6:
+		if addr, ok := netip.AddrFromSlice(value); ok {
+			record.SrcAddr = addr
+		}
+	case flowFieldDstAddr, flowFieldDstAddr6:
+		if addr, ok := netip.AddrFromSlice(value); ok {
+			record.DstAddr = addr
+		}
+	}
+}
+
+// flowUint decodes a big-endian unsigned integer of 1 to 8 bytes (reduced-size encoding)
+func flowUint(value []byte) uint64 {
+	var n uint64
+	for _, b := range value {
+		n = n<<8 | uint64(b)
+	}
+	return n
+}
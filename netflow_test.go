@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Packet builders for the flow decoder tests (big-endian, as on the wire)
+
+// flowSet returns a v9 flowset or IPFIX set: ID, length including the 4-byte header, body
+func flowSet(id uint16, body ...[]byte) []byte {
+	set := []byte{0, 0, 0, 0}
+	for _, b := range body {
+		set = append(set, b...)
+	}
+	binary.BigEndian.PutUint16(set, id)
+	binary.BigEndian.PutUint16(set[2:], uint16(len(set)))
+	return set
+}
+
+// flowTemplateRecord returns a template record; fields are (ID, length) pairs
+func flowTemplateRecord(id uint16, fields ...[2]uint16) []byte {
+	record := binary.BigEndian.AppendUint16(nil, id)
+	record = binary.BigEndian.AppendUint16(record, uint16(len(fields)))
+	for _, field := range fields {
+		record = binary.BigEndian.AppendUint16(record, field[0])
+		record = binary.BigEndian.AppendUint16(record, field[1])
+	}
+	return record
+}
+
+// netFlowV9Packet returns a v9 packet with source ID 1
+func netFlowV9Packet(sets ...[]byte) []byte {
+	packet := make([]byte, 20)
+	binary.BigEndian.PutUint16(packet, 9)
+	binary.BigEndian.PutUint16(packet[2:], uint16(len(sets)))
+	binary.BigEndian.PutUint32(packet[16:], 1)
+	for _, set := range sets {
+		packet = append(packet, set...)
+	}
+	return packet
+}
+
+// ipfixPacket returns an IPFIX message with observation domain 1
+func ipfixPacket(sets ...[]byte) []byte {
+	packet := make([]byte, 16)
+	binary.BigEndian.PutUint16(packet, 10)
+	binary.BigEndian.PutUint32(packet[12:], 1)
+	for _, set := range sets {
+		packet = append(packet, set...)
+	}
+	binary.BigEndian.PutUint16(packet[2:], uint16(len(packet)))
+	return packet
+}
+
+// netFlowV5Packet returns a v5 packet with one 48-byte record per flow
+func netFlowV5Packet(sampling uint16, flows ...flowRecord) []byte {
+	packet := make([]byte, 24)
+	binary.BigEndian.PutUint16(packet, 5)
+	binary.BigEndian.PutUint16(packet[2:], uint16(len(flows)))
+	binary.BigEndian.PutUint16(packet[22:], 1<<14|sampling) // Mode 1 (packet interval)
+	for _, flow := range flows {
+		record := make([]byte, 48)
+		copy(record, flow.SrcAddr.AsSlice())
+		copy(record[4:], flow.DstAddr.AsSlice())
+		binary.BigEndian.PutUint32(record[16:], uint32(flow.Packets))
+		binary.BigEndian.PutUint32(record[20:], uint32(flow.Bytes))
+		binary.BigEndian.PutUint16(record[32:], flow.SrcPort)
+		binary.BigEndian.PutUint16(record[34:], flow.DstPort)
+		record[38] = flow.Protocol
+		packet = append(packet, record...)
+	}
+	return packet
+}
+
+// testFlowTemplate is IPv4 addresses, protocol, ports, bytes (4) and packets (4): a 21-byte record
+var testFlowTemplate = [][2]uint16{
+	{flowFieldSrcAddr, 4}, {flowFieldDstAddr, 4}, {flowFieldProtocol, 1},
+	{flowFieldSrcPort, 2}, {flowFieldDstPort, 2}, {flowFieldBytes, 4}, {flowFieldPackets, 4},
+}
+
+// testFlow is the record of testFlowData
+var testFlow = flowRecord{
+	SrcAddr: netip.MustParseAddr("192.168.88.10"), DstAddr: netip.MustParseAddr("1.1.1.1"),
+	Protocol: 6, SrcPort: 50000, DstPort: 443, Bytes: 1500, Packets: 3,
+}
+
+// testFlowData is testFlow encoded with testFlowTemplate
+var testFlowData = []byte{
+	192, 168, 88, 10, 1, 1, 1, 1, 6,
+	0xc3, 0x50, 0x01, 0xbb,
+	0, 0, 0x05, 0xdc, 0, 0, 0, 3,
+}
+
+var testFlowTime = time.Unix(1700000000, 0)
+
+func TestDecodeNetFlowV5(t *testing.T) {
+	flow := testFlow
+	packet := netFlowV5Packet(10, flow, flow)
+
+	d := newFlowDecoder()
+	version, records, err := d.Decode(testFlowTime, "10.0.0.1", packet)
+	if err != nil || version != 5 || len(records) != 2 {
+		t.Fatalf("Decode = version %d, %d records, error %v; want version 5 with 2 records", version, len(records), err)
+	}
+	want := flow
+	want.Bytes, want.Packets = flow.Bytes*10, flow.Packets*10
+	if records[0] != want {
+		t.Errorf("record %+v, want %+v (scaled by the 1:10 sampling)", records[0], want)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		packet []byte
+		want   string
+	}{
+		{"empty", nil, "packet too short"},
+		{"truncated header", packet[:20], "v5 header too short"},
+		{"truncated records", packet[:24+48+20], "v5 packet truncated"},
+		{"unknown version", []byte{0, 7, 0, 0}, "unsupported version 7"},
+	} {
+		if _, _, err := d.Decode(testFlowTime, "10.0.0.1", tt.packet); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestDecodeNetFlowV9(t *testing.T) {
+	template := flowSet(0, flowTemplateRecord(256, testFlowTemplate...))
+	data := flowSet(256, testFlowData, testFlowData, []byte{0, 0, 0}) // Two records and padding
+
+	d := newFlowDecoder()
+
+	// Data before its template
+	if _, records, err := d.Decode(testFlowTime, "10.0.0.1", netFlowV9Packet(data)); !errors.Is(err, errFlowTemplateMissing) || len(records) != 0 {
+		t.Fatalf("data without template: %d records, error %v; want errFlowTemplateMissing", len(records), err)
+	}
+
+	version, records, err := d.Decode(testFlowTime, "10.0.0.1", netFlowV9Packet(template, data))
+	if err != nil || version != 9 || len(records) != 2 {
+		t.Fatalf("Decode = version %d, %d records, error %v; want version 9 with 2 records", version, len(records), err)
+	}
+	if records[0] != testFlow {
+		t.Errorf("record %+v, want %+v", records[0], testFlow)
+	}
+
+	// Templates belong to one exporter
+	if _, _, err := d.Decode(testFlowTime, "10.0.0.2", netFlowV9Packet(data)); !errors.Is(err, errFlowTemplateMissing) {
+		t.Errorf("data from another exporter: error %v, want errFlowTemplateMissing", err)
+	}
+
+	// A template without fields withdraws it
+	withdraw := flowSet(0, flowTemplateRecord(256))
+	if _, _, err := d.Decode(testFlowTime, "10.0.0.1", netFlowV9Packet(withdraw, data)); !errors.Is(err, errFlowTemplateMissing) {
+		t.Errorf("data after withdrawal: error %v, want errFlowTemplateMissing", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		packet []byte
+		want   string
+	}{
+		{"truncated header", netFlowV9Packet()[:12], "v9 header too short"},
+		{"flowset longer than packet", netFlowV9Packet(template)[:30], "invalid length"},
+		{"flowset length below header", netFlowV9Packet([]byte{1, 0, 0, 2}), "invalid length 2"},
+		{"truncated template", netFlowV9Packet(flowSet(0, flowTemplateRecord(256, testFlowTemplate...)[:10])), "template 256 truncated"},
+		{"template ID below 256", netFlowV9Packet(flowSet(0, flowTemplateRecord(255, testFlowTemplate...))), "invalid template ID 255"},
+		{"zero-length field", netFlowV9Packet(flowSet(0, flowTemplateRecord(257, [2]uint16{flowFieldBytes, 0})), flowSet(257, []byte{1, 2, 3, 4})), "zero-length field"},
+		{"truncated record", netFlowV9Packet(template, flowSet(256, testFlowData[:18])), "record truncated"},
+	} {
+		if _, _, err := d.Decode(testFlowTime, "10.0.0.1", tt.packet); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestDecodeIPFIX(t *testing.T) {
+	// Variable-length field (application name) between the addresses and ports, and an enterprise field
+	fields := [][2]uint16{
+		{flowFieldSrcAddr, 4}, {flowFieldDstAddr, 4}, {96, ipfixVariableLength},
+		{flowFieldProtocol, 1}, {flowFieldSrcPort, 2}, {flowFieldDstPort, 2}, {flowFieldBytes, 4}, {flowFieldPackets, 4},
+	}
+	template := flowTemplateRecord(300, fields...)
+	// Enterprise field 0x8000|1 (bytes, enterprise 9), 2 bytes long: must not be read as octetDeltaCount
+	template = append(template, 0x80, 0x01, 0, 2, 0, 0, 0, 9)
+	binary.BigEndian.PutUint16(template[2:], uint16(len(fields)+1))
+
+	record := func(name []byte) []byte {
+		r := append([]byte(nil), testFlowData[:8]...)
+		r = append(r, name...)
+		r = append(r, testFlowData[8:]...)
+		return append(r, 0xff, 0xff)
+	}
+	short := record(append([]byte{3}, "dns"...))
+	long := record(append([]byte{255, 1, 44}, make([]byte, 300)...)) // 255 then a 2-byte length
+	templateSet := flowSet(2, template)
+
+	d := newFlowDecoder()
+	version, records, err := d.Decode(testFlowTime, "10.0.0.1", ipfixPacket(templateSet, flowSet(300, short, long)))
+	if err != nil || version != 10 || len(records) != 2 {
+		t.Fatalf("Decode = version %d, %d records, error %v; want version 10 with 2 records", version, len(records), err)
+	}
+	for i, r := range records {
+		if r != testFlow {
+			t.Errorf("record %d: %+v, want %+v", i, r, testFlow)
+		}
+	}
+
+	// Template ID 2 with no fields withdraws every template of the domain
+	withdrawAll := flowSet(2, flowTemplateRecord(2))
+	if _, _, err := d.Decode(testFlowTime, "10.0.0.1", ipfixPacket(withdrawAll, flowSet(300, short))); !errors.Is(err, errFlowTemplateMissing) {
+		t.Errorf("data after withdrawing all templates: error %v, want errFlowTemplateMissing", err)
+	}
+
+	truncatedLength := ipfixPacket(templateSet, flowSet(300, short))
+	binary.BigEndian.PutUint16(truncatedLength[2:], uint16(len(truncatedLength)+1))
+	for _, tt := range []struct {
+		name   string
+		packet []byte
+		want   string
+	}{
+		{"truncated header", ipfixPacket()[:10], "IPFIX header too short"},
+		{"message length beyond packet", truncatedLength, "invalid length"},
+		{"set longer than message", ipfixPacket(templateSet[:20]), "invalid length"},
+		{"truncated enterprise number", ipfixPacket(flowSet(2, template[:len(template)-2])), "template 300 truncated"},
+		{"variable length beyond record", ipfixPacket(templateSet, flowSet(300, short[:8], []byte{200, 1, 2, 3})), "record truncated"},
+		{"truncated 3-byte length", ipfixPacket(templateSet, flowSet(300, short[:8], []byte{255, 1, 0, 0}[:3])), "record truncated"},
+	} {
+		if _, _, err := d.Decode(testFlowTime, "10.0.0.1", tt.packet); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestFlowTemplateCache(t *testing.T) {
+	d := newFlowDecoder()
+	d.maxTemplates = 2
+	template := func(id uint16) []byte { return flowSet(2, flowTemplateRecord(id, testFlowTemplate...)) }
+	data := func(id uint16) []byte { return flowSet(id, testFlowData) }
+
+	now := testFlowTime
+	for _, id := range []uint16{256, 257} {
+		if _, _, err := d.Decode(now, "10.0.0.1", ipfixPacket(template(id))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Full: new templates are refused, known ones still refreshed
+	if _, _, err := d.Decode(now, "10.0.0.2", ipfixPacket(template(256))); err == nil || !strings.Contains(err.Error(), "template cache full") {
+		t.Errorf("template beyond the cap: error %v, want template cache full", err)
+	}
+	now = now.Add(flowTemplateTTL / 2)
+	if _, _, err := d.Decode(now, "10.0.0.1", ipfixPacket(template(256))); err != nil {
+		t.Errorf("refreshing a known template: %v", err)
+	}
+
+	// 257 expires, 256 was refreshed half a TTL ago
+	now = now.Add(flowTemplateTTL / 2)
+	if _, _, err := d.Decode(now, "10.0.0.1", ipfixPacket(data(257))); !errors.Is(err, errFlowTemplateMissing) {
+		t.Errorf("data of an expired template: error %v, want errFlowTemplateMissing", err)
+	}
+	if _, records, err := d.Decode(now, "10.0.0.1", ipfixPacket(data(256))); err != nil || len(records) != 1 {
+		t.Errorf("data of a refreshed template: %d records, error %v", len(records), err)
+	}
+
+	// The expired template made room for another exporter
+	if _, _, err := d.Decode(now, "10.0.0.2", ipfixPacket(template(256))); err != nil {
+		t.Errorf("template after expiry: %v", err)
+	}
+	if len(d.templates) != 2 {
+		t.Errorf("%d templates cached, want 2", len(d.templates))
+	}
+}
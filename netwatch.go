@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Netwatch (reachability checks run by the router)
+// ============================================================================
+
+// NetwatchEntry is one /tool/netwatch entry
+type NetwatchEntry struct {
+	Host   string    `json:"host"`
+	Name   string    `json:"name,omitempty"` // Entry name (v7) or comment
+	Type   string    `json:"type,omitempty"` // simple, icmp, tcp-conn, http-get, dns (v7); empty on v6
+	Status string    `json:"status"`         // up, down or unknown
+	Since  time.Time `json:"since"`          // Last status change (zero if never changed)
+}
+
+// Up reports whether the host is reachable (false for down and unknown)
+func (e NetwatchEntry) Up() bool {
+	return e.Status == "up"
+}
+
+// NetwatchSnapshot is the result of one collection cycle
+type NetwatchSnapshot struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Entries   []NetwatchEntry `json:"entries"` // Sorted by host
+}
+
+// NetwatchCollector polls /tool/netwatch and reports the host states
+//
+// The router runs the checks itself, so hosts only reachable from the router
+// (or over its VPNs) are covered, and their up/down transitions reach the same
+// metrics and alert pipeline as the interface rates.
+type NetwatchCollector struct {
+	client RouterClient
+	config *NetwatchConfig
+
+	statuses map[string]string // Host -> status at the last poll (only used by the collector goroutine)
+
+	latest   *NetwatchSnapshot
+	latestMu sync.RWMutex
+}
+
+// NewNetwatchCollector creates a new netwatch collector
+func NewNetwatchCollector(client RouterClient, config *NetwatchConfig) *NetwatchCollector {
+	logInfo("Netwatch", "Netwatch collector initialized (interval: %v)", config.Interval)
+
+	return &NetwatchCollector{
+		client:   client,
+		config:   config,
+		statuses: make(map[string]string),
+	}
+}
+
+// Collect reads the enabled netwatch entries and logs status changes
+func (n *NetwatchCollector) Collect(ctx context.Context, now time.Time) (*NetwatchSnapshot, error) {
+	rows, err := n.client.Run(ctx, "/tool/netwatch/print", "=.proplist=host,name,comment,type,status,since,disabled")
+	if err != nil {
+		return nil, fmt.Errorf("netwatch: %w", err)
+	}
+
+	snapshot := &NetwatchSnapshot{Timestamp: now, Entries: make([]NetwatchEntry, 0, len(rows))}
+	statuses := make(map[string]string, len(rows))
+	for _, row := range rows {
+		if row["disabled"] == "true" || row["host"] == "" {
+			continue
+		}
+		entry := NetwatchEntry{
+			Host:   row["host"],
+			Name:   row["name"],
+			Type:   row["type"],
+			Status: row["status"],
+			Since:  parseRouterOSTime(row["since"], now.Location()),
+		}
+		if entry.Name == "" {
+			entry.Name = row["comment"]
+		}
+		if entry.Status == "" {
+			entry.Status = "unknown"
+		}
+		snapshot.Entries = append(snapshot.Entries, entry)
+
+		statuses[entry.Host] = entry.Status
+		if previous, ok := n.statuses[entry.Host]; ok && previous != entry.Status {
+			logInfo("Netwatch", "%s: %s -> %s", netwatchDisplayName(entry), previous, entry.Status)
+		}
+	}
+	n.statuses = statuses
+	sort.Slice(snapshot.Entries, func(i, j int) bool { return snapshot.Entries[i].Host < snapshot.Entries[j].Host })
+
+	n.latestMu.Lock()
+	n.latest = snapshot
+	n.latestMu.Unlock()
+
+	return snapshot, nil
+}
+
+// Latest returns the most recent snapshot (nil before the first collection)
+func (n *NetwatchCollector) Latest() *NetwatchSnapshot {
+	n.latestMu.RLock()
+	defer n.latestMu.RUnlock()
+	return n.latest
+}
+
+// netwatchDisplayName returns "name (host)" or the host alone
+func netwatchDisplayName(entry NetwatchEntry) string {
+	if entry.Name == "" || entry.Name == entry.Host {
+		return entry.Host
+	}
+	return entry.Name + " (" + entry.Host + ")"
+}
+
+// parseRouterOSTime parses a RouterOS date-time in the router's clock
+// Accepts "2024-01-02 15:04:05" (v7.10+) and "jan/02/2024 15:04:05" (older versions);
+// the router's time zone is not reported, so loc (the TIMEZONE zone) is assumed.
+func parseRouterOSTime(value string, loc *time.Location) time.Time {
+	for _, layout := range []string{"2006-01-02 15:04:05", "Jan/02/2006 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// netwatchMetrics renders netwatch entries in Prometheus text format
+// Samples carry the snapshot timestamp when withTimestamp is set (VictoriaMetrics push).
+func netwatchMetrics(snapshot *NetwatchSnapshot, withTimestamp bool) string {
+	timestamp := ""
+	if withTimestamp {
+		timestamp = fmt.Sprintf(" %d", snapshot.Timestamp.UnixMilli())
+	}
+
+	var up, since strings.Builder
+	for _, entry := range snapshot.Entries {
+		if entry.Status == "unknown" {
+			continue // Not checked yet
+		}
+		series := fmt.Sprintf("host=\"%s\"", escapeLabelValue(entry.Host))
+		if entry.Name != "" {
+			series += fmt.Sprintf(",name=\"%s\"", escapeLabelValue(entry.Name))
+		}
+		if entry.Type != "" {
+			series += fmt.Sprintf(",type=\"%s\"", escapeLabelValue(entry.Type))
+		}
+		value := 0
+		if entry.Up() {
+			value = 1
+		}
+		fmt.Fprintf(&up, "mikrotik_netwatch_up{%s} %d%s\n", series, value, timestamp)
+		if !entry.Since.IsZero() {
+			fmt.Fprintf(&since, "mikrotik_netwatch_status_since_seconds{%s} %d%s\n", series, entry.Since.Unix(), timestamp)
+		}
+	}
+	if up.Len() == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintln(&out, "# HELP mikrotik_netwatch_up Whether the netwatch host is reachable (1) or down (0)")
+	fmt.Fprintln(&out, "# TYPE mikrotik_netwatch_up gauge")
+	fmt.Fprint(&out, up.String())
+	if since.Len() > 0 {
+		fmt.Fprintln(&out, "# HELP mikrotik_netwatch_status_since_seconds Unix time of the last status change (router clock)")
+		fmt.Fprintln(&out, "# TYPE mikrotik_netwatch_status_since_seconds gauge")
+		fmt.Fprint(&out, since.String())
+	}
+	return out.String()
+}
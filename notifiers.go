@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ============================================================================
+// Chat Notifiers (Telegram, Slack, Discord)
+// ============================================================================
+
+// Notification is a chat message: a bold title and a body
+type Notification struct {
+	Title string
+	Text  string
+	Code  bool // Body is preformatted (rendered in a monospace block)
+}
+
+// Notifier delivers notifications to one chat service
+type Notifier interface {
+	Name() string // Target name used in ALERT_TARGETS / SUMMARY_TARGETS
+	Notify(n Notification) error
+}
+
+// Notifiers fans notifications out to the configured chat services
+type Notifiers struct {
+	notifiers []Notifier
+}
+
+// NewNotifiers creates a notifier for every configured chat service
+func NewNotifiers(config *NotifyConfig) *Notifiers {
+	client := &http.Client{Timeout: config.Timeout}
+	n := &Notifiers{}
+	if config.TelegramToken != "" {
+		n.notifiers = append(n.notifiers, &telegramNotifier{
+			url:    strings.TrimSuffix(config.TelegramAPIURL, "/") + "/bot" + config.TelegramToken + "/sendMessage",
+			chatID: config.TelegramChatID,
+			client: client,
+		})
+	}
+	if config.SlackWebhookURL != "" {
+		n.notifiers = append(n.notifiers, &slackNotifier{url: config.SlackWebhookURL, client: client})
+	}
+	if config.DiscordWebhookURL != "" {
+		n.notifiers = append(n.notifiers, &discordNotifier{url: config.DiscordWebhookURL, client: client})
+	}
+
+	logInfo("Notify", "Chat notifiers initialized: %s", strings.Join(n.Names(), ", "))
+	return n
+}
+
+// Names returns the names of the configured notifiers (nil-safe)
+func (n *Notifiers) Names() []string {
+	if n == nil {
+		return nil
+	}
+	names := make([]string, len(n.notifiers))
+	for i, notifier := range n.notifiers {
+		names[i] = notifier.Name()
+	}
+	return names
+}
+
+// Send delivers a notification to the targets in the background (all notifiers if targets is empty)
+// Targets that are not chat services (e.g. "webhook") are ignored.
+func (n *Notifiers) Send(targets []string, notification Notification) {
+	if n == nil {
+		return
+	}
+	wanted := toSet(targets)
+	for _, notifier := range n.notifiers {
+		if len(wanted) > 0 && !wanted[notifier.Name()] {
+			continue
+		}
+		go func(notifier Notifier) {
+			if err := notifier.Notify(notification); err != nil {
+				logError("Notify", "%s: %s failed: %v", notifier.Name(), notification.Title, err)
+			}
+		}(notifier)
+	}
+}
+
+// telegramNotifier sends messages through the Telegram Bot API (HTML formatting)
+type telegramNotifier struct {
+	url    string
+	chatID string
+	client *http.Client
+}
+
+func (t *telegramNotifier) Name() string { return "telegram" }
+
+// Notify calls sendMessage; Telegram limits messages to 4096 characters
+func (t *telegramNotifier) Notify(n Notification) error {
+	text := html.EscapeString(truncateMessage(n.Text, 3800))
+	if n.Code {
+		text = "<pre>" + text + "</pre>"
+	}
+	return postNotification(t.client, t.url, map[string]interface{}{
+		"chat_id":                  t.chatID,
+		"text":                     "<b>" + html.EscapeString(n.Title) + "</b>\n" + text,
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	})
+}
+
+// slackNotifier posts to a Slack incoming webhook (mrkdwn formatting)
+type slackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (s *slackNotifier) Name() string { return "slack" }
+
+// Notify posts {"text": ...}; Slack truncates messages above 40000 characters
+func (s *slackNotifier) Notify(n Notification) error {
+	return postNotification(s.client, s.url, map[string]string{
+		"text": "*" + n.Title + "*\n" + markdownBody(truncateMessage(n.Text, 39000), n.Code),
+	})
+}
+
+// discordNotifier posts to a Discord webhook (markdown formatting)
+type discordNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (d *discordNotifier) Name() string { return "discord" }
+
+// Notify posts {"content": ...}; Discord rejects messages above 2000 characters
+func (d *discordNotifier) Notify(n Notification) error {
+	return postNotification(d.client, d.url, map[string]string{
+		"content": "**" + n.Title + "**\n" + markdownBody(truncateMessage(n.Text, 1800), n.Code),
+	})
+}
+
+// markdownBody wraps a preformatted body in a code block
+func markdownBody(text string, code bool) string {
+	if code {
+		return "```\n" + text + "\n```"
+	}
+	return text
+}
+
+// truncateMessage cuts a body to at most max bytes at a line boundary
+func truncateMessage(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	cut := strings.LastIndexByte(text[:max], '\n')
+	if cut <= 0 {
+		cut = max
+	}
+	return text[:cut] + "\n..."
+}
+
+// postNotification sends a JSON payload and reports the response body on failure
+func postNotification(client *http.Client, target string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mikrotik-interface-stats/"+Version)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The Telegram URL contains the bot token; don't log it
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// OpenTelemetry (OTLP) Exporter
+// ============================================================================
+//
+// Completed aggregation windows are exported as OTLP gauges in an
+// ExportMetricsServiceRequest: posted to <endpoint>/v1/metrics in the protobuf
+// (OTLP_PROTOCOL=http/protobuf) or JSON (http/json) encoding, or sent to the
+// MetricsService/Export gRPC method (grpc):
+//
+//   mikrotik.interface.{rx,tx}_rate.{avg,peak,min,stddev}  (By/s)
+//   mikrotik.interface.sample_count                          ({sample})
+//   mikrotik.interface.coverage                              (1, fraction of expected samples)
+//   mikrotik.interface.{rx,tx}_utilization                   (1, average rate / link speed)
+//
+// Data points carry the interface and interval attributes; router identity
+// (service.name, mikrotik.router.host, EXTRA_LABELS, OTLP_RESOURCE_ATTRIBUTES)
+// is attached as resource attributes.
+//
+// gRPC is spoken directly over HTTP/2 (h2c for http:// endpoints, TLS for
+// https://): one length-prefixed message per call, with the outcome in the
+// grpc-status trailer. Unary calls need nothing more than net/http offers.
+
+const otlpScopeName = "github.com/firadio/golang-mikrotik-interface-stats"
+
+// otlpGRPCMethod is the path of the OTLP metrics export gRPC method
+const otlpGRPCMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+
+// OTLPOutput implements OutputWriter by aggregating samples into time windows
+// and exporting each completed window to an OpenTelemetry Collector
+type OTLPOutput struct {
+	config     *OTLPConfig
+	httpClient *http.Client
+	aggregator *TimeWindowAggregator
+	flusher    *windowFlusher // Completes windows during outages and at shutdown
+	resource   []otlpKeyValue // Resource attributes (sorted by key)
+}
+
+// OTLP JSON types (subset of opentelemetry-proto, lowerCamelCase field names)
+type otlpExportRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpMetric struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Unit        string    `json:"unit,omitempty"`
+	Gauge       otlpGauge `json:"gauge"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"` // 64-bit integers are strings in proto3 JSON
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsDouble          *float64       `json:"asDouble,omitempty"`
+	AsInt             string         `json:"asInt,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// NewOTLPOutput creates an OTLP exporter
+// routerHost and extraLabels identify the router in the resource attributes; sampleInterval is the poll interval
+func NewOTLPOutput(config *OTLPConfig, sampleInterval time.Duration, routerHost string, extraLabels map[string]string) *OTLPOutput {
+	logInfo("OTLP", "Exporter initialized (endpoint: %s, interval: %v)", config.Endpoint, config.Interval)
+
+	// Later sources override earlier ones
+	attrs := map[string]string{
+		"service.name":         "mikrotik-interface-stats",
+		"service.version":      Version,
+		"mikrotik.router.host": routerHost,
+	}
+	for k, v := range extraLabels {
+		attrs[k] = v
+	}
+	for k, v := range config.ResourceAttributes {
+		attrs[k] = v
+	}
+
+	httpClient := &http.Client{Timeout: config.Timeout}
+	if config.Protocol == "grpc" {
+		// gRPC needs HTTP/2, without TLS (prior knowledge) for http:// endpoints
+		var protocols http.Protocols
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		httpClient.Transport = &http.Transport{Proxy: http.ProxyFromEnvironment, Protocols: &protocols}
+	}
+
+	o := &OTLPOutput{
+		config:     config,
+		httpClient: httpClient,
+		aggregator: NewTimeWindowAggregator(config.Interval, sampleInterval),
+		resource:   otlpAttributes(attrs),
+	}
+	o.flusher = newWindowFlusher(o.aggregator, o.send)
+	return o
+}
+
+func (o *OTLPOutput) WriteHeader() {}
+
+func (o *OTLPOutput) WriteStats(timestamp time.Time, stats map[string]*RateInfo) {
+	for _, rateInfo := range stats {
+		o.aggregator.AddSample(timestamp, rateInfo)
+	}
+
+	o.flusher.sendCompleted()
+}
+
+// send exports one completed window
+func (o *OTLPOutput) send(window *AggregationWindow) {
+	if err := o.export(window); err != nil {
+		logError("OTLP", "Failed to export window ending %s: %v", window.EndTime.Format("15:04:05"), err)
+	}
+}
+
+// Close exports the last (partial) window
+func (o *OTLPOutput) Close() {
+	o.flusher.Close()
+}
+
+// SetSampleInterval follows poll interval changes (see sampleIntervalSetter)
+func (o *OTLPOutput) SetSampleInterval(now time.Time, sampleInterval time.Duration) {
+	o.aggregator.SetSampleInterval(now, sampleInterval)
+}
+
+// export sends one aggregation window to the collector
+func (o *OTLPOutput) export(window *AggregationWindow) error {
+	if len(window.Interfaces) == 0 {
+		return nil
+	}
+
+	request := o.buildRequest(window)
+	if o.config.Protocol == "grpc" {
+		return o.exportGRPC(request.marshalProto())
+	}
+
+	contentType := "application/x-protobuf"
+	var body []byte
+	if o.config.Protocol == "http/json" {
+		var err error
+		if body, err = json.Marshal(request); err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		contentType = "application/json"
+	} else {
+		body = request.marshalProto()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(o.config.Endpoint, "/")+"/v1/metrics", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range o.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// exportGRPC calls MetricsService/Export with an encoded ExportMetricsServiceRequest
+func (o *OTLPOutput) exportGRPC(message []byte) error {
+	// Length-prefixed message: uncompressed flag, big-endian length
+	body := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(message)))
+	body = append(body, message...)
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(o.config.Endpoint, "/")+otlpGRPCMethod, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("Grpc-Timeout", fmt.Sprintf("%dm", o.config.Timeout.Milliseconds()))
+	for k, v := range o.config.Headers {
+		req.Header.Set(k, v) // Sent as gRPC metadata
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	// The status follows the response message (an ExportMetricsServiceResponse, ignored)
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return err
+	}
+
+	// A failed call may send the status in the headers only
+	status, statusMessage := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, statusMessage = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	switch status {
+	case "0":
+		return nil
+	case "":
+		return fmt.Errorf("collector response has no grpc-status")
+	default:
+		if decoded, err := url.PathUnescape(statusMessage); err == nil {
+			statusMessage = decoded
+		}
+		return fmt.Errorf("collector returned gRPC status %s: %s", status, statusMessage)
+	}
+}
+
+// buildRequest converts an aggregation window to an ExportMetricsServiceRequest
+func (o *OTLPOutput) buildRequest(window *AggregationWindow) *otlpExportRequest {
+	start := strconv.FormatInt(window.StartTime.UnixNano(), 10)
+	end := strconv.FormatInt(window.EndTime.UnixNano(), 10)
+	interval := fmt.Sprintf("%ds", int(window.Interval.Seconds()))
+
+	names := make([]string, 0, len(window.Interfaces))
+	for name, stats := range window.Interfaces {
+		if stats.Count > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	// Metric name -> data points (one per interface)
+	rates := []struct {
+		name  string
+		value func(*WindowStats) float64
+	}{
+		{"mikrotik.interface.rx_rate.avg", func(s *WindowStats) float64 { return s.RxSum / float64(s.Count) }},
+		{"mikrotik.interface.rx_rate.peak", func(s *WindowStats) float64 { return s.RxPeak }},
+		{"mikrotik.interface.rx_rate.min", func(s *WindowStats) float64 { return s.RxMin }},
+		{"mikrotik.interface.rx_rate.stddev", func(s *WindowStats) float64 { return stddev(s.RxSum, s.RxSumSq, s.Count) }},
+		{"mikrotik.interface.tx_rate.avg", func(s *WindowStats) float64 { return s.TxSum / float64(s.Count) }},
+		{"mikrotik.interface.tx_rate.peak", func(s *WindowStats) float64 { return s.TxPeak }},
+		{"mikrotik.interface.tx_rate.min", func(s *WindowStats) float64 { return s.TxMin }},
+		{"mikrotik.interface.tx_rate.stddev", func(s *WindowStats) float64 { return stddev(s.TxSum, s.TxSumSq, s.Count) }},
+	}
+
+	metrics := make([]otlpMetric, 0, len(rates)+2)
+	for _, rate := range rates {
+		metric := otlpMetric{Name: rate.name, Unit: "By/s"}
+		for _, name := range names {
+			value := rate.value(window.Interfaces[name])
+			metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, otlpDataPoint{
+				Attributes:        otlpAttributes(map[string]string{"interface": name, "interval": interval}),
+				StartTimeUnixNano: start,
+				TimeUnixNano:      end,
+				AsDouble:          &value,
+			})
+		}
+		metrics = append(metrics, metric)
+	}
+
+	count := otlpMetric{Name: "mikrotik.interface.sample_count", Unit: "{sample}"}
+	for _, name := range names {
+		count.Gauge.DataPoints = append(count.Gauge.DataPoints, otlpDataPoint{
+			Attributes:        otlpAttributes(map[string]string{"interface": name, "interval": interval}),
+			StartTimeUnixNano: start,
+			TimeUnixNano:      end,
+			AsInt:             strconv.Itoa(window.Interfaces[name].Count),
+		})
+	}
+	metrics = append(metrics, count)
+
+	coverage := otlpMetric{Name: "mikrotik.interface.coverage", Unit: "1"}
+	for _, name := range names {
+		value := window.Coverage(window.Interfaces[name])
+		coverage.Gauge.DataPoints = append(coverage.Gauge.DataPoints, otlpDataPoint{
+			Attributes:        otlpAttributes(map[string]string{"interface": name, "interval": interval}),
+			StartTimeUnixNano: start,
+			TimeUnixNano:      end,
+			AsDouble:          &value,
+		})
+	}
+	metrics = append(metrics, coverage)
+
+	// Utilization only for interfaces with a known link speed
+	for _, dir := range []struct {
+		name string
+		avg  func(*WindowStats) float64
+	}{
+		{"mikrotik.interface.rx_utilization", func(s *WindowStats) float64 { return s.RxSum / float64(s.Count) }},
+		{"mikrotik.interface.tx_utilization", func(s *WindowStats) float64 { return s.TxSum / float64(s.Count) }},
+	} {
+		metric := otlpMetric{Name: dir.name, Unit: "1"}
+		for _, name := range names {
+			stats := window.Interfaces[name]
+			if stats.LinkSpeed <= 0 {
+				continue
+			}
+			value := utilization(dir.avg(stats), stats.LinkSpeed)
+			metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, otlpDataPoint{
+				Attributes:        otlpAttributes(map[string]string{"interface": name, "interval": interval}),
+				StartTimeUnixNano: start,
+				TimeUnixNano:      end,
+				AsDouble:          &value,
+			})
+		}
+		if len(metric.Gauge.DataPoints) > 0 {
+			metrics = append(metrics, metric)
+		}
+	}
+
+	return &otlpExportRequest{
+		ResourceMetrics: []otlpResourceMetrics{{
+			Resource: otlpResource{Attributes: o.resource},
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpScope{Name: otlpScopeName, Version: Version},
+				Metrics: metrics,
+			}},
+		}},
+	}
+}
+
+// otlpAttributes converts a map to string attributes sorted by key
+func otlpAttributes(attrs map[string]string) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: otlpAnyValue{StringValue: attrs[k]}})
+	}
+	return kvs
+}
+
+// ============================================================================
+// OTLP protobuf encoding
+// ============================================================================
+//
+// The JSON types above are encoded with the field numbers of opentelemetry-proto
+// (collector/metrics/v1, metrics/v1, common/v1, resource/v1):
+//
+//   ExportMetricsServiceRequest { repeated ResourceMetrics resource_metrics = 1; }
+//   ResourceMetrics      { Resource resource = 1; repeated ScopeMetrics scope_metrics = 2; }
+//   Resource             { repeated KeyValue attributes = 1; }
+//   ScopeMetrics         { InstrumentationScope scope = 1; repeated Metric metrics = 2; }
+//   InstrumentationScope { string name = 1; string version = 2; }
+//   Metric               { string name = 1; string description = 2; string unit = 3; Gauge gauge = 5; }
+//   Gauge                { repeated NumberDataPoint data_points = 1; }
+//   NumberDataPoint      { fixed64 start_time_unix_nano = 2; fixed64 time_unix_nano = 3;
+//                          double as_double = 4; sfixed64 as_int = 6; repeated KeyValue attributes = 7; }
+//   KeyValue             { string key = 1; AnyValue value = 2; }
+//   AnyValue             { string string_value = 1; }
+
+// marshalProto encodes the request as a protobuf ExportMetricsServiceRequest
+func (r *otlpExportRequest) marshalProto() []byte {
+	var buf []byte
+	for _, rm := range r.ResourceMetrics {
+		var resource []byte
+		for _, kv := range rm.Resource.Attributes {
+			resource = appendProtoBytes(resource, 1, kv.marshalProto())
+		}
+		msg := appendProtoBytes(nil, 1, resource)
+
+		for _, sm := range rm.ScopeMetrics {
+			var scope []byte
+			scope = appendProtoString(scope, 1, sm.Scope.Name)
+			scope = appendProtoString(scope, 2, sm.Scope.Version)
+			scopeMetrics := appendProtoBytes(nil, 1, scope)
+			for _, metric := range sm.Metrics {
+				scopeMetrics = appendProtoBytes(scopeMetrics, 2, metric.marshalProto())
+			}
+			msg = appendProtoBytes(msg, 2, scopeMetrics)
+		}
+		buf = appendProtoBytes(buf, 1, msg)
+	}
+	return buf
+}
+
+func (m *otlpMetric) marshalProto() []byte {
+	var buf []byte
+	buf = appendProtoString(buf, 1, m.Name)
+	buf = appendProtoString(buf, 2, m.Description)
+	buf = appendProtoString(buf, 3, m.Unit)
+
+	var gauge []byte
+	for _, dp := range m.Gauge.DataPoints {
+		gauge = appendProtoBytes(gauge, 1, dp.marshalProto())
+	}
+	return appendProtoBytes(buf, 5, gauge)
+}
+
+// marshalProto encodes a data point (the JSON form keeps 64-bit integers as strings)
+func (dp *otlpDataPoint) marshalProto() []byte {
+	var buf []byte
+	start, _ := strconv.ParseUint(dp.StartTimeUnixNano, 10, 64)
+	end, _ := strconv.ParseUint(dp.TimeUnixNano, 10, 64)
+	buf = appendProtoFixed64(buf, 2, start)
+	buf = appendProtoFixed64(buf, 3, end)
+	// The value is a oneof, so it is written even when zero
+	if dp.AsDouble != nil {
+		buf = appendProtoFixed64(buf, 4, math.Float64bits(*dp.AsDouble))
+	}
+	if dp.AsInt != "" {
+		value, _ := strconv.ParseInt(dp.AsInt, 10, 64)
+		buf = appendProtoFixed64(buf, 6, uint64(value))
+	}
+	for _, kv := range dp.Attributes {
+		buf = appendProtoBytes(buf, 7, kv.marshalProto())
+	}
+	return buf
+}
+
+func (kv *otlpKeyValue) marshalProto() []byte {
+	buf := appendProtoString(nil, 1, kv.Key)
+	return appendProtoBytes(buf, 2, appendProtoString(nil, 1, kv.Value.StringValue))
+}
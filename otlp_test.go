@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// testOTLPWindow returns a 10s window with one interface: rates 100/300 RX and 50/50 TX on a 1 Gbit/s link
+func testOTLPWindow() *AggregationWindow {
+	start := time.Unix(1700000000, 0)
+	return &AggregationWindow{
+		StartTime: start,
+		EndTime:   start.Add(10 * time.Second),
+		Interval:  10 * time.Second,
+		Expected:  10,
+		Interfaces: map[string]*WindowStats{
+			"ether1": {RxSum: 400, TxSum: 100, RxPeak: 300, TxPeak: 50, RxMin: 100, TxMin: 50,
+				RxSumSq: 100000, TxSumSq: 5000, Count: 2, LinkSpeed: 1e9},
+		},
+	}
+}
+
+// protoStrings returns the string fields of a message by field number
+func protoStrings(t *testing.T, msg []byte) map[protowire.Number]string {
+	t.Helper()
+	strings := make(map[protowire.Number]string)
+	for _, field := range decodeProto(t, msg) {
+		if field.typ == protowire.BytesType {
+			strings[field.num] = string(field.bytes)
+		}
+	}
+	return strings
+}
+
+// protoKeyValues decodes repeated KeyValue fields with string values
+func protoKeyValues(t *testing.T, fields []protoField, num protowire.Number) map[string]string {
+	t.Helper()
+	attrs := make(map[string]string)
+	for _, field := range fields {
+		if field.num != num {
+			continue
+		}
+		kv := decodeProto(t, field.bytes)
+		if len(kv) != 2 || kv[0].num != 1 || kv[1].num != 2 {
+			t.Fatalf("KeyValue fields %+v, want key (1) and value (2)", kv)
+		}
+		attrs[string(kv[0].bytes)] = protoStrings(t, kv[1].bytes)[1]
+	}
+	return attrs
+}
+
+func TestOTLPProtobufEncoding(t *testing.T) {
+	output := NewOTLPOutput(&OTLPConfig{Protocol: "http/protobuf", Interval: 10 * time.Second}, time.Second, "router", map[string]string{"site": "hq"})
+	defer output.Close()
+	window := testOTLPWindow()
+
+	request := decodeProto(t, output.buildRequest(window).marshalProto())
+	if len(request) != 1 || request[0].num != 1 {
+		t.Fatalf("request fields %+v, want one resource_metrics (1)", request)
+	}
+	resourceMetrics := decodeProto(t, request[0].bytes)
+	if len(resourceMetrics) != 2 || resourceMetrics[0].num != 1 || resourceMetrics[1].num != 2 {
+		t.Fatalf("ResourceMetrics fields %+v, want resource (1) and scope_metrics (2)", resourceMetrics)
+	}
+
+	resource := protoKeyValues(t, decodeProto(t, resourceMetrics[0].bytes), 1)
+	if resource["mikrotik.router.host"] != "router" || resource["site"] != "hq" || resource["service.name"] != "mikrotik-interface-stats" {
+		t.Errorf("resource attributes %v", resource)
+	}
+
+	scopeMetrics := decodeProto(t, resourceMetrics[1].bytes)
+	if scope := protoStrings(t, scopeMetrics[0].bytes); scopeMetrics[0].num != 1 || scope[1] != otlpScopeName || scope[2] != Version {
+		t.Errorf("scope %v, want name %q and version %q", scope, otlpScopeName, Version)
+	}
+
+	// Metric name -> value of its only data point (as_double, or as_int as float)
+	values := make(map[string]float64)
+	for _, field := range scopeMetrics[1:] {
+		if field.num != 2 {
+			t.Fatalf("unexpected ScopeMetrics field %d", field.num)
+		}
+		metric := decodeProto(t, field.bytes)
+		names := protoStrings(t, field.bytes)
+		last := metric[len(metric)-1]
+		if last.num != 5 {
+			t.Fatalf("%s: last field %d, want gauge (5)", names[1], last.num)
+		}
+		gauge := decodeProto(t, last.bytes)
+		if len(gauge) != 1 || gauge[0].num != 1 {
+			t.Fatalf("%s: gauge fields %+v, want one data point", names[1], gauge)
+		}
+
+		point := decodeProto(t, gauge[0].bytes)
+		times := make(map[protowire.Number]uint64)
+		for _, f := range point {
+			switch {
+			case f.num == 2 || f.num == 3:
+				if f.typ != protowire.Fixed64Type {
+					t.Fatalf("%s: time field %d has wire type %d, want fixed64", names[1], f.num, f.typ)
+				}
+				times[f.num] = f.value
+			case f.num == 4:
+				values[names[1]] = math.Float64frombits(f.value)
+			case f.num == 6:
+				values[names[1]] = float64(int64(f.value))
+			}
+		}
+		if times[2] != uint64(window.StartTime.UnixNano()) || times[3] != uint64(window.EndTime.UnixNano()) {
+			t.Errorf("%s: times %v, want start %d and end %d", names[1], times, window.StartTime.UnixNano(), window.EndTime.UnixNano())
+		}
+		if attrs := protoKeyValues(t, point, 7); attrs["interface"] != "ether1" || attrs["interval"] != "10s" {
+			t.Errorf("%s: data point attributes %v", names[1], attrs)
+		}
+	}
+
+	want := map[string]float64{
+		"mikrotik.interface.rx_rate.avg":    200,
+		"mikrotik.interface.rx_rate.peak":   300,
+		"mikrotik.interface.tx_rate.min":    50,
+		"mikrotik.interface.tx_rate.stddev": 0,
+		"mikrotik.interface.sample_count":   2,
+		"mikrotik.interface.coverage":       0.2,
+		"mikrotik.interface.rx_utilization": 200 * 8 / 1e9,
+	}
+	for name, value := range want {
+		got, ok := values[name]
+		if !ok {
+			t.Errorf("%s: missing (zero values must still be encoded)", name)
+		} else if math.Abs(got-value) > 1e-12 {
+			t.Errorf("%s = %v, want %v", name, got, value)
+		}
+	}
+}
+
+func TestOTLPExportProtocols(t *testing.T) {
+	for _, tt := range []struct {
+		protocol    string
+		contentType string
+	}{
+		{"http/protobuf", "application/x-protobuf"},
+		{"http/json", "application/json"},
+	} {
+		t.Run(tt.protocol, func(t *testing.T) {
+			var contentType, path string
+			var body []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType, path = r.Header.Get("Content-Type"), r.URL.Path
+				body, _ = io.ReadAll(r.Body)
+			}))
+			defer server.Close()
+
+			config := &OTLPConfig{Endpoint: server.URL + "/", Protocol: tt.protocol, Interval: 10 * time.Second, Timeout: time.Second}
+			output := NewOTLPOutput(config, time.Second, "router", nil)
+			defer output.Close()
+			if err := output.export(testOTLPWindow()); err != nil {
+				t.Fatal(err)
+			}
+
+			if path != "/v1/metrics" || contentType != tt.contentType {
+				t.Errorf("posted %s to %s, want %s to /v1/metrics", contentType, path, tt.contentType)
+			}
+			if tt.protocol == "http/json" && !json.Valid(body) {
+				t.Errorf("invalid JSON body: %s", body)
+			}
+			if tt.protocol == "http/protobuf" {
+				decodeProto(t, body)
+			}
+		})
+	}
+}
+
+func TestOTLPExportGRPC(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(w http.ResponseWriter)
+		wantErr string
+	}{
+		{"ok", func(w http.ResponseWriter) {
+			w.Header().Set("Trailer", "Grpc-Status")
+			w.Write([]byte{0, 0, 0, 0, 0}) // Empty ExportMetricsServiceResponse
+			w.Header().Set("Grpc-Status", "0")
+		}, ""},
+		{"error in trailers", func(w http.ResponseWriter) {
+			w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+			w.WriteHeader(http.StatusOK)
+			w.Header().Set("Grpc-Status", "8")
+			w.Header().Set("Grpc-Message", "queue%20is%20full")
+		}, "gRPC status 8: queue is full"},
+		{"trailers only", func(w http.ResponseWriter) {
+			w.Header().Set("Grpc-Status", "16")
+			w.Header().Set("Grpc-Message", "missing token")
+			w.WriteHeader(http.StatusOK)
+		}, "gRPC status 16: missing token"},
+		{"no status", func(w http.ResponseWriter) {}, "no grpc-status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var proto, path, contentType, te, auth string
+			var body []byte
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				proto, path, contentType, te, auth = r.Proto, r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("TE"), r.Header.Get("Authorization")
+				body, _ = io.ReadAll(r.Body)
+				tt.respond(w)
+			}))
+			server.Config.Protocols = new(http.Protocols)
+			server.Config.Protocols.SetUnencryptedHTTP2(true)
+			server.Start()
+			defer server.Close()
+
+			config := &OTLPConfig{Endpoint: server.URL, Protocol: "grpc", Interval: 10 * time.Second, Timeout: time.Second,
+				Headers: map[string]string{"Authorization": "Bearer secret"}}
+			output := NewOTLPOutput(config, time.Second, "router", nil)
+			defer output.Close()
+			err := output.export(testOTLPWindow())
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("export = %v, want an error containing %q", err, tt.wantErr)
+			}
+
+			if proto != "HTTP/2.0" || path != otlpGRPCMethod || contentType != "application/grpc" || te != "trailers" {
+				t.Errorf("%s POST %s (Content-Type %q, TE %q), want HTTP/2.0 POST %s (application/grpc, trailers)",
+					proto, path, contentType, te, otlpGRPCMethod)
+			}
+			if auth != "Bearer secret" {
+				t.Errorf("Authorization metadata %q, want the configured header", auth)
+			}
+			if len(body) < 5 || body[0] != 0 || int(binary.BigEndian.Uint32(body[1:])) != len(body)-5 {
+				t.Fatalf("body is not one uncompressed length-prefixed message: % x", body[:min(len(body), 5)])
+			}
+			decodeProto(t, body[5:])
+		})
+	}
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"math"
 	"os"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -81,13 +85,6 @@ func clearScreen() {
 	fmt.Print("\033[2J\033[H")
 }
 
-// moveCursorHome moves cursor to top-left without clearing screen
-// \033[H - move cursor to home position (1,1)
-// More efficient than clearScreen, reduces flicker
-func moveCursorHome() {
-	fmt.Print("\033[H")
-}
-
 // ============================================================================
 // Output Interface
 // ============================================================================
@@ -95,9 +92,9 @@ func moveCursorHome() {
 // OutputWriter defines the interface for output implementations
 // Allows multiple output formats (terminal, log, metrics, etc.)
 type OutputWriter interface {
-	WriteHeader()                                          // Initialize output (print headers, etc.)
+	WriteHeader()                                               // Initialize output (print headers, etc.)
 	WriteStats(timestamp time.Time, stats map[string]*RateInfo) // Write statistics
-	Close()                                                // Cleanup resources
+	Close()                                                     // Cleanup resources
 }
 
 // RateInfo holds calculated rate information for an interface
@@ -111,6 +108,21 @@ type RateInfo struct {
 	TxAvg         float64 // Average TX rate over stats window
 	RxPeak        float64 // Peak RX rate over stats window
 	TxPeak        float64 // Peak TX rate over stats window
+	RxMin         float64 // Minimum RX rate over stats window
+	TxMin         float64 // Minimum TX rate over stats window
+	RxStdDev      float64 // RX rate standard deviation over stats window
+	TxStdDev      float64 // TX rate standard deviation over stats window
+	RxBytes       uint64  // Raw RX counter (total bytes, as reported by the router)
+	TxBytes       uint64  // Raw TX counter (total bytes, as reported by the router)
+	LinkSpeed     float64 // Link speed (bits/s, 0 if unknown)
+	RxUtilization float64 // RX rate as a fraction of the link speed (0 if unknown)
+	TxUtilization float64 // TX rate as a fraction of the link speed (0 if unknown)
+
+	State *InterfaceState // Link state (nil if unknown)
+
+	// Rates over the stats window, oldest first (terminal refresh mode only, for sparklines)
+	RxHistory []float64
+	TxHistory []float64
 }
 
 // ============================================================================
@@ -118,28 +130,90 @@ type RateInfo struct {
 // ============================================================================
 
 // TerminalOutput implements OutputWriter for terminal display
+// Refresh mode is an interactive TUI (see terminal.go); append mode prints one line per sample
 type TerminalOutput struct {
-	refreshMode      bool            // true = refresh mode (like top), false = append mode (like tail -f)
-	rateUnit         string          // "bps" or "Bps"
-	rateScale        string          // "auto", "k", "M", "G"
-	uplinkInterfaces map[string]bool // Set of uplink interface names for RX/TX swapping
-	statsWindowSize  int             // Statistics window size in seconds
+	refreshMode     bool               // true = refresh mode (like top), false = append mode (like tail -f)
+	rateUnit        string             // "bps" or "Bps"
+	rateScale       string             // "auto", "k", "M", "G"
+	extraStats      bool               // Show min/stddev columns
+	highlightRate   float64            // Highlight interfaces at or above this rate (bytes/s, 0 = off)
+	colors          bool               // Color rate cells by colorWarn/colorCrit (refresh mode)
+	colorWarn       colorThreshold     // Yellow from here
+	colorCrit       colorThreshold     // Red from here
+	sparklines      bool               // Show upload/download mini-graphs
+	columns         []int              // Indexes into terminalColumns, in display order
+	nameWidth       int                // Interface name column width (0 = fit longest name)
+	showTotal       bool               // Show the TOTAL row
+	groups          map[string]bool    // Virtual group interfaces (excluded from TOTAL)
+	userConfig      *UserConfigManager // Uplink classification for RX/TX swapping
+	percentile      *PercentileTracker // Percentile summary (nil if disabled)
+	statsWindowSize int                // Statistics window size in seconds
+	smoother        *rateSmoother      // Smoothed current rates (nil = measured rates)
+
+	// Refresh mode view state (changed by key presses)
+	lastStats    map[string]*RateInfo // Stats on screen (redrawn on key press)
+	lastTime     time.Time
+	sortColumn   int  // Index into terminalColumns, or sortByName
+	sortDesc     bool // Descending order
+	paused       bool // Keep the current screen
+	offset       int  // First visible row when scrolling
+	pageSize     int  // Visible rows
+	showHelp     bool
+	interactive  bool   // Keyboard controls active
+	restoreInput func() // Restores the terminal input mode
+	mu           sync.Mutex
 }
 
 // NewTerminalOutput creates a new terminal output handler
-func NewTerminalOutput(refreshMode bool, rateUnit, rateScale string, uplinkInterfaces []string, statsWindowSize int) *TerminalOutput {
-	// Convert uplink interface list to set for O(1) lookup
-	uplinkSet := make(map[string]bool, len(uplinkInterfaces))
-	for _, iface := range uplinkInterfaces {
-		uplinkSet[iface] = true
+func NewTerminalOutput(config *TerminalConfig, groups []InterfaceGroup, userConfig *UserConfigManager, percentile *PercentileTracker, statsWindowSize int) *TerminalOutput {
+	t := &TerminalOutput{
+		refreshMode: config.Mode == "refresh",
+		userConfig:  userConfig,
+		percentile:  percentile,
 	}
+	t.configure(config, groups, statsWindowSize)
+	return t
+}
+
+// Reconfigure applies new display settings in place (configuration reload)
+// The view state and keyboard controls are kept; the mode cannot change.
+func (t *TerminalOutput) Reconfigure(config *TerminalConfig, groups []InterfaceGroup, statsWindowSize int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.configure(config, groups, statsWindowSize)
+}
 
-	return &TerminalOutput{
-		refreshMode:      refreshMode,
-		rateUnit:         rateUnit,
-		rateScale:        rateScale,
-		uplinkInterfaces: uplinkSet,
-		statsWindowSize:  statsWindowSize,
+// configure sets the display settings from the configuration
+func (t *TerminalOutput) configure(config *TerminalConfig, groups []InterfaceGroup, statsWindowSize int) {
+	t.rateUnit = config.RateUnit
+	t.rateScale = config.RateScale
+	t.extraStats = config.ExtraStats
+	t.highlightRate = config.HighlightRate
+	t.colorWarn, _ = parseColorThreshold(config.ColorWarn) // Validated with the config
+	t.colorCrit, _ = parseColorThreshold(config.ColorCrit)
+	t.colors = terminalColors(config.Color) && (t.colorWarn.set() || t.colorCrit.set())
+	t.sparklines = config.Sparklines
+	t.nameWidth = config.NameWidth
+	t.showTotal = config.Total
+	t.statsWindowSize = statsWindowSize
+	t.smoother = newRateSmoother(config.Smoothing)
+	t.sortColumn, t.sortDesc, _ = parseTerminalSort(config.Sort)
+	t.groups = make(map[string]bool, len(groups))
+	for _, group := range groups {
+		t.groups[group.Name] = true
+	}
+
+	// Explicitly listed columns are shown as configured, including min/stddev
+	columns := defaultTerminalColumns
+	if len(config.Columns) > 0 {
+		columns = config.Columns
+		t.extraStats = true
+	}
+	t.columns = nil
+	for _, key := range columns {
+		if i := findTerminalColumn(key); i >= 0 {
+			t.columns = append(t.columns, i)
+		}
 	}
 }
 
@@ -149,6 +223,7 @@ func (t *TerminalOutput) WriteHeader() {
 		fmt.Println("Mikrotik Interface Traffic Monitor")
 		fmt.Println(strings.Repeat("=", 80))
 		fmt.Println("Initializing...")
+		t.startKeyboard()
 	} else {
 		fmt.Println("\nMonitoring interface traffic (Ctrl+C to stop):")
 		fmt.Println(strings.Repeat("=", 80))
@@ -156,154 +231,16 @@ func (t *TerminalOutput) WriteHeader() {
 }
 
 func (t *TerminalOutput) WriteStats(timestamp time.Time, stats map[string]*RateInfo) {
-	timeStr := timestamp.Format("2006-01-02 15:04:05")
-
-	// Sort interface names for consistent ordering
-	names := make([]string, 0, len(stats))
-	for name := range stats {
-		names = append(names, name)
-	}
-	sort.Strings(names)
-
 	if t.refreshMode {
-		// Refresh mode: move cursor to home and overwrite
-		// Use moveCursorHome instead of clearScreen to reduce flicker
-		moveCursorHome()
-		fmt.Println("Mikrotik Interface Traffic Monitor")
-		fmt.Println(strings.Repeat("=", 80))
-
-		// Display Time, Unit and Window size on one line
-		unitSuffix := getUnitSuffix(t.rateUnit, t.rateScale)
-		fmt.Printf("Time: %s | Unit: %s | Window: %ds\n", timeStr, unitSuffix, t.statsWindowSize)
-
-		fmt.Println(strings.Repeat("-", 80))
-		// Header: 10+10+10+10+10+10+10 = 70 chars (留10字符余量)
-		// Fixed column headers
-		fmt.Printf("%-10s %10s %10s %10s %10s %10s %10s\n",
-			"Interface", "Up", "Down", "UpAvg", "DnAvg", "UpPeak", "DnPeak")
-		fmt.Println(strings.Repeat("-", 80))
-
-		for _, name := range names {
-			info := stats[name]
-			var downloadRate, uploadRate, uploadAvg, downloadAvg, uploadPeak, downloadPeak float64
-
-			// Convert RX/TX to Upload/Download based on interface type
-			//
-			// Uplink (WAN to ISP):
-			//   - TX = Upload to internet
-			//   - RX = Download from internet
-			//   - No swap needed (matches user expectation)
-			//
-			// Downlink (LAN/VLAN to users):
-			//   - TX = Download (router sends to user)
-			//   - RX = Upload (router receives from user)
-			//   - Swap needed for user perspective
-			if t.uplinkInterfaces[name] {
-				// Uplink: no swap
-				uploadRate = info.TxRate
-				downloadRate = info.RxRate
-				uploadAvg = info.TxAvg
-				downloadAvg = info.RxAvg
-				uploadPeak = info.TxPeak
-				downloadPeak = info.RxPeak
-			} else {
-				// Downlink: swap TX/RX
-				uploadRate = info.RxRate
-				downloadRate = info.TxRate
-				uploadAvg = info.RxAvg
-				downloadAvg = info.TxAvg
-				uploadPeak = info.RxPeak
-				downloadPeak = info.TxPeak
-			}
-
-			// Format rates as numeric values only (no unit suffix)
-			uploadStr := formatNumeric(uploadRate, t.rateUnit, t.rateScale)
-			downloadStr := formatNumeric(downloadRate, t.rateUnit, t.rateScale)
-			uploadAvgStr := formatNumeric(uploadAvg, t.rateUnit, t.rateScale)
-			downloadAvgStr := formatNumeric(downloadAvg, t.rateUnit, t.rateScale)
-			uploadPeakStr := formatNumeric(uploadPeak, t.rateUnit, t.rateScale)
-			downloadPeakStr := formatNumeric(downloadPeak, t.rateUnit, t.rateScale)
-
-			// Truncate interface name if needed
-			ifName := info.InterfaceName
-			if len(ifName) > 10 {
-				ifName = ifName[:10]
-			}
-
-			// Left-align interface name, right-align all numeric values
-			fmt.Printf("%-10s %10s %10s %10s %10s %10s %10s\n",
-				ifName, uploadStr, downloadStr, uploadAvgStr, downloadAvgStr, uploadPeakStr, downloadPeakStr)
-		}
-
-		fmt.Println(strings.Repeat("-", 80))
-		fmt.Println("Press Ctrl+C to stop")
-		// Clear any remaining lines from previous output (if interface count decreased)
-		fmt.Print("\033[J")
-	} else {
-		// Append mode: add new lines
-		for _, name := range names {
-			info := stats[name]
-			var downloadRate, uploadRate float64
-
-			// Check if this is an uplink interface
-			if t.uplinkInterfaces[name] {
-				// Uplink (WAN to ISP): TX=Upload (to internet), RX=Download (from internet)
-				// This is the "normal" understanding, no swap needed
-				downloadRate = info.RxRate
-				uploadRate = info.TxRate
-			} else {
-				// Downlink (to users/LAN): TX=Download (data to user), RX=Upload (data from user)
-				// From user perspective, needs swap
-				downloadRate = info.TxRate
-				uploadRate = info.RxRate
-			}
-
-			downloadFormatted := FormatRate(downloadRate, t.rateUnit, t.rateScale)
-			uploadFormatted := FormatRate(uploadRate, t.rateUnit, t.rateScale)
-			fmt.Printf("[%s] %s: Upload: %s  Download: %s\n",
-				timeStr, info.InterfaceName, uploadFormatted, downloadFormatted)
-		}
+		t.writeRefresh(timestamp, stats)
+		return
 	}
-}
-
-func (t *TerminalOutput) Close() {
-	// Nothing to close for terminal output
-}
 
-// ============================================================================
-// Log Output (for services/daemons)
-// ============================================================================
-
-// LogOutput implements OutputWriter for structured logging
-// Suitable for running as a service or daemon
-type LogOutput struct {
-	rateUnit         string          // "bps" or "Bps"
-	rateScale        string          // "auto", "k", "M", "G"
-	uplinkInterfaces map[string]bool // Set of uplink interface names for RX/TX swapping
-	statsWindowSize  int             // Statistics window size (unused in log mode)
-}
-
-// NewLogOutput creates a new log output handler
-func NewLogOutput(rateUnit, rateScale string, uplinkInterfaces []string, statsWindowSize int) *LogOutput {
-	// Build uplink interface set for fast lookup
-	uplinkSet := make(map[string]bool)
-	for _, iface := range uplinkInterfaces {
-		uplinkSet[iface] = true
-	}
-
-	return &LogOutput{
-		rateUnit:         rateUnit,
-		rateScale:        rateScale,
-		uplinkInterfaces: uplinkSet,
-		statsWindowSize:  statsWindowSize,
-	}
-}
-
-func (l *LogOutput) WriteHeader() {
-	log.Println("Mikrotik Interface Traffic Monitor started")
-}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats = t.smoother.apply(stats)
+	timeStr := timestamp.Format("2006-01-02 15:04:05")
 
-func (l *LogOutput) WriteStats(timestamp time.Time, stats map[string]*RateInfo) {
 	// Sort interface names for consistent ordering
 	names := make([]string, 0, len(stats))
 	for name := range stats {
@@ -311,12 +248,13 @@ func (l *LogOutput) WriteStats(timestamp time.Time, stats map[string]*RateInfo)
 	}
 	sort.Strings(names)
 
+	// Append mode: add new lines
 	for _, name := range names {
 		info := stats[name]
 		var downloadRate, uploadRate float64
 
 		// Check if this is an uplink interface
-		if l.uplinkInterfaces[name] {
+		if t.userConfig.IsUplink(name) {
 			// Uplink (WAN to ISP): TX=Upload (to internet), RX=Download (from internet)
 			// This is the "normal" understanding, no swap needed
 			downloadRate = info.RxRate
@@ -328,14 +266,15 @@ func (l *LogOutput) WriteStats(timestamp time.Time, stats map[string]*RateInfo)
 			uploadRate = info.RxRate
 		}
 
-		downloadFormatted := FormatRate(downloadRate, l.rateUnit, l.rateScale)
-		uploadFormatted := FormatRate(uploadRate, l.rateUnit, l.rateScale)
-		log.Printf("interface=%s upload=%s download=%s", info.InterfaceName, uploadFormatted, downloadFormatted)
+		downloadFormatted := FormatRate(downloadRate, t.rateUnit, t.rateScale)
+		uploadFormatted := FormatRate(uploadRate, t.rateUnit, t.rateScale)
+		fmt.Printf("[%s] %s: Upload: %s  Download: %s\n",
+			timeStr, t.userConfig.GetInterfaceLabel(name), uploadFormatted, downloadFormatted)
 	}
 }
 
-func (l *LogOutput) Close() {
-	log.Println("Mikrotik Interface Traffic Monitor stopped")
+func (t *TerminalOutput) Close() {
+	t.stopKeyboard()
 }
 
 // ============================================================================
@@ -344,54 +283,77 @@ func (l *LogOutput) Close() {
 
 // StructuredLogger implements structured logging output
 // Suitable for running as a service with JSON or text format
+// Records are written through a slog handler, so samples share the format of diagnostic logs:
+//
+//	time=... level=INFO msg=stats interface=ether1 upload="1.2 Mbps" download="15.0 Mbps" upload_bps=1200000 ...
 type StructuredLogger struct {
-	config           *LogConfig
-	uplinkInterfaces map[string]bool
-	writer           *log.Logger
-	file             *os.File // Only used if Output="file"
+	config     *LogConfig
+	userConfig *UserConfigManager // Uplink classification for RX/TX swapping
+	handler    slog.Handler
+	file       io.Closer     // Rotating file or syslog connection (nil for stdout)
+	changes    *changeFilter // Skips unchanged interfaces (nil = log every sample)
+	smoother   *rateSmoother // Smoothed rates (nil = measured rates)
+
+	labels []slog.Attr // Extra labels as a "labels" group, empty if none
 }
 
 // NewStructuredLogger creates a new structured logger
-func NewStructuredLogger(config *LogConfig, uplinkInterfaces []string) *StructuredLogger {
-	// Convert uplink interface list to set for O(1) lookup
-	uplinkSet := make(map[string]bool, len(uplinkInterfaces))
-	for _, iface := range uplinkInterfaces {
-		uplinkSet[iface] = true
+func NewStructuredLogger(config *LogConfig, userConfig *UserConfigManager, extraLabels map[string]string) *StructuredLogger {
+	logger := &StructuredLogger{
+		config:     config,
+		userConfig: userConfig,
+		changes:    newChangeFilter(config.Changes),
+		smoother:   newRateSmoother(config.Smoothing),
 	}
 
-	logger := &StructuredLogger{
-		config:           config,
-		uplinkInterfaces: uplinkSet,
+	// Render static labels once, sorted for stable output
+	if len(extraLabels) > 0 {
+		names := make([]string, 0, len(extraLabels))
+		for name := range extraLabels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		group := make([]any, 0, len(names))
+		for _, name := range names {
+			group = append(group, slog.String(name, extraLabels[name]))
+		}
+		logger.labels = []slog.Attr{slog.Group("labels", group...)}
 	}
 
 	// Setup output writer
-	if config.Output == "file" {
-		// Open log file with append mode
-		file, err := os.OpenFile(config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	var writer io.Writer = os.Stdout
+	switch config.Output {
+	case "syslog":
+		syslog, err := newSyslogWriter(config)
+		if err != nil {
+			logFatal("Log", "Failed to connect to syslog: %v", err)
+		}
+		logger.file = syslog
+		writer = syslog
+	case "file":
+		// Open log file with append mode (rotated by size, pruned by count/age)
+		file, err := openRotatingFile(config)
 		if err != nil {
-			log.Fatalf("Failed to open log file %s: %v", config.File, err)
+			logFatal("Log", "Failed to open log file %s: %v", config.File, err)
 		}
 		logger.file = file
-		logger.writer = log.New(file, "", 0) // No prefix, we'll format ourselves
-	} else {
-		// Use stdout
-		logger.writer = log.New(os.Stdout, "", 0)
+		writer = file
 	}
+	logger.handler = newLogHandler(writer, config.Format, slog.LevelInfo)
 
 	return logger
 }
 
 // WriteHeader initializes logging
 func (s *StructuredLogger) WriteHeader() {
-	if s.config.Format == "json" {
-		s.writer.Printf(`{"level":"info","msg":"Mikrotik Interface Traffic Monitor started"}`)
-	} else {
-		s.writer.Printf("%s [INFO] Mikrotik Interface Traffic Monitor started", time.Now().Format(time.RFC3339))
-	}
+	s.write(time.Now(), "Mikrotik Interface Traffic Monitor started")
 }
 
 // WriteStats writes statistics in structured format
 func (s *StructuredLogger) WriteStats(timestamp time.Time, stats map[string]*RateInfo) {
+	stats = s.smoother.apply(stats)
+
 	// Sort interface names for consistent ordering
 	names := make([]string, 0, len(stats))
 	for name := range stats {
@@ -401,10 +363,13 @@ func (s *StructuredLogger) WriteStats(timestamp time.Time, stats map[string]*Rat
 
 	for _, name := range names {
 		info := stats[name]
+		if s.changes != nil && !s.changes.changed(name, info, timestamp) {
+			continue
+		}
 		var downloadRate, uploadRate float64
 
 		// Convert RX/TX to Upload/Download based on interface type
-		if s.uplinkInterfaces[name] {
+		if s.userConfig.IsUplink(name) {
 			// Uplink: no swap
 			uploadRate = info.TxRate
 			downloadRate = info.RxRate
@@ -414,54 +379,33 @@ func (s *StructuredLogger) WriteStats(timestamp time.Time, stats map[string]*Rat
 			downloadRate = info.TxRate
 		}
 
-		// Format based on configured format
-		if s.config.Format == "json" {
-			s.writeJSON(timestamp, info.InterfaceName, uploadRate, downloadRate)
-		} else {
-			s.writeText(timestamp, info.InterfaceName, uploadRate, downloadRate)
+		attrs := []slog.Attr{slog.String("interface", info.InterfaceName)}
+		if label := s.userConfig.CustomLabel(name); label != "" {
+			attrs = append(attrs, slog.String("label", label))
 		}
+		attrs = append(attrs,
+			slog.String("upload", strings.TrimSpace(FormatRate(uploadRate, s.config.RateUnit, s.config.RateScale))),
+			slog.String("download", strings.TrimSpace(FormatRate(downloadRate, s.config.RateUnit, s.config.RateScale))),
+			slog.Float64("upload_bps", math.Round(uploadRate*8)), // Convert to bits for numeric field
+			slog.Float64("download_bps", math.Round(downloadRate*8)),
+		)
+		s.write(timestamp, "stats", attrs...)
 	}
 }
 
-// writeJSON writes a JSON log entry
-func (s *StructuredLogger) writeJSON(timestamp time.Time, iface string, uploadRate, downloadRate float64) {
-	// Format rates
-	uploadFormatted := FormatRate(uploadRate, s.config.RateUnit, s.config.RateScale)
-	downloadFormatted := FormatRate(downloadRate, s.config.RateUnit, s.config.RateScale)
-
-	// Write JSON (single line)
-	s.writer.Printf(`{"time":"%s","interface":"%s","upload":"%s","download":"%s","upload_bps":%.0f,"download_bps":%.0f}`,
-		timestamp.Format(time.RFC3339),
-		iface,
-		strings.TrimSpace(uploadFormatted),
-		strings.TrimSpace(downloadFormatted),
-		uploadRate*8,   // Convert to bits for numeric field
-		downloadRate*8,
-	)
-}
-
-// writeText writes a text log entry
-func (s *StructuredLogger) writeText(timestamp time.Time, iface string, uploadRate, downloadRate float64) {
-	// Format rates
-	uploadFormatted := FormatRate(uploadRate, s.config.RateUnit, s.config.RateScale)
-	downloadFormatted := FormatRate(downloadRate, s.config.RateUnit, s.config.RateScale)
-
-	// Write text format
-	s.writer.Printf("%s interface=%s upload=%s download=%s",
-		timestamp.Format(time.RFC3339),
-		iface,
-		strings.TrimSpace(uploadFormatted),
-		strings.TrimSpace(downloadFormatted),
-	)
+// write emits one INFO record with the given time (the sample time, not the write time)
+func (s *StructuredLogger) write(timestamp time.Time, msg string, attrs ...slog.Attr) {
+	record := slog.NewRecord(timestamp, slog.LevelInfo, msg, 0)
+	record.AddAttrs(attrs...)
+	record.AddAttrs(s.labels...)
+	if err := s.handler.Handle(context.Background(), record); err != nil {
+		logWarn("Log", "Failed to write log record: %v", err)
+	}
 }
 
 // Close closes the logger
 func (s *StructuredLogger) Close() {
-	if s.config.Format == "json" {
-		s.writer.Printf(`{"level":"info","msg":"Mikrotik Interface Traffic Monitor stopped"}`)
-	} else {
-		s.writer.Printf("%s [INFO] Mikrotik Interface Traffic Monitor stopped", time.Now().Format(time.RFC3339))
-	}
+	s.write(time.Now(), "Mikrotik Interface Traffic Monitor stopped")
 
 	// Close file if opened
 	if s.file != nil {
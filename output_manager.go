@@ -0,0 +1,173 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================================================
+// Output Manager (fan-out to all registered outputs)
+// ============================================================================
+
+// OutputManager fans out samples to every registered OutputWriter
+//
+// Each output runs on its own goroutine per sample, so a slow or failing
+// output never delays the others or the sampling loop:
+//   - panics are recovered and logged per output
+//   - WriteStats waits at most timeout for all outputs
+//   - an output still busy with the previous sample skips the next one
+type OutputManager struct {
+	outputs []*managedOutput
+	timeout time.Duration
+}
+
+// managedOutput tracks per-output state
+type managedOutput struct {
+	name    string
+	writer  OutputWriter
+	busy    atomic.Bool  // Set while WriteStats is running
+	paused  atomic.Bool  // Paused through the admin API (receives no samples)
+	skipped atomic.Int64 // Samples dropped because the output was busy
+}
+
+// NewOutputManager creates an empty output manager
+func NewOutputManager(timeout time.Duration) *OutputManager {
+	return &OutputManager{timeout: timeout}
+}
+
+// Register adds an output (must be called before WriteHeader)
+func (m *OutputManager) Register(name string, writer OutputWriter) {
+	m.outputs = append(m.outputs, &managedOutput{name: name, writer: writer})
+	logInfo("Output", "Registered output: %s", name)
+}
+
+// Replace swaps the writer of a registered output (configuration reload)
+// The old writer is closed and the new one initialized; an unknown name is registered.
+func (m *OutputManager) Replace(name string, writer OutputWriter) {
+	for _, output := range m.outputs {
+		if output.name == name {
+			output.call("Close", output.writer.Close)
+			output.writer = writer
+			output.call("WriteHeader", writer.WriteHeader)
+			logInfo("Output", "Reloaded output: %s", name)
+			return
+		}
+	}
+	m.Register(name, writer)
+	output := m.outputs[len(m.outputs)-1]
+	output.call("WriteHeader", writer.WriteHeader)
+}
+
+// Remove closes and unregisters an output (configuration reload)
+func (m *OutputManager) Remove(name string) {
+	for i, output := range m.outputs {
+		if output.name == name {
+			output.call("Close", output.writer.Close)
+			m.outputs = append(m.outputs[:i], m.outputs[i+1:]...)
+			logInfo("Output", "Removed output: %s", name)
+			return
+		}
+	}
+}
+
+// sampleIntervalSetter is implemented by outputs that aggregate samples into windows
+// and expect a number of samples per window from the poll interval
+type sampleIntervalSetter interface {
+	SetSampleInterval(now time.Time, sampleInterval time.Duration)
+}
+
+// SetSampleInterval passes a poll interval change to the outputs that aggregate samples
+func (m *OutputManager) SetSampleInterval(now time.Time, sampleInterval time.Duration) {
+	for _, output := range m.outputs {
+		if setter, ok := output.writer.(sampleIntervalSetter); ok {
+			setter.SetSampleInterval(now, sampleInterval)
+		}
+	}
+}
+
+// SetPaused pauses the named outputs and resumes all others
+func (m *OutputManager) SetPaused(names []string) {
+	paused := toSet(names)
+	for _, output := range m.outputs {
+		if output.paused.Swap(paused[output.name]) != paused[output.name] {
+			logInfo("Output", "%s %s", output.name, map[bool]string{true: "paused", false: "resumed"}[paused[output.name]])
+		}
+	}
+}
+
+// Len returns the number of registered outputs
+func (m *OutputManager) Len() int {
+	return len(m.outputs)
+}
+
+// WriteHeader initializes all outputs sequentially (in registration order)
+func (m *OutputManager) WriteHeader() {
+	for _, output := range m.outputs {
+		output.call("WriteHeader", output.writer.WriteHeader)
+	}
+}
+
+// WriteStats sends a sample to all outputs concurrently
+// The stats map is shared read-only between outputs
+func (m *OutputManager) WriteStats(timestamp time.Time, stats map[string]*RateInfo) {
+	var wg sync.WaitGroup
+	started := make([]*managedOutput, 0, len(m.outputs))
+
+	for _, output := range m.outputs {
+		if output.paused.Load() {
+			continue
+		}
+		if !output.busy.CompareAndSwap(false, true) {
+			if n := output.skipped.Add(1); n == 1 || n%60 == 0 {
+				logWarn("Output", "%s is still busy, skipped %d sample(s)", output.name, n)
+			}
+			continue
+		}
+
+		started = append(started, output)
+		wg.Add(1)
+		go func(output *managedOutput, writer OutputWriter) {
+			defer wg.Done()
+			defer output.busy.Store(false)
+			output.call("WriteStats", func() {
+				writer.WriteStats(timestamp, stats)
+			})
+		}(output, output.writer)
+	}
+
+	// Wait for all outputs, but never longer than the timeout
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(m.timeout):
+		for _, output := range started {
+			if output.busy.Load() {
+				logWarn("Output", "%s exceeded %v, continuing without it", output.name, m.timeout)
+			}
+		}
+	}
+}
+
+// Close closes all outputs in reverse registration order
+func (m *OutputManager) Close() {
+	for i := len(m.outputs) - 1; i >= 0; i-- {
+		output := m.outputs[i]
+		output.call("Close", output.writer.Close)
+	}
+}
+
+// call runs fn and recovers from panics so one output cannot crash the monitor
+func (o *managedOutput) call(method string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			logError("Output", "%s.%s panicked: %v", o.name, method, r)
+		}
+	}()
+	fn()
+}
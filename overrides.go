@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ============================================================================
+// Runtime Configuration Overrides (admin API)
+// ============================================================================
+
+// ConfigOverrides are settings changed through /api/admin/config
+// They are persisted in the user configuration and take precedence over the
+// env file, including after a reload. Empty fields are not overridden.
+type ConfigOverrides struct {
+	PollInterval    string          `json:"poll_interval,omitempty"`     // Duration ("2s")
+	Interfaces      []string        `json:"interfaces,omitempty"`        // Replaces INTERFACES
+	StatsWindowSize int             `json:"stats_window_size,omitempty"` // Replaces STATS_WINDOW_SIZE
+	AlertThresholds *string         `json:"alert_thresholds,omitempty"`  // Replaces ALERT_THRESHOLDS ("" = no threshold rules)
+	Outputs         map[string]bool `json:"outputs,omitempty"`           // Output name -> enabled (false pauses it)
+}
+
+// errOverridesNotSaved reports that valid overrides could not be persisted
+var errOverridesNotSaved = errors.New("failed to save configuration")
+
+// outputNames are the outputs registered by NewMonitor, in registration order
+var outputNames = []string{"terminal", "log", "web", "victoriametrics", "otlp", "pushgateway", "stream", "rrd", "zabbix"}
+
+// IsZero reports whether nothing is overridden
+func (o *ConfigOverrides) IsZero() bool {
+	return o.PollInterval == "" && len(o.Interfaces) == 0 && o.StatsWindowSize == 0 &&
+		o.AlertThresholds == nil && len(o.Outputs) == 0
+}
+
+// merge returns a copy of base with the overrides applied, validated like a loaded configuration
+func (o *ConfigOverrides) merge(base *Config) (*Config, error) {
+	c := *base
+
+	if o.PollInterval != "" {
+		interval, err := time.ParseDuration(o.PollInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid poll_interval: %s", o.PollInterval)
+		}
+		c.PollInterval = interval
+	}
+	if len(o.Interfaces) > 0 {
+		c.Interfaces = append([]string{}, o.Interfaces...)
+	}
+	if o.StatsWindowSize != 0 {
+		c.StatsWindowSize = o.StatsWindowSize
+	}
+	if o.AlertThresholds != nil {
+		if c.Alerts == nil {
+			return nil, fmt.Errorf("alert_thresholds requires ALERTS_ENABLED=true")
+		}
+		alerts := *c.Alerts
+		alerts.Thresholds = *o.AlertThresholds
+		c.Alerts = &alerts
+	}
+
+	c.DisabledOutputs = nil
+	for name, enabled := range o.Outputs {
+		if !outputConfigured(&c, name) {
+			return nil, fmt.Errorf("output %q is not configured (one of: %v)", name, outputNames)
+		}
+		if !enabled {
+			c.DisabledOutputs = append(c.DisabledOutputs, name)
+		}
+	}
+	sort.Strings(c.DisabledOutputs)
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Apply returns base with the overrides applied
+// Overrides that no longer fit the loaded configuration are ignored as a whole.
+func (o *ConfigOverrides) Apply(base *Config) *Config {
+	if o.IsZero() {
+		return base
+	}
+	c, err := o.merge(base)
+	if err != nil {
+		logWarn("Admin", "Ignoring configuration overrides: %v", err)
+		return base
+	}
+	return c
+}
+
+// outputConfigured reports whether an output is enabled in the configuration
+func outputConfigured(c *Config, name string) bool {
+	switch name {
+	case "terminal":
+		return c.Terminal != nil && c.Terminal.Enabled
+	case "log":
+		return c.Log != nil
+	case "web":
+		return c.Web != nil
+	case "victoriametrics":
+		return c.VictoriaMetrics != nil
+	case "otlp":
+		return c.OTLP != nil
+	case "pushgateway":
+		return c.Pushgateway != nil
+	case "stream":
+		return c.Stream != nil
+	case "rrd":
+		return c.RRD != nil
+	case "zabbix":
+		return c.Zabbix != nil
+	}
+	return false
+}
+
+// CurrentConfig returns the running configuration (safe for concurrent use)
+func (m *Monitor) CurrentConfig() *Config {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+	return m.config
+}
+
+// SetConfigOverrides validates and persists the overrides, then applies them
+// Returns the configuration that will be running after the next sample.
+func (m *Monitor) SetConfigOverrides(overrides ConfigOverrides) (*Config, error) {
+	// Held until queued so a concurrent reload cannot apply an older merge afterwards
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+
+	config, err := overrides.merge(m.loaded)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.userConfig.SetConfigOverrides(overrides); err != nil {
+		return nil, fmt.Errorf("%w: %v", errOverridesNotSaved, err)
+	}
+	m.queueConfig(config)
+	return config, nil
+}
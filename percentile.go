@@ -0,0 +1,277 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// 95th Percentile (burstable billing)
+// ============================================================================
+
+// PercentileTracker computes the Nth percentile of sample-interval averages
+//
+// Industry-standard transit billing: average each interface over 5-minute
+// buckets, collect the buckets for a calendar month, discard the top 5%
+// and bill the highest remaining bucket.
+type PercentileTracker struct {
+	config   *PercentileConfig
+	location *time.Location // TIMEZONE: buckets and billing months start on its boundaries
+
+	windowStart time.Time                    // Start of the current billing window
+	buckets     map[string]*percentileBucket // Open bucket per interface
+	series      map[string]*percentileSeries // Completed bucket averages per interface
+	closed      *closedPercentileMonth       // Final results of the month that just ended (until taken)
+	mu          sync.RWMutex
+}
+
+// percentileBucket accumulates 1s rates within one sample interval
+type percentileBucket struct {
+	start time.Time
+	rxSum float64
+	txSum float64
+	count int
+}
+
+// closedPercentileMonth holds the final results of a billing month
+type closedPercentileMonth struct {
+	end     time.Time // Start of the next month
+	results []PercentileResult
+}
+
+// percentileSeries holds completed bucket averages (bytes/s)
+type percentileSeries struct {
+	times []time.Time
+	rx    []float64
+	tx    []float64
+}
+
+// PercentileResult is the percentile rate for one interface (RX/TX naming, bytes/s)
+type PercentileResult struct {
+	Interface   string    `json:"interface"`
+	RxRate      float64   `json:"rx_rate"`
+	TxRate      float64   `json:"tx_rate"`
+	Samples     int       `json:"samples"`      // Completed buckets in the window
+	WindowStart time.Time `json:"window_start"` // Start of the billing window
+}
+
+// NewPercentileTracker creates a new percentile tracker (location is the TIMEZONE zone)
+func NewPercentileTracker(config *PercentileConfig, location *time.Location) *PercentileTracker {
+	logInfo("Percentile", "%gth percentile tracker initialized (sample: %v, window: %s)",
+		config.Percentile, config.SampleInterval, config.Window)
+
+	return &PercentileTracker{
+		config:   config,
+		location: location,
+		buckets:  make(map[string]*percentileBucket),
+		series:   make(map[string]*percentileSeries),
+	}
+}
+
+// Add records one set of rates and reports whether any sample bucket was completed
+func (p *PercentileTracker) Add(now time.Time, stats map[string]*RateInfo) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rollWindow(now)
+
+	bucketStart := truncateIn(now, p.config.SampleInterval, p.location)
+	completed := false
+
+	for name, info := range stats {
+		bucket, ok := p.buckets[name]
+		if ok && !bucket.start.Equal(bucketStart) {
+			p.closeBucket(name, bucket)
+			completed = true
+			ok = false
+		}
+		if !ok {
+			bucket = &percentileBucket{start: bucketStart}
+			p.buckets[name] = bucket
+		}
+
+		bucket.rxSum += info.RxRate
+		bucket.txSum += info.TxRate
+		bucket.count++
+	}
+
+	return completed
+}
+
+// closeBucket appends a bucket average to the interface series
+// Buckets that started before the current window are discarded
+func (p *PercentileTracker) closeBucket(name string, bucket *percentileBucket) {
+	if bucket.count == 0 || bucket.start.Before(p.windowStart) {
+		return
+	}
+
+	series, ok := p.series[name]
+	if !ok {
+		series = &percentileSeries{}
+		p.series[name] = series
+	}
+	series.times = append(series.times, bucket.start)
+	series.rx = append(series.rx, bucket.rxSum/float64(bucket.count))
+	series.tx = append(series.tx, bucket.txSum/float64(bucket.count))
+}
+
+// rollWindow resets or trims the series when the billing window moves
+func (p *PercentileTracker) rollWindow(now time.Time) {
+	if p.config.Window == "month" {
+		local := now.In(p.location)
+		monthStart := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, p.location)
+		if !monthStart.Equal(p.windowStart) {
+			if !p.windowStart.IsZero() {
+				// The open buckets are the last ones of the month that ended
+				for name, bucket := range p.buckets {
+					p.closeBucket(name, bucket)
+				}
+				p.closed = &closedPercentileMonth{end: monthStart, results: p.results()}
+				for _, result := range p.closed.results {
+					logInfo("Percentile", "%s %gth percentile for %s: rx %s, tx %s (%d samples)",
+						result.Interface, p.config.Percentile, p.windowStart.Format("2006-01"),
+						formatAlertRate(result.RxRate*8), formatAlertRate(result.TxRate*8), result.Samples)
+				}
+				logInfo("Percentile", "New billing month started (%s), resetting samples", monthStart.Format("2006-01"))
+			}
+			p.windowStart = monthStart
+			p.buckets = make(map[string]*percentileBucket)
+			p.series = make(map[string]*percentileSeries)
+		}
+		return
+	}
+
+	// Rolling window: drop buckets older than the window length
+	p.windowStart = now.Add(-p.config.WindowDuration)
+	for _, series := range p.series {
+		drop := 0
+		for drop < len(series.times) && series.times[drop].Before(p.windowStart) {
+			drop++
+		}
+		if drop > 0 {
+			series.times = series.times[drop:]
+			series.rx = series.rx[drop:]
+			series.tx = series.tx[drop:]
+		}
+	}
+}
+
+// Results returns the current percentile for every interface with completed samples
+func (p *PercentileTracker) Results() []PercentileResult {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.results()
+}
+
+// TakeClosedMonth returns the final results of the billing month that just ended, once
+// end is the start of the next month; ok is false when no month ended since the last call.
+func (p *PercentileTracker) TakeClosedMonth() (results []PercentileResult, end time.Time, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed == nil {
+		return nil, time.Time{}, false
+	}
+	closed := p.closed
+	p.closed = nil
+	return closed.results, closed.end, true
+}
+
+// results computes the percentiles of the current window (caller holds the lock)
+func (p *PercentileTracker) results() []PercentileResult {
+	results := make([]PercentileResult, 0, len(p.series))
+	for name, series := range p.series {
+		if len(series.rx) == 0 {
+			continue
+		}
+		results = append(results, PercentileResult{
+			Interface:   name,
+			RxRate:      percentile(series.rx, p.config.Percentile),
+			TxRate:      percentile(series.tx, p.config.Percentile),
+			Samples:     len(series.rx),
+			WindowStart: p.windowStart,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Interface < results[j].Interface
+	})
+	return results
+}
+
+// percentileState is the persisted form of the completed buckets (state file)
+type percentileState struct {
+	SampleInterval time.Duration                   `json:"sample_interval"`
+	WindowStart    time.Time                       `json:"window_start"`
+	Series         map[string]percentileSeriesJSON `json:"series"`
+}
+
+// percentileSeriesJSON is the persisted form of percentileSeries
+type percentileSeriesJSON struct {
+	Times []time.Time `json:"times"`
+	Rx    []float64   `json:"rx"`
+	Tx    []float64   `json:"tx"`
+}
+
+// snapshot returns the completed buckets for the state file (open buckets are not kept)
+func (p *PercentileTracker) snapshot() *percentileState {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	state := &percentileState{
+		SampleInterval: p.config.SampleInterval,
+		WindowStart:    p.windowStart,
+		Series:         make(map[string]percentileSeriesJSON, len(p.series)),
+	}
+	for name, series := range p.series {
+		state.Series[name] = percentileSeriesJSON{
+			Times: append([]time.Time(nil), series.times...),
+			Rx:    append([]float64(nil), series.rx...),
+			Tx:    append([]float64(nil), series.tx...),
+		}
+	}
+	return state
+}
+
+// restore continues the billing window saved before a restart
+// Buckets of another sample interval are not comparable and are dropped; a window
+// that ended in the meantime is reset by the next sample.
+func (p *PercentileTracker) restore(state *percentileState) bool {
+	if state.SampleInterval != p.config.SampleInterval {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.windowStart = state.WindowStart
+	p.series = make(map[string]*percentileSeries, len(state.Series))
+	for name, series := range state.Series {
+		if len(series.Rx) != len(series.Times) || len(series.Tx) != len(series.Times) {
+			continue
+		}
+		p.series[name] = &percentileSeries{times: series.Times, rx: series.Rx, tx: series.Tx}
+	}
+	return true
+}
+
+// percentile returns the nearest-rank percentile (the value below which pct% of samples fall)
+func percentile(values []float64, pct float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(pct/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
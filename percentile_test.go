@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentileMonthRollover(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPercentileTracker(&PercentileConfig{Percentile: 100, SampleInterval: 5 * time.Minute, Window: "month"}, berlin)
+	add := func(now time.Time, rx float64) bool {
+		return p.Add(now, map[string]*RateInfo{"ether1": {InterfaceName: "ether1", RxRate: rx, TxRate: rx / 2}})
+	}
+
+	// Two buckets at the end of January: 23:50 (closed) and 23:55 (still open at midnight)
+	add(time.Date(2024, 1, 31, 23, 50, 0, 0, berlin), 100)
+	add(time.Date(2024, 1, 31, 23, 55, 0, 0, berlin), 300)
+	add(time.Date(2024, 1, 31, 23, 59, 59, 0, berlin), 500)
+	if _, _, ok := p.TakeClosedMonth(); ok {
+		t.Fatal("month reported closed before it ended")
+	}
+
+	add(time.Date(2024, 2, 1, 0, 0, 1, 0, berlin), 10)
+	results, end, ok := p.TakeClosedMonth()
+	if !ok || len(results) != 1 {
+		t.Fatalf("TakeClosedMonth = %v, %v; want the January results", results, ok)
+	}
+	// The open 23:55 bucket (average 400) is part of January and its maximum
+	if r := results[0]; r.Samples != 2 || r.RxRate != 400 || r.TxRate != 200 || !r.WindowStart.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, berlin)) {
+		t.Errorf("January result %+v, want 2 samples, rx 400, tx 200 from January 1st", r)
+	}
+	if want := time.Date(2024, 2, 1, 0, 0, 0, 0, berlin); !end.Equal(want) {
+		t.Errorf("month end %v, want %v", end, want)
+	}
+	if _, _, ok := p.TakeClosedMonth(); ok {
+		t.Error("closed month returned twice")
+	}
+
+	// February starts empty; the January bucket does not leak into it
+	add(time.Date(2024, 2, 1, 0, 5, 0, 0, berlin), 20)
+	if results := p.Results(); len(results) != 1 || results[0].Samples != 1 || results[0].RxRate != 10 {
+		t.Errorf("February results %+v, want one 10 B/s sample", results)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Latency Probes (RouterOS ping)
+// ============================================================================
+
+// PingResult is the outcome of one ping run toward a target
+// RTTs are in seconds and zero when no reply was received.
+type PingResult struct {
+	Target   string  `json:"target"`
+	Sent     int     `json:"sent"`
+	Received int     `json:"received"`
+	Loss     float64 `json:"loss"` // Fraction of packets lost (0-1)
+	RTTMin   float64 `json:"rtt_min"`
+	RTTAvg   float64 `json:"rtt_avg"`
+	RTTMax   float64 `json:"rtt_max"`
+	Jitter   float64 `json:"jitter"` // Mean difference between consecutive RTTs
+}
+
+// PingSnapshot is the result of one collection cycle
+type PingSnapshot struct {
+	Timestamp time.Time    `json:"timestamp"`
+	Results   []PingResult `json:"results"` // In PING_TARGETS order
+}
+
+// PingCollector runs /ping on the router toward the configured targets
+//
+// The router sends PING_COUNT echo requests per target and returns one reply per
+// packet (time=12ms345us, or status=timeout). Probing from the router measures
+// the path the monitored traffic takes, so latency can be correlated with the
+// interface rates.
+type PingCollector struct {
+	client RouterClient
+	config *PingConfig
+
+	latest   *PingSnapshot
+	latestMu sync.RWMutex
+}
+
+// NewPingCollector creates a new latency probe collector
+func NewPingCollector(client RouterClient, config *PingConfig) *PingCollector {
+	logInfo("Ping", "Latency probes initialized (targets: %v, interval: %v, %d packets every %v)",
+		config.Targets, config.Interval, config.Count, config.PacketInterval)
+
+	return &PingCollector{
+		client: client,
+		config: config,
+	}
+}
+
+// Collect pings every target and stores the results
+func (p *PingCollector) Collect(ctx context.Context, now time.Time) (*PingSnapshot, error) {
+	// Ping runs are pipelined, so all targets are probed over the same period
+	commands := make([][]string, len(p.config.Targets))
+	for i, target := range p.config.Targets {
+		commands[i] = []string{
+			"/ping",
+			"=address=" + target,
+			"=count=" + strconv.Itoa(p.config.Count),
+			"=interval=" + formatPingInterval(p.config.PacketInterval),
+		}
+	}
+	results, errs := RunAll(ctx, p.client, commands...)
+
+	snapshot := &PingSnapshot{Timestamp: now}
+	var firstErr error
+	for i, target := range p.config.Targets {
+		if errs[i] != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("ping %s: %w", target, errs[i])
+			}
+			continue
+		}
+		snapshot.Results = append(snapshot.Results, parsePingRows(target, results[i]))
+	}
+
+	// Keep the previous results if every target failed
+	if len(snapshot.Results) > 0 {
+		p.latestMu.Lock()
+		p.latest = snapshot
+		p.latestMu.Unlock()
+	}
+
+	if len(snapshot.Results) == 0 {
+		return nil, firstErr
+	}
+	return snapshot, firstErr
+}
+
+// Latest returns the most recent snapshot (nil before the first collection)
+func (p *PingCollector) Latest() *PingSnapshot {
+	p.latestMu.RLock()
+	defer p.latestMu.RUnlock()
+	return p.latest
+}
+
+// parsePingRows summarizes the per-packet replies of /ping
+//
+// Every reply carries seq; answered packets have time=..., lost ones a status
+// such as "timeout" or "host unreachable". The running sent/received totals of
+// the replies are not used, so a reply missing from a truncated run counts as lost.
+func parsePingRows(target string, rows []map[string]string) PingResult {
+	result := PingResult{Target: target}
+	var rtts []float64
+	for _, row := range rows {
+		if _, ok := row["seq"]; !ok {
+			continue // Summary rows of older versions
+		}
+		result.Sent++
+		if row["status"] != "" {
+			continue
+		}
+		if rtt, ok := parsePingTime(row["time"]); ok {
+			rtts = append(rtts, rtt)
+		}
+	}
+
+	result.Received = len(rtts)
+	if result.Sent > 0 {
+		result.Loss = float64(result.Sent-result.Received) / float64(result.Sent)
+	}
+	if len(rtts) == 0 {
+		return result
+	}
+
+	result.RTTMin, result.RTTMax = rtts[0], rtts[0]
+	var sum, diffs float64
+	for i, rtt := range rtts {
+		sum += rtt
+		result.RTTMin = math.Min(result.RTTMin, rtt)
+		result.RTTMax = math.Max(result.RTTMax, rtt)
+		if i > 0 {
+			diffs += math.Abs(rtt - rtts[i-1])
+		}
+	}
+	result.RTTAvg = sum / float64(len(rtts))
+	if len(rtts) > 1 {
+		result.Jitter = diffs / float64(len(rtts)-1)
+	}
+	return result
+}
+
+// parsePingTime converts a RouterOS round-trip time to seconds
+// Accepts "12ms345us" (v7), "12ms" and "<1ms" (v6) and "1s20ms".
+func parsePingTime(value string) (float64, bool) {
+	value = strings.TrimPrefix(value, "<")
+	if value == "" {
+		return 0, false
+	}
+
+	units := map[string]float64{"s": 1, "ms": 1e-3, "us": 1e-6, "ns": 1e-9}
+	var total float64
+	for value != "" {
+		end := strings.IndexFunc(value, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if end <= 0 {
+			return 0, false
+		}
+		number, err := strconv.ParseFloat(value[:end], 64)
+		if err != nil {
+			return 0, false
+		}
+		value = value[end:]
+
+		unitEnd := strings.IndexFunc(value, func(r rune) bool { return r >= '0' && r <= '9' })
+		if unitEnd < 0 {
+			unitEnd = len(value)
+		}
+		scale, ok := units[value[:unitEnd]]
+		if !ok {
+			return 0, false
+		}
+		total += number * scale
+		value = value[unitEnd:]
+	}
+	return total, true
+}
+
+// formatPingInterval renders the packet interval for /ping ("200ms", "1s")
+func formatPingInterval(d time.Duration) string {
+	if d%time.Second == 0 {
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	}
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
+
+// pingMetrics renders ping results in Prometheus text format
+// Samples carry the snapshot timestamp when withTimestamp is set (VictoriaMetrics push).
+func pingMetrics(snapshot *PingSnapshot, withTimestamp bool) string {
+	timestamp := ""
+	if withTimestamp {
+		timestamp = fmt.Sprintf(" %d", snapshot.Timestamp.UnixMilli())
+	}
+	results := append([]PingResult(nil), snapshot.Results...)
+	sort.Slice(results, func(i, j int) bool { return results[i].Target < results[j].Target })
+
+	var loss, sent, rttMin, rttAvg, rttMax, jitter strings.Builder
+	for _, result := range results {
+		series := fmt.Sprintf("target=\"%s\"", escapeLabelValue(result.Target))
+		fmt.Fprintf(&loss, "mikrotik_ping_loss_ratio{%s} %.4f%s\n", series, result.Loss, timestamp)
+		fmt.Fprintf(&sent, "mikrotik_ping_packets_sent{%s} %d%s\n", series, result.Sent, timestamp)
+		if result.Received == 0 {
+			continue // No RTT without a reply
+		}
+		fmt.Fprintf(&rttMin, "mikrotik_ping_rtt_min_seconds{%s} %.6f%s\n", series, result.RTTMin, timestamp)
+		fmt.Fprintf(&rttAvg, "mikrotik_ping_rtt_avg_seconds{%s} %.6f%s\n", series, result.RTTAvg, timestamp)
+		fmt.Fprintf(&rttMax, "mikrotik_ping_rtt_max_seconds{%s} %.6f%s\n", series, result.RTTMax, timestamp)
+		fmt.Fprintf(&jitter, "mikrotik_ping_jitter_seconds{%s} %.6f%s\n", series, result.Jitter, timestamp)
+	}
+
+	var out strings.Builder
+	fmt.Fprintln(&out, "# HELP mikrotik_ping_loss_ratio Fraction of echo requests without a reply in the last ping run")
+	fmt.Fprintln(&out, "# TYPE mikrotik_ping_loss_ratio gauge")
+	fmt.Fprint(&out, loss.String())
+	fmt.Fprintln(&out, "# HELP mikrotik_ping_packets_sent Echo requests sent in the last ping run")
+	fmt.Fprintln(&out, "# TYPE mikrotik_ping_packets_sent gauge")
+	fmt.Fprint(&out, sent.String())
+	if rttAvg.Len() > 0 {
+		fmt.Fprintln(&out, "# HELP mikrotik_ping_rtt_min_seconds Minimum round-trip time in the last ping run")
+		fmt.Fprintln(&out, "# TYPE mikrotik_ping_rtt_min_seconds gauge")
+		fmt.Fprint(&out, rttMin.String())
+		fmt.Fprintln(&out, "# HELP mikrotik_ping_rtt_avg_seconds Average round-trip time in the last ping run")
+		fmt.Fprintln(&out, "# TYPE mikrotik_ping_rtt_avg_seconds gauge")
+		fmt.Fprint(&out, rttAvg.String())
+		fmt.Fprintln(&out, "# HELP mikrotik_ping_rtt_max_seconds Maximum round-trip time in the last ping run")
+		fmt.Fprintln(&out, "# TYPE mikrotik_ping_rtt_max_seconds gauge")
+		fmt.Fprint(&out, rttMax.String())
+		fmt.Fprintln(&out, "# HELP mikrotik_ping_jitter_seconds Mean difference between consecutive round-trip times in the last ping run")
+		fmt.Fprintln(&out, "# TYPE mikrotik_ping_jitter_seconds gauge")
+		fmt.Fprint(&out, jitter.String())
+	}
+	return out.String()
+}
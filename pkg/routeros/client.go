@@ -0,0 +1,548 @@
+// Package routeros implements the MikroTik RouterOS API protocol (TCP port 8728, api-ssl 8729)
+//
+// A Client is safe for concurrent use: every command carries a unique .tag and a
+// single reader goroutine routes reply sentences back by tag, so commands from
+// several goroutines are pipelined on one connection instead of waiting for each
+// other's round-trips.
+//
+//	client, err := routeros.Dial(ctx, "192.168.88.1:8728", routeros.Options{
+//		Username: "monitor",
+//		Password: "secret",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	defer client.Close()
+//	rows, err := client.Run(ctx, "/interface/print", "=.proplist=name,rx-byte,tx-byte")
+//
+// Reference: https://help.mikrotik.com/docs/display/ROS/API
+package routeros
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is the dial timeout and command deadline when Options.Timeout is zero
+const DefaultTimeout = 10 * time.Second
+
+// DefaultMaxWordLength is the longest reply word accepted when Options.MaxWordLength is zero
+// Attribute values are short; the longest are script sources and log messages.
+const DefaultMaxWordLength = 1 << 20
+
+// Options configures a connection
+type Options struct {
+	Username string
+	Password string
+
+	// TLSConfig enables api-ssl; the router needs a certificate assigned to the
+	// service (/ip service set api-ssl certificate=...)
+	TLSConfig *tls.Config
+
+	// Timeout bounds dialing, the TLS handshake, writes and commands whose context has no deadline
+	Timeout time.Duration
+
+	// Login selects how the password is sent (default LoginAuto)
+	Login LoginMethod
+
+	// MaxWordLength bounds the size of a reply word (default DefaultMaxWordLength)
+	// A longer word is treated as a corrupted stream and closes the connection.
+	MaxWordLength int
+
+	// Debugf receives every word read from the router (nil = no debug output)
+	Debugf func(format string, args ...any)
+
+	// Record receives a transcript of the session (see Record; nil = not recorded)
+	Record io.Writer
+}
+
+// LoginMethod selects the /login variant
+type LoginMethod int
+
+const (
+	// LoginAuto sends the password (RouterOS 6.43+) and answers the MD5 challenge
+	// if the router replies with one instead (older versions)
+	LoginAuto LoginMethod = iota
+
+	// LoginPlain only sends the password; older routers are rejected
+	LoginPlain
+
+	// LoginChallenge only uses the MD5 challenge-response of RouterOS before 6.43,
+	// so the password never crosses an unencrypted connection to such routers
+	LoginChallenge
+)
+
+// timeout returns the configured timeout or DefaultTimeout
+func (o *Options) timeout() time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return DefaultTimeout
+}
+
+// maxWordLength returns the configured word limit or DefaultMaxWordLength
+func (o *Options) maxWordLength() int {
+	if o.MaxWordLength > 0 {
+		return o.MaxWordLength
+	}
+	return DefaultMaxWordLength
+}
+
+// Client is an authenticated connection to a router
+type Client struct {
+	conn    net.Conn      // TCP (or TLS) connection to the API service
+	reader  *bufio.Reader // Buffered reads from conn (reader goroutine only)
+	maxWord int           // Longest accepted reply word
+	timeout time.Duration // Default per-command deadline when the context has none
+	debugf  func(format string, args ...any)
+
+	version   Version // Detected after login (zero if /system/resource is not readable)
+	boardName string
+
+	writeMu sync.Mutex // Serializes sentence writes on the shared connection
+
+	mu      sync.Mutex                 // Guards pending, nextTag and err
+	pending map[string]*pendingCommand // In-flight commands by tag
+	nextTag uint64
+	err     error // Set when the reader stops; fails all later commands
+}
+
+// pendingCommand collects the reply sentences of one tagged command
+type pendingCommand struct {
+	rows   []map[string]string
+	trap   error
+	result chan commandResult // Buffered; receives exactly one result
+
+	// Listen only: rows are handed to the stream instead of collected
+	stream chan map[string]string // Unbuffered, never closed
+	stop   chan struct{}          // Closed when the listener is gone
+}
+
+// commandResult is the outcome of a command
+type commandResult struct {
+	rows []map[string]string
+	err  error
+}
+
+// Dial connects to the API service at address ("host:port") and logs in
+func Dial(ctx context.Context, address string, opts Options) (*Client, error) {
+	dialer := &net.Dialer{Timeout: opts.timeout()}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	if opts.TLSConfig != nil {
+		tlsConn := tls.Client(conn, opts.TLSConfig)
+		handshakeCtx, cancel := context.WithTimeout(ctx, opts.timeout())
+		err := tlsConn.HandshakeContext(handshakeCtx)
+		cancel()
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	return NewClient(ctx, conn, opts)
+}
+
+// NewClient logs in over an established connection (closed if the login fails)
+func NewClient(ctx context.Context, conn net.Conn, opts Options) (*Client, error) {
+	if opts.Record != nil {
+		conn = Record(conn, opts.Record)
+	}
+	client := &Client{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		maxWord: opts.maxWordLength(),
+		timeout: opts.timeout(),
+		debugf:  opts.Debugf,
+		pending: make(map[string]*pendingCommand),
+	}
+	go client.readLoop()
+
+	if err := client.login(ctx, opts.Username, opts.Password, opts.Login); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to login: %w", err)
+	}
+	if err := client.detectVersion(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to login: %w", err)
+	}
+	return client, nil
+}
+
+// Version returns the RouterOS version detected after login (zero if unknown)
+func (c *Client) Version() Version {
+	return c.version
+}
+
+// BoardName returns the router model detected after login ("" if unknown)
+func (c *Client) BoardName() string {
+	return c.boardName
+}
+
+// Close closes the connection
+// Commands still in flight fail with net.ErrClosed.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// writeSentence sends one sentence (a command and its arguments) in a single write
+// A failed write leaves the stream in an unknown state, so the connection is closed.
+func (c *Client) writeSentence(words ...string) error {
+	var buf []byte
+	for _, word := range words {
+		buf = encodeWord(buf, word)
+	}
+	buf = encodeWord(buf, "")
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(buf); err != nil {
+		c.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// readLoop reads reply sentences and routes them to the pending command with the same .tag
+// Runs until the connection fails or is closed; all pending commands then fail with that error.
+// A stream that cannot be decoded is not resynchronized byte by byte: word boundaries
+// are lost, so the connection is closed and the caller reconnects.
+func (c *Client) readLoop() {
+	for {
+		words, err := readSentence(c.reader, c.maxWord, c.debugf)
+		if err != nil {
+			c.debug("readLoop: error reading sentence: %v", err)
+			c.fail(err)
+			var protoErr *ProtocolError
+			if errors.As(err, &protoErr) {
+				c.conn.Close()
+			}
+			return
+		}
+
+		reply := words[0]
+		attrs := make(map[string]string)
+		var tag string
+		for _, word := range words[1:] {
+			if t, ok := strings.CutPrefix(word, ".tag="); ok {
+				tag = t
+			} else if strings.HasPrefix(word, "=") {
+				if key, value, ok := strings.Cut(word[1:], "="); ok {
+					attrs[key] = value
+				}
+			}
+		}
+
+		// !fatal is untagged and means the router is closing the connection
+		if reply == "!fatal" {
+			c.fail(&FatalError{Message: strings.Join(words[1:], " ")})
+			c.conn.Close()
+			return
+		}
+
+		c.mu.Lock()
+		cmd := c.pending[tag]
+		if cmd != nil && reply == "!done" {
+			delete(c.pending, tag)
+		}
+		c.mu.Unlock()
+		if cmd == nil {
+			continue // Reply to a cancelled or untagged command
+		}
+
+		switch reply {
+		case "!empty":
+			// RouterOS 7.18+ announces an empty result before !done
+		case "!re":
+			if cmd.stream != nil {
+				select {
+				case cmd.stream <- attrs:
+				case <-cmd.stop:
+				}
+			} else {
+				cmd.rows = append(cmd.rows, attrs)
+			}
+		case "!trap":
+			cmd.trap = newTrapError(attrs)
+		case "!done":
+			// !done may carry attributes (e.g. the /login challenge)
+			if len(attrs) > 0 {
+				cmd.rows = append(cmd.rows, attrs)
+			}
+			if cmd.trap != nil {
+				cmd.result <- commandResult{err: cmd.trap}
+			} else {
+				cmd.result <- commandResult{rows: cmd.rows}
+			}
+		default:
+			// Sentence framing is intact, so an unknown reply type is skipped
+			c.debug("readLoop: ignoring unknown reply %q", reply)
+		}
+	}
+}
+
+// debug forwards to Options.Debugf when set
+func (c *Client) debug(format string, args ...any) {
+	if c.debugf != nil {
+		c.debugf(format, args...)
+	}
+}
+
+// fail records the reader error and fails every pending command with it
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	for tag, cmd := range c.pending {
+		cmd.result <- commandResult{err: err}
+		delete(c.pending, tag)
+	}
+}
+
+// start registers a tagged command and sends it
+func (c *Client) start(ctx context.Context, cmd *pendingCommand, words []string) (string, error) {
+	if len(words) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return "", fmt.Errorf("connection failed: %w", err)
+	}
+	c.nextTag++
+	tag := strconv.FormatUint(c.nextTag, 10)
+	c.pending[tag] = cmd
+	c.mu.Unlock()
+
+	sentence := append(append([]string{}, words...), ".tag="+tag)
+	if err := c.writeSentence(sentence...); err != nil {
+		c.forget(tag)
+		return "", contextError(ctx, fmt.Errorf("sendCommand failed: %w", err))
+	}
+	return tag, nil
+}
+
+// Run sends a command and returns its reply rows (the attributes of each !re sentence)
+// The command is bounded by the context deadline (or Options.Timeout) and cancelled
+// on the router (/cancel) as soon as the context is done. A !trap reply is returned
+// as a *TrapError.
+func (c *Client) Run(ctx context.Context, words ...string) ([]map[string]string, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	cmd := &pendingCommand{result: make(chan commandResult, 1)}
+	tag, err := c.start(ctx, cmd, words)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case res := <-cmd.result:
+		if res.err != nil {
+			return nil, contextError(ctx, fmt.Errorf("readResponse failed: %w", res.err))
+		}
+		return res.rows, nil
+	case <-ctx.Done():
+		c.cancel(tag)
+		return nil, contextError(ctx, fmt.Errorf("command %s aborted", words[0]))
+	}
+}
+
+// cancel stops a command on the router; its remaining replies are discarded by tag
+func (c *Client) cancel(tag string) {
+	if c.forget(tag) {
+		go c.writeSentence("/cancel", "=tag="+tag)
+	}
+}
+
+// forget removes a pending command, reporting whether it was still in flight
+func (c *Client) forget(tag string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.pending[tag]
+	delete(c.pending, tag)
+	return ok
+}
+
+// Stream delivers the rows of a command started with Listen
+type Stream struct {
+	// Rows receives every !re sentence; closed when the command ends
+	Rows <-chan map[string]string
+
+	err error // Set before Rows is closed
+}
+
+// Err reports why the stream ended once Rows is closed
+// It is nil when the command finished on its own and the context error after cancellation.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Listen starts a command that keeps replying until it is cancelled, such as
+// "/interface/listen" or "/interface/monitor-traffic" without =once=
+// Rows is closed when ctx is done (the command is then cancelled on the router),
+// when the command finishes or when the connection fails. Rows must be drained:
+// the connection's reader waits for the consumer of a stream.
+func (c *Client) Listen(ctx context.Context, words ...string) (*Stream, error) {
+	cmd := &pendingCommand{
+		result: make(chan commandResult, 1),
+		stream: make(chan map[string]string),
+		stop:   make(chan struct{}),
+	}
+	tag, err := c.start(ctx, cmd, words)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make(chan map[string]string)
+	stream := &Stream{Rows: rows}
+	go func() {
+		defer close(rows)
+		defer close(cmd.stop)
+		for {
+			select {
+			case row := <-cmd.stream:
+				select {
+				case rows <- row:
+				case <-ctx.Done():
+					c.cancel(tag)
+					stream.err = ctx.Err()
+					return
+				}
+			case res := <-cmd.result:
+				stream.err = res.err
+				return
+			case <-ctx.Done():
+				c.cancel(tag)
+				stream.err = ctx.Err()
+				return
+			}
+		}
+	}()
+	return stream, nil
+}
+
+// contextError prefers the context error when the command failed due to cancellation
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%w (%v)", ctxErr, err)
+	}
+	return err
+}
+
+// login authenticates the connection
+// RouterOS 6.43+ accepts the password directly; older versions reply with a
+// challenge that is answered with an MD5 hash. A rejected login matches ErrAuthFailed.
+func (c *Client) login(ctx context.Context, username, password string, method LoginMethod) error {
+	if method == LoginChallenge {
+		rows, err := c.Run(ctx, "/login")
+		if err != nil {
+			return authError(err)
+		}
+		challenge, ok := loginChallenge(rows)
+		if !ok {
+			return fmt.Errorf("router sent no login challenge (RouterOS 6.43 and newer need the plain login)")
+		}
+		return c.challengeLogin(ctx, username, password, challenge)
+	}
+
+	rows, err := c.Run(ctx, "/login", "=name="+username, "=password="+password)
+	if err != nil {
+		return authError(err)
+	}
+	challenge, ok := loginChallenge(rows)
+	if !ok {
+		return nil
+	}
+
+	// A router before 6.43 ignores the password and replies with a challenge
+	if method == LoginPlain {
+		return fmt.Errorf("router requires the challenge login (RouterOS before 6.43)")
+	}
+	return c.challengeLogin(ctx, username, password, challenge)
+}
+
+// loginChallenge returns the =ret= challenge of a /login reply
+func loginChallenge(rows []map[string]string) (string, bool) {
+	if len(rows) == 0 {
+		return "", false
+	}
+	challenge, ok := rows[0]["ret"]
+	return challenge, ok
+}
+
+// challengeLogin answers a login challenge with "00" + md5(0x00 + password + challenge)
+func (c *Client) challengeLogin(ctx context.Context, username, password, challenge string) error {
+	raw, err := hex.DecodeString(challenge)
+	if err != nil {
+		return fmt.Errorf("invalid login challenge %q", challenge)
+	}
+	hash := md5.Sum(append(append([]byte{0}, password...), raw...))
+
+	rows, err := c.Run(ctx, "/login", "=name="+username, "=response=00"+hex.EncodeToString(hash[:]))
+	if err != nil {
+		return authError(err)
+	}
+	// Another challenge means the response was not accepted
+	if _, again := loginChallenge(rows); again {
+		return fmt.Errorf("%w: challenge response not accepted", ErrAuthFailed)
+	}
+	return nil
+}
+
+// detectVersion reads the RouterOS version, which also verifies the login
+// A user without the read policy still connects, with an unknown version.
+func (c *Client) detectVersion(ctx context.Context) error {
+	rows, err := c.Run(ctx, "/system/resource/print", "=.proplist=version,board-name")
+	var trap *TrapError
+	if errors.As(err, &trap) {
+		if strings.HasPrefix(trap.Message, "not logged in") {
+			return fmt.Errorf("%w: %s", ErrAuthFailed, trap.Message)
+		}
+		c.debug("detectVersion: %v", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	c.boardName = rows[0]["board-name"]
+	if c.version, err = ParseVersion(rows[0]["version"]); err != nil {
+		c.debug("detectVersion: %v", err)
+	}
+	return nil
+}
+
+// authError marks a !trap reply to /login as ErrAuthFailed
+func authError(err error) error {
+	var trap *TrapError
+	if errors.As(err, &trap) {
+		return fmt.Errorf("%w: %s", ErrAuthFailed, trap.Message)
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package routeros_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firadio/golang-mikrotik-interface-stats/pkg/routeros"
+	"github.com/firadio/golang-mikrotik-interface-stats/pkg/routeros/routerostest"
+)
+
+// newTestRouter returns a router accepting monitor/secret with a clock advanced by hand
+func newTestRouter() (*routerostest.Router, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	router := routerostest.NewRouter()
+	router.Username, router.Password = "monitor", "secret"
+	router.Now = func() time.Time { return now }
+	return router, &now
+}
+
+func dialTestRouter(t *testing.T, router *routerostest.Router, opts routeros.Options) *routeros.Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := routeros.NewClient(ctx, router.Pipe(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestClientLogin(t *testing.T) {
+	router, _ := newTestRouter()
+	router.Version, router.BoardName = "7.14.2 (stable)", "RB5009UG+S+"
+
+	var transcript bytes.Buffer
+	client := dialTestRouter(t, router, routeros.Options{Username: "monitor", Password: "secret", Record: &transcript})
+	if got, want := client.Version(), (routeros.Version{Major: 7, Minor: 14, Patch: 2, Channel: "stable"}); got != want {
+		t.Errorf("Version() = %v, want %v", got, want)
+	}
+	if got := client.BoardName(); got != "RB5009UG+S+" {
+		t.Errorf("BoardName() = %q, want RB5009UG+S+", got)
+	}
+	if !strings.Contains(transcript.String(), `"/login" "=name=monitor" "=password=*" ".tag=1"`) {
+		t.Errorf("plain login not sent (password redacted):\n%s", transcript.String())
+	}
+
+	for _, opts := range []routeros.Options{
+		{Username: "monitor", Password: "wrong"},
+		{Username: "admin", Password: "secret"},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := routeros.NewClient(ctx, router.Pipe(), opts)
+		cancel()
+		if !errors.Is(err, routeros.ErrAuthFailed) {
+			t.Errorf("login as %s/%s: error %v, want ErrAuthFailed", opts.Username, opts.Password, err)
+		}
+	}
+}
+
+func TestClientPipelining(t *testing.T) {
+	router, now := newTestRouter()
+	const interfaces = 20
+	for i := 1; i <= interfaces; i++ {
+		router.AddInterface(fmt.Sprintf("ether%d", i), routerostest.Sine{RxMean: float64(i) * 1000, TxMean: 500})
+	}
+	*now = now.Add(10 * time.Second)
+
+	var transcript bytes.Buffer
+	client := dialTestRouter(t, router, routeros.Options{Username: "monitor", Password: "secret", Record: &transcript})
+
+	// Concurrent commands share the connection; each reply must reach its own caller
+	var wg sync.WaitGroup
+	errs := make(chan error, interfaces)
+	for i := 1; i <= interfaces; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("ether%d", i)
+			rows, err := client.Run(context.Background(), "/interface/print", "=.proplist=name,rx-byte", "?name="+name)
+			var rx int
+			if err == nil && len(rows) == 1 {
+				rx, _ = strconv.Atoi(rows[0]["rx-byte"])
+			}
+			switch {
+			case err != nil:
+				errs <- fmt.Errorf("%s: %v", name, err)
+			case len(rows) != 1 || rows[0]["name"] != name:
+				errs <- fmt.Errorf("%s: got rows %v", name, rows)
+			case rx < i*10000-1 || rx > i*10000:
+				// Counters are truncated integrals of the rate
+				errs <- fmt.Errorf("%s: rx-byte %s after 10s at %d B/s", name, rows[0]["rx-byte"], i*1000)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// Login, version detection, then one tag per command
+	for tag := 1; tag <= interfaces+2; tag++ {
+		if !strings.Contains(transcript.String(), `"!done" ".tag=`+strconv.Itoa(tag)+`"`) {
+			t.Errorf("no reply with .tag=%d in the transcript", tag)
+		}
+	}
+}
+
+func TestClientTrap(t *testing.T) {
+	router, _ := newTestRouter()
+	client := dialTestRouter(t, router, routeros.Options{Username: "monitor", Password: "secret"})
+
+	_, err := client.Run(context.Background(), "/routing/bgp/session/print")
+	var trap *routeros.TrapError
+	if !errors.As(err, &trap) || !errors.Is(err, routeros.ErrNoSuchCommand) {
+		t.Fatalf("unknown command: error %v, want a no such command trap", err)
+	}
+	if trap.Category != routeros.CategoryNone || trap.Message != "no such command prefix" {
+		t.Errorf("trap %+v", trap)
+	}
+
+	// A trap ends one command, not the connection
+	rows, err := client.Run(context.Background(), "/system/identity/print")
+	if err != nil || len(rows) != 1 || rows[0]["name"] != "routerostest" {
+		t.Errorf("command after a trap: rows %v, error %v", rows, err)
+	}
+}
+
+func TestClientFatal(t *testing.T) {
+	router, _ := newTestRouter()
+	client := dialTestRouter(t, router, routeros.Options{Username: "monitor", Password: "secret"})
+
+	_, err := client.Run(context.Background(), "/quit")
+	var fatal *routeros.FatalError
+	if !errors.As(err, &fatal) || !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("/quit: error %v, want a FatalError matching net.ErrClosed", err)
+	}
+	if fatal.Message != "session terminated on request" {
+		t.Errorf("fatal message %q", fatal.Message)
+	}
+
+	// Every later command fails without being sent
+	if _, err := client.Run(context.Background(), "/system/identity/print"); !errors.As(err, &fatal) {
+		t.Errorf("command after !fatal: error %v, want the FatalError", err)
+	}
+}
@@ -0,0 +1,135 @@
+package routeros
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Errors matched with errors.Is against the errors returned by Dial and Run
+var (
+	// ErrAuthFailed means the router rejected the login (wrong user name or password);
+	// retrying with the same credentials cannot succeed
+	ErrAuthFailed = errors.New("authentication failed")
+
+	// ErrPermissionDenied means the user's group lacks the policy for the command
+	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrNoSuchCommand means the menu or command does not exist on this router
+	// (older RouterOS version or missing package)
+	ErrNoSuchCommand = errors.New("no such command")
+
+	// ErrInterrupted means the command was cancelled or interrupted on the router
+	ErrInterrupted = errors.New("command interrupted")
+
+	// ErrProtocol means the reply stream was malformed or out of phase; the
+	// connection is closed and has to be re-established
+	ErrProtocol = errors.New("protocol error")
+)
+
+// TrapCategory is the =category= of a !trap reply
+type TrapCategory int
+
+// Categories defined by the RouterOS API
+const (
+	CategoryNone        TrapCategory = -1 // The reply had no category
+	CategoryMissing     TrapCategory = 0  // Missing item or command
+	CategoryArgument    TrapCategory = 1  // Argument value failure
+	CategoryInterrupted TrapCategory = 2  // Execution of command interrupted
+	CategoryScripting   TrapCategory = 3  // Scripting related failure
+	CategoryGeneral     TrapCategory = 4  // General failure
+	CategoryAPI         TrapCategory = 5  // API related failure
+	CategoryTTY         TrapCategory = 6  // TTY related failure
+	CategoryReturn      TrapCategory = 7  // Value generated with :return
+)
+
+var categoryNames = map[TrapCategory]string{
+	CategoryMissing:     "missing item or command",
+	CategoryArgument:    "argument value failure",
+	CategoryInterrupted: "command interrupted",
+	CategoryScripting:   "scripting failure",
+	CategoryGeneral:     "general failure",
+	CategoryAPI:         "API failure",
+	CategoryTTY:         "TTY failure",
+	CategoryReturn:      "return value",
+}
+
+func (c TrapCategory) String() string {
+	if name, ok := categoryNames[c]; ok {
+		return name
+	}
+	if c == CategoryNone {
+		return "none"
+	}
+	return "category " + strconv.Itoa(int(c))
+}
+
+// TrapError is a !trap reply: the router rejected or aborted a command
+type TrapError struct {
+	Category TrapCategory // CategoryNone if the reply had no =category=
+	Message  string       // The =message= attribute, e.g. "no such command"
+}
+
+// newTrapError builds a TrapError from the attributes of a !trap sentence
+func newTrapError(attrs map[string]string) *TrapError {
+	trap := &TrapError{Category: CategoryNone, Message: attrs["message"]}
+	if category, err := strconv.Atoi(attrs["category"]); err == nil {
+		trap.Category = TrapCategory(category)
+	}
+	return trap
+}
+
+func (e *TrapError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = "!trap"
+	}
+	if e.Category != CategoryNone {
+		return fmt.Sprintf("error response: %s (%s)", message, e.Category)
+	}
+	return "error response: " + message
+}
+
+// Is matches the sentinel errors by category and message
+func (e *TrapError) Is(target error) bool {
+	switch target {
+	case ErrPermissionDenied:
+		return strings.HasPrefix(e.Message, "not enough permissions")
+	case ErrNoSuchCommand:
+		return strings.HasPrefix(e.Message, "no such command")
+	case ErrInterrupted:
+		return e.Category == CategoryInterrupted
+	}
+	return false
+}
+
+// FatalError is a !fatal reply: the router is closing the connection
+// It matches net.ErrClosed with errors.Is.
+type FatalError struct {
+	Message string
+}
+
+func (e *FatalError) Error() string {
+	return "router closed the connection (" + e.Message + ")"
+}
+
+func (e *FatalError) Unwrap() error {
+	return net.ErrClosed
+}
+
+// ProtocolError is a reply stream that cannot be decoded (oversized word,
+// invalid length prefix, sentence not starting with a reply word)
+// It matches ErrProtocol and net.ErrClosed with errors.Is.
+type ProtocolError struct {
+	Reason string
+}
+
+func (e *ProtocolError) Error() string {
+	return "protocol error: " + e.Reason
+}
+
+func (e *ProtocolError) Unwrap() []error {
+	return []error{ErrProtocol, net.ErrClosed}
+}
@@ -0,0 +1,135 @@
+package routeros
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ============================================================================
+// Word Codec (length-prefixed words, sentences end with an empty word)
+// ============================================================================
+
+// encodeWord appends a word to buf using the RouterOS API length encoding
+func encodeWord(buf []byte, w string) []byte {
+	length := len(w)
+	var lengthBytes []byte
+
+	if length < 0x80 {
+		lengthBytes = []byte{byte(length)}
+	} else if length < 0x4000 {
+		lengthBytes = []byte{byte(length>>8) | 0x80, byte(length)}
+	} else if length < 0x200000 {
+		lengthBytes = []byte{byte(length>>16) | 0xC0, byte(length >> 8), byte(length)}
+	} else if length < 0x10000000 {
+		lengthBytes = []byte{byte(length>>24) | 0xE0, byte(length >> 16), byte(length >> 8), byte(length)}
+	} else {
+		lengthBytes = []byte{0xF0, byte(length >> 24), byte(length >> 16), byte(length >> 8), byte(length)}
+	}
+
+	buf = append(buf, lengthBytes...)
+	return append(buf, w...)
+}
+
+// maxSentenceWords bounds the number of words in one reply sentence
+const maxSentenceWords = 4096
+
+// readWord reads one length-prefixed word of at most maxLength bytes
+// Reads block until data arrives or the connection closes.
+func readWord(r *bufio.Reader, maxLength int) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	length, extra, err := lengthPrefix(b)
+	if err != nil {
+		return "", err
+	}
+	for i := 0; i < extra; i++ {
+		next, err := r.ReadByte()
+		if err != nil {
+			return "", unexpectedEOF(err)
+		}
+		length = length<<8 | int(next)
+	}
+
+	if length == 0 {
+		return "", nil
+	}
+	if length > maxLength {
+		return "", &ProtocolError{Reason: fmt.Sprintf("word of %d bytes exceeds the %d byte limit", length, maxLength)}
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", unexpectedEOF(err)
+	}
+
+	return string(data), nil
+}
+
+// lengthPrefix decodes the first byte of a word: the high bits of the length and
+// how many more length bytes follow (selected by the number of leading one bits)
+func lengthPrefix(b byte) (length, extra int, err error) {
+	switch {
+	case b&0x80 == 0:
+		return int(b), 0, nil
+	case b&0xC0 == 0x80:
+		return int(b &^ 0xC0), 1, nil
+	case b&0xE0 == 0xC0:
+		return int(b &^ 0xE0), 2, nil
+	case b&0xF0 == 0xE0:
+		return int(b &^ 0xF0), 3, nil
+	case b == 0xF0:
+		return 0, 4, nil
+	}
+	// 0xF1-0xFF are reserved control bytes, never sent in a reply
+	return 0, 0, &ProtocolError{Reason: fmt.Sprintf("invalid length prefix 0x%02X", b)}
+}
+
+// unexpectedEOF reports a connection closed in the middle of a word
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readSentence reads the words of one reply sentence up to the empty terminator word
+// Every word is passed to debugf when it is set. A sentence must start with a reply
+// word ("!re", "!done", ...); anything else means the stream is out of phase.
+func readSentence(r *bufio.Reader, maxWordLength int, debugf func(format string, args ...any)) ([]string, error) {
+	var words []string
+	for {
+		word, err := readWord(r, maxWordLength)
+		if err != nil {
+			return nil, err
+		}
+		if word == "" {
+			if len(words) == 0 {
+				continue // Stray delimiter
+			}
+			return words, nil
+		}
+		if debugf != nil {
+			debugf("readSentence: word=%q", word)
+		}
+		if len(words) == 0 && !strings.HasPrefix(word, "!") {
+			return nil, &ProtocolError{Reason: fmt.Sprintf("sentence starts with %q instead of a reply word", truncate(word, 32))}
+		}
+		if len(words) == maxSentenceWords {
+			return nil, &ProtocolError{Reason: fmt.Sprintf("sentence longer than %d words", maxSentenceWords)}
+		}
+		words = append(words, word)
+	}
+}
+
+// truncate shortens s to at most n bytes for error messages
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n] + "..."
+	}
+	return s
+}
@@ -0,0 +1,205 @@
+package routeros
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// Print Queries (=.proplist=, ?query words and print flags)
+// ============================================================================
+
+// Filter is a condition of a print query, or a print flag such as Detail
+// Conditions are combined with AND unless grouped with Or or OneOf.
+type Filter struct {
+	words []string
+	err   error
+}
+
+// Print flags, passed along with the filters
+var (
+	// Detail adds =detail= (every property, including the ones print hides by default)
+	Detail = Flag("detail")
+
+	// Stats adds =stats= (live counters of /interface and similar menus)
+	Stats = Flag("stats")
+)
+
+// Flag adds a print argument without a value ("detail", "stats", "without-paging", ...)
+func Flag(name string) Filter {
+	return Filter{words: []string{"=" + name + "="}, err: checkName(name)}
+}
+
+// Equal matches rows whose property equals value
+func Equal(name, value string) Filter {
+	return Filter{words: []string{"?" + name + "=" + value}, err: checkName(name)}
+}
+
+// Less matches rows whose property is less than value
+func Less(name, value string) Filter {
+	return Filter{words: []string{"?<" + name + "=" + value}, err: checkName(name)}
+}
+
+// Greater matches rows whose property is greater than value
+func Greater(name, value string) Filter {
+	return Filter{words: []string{"?>" + name + "=" + value}, err: checkName(name)}
+}
+
+// Has matches rows that have the property
+func Has(name string) Filter {
+	return Filter{words: []string{"?" + name}, err: checkName(name)}
+}
+
+// Missing matches rows that lack the property
+func Missing(name string) Filter {
+	return Filter{words: []string{"?-" + name}, err: checkName(name)}
+}
+
+// Not negates a filter
+func Not(f Filter) Filter {
+	return Filter{words: append(append([]string{}, f.words...), "?#!"), err: f.err}
+}
+
+// Or matches rows matching any of the filters (all rows when there are none)
+func Or(filters ...Filter) Filter {
+	var or Filter
+	for i, f := range filters {
+		or.words = append(or.words, f.words...)
+		if i >= 1 {
+			or.words = append(or.words, "?#|") // OR operator after each condition from 2nd onwards
+		}
+		if or.err == nil {
+			or.err = f.err
+		}
+	}
+	return or
+}
+
+// OneOf matches rows whose property equals one of the values (all rows when there are none)
+func OneOf(name string, values ...string) Filter {
+	filters := make([]Filter, len(values))
+	for i, value := range values {
+		filters[i] = Equal(name, value)
+	}
+	return Or(filters...)
+}
+
+// checkName rejects property names that would change the meaning of a word
+// Values need no escaping: words are length-prefixed, so "=", newlines and any
+// other byte in a value reach the router verbatim. A name is the part of the
+// word the router splits on, and its first character selects the operator.
+func checkName(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty property name")
+	}
+	if strings.ContainsAny(name[:1], "-<>#") || strings.ContainsFunc(name, func(r rune) bool {
+		return r == '=' || r == ',' || r <= ' ' || r == 0x7f
+	}) {
+		return fmt.Errorf("invalid property name %q", name)
+	}
+	return nil
+}
+
+// QueryCommand builds the words of a print command: path ("/interface" or
+// "/interface/print"), the properties to return (all when empty) and filters
+// The words can be passed to Run, or to any transport taking API words.
+func QueryCommand(path string, props []string, filters ...Filter) ([]string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid menu path %q", path)
+	}
+	if !strings.HasSuffix(path, "/print") {
+		path = strings.TrimSuffix(path, "/") + "/print"
+	}
+
+	words := []string{path}
+	if len(props) > 0 {
+		for _, prop := range props {
+			if err := checkName(prop); err != nil {
+				return nil, err
+			}
+		}
+		words = append(words, "=.proplist="+strings.Join(props, ","))
+	}
+
+	// Flags go before the query words, which the router reads as a stack
+	var query []string
+	for _, f := range filters {
+		if f.err != nil {
+			return nil, f.err
+		}
+		for _, word := range f.words {
+			if strings.HasPrefix(word, "=") {
+				words = append(words, word)
+			} else {
+				query = append(query, word)
+			}
+		}
+	}
+	return append(words, query...), nil
+}
+
+// Query runs a print command built by QueryCommand
+//
+//	rows, err := client.Query(ctx, "/queue/simple", []string{"name", "bytes"},
+//		routeros.OneOf("name", "guest", "office"))
+func (c *Client) Query(ctx context.Context, path string, props []string, filters ...Filter) ([]Row, error) {
+	words, err := QueryCommand(path, props, filters...)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.Run(ctx, words...)
+	if err != nil {
+		return nil, err
+	}
+	return Rows(rows), nil
+}
+
+// Row is one reply row (property name -> value) with typed accessors
+type Row map[string]string
+
+// Rows converts the rows returned by Run
+func Rows(rows []map[string]string) []Row {
+	converted := make([]Row, len(rows))
+	for i, row := range rows {
+		converted[i] = row
+	}
+	return converted
+}
+
+// String returns a property ("" if missing)
+func (r Row) String(name string) string {
+	return r[name]
+}
+
+// Bool reports whether a property is "true" or "yes"
+func (r Row) Bool(name string) bool {
+	return r[name] == "true" || r[name] == "yes"
+}
+
+// Uint parses an unsigned property such as a byte counter
+func (r Row) Uint(name string) (uint64, error) {
+	value, ok := r[name]
+	if !ok {
+		return 0, fmt.Errorf("missing property %s", name)
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return n, nil
+}
+
+// Int parses a signed property
+func (r Row) Int(name string) (int64, error) {
+	value, ok := r[name]
+	if !ok {
+		return 0, fmt.Errorf("missing property %s", name)
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return n, nil
+}
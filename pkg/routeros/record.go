@@ -0,0 +1,513 @@
+package routeros
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Session Recording (transcripts of the words exchanged with a router)
+// ============================================================================
+//
+// A transcript has one line per sentence: the direction (">" sent, "<" received),
+// the seconds since the connection was opened and the words, quoted like Go strings:
+//
+//	> 0.000 "/login" "=name=monitor" "=password=*" ".tag=1"
+//	< 0.004 "!done" ".tag=1"
+//	< 9.120 raw f1000102
+//	< 9.500 EOF
+//
+// Received bytes that do not decode into words (or whose length prefixes are not the
+// shortest encoding) are kept as "raw" hex, so the exact stream can be replayed.
+// Passwords and challenge responses are redacted. Lines starting with '#' are comments.
+
+// redactedWords are the command attributes whose values are not recorded
+var redactedWords = []string{"=password=", "=response="}
+
+// Record returns a connection that writes a transcript of the session to w
+// w is closed with the connection if it is an io.Closer. A failing w stops the
+// recording, not the connection.
+//
+//	client, err := routeros.NewClient(ctx, routeros.Record(conn, file), opts)
+func Record(conn net.Conn, w io.Writer) net.Conn {
+	c := &recordConn{Conn: conn, w: w, started: time.Now()}
+	c.comment("RouterOS API session with %s, recorded %s", conn.RemoteAddr(), c.started.Format(time.RFC3339))
+	return c
+}
+
+// recordConn writes every sentence passing through a connection to a transcript
+type recordConn struct {
+	net.Conn
+	started time.Time
+
+	mu       sync.Mutex // Guards everything below (reads and writes run concurrently)
+	w        io.Writer
+	err      error  // First error writing the transcript; recording stops
+	commands []byte // Sent bytes not yet forming a whole sentence
+	replies  []byte // Received bytes not yet forming a whole sentence
+	raw      bool   // The received stream could not be decoded: the rest is hex
+	closed   bool
+}
+
+func (c *recordConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n > 0 {
+		c.replies = append(c.replies, p[:n]...)
+		c.recordReplies()
+	}
+	if err == io.EOF {
+		c.flushReplies()
+		c.line("<", "EOF")
+	}
+	return n, err
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	c.commands = append(c.commands, p...)
+	for {
+		words, n, err := decodeSentence(c.commands)
+		if err != nil || n == 0 {
+			break // Only the client writes here, so the stream is always well-formed
+		}
+		c.commands = c.commands[n:]
+		c.line(">", quoteWords(redact(words)))
+	}
+	c.mu.Unlock()
+
+	return c.Conn.Write(p)
+}
+
+// Close closes the connection and ends the transcript
+func (c *recordConn) Close() error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		c.flushReplies()
+		if closer, ok := c.w.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
+
+// recordReplies writes the complete sentences among the received bytes
+func (c *recordConn) recordReplies() {
+	for !c.raw && len(c.replies) > 0 {
+		words, n, err := decodeSentence(c.replies)
+		if err != nil {
+			c.raw = true
+			break
+		}
+		if n == 0 {
+			return // Wait for the rest of the sentence
+		}
+		if encoded := encodeSentence(words); !bytes.Equal(encoded, c.replies[:n]) {
+			c.line("<", "raw "+hex.EncodeToString(c.replies[:n]))
+		} else {
+			c.line("<", quoteWords(words))
+		}
+		c.replies = c.replies[n:]
+	}
+	c.flushReplies()
+}
+
+// flushReplies writes the received bytes that are not a whole sentence as hex
+// once the stream is known to be undecodable or has ended
+func (c *recordConn) flushReplies() {
+	if len(c.replies) > 0 && (c.raw || c.closed) {
+		c.line("<", "raw "+hex.EncodeToString(c.replies))
+		c.replies = nil
+	}
+}
+
+// line writes one transcript line
+func (c *recordConn) line(direction, payload string) {
+	c.printf("%s %.3f %s\n", direction, time.Since(c.started).Seconds(), payload)
+}
+
+func (c *recordConn) comment(format string, args ...any) {
+	c.printf("# "+format+"\n", args...)
+}
+
+func (c *recordConn) printf(format string, args ...any) {
+	if c.err == nil {
+		_, c.err = fmt.Fprintf(c.w, format, args...)
+	}
+}
+
+// redact hides the values of password words
+func redact(words []string) []string {
+	redacted := make([]string, len(words))
+	for i, word := range words {
+		redacted[i] = word
+		for _, prefix := range redactedWords {
+			if strings.HasPrefix(word, prefix) {
+				redacted[i] = prefix + "*"
+			}
+		}
+	}
+	return redacted
+}
+
+// quoteWords formats words as space-separated Go string literals
+func quoteWords(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = strconv.Quote(word)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// encodeSentence encodes words followed by the empty terminator word
+func encodeSentence(words []string) []byte {
+	var buf []byte
+	for _, word := range words {
+		buf = encodeWord(buf, word)
+	}
+	return encodeWord(buf, "")
+}
+
+// decodeSentence decodes the sentence at the start of buf
+// n is the number of bytes it takes, or 0 if buf does not hold a whole sentence yet.
+func decodeSentence(buf []byte) (words []string, n int, err error) {
+	for n < len(buf) {
+		length, extra, err := lengthPrefix(buf[n])
+		if err != nil {
+			return nil, 0, err
+		}
+		if n+1+extra > len(buf) {
+			return nil, 0, nil
+		}
+		for _, b := range buf[n+1 : n+1+extra] {
+			length = length<<8 | int(b)
+		}
+		n += 1 + extra
+		if length == 0 {
+			return words, n, nil
+		}
+		if length > len(buf)-n {
+			return nil, 0, nil
+		}
+		words = append(words, string(buf[n:n+length]))
+		n += length
+	}
+	return nil, 0, nil
+}
+
+// ============================================================================
+// Session Replay
+// ============================================================================
+
+// ReplayConn is a connection that plays back a recorded session
+//
+// Each sentence the client sends is matched with a recorded command (ignoring tags
+// and redacted values), and the replies recorded for that command are delivered
+// with the client's tag, so the client and its parser see the router's replies
+// even if commands are issued in another order than recorded. Raw bytes and
+// untagged replies (such as !fatal) stay in their recorded position. Sending a
+// command that is not in the recording fails the write.
+type ReplayConn struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	commands []*replayCommand
+	replies  []*replayReply
+	tags     map[string]string // Recorded tag -> client tag of matched commands
+	sent     []byte            // Written bytes not yet forming a whole sentence
+	ready    []byte            // Released reply bytes not yet read
+	eof      bool              // The recorded end of the stream was released
+	closed   bool
+}
+
+// replayCommand is a recorded command
+type replayCommand struct {
+	words   []string
+	tag     string
+	matched bool
+}
+
+// replayReply is a recorded reply sentence, raw bytes or the end of the stream
+type replayReply struct {
+	words    []string // nil for raw bytes and EOF
+	data     []byte   // Raw bytes
+	tag      string   // "" = delivered in recorded order
+	after    int      // Number of commands recorded before it
+	eof      bool
+	released bool
+}
+
+// Replay reads a transcript written by Record and returns a connection playing it back
+//
+//	conn, err := routeros.Replay(file)
+//	client, err := routeros.NewClient(ctx, conn, routeros.Options{Username: "monitor"})
+func Replay(r io.Reader) (*ReplayConn, error) {
+	c := &ReplayConn{tags: make(map[string]string)}
+	c.cond = sync.NewCond(&c.mu)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 2*DefaultMaxWordLength+1024) // Raw hex of a maximal word
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 3)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected direction and time", line)
+		}
+		payload := ""
+		if len(fields) == 3 {
+			payload = fields[2]
+		}
+
+		switch {
+		case fields[0] == ">":
+			words, err := unquoteWords(payload)
+			if err != nil || len(words) == 0 {
+				return nil, fmt.Errorf("line %d: invalid command: %v", line, err)
+			}
+			c.commands = append(c.commands, &replayCommand{words: words, tag: sentenceTag(words)})
+		case fields[0] == "<" && payload == "EOF":
+			c.replies = append(c.replies, &replayReply{eof: true, after: len(c.commands)})
+		case fields[0] == "<" && strings.HasPrefix(payload, "raw "):
+			data, err := hex.DecodeString(payload[len("raw "):])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid raw bytes: %v", line, err)
+			}
+			c.replies = append(c.replies, &replayReply{data: data, after: len(c.commands)})
+		case fields[0] == "<":
+			words, err := unquoteWords(payload)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid reply: %v", line, err)
+			}
+			reply := &replayReply{words: append([]string{}, words...), after: len(c.commands)} // Non-nil: an empty sentence is not raw
+			if len(words) > 0 && words[0] != "!fatal" {
+				reply.tag = sentenceTag(words)
+			}
+			c.replies = append(c.replies, reply)
+		default:
+			return nil, fmt.Errorf("line %d: unknown direction %q", line, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(c.commands) == 0 {
+		return nil, fmt.Errorf("recording has no commands")
+	}
+
+	c.release()
+	return c, nil
+}
+
+// Commands returns the recorded commands in order, without their .tag words
+func (c *ReplayConn) Commands() [][]string {
+	commands := make([][]string, len(c.commands))
+	for i, cmd := range c.commands {
+		for _, word := range cmd.words {
+			if !strings.HasPrefix(word, ".tag=") {
+				commands[i] = append(commands[i], word)
+			}
+		}
+	}
+	return commands
+}
+
+// Pending returns the recorded commands that have not been sent yet, without their .tag words
+func (c *ReplayConn) Pending() [][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var pending [][]string
+	for i, words := range c.Commands() {
+		if !c.commands[i].matched {
+			pending = append(pending, words)
+		}
+	}
+	return pending
+}
+
+// Read returns the replies released so far, blocking until there are some
+func (c *ReplayConn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.ready) == 0 && !c.eof && !c.closed {
+		c.cond.Wait()
+	}
+	switch {
+	case c.closed:
+		return 0, net.ErrClosed
+	case len(c.ready) == 0:
+		return 0, io.EOF
+	}
+	n := copy(p, c.ready)
+	c.ready = c.ready[n:]
+	return n, nil
+}
+
+// Write matches the sent sentences with recorded commands and releases their replies
+func (c *ReplayConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	c.sent = append(c.sent, p...)
+	for {
+		words, n, err := decodeSentence(c.sent)
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			return len(p), nil
+		}
+		c.sent = c.sent[n:]
+
+		cmd := c.match(words)
+		if cmd == nil {
+			return 0, fmt.Errorf("replay: command %s was not recorded", quoteWords(redact(words)))
+		}
+		cmd.matched = true
+		if cmd.tag != "" {
+			c.tags[cmd.tag] = sentenceTag(words)
+		}
+		c.release()
+	}
+}
+
+// match returns the first unmatched recorded command equal to words
+func (c *ReplayConn) match(words []string) *replayCommand {
+	sent := commandKey(words)
+	for _, cmd := range c.commands {
+		if !cmd.matched && commandKey(cmd.words) == sent {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// release delivers the recorded replies that are due, in recorded order
+// Tagged replies are due once their command was sent; raw bytes, untagged
+// replies and EOF once the commands and replies recorded before them were.
+func (c *ReplayConn) release() {
+	waiting := false // An earlier reply is still waiting for its command
+	for _, reply := range c.replies {
+		if reply.released {
+			continue
+		}
+		if reply.tag == "" {
+			if waiting || !c.commandsSent(reply.after) {
+				break
+			}
+		} else if _, ok := c.tags[reply.tag]; !ok {
+			waiting = true
+			continue
+		}
+
+		reply.released = true
+		switch {
+		case reply.eof:
+			c.eof = true
+		case reply.words == nil:
+			c.ready = append(c.ready, reply.data...)
+		default:
+			words := make([]string, len(reply.words))
+			for i, word := range reply.words {
+				if strings.HasPrefix(word, ".tag=") {
+					word = ".tag=" + c.tags[reply.tag]
+				}
+				words[i] = word
+			}
+			c.ready = append(c.ready, encodeSentence(words)...)
+		}
+	}
+	c.cond.Broadcast()
+}
+
+// commandsSent reports whether the first n recorded commands were sent
+func (c *ReplayConn) commandsSent(n int) bool {
+	for _, cmd := range c.commands[:n] {
+		if !cmd.matched {
+			return false
+		}
+	}
+	return true
+}
+
+// Close ends the replay; blocked reads fail with net.ErrClosed
+func (c *ReplayConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.cond.Broadcast()
+	return nil
+}
+
+func (c *ReplayConn) LocalAddr() net.Addr                { return replayAddr{} }
+func (c *ReplayConn) RemoteAddr() net.Addr               { return replayAddr{} }
+func (c *ReplayConn) SetDeadline(t time.Time) error      { return nil }
+func (c *ReplayConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *ReplayConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// replayAddr is the address of both ends of a ReplayConn
+type replayAddr struct{}
+
+func (replayAddr) Network() string { return "replay" }
+func (replayAddr) String() string  { return "replay" }
+
+// commandKey identifies a command regardless of its tag and redacted values
+// (the =tag= of /cancel refers to a tag, so its value is ignored too).
+func commandKey(words []string) string {
+	var key []string
+	for _, word := range words {
+		if strings.HasPrefix(word, ".tag=") {
+			continue
+		}
+		for _, prefix := range []string{"=password=", "=response=", "=tag="} {
+			if strings.HasPrefix(word, prefix) {
+				word = prefix
+			}
+		}
+		key = append(key, word)
+	}
+	return quoteWords(key)
+}
+
+// sentenceTag returns the value of a sentence's .tag word ("" if untagged)
+func sentenceTag(words []string) string {
+	for _, word := range words {
+		if tag, ok := strings.CutPrefix(word, ".tag="); ok {
+			return tag
+		}
+	}
+	return ""
+}
+
+// unquoteWords parses space-separated Go string literals
+func unquoteWords(s string) ([]string, error) {
+	var words []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		word, _ := strconv.Unquote(quoted)
+		words = append(words, word)
+		s = s[len(quoted):]
+	}
+	return words, nil
+}
@@ -0,0 +1,475 @@
+// Package routerostest provides a fake RouterOS router speaking the API protocol
+//
+// A Router answers logins, /interface/print with counters generated by a Traffic
+// (sine waves or replayed traces), /system/resource/print and /system/health/print,
+// so programs built on package routeros can be demonstrated and integration-tested
+// without a router:
+//
+//	router := routerostest.NewRouter()
+//	router.AddInterface("ether1", routerostest.Sine{RxMean: 5e6, TxMean: 1e6, Amplitude: 0.5, Period: time.Hour})
+//	client, err := routeros.NewClient(ctx, router.Pipe(), routeros.Options{})
+//
+// Other commands are answered with a "no such command" trap, like a router
+// missing the package. /quit ends the session with a !fatal reply.
+package routerostest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/firadio/golang-mikrotik-interface-stats/pkg/routeros"
+)
+
+// Router is a fake router; it is safe for concurrent use by several connections
+type Router struct {
+	Username, Password string // Credentials accepted by /login (empty Username = any)
+	Version            string // Reported by /system/resource/print (default: "7.16 (stable)")
+	BoardName          string // Reported by /system/resource/print (default: "CHR")
+
+	// Now returns the current time (default: time.Now); tests can advance it by hand
+	Now func() time.Time
+
+	// Unknown creates the traffic of interfaces queried by name that were not added
+	// (nil = such names match nothing, like on a router)
+	Unknown func(name string) Traffic
+
+	mu         sync.Mutex
+	started    time.Time
+	interfaces map[string]*fakeInterface
+}
+
+// fakeInterface is the counter state of one interface
+type fakeInterface struct {
+	id        string // .id
+	name      string
+	kind      string // "ether", "vlan", ...
+	traffic   Traffic
+	rx, tx    float64 // Counters (bytes)
+	updated   time.Time
+	running   bool
+	disabled  bool
+	linkDowns uint64
+}
+
+// NewRouter creates a router without interfaces
+func NewRouter() *Router {
+	return &Router{interfaces: make(map[string]*fakeInterface)}
+}
+
+func (r *Router) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// AddInterface adds a running interface whose counters follow traffic
+// The type is guessed from the name prefix (vlan, bridge, bonding, ...; default ether).
+func (r *Router) AddInterface(name string, traffic Traffic) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addInterface(name, traffic)
+}
+
+func (r *Router) addInterface(name string, traffic Traffic) {
+	kind := "ether"
+	for _, prefix := range []string{"vlan", "bridge", "bonding", "pppoe-out", "wlan", "wg"} {
+		if strings.HasPrefix(name, prefix) {
+			kind = prefix
+			break
+		}
+	}
+	r.interfaces[name] = &fakeInterface{
+		id:      fmt.Sprintf("*%X", len(r.interfaces)+1),
+		name:    name,
+		kind:    kind,
+		traffic: traffic,
+		updated: r.now(),
+		running: true,
+	}
+}
+
+// SetRunning brings an interface's link up or down; going down counts a link-down
+// A down interface carries no traffic.
+func (r *Router) SetRunning(name string, running bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	iface, ok := r.interfaces[name]
+	if !ok {
+		return fmt.Errorf("no such interface %s", name)
+	}
+	r.advance(iface, r.now())
+	if iface.running && !running {
+		iface.linkDowns++
+	}
+	iface.running = running
+	return nil
+}
+
+// Counters returns an interface's byte counters at the current time
+func (r *Router) Counters(name string) (rx, tx uint64, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	iface, ok := r.interfaces[name]
+	if !ok {
+		return 0, 0, false
+	}
+	r.advance(iface, r.now())
+	return uint64(iface.rx), uint64(iface.tx), true
+}
+
+// maxSteps bounds the integration work after a long pause
+const maxSteps = 3600
+
+// advance integrates the traffic since the last update (trapezoids of at most a second)
+func (r *Router) advance(iface *fakeInterface, now time.Time) {
+	elapsed := now.Sub(iface.updated)
+	if elapsed <= 0 {
+		return
+	}
+	if iface.running && !iface.disabled && iface.traffic != nil {
+		steps := min(int(elapsed/time.Second)+1, maxSteps)
+		step := elapsed / time.Duration(steps)
+		t := iface.updated
+		rx0, tx0 := iface.traffic.Rate(t)
+		for i := 0; i < steps; i++ {
+			next := t.Add(step)
+			rx1, tx1 := iface.traffic.Rate(next)
+			iface.rx += (rx0 + rx1) / 2 * step.Seconds()
+			iface.tx += (tx0 + tx1) / 2 * step.Seconds()
+			t, rx0, tx0 = next, rx1, tx1
+		}
+	}
+	iface.updated = now
+}
+
+// ============================================================================
+// Connections
+// ============================================================================
+
+// Pipe returns the client end of an in-memory connection served by the router
+func (r *Router) Pipe() net.Conn {
+	client, server := net.Pipe()
+	go r.Serve(server)
+	return client
+}
+
+// Listen serves connections on a TCP address (e.g. "127.0.0.1:0") until the listener is closed
+func (r *Router) Listen(address string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go r.Serve(conn)
+		}
+	}()
+	return listener, nil
+}
+
+// Serve answers the commands of one connection until it is closed
+// Commands must log in first, like on a router.
+func (r *Router) Serve(conn net.Conn) error {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	loggedIn := false
+
+	for {
+		words, err := readSentence(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe) {
+				return nil
+			}
+			return err
+		}
+
+		command, attrs, names, tag := parseCommand(words)
+		var rows []map[string]string
+		var trap *routeros.TrapError
+		switch {
+		case command == "/login":
+			if r.Username != "" && (attrs["name"] != r.Username || attrs["password"] != r.Password) {
+				trap = &routeros.TrapError{Category: routeros.CategoryNone, Message: "invalid user name or password (6)"}
+			} else {
+				loggedIn = true
+			}
+		case !loggedIn:
+			trap = &routeros.TrapError{Category: routeros.CategoryNone, Message: "not logged in"}
+		case command == "/cancel":
+			// Every command completes at once, so there is nothing to cancel
+		case command == "/quit":
+			// Untagged, and the router hangs up right after it
+			conn.Write(appendWord(appendWord(appendWord(nil, "!fatal"), "session terminated on request"), ""))
+			return nil
+		default:
+			rows, trap = r.Command(command, names)
+		}
+
+		var reply []byte
+		for _, row := range rows {
+			reply = appendSentence(reply, "!re", tag, selectProps(row, attrs[".proplist"]))
+		}
+		if trap != nil {
+			fields := map[string]string{"message": trap.Message}
+			if trap.Category != routeros.CategoryNone {
+				fields["category"] = strconv.Itoa(int(trap.Category))
+			}
+			reply = appendSentence(reply, "!trap", tag, fields)
+		}
+		reply = appendSentence(reply, "!done", tag, nil)
+		if _, err := conn.Write(reply); err != nil {
+			return nil
+		}
+	}
+}
+
+// Command runs a command and returns its rows, or the trap a router would reply with
+// names are the ?name= conditions of a print query (any of them matches; nil = all).
+func (r *Router) Command(command string, names []string) ([]map[string]string, *routeros.TrapError) {
+	switch command {
+	case "/interface/print":
+		return r.interfaceRows(names), nil
+	case "/system/resource/print":
+		return []map[string]string{r.resource()}, nil
+	case "/system/health/print":
+		return []map[string]string{
+			{"name": "cpu-temperature", "value": "47", "type": "C"},
+			{"name": "voltage", "value": "24.1", "type": "V"},
+		}, nil
+	case "/system/identity/print":
+		return []map[string]string{{"name": "routerostest"}}, nil
+	}
+	return nil, &routeros.TrapError{Category: routeros.CategoryNone, Message: "no such command prefix"}
+}
+
+// interfaceRows returns the /interface/print rows of the named interfaces (sorted by name)
+func (r *Router) interfaceRows(names []string) []map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+		if _, ok := r.interfaces[name]; !ok && r.Unknown != nil {
+			r.addInterface(name, r.Unknown(name))
+		}
+	}
+
+	now := r.now()
+	var rows []map[string]string
+	for name, iface := range r.interfaces {
+		if len(wanted) > 0 && !wanted[name] {
+			continue
+		}
+		r.advance(iface, now)
+		rows = append(rows, map[string]string{
+			".id":         iface.id,
+			"name":        name,
+			"type":        iface.kind,
+			"mtu":         "1500",
+			"actual-mtu":  "1500",
+			"mac-address": macAddress(name),
+			"rx-byte":     strconv.FormatUint(uint64(iface.rx), 10),
+			"tx-byte":     strconv.FormatUint(uint64(iface.tx), 10),
+			"running":     strconv.FormatBool(iface.running),
+			"disabled":    strconv.FormatBool(iface.disabled),
+			"link-downs":  strconv.FormatUint(iface.linkDowns, 10),
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i]["name"] < rows[j]["name"] })
+	return rows
+}
+
+// resource returns the /system/resource/print row
+func (r *Router) resource() map[string]string {
+	r.mu.Lock()
+	if r.started.IsZero() {
+		r.started = r.now()
+	}
+	uptime := r.now().Sub(r.started).Round(time.Second)
+	r.mu.Unlock()
+
+	version, board := r.Version, r.BoardName
+	if version == "" {
+		version = "7.16 (stable)"
+	}
+	if board == "" {
+		board = "CHR"
+	}
+	return map[string]string{
+		"version":      version,
+		"board-name":   board,
+		"architecture": "x86_64",
+		"uptime":       routerOSDuration(uptime),
+		"cpu-load":     "7",
+		"free-memory":  "805306368",
+		"total-memory": "1073741824",
+		"cpu-count":    "2",
+	}
+}
+
+// routerOSDuration formats a duration like RouterOS ("1w2d3h4m5s")
+func routerOSDuration(d time.Duration) string {
+	seconds := int64(d.Seconds())
+	var b strings.Builder
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"w", 604800}, {"d", 86400}, {"h", 3600}, {"m", 60}} {
+		if seconds >= unit.size {
+			fmt.Fprintf(&b, "%d%s", seconds/unit.size, unit.suffix)
+			seconds %= unit.size
+		}
+	}
+	fmt.Fprintf(&b, "%ds", seconds)
+	return b.String()
+}
+
+// macAddress derives a stable locally administered MAC address from a name
+func macAddress(name string) string {
+	var hash uint32 = 2166136261
+	for i := 0; i < len(name); i++ {
+		hash = (hash ^ uint32(name[i])) * 16777619
+	}
+	return fmt.Sprintf("02:00:%02X:%02X:%02X:%02X", byte(hash>>24), byte(hash>>16), byte(hash>>8), byte(hash))
+}
+
+// ============================================================================
+// Word Codec (server side)
+// ============================================================================
+
+// parseCommand splits a command sentence into the command, its =attributes=,
+// the values of its ?name= conditions and its .tag
+func parseCommand(words []string) (command string, attrs map[string]string, names []string, tag string) {
+	attrs = make(map[string]string)
+	for i, word := range words {
+		switch {
+		case i == 0:
+			command = word
+		case strings.HasPrefix(word, ".tag="):
+			tag = word[len(".tag="):]
+		case strings.HasPrefix(word, "?name="):
+			names = append(names, word[len("?name="):])
+		case strings.HasPrefix(word, "="):
+			if key, value, ok := strings.Cut(word[1:], "="); ok {
+				attrs[key] = value
+			}
+		}
+	}
+	return command, attrs, names, tag
+}
+
+// selectProps keeps the properties listed in =.proplist= (all if empty)
+func selectProps(row map[string]string, proplist string) map[string]string {
+	if proplist == "" {
+		return row
+	}
+	selected := make(map[string]string)
+	for _, name := range strings.Split(proplist, ",") {
+		if value, ok := row[name]; ok {
+			selected[name] = value
+		}
+	}
+	return selected
+}
+
+// appendSentence encodes a reply sentence with its tag and =attributes= (sorted)
+func appendSentence(buf []byte, reply, tag string, fields map[string]string) []byte {
+	buf = appendWord(buf, reply)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		buf = appendWord(buf, "="+key+"="+fields[key])
+	}
+	if tag != "" {
+		buf = appendWord(buf, ".tag="+tag)
+	}
+	return appendWord(buf, "")
+}
+
+// appendWord appends a word with the RouterOS API length prefix
+func appendWord(buf []byte, word string) []byte {
+	n := len(word)
+	switch {
+	case n < 0x80:
+		buf = append(buf, byte(n))
+	case n < 0x4000:
+		buf = append(buf, byte(n>>8)|0x80, byte(n))
+	case n < 0x200000:
+		buf = append(buf, byte(n>>16)|0xC0, byte(n>>8), byte(n))
+	case n < 0x10000000:
+		buf = append(buf, byte(n>>24)|0xE0, byte(n>>16), byte(n>>8), byte(n))
+	default:
+		buf = append(buf, 0xF0, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(buf, word...)
+}
+
+// maxCommandWord bounds the words a client may send
+const maxCommandWord = 1 << 20
+
+// readSentence reads the words of one command up to the empty terminator word
+func readSentence(r *bufio.Reader) ([]string, error) {
+	var words []string
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		var length, extra int
+		switch {
+		case b&0x80 == 0:
+			length = int(b)
+		case b&0xC0 == 0x80:
+			length, extra = int(b&^0xC0), 1
+		case b&0xE0 == 0xC0:
+			length, extra = int(b&^0xE0), 2
+		case b&0xF0 == 0xE0:
+			length, extra = int(b&^0xF0), 3
+		case b == 0xF0:
+			extra = 4
+		default:
+			return nil, fmt.Errorf("invalid length prefix 0x%02X", b)
+		}
+		for i := 0; i < extra; i++ {
+			next, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			length = length<<8 | int(next)
+		}
+
+		if length == 0 {
+			if len(words) == 0 {
+				continue
+			}
+			return words, nil
+		}
+		if length > maxCommandWord {
+			return nil, fmt.Errorf("word of %d bytes is too long", length)
+		}
+		word := make([]byte, length)
+		if _, err := io.ReadFull(r, word); err != nil {
+			return nil, err
+		}
+		words = append(words, string(word))
+	}
+}
@@ -0,0 +1,173 @@
+package routerostest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Synthetic Traffic (sine waves and replayed counter traces)
+// ============================================================================
+
+// Traffic generates the traffic of a fake interface
+type Traffic interface {
+	// Rate returns the receive and transmit rates at t, in bytes/s
+	Rate(t time.Time) (rx, tx float64)
+}
+
+// Sine is traffic oscillating around a mean rate, like a daily load curve
+type Sine struct {
+	RxMean, TxMean float64       // Mean rates (bytes/s)
+	Amplitude      float64       // Swing as a fraction of the mean (0-1)
+	Period         time.Duration // Length of one cycle (default: 24h)
+	Phase          time.Duration // Shift of the cycle, to desynchronize interfaces
+	Noise          float64       // Random jitter as a fraction of the rate (0 = smooth)
+}
+
+// Rate implements Traffic
+func (s Sine) Rate(t time.Time) (rx, tx float64) {
+	period := s.Period
+	if period <= 0 {
+		period = 24 * time.Hour
+	}
+	angle := 2 * math.Pi * float64((t.UnixNano()+int64(s.Phase))%int64(period)) / float64(period)
+	factor := 1 + s.Amplitude*math.Sin(angle)
+	return s.jitter(s.RxMean * factor), s.jitter(s.TxMean * factor)
+}
+
+func (s Sine) jitter(rate float64) float64 {
+	if s.Noise > 0 {
+		rate *= 1 + s.Noise*(2*rand.Float64()-1)
+	}
+	return math.Max(rate, 0)
+}
+
+// Replay is traffic replayed from recorded counters, looping over the trace
+// The rate between two samples is constant; the router integrates it in steps of
+// up to a second, so replayed counters follow the recording to within a step.
+type Replay struct {
+	offsets []float64 // Seconds since the first sample
+	rx, tx  []uint64  // Counters at each sample
+}
+
+// Duration returns the length of one loop
+func (p *Replay) Duration() time.Duration {
+	if len(p.offsets) == 0 {
+		return 0
+	}
+	return time.Duration(p.offsets[len(p.offsets)-1] * float64(time.Second))
+}
+
+// Rate implements Traffic: the rate of the recorded interval t falls into
+// Counter resets in the trace (a lower value than the previous sample) read as idle.
+func (p *Replay) Rate(t time.Time) (rx, tx float64) {
+	if len(p.offsets) < 2 {
+		return 0, 0
+	}
+	length := p.offsets[len(p.offsets)-1]
+	offset := math.Mod(float64(t.UnixNano())/1e9, length)
+	i := sort.SearchFloat64s(p.offsets, offset)
+	if i == 0 {
+		i = 1
+	}
+	if p.offsets[i] == offset && i < len(p.offsets)-1 {
+		i++ // The sample ends the previous interval
+	}
+	elapsed := p.offsets[i] - p.offsets[i-1]
+	return counterRate(p.rx[i-1], p.rx[i], elapsed), counterRate(p.tx[i-1], p.tx[i], elapsed)
+}
+
+func counterRate(previous, current uint64, elapsed float64) float64 {
+	if current < previous || elapsed <= 0 {
+		return 0
+	}
+	return float64(current-previous) / elapsed
+}
+
+// LoadTrace reads recorded counters for Replay, keyed by interface
+//
+// The trace is CSV with the columns time, interface, rx-byte and tx-byte; time is
+// Unix seconds (fractions allowed) or RFC 3339. A header row and rows starting with
+// '#' are skipped. Rows need not be sorted, and every interface needs two samples.
+//
+//	time,interface,rx-byte,tx-byte
+//	1700000000,ether1,1000000,500000
+//	1700000001,ether1,1125000,562500
+func LoadTrace(r io.Reader) (map[string]*Replay, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+
+	type sample struct {
+		at     float64
+		rx, tx uint64
+	}
+	samples := make(map[string][]sample)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		at, err := parseTraceTime(record[0])
+		if err != nil {
+			if line == 1 {
+				continue // Header
+			}
+			return nil, fmt.Errorf("line %d: invalid time %q", line, record[0])
+		}
+		rx, err := strconv.ParseUint(record[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rx-byte %q", line, record[2])
+		}
+		tx, err := strconv.ParseUint(record[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid tx-byte %q", line, record[3])
+		}
+		samples[record[1]] = append(samples[record[1]], sample{at, rx, tx})
+	}
+
+	traces := make(map[string]*Replay, len(samples))
+	for name, list := range samples {
+		if len(list) < 2 {
+			return nil, fmt.Errorf("interface %s: at least two samples needed", name)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].at < list[j].at })
+		replay := &Replay{}
+		for _, s := range list {
+			replay.offsets = append(replay.offsets, s.at-list[0].at)
+			replay.rx = append(replay.rx, s.rx)
+			replay.tx = append(replay.tx, s.tx)
+		}
+		if replay.Duration() <= 0 {
+			return nil, fmt.Errorf("interface %s: samples span no time", name)
+		}
+		traces[name] = replay
+	}
+	if len(traces) == 0 {
+		return nil, fmt.Errorf("trace has no samples")
+	}
+	return traces, nil
+}
+
+// parseTraceTime parses Unix seconds or an RFC 3339 time
+func parseTraceTime(value string) (float64, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return seconds, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
+	return float64(t.UnixNano()) / 1e9, nil
+}
@@ -0,0 +1,68 @@
+package routeros
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a RouterOS version as reported by /system/resource ("7.14.2 (stable)")
+// The zero Version means the version is unknown.
+type Version struct {
+	Major, Minor, Patch int
+	Channel             string // "stable", "long-term", "testing", ... ("" if not reported)
+}
+
+// ParseVersion parses "6.49.10 (long-term)", "7.15rc3 (testing)" or "7.14"
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	number, channel, _ := strings.Cut(strings.TrimSpace(s), " ")
+	v.Channel = strings.Trim(channel, "()")
+
+	// Pre-releases ("7.15rc3", "7.15beta2") are treated as the release they precede
+	if i := strings.IndexAny(number, "abcr"); i > 0 {
+		number = number[:i]
+	}
+	parts := strings.Split(number, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid RouterOS version %q", s)
+	}
+	fields := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid RouterOS version %q", s)
+		}
+		*fields[i] = n
+	}
+	return v, nil
+}
+
+// Known reports whether the version was detected
+func (v Version) Known() bool {
+	return v.Major > 0
+}
+
+// AtLeast reports whether the version is major.minor or newer
+func (v Version) AtLeast(major, minor int) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+func (v Version) String() string {
+	if !v.Known() {
+		return "unknown"
+	}
+	s := fmt.Sprintf("%d.%d", v.Major, v.Minor)
+	if v.Patch > 0 {
+		s += fmt.Sprintf(".%d", v.Patch)
+	}
+	if v.Channel != "" {
+		s += " (" + v.Channel + ")"
+	}
+	return s
+}
+
+// PlainLogin reports whether the router accepts the password in /login (6.43 and newer)
+func (v Version) PlainLogin() bool {
+	return v.AtLeast(6, 43)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Prometheus Pushgateway Output
+// ============================================================================
+//
+// Completed aggregation windows are pushed with the same series as the
+// VictoriaMetrics output (mikrotik_interface_rx_rate_avg{interface,interval}, ...)
+// to a grouping key identifying this monitor:
+//
+//   <url>/metrics/job/<job>/instance/<MIKROTIK_HOST>/<EXTRA_LABELS>/<PUSHGATEWAY_GROUPING>
+//
+// pushgateway mode replaces the group with PUT on every window; samples carry no
+// timestamps (the Pushgateway rejects them) and are scraped as current values.
+// vmagent mode posts to the Pushgateway-compatible import path of vmagent or
+// VictoriaMetrics (<url>/api/v1/import/prometheus/metrics/job/...), which adds the
+// grouping labels to every series and keeps the window timestamps.
+
+// PushgatewayOutput implements OutputWriter by aggregating samples into time windows
+// and pushing each completed window to a Prometheus Pushgateway (or vmagent)
+type PushgatewayOutput struct {
+	config     *PushgatewayConfig
+	httpClient *http.Client
+	aggregator *TimeWindowAggregator
+	flusher    *windowFlusher // Completes windows during outages and at shutdown
+	url        string         // Push URL including the grouping key
+
+	userConfig   *UserConfigManager // Interface labels added as label="..." (nil = none)
+	metricPrefix string             // Metric name prefix replacing "mikrotik_" (METRIC_PREFIX)
+	rateScale    float64            // Multiplier from bytes/s to the metric unit (8 for METRIC_UNIT=bits)
+}
+
+// NewPushgatewayOutput creates a Pushgateway output
+// routerHost and extraLabels identify the router in the grouping key; sampleInterval is the poll interval
+func NewPushgatewayOutput(config *PushgatewayConfig, sampleInterval time.Duration, routerHost string, extraLabels map[string]string,
+	userConfig *UserConfigManager, metricPrefix string, rateScale float64) *PushgatewayOutput {
+	// Later sources override earlier ones
+	grouping := map[string]string{"instance": routerHost}
+	for k, v := range extraLabels {
+		grouping[k] = v
+	}
+	for k, v := range config.Grouping {
+		grouping[k] = v
+	}
+
+	base := strings.TrimSuffix(config.URL, "/")
+	if config.Mode == "vmagent" {
+		base += "/api/v1/import/prometheus"
+	}
+
+	o := &PushgatewayOutput{
+		config:       config,
+		httpClient:   &http.Client{Timeout: config.Timeout},
+		aggregator:   NewTimeWindowAggregator(config.Interval, sampleInterval),
+		url:          base + groupingPath(config.Job, grouping),
+		userConfig:   userConfig,
+		metricPrefix: metricPrefix,
+		rateScale:    rateScale,
+	}
+	o.flusher = newWindowFlusher(o.aggregator, o.send)
+
+	logInfo("Pushgateway", "Output initialized (%s mode, %s, interval: %v)", config.Mode, o.url, config.Interval)
+	return o
+}
+
+// groupingPath encodes a grouping key as /metrics/job/<job>/<name>/<value>...
+// Values that are empty or contain a slash use the name@base64/<value> form.
+func groupingPath(job string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var path strings.Builder
+	path.WriteString("/metrics")
+	writeGroupingLabel(&path, "job", job)
+	for _, name := range names {
+		writeGroupingLabel(&path, name, labels[name])
+	}
+	return path.String()
+}
+
+func writeGroupingLabel(path *strings.Builder, name, value string) {
+	if value == "" || strings.Contains(value, "/") {
+		encoded := base64.URLEncoding.EncodeToString([]byte(value))
+		if encoded == "" {
+			encoded = "=" // Empty value
+		}
+		fmt.Fprintf(path, "/%s@base64/%s", name, encoded)
+		return
+	}
+	fmt.Fprintf(path, "/%s/%s", name, url.PathEscape(value))
+}
+
+func (o *PushgatewayOutput) WriteHeader() {}
+
+func (o *PushgatewayOutput) WriteStats(timestamp time.Time, stats map[string]*RateInfo) {
+	for _, rateInfo := range stats {
+		o.aggregator.AddSample(timestamp, rateInfo)
+	}
+
+	o.flusher.sendCompleted()
+}
+
+// send pushes one completed window
+func (o *PushgatewayOutput) send(window *AggregationWindow) {
+	if err := o.push(window); err != nil {
+		logError("Pushgateway", "Failed to push window ending %s: %v", window.EndTime.Format("15:04:05"), err)
+	}
+}
+
+// Close pushes the last (partial) window, then deletes the group if PUSHGATEWAY_DELETE_ON_STOP is set
+func (o *PushgatewayOutput) Close() {
+	o.flusher.Close()
+
+	if o.config.DeleteOnStop {
+		if err := o.request(http.MethodDelete, nil); err != nil {
+			logError("Pushgateway", "Failed to delete group: %v", err)
+		} else {
+			logInfo("Pushgateway", "Deleted group %s", o.url)
+		}
+	}
+}
+
+// SetSampleInterval follows poll interval changes (see sampleIntervalSetter)
+func (o *PushgatewayOutput) SetSampleInterval(now time.Time, sampleInterval time.Duration) {
+	o.aggregator.SetSampleInterval(now, sampleInterval)
+}
+
+// push sends one aggregation window
+func (o *PushgatewayOutput) push(window *AggregationWindow) error {
+	if len(window.Interfaces) == 0 {
+		return nil
+	}
+
+	metrics := renameMetrics(windowMetrics(window, o.rateScale, o.userConfig), o.metricPrefix)
+	if o.config.Mode == "vmagent" {
+		return o.request(http.MethodPost, []byte(metrics))
+	}
+	// PUT replaces the whole group, so interfaces removed by a reload disappear
+	return o.request(http.MethodPut, pushgatewayExposition(metrics))
+}
+
+// request sends body (nil for DELETE) to the grouping key URL
+func (o *PushgatewayOutput) request(method string, body []byte) error {
+	req, err := http.NewRequest(method, o.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	}
+	for k, v := range o.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s returned %s: %s", method, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// pushgatewayExposition rewrites timestamped Prometheus text for the Pushgateway:
+// samples are grouped by metric family under a TYPE line and timestamps removed
+func pushgatewayExposition(metrics string) []byte {
+	families := make(map[string][]string)
+	for _, line := range strings.Split(metrics, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		nameEnd := strings.IndexAny(line, "{ ")
+		if nameEnd <= 0 {
+			continue
+		}
+		// windowMetrics writes `series value timestamp`: drop what follows the last space
+		line = line[:strings.LastIndexByte(line, ' ')]
+		name := line[:nameEnd]
+		families[name] = append(families[name], line)
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		kind := "gauge"
+		if strings.HasSuffix(name, "_total") {
+			kind = "counter"
+		}
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", name, kind)
+		for _, line := range families[name] {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ============================================================================
+// Configuration Reload (SIGHUP / env file changes)
+// ============================================================================
+
+// ConfigReloader reloads the configuration on SIGHUP and when the env file changes
+// A configuration that fails to load or validate is rejected and the running one kept.
+type ConfigReloader struct {
+	envFile  string
+	interval time.Duration // Env file poll interval (0 = SIGHUP only)
+	apply    func(*Config)
+
+	modTime time.Time // Env file state at the last load
+	size    int64
+}
+
+// NewConfigReloader creates a reloader that hands every valid new configuration to apply
+func NewConfigReloader(envFile string, interval time.Duration, apply func(*Config)) *ConfigReloader {
+	if envFile == "" {
+		interval = 0 // Environment variables only: nothing to watch
+	}
+	r := &ConfigReloader{envFile: envFile, interval: interval, apply: apply}
+	r.modTime, r.size = fileState(envFile)
+	if interval > 0 {
+		logInfo("Reload", "Watching %s for changes (every %v, or send SIGHUP)", envFile, interval)
+	}
+	return r
+}
+
+// fileState returns the modification time and size of a file (zero if missing)
+func fileState(path string) (time.Time, int64) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
+
+// Run waits for SIGHUP or env file changes until ctx is cancelled
+func (r *ConfigReloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	// A nil channel never fires, so watching is off without a ticker
+	var tick <-chan time.Time
+	if r.interval > 0 {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			logInfo("Reload", "SIGHUP received, reloading configuration")
+			r.reload()
+		case <-tick:
+			modTime, size := fileState(r.envFile)
+			if modTime.Equal(r.modTime) && size == r.size {
+				continue
+			}
+			logInfo("Reload", "%s changed, reloading configuration", r.envFile)
+			r.reload()
+		}
+	}
+}
+
+// reload loads and validates the configuration and applies it
+func (r *ConfigReloader) reload() {
+	r.modTime, r.size = fileState(r.envFile)
+	config, err := LoadConfig(r.envFile)
+	if err != nil {
+		logError("Reload", "Configuration rejected, keeping the running one: %v", err)
+		return
+	}
+	r.apply(config)
+}
+
+// reloadableFields are the Config fields applied at runtime by Monitor.applyConfig
+// Changes to any other field are reported as requiring a restart.
+var reloadableFields = map[string]bool{
+	"Interfaces":       true,
+	"UplinkInterfaces": true,
+	"Groups":           true,
+	"StatsWindowSize":  true,
+	"Debug":            true,
+	"LogLevel":         true,
+	"LogFormat":        true,
+	"OutputTimeout":    true,
+	"PollInterval":     true,
+	"PollAlign":        true,
+	"PollAdaptive":     true,
+	"PollMaxInterval":  true,
+	"DisabledOutputs":  true,
+	"ReloadInterval":   true, // Takes effect on restart, but harmless to change
+	"Password":         true, // Used by the next reconnect
+	"CredentialSource": true,
+	"Terminal":         true,
+	"Log":              true,
+	"Alerts":           true,
+}
+
+// Reload applies a configuration loaded from the environment, with the admin API overrides on top
+func (m *Monitor) Reload(config *Config) {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+
+	m.loaded = config
+	overrides := m.userConfig.ConfigOverrides()
+	m.queueConfig(overrides.Apply(config))
+}
+
+// queueConfig queues a new configuration for the monitoring loop
+// A configuration still waiting to be applied is replaced.
+func (m *Monitor) queueConfig(config *Config) {
+	select {
+	case <-m.reloads:
+	default:
+	}
+	m.reloads <- config
+}
+
+// applyConfig applies a reloaded configuration between samples (monitoring loop goroutine)
+// Only the components affected by a change are re-initialized; rates, the stats
+// window and WebSocket clients are kept.
+func (m *Monitor) applyConfig(next *Config) {
+	prev := m.config
+	var applied []string
+	changed := func(field string) bool {
+		return !reflect.DeepEqual(reflect.ValueOf(prev).Elem().FieldByName(field).Interface(),
+			reflect.ValueOf(next).Elem().FieldByName(field).Interface())
+	}
+
+	interfacesChanged := changed("Interfaces") || changed("Groups")
+	if interfacesChanged {
+		m.interfaces, m.groups = next.Interfaces, next.Groups
+
+		// Forget removed interfaces; new ones start with the next sample
+		keep := toSet(next.Interfaces)
+		for _, group := range next.Groups {
+			keep[group.Name] = true
+		}
+		for _, port := range m.memberPorts {
+			keep[port] = true // Until the discovery drops them
+		}
+		for name := range m.rateMap {
+			if !keep[name] {
+				delete(m.rateMap, name)
+			}
+		}
+		if m.members != nil {
+			m.members.SetInterfaces(next.Interfaces)
+		}
+		if m.linkSpeeds != nil {
+			m.linkSpeeds.SetInterfaces(m.polledInterfaces())
+		}
+		if m.webServer != nil {
+			m.webServer.SetInterfaces(next.Interfaces, next.Groups)
+		}
+		applied = append(applied, "interfaces")
+	}
+
+	if changed("UplinkInterfaces") {
+		m.userConfig.SetDefaultUplinks(next.UplinkInterfaces)
+		applied = append(applied, "uplinks")
+	}
+
+	windowChanged := changed("StatsWindowSize")
+	if windowChanged {
+		// Histories are resized, keeping the most recent samples
+		n := next.StatsWindowSize
+		m.statsWindowSize = n
+		for _, rate := range m.rateMap {
+			rate.RxHistory = resizeHistory(rate.chronological(rate.RxHistory), n)
+			rate.TxHistory = resizeHistory(rate.chronological(rate.TxHistory), n)
+			rate.HistoryCount = min(rate.HistoryCount, n)
+			rate.HistoryIndex = rate.HistoryCount % n
+		}
+		applied = append(applied, "stats window")
+	}
+
+	if changed("LogLevel") || changed("LogFormat") || changed("Debug") {
+		setupLogging(next.LogLevel, next.LogFormat)
+		applied = append(applied, "log level")
+	}
+
+	if changed("OutputTimeout") {
+		m.outputs.timeout = next.OutputTimeout
+		applied = append(applied, "output timeout")
+	}
+
+	if changed("PollInterval") || changed("PollAlign") || changed("PollAdaptive") || changed("PollMaxInterval") {
+		m.interval, m.align = next.PollInterval, next.PollAlign // The monitoring loop resets its ticker
+		m.backoff = newPollBackoff(next)
+		m.outputs.SetSampleInterval(time.Now(), m.pollInterval())
+		applied = append(applied, "poll interval")
+	}
+
+	// Terminal: settings are applied in place; switching mode needs the keyboard setup of a restart
+	var restart []string
+	switch {
+	case (prev.Terminal == nil) != (next.Terminal == nil) || (prev.Terminal != nil && prev.Terminal.Mode != next.Terminal.Mode):
+		restart = append(restart, "Terminal")
+	case next.Terminal != nil && (changed("Terminal") || interfacesChanged || windowChanged):
+		m.terminalWriter.Reconfigure(next.Terminal, next.Groups, next.StatsWindowSize)
+		applied = append(applied, "terminal")
+	}
+
+	if changed("Log") {
+		if next.Log != nil {
+			m.logWriter = NewStructuredLogger(next.Log, m.userConfig, next.ExtraLabels)
+			m.outputs.Replace("log", m.logWriter)
+		} else {
+			m.logWriter = nil
+			m.outputs.Remove("log")
+		}
+		applied = append(applied, "log output")
+	}
+
+	// A re-registered output starts unpaused
+	if changed("DisabledOutputs") || changed("Log") {
+		m.outputs.SetPaused(next.DisabledOutputs)
+		if changed("DisabledOutputs") {
+			applied = append(applied, "output toggles")
+		}
+	}
+
+	if changed("Alerts") {
+		m.alerts = nil
+		if next.Alerts != nil {
+			m.alerts = NewAlertEngine(next.Alerts, m.notifiers, m.userConfig.IsUplink, m.userConfig.CustomLabel)
+		}
+		applied = append(applied, "alerts")
+	}
+
+	// Everything else (connection, web server, storage, collectors) is only read at startup
+	configType := reflect.TypeOf(*next)
+	for i := 0; i < configType.NumField(); i++ {
+		name := configType.Field(i).Name
+		if !reloadableFields[name] && changed(name) {
+			restart = append(restart, name)
+		}
+	}
+
+	// Keep the previous values of settings that were not applied, so they are reported again
+	for _, name := range restart {
+		reflect.ValueOf(next).Elem().FieldByName(name).Set(reflect.ValueOf(prev).Elem().FieldByName(name))
+	}
+	m.configMu.Lock()
+	m.config = next
+	m.configMu.Unlock()
+
+	// Credentials are used by the next reconnect (after a connection error or rejected login)
+	if changed("Password") {
+		if client, ok := m.client.(*instrumentedClient); ok {
+			client.SetConfig(next)
+		}
+	}
+
+	if len(applied) > 0 {
+		logInfo("Reload", "Configuration applied: %s", strings.Join(applied, ", "))
+	} else {
+		logInfo("Reload", "No applicable changes")
+	}
+	if len(restart) > 0 {
+		logWarn("Reload", "Restart required to apply: %s", strings.Join(restart, ", "))
+	}
+}
+
+// resizeHistory builds a ring buffer of size n from values in chronological order, keeping the latest
+func resizeHistory(values []float64, n int) []float64 {
+	if len(values) > n {
+		values = values[len(values)-n:]
+	}
+	resized := make([]float64, n)
+	copy(resized, values)
+	return resized
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ============================================================================
+// Prometheus Remote Write (snappy-compressed protobuf)
+// ============================================================================
+//
+// Metrics are generated in Prometheus text format for /api/v1/import/prometheus.
+// For remote_write (Thanos Receive, Mimir, Cortex, VictoriaMetrics /api/v1/write)
+// the same text is parsed and re-encoded as a prometheus.WriteRequest:
+//
+//   message WriteRequest { repeated TimeSeries timeseries = 1; }
+//   message TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//   message Label        { string name = 1; string value = 2; }
+//   message Sample       { double value = 1; int64 timestamp = 2; }
+//
+// The messages are written field by field with protowire (no generated code for
+// four small messages) and compressed with the snappy block format.
+
+// promSample is one parsed line of Prometheus text format
+type promSample struct {
+	labels    [][2]string // Sorted by name, including __name__
+	value     float64
+	timestamp int64 // Milliseconds
+}
+
+// encodeRemoteWrite converts Prometheus text metrics to a snappy-compressed WriteRequest
+func encodeRemoteWrite(metrics string, now time.Time) ([]byte, error) {
+	var request []byte
+
+	for _, line := range strings.Split(metrics, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sample, err := parsePromLine(line, now)
+		if err != nil {
+			return nil, err
+		}
+		request = appendProtoBytes(request, 1, encodeTimeSeries(sample))
+	}
+
+	return snappy.Encode(nil, request), nil
+}
+
+// parsePromLine parses `name{k="v",...} value [timestamp]`
+func parsePromLine(line string, now time.Time) (promSample, error) {
+	var sample promSample
+
+	nameEnd := strings.IndexAny(line, "{ ")
+	if nameEnd <= 0 {
+		return sample, fmt.Errorf("invalid metric line: %q", line)
+	}
+	sample.labels = append(sample.labels, [2]string{"__name__", line[:nameEnd]})
+	rest := line[nameEnd:]
+
+	// Labels
+	if rest[0] == '{' {
+		rest = rest[1:]
+		for {
+			rest = strings.TrimLeft(rest, " ,")
+			if strings.HasPrefix(rest, "}") {
+				rest = rest[1:]
+				break
+			}
+
+			eq := strings.Index(rest, "=\"")
+			if eq <= 0 {
+				return sample, fmt.Errorf("invalid labels in line: %q", line)
+			}
+			name := strings.TrimSpace(rest[:eq])
+			rest = rest[eq+2:]
+
+			var value strings.Builder
+			closed := false
+			for i := 0; i < len(rest); i++ {
+				c := rest[i]
+				if c == '\\' && i+1 < len(rest) {
+					i++
+					switch rest[i] {
+					case 'n':
+						value.WriteByte('\n')
+					default:
+						value.WriteByte(rest[i])
+					}
+					continue
+				}
+				if c == '"' {
+					rest = rest[i+1:]
+					closed = true
+					break
+				}
+				value.WriteByte(c)
+			}
+			if !closed {
+				return sample, fmt.Errorf("unterminated label value in line: %q", line)
+			}
+			sample.labels = append(sample.labels, [2]string{name, value.String()})
+		}
+	}
+
+	// Value and optional timestamp
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return sample, fmt.Errorf("missing value in line: %q", line)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid value in line: %q", line)
+	}
+	sample.value = value
+
+	sample.timestamp = now.UnixMilli()
+	if len(fields) > 1 {
+		if sample.timestamp, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return sample, fmt.Errorf("invalid timestamp in line: %q", line)
+		}
+	}
+
+	// Remote write requires labels sorted by name
+	sort.Slice(sample.labels, func(i, j int) bool {
+		return sample.labels[i][0] < sample.labels[j][0]
+	})
+
+	return sample, nil
+}
+
+// encodeTimeSeries encodes a single-sample TimeSeries message
+func encodeTimeSeries(sample promSample) []byte {
+	var ts []byte
+	for _, label := range sample.labels {
+		var msg []byte
+		msg = appendProtoBytes(msg, 1, []byte(label[0]))
+		msg = appendProtoBytes(msg, 2, []byte(label[1]))
+		ts = appendProtoBytes(ts, 1, msg)
+	}
+
+	s := appendProtoFixed64(nil, 1, math.Float64bits(sample.value))
+	s = protowire.AppendTag(s, 2, protowire.VarintType)
+	s = protowire.AppendVarint(s, uint64(sample.timestamp))
+	ts = appendProtoBytes(ts, 2, s)
+
+	return ts
+}
+
+// appendProtoBytes appends a length-delimited field (wire type 2)
+func appendProtoBytes(buf []byte, field protowire.Number, data []byte) []byte {
+	buf = protowire.AppendTag(buf, field, protowire.BytesType)
+	return protowire.AppendBytes(buf, data)
+}
+
+// appendProtoString appends a string field (omitted when empty, as in proto3)
+func appendProtoString(buf []byte, field protowire.Number, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	return appendProtoBytes(buf, field, []byte(s))
+}
+
+// appendProtoFixed64 appends a 64-bit field (wire type 1: fixed64, sfixed64 and double)
+func appendProtoFixed64(buf []byte, field protowire.Number, v uint64) []byte {
+	buf = protowire.AppendTag(buf, field, protowire.Fixed64Type)
+	return protowire.AppendFixed64(buf, v)
+}
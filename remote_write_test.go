@@ -0,0 +1,143 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoField is one decoded protobuf field
+type protoField struct {
+	num   protowire.Number
+	typ   protowire.Type
+	bytes []byte // Length-delimited value
+	value uint64 // Varint or fixed64 value
+}
+
+// decodeProto splits a protobuf message into its fields, failing the test on malformed input
+func decodeProto(t *testing.T, msg []byte) []protoField {
+	t.Helper()
+	var fields []protoField
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			t.Fatalf("invalid tag: %v", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+
+		field := protoField{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			field.value, n = protowire.ConsumeVarint(msg)
+		case protowire.Fixed64Type:
+			field.value, n = protowire.ConsumeFixed64(msg)
+		case protowire.BytesType:
+			field.bytes, n = protowire.ConsumeBytes(msg)
+		default:
+			t.Fatalf("field %d: unexpected wire type %d", num, typ)
+		}
+		if n < 0 {
+			t.Fatalf("field %d: %v", num, protowire.ParseError(n))
+		}
+		msg = msg[n:]
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func TestEncodeRemoteWrite(t *testing.T) {
+	now := time.UnixMilli(1700000000123)
+	metrics := strings.Join([]string{
+		"# HELP mikrotik_interface_rx_rate Receive rate",
+		`mikrotik_interface_rx_rate{interface="ether1",instance="router",alias="WAN \"main\""} 1250.5 1699999999000`,
+		"mikrotik_up 1",
+		"",
+	}, "\n")
+
+	body, err := encodeRemoteWrite(metrics, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatalf("reference decoder: %v", err)
+	}
+
+	want := []struct {
+		labels    [][2]string
+		value     float64
+		timestamp int64
+	}{
+		{[][2]string{{"__name__", "mikrotik_interface_rx_rate"}, {"alias", `WAN "main"`}, {"instance", "router"}, {"interface", "ether1"}}, 1250.5, 1699999999000},
+		{[][2]string{{"__name__", "mikrotik_up"}}, 1, now.UnixMilli()},
+	}
+
+	series := decodeProto(t, request)
+	if len(series) != len(want) {
+		t.Fatalf("got %d time series, want %d", len(series), len(want))
+	}
+	for i, ts := range series {
+		if ts.num != 1 || ts.typ != protowire.BytesType {
+			t.Fatalf("series %d: field %d type %d, want WriteRequest.timeseries (1, bytes)", i, ts.num, ts.typ)
+		}
+
+		var labels [][2]string
+		var samples []protoField
+		for _, field := range decodeProto(t, ts.bytes) {
+			switch field.num {
+			case 1:
+				var label [2]string
+				for _, part := range decodeProto(t, field.bytes) {
+					label[part.num-1] = string(part.bytes)
+				}
+				labels = append(labels, label)
+			case 2:
+				samples = append(samples, field)
+			default:
+				t.Fatalf("series %d: unexpected TimeSeries field %d", i, field.num)
+			}
+		}
+
+		if len(labels) != len(want[i].labels) {
+			t.Fatalf("series %d: labels %q, want %q", i, labels, want[i].labels)
+		}
+		for j := range labels {
+			if labels[j] != want[i].labels[j] {
+				t.Errorf("series %d: labels %q, want %q (sorted, __name__ first)", i, labels, want[i].labels)
+				break
+			}
+		}
+
+		if len(samples) != 1 {
+			t.Fatalf("series %d: %d samples, want 1", i, len(samples))
+		}
+		sample := decodeProto(t, samples[0].bytes)
+		if len(sample) != 2 || sample[0].num != 1 || sample[0].typ != protowire.Fixed64Type ||
+			sample[1].num != 2 || sample[1].typ != protowire.VarintType {
+			t.Fatalf("series %d: sample fields %+v, want value (1, double) and timestamp (2, varint)", i, sample)
+		}
+		if value := math.Float64frombits(sample[0].value); value != want[i].value {
+			t.Errorf("series %d: value %v, want %v", i, value, want[i].value)
+		}
+		if timestamp := int64(sample[1].value); timestamp != want[i].timestamp {
+			t.Errorf("series %d: timestamp %d, want %d (milliseconds)", i, timestamp, want[i].timestamp)
+		}
+	}
+}
+
+func TestEncodeRemoteWriteInvalidLine(t *testing.T) {
+	for _, line := range []string{
+		`mikrotik_up{interface="ether1} 1`,
+		"mikrotik_up",
+		"mikrotik_up one",
+		"mikrotik_up 1 soon",
+	} {
+		if _, err := encodeRemoteWrite(line, time.Now()); err == nil {
+			t.Errorf("%q: expected an error", line)
+		}
+	}
+}
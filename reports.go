@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Scheduled Usage Reports (VictoriaMetrics history -> chat / email / file)
+// ============================================================================
+
+// Report is the usage of every interface over one period, with a row per day
+// Figures are in the user perspective (upload/download), bytes and bytes/s.
+type Report struct {
+	Period     string // day, week or month
+	Start, End time.Time
+	Percentile float64
+	Interfaces []ReportInterface
+}
+
+// ReportInterface is one interface's usage per day and for the whole period
+type ReportInterface struct {
+	Name  string
+	Label string
+	Days  []ReportRow
+	Total ReportRow
+}
+
+// ReportRow is the usage over one day (or the whole period for the total)
+type ReportRow struct {
+	Date               time.Time
+	Download, Upload   float64 // bytes
+	DownloadAvg        float64 // bytes/s
+	UploadAvg          float64
+	DownloadPeak       float64
+	UploadPeak         float64
+	DownloadPercentile float64
+	UploadPercentile   float64
+}
+
+// ReportGenerator builds usage reports from the stored history on a cron schedule
+type ReportGenerator struct {
+	config    *ReportConfig
+	location  *time.Location // TIMEZONE: schedule and report periods
+	schedule  *cronSchedule
+	vm        *VMClient
+	notifiers *Notifiers
+	isUplink  func(string) bool
+	labels    func(string) string
+}
+
+// NewReportGenerator creates a report generator; isUplink and labels come from the user configuration
+// The schedule and the report periods follow location (the TIMEZONE zone).
+func NewReportGenerator(config *ReportConfig, location *time.Location, vm *VMClient, notifiers *Notifiers, isUplink func(string) bool, labels func(string) string) *ReportGenerator {
+	schedule, _ := parseCron(config.Schedule) // Validated with the config
+	logInfo("Report", "%s reports scheduled at %q to %s", config.Period, config.Schedule, strings.Join(config.Targets, ", "))
+
+	return &ReportGenerator{
+		config:    config,
+		location:  location,
+		schedule:  schedule,
+		vm:        vm,
+		notifiers: notifiers,
+		isUplink:  isUplink,
+		labels:    labels,
+	}
+}
+
+// Run generates and delivers a report at every scheduled time until ctx is cancelled
+func (g *ReportGenerator) Run(ctx context.Context) {
+	for {
+		next := g.schedule.Next(time.Now().In(g.location))
+		if next.IsZero() {
+			logWarn("Report", "Schedule %q never matches, reports disabled", g.config.Schedule)
+			return
+		}
+		logDebug("Report", "Next report at %s", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			report := g.Generate(now.In(g.location))
+			g.Deliver(report)
+		}
+	}
+}
+
+// reportPeriod returns the last complete period before now (calendar days in now's zone)
+func reportPeriod(now time.Time, period string) (start, end time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case "week":
+		return today.AddDate(0, 0, -7), today
+	case "month":
+		end = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return end.AddDate(0, -1, 0), end
+	default:
+		return today.AddDate(0, 0, -1), today
+	}
+}
+
+// Generate queries the history of the last complete period
+func (g *ReportGenerator) Generate(now time.Time) *Report {
+	start, end := reportPeriod(now, g.config.Period)
+	report := &Report{
+		Period:     g.config.Period,
+		Start:      start,
+		End:        end,
+		Percentile: g.config.Percentile,
+	}
+
+	byName := make(map[string]*ReportInterface)
+	entry := func(name string) *ReportInterface {
+		if iface, ok := byName[name]; ok {
+			return iface
+		}
+		iface := &ReportInterface{Name: name, Label: g.labels(name)}
+		byName[name] = iface
+		return iface
+	}
+
+	// One set of queries per day; a one-day period needs none beyond the total
+	if g.config.Period != "day" {
+		for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+			for name, usage := range g.vm.QueryUsage(g.config.Interfaces, day, day.AddDate(0, 0, 1), g.config.Percentile) {
+				iface := entry(name)
+				iface.Days = append(iface.Days, g.row(day, name, usage))
+			}
+		}
+	}
+	for name, usage := range g.vm.QueryUsage(g.config.Interfaces, start, end, g.config.Percentile) {
+		entry(name).Total = g.row(start, name, usage)
+	}
+
+	for _, iface := range byName {
+		report.Interfaces = append(report.Interfaces, *iface)
+	}
+	sort.Slice(report.Interfaces, func(i, j int) bool {
+		return report.Interfaces[i].Name < report.Interfaces[j].Name
+	})
+	return report
+}
+
+// row converts RX/TX usage to the upload/download perspective
+func (g *ReportGenerator) row(date time.Time, name string, u *UsageStats) ReportRow {
+	row := ReportRow{
+		Date:               date,
+		Download:           u.TxBytes,
+		Upload:             u.RxBytes,
+		DownloadAvg:        u.TxAvg,
+		UploadAvg:          u.RxAvg,
+		DownloadPeak:       u.TxPeak,
+		UploadPeak:         u.RxPeak,
+		DownloadPercentile: u.TxPercentile,
+		UploadPercentile:   u.RxPercentile,
+	}
+	// Uplink: TX = upload; downlink: RX = upload
+	if g.isUplink(name) {
+		row.Download, row.Upload = u.RxBytes, u.TxBytes
+		row.DownloadAvg, row.UploadAvg = u.RxAvg, u.TxAvg
+		row.DownloadPeak, row.UploadPeak = u.RxPeak, u.TxPeak
+		row.DownloadPercentile, row.UploadPercentile = u.RxPercentile, u.TxPercentile
+	}
+	return row
+}
+
+// Title describes the report period
+func (r *Report) Title() string {
+	last := r.End.AddDate(0, 0, -1)
+	if r.Period == "day" {
+		return "Daily traffic report " + r.Start.Format("2006-01-02")
+	}
+	return fmt.Sprintf("%s traffic report %s to %s", map[string]string{"week": "Weekly", "month": "Monthly"}[r.Period],
+		r.Start.Format("2006-01-02"), last.Format("2006-01-02"))
+}
+
+// Text renders the report as a fixed-width table; detail adds a row per day
+func (r *Report) Text(detail bool) string {
+	if len(r.Interfaces) == 0 {
+		return "No data stored for this period"
+	}
+
+	var b strings.Builder
+	pct := fmt.Sprintf("%gth", r.Percentile)
+	for i, iface := range r.Interfaces {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(iface.Name)
+		if iface.Label != "" {
+			b.WriteString(" (" + iface.Label + ")")
+		}
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "  %-10s %9s %9s %11s %11s %11s %11s\n", "", "Down", "Up", "Peak Down", "Peak Up", pct+" Down", pct+" Up")
+		if detail {
+			for _, day := range iface.Days {
+				b.WriteString(day.text(day.Date.Format("2006-01-02")))
+			}
+		}
+		b.WriteString(iface.Total.text("Total"))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// text renders one table row
+func (row ReportRow) text(name string) string {
+	return fmt.Sprintf("  %-10s %9s %9s %11s %11s %11s %11s\n", name,
+		formatByteCount(uint64(row.Download)), formatByteCount(uint64(row.Upload)),
+		formatAlertRate(row.DownloadPeak*8), formatAlertRate(row.UploadPeak*8),
+		formatAlertRate(row.DownloadPercentile*8), formatAlertRate(row.UploadPercentile*8))
+}
+
+// CSV renders one line per interface and day (and a "total" line per interface); rates in bits/s
+func (r *Report) CSV() string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write([]string{"interface", "label", "date", "download_bytes", "upload_bytes",
+		"download_avg_bps", "upload_avg_bps", "download_peak_bps", "upload_peak_bps",
+		"download_percentile_bps", "upload_percentile_bps"})
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 0, 64) }
+	for _, iface := range r.Interfaces {
+		rows := append(append([]ReportRow(nil), iface.Days...), iface.Total)
+		for i, row := range rows {
+			date := row.Date.Format("2006-01-02")
+			if i == len(rows)-1 {
+				date = "total"
+			}
+			w.Write([]string{iface.Name, iface.Label, date, f(row.Download), f(row.Upload),
+				f(row.DownloadAvg * 8), f(row.UploadAvg * 8), f(row.DownloadPeak * 8), f(row.UploadPeak * 8),
+				f(row.DownloadPercentile * 8), f(row.UploadPercentile * 8)})
+		}
+	}
+	w.Flush()
+	return b.String()
+}
+
+// Deliver sends a report to every configured target
+func (g *ReportGenerator) Deliver(report *Report) {
+	logInfo("Report", "%s (%d interfaces)", report.Title(), len(report.Interfaces))
+	targets := toSet(g.config.Targets)
+
+	// Chat messages are kept short: totals only
+	g.notifiers.Send(g.config.Targets, Notification{Title: report.Title(), Text: report.Text(false), Code: true})
+
+	if targets["file"] {
+		if err := g.writeFiles(report); err != nil {
+			logError("Report", "Failed to write report: %v", err)
+		}
+	}
+	if targets["email"] {
+		go func() {
+			if err := g.sendEmail(report); err != nil {
+				logError("Report", "Failed to send report email: %v", err)
+			}
+		}()
+	}
+}
+
+// writeFiles writes the report as text and CSV to REPORT_DIR
+func (g *ReportGenerator) writeFiles(report *Report) error {
+	if err := os.MkdirAll(g.config.Dir, 0755); err != nil {
+		return err
+	}
+	base := filepath.Join(g.config.Dir, fmt.Sprintf("traffic-%s-%s", report.Period, report.Start.Format("2006-01-02")))
+	text := report.Title() + "\n\n" + report.Text(true) + "\n"
+	if err := os.WriteFile(base+".txt", []byte(text), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(base+".csv", []byte(report.CSV()), 0644); err != nil {
+		return err
+	}
+	logInfo("Report", "Report written to %s.{txt,csv}", base)
+	return nil
+}
+
+// sendEmail mails the text report with the CSV attached (STARTTLS when the server offers it)
+func (g *ReportGenerator) sendEmail(report *Report) error {
+	boundary := fmt.Sprintf("report-%d", time.Now().UnixNano())
+	filename := fmt.Sprintf("traffic-%s-%s.csv", report.Period, report.Start.Format("2006-01-02"))
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", g.config.SMTPFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(g.config.EmailTo, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", report.Title())
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().In(g.location).Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&msg, "--%s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n", boundary)
+	msg.WriteString(strings.ReplaceAll(report.Text(true), "\n", "\r\n") + "\r\n")
+	fmt.Fprintf(&msg, "--%s\r\nContent-Type: text/csv; charset=utf-8\r\nContent-Disposition: attachment; filename=%q\r\n\r\n", boundary, filename)
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(report.CSV(), "\r\n", "\n"), "\n", "\r\n"))
+	fmt.Fprintf(&msg, "--%s--\r\n", boundary)
+
+	var auth smtp.Auth
+	if g.config.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", g.config.SMTPUsername, g.config.SMTPPassword, g.config.SMTPHost)
+	}
+	addr := net.JoinHostPort(g.config.SMTPHost, strconv.Itoa(g.config.SMTPPort))
+	return smtp.SendMail(addr, auth, g.config.SMTPFrom, g.config.EmailTo, []byte(msg.String()))
+}
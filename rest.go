@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/firadio/golang-mikrotik-interface-stats/pkg/routeros"
+)
+
+// RouterOS v7 REST API Client
+// Alternative transport for environments where only the www-ssl service is reachable
+// Reference: https://help.mikrotik.com/docs/display/ROS/REST+API
+//
+// API-style command words are translated to REST calls:
+//   /interface/print        -> POST https://host/rest/interface/print
+//   =key=value              -> JSON body attribute {"key": "value"}
+//   =.proplist=a,b          -> {".proplist": ["a", "b"]}
+//   ?name=x ?name=y ?#|     -> {".query": ["name=x", "name=y", "#|"]}
+
+// RESTClient implements RouterClient over the RouterOS v7 REST API
+type RESTClient struct {
+	baseURL    string       // https://host:port/rest
+	username   string       // Basic auth username
+	password   string       // Basic auth password
+	httpClient *http.Client // Reused HTTP client (keep-alive)
+
+	version routeros.Version // Detected when connecting (zero if unknown)
+}
+
+// NewRESTClient creates a REST client and verifies credentials with a lightweight request
+func NewRESTClient(ctx context.Context, config *Config) (*RESTClient, error) {
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: config.RESTInsecure,
+		},
+		MaxIdleConnsPerHost: 2,
+	}
+
+	host, port, err := resolveRouterAddress(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	client := &RESTClient{
+		baseURL:  "https://" + net.JoinHostPort(host, port) + "/rest",
+		username: config.Username,
+		password: config.Password,
+		httpClient: &http.Client{
+			Timeout:   config.CommandTimeout,
+			Transport: transport,
+		},
+	}
+
+	// Verify connectivity and authentication, and detect the RouterOS version
+	rows, err := client.Run(ctx, "/system/resource/print", "=.proplist=version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	if len(rows) > 0 {
+		client.version, _ = routeros.ParseVersion(rows[0]["version"])
+	}
+
+	return client, nil
+}
+
+// GetInterfaceStats queries interface counters via REST
+func (c *RESTClient) GetInterfaceStats(ctx context.Context, interfaces []string) ([]InterfaceStats, error) {
+	return queryInterfaceStats(ctx, c, interfaces)
+}
+
+// Run translates API command words to a REST request and returns the response rows
+func (c *RESTClient) Run(ctx context.Context, words ...string) ([]map[string]string, error) {
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	body := make(map[string]interface{})
+	var query []string
+
+	for _, word := range words[1:] {
+		switch {
+		case strings.HasPrefix(word, "?"):
+			query = append(query, word[1:])
+		case strings.HasPrefix(word, "="):
+			parts := strings.SplitN(word[1:], "=", 2)
+			if len(parts) == 1 {
+				body[parts[0]] = "" // Flag attribute such as =stats
+			} else if parts[0] == ".proplist" {
+				body[parts[0]] = strings.Split(parts[1], ",")
+			} else {
+				body[parts[0]] = parts[1]
+			}
+		}
+	}
+	if len(query) > 0 {
+		body[".query"] = query
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+words[0], bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	// Same error kinds as the binary API, so callers need not know the transport
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("%w: REST error %d", routeros.ErrAuthFailed, resp.StatusCode)
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("%w: REST error %d", routeros.ErrPermissionDenied, resp.StatusCode)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var restErr struct {
+			Message string `json:"message"`
+			Detail  string `json:"detail"`
+		}
+		if json.Unmarshal(respBody, &restErr) == nil && restErr.Message != "" {
+			return nil, fmt.Errorf("REST error %d: %s (%s)", resp.StatusCode, restErr.Message, restErr.Detail)
+		}
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return parseRESTRows(respBody)
+}
+
+// Close releases idle HTTP connections
+func (c *RESTClient) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// parseRESTRows converts a REST response (array of objects or single object) to rows
+// RouterOS returns all values as strings, but other JSON types are stringified for safety
+func parseRESTRows(data []byte) ([]map[string]string, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var raw []map[string]interface{}
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+	} else {
+		var single map[string]interface{}
+		if err := json.Unmarshal(data, &single); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		raw = append(raw, single)
+	}
+
+	rows := make([]map[string]string, 0, len(raw))
+	for _, item := range raw {
+		row := make(map[string]string, len(item))
+		for key, value := range item {
+			if str, ok := value.(string); ok {
+				row[key] = str
+			} else {
+				row[key] = fmt.Sprint(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// History Retention (rollup tier and per-tier deletion in VictoriaMetrics)
+// ============================================================================
+//
+// The aggregation windows (interval="10s") are rolled up into coarser series
+// (interval="300s") as soon as a bucket is complete, so the rollups always cover
+// the raw data horizon. VictoriaMetrics can only delete whole series, so a tier is
+// trimmed by exporting what is newer than its horizon, deleting the series and
+// importing the export back - the procedure its documentation gives for removing
+// samples. Pushes wait while a series is rewritten, and the export is kept in the
+// data directory until it has been imported, so no sample is lost.
+//
+// Only the rolled-up metrics are trimmed: the standard deviations have no rollup
+// and stay until the VictoriaMetrics retention period, like the series without an
+// interval label.
+
+const (
+	retentionChunk        = 24 * time.Hour // Rollup range per query
+	retentionTrimInterval = 24 * time.Hour // How often the tiers are trimmed
+)
+
+// retentionPendingPattern matches the exports of series being rewritten (in the data directory)
+const retentionPendingPattern = "retention-*.jsonl"
+
+// retentionHTTPClient is used for exports and imports, which take longer than VM_TIMEOUT allows
+var retentionHTTPClient = &http.Client{Timeout: 5 * time.Minute}
+
+// rollupMetric is a window series and how the windows of a bucket are combined
+// Standard deviations cannot be combined from the windows and are neither rolled up nor trimmed.
+type rollupMetric struct {
+	name     string // Without the prefix, e.g. "interface_rx_rate_avg"
+	function string // Rollup function over the bucket ("sum_over_time" for coverage, see rollupQuery)
+}
+
+var rollupMetrics = []rollupMetric{
+	{"interface_rx_rate_avg", "avg_over_time"},
+	{"interface_tx_rate_avg", "avg_over_time"},
+	{"interface_rx_rate_peak", "max_over_time"},
+	{"interface_tx_rate_peak", "max_over_time"},
+	{"interface_rx_rate_min", "min_over_time"},
+	{"interface_tx_rate_min", "min_over_time"},
+	{"interface_rx_utilization_ratio", "avg_over_time"},
+	{"interface_tx_utilization_ratio", "avg_over_time"},
+	{"interface_sample_count", "sum_over_time"},
+	{"interface_coverage_ratio", "sum_over_time"},
+	{"interface_rx_rate_histogram_bucket", "sum_over_time"}, // Sample counts per le, like sample_count
+	{"interface_tx_rate_histogram_bucket", "sum_over_time"},
+}
+
+// RetentionManager writes the rollup tier and deletes each tier past its horizon
+type RetentionManager struct {
+	config *RetentionConfig
+	vm     *VMClient
+	raw    string // Interval label of the aggregation windows ("10s")
+	rollup string // Interval label of the rollups ("300s")
+
+	next     time.Time // End of the last bucket rolled up (zero until looked up)
+	lastTrim time.Time
+}
+
+// NewRetentionManager creates the retention job and points history queries at the rollups
+func NewRetentionManager(config *RetentionConfig, vm *VMClient) *RetentionManager {
+	r := &RetentionManager{
+		config: config,
+		vm:     vm,
+		raw:    intervalLabel(vm.config.Interval),
+		rollup: intervalLabel(config.RollupStep),
+	}
+	vm.retention = config
+
+	rollupKept := "until the VictoriaMetrics retention period"
+	if config.RollupRetention > 0 {
+		rollupKept = "for " + config.RollupRetention.String()
+	}
+	logInfo("Retention", "Windows (interval=%q) kept for %v, rollups (interval=%q) kept %s",
+		r.raw, config.RawRetention, r.rollup, rollupKept)
+	return r
+}
+
+// intervalLabel returns the interval label value of a window or rollup size
+func intervalLabel(d time.Duration) string {
+	return fmt.Sprintf("%ds", int(d.Seconds()))
+}
+
+// alignTime rounds t down to a multiple of step since the Unix epoch (like VictoriaMetrics' query steps)
+func alignTime(t time.Time, step time.Duration) time.Time {
+	seconds := int64(step.Seconds())
+	return time.Unix(t.Unix()/seconds*seconds, 0)
+}
+
+// Run writes rollups every RETENTION_INTERVAL and trims the tiers once a day until ctx is cancelled
+// The tiers are only trimmed after a successful rollup, so raw data is never
+// deleted before it has been rolled up.
+func (r *RetentionManager) Run(ctx context.Context) {
+	r.vm.importPending()
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		if err := r.rollUp(ctx, now); err != nil {
+			if ctx.Err() == nil {
+				logError("Retention", "Rollup failed: %v", err)
+			}
+		} else if now.Sub(r.lastTrim) >= retentionTrimInterval {
+			r.trim(ctx, now)
+			r.lastTrim = now
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// rollUp writes the rollups of every bucket completed since the last one
+func (r *RetentionManager) rollUp(ctx context.Context, now time.Time) error {
+	step := r.config.RollupStep
+
+	// A bucket is complete once its last window has been pushed and is searchable
+	end := alignTime(now.Add(-max(2*r.vm.config.Interval, time.Minute)), step)
+
+	if r.next.IsZero() {
+		last, err := r.lastRollup(now)
+		if err != nil {
+			return err
+		}
+		if last.IsZero() || now.Sub(last) > r.config.RawRetention {
+			// First run: roll up the windows still stored
+			last = alignTime(now.Add(-r.config.RawRetention), step)
+			logInfo("Retention", "Rolling up stored windows since %s", last.Format(time.RFC3339))
+		}
+		r.next = last
+	}
+
+	for r.next.Before(end) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		chunkEnd := r.next.Add(retentionChunk)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+		series, err := r.writeRollups(r.next.Add(step), chunkEnd)
+		if err != nil {
+			return err
+		}
+		logDebug("Retention", "Rolled up (%s, %s]: %d series", r.next.Format(time.RFC3339), chunkEnd.Format(time.RFC3339), series)
+		r.next = chunkEnd
+	}
+	return nil
+}
+
+// lastRollup returns the timestamp of the newest rollup (zero if there is none within RETENTION_RAW)
+func (r *RetentionManager) lastRollup(now time.Time) (time.Time, error) {
+	query := fmt.Sprintf(`max(tlast_over_time(%sinterface_rx_rate_avg{interval="%s"%s}[%ds]))`,
+		r.vm.metricPrefix, r.rollup, r.vm.extraMatchers, int(r.config.RawRetention.Seconds()))
+	values := r.vm.queryInstant(query, now)
+	if values == nil {
+		return time.Time{}, fmt.Errorf("looking up the last rollup failed")
+	}
+	last, ok := values[""]
+	if !ok {
+		return time.Time{}, nil
+	}
+	return alignTime(time.Unix(int64(last), 0), r.config.RollupStep), nil
+}
+
+// rollupQuery returns the query computing a metric's rollups from the windows
+func (r *RetentionManager) rollupQuery(metric rollupMetric) string {
+	query := fmt.Sprintf(`%s(%s%s{interval="%s"%s}[%ds])`, metric.function,
+		r.vm.metricPrefix, metric.name, r.raw, r.vm.extraMatchers, int(r.config.RollupStep.Seconds()))
+	if metric.name == "interface_coverage_ratio" {
+		// Missing windows count as zero coverage
+		query += fmt.Sprintf(" / %d", int(r.config.RollupStep/r.vm.config.Interval))
+	}
+	return query
+}
+
+// writeRollups computes the rollups of the buckets ending in [start, end] and imports them
+// It returns the number of series written.
+func (r *RetentionManager) writeRollups(start, end time.Time) (int, error) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	count := 0
+	for _, metric := range rollupMetrics {
+		series, err := r.vm.querySeries(r.rollupQuery(metric), start, end, int(r.config.RollupStep.Seconds()))
+		if err != nil {
+			return 0, fmt.Errorf("query %s: %w", metric.name, err)
+		}
+		for _, s := range series {
+			s.Metric["__name__"] = r.vm.metricPrefix + metric.name
+			s.Metric["interval"] = r.rollup
+			if err := encoder.Encode(s); err != nil {
+				return 0, err
+			}
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	return count, r.vm.importSeries(body.Bytes())
+}
+
+// trim deletes the data of each tier past its horizon
+func (r *RetentionManager) trim(ctx context.Context, now time.Time) {
+	rawHorizon := now.Add(-r.config.RawRetention)
+	if r.next.Before(rawHorizon) {
+		logWarn("Retention", "Rollups end at %s, before the raw data horizon; windows not trimmed", r.next.Format(time.RFC3339))
+	} else {
+		r.trimTier(ctx, r.raw, rawHorizon)
+	}
+	if r.config.RollupRetention > 0 {
+		r.trimTier(ctx, r.rollup, now.Add(-r.config.RollupRetention))
+	}
+}
+
+// trimmedNames returns the __name__ pattern of the trimmed series (the rolled-up metrics)
+func (r *RetentionManager) trimmedNames() string {
+	names := make([]string, len(rollupMetrics))
+	for i, metric := range rollupMetrics {
+		names[i] = metric.name
+	}
+	return r.vm.metricPrefix + "(" + strings.Join(names, "|") + ")"
+}
+
+// trimTier rewrites the series of one tier that have data older than horizon, interface by interface
+func (r *RetentionManager) trimTier(ctx context.Context, interval string, horizon time.Time) {
+	tier := fmt.Sprintf(`{__name__=~"%s",interval="%s"%s}`, r.trimmedNames(), interval, r.vm.extraMatchers)
+	names, err := r.vm.seriesInterfaces(tier, horizon)
+	if err != nil {
+		logError("Retention", "Listing interval=%q series failed: %v", interval, err)
+		return
+	}
+
+	for _, name := range names {
+		if ctx.Err() != nil {
+			return
+		}
+		match := fmt.Sprintf(`{__name__=~"%s",interval="%s",interface="%s"%s}`,
+			r.trimmedNames(), interval, escapeLabelValue(name), r.vm.extraMatchers)
+		kept, err := r.vm.rewriteSeries(match, horizon)
+		if err != nil {
+			logError("Retention", "Trimming %s (interval=%q) failed: %v", name, interval, err)
+			continue
+		}
+		logInfo("Retention", "Trimmed %s (interval=%q) to %s: %d samples kept", name, interval, horizon.Format(time.RFC3339), kept)
+	}
+}
+
+// ============================================================================
+// VictoriaMetrics Series API (export / delete / import)
+// ============================================================================
+
+// vmSeries is one series in the JSON line format of /api/v1/export and /api/v1/import
+type vmSeries struct {
+	Metric     map[string]string `json:"metric"`
+	Values     []float64         `json:"values"`
+	Timestamps []int64           `json:"timestamps"` // Milliseconds
+}
+
+// querySeries executes a range query and returns the series with all their labels
+// Results are not served from the rollup cache, which may predate replayed data.
+func (c *VMClient) querySeries(query string, start, end time.Time, step int) ([]vmSeries, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", fmt.Sprintf("%d", start.Unix()))
+	params.Set("end", fmt.Sprintf("%d", end.Unix()))
+	params.Set("step", fmt.Sprintf("%d", step))
+	params.Set("nocache", "1")
+
+	var vmResp struct {
+		Status string `json:"status"`
+		Data   struct {
+			Result []struct {
+				Metric map[string]string `json:"metric"`
+				Values [][]interface{}   `json:"values"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := c.getJSON("/api/v1/query_range", params, &vmResp); err != nil {
+		return nil, err
+	}
+	if vmResp.Status != "success" {
+		return nil, fmt.Errorf("query failed: %s", vmResp.Status)
+	}
+
+	series := make([]vmSeries, 0, len(vmResp.Data.Result))
+	for _, result := range vmResp.Data.Result {
+		s := vmSeries{Metric: result.Metric}
+		if s.Metric == nil {
+			s.Metric = make(map[string]string)
+		}
+		for _, value := range result.Values {
+			if len(value) < 2 {
+				continue
+			}
+			timestamp, _ := value[0].(float64)
+			valueStr, _ := value[1].(string)
+			var val float64
+			if _, err := fmt.Sscanf(valueStr, "%g", &val); err != nil {
+				continue
+			}
+			s.Timestamps = append(s.Timestamps, int64(timestamp*1000))
+			s.Values = append(s.Values, val)
+		}
+		if len(s.Values) > 0 {
+			series = append(series, s)
+		}
+	}
+	return series, nil
+}
+
+// seriesInterfaces returns the interfaces of the series matching match with data before end
+func (c *VMClient) seriesInterfaces(match string, end time.Time) ([]string, error) {
+	params := url.Values{}
+	params.Set("match[]", match)
+	params.Set("start", "0")
+	params.Set("end", fmt.Sprintf("%d", end.Unix()))
+
+	var vmResp struct {
+		Status string              `json:"status"`
+		Data   []map[string]string `json:"data"`
+	}
+	if err := c.getJSON("/api/v1/series", params, &vmResp); err != nil {
+		return nil, err
+	}
+	if vmResp.Status != "success" {
+		return nil, fmt.Errorf("series lookup failed: %s", vmResp.Status)
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, labels := range vmResp.Data {
+		if name := labels["interface"]; name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// rewriteSeries drops the samples of the matching series older than horizon
+// The newer samples are exported, the series deleted and the export imported back.
+// Pushes wait meanwhile. The export is written to the data directory before the
+// series are deleted and removed once it is imported, so after a failed import or
+// a crash it is imported again on the next start (importPending).
+func (c *VMClient) rewriteSeries(match string, horizon time.Time) (int, error) {
+	c.pushMu.Lock()
+	defer c.pushMu.Unlock()
+
+	// Samples pushed just before the lock become searchable, so the export includes them
+	if _, err := c.request(http.MethodGet, "/internal/force_flush", nil, nil); err != nil {
+		return 0, fmt.Errorf("flush: %w", err)
+	}
+
+	params := url.Values{}
+	params.Set("match[]", match)
+	params.Set("start", fmt.Sprintf("%d", horizon.Unix()))
+	exported, err := c.request(http.MethodGet, "/api/v1/export", params, nil)
+	if err != nil {
+		return 0, fmt.Errorf("export: %w", err)
+	}
+
+	var file string
+	if len(bytes.TrimSpace(exported)) > 0 {
+		if file, err = writePendingExport(exported); err != nil {
+			return 0, fmt.Errorf("save export: %w", err)
+		}
+	}
+
+	params = url.Values{}
+	params.Set("match[]", match)
+	if c.retention.DeleteAuthKey != "" {
+		params.Set("authKey", c.retention.DeleteAuthKey)
+	}
+	if _, err := c.request(http.MethodPost, "/api/v1/admin/tsdb/delete_series", params, nil); err != nil {
+		return 0, fmt.Errorf("delete: %w", err)
+	}
+
+	if file == "" {
+		return 0, nil
+	}
+	if err := c.importSeries(exported); err != nil {
+		return 0, fmt.Errorf("re-import: %w (export kept in %s)", err, file)
+	}
+	if err := os.Remove(file); err != nil {
+		logWarn("Retention", "Removing %s failed: %v", file, err)
+	}
+
+	kept := 0
+	decoder := json.NewDecoder(bytes.NewReader(exported))
+	for {
+		var s vmSeries
+		if err := decoder.Decode(&s); err != nil {
+			break
+		}
+		kept += len(s.Values)
+	}
+	return kept, nil
+}
+
+// writePendingExport saves an export to the data directory and syncs it to disk
+func writePendingExport(data []byte) (string, error) {
+	if err := os.MkdirAll(defaultDataDir, 0755); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(defaultDataDir, retentionPendingPattern)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// importPending imports the exports left by rewrites that did not finish (failed import or crash)
+// An export whose series were not deleted yet only duplicates samples VictoriaMetrics already has.
+func (c *VMClient) importPending() {
+	files, _ := filepath.Glob(filepath.Join(defaultDataDir, retentionPendingPattern))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			logError("Retention", "Reading %s failed: %v", file, err)
+			continue
+		}
+		if err := c.importSeries(data); err != nil {
+			logError("Retention", "Importing %s failed (kept for the next start): %v", file, err)
+			continue
+		}
+		os.Remove(file)
+		logInfo("Retention", "Imported %s left by an unfinished trim", file)
+	}
+}
+
+// importSeries imports series in the JSON line format
+func (c *VMClient) importSeries(data []byte) error {
+	var err error
+	for attempt := 0; attempt <= c.config.RetryCount; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		if _, err = c.request(http.MethodPost, "/api/v1/import", nil, data); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// getJSON sends a GET request to the VictoriaMetrics API and decodes the JSON response
+func (c *VMClient) getJSON(path string, params url.Values, v interface{}) error {
+	body, err := c.request(http.MethodGet, path, params, nil)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// request sends a request to the VictoriaMetrics API and returns the response body
+func (c *VMClient) request(method, path string, params url.Values, body []byte) ([]byte, error) {
+	target := strings.TrimSuffix(c.config.URL, "/") + path
+	if len(params) > 0 {
+		target += "?" + params.Encode()
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	c.authorize(req)
+	resp, err := retentionHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
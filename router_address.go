@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// Router Address (IPv6 literals, default ports, DNS SRV discovery)
+// ============================================================================
+
+// normalizeRouterHost strips the brackets of an IPv6 literal ("[2001:db8::1]" -> "2001:db8::1")
+// net.JoinHostPort adds them back when dialing.
+func normalizeRouterHost(host string) string {
+	host = strings.TrimSpace(host)
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// validateRouterHost accepts IPv4/IPv6 literals (optionally with a %zone) and DNS names
+func validateRouterHost(host string) error {
+	addr, zone, _ := strings.Cut(host, "%")
+	if ip := net.ParseIP(addr); ip != nil {
+		if zone != "" && ip.To4() != nil {
+			return fmt.Errorf("zone %q is only valid for IPv6 addresses", zone)
+		}
+		return nil
+	}
+	if zone != "" || strings.Contains(host, ":") {
+		return fmt.Errorf("%q is neither an IP address nor a host name (set the port with MIKROTIK_PORT, not host:port)", host)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("%q is not a valid host name", host)
+		}
+		for _, c := range label {
+			if !(c == '-' || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+				return fmt.Errorf("%q is not a valid host name", host)
+			}
+		}
+	}
+	return nil
+}
+
+// defaultRouterPort returns the standard RouterOS service port for a transport
+func defaultRouterPort(transport string, useTLS bool) string {
+	switch {
+	case transport == "rest":
+		return "443" // www-ssl
+	case useTLS:
+		return "8729" // api-ssl
+	default:
+		return "8728" // api
+	}
+}
+
+// srvService returns the DNS SRV service name used for port discovery
+// e.g. _mikrotik-api._tcp.core1.example.com
+func srvService(transport string, useTLS bool) string {
+	switch {
+	case transport == "rest":
+		return "mikrotik-rest"
+	case useTLS:
+		return "mikrotik-api-ssl"
+	default:
+		return "mikrotik-api"
+	}
+}
+
+// resolveRouterAddress returns the host:port to dial
+// With MIKROTIK_SRV the target and port come from the SRV record of MIKROTIK_HOST,
+// looked up on every (re)connect so DNS changes are picked up.
+func resolveRouterAddress(ctx context.Context, config *Config) (host, port string, err error) {
+	if !config.SRV {
+		return config.Host, config.Port, nil
+	}
+
+	service := srvService(config.Transport, config.TLS)
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, service, "tcp", config.Host)
+	if err != nil {
+		return "", "", fmt.Errorf("SRV lookup _%s._tcp.%s: %w", service, config.Host, err)
+	}
+	if len(records) == 0 {
+		return "", "", fmt.Errorf("SRV lookup _%s._tcp.%s: no records", service, config.Host)
+	}
+
+	// Records are sorted by priority and randomized by weight
+	target := strings.TrimSuffix(records[0].Target, ".")
+	logDebug("Client", "SRV _%s._tcp.%s -> %s:%d", service, config.Host, target, records[0].Port)
+	return target, strconv.Itoa(int(records[0].Port)), nil
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ============================================================================
+// RRDtool / MRTG Output
+// ============================================================================
+//
+// Samples are aggregated into RRD_INTERVAL windows (5 minutes like MRTG and
+// Cacti) and every completed window is written per interface:
+//
+//   rrdtool: rrdtool update <RRD_DIR>/<interface>.rrd --template ds0:ds1 <end>:<rx-byte>:<tx-byte>
+//            The raw counters feed COUNTER data sources, so RRDtool computes the
+//            rates as if it had polled the router itself. Missing files are
+//            created with the MRTG layout (AVERAGE and MAX, 800 rows each of
+//            1, 6, 24 and 288 steps); RRD_DS_IN/RRD_DS_OUT match existing files
+//            (Cacti uses traffic_in/traffic_out).
+//   mrtg:    <RRD_DIR>/<interface>.log in MRTG's log format, read by 14all.cgi,
+//            routers2 and other MRTG front ends: a counter line followed by
+//            "time avg-in avg-out max-in max-out" rows (bytes/s, newest first),
+//            consolidated like rateup: 600 rows of 5 minutes, 600 of 30 minutes,
+//            600 of 2 hours and 797 of a day.
+//
+// In is RX and out is TX of the router interface, as in MRTG's ifInOctets/ifOutOctets.
+
+// RRDOutput implements OutputWriter by aggregating samples into time windows
+// and writing each completed window to RRD files or MRTG logs
+type RRDOutput struct {
+	config     *RRDConfig
+	aggregator *TimeWindowAggregator
+	flusher    *windowFlusher
+
+	// Owned by send (serialized by the flusher)
+	created map[string]bool      // RRD files known to exist
+	logs    map[string][]mrtgRow // MRTG log path -> rows, newest first (loaded on first write)
+}
+
+// mrtgRow is one data row of an MRTG log (bytes/s over the period ending at time)
+type mrtgRow struct {
+	time                         int64
+	avgIn, avgOut, maxIn, maxOut float64
+}
+
+// mrtgTiers are the consolidation steps of an MRTG log (oldest rows are dropped)
+var mrtgTiers = []struct {
+	step time.Duration
+	rows int
+}{
+	{5 * time.Minute, 600},
+	{30 * time.Minute, 600},
+	{2 * time.Hour, 600},
+	{24 * time.Hour, 797},
+}
+
+// NewRRDOutput creates an RRDtool or MRTG log output (sampleInterval is the poll interval)
+func NewRRDOutput(config *RRDConfig, sampleInterval time.Duration) *RRDOutput {
+	o := &RRDOutput{
+		config:     config,
+		aggregator: NewTimeWindowAggregator(config.Interval, sampleInterval),
+		created:    make(map[string]bool),
+		logs:       make(map[string][]mrtgRow),
+	}
+	o.flusher = newWindowFlusher(o.aggregator, o.send)
+
+	if config.Format == "rrdtool" {
+		if _, err := exec.LookPath(config.RRDTool); err != nil {
+			logWarn("RRD", "%s not found, updates will fail: %v", config.RRDTool, err)
+		}
+	}
+	logInfo("RRD", "Output initialized (%s format, %s, interval: %v)", config.Format, config.Dir, config.Interval)
+	return o
+}
+
+func (o *RRDOutput) WriteHeader() {}
+
+func (o *RRDOutput) WriteStats(timestamp time.Time, stats map[string]*RateInfo) {
+	for _, rateInfo := range stats {
+		o.aggregator.AddSample(timestamp, rateInfo)
+	}
+
+	o.flusher.sendCompleted()
+}
+
+// Close writes the last (partial) window
+func (o *RRDOutput) Close() {
+	o.flusher.Close()
+}
+
+// SetSampleInterval follows poll interval changes (see sampleIntervalSetter)
+func (o *RRDOutput) SetSampleInterval(now time.Time, sampleInterval time.Duration) {
+	o.aggregator.SetSampleInterval(now, sampleInterval)
+}
+
+// send writes one completed window for every interface
+func (o *RRDOutput) send(window *AggregationWindow) {
+	if len(window.Interfaces) == 0 {
+		return
+	}
+	if err := os.MkdirAll(o.config.Dir, 0755); err != nil {
+		logError("RRD", "Failed to create %s: %v", o.config.Dir, err)
+		return
+	}
+
+	// The partial window written at shutdown ends in the future; RRDtool refuses
+	// an update that is not newer than the previous one after a restart
+	end := window.EndTime
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+
+	names := make([]string, 0, len(window.Interfaces))
+	for name := range window.Interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		stats := window.Interfaces[name]
+		if stats.Count == 0 {
+			continue
+		}
+		var err error
+		if o.config.Format == "mrtg" {
+			err = o.writeLog(name, stats, end)
+		} else {
+			err = o.updateRRD(name, stats, end)
+		}
+		if err != nil {
+			logError("RRD", "%s: %v", name, err)
+		}
+	}
+}
+
+// filePath returns the file of an interface in RRD_DIR
+func (o *RRDOutput) filePath(name, ext string) string {
+	return filepath.Join(o.config.Dir, rrdFileName(name)+ext)
+}
+
+// rrdFileName maps an interface name to a safe file name (letters, digits, "-", "_" and ".")
+func rrdFileName(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+	if safe == "" || safe[0] == '.' {
+		safe = "_" + safe
+	}
+	return safe
+}
+
+// updateRRD feeds the window's counters to the interface's RRD, creating it if needed
+func (o *RRDOutput) updateRRD(name string, stats *WindowStats, end time.Time) error {
+	path := o.filePath(name, ".rrd")
+	if !o.created[path] && o.config.Daemon == "" {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			if !o.config.Create {
+				return fmt.Errorf("%s does not exist (RRD_CREATE=false)", path)
+			}
+			if err := o.rrdtool(rrdCreateArgs(path, o.config, stats.LinkSpeed, end)...); err != nil {
+				return err
+			}
+			logInfo("RRD", "Created %s", path)
+		}
+		o.created[path] = true
+	}
+
+	return o.rrdtool("update", path, "--template", o.config.DSIn+":"+o.config.DSOut,
+		fmt.Sprintf("%d:%d:%d", end.Unix(), stats.RxBytes, stats.TxBytes))
+}
+
+// rrdCreateArgs returns the rrdtool arguments creating an RRD with the MRTG layout
+// The maximum rate is the link speed when known, so a counter reset is not read as a wrap.
+func rrdCreateArgs(path string, config *RRDConfig, linkSpeed float64, start time.Time) []string {
+	step := int64(config.Interval / time.Second)
+	maximum := "U"
+	if linkSpeed > 0 {
+		maximum = strconv.FormatFloat(linkSpeed/8, 'f', 0, 64)
+	}
+
+	args := []string{"create", path,
+		"--start", strconv.FormatInt(start.Unix()-step, 10),
+		"--step", strconv.FormatInt(step, 10),
+		fmt.Sprintf("DS:%s:COUNTER:%d:0:%s", config.DSIn, 2*step, maximum),
+		fmt.Sprintf("DS:%s:COUNTER:%d:0:%s", config.DSOut, 2*step, maximum),
+	}
+	for _, cf := range []string{"AVERAGE", "MAX"} {
+		for _, steps := range []int{1, 6, 24, 288} {
+			args = append(args, fmt.Sprintf("RRA:%s:0.5:%d:800", cf, steps))
+		}
+	}
+	return args
+}
+
+// rrdtool runs an rrdtool command (through rrdcached with RRD_DAEMON)
+func (o *RRDOutput) rrdtool(args ...string) error {
+	if o.config.Daemon != "" {
+		args = append([]string{args[0], "--daemon", o.config.Daemon}, args[1:]...)
+	}
+	out, err := exec.Command(o.config.RRDTool, args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("rrdtool %s: %s", args[0], msg)
+		}
+		return fmt.Errorf("rrdtool %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// writeLog adds the window to the interface's MRTG log and rewrites it
+func (o *RRDOutput) writeLog(name string, stats *WindowStats, end time.Time) error {
+	path := o.filePath(name, ".log")
+	rows, ok := o.logs[path]
+	if !ok {
+		var err error
+		if rows, err = readMRTGLog(path); err != nil {
+			logWarn("RRD", "Starting a new %s: %v", path, err)
+		}
+	}
+
+	n := float64(stats.Count)
+	row := mrtgRow{time: end.Unix(), avgIn: stats.RxSum / n, avgOut: stats.TxSum / n, maxIn: stats.RxPeak, maxOut: stats.TxPeak}
+	rows = consolidateMRTG(append([]mrtgRow{row}, rows...), end.Unix())
+	o.logs[path] = rows
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%d %d %d\n", end.Unix(), stats.RxBytes, stats.TxBytes)
+	for _, row := range rows {
+		fmt.Fprintf(&buf, "%d %.0f %.0f %.0f %.0f\n", row.time, row.avgIn, row.avgOut, row.maxIn, row.maxOut)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(buf.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readMRTGLog reads the data rows of an MRTG log (none if the file does not exist)
+func readMRTGLog(path string) ([]mrtgRow, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rows []mrtgRow
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if line == 1 {
+			continue // Counter line
+		}
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("line %d: expected 5 fields", line)
+		}
+		var row mrtgRow
+		var values [4]float64
+		row.time, err = strconv.ParseInt(fields[0], 10, 64)
+		for i := range values {
+			if err == nil {
+				values[i], err = strconv.ParseFloat(fields[i+1], 64)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		row.avgIn, row.avgOut, row.maxIn, row.maxOut = values[0], values[1], values[2], values[3]
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+// consolidateMRTG folds rows into the MRTG tiers as of now (newest first)
+// Rows move to the next tier together with the whole period of that tier, so each
+// period averages rows of equal length; averages are averaged, maximums kept.
+func consolidateMRTG(rows []mrtgRow, now int64) []mrtgRow {
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].time > rows[j].time })
+
+	var out []mrtgRow
+	i := 0
+	var start int64 // Age (seconds) where the tier begins
+	for t, tier := range mrtgTiers {
+		step := int64(tier.step / time.Second)
+		limit := start + step*int64(tier.rows)
+		next := step // Period of the next tier, which decides when rows move on
+		if t+1 < len(mrtgTiers) {
+			next = int64(mrtgTiers[t+1].step / time.Second)
+		}
+		for i < len(rows) {
+			if now-periodEnd(rows[i].time, next) >= limit {
+				break
+			}
+			period := periodEnd(rows[i].time, step)
+			row := mrtgRow{time: period}
+			n := 0
+			for ; i < len(rows) && periodEnd(rows[i].time, step) == period; i++ {
+				row.avgIn += rows[i].avgIn
+				row.avgOut += rows[i].avgOut
+				row.maxIn = max(row.maxIn, rows[i].maxIn)
+				row.maxOut = max(row.maxOut, rows[i].maxOut)
+				n++
+			}
+			row.avgIn /= float64(n)
+			row.avgOut /= float64(n)
+			out = append(out, row)
+		}
+		start = limit
+	}
+	return out
+}
+
+// periodEnd returns the end of the step-second period holding t (t itself on a boundary)
+func periodEnd(t, step int64) int64 {
+	return (t + step - 1) / step * step
+}
+
+// validDSName reports whether name is a valid RRDtool data source name
+func validDSName(name string) bool {
+	if name == "" || len(name) > 19 {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
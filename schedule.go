@@ -0,0 +1,469 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Interface Schedule (enable/disable windows)
+// ============================================================================
+
+// scheduleActionHistory is the number of recent actions kept for /api/schedule
+const scheduleActionHistory = 100
+
+// Schedule actions
+const (
+	ScheduleDisable       = "disable"        // Interface disabled
+	ScheduleEnable        = "enable"         // Interface enabled
+	ScheduleOverrideSet   = "override"       // Override set through /api/admin/schedule
+	ScheduleOverrideEnded = "override-ended" // Override expired or was removed
+	ScheduleError         = "error"          // Reading or writing the interface failed
+)
+
+// ScheduleWindow keeps an interface disabled from one cron time until another
+type ScheduleWindow struct {
+	Interface string
+	Disable   *cronSchedule // Start of the window
+	Enable    *cronSchedule // End of the window
+
+	rule string // Entry as configured, for logs and the audit file
+}
+
+// String renders the window in INTERFACE_SCHEDULE_WINDOWS syntax
+func (w ScheduleWindow) String() string {
+	return w.rule
+}
+
+// disabledAt reports whether the window is open at now: its last start is later than its last end
+func (w ScheduleWindow) disabledAt(now time.Time) bool {
+	return w.Disable.Prev(now).After(w.Enable.Prev(now))
+}
+
+// parseScheduleWindows parses "vlan-guest=0 23 * * * until 0 7 * * *;..."
+// Entries are separated by semicolons, as cron fields use commas.
+func parseScheduleWindows(value string) ([]ScheduleWindow, error) {
+	var windows []ScheduleWindow
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, crons, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid window %q (expected interface=<cron> until <cron>)", entry)
+		}
+		from, until, ok := strings.Cut(crons, " until ")
+		if !ok {
+			return nil, fmt.Errorf("invalid window %q (expected interface=<cron> until <cron>)", entry)
+		}
+		disable, err := parseCron(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: start %v", entry, err)
+		}
+		enable, err := parseCron(until)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: end %v", entry, err)
+		}
+		if disable.Next(time.Now()).IsZero() || enable.Next(time.Now()).IsZero() {
+			return nil, fmt.Errorf("invalid window %q (never matches)", entry)
+		}
+		windows = append(windows, ScheduleWindow{Interface: name, Disable: disable, Enable: enable, rule: entry})
+	}
+	return windows, nil
+}
+
+// ScheduleOverride pins an interface to a state until a given time
+type ScheduleOverride struct {
+	Disabled bool      `json:"disabled"`
+	Until    time.Time `json:"until"`
+	By       string    `json:"by"` // Who set it ("admin from 192.0.2.7:51234", "token:ops from ...")
+	Since    time.Time `json:"since"`
+}
+
+// ScheduleAction is one entry of the audit trail
+type ScheduleAction struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"` // disable, enable, override, override-ended or error
+	DryRun    bool      `json:"dry_run,omitempty"`
+	Interface string    `json:"interface"`
+	Reason    string    `json:"reason,omitempty"` // Window or override behind the change
+	By        string    `json:"by,omitempty"`     // Overrides only
+	Message   string    `json:"message"`
+}
+
+// ScheduleInterfaceStatus is the state of one scheduled interface in /api/schedule
+type ScheduleInterfaceStatus struct {
+	Interface string            `json:"interface"`
+	Windows   []string          `json:"windows"`
+	Scheduled bool              `json:"scheduled_disabled"`         // State the windows ask for
+	Disabled  bool              `json:"disabled"`                   // State enforced (override first)
+	Next      time.Time         `json:"next_change"`                // Next scheduled change (zero if none within 5 years)
+	Override  *ScheduleOverride `json:"override,omitempty"`         // Active override
+	Applied   *bool             `json:"applied_disabled,omitempty"` // State last enforced (missing before the first check)
+}
+
+// ScheduleSnapshot is returned by /api/schedule
+type ScheduleSnapshot struct {
+	DryRun     bool                      `json:"dry_run"`
+	Interfaces []ScheduleInterfaceStatus `json:"interfaces"`
+	Actions    []ScheduleAction          `json:"actions"` // Newest first
+}
+
+// scheduleState is the content of INTERFACE_SCHEDULE_STATE_FILE
+type scheduleState struct {
+	Overrides map[string]*ScheduleOverride `json:"overrides"` // Interface -> override
+}
+
+// errScheduleUnknownInterface reports an override for an interface without windows
+var errScheduleUnknownInterface = errors.New("interface has no schedule window")
+
+// InterfaceScheduler disables and enables interfaces on INTERFACE_SCHEDULE_WINDOWS
+//
+// An interface is disabled while any of its windows is open, i.e. when the
+// last start of the window is later than its last end, so a missed start
+// (monitor down at 23:00) is still applied on the next check. The router is
+// only written when the scheduled state changes, or on the first check after
+// a start: an interface switched by hand stays as it is until the next change.
+// Overrides set through /api/admin/schedule pin an interface to a state until
+// they expire and are kept in the state file; setting, removing or expiring one
+// checks the router again. Every action is logged and
+// appended to INTERFACE_SCHEDULE_AUDIT_FILE; in dry-run mode nothing is
+// written to the router.
+type InterfaceScheduler struct {
+	client   RouterClient
+	config   *ScheduleConfig
+	location *time.Location              // TIMEZONE: zone of the window schedules
+	windows  map[string][]ScheduleWindow // Interface -> windows
+	names    []string                    // Scheduled interfaces, sorted
+
+	applied   map[string]bool // Interface -> disabled state last enforced
+	overrides map[string]*ScheduleOverride
+	actions   []ScheduleAction // Newest last
+	mu        sync.Mutex
+
+	runMu sync.Mutex // Serializes Enforce (collector and override changes)
+}
+
+// NewInterfaceScheduler creates a scheduler and loads its saved overrides
+// The window schedules run in location (the TIMEZONE zone).
+func NewInterfaceScheduler(client RouterClient, config *ScheduleConfig, location *time.Location) *InterfaceScheduler {
+	windows, _ := parseScheduleWindows(config.Windows) // Validated with the config
+	mode := "enforcing"
+	if config.DryRun {
+		mode = "dry run"
+	}
+
+	s := &InterfaceScheduler{
+		client:    client,
+		config:    config,
+		location:  location,
+		windows:   make(map[string][]ScheduleWindow),
+		applied:   make(map[string]bool),
+		overrides: make(map[string]*ScheduleOverride),
+	}
+	for _, window := range windows {
+		if _, ok := s.windows[window.Interface]; !ok {
+			s.names = append(s.names, window.Interface)
+		}
+		s.windows[window.Interface] = append(s.windows[window.Interface], window)
+	}
+	sort.Strings(s.names)
+	logInfo("Schedule", "Interface schedule initialized (%d windows on %s, %s, every %v)",
+		len(windows), strings.Join(s.names, ", "), mode, config.Interval)
+
+	s.load()
+	return s
+}
+
+// load reads the saved overrides (missing or unreadable file = none)
+func (s *InterfaceScheduler) load() {
+	data, err := os.ReadFile(s.config.StateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logWarn("Schedule", "Failed to read %s: %v", s.config.StateFile, err)
+		}
+		return
+	}
+	var state scheduleState
+	if err := json.Unmarshal(data, &state); err != nil {
+		logWarn("Schedule", "Ignoring unreadable %s: %v", s.config.StateFile, err)
+		return
+	}
+	for name, override := range state.Overrides {
+		if _, ok := s.windows[name]; ok && override != nil {
+			s.overrides[name] = override
+		}
+	}
+	if len(s.overrides) > 0 {
+		logInfo("Schedule", "%d interface overrides set before the restart", len(s.overrides))
+	}
+}
+
+// save writes the overrides through a temporary file
+func (s *InterfaceScheduler) save() error {
+	s.mu.Lock()
+	data, err := json.Marshal(scheduleState{Overrides: s.overrides})
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("marshal schedule state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.config.StateFile), 0755); err != nil {
+		return err
+	}
+	tmp := s.config.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.config.StateFile)
+}
+
+// scheduled returns whether the windows of an interface ask for it to be disabled, and the open window
+func (s *InterfaceScheduler) scheduled(name string, now time.Time) (bool, string) {
+	for _, window := range s.windows[name] {
+		if window.disabledAt(now) {
+			return true, window.String()
+		}
+	}
+	return false, ""
+}
+
+// nextChange returns when the scheduled state of an interface changes next
+// Disabled interfaces change once all open windows ended; enabled ones when any window starts.
+func (s *InterfaceScheduler) nextChange(name string, now time.Time) time.Time {
+	disabled, _ := s.scheduled(name, now)
+	var next time.Time
+	for _, window := range s.windows[name] {
+		switch {
+		case disabled && window.disabledAt(now):
+			if end := window.Enable.Next(now); !end.IsZero() && end.After(next) {
+				next = end
+			}
+		case !disabled:
+			if start := window.Disable.Next(now); !start.IsZero() && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next
+}
+
+// Enforce brings every scheduled interface to the state of its windows or override
+func (s *InterfaceScheduler) Enforce(ctx context.Context) error {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	now := time.Now().In(s.location)
+	var errs []error
+	changed := s.expireOverrides(now)
+	for _, name := range s.names {
+		disabled, reason := s.scheduled(name, now)
+		s.mu.Lock()
+		if override := s.overrides[name]; override != nil {
+			disabled, reason = override.Disabled, "override by "+override.By
+		}
+		applied, ok := s.applied[name]
+		s.mu.Unlock()
+		if ok && applied == disabled {
+			continue
+		}
+		if reason == "" {
+			reason = "outside the schedule windows"
+		}
+
+		if err := s.apply(ctx, now, name, disabled, reason); err != nil {
+			s.record(ScheduleAction{Time: now, Action: ScheduleError, Interface: name, Reason: reason, Message: err.Error()})
+			errs = append(errs, err)
+			continue
+		}
+		s.mu.Lock()
+		s.applied[name] = disabled
+		s.mu.Unlock()
+	}
+
+	if changed {
+		if err := s.save(); err != nil {
+			logError("Schedule", "Failed to save %s: %v", s.config.StateFile, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expireOverrides removes the overrides that ran out and reports whether any did
+func (s *InterfaceScheduler) expireOverrides(now time.Time) bool {
+	s.mu.Lock()
+	var expired []string
+	for name, override := range s.overrides {
+		if !now.Before(override.Until) {
+			expired = append(expired, name)
+			delete(s.overrides, name)
+			delete(s.applied, name)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Strings(expired)
+	for _, name := range expired {
+		s.record(ScheduleAction{Time: now, Action: ScheduleOverrideEnded, Interface: name,
+			Message: fmt.Sprintf("Override of %s expired, back to the schedule", name)})
+	}
+	return len(expired) > 0
+}
+
+// apply sets the disabled state of an interface on the router (logged only in dry run)
+func (s *InterfaceScheduler) apply(ctx context.Context, now time.Time, name string, disabled bool, reason string) error {
+	rows, err := s.client.Run(ctx, "/interface/print", "?name="+name, "=.proplist=.id,name,disabled")
+	if err != nil {
+		return fmt.Errorf("read interface %s: %w", name, err)
+	}
+	var id, current string
+	for _, row := range rows {
+		if row["name"] == name {
+			id, current = row[".id"], row["disabled"]
+		}
+	}
+	if id == "" {
+		return fmt.Errorf("interface %s not found in /interface", name)
+	}
+	if (current == "true") == disabled {
+		return nil // Already there (e.g. switched by hand, or the first check after a restart)
+	}
+
+	action, value := ScheduleEnable, "no"
+	if disabled {
+		action, value = ScheduleDisable, "yes"
+	}
+	if !s.config.DryRun {
+		if _, err := s.client.Run(ctx, "/interface/set", "=.id="+id, "=disabled="+value); err != nil {
+			return fmt.Errorf("%s interface %s: %w", action, name, err)
+		}
+	}
+	s.record(ScheduleAction{
+		Time: now, Action: action, DryRun: s.config.DryRun, Interface: name, Reason: reason,
+		Message: fmt.Sprintf("Interface %s %sd (%s)", name, action, reason),
+	})
+	return nil
+}
+
+// SetOverride pins an interface to a state until a time (zero = until the next scheduled change)
+func (s *InterfaceScheduler) SetOverride(name string, disabled bool, until time.Time, by string) (*ScheduleOverride, error) {
+	if _, ok := s.windows[name]; !ok {
+		return nil, fmt.Errorf("%w: %s", errScheduleUnknownInterface, name)
+	}
+	now := time.Now().In(s.location)
+	if until.IsZero() {
+		until = s.nextChange(name, now)
+		if until.IsZero() {
+			return nil, fmt.Errorf("%s has no scheduled change to override until, set 'until' or 'duration'", name)
+		}
+	}
+	if !until.After(now) {
+		return nil, fmt.Errorf("'until' must be in the future")
+	}
+
+	override := &ScheduleOverride{Disabled: disabled, Until: until, By: by, Since: now}
+	s.mu.Lock()
+	s.overrides[name] = override
+	delete(s.applied, name)
+	s.mu.Unlock()
+
+	state := "enabled"
+	if disabled {
+		state = "disabled"
+	}
+	s.record(ScheduleAction{Time: now, Action: ScheduleOverrideSet, Interface: name, By: by,
+		Message: fmt.Sprintf("%s keeps %s %s until %s", by, name, state, until.Format(time.RFC3339))})
+	if err := s.save(); err != nil {
+		logError("Schedule", "Failed to save %s: %v", s.config.StateFile, err)
+	}
+	return override, nil
+}
+
+// ClearOverride returns an interface to its schedule (false if it had no override)
+func (s *InterfaceScheduler) ClearOverride(name, by string) bool {
+	s.mu.Lock()
+	_, ok := s.overrides[name]
+	delete(s.overrides, name)
+	delete(s.applied, name)
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	s.record(ScheduleAction{Time: time.Now().In(s.location), Action: ScheduleOverrideEnded, Interface: name, By: by,
+		Message: fmt.Sprintf("%s removed the override of %s, back to the schedule", by, name)})
+	if err := s.save(); err != nil {
+		logError("Schedule", "Failed to save %s: %v", s.config.StateFile, err)
+	}
+	return true
+}
+
+// record logs an action, appends it to the audit file and keeps it for /api/schedule
+func (s *InterfaceScheduler) record(action ScheduleAction) {
+	message := action.Message
+	if action.DryRun {
+		message = "[dry run] " + message
+	}
+	if action.Action == ScheduleError {
+		logError("Schedule", "%s", message)
+	} else {
+		logInfo("Schedule", "%s", message)
+	}
+
+	if err := appendJSONLine(s.config.AuditFile, action); err != nil {
+		logError("Schedule", "Failed to write %s: %v", s.config.AuditFile, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions = append(s.actions, action)
+	if len(s.actions) > scheduleActionHistory {
+		s.actions = s.actions[len(s.actions)-scheduleActionHistory:]
+	}
+}
+
+// Snapshot returns the scheduled interfaces with their state and the recent actions
+func (s *InterfaceScheduler) Snapshot() *ScheduleSnapshot {
+	now := time.Now().In(s.location)
+	snapshot := &ScheduleSnapshot{DryRun: s.config.DryRun, Interfaces: make([]ScheduleInterfaceStatus, 0, len(s.names))}
+	for _, name := range s.names {
+		scheduled, _ := s.scheduled(name, now)
+		status := ScheduleInterfaceStatus{
+			Interface: name,
+			Scheduled: scheduled,
+			Disabled:  scheduled,
+			Next:      s.nextChange(name, now),
+		}
+		for _, window := range s.windows[name] {
+			status.Windows = append(status.Windows, window.String())
+		}
+		s.mu.Lock()
+		if override := s.overrides[name]; override != nil && now.Before(override.Until) {
+			copied := *override
+			status.Override, status.Disabled = &copied, override.Disabled
+		}
+		if applied, ok := s.applied[name]; ok {
+			status.Applied = &applied
+		}
+		s.mu.Unlock()
+		snapshot.Interfaces = append(snapshot.Interfaces, status)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot.Actions = make([]ScheduleAction, 0, len(s.actions))
+	for i := len(s.actions) - 1; i >= 0; i-- {
+		snapshot.Actions = append(snapshot.Actions, s.actions[i])
+	}
+	return snapshot
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// systemd Notification (sd_notify)
+// ============================================================================
+
+// systemdNotifier implements the sd_notify protocol for Type=notify services
+//
+// READY=1 is sent after the first successful sample, WATCHDOG=1 from the
+// monitoring loop at half the WatchdogSec interval, and STOPPING=1 on shutdown.
+// If the loop hangs (e.g. on a stuck socket) the pings stop and systemd
+// restarts the service.
+type systemdNotifier struct {
+	socket   string        // $NOTIFY_SOCKET (path, or abstract socket starting with @)
+	watchdog time.Duration // Ping interval (half of $WATCHDOG_USEC), 0 if disabled
+
+	ready    bool
+	lastPing time.Time
+	mu       sync.Mutex
+}
+
+// newSystemdNotifier returns a notifier, or nil when not running under systemd Type=notify
+func newSystemdNotifier() *systemdNotifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	n := &systemdNotifier{socket: socket}
+
+	// The watchdog applies to this process only if WATCHDOG_PID is unset or matches
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+		if pid := os.Getenv("WATCHDOG_PID"); pid == "" || pid == strconv.Itoa(os.Getpid()) {
+			n.watchdog = time.Duration(usec) * time.Microsecond / 2
+		}
+	}
+
+	logInfo("Systemd", "sd_notify enabled (watchdog ping every %v)", n.watchdog)
+	return n
+}
+
+// notify sends a state string to the systemd notification socket
+func (n *systemdNotifier) notify(state string) error {
+	addr := &net.UnixAddr{Name: n.socket, Net: "unixgram"}
+	if addr.Name[0] == '@' {
+		addr.Name = "\x00" + addr.Name[1:] // Abstract namespace
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// Ready signals READY=1 once (safe to call after every successful sample)
+func (n *systemdNotifier) Ready() {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.ready {
+		return
+	}
+	if err := n.notify("READY=1\nSTATUS=Monitoring interfaces"); err != nil {
+		logWarn("Systemd", "Failed to notify readiness: %v", err)
+		return
+	}
+	n.ready = true
+	logInfo("Systemd", "Notified readiness")
+}
+
+// Watchdog pings the systemd watchdog if the ping interval has elapsed
+func (n *systemdNotifier) Watchdog(now time.Time) {
+	if n == nil || n.watchdog == 0 {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if now.Sub(n.lastPing) < n.watchdog {
+		return
+	}
+	if err := n.notify("WATCHDOG=1"); err != nil {
+		logWarn("Systemd", "Failed to ping watchdog: %v", err)
+		return
+	}
+	n.lastPing = now
+}
+
+// Stopping signals STOPPING=1 during shutdown
+func (n *systemdNotifier) Stopping() {
+	if n == nil {
+		return
+	}
+	if err := n.notify("STOPPING=1"); err != nil {
+		logWarn("Systemd", "Failed to notify shutdown: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// ============================================================================
+// Router Password Sources (environment, secrets file, stdin, OS keyring)
+// ============================================================================
+
+// defaultKeyringService is the keyring service (Windows: generic credential target) holding the password
+const defaultKeyringService = "mikrotik-stats"
+
+// stdinPassword is the password read from stdin (MIKROTIK_PASSWORD_FILE=-), kept for reloads
+var stdinPassword string
+
+// loadPassword reads the router password from the first configured source
+//
+//	MIKROTIK_PASSWORD          plain value (e.g. from .env)
+//	MIKROTIK_PASSWORD_FILE     secrets file (Docker/Kubernetes secrets, systemd credentials); "-" reads stdin
+//	MIKROTIK_PASSWORD_KEYRING  OS keyring: Secret Service (Linux), Keychain (macOS), Credential Manager (Windows)
+//
+// Returns the password and the name of the source it came from.
+func loadPassword(username string) (password, source string, err error) {
+	if password := os.Getenv("MIKROTIK_PASSWORD"); password != "" {
+		return password, "env", nil
+	}
+
+	if path := os.Getenv("MIKROTIK_PASSWORD_FILE"); path != "" {
+		if path == "-" {
+			// stdin can only be read once; configuration reloads reuse the first password
+			if stdinPassword != "" {
+				return stdinPassword, "stdin", nil
+			}
+			password, err := readPasswordStdin(username)
+			if err != nil {
+				return "", "", fmt.Errorf("failed to read password from stdin: %w", err)
+			}
+			stdinPassword = password
+			return password, "stdin", nil
+		}
+		password, err := readPasswordFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read MIKROTIK_PASSWORD_FILE: %w", err)
+		}
+		return password, "file", nil
+	}
+
+	if parseBool(os.Getenv("MIKROTIK_PASSWORD_KEYRING"), false) {
+		service := getEnvOrDefault("MIKROTIK_KEYRING_SERVICE", defaultKeyringService)
+		password, err := keyringPassword(service, username)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read password from keyring (service %q, user %q): %w", service, username, err)
+		}
+		return password, "keyring", nil
+	}
+
+	return "", "", nil
+}
+
+// readPasswordFile reads a password file, ignoring a trailing newline
+// Warns when the file is readable by other users, like ssh does for private keys.
+func readPasswordFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		logWarn("Config", "Password file %s is accessible by other users (mode %04o), consider chmod 600", path, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	password := strings.TrimRight(string(data), "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return password, nil
+}
+
+// readPasswordStdin reads one line from stdin, prompting without echo when stdin is a terminal
+// Piped input (echo "$PASS" | mikrotik-stats) is read silently.
+func readPasswordStdin(username string) (string, error) {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprintf(os.Stderr, "Password for %s: ", username)
+		if restore, err := disableEcho(); err == nil {
+			defer func() {
+				restore()
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("empty password")
+	}
+	return password, nil
+}
@@ -0,0 +1,19 @@
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// cmdService is only available on Windows (see DEPLOYMENT.md for systemd)
+func cmdService(args []string) int {
+	fmt.Fprintln(os.Stderr, "Windows services are only supported on Windows; use the systemd unit from DEPLOYMENT.md")
+	return 2
+}
+
+// watchConsoleClose is a no-op: SIGTERM reaches the monitor through os/signal
+func watchConsoleClose(stop func()) (done func()) {
+	return func() {}
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+)
+
+// ============================================================================
+// Windows Service (service install / uninstall / run)
+// ============================================================================
+
+var (
+	procOpenSCManager                = advapi32.NewProc("OpenSCManagerW")
+	procCreateService                = advapi32.NewProc("CreateServiceW")
+	procOpenService                  = advapi32.NewProc("OpenServiceW")
+	procDeleteService                = advapi32.NewProc("DeleteService")
+	procControlService               = advapi32.NewProc("ControlService")
+	procCloseServiceHandle           = advapi32.NewProc("CloseServiceHandle")
+	procChangeServiceConfig2         = advapi32.NewProc("ChangeServiceConfig2W")
+	procStartServiceCtrlDispatcher   = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerEx = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus             = advapi32.NewProc("SetServiceStatus")
+	procRegisterEventSource          = advapi32.NewProc("RegisterEventSourceW")
+	procReportEvent                  = advapi32.NewProc("ReportEventW")
+	procRegCreateKeyEx               = advapi32.NewProc("RegCreateKeyExW")
+	procRegSetValueEx                = advapi32.NewProc("RegSetValueExW")
+	procRegCloseKey                  = advapi32.NewProc("RegCloseKey")
+	procRegDeleteKey                 = advapi32.NewProc("RegDeleteKeyW")
+
+	procSetConsoleCtrlHandler = kernel32.NewProc("SetConsoleCtrlHandler")
+)
+
+const (
+	defaultServiceName  = "mikrotik-stats"
+	serviceDisplayName  = "Mikrotik Interface Traffic Monitor"
+	serviceStopWaitHint = 20 * time.Second // Time announced to the SCM for the shutdown
+
+	scManagerAllAccess     = 0xF003F
+	serviceAllAccess       = 0xF01FF
+	serviceWin32OwnProcess = 0x10
+	serviceAutoStart       = 2
+	serviceErrorNormal     = 1
+
+	serviceConfigDescription        = 1
+	serviceConfigFailureActions     = 2
+	serviceConfigFailureActionsFlag = 4
+	scActionRestart                 = 1
+
+	serviceControlStop        = 1
+	serviceControlInterrogate = 4
+	serviceControlShutdown    = 5
+	serviceAcceptStop         = 1
+	serviceAcceptShutdown     = 4
+
+	serviceStopped     = 1
+	serviceStopPending = 3
+	serviceRunning     = 4
+
+	errorCallNotImplemented   = 120
+	errorServiceSpecificError = 1066
+
+	hkeyLocalMachine = 0x80000002
+	keyAllAccess     = 0xF003F
+	regExpandSz      = 2
+	regDword         = 4
+
+	eventlogErrorType       = 1
+	eventlogWarningType     = 2
+	eventlogInformationType = 4
+)
+
+// serviceStatus mirrors SERVICE_STATUS
+type serviceStatus struct {
+	serviceType             uint32
+	currentState            uint32
+	controlsAccepted        uint32
+	win32ExitCode           uint32
+	serviceSpecificExitCode uint32
+	checkPoint              uint32
+	waitHint                uint32
+}
+
+// serviceTableEntry mirrors SERVICE_TABLE_ENTRYW
+type serviceTableEntry struct {
+	name *uint16
+	proc uintptr
+}
+
+// scAction mirrors SC_ACTION
+type scAction struct {
+	actionType uint32
+	delay      uint32 // Milliseconds
+}
+
+// serviceFailureActions mirrors SERVICE_FAILURE_ACTIONSW
+type serviceFailureActions struct {
+	resetPeriod  uint32 // Seconds without failure after which the count restarts
+	rebootMsg    *uint16
+	command      *uint16
+	actionsCount uint32
+	actions      *scAction
+}
+
+// cmdService manages the Windows service
+//
+//	mikrotik-stats service install --env=C:\mikrotik-stats\.env
+//	sc start mikrotik-stats
+//	mikrotik-stats service uninstall
+//
+// The service control manager starts "service run" with the absolute paths
+// recorded at install time.
+func cmdService(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintf(os.Stderr, "Usage: %s service install|uninstall|run [--env=FILE] [--name=NAME]\n", os.Args[0])
+		return 2
+	}
+	action := args[0]
+
+	fs, envFile := newFlagSet("service " + action)
+	name := fs.String("name", defaultServiceName, "Service name")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *name == "" || strings.ContainsAny(*name, " \"\\/") {
+		fmt.Fprintln(os.Stderr, "Invalid service name (no spaces, quotes or slashes)")
+		return 2
+	}
+
+	var err error
+	switch action {
+	case "install":
+		err = installService(*name, *envFile)
+	case "uninstall":
+		err = uninstallService(*name)
+	case "run":
+		return runService(*name, *envFile)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown service action: %s (install, uninstall or run)\n", action)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Service %s failed: %v\n", action, err)
+		return 1
+	}
+	return 0
+}
+
+// installService registers the service (automatic start, restart on failure) and its event source
+func installService(name, envFile string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	env, err := filepath.Abs(envFile)
+	if err != nil {
+		return err
+	}
+	// Services do not see the installing user's environment, so the env file is the configuration
+	if _, err := os.Stat(env); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s not found; only system environment variables will be used\n", env)
+	}
+
+	manager, err := openSCManager()
+	if err != nil {
+		return err
+	}
+	defer procCloseServiceHandle.Call(manager)
+
+	command := fmt.Sprintf(`"%s" service run --env="%s" --name=%s`, exe, env, name)
+	service, _, err := procCreateService.Call(manager, utf16Ptr(name), utf16Ptr(serviceDisplayName),
+		serviceAllAccess, serviceWin32OwnProcess, serviceAutoStart, serviceErrorNormal,
+		utf16Ptr(command), 0, 0, 0, 0, 0)
+	if service == 0 {
+		return fmt.Errorf("CreateService: %w", err)
+	}
+	defer procCloseServiceHandle.Call(service)
+
+	description := utf16Ptr("Monitors Mikrotik router interface traffic")
+	if r, _, err := procChangeServiceConfig2.Call(service, serviceConfigDescription, uintptr(unsafe.Pointer(&description))); r == 0 {
+		logWarn("Service", "Failed to set the description: %v", err)
+	}
+
+	// Restart after failures, including a non-zero exit code (like systemd Restart=on-failure)
+	actions := []scAction{{scActionRestart, 10000}, {scActionRestart, 30000}, {scActionRestart, 60000}}
+	failure := serviceFailureActions{resetPeriod: 86400, actionsCount: uint32(len(actions)), actions: &actions[0]}
+	if r, _, err := procChangeServiceConfig2.Call(service, serviceConfigFailureActions, uintptr(unsafe.Pointer(&failure))); r == 0 {
+		logWarn("Service", "Failed to set the recovery actions: %v", err)
+	}
+	nonCrashFailures := int32(1)
+	procChangeServiceConfig2.Call(service, serviceConfigFailureActionsFlag, uintptr(unsafe.Pointer(&nonCrashFailures)))
+
+	if err := installEventSource(name); err != nil {
+		logWarn("Service", "Failed to register the event log source: %v", err)
+	}
+
+	fmt.Printf("Service %s installed (%s)\nStart it with: sc start %s\n", name, command, name)
+	return nil
+}
+
+// uninstallService stops and deletes the service and its event source
+func uninstallService(name string) error {
+	manager, err := openSCManager()
+	if err != nil {
+		return err
+	}
+	defer procCloseServiceHandle.Call(manager)
+
+	service, _, err := procOpenService.Call(manager, utf16Ptr(name), serviceAllAccess)
+	if service == 0 {
+		return fmt.Errorf("OpenService: %w", err)
+	}
+	defer procCloseServiceHandle.Call(service)
+
+	var status serviceStatus
+	procControlService.Call(service, serviceControlStop, uintptr(unsafe.Pointer(&status))) // Fails if not running
+	if r, _, err := procDeleteService.Call(service); r == 0 {
+		return fmt.Errorf("DeleteService: %w", err)
+	}
+	procRegDeleteKey.Call(hkeyLocalMachine, utf16Ptr(eventSourceKey(name)))
+
+	fmt.Printf("Service %s removed\n", name)
+	return nil
+}
+
+func openSCManager() (uintptr, error) {
+	manager, _, err := procOpenSCManager.Call(0, 0, scManagerAllAccess)
+	if manager == 0 {
+		return 0, fmt.Errorf("OpenSCManager (run as Administrator): %w", err)
+	}
+	return manager, nil
+}
+
+// windowsService is the state shared with the service control callbacks
+type windowsService struct {
+	name     string
+	envFile  string
+	ctx      context.Context
+	cancel   context.CancelFunc
+	exitCode int
+
+	mu         sync.Mutex
+	handle     uintptr // SERVICE_STATUS_HANDLE
+	checkPoint uint32
+}
+
+// runService runs the monitor under the service control manager
+// Diagnostic messages go to the Application event log; relative paths (data/,
+// log files) resolve next to the executable instead of the system directory.
+func runService(name, envFile string) int {
+	if exe, err := os.Executable(); err == nil {
+		os.Chdir(filepath.Dir(exe))
+	}
+	if events, err := openEventLog(name); err == nil {
+		serviceLogHandler = events.handler
+		setupLogging(slog.LevelInfo, "text")
+	}
+
+	s := &windowsService{name: name, envFile: envFile}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	table := []serviceTableEntry{{name: syscall.StringToUTF16Ptr(name), proc: syscall.NewCallback(s.main)}, {}}
+	if r, _, err := procStartServiceCtrlDispatcher.Call(uintptr(unsafe.Pointer(&table[0]))); r == 0 {
+		fmt.Fprintf(os.Stderr, "Not started by the service control manager (use 'sc start %s'): %v\n", name, err)
+		return 1
+	}
+	return s.exitCode
+}
+
+// main is the ServiceMain callback; it returns once the service has stopped
+func (s *windowsService) main(argc, argv uintptr) uintptr {
+	handle, _, err := procRegisterServiceCtrlHandlerEx.Call(utf16Ptr(s.name), syscall.NewCallback(s.control), 0)
+	if handle == 0 {
+		logError("Service", "RegisterServiceCtrlHandlerEx: %v", err)
+		s.exitCode = 1
+		return 0
+	}
+	s.handle = handle
+
+	s.setStatus(serviceRunning, 0)
+	logInfo("Service", "Service %s started", s.name)
+	s.exitCode = runMonitor(s.ctx, s.envFile)
+	s.setStatus(serviceStopped, s.exitCode)
+	return 0
+}
+
+// control is the HandlerEx callback: stop and shutdown cancel the monitor
+func (s *windowsService) control(code, eventType, eventData, context uintptr) uintptr {
+	switch code {
+	case serviceControlStop, serviceControlShutdown:
+		logInfo("Service", "Stop requested, flushing outputs")
+		s.setStatus(serviceStopPending, 0)
+		s.cancel()
+	case serviceControlInterrogate:
+	default:
+		return errorCallNotImplemented
+	}
+	return 0
+}
+
+// setStatus reports the service state; a non-zero exit code makes the SCM run the recovery actions
+func (s *windowsService) setStatus(state uint32, exitCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := serviceStatus{serviceType: serviceWin32OwnProcess, currentState: state}
+	switch state {
+	case serviceRunning:
+		status.controlsAccepted = serviceAcceptStop | serviceAcceptShutdown
+	case serviceStopPending:
+		s.checkPoint++
+		status.checkPoint = s.checkPoint
+		status.waitHint = uint32(serviceStopWaitHint / time.Millisecond)
+	}
+	if exitCode != 0 {
+		status.win32ExitCode = errorServiceSpecificError
+		status.serviceSpecificExitCode = uint32(exitCode)
+	}
+	if r, _, err := procSetServiceStatus.Call(s.handle, uintptr(unsafe.Pointer(&status))); r == 0 {
+		logWarn("Service", "SetServiceStatus: %v", err)
+	}
+}
+
+// ============================================================================
+// Event Log
+// ============================================================================
+
+// eventSourceKey is the registry key of the service's event source
+func eventSourceKey(name string) string {
+	return `SYSTEM\CurrentControlSet\Services\EventLog\Application\` + name
+}
+
+// installEventSource registers the source with EventCreate.exe as message file,
+// whose message 1 displays the logged text as is
+func installEventSource(name string) error {
+	var key uintptr
+	if r, _, _ := procRegCreateKeyEx.Call(hkeyLocalMachine, utf16Ptr(eventSourceKey(name)), 0, 0, 0,
+		keyAllAccess, 0, uintptr(unsafe.Pointer(&key)), 0); r != 0 {
+		return fmt.Errorf("RegCreateKeyEx: %w", syscall.Errno(r))
+	}
+	defer procRegCloseKey.Call(key)
+
+	file := utf16.Encode([]rune(`%SystemRoot%\System32\EventCreate.exe` + "\x00"))
+	if r, _, _ := procRegSetValueEx.Call(key, utf16Ptr("EventMessageFile"), 0, regExpandSz,
+		uintptr(unsafe.Pointer(&file[0])), uintptr(len(file)*2)); r != 0 {
+		return fmt.Errorf("RegSetValueEx: %w", syscall.Errno(r))
+	}
+	types := uint32(eventlogErrorType | eventlogWarningType | eventlogInformationType)
+	if r, _, _ := procRegSetValueEx.Call(key, utf16Ptr("TypesSupported"), 0, regDword,
+		uintptr(unsafe.Pointer(&types)), 4); r != 0 {
+		return fmt.Errorf("RegSetValueEx: %w", syscall.Errno(r))
+	}
+	return nil
+}
+
+// eventLog writes to the Application event log
+type eventLog struct {
+	source uintptr
+}
+
+func openEventLog(name string) (*eventLog, error) {
+	source, _, err := procRegisterEventSource.Call(0, utf16Ptr(name))
+	if source == 0 {
+		return nil, fmt.Errorf("RegisterEventSource: %w", err)
+	}
+	return &eventLog{source: source}, nil
+}
+
+// report writes one event (event ID 1, see installEventSource)
+func (l *eventLog) report(eventType uint16, message string) error {
+	text, err := syscall.UTF16PtrFromString(strings.ReplaceAll(message, "\x00", ""))
+	if err != nil {
+		return err
+	}
+	if r, _, err := procReportEvent.Call(l.source, uintptr(eventType), 0, 1, 0, 1, 0, uintptr(unsafe.Pointer(&text)), 0); r == 0 {
+		return err
+	}
+	return nil
+}
+
+// handler returns a slog handler writing "[component] message key=value" events
+func (l *eventLog) handler(level slog.Leveler) slog.Handler {
+	return &eventLogHandler{log: l, level: level}
+}
+
+// eventLogHandler is a slog handler for the event log (groups are flattened)
+type eventLogHandler struct {
+	log   *eventLog
+	level slog.Leveler
+	attrs []slog.Attr
+}
+
+func (h *eventLogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *eventLogHandler) Handle(_ context.Context, record slog.Record) error {
+	var component string
+	var fields strings.Builder
+	add := func(attr slog.Attr) bool {
+		if attr.Key == "component" {
+			component = attr.Value.String()
+		} else {
+			fmt.Fprintf(&fields, " %s=%v", attr.Key, attr.Value)
+		}
+		return true
+	}
+	for _, attr := range h.attrs {
+		add(attr)
+	}
+	record.Attrs(add)
+
+	message := record.Message + fields.String()
+	if component != "" {
+		message = "[" + component + "] " + message
+	}
+
+	eventType := uint16(eventlogInformationType)
+	switch {
+	case record.Level >= slog.LevelError:
+		eventType = eventlogErrorType
+	case record.Level >= slog.LevelWarn:
+		eventType = eventlogWarningType
+	}
+	return h.log.report(eventType, message)
+}
+
+func (h *eventLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &eventLogHandler{log: h.log, level: h.level, attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+}
+
+func (h *eventLogHandler) WithGroup(name string) slog.Handler {
+	return h
+}
+
+// ============================================================================
+// Console Close
+// ============================================================================
+
+// Console control events that end the process (Ctrl+C and Ctrl+Break go through os/signal)
+const (
+	ctrlCloseEvent    = 2
+	ctrlLogoffEvent   = 5
+	ctrlShutdownEvent = 6
+)
+
+// consoleCloseGrace is how long the console handler holds the process for the
+// shutdown; Windows terminates it about 5 seconds after CTRL_CLOSE_EVENT
+const consoleCloseGrace = 4500 * time.Millisecond
+
+// watchConsoleClose stops the monitor when the console window is closed, the user
+// logs off or the system shuts down, and holds the process until done is called,
+// so outputs are flushed and state is saved before Windows terminates it
+func watchConsoleClose(stop func()) (done func()) {
+	finished := make(chan struct{})
+	var once sync.Once
+
+	handler := syscall.NewCallback(func(event uintptr) uintptr {
+		switch event {
+		case ctrlCloseEvent, ctrlLogoffEvent, ctrlShutdownEvent:
+			logInfo("", "Console closing, flushing outputs")
+			stop()
+			select {
+			case <-finished:
+			case <-time.After(consoleCloseGrace):
+				logWarn("", "Shutdown did not finish within %v", consoleCloseGrace)
+			}
+			return 1
+		}
+		return 0 // Not handled: the next handler (os/signal) runs
+	})
+	if r, _, err := procSetConsoleCtrlHandler.Call(handler, 1); r == 0 {
+		logDebug("", "SetConsoleCtrlHandler: %v", err)
+	}
+
+	return func() { once.Do(func() { close(finished) }) }
+}
+
+// utf16Ptr converts a string without NUL bytes for a Windows API call
+func utf16Ptr(s string) uintptr {
+	return uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(s)))
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// PPP / Hotspot Session Collector
+// ============================================================================
+
+// Session types reported by the collector
+const (
+	SessionTypePPP     = "ppp"
+	SessionTypeHotspot = "hotspot"
+)
+
+// SessionRate holds calculated rates for a single active subscriber session
+// Rates are already in user perspective (upload = from user, download = to user)
+type SessionRate struct {
+	Type         string  `json:"type"`          // "ppp" or "hotspot"
+	User         string  `json:"user"`          // PPP secret name / hotspot user
+	Service      string  `json:"service"`       // pppoe, l2tp, sstp, ... (PPP only)
+	Address      string  `json:"address"`       // Assigned IP address
+	CallerID     string  `json:"caller_id"`     // MAC address or caller ID
+	Uptime       string  `json:"uptime"`        // Session uptime as reported by RouterOS
+	UploadRate   float64 `json:"upload_rate"`   // bytes/s from user
+	DownloadRate float64 `json:"download_rate"` // bytes/s to user
+}
+
+// SessionSnapshot is the result of one collection cycle
+type SessionSnapshot struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Counts    map[string]int `json:"counts"` // Session type -> active session count
+	Sessions  []SessionRate  `json:"sessions"`
+}
+
+// sessionCounter is the previous counter state for a session
+type sessionCounter struct {
+	RxByte uint64
+	TxByte uint64
+	Time   time.Time
+}
+
+// SessionCollector polls /ppp/active and /ip/hotspot/active and calculates per-session rates
+type SessionCollector struct {
+	client   RouterClient
+	config   *SessionsConfig
+	counters map[string]*sessionCounter // Session key -> previous counters
+
+	latest   *SessionSnapshot
+	latestMu sync.RWMutex
+}
+
+// NewSessionCollector creates a new session collector
+func NewSessionCollector(client RouterClient, config *SessionsConfig) *SessionCollector {
+	logInfo("Sessions", "Session collector initialized (interval: %v, ppp: %v, hotspot: %v)",
+		config.Interval, config.PPP, config.Hotspot)
+
+	return &SessionCollector{
+		client:   client,
+		config:   config,
+		counters: make(map[string]*sessionCounter),
+	}
+}
+
+// Collect queries the router for active sessions and returns a snapshot with rates
+func (s *SessionCollector) Collect(ctx context.Context, now time.Time) (*SessionSnapshot, error) {
+	snapshot := &SessionSnapshot{
+		Timestamp: now,
+		Counts:    make(map[string]int),
+		Sessions:  make([]SessionRate, 0),
+	}
+	seen := make(map[string]bool)
+
+	if s.config.PPP {
+		sessions, err := s.collectPPP(ctx, now, seen)
+		if err != nil {
+			return nil, fmt.Errorf("ppp sessions: %w", err)
+		}
+		snapshot.Sessions = append(snapshot.Sessions, sessions...)
+		snapshot.Counts[SessionTypePPP] = len(sessions)
+	}
+
+	if s.config.Hotspot {
+		sessions, err := s.collectHotspot(ctx, now, seen)
+		if err != nil {
+			return nil, fmt.Errorf("hotspot sessions: %w", err)
+		}
+		snapshot.Sessions = append(snapshot.Sessions, sessions...)
+		snapshot.Counts[SessionTypeHotspot] = len(sessions)
+	}
+
+	// Forget sessions that have disconnected
+	for key := range s.counters {
+		if !seen[key] {
+			delete(s.counters, key)
+		}
+	}
+
+	sort.Slice(snapshot.Sessions, func(i, j int) bool {
+		if snapshot.Sessions[i].Type != snapshot.Sessions[j].Type {
+			return snapshot.Sessions[i].Type < snapshot.Sessions[j].Type
+		}
+		return snapshot.Sessions[i].User < snapshot.Sessions[j].User
+	})
+
+	s.latestMu.Lock()
+	s.latest = snapshot
+	s.latestMu.Unlock()
+
+	return snapshot, nil
+}
+
+// Latest returns the most recent snapshot (nil before the first collection)
+func (s *SessionCollector) Latest() *SessionSnapshot {
+	s.latestMu.RLock()
+	defer s.latestMu.RUnlock()
+	return s.latest
+}
+
+// collectPPP reads active PPP sessions and their dynamic interface counters
+//
+// RouterOS does not report byte counters on /ppp/active, but every PPP session
+// owns a dynamic interface named "<service-user>" (e.g. "<pppoe-alice>").
+// On that interface RX = from user (upload), TX = to user (download).
+func (s *SessionCollector) collectPPP(ctx context.Context, now time.Time, seen map[string]bool) ([]SessionRate, error) {
+	// Both queries are pipelined on the connection
+	results, errs := RunAll(ctx, s.client,
+		[]string{
+			"/ppp/active/print",
+			"=.proplist=.id,name,service,caller-id,address,uptime",
+		},
+		[]string{
+			"/interface/print",
+			"=stats",
+			"=.proplist=name,rx-byte,tx-byte",
+			"?dynamic=yes",
+		},
+	)
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	active, ifaces := results[0], results[1]
+
+	counters := make(map[string]map[string]string, len(ifaces))
+	for _, iface := range ifaces {
+		counters[iface["name"]] = iface
+	}
+
+	sessions := make([]SessionRate, 0, len(active))
+	for _, entry := range active {
+		user := entry["name"]
+		service := entry["service"]
+		session := SessionRate{
+			Type:     SessionTypePPP,
+			User:     user,
+			Service:  service,
+			Address:  entry["address"],
+			CallerID: entry["caller-id"],
+			Uptime:   entry["uptime"],
+		}
+
+		ifaceName := fmt.Sprintf("<%s-%s>", service, user)
+		if iface, ok := counters[ifaceName]; ok {
+			key := SessionTypePPP + "/" + entry[".id"]
+			seen[key] = true
+			rxRate, txRate := s.updateCounters(key, iface["rx-byte"], iface["tx-byte"], now)
+			session.UploadRate = rxRate
+			session.DownloadRate = txRate
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// collectHotspot reads active hotspot sessions
+// bytes-in = from user (upload), bytes-out = to user (download)
+func (s *SessionCollector) collectHotspot(ctx context.Context, now time.Time, seen map[string]bool) ([]SessionRate, error) {
+	active, err := s.client.Run(ctx,
+		"/ip/hotspot/active/print",
+		"=.proplist=.id,user,address,mac-address,uptime,bytes-in,bytes-out",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]SessionRate, 0, len(active))
+	for _, entry := range active {
+		key := SessionTypeHotspot + "/" + entry[".id"]
+		seen[key] = true
+		rxRate, txRate := s.updateCounters(key, entry["bytes-in"], entry["bytes-out"], now)
+
+		sessions = append(sessions, SessionRate{
+			Type:         SessionTypeHotspot,
+			User:         entry["user"],
+			Address:      entry["address"],
+			CallerID:     entry["mac-address"],
+			Uptime:       entry["uptime"],
+			UploadRate:   rxRate,
+			DownloadRate: txRate,
+		})
+	}
+
+	return sessions, nil
+}
+
+// updateCounters stores new counter values for a session and returns rates since the previous poll
+// The first observation of a session (or a counter reset) yields zero rates
+func (s *SessionCollector) updateCounters(key, rxStr, txStr string, now time.Time) (rxRate, txRate float64) {
+	rx, errRx := strconv.ParseUint(strings.TrimSpace(rxStr), 10, 64)
+	tx, errTx := strconv.ParseUint(strings.TrimSpace(txStr), 10, 64)
+	if errRx != nil || errTx != nil {
+		return 0, 0
+	}
+
+	prev, exists := s.counters[key]
+	s.counters[key] = &sessionCounter{RxByte: rx, TxByte: tx, Time: now}
+	if !exists || rx < prev.RxByte || tx < prev.TxByte {
+		return 0, 0
+	}
+
+	timeDiff := now.Sub(prev.Time).Seconds()
+	if timeDiff <= 0 {
+		return 0, 0
+	}
+
+	return float64(rx-prev.RxByte) / timeDiff, float64(tx-prev.TxByte) / timeDiff
+}
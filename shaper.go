@@ -0,0 +1,532 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Traffic Shaper (queue limits driven by measured traffic)
+// ============================================================================
+
+// shaperActionHistory is the number of recent actions kept for /api/shaper
+const shaperActionHistory = 100
+
+// Shaper actions
+const (
+	ShaperCap     = "cap"     // max-limit set to the policy limit
+	ShaperRestore = "restore" // max-limit set back to the value before the cap
+	ShaperRelease = "release" // Cap dropped without a write: the queue was changed by someone else
+	ShaperError   = "error"   // Reading or writing the queue failed
+)
+
+// ShaperPolicy caps a simple queue once an interface used up its traffic quota
+type ShaperPolicy struct {
+	Interface string  // Interface or group whose traffic counts (upload + download)
+	Period    string  // day, week or month (local calendar)
+	Quota     float64 // bytes per period
+	Queue     string  // /queue/simple entry name
+	Limit     string  // max-limit while capped, "upload/download" of the queue target (e.g. "10M/10M")
+
+	rule string // Entry as configured, for logs and the audit file
+}
+
+// String renders the policy in SHAPER_POLICIES syntax
+func (p ShaperPolicy) String() string {
+	return p.rule
+}
+
+// parseShaperPolicies parses "vlan2622:month>500G=customer-a:10M/10M,..."
+// The quota is in bytes with a k/M/G/T suffix (decimal); the limit is written to the queue as is.
+func parseShaperPolicies(value string) ([]ShaperPolicy, error) {
+	var policies []ShaperPolicy
+	for _, entry := range parseCommaSeparated(value, "") {
+		condition, action, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid policy %q (expected interface:period>quota=queue:limit)", entry)
+		}
+		name, quota, ok := strings.Cut(condition, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid policy %q (expected interface:period>quota)", entry)
+		}
+		period, quota, ok := strings.Cut(quota, ">")
+		period = strings.ToLower(strings.TrimSpace(period))
+		if !ok || (period != "day" && period != "week" && period != "month") {
+			return nil, fmt.Errorf("invalid policy %q (period must be day, week or month followed by >quota)", entry)
+		}
+		bytes, err := parseVolume(quota)
+		if err != nil || bytes <= 0 {
+			return nil, fmt.Errorf("invalid policy %q (bad quota %q)", entry, quota)
+		}
+		i := strings.LastIndex(action, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid policy %q (expected queue:upload/download after =)", entry)
+		}
+		queue, limit := action[:i], action[i+1:]
+		if _, _, ok := parseQueueLimit(limit); !ok {
+			return nil, fmt.Errorf("invalid policy %q (bad limit %q, e.g. 10M/10M)", entry, limit)
+		}
+		policies = append(policies, ShaperPolicy{
+			Interface: name,
+			Period:    period,
+			Quota:     bytes,
+			Queue:     queue,
+			Limit:     limit,
+			rule:      entry,
+		})
+	}
+	return policies, nil
+}
+
+// parseVolume parses a byte count such as "500G" or "1.5T" (decimal units)
+func parseVolume(value string) (float64, error) {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "B")
+	multiplier := 1.0
+	if value != "" {
+		switch value[len(value)-1] {
+		case 'k', 'K':
+			multiplier = 1e3
+		case 'M':
+			multiplier = 1e6
+		case 'G':
+			multiplier = 1e9
+		case 'T':
+			multiplier = 1e12
+		}
+	}
+	if multiplier != 1 {
+		value = value[:len(value)-1]
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid volume: %s", value)
+	}
+	return n * multiplier, nil
+}
+
+// parseQueueLimit parses a queue max-limit "upload/download" ("10M/10M" or "10000000/10000000")
+func parseQueueLimit(value string) (upload, download float64, ok bool) {
+	up, down, found := strings.Cut(value, "/")
+	if !found {
+		return 0, 0, false
+	}
+	upload, err1 := parseRate(up)
+	download, err2 := parseRate(down)
+	return upload, download, err1 == nil && err2 == nil
+}
+
+// sameQueueLimit reports whether two max-limit values are equal ("10M/10M" equals "10000000/10000000")
+func sameQueueLimit(a, b string) bool {
+	au, ad, ok1 := parseQueueLimit(a)
+	bu, bd, ok2 := parseQueueLimit(b)
+	if !ok1 || !ok2 {
+		return a == b
+	}
+	return au == bu && ad == bd
+}
+
+// shaperPeriodNames are the periods as used in messages
+var shaperPeriodNames = map[string]string{"day": "today", "week": "this week", "month": "this month"}
+
+// shaperPeriodStart returns the start of the calendar period containing now (in now's zone)
+func shaperPeriodStart(now time.Time, period string) time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case "week":
+		return today.AddDate(0, 0, -(int(today.Weekday())+6)%7) // Monday
+	case "month":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	default:
+		return today
+	}
+}
+
+// ShaperAction is one entry of the audit trail
+type ShaperAction struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"` // cap, restore, release or error
+	DryRun    bool      `json:"dry_run,omitempty"`
+	Queue     string    `json:"queue"`
+	Interface string    `json:"interface"`
+	Policy    string    `json:"policy"`
+	Used      float64   `json:"used"`           // Bytes in the current period
+	From      string    `json:"from,omitempty"` // max-limit before the action
+	To        string    `json:"to,omitempty"`   // max-limit after the action
+	Message   string    `json:"message"`
+}
+
+// ShaperPolicyStatus is the state of one policy in /api/shaper
+type ShaperPolicyStatus struct {
+	Policy      string    `json:"policy"`
+	Interface   string    `json:"interface"`
+	Period      string    `json:"period"`
+	PeriodStart time.Time `json:"period_start"`
+	Quota       float64   `json:"quota"` // bytes
+	Used        float64   `json:"used"`  // bytes
+	Queue       string    `json:"queue"`
+	Limit       string    `json:"limit"`
+	Capped      bool      `json:"capped"`
+	Original    string    `json:"original,omitempty"` // max-limit restored when the period ends
+	Since       time.Time `json:"since"`
+}
+
+// ShaperSnapshot is returned by /api/shaper
+type ShaperSnapshot struct {
+	DryRun   bool                 `json:"dry_run"`
+	Policies []ShaperPolicyStatus `json:"policies"`
+	Actions  []ShaperAction       `json:"actions"` // Newest first
+}
+
+// shaperUsage is the traffic of one interface in one period
+type shaperUsage struct {
+	Start time.Time `json:"start"`
+	Bytes float64   `json:"bytes"`
+}
+
+// shaperCap is a queue limited by a policy
+type shaperCap struct {
+	Policy   string    `json:"policy"`
+	Original string    `json:"original"` // max-limit before the cap
+	Limit    string    `json:"limit"`    // max-limit written
+	Since    time.Time `json:"since"`
+	DryRun   bool      `json:"dry_run,omitempty"` // Only logged, the queue was not changed
+}
+
+// shaperState is the content of SHAPER_STATE_FILE
+type shaperState struct {
+	Usage  map[string]*shaperUsage `json:"usage"`  // interface/period -> traffic
+	Capped map[string]*shaperCap   `json:"capped"` // queue -> cap
+}
+
+// ShaperController enforces SHAPER_POLICIES on /queue/simple
+//
+// Traffic is counted from the interface byte counters of every sample, per
+// local calendar period. When an interface exceeds its quota, the policy's
+// queue gets the policy max-limit; the previous value is kept in the state
+// file and written back when the period ends. A queue whose max-limit was
+// changed by someone else while capped is left alone. Every action is logged
+// and appended to SHAPER_AUDIT_FILE; in dry-run mode nothing is written to
+// the router. Usage is only counted while the monitor runs.
+type ShaperController struct {
+	client   RouterClient
+	config   *ShaperConfig
+	location *time.Location // TIMEZONE: quota periods start at its midnight
+	policies []ShaperPolicy
+
+	counters map[string][2]uint64 // Interface -> raw rx, tx counters of the last sample
+
+	state   shaperState
+	actions []ShaperAction // Newest last
+	mu      sync.Mutex
+}
+
+// NewShaperController creates a shaper and loads its saved usage and caps
+// Quota periods are calendar periods in location (the TIMEZONE zone).
+func NewShaperController(client RouterClient, config *ShaperConfig, location *time.Location) *ShaperController {
+	policies, _ := parseShaperPolicies(config.Policies) // Validated with the config
+	mode := "enforcing"
+	if config.DryRun {
+		mode = "dry run"
+	}
+	logInfo("Shaper", "Traffic shaper initialized (%d policies, %s, every %v)", len(policies), mode, config.Interval)
+
+	s := &ShaperController{
+		client:   client,
+		config:   config,
+		location: location,
+		policies: policies,
+		counters: make(map[string][2]uint64),
+		state: shaperState{
+			Usage:  make(map[string]*shaperUsage),
+			Capped: make(map[string]*shaperCap),
+		},
+	}
+	s.load()
+	return s
+}
+
+// load reads the saved usage and caps (missing or unreadable file = start over)
+func (s *ShaperController) load() {
+	data, err := os.ReadFile(s.config.StateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logWarn("Shaper", "Failed to read %s: %v", s.config.StateFile, err)
+		}
+		return
+	}
+	var state shaperState
+	if err := json.Unmarshal(data, &state); err != nil {
+		logWarn("Shaper", "Ignoring unreadable %s: %v", s.config.StateFile, err)
+		return
+	}
+	for key, usage := range state.Usage {
+		s.state.Usage[key] = usage
+	}
+	for queue, capped := range state.Capped {
+		s.state.Capped[queue] = capped
+	}
+	if len(s.state.Capped) > 0 {
+		logInfo("Shaper", "%d queues capped before the restart", len(s.state.Capped))
+	}
+}
+
+// save writes usage and caps through a temporary file
+func (s *ShaperController) save() error {
+	s.mu.Lock()
+	data, err := json.Marshal(s.state)
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("marshal shaper state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.config.StateFile), 0755); err != nil {
+		return err
+	}
+	tmp := s.config.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.config.StateFile)
+}
+
+// Add counts the traffic of one sample (monitoring loop goroutine)
+func (s *ShaperController) Add(now time.Time, stats map[string]*RateInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counted := make(map[string]bool) // Policies sharing an interface and period share the usage
+	for _, policy := range s.policies {
+		info, ok := stats[policy.Interface]
+		key := policy.Interface + "/" + policy.Period
+		if !ok || counted[key] {
+			continue
+		}
+		counted[key] = true
+		start := shaperPeriodStart(now, policy.Period)
+		usage := s.state.Usage[key]
+		if usage == nil || !usage.Start.Equal(start) {
+			usage = &shaperUsage{Start: start}
+			s.state.Usage[key] = usage
+		}
+		usage.Bytes += s.delta(policy.Interface, info)
+	}
+	for _, policy := range s.policies {
+		if info, ok := stats[policy.Interface]; ok {
+			s.counters[policy.Interface] = [2]uint64{info.RxBytes, info.TxBytes}
+		}
+	}
+}
+
+// delta returns the bytes counted by an interface since the previous sample
+// A counter that went backwards (router reboot, counter reset) counts from zero.
+func (s *ShaperController) delta(name string, info *RateInfo) float64 {
+	last, ok := s.counters[name]
+	if !ok {
+		return 0
+	}
+	var total uint64
+	for i, value := range []uint64{info.RxBytes, info.TxBytes} {
+		if value >= last[i] {
+			total += value - last[i]
+		} else {
+			total += value
+		}
+	}
+	return float64(total)
+}
+
+// used returns the bytes of a policy's interface in the current period
+func (s *ShaperController) used(policy ShaperPolicy, now time.Time) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	usage := s.state.Usage[policy.Interface+"/"+policy.Period]
+	if usage == nil || !usage.Start.Equal(shaperPeriodStart(now, policy.Period)) {
+		return 0
+	}
+	return usage.Bytes
+}
+
+// Enforce caps the queues of exceeded policies and restores them in a new period
+func (s *ShaperController) Enforce(ctx context.Context) error {
+	now := time.Now().In(s.location)
+	var errs []error
+	for _, policy := range s.policies {
+		used := s.used(policy, now)
+		s.mu.Lock()
+		capped := s.state.Capped[policy.Queue]
+		s.mu.Unlock()
+
+		var err error
+		switch {
+		case used >= policy.Quota && capped == nil:
+			err = s.limitQueue(ctx, now, policy, used)
+		case used < policy.Quota && capped != nil && (capped.DryRun || !s.config.DryRun):
+			// A real cap outlives a switch to dry run and is restored once enforcing again
+			err = s.restoreQueue(ctx, now, policy, capped, used)
+		}
+		if err != nil {
+			s.record(ShaperAction{Time: now, Action: ShaperError, Queue: policy.Queue, Interface: policy.Interface,
+				Policy: policy.String(), Used: used, Message: err.Error()})
+			errs = append(errs, err)
+		}
+	}
+
+	if err := s.save(); err != nil {
+		logError("Shaper", "Failed to save %s: %v", s.config.StateFile, err)
+	}
+	return errors.Join(errs...)
+}
+
+// limitQueue sets the policy limit on its queue, remembering the previous max-limit
+func (s *ShaperController) limitQueue(ctx context.Context, now time.Time, policy ShaperPolicy, used float64) error {
+	id, current, err := s.queue(ctx, policy.Queue)
+	if err != nil {
+		return err
+	}
+	if !s.config.DryRun && !sameQueueLimit(current, policy.Limit) {
+		if _, err := s.client.Run(ctx, "/queue/simple/set", "=.id="+id, "=max-limit="+policy.Limit); err != nil {
+			return fmt.Errorf("cap queue %s: %w", policy.Queue, err)
+		}
+	}
+
+	s.mu.Lock()
+	s.state.Capped[policy.Queue] = &shaperCap{Policy: policy.String(), Original: current, Limit: policy.Limit, Since: now, DryRun: s.config.DryRun}
+	s.mu.Unlock()
+	s.record(ShaperAction{
+		Time: now, Action: ShaperCap, DryRun: s.config.DryRun, Queue: policy.Queue, Interface: policy.Interface,
+		Policy: policy.String(), Used: used, From: current, To: policy.Limit,
+		Message: fmt.Sprintf("%s used %s of %s %s, queue %s limited to %s (was %s)",
+			policy.Interface, formatByteCount(uint64(used)), formatByteCount(uint64(policy.Quota)), shaperPeriodNames[policy.Period], policy.Queue, policy.Limit, current),
+	})
+	return nil
+}
+
+// restoreQueue writes the max-limit from before the cap back, unless the queue was changed meanwhile
+func (s *ShaperController) restoreQueue(ctx context.Context, now time.Time, policy ShaperPolicy, capped *shaperCap, used float64) error {
+	id, current, err := s.queue(ctx, policy.Queue)
+	if err != nil {
+		return err
+	}
+
+	action := ShaperAction{
+		Time: now, Action: ShaperRestore, DryRun: capped.DryRun, Queue: policy.Queue, Interface: policy.Interface,
+		Policy: policy.String(), Used: used, From: current, To: capped.Original,
+		Message: fmt.Sprintf("New %s for %s, queue %s restored to %s", policy.Period, policy.Interface, policy.Queue, capped.Original),
+	}
+	switch {
+	case capped.DryRun:
+	case !sameQueueLimit(current, capped.Limit):
+		action.Action, action.To = ShaperRelease, ""
+		action.Message = fmt.Sprintf("Queue %s was changed to %s while capped, leaving it alone", policy.Queue, current)
+	default:
+		if _, err := s.client.Run(ctx, "/queue/simple/set", "=.id="+id, "=max-limit="+capped.Original); err != nil {
+			return fmt.Errorf("restore queue %s: %w", policy.Queue, err)
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.state.Capped, policy.Queue)
+	s.mu.Unlock()
+	s.record(action)
+	return nil
+}
+
+// queue returns the ID and max-limit of a simple queue
+func (s *ShaperController) queue(ctx context.Context, name string) (id, maxLimit string, err error) {
+	rows, err := s.client.Run(ctx, "/queue/simple/print", "?name="+name, "=.proplist=.id,name,max-limit")
+	if err != nil {
+		return "", "", fmt.Errorf("read queue %s: %w", name, err)
+	}
+	for _, row := range rows {
+		if row["name"] == name {
+			return row[".id"], row["max-limit"], nil
+		}
+	}
+	return "", "", fmt.Errorf("queue %s not found in /queue/simple", name)
+}
+
+// record logs an action, appends it to the audit file and keeps it for /api/shaper
+func (s *ShaperController) record(action ShaperAction) {
+	message := action.Message
+	if action.DryRun {
+		message = "[dry run] " + message
+	}
+	switch action.Action {
+	case ShaperCap:
+		logWarn("Shaper", "%s", message)
+	case ShaperError:
+		logError("Shaper", "%s", message)
+	default:
+		logInfo("Shaper", "%s", message)
+	}
+
+	if err := appendJSONLine(s.config.AuditFile, action); err != nil {
+		logError("Shaper", "Failed to write %s: %v", s.config.AuditFile, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions = append(s.actions, action)
+	if len(s.actions) > shaperActionHistory {
+		s.actions = s.actions[len(s.actions)-shaperActionHistory:]
+	}
+}
+
+// appendJSONLine appends one JSON document per line to a file, creating its directory
+func appendJSONLine(path string, value any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file) // One line per document
+	encoder.SetEscapeHTML(false)     // Keep policies such as "month>500G" readable
+	err = encoder.Encode(value)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Snapshot returns the policies with their usage and the recent actions
+func (s *ShaperController) Snapshot() *ShaperSnapshot {
+	now := time.Now().In(s.location)
+	snapshot := &ShaperSnapshot{DryRun: s.config.DryRun, Policies: make([]ShaperPolicyStatus, 0, len(s.policies))}
+	for _, policy := range s.policies {
+		used := s.used(policy, now)
+		status := ShaperPolicyStatus{
+			Policy:      policy.String(),
+			Interface:   policy.Interface,
+			Period:      policy.Period,
+			PeriodStart: shaperPeriodStart(now, policy.Period),
+			Quota:       policy.Quota,
+			Used:        used,
+			Queue:       policy.Queue,
+			Limit:       policy.Limit,
+		}
+		s.mu.Lock()
+		if capped := s.state.Capped[policy.Queue]; capped != nil {
+			status.Capped, status.Original, status.Since = true, capped.Original, capped.Since
+		}
+		s.mu.Unlock()
+		snapshot.Policies = append(snapshot.Policies, status)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot.Actions = make([]ShaperAction, 0, len(s.actions))
+	for i := len(s.actions) - 1; i >= 0; i-- {
+		snapshot.Actions = append(snapshot.Actions, s.actions[i])
+	}
+	return snapshot
+}
@@ -0,0 +1,60 @@
+package main
+
+// ============================================================================
+// Rate Smoothing (EWMA of the current rates)
+// ============================================================================
+
+// rateSmoother replaces the current rates of an output with their exponentially
+// weighted moving average
+//
+// On bursty links the per-second rates jump by an order of magnitude from one
+// sample to the next. Each sample moves the smoothed rate by alpha of the way
+// towards the measured one, so a burst fades over a few samples instead of
+// flashing by. Window averages, peaks and counters are left as measured. Only
+// used by the goroutine of its output.
+type rateSmoother struct {
+	alpha float64
+	rates map[string][2]float64 // Interface -> smoothed rx, tx
+}
+
+// newRateSmoother creates a smoother (nil if the output shows the measured rates)
+func newRateSmoother(config *SmoothingConfig) *rateSmoother {
+	if config == nil {
+		return nil
+	}
+	return &rateSmoother{alpha: config.Alpha, rates: make(map[string][2]float64)}
+}
+
+// apply returns the sample with smoothed current rates (the sample itself without smoothing)
+// The RateInfo values are copied, as the other outputs share them.
+func (s *rateSmoother) apply(stats map[string]*RateInfo) map[string]*RateInfo {
+	if s == nil {
+		return stats
+	}
+
+	smoothed := make(map[string]*RateInfo, len(stats))
+	for name, info := range stats {
+		rates, ok := s.rates[name]
+		if !ok {
+			rates = [2]float64{info.RxRate, info.TxRate} // New interface: start at the measured rate
+		} else {
+			rates[0] += s.alpha * (info.RxRate - rates[0])
+			rates[1] += s.alpha * (info.TxRate - rates[1])
+		}
+
+		copied := *info
+		copied.RxRate, copied.TxRate = rates[0], rates[1]
+		if copied.LinkSpeed > 0 {
+			copied.RxUtilization = utilization(rates[0], copied.LinkSpeed)
+			copied.TxUtilization = utilization(rates[1], copied.LinkSpeed)
+		}
+		smoothed[name] = &copied
+	}
+
+	// Forget interfaces that are no longer reported
+	s.rates = make(map[string][2]float64, len(smoothed))
+	for name, info := range smoothed {
+		s.rates[name] = [2]float64{info.RxRate, info.TxRate}
+	}
+	return smoothed
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SNMP Fallback Client
+// Minimal SNMPv2c implementation (GetBulk walk only) for routers with the API service disabled
+// Reference: RFC 3416 (protocol operations), RFC 2863 (IF-MIB)
+//
+// Interface counters are read from IF-MIB::ifXTable 64-bit columns:
+//   ifName        1.3.6.1.2.1.31.1.1.1.1
+//   ifHCInOctets  1.3.6.1.2.1.31.1.1.1.6   (= rx-byte)
+//   ifHCOutOctets 1.3.6.1.2.1.31.1.1.1.10  (= tx-byte)
+
+var (
+	oidIfName        = []int{1, 3, 6, 1, 2, 1, 31, 1, 1, 1, 1}
+	oidIfHCInOctets  = []int{1, 3, 6, 1, 2, 1, 31, 1, 1, 1, 6}
+	oidIfHCOutOctets = []int{1, 3, 6, 1, 2, 1, 31, 1, 1, 1, 10}
+)
+
+// BER / SNMP tags
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berNull        = 0x05
+	berOID         = 0x06
+	berSequence    = 0x30
+	snmpResponse   = 0xA2
+	snmpGetBulk    = 0xA5
+	snmpNoSuchObj  = 0x80
+	snmpNoSuchInst = 0x81
+	snmpEndOfMib   = 0x82
+	snmpVersion2c  = 1
+	snmpMaxRepeats = 25
+)
+
+// SNMPClient implements RouterClient using SNMPv2c
+// Only interface counters are available; Run() is not supported
+// Safe for concurrent use: requests on the shared socket are serialized
+type SNMPClient struct {
+	conn      net.Conn
+	community string
+	timeout   time.Duration
+	retries   int
+	mu        sync.Mutex
+}
+
+// snmpVarBind is a decoded OID/value pair
+type snmpVarBind struct {
+	OID   []int
+	Type  byte
+	Value []byte
+}
+
+// NewSNMPClient creates an SNMP client and verifies the agent responds
+func NewSNMPClient(ctx context.Context, config *Config) (*SNMPClient, error) {
+	address := net.JoinHostPort(config.Host, config.SNMPPort)
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	client := &SNMPClient{
+		conn:      conn,
+		community: config.SNMPCommunity,
+		timeout:   config.CommandTimeout,
+		retries:   1,
+	}
+
+	// UDP is connectionless, so probe the agent once to fail fast on bad community/host
+	if _, err := client.getBulk(ctx, oidIfName, 1); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SNMP agent not responding: %w", err)
+	}
+
+	return client, nil
+}
+
+// GetInterfaceStats walks ifName/ifHCInOctets/ifHCOutOctets and returns counters for the requested interfaces
+func (c *SNMPClient) GetInterfaceStats(ctx context.Context, interfaces []string) ([]InterfaceStats, error) {
+	logDebug("Client", "SNMP walk %s, %s, %s",
+		formatOID(oidIfName), formatOID(oidIfHCInOctets), formatOID(oidIfHCOutOctets))
+
+	names, err := c.walk(ctx, oidIfName)
+	if err != nil {
+		return nil, fmt.Errorf("walk ifName: %w", err)
+	}
+	inOctets, err := c.walk(ctx, oidIfHCInOctets)
+	if err != nil {
+		return nil, fmt.Errorf("walk ifHCInOctets: %w", err)
+	}
+	outOctets, err := c.walk(ctx, oidIfHCOutOctets)
+	if err != nil {
+		return nil, fmt.Errorf("walk ifHCOutOctets: %w", err)
+	}
+
+	wanted := toSet(interfaces)
+	stats := make([]InterfaceStats, 0, len(interfaces))
+	for index, vb := range names {
+		name := string(vb.Value)
+		if !wanted[name] {
+			continue
+		}
+
+		in, okIn := inOctets[index]
+		out, okOut := outOctets[index]
+		if !okIn || !okOut {
+			continue
+		}
+
+		stats = append(stats, InterfaceStats{
+			Name:   name,
+			RxByte: decodeUnsigned(in.Value),
+			TxByte: decodeUnsigned(out.Value),
+		})
+	}
+
+	return stats, nil
+}
+
+// Run is not supported over SNMP (collectors need the API or REST transport)
+func (c *SNMPClient) Run(ctx context.Context, words ...string) ([]map[string]string, error) {
+	return nil, fmt.Errorf("command %q not supported by SNMP transport", strings.Join(words, " "))
+}
+
+// Close closes the UDP socket
+func (c *SNMPClient) Close() error {
+	return c.conn.Close()
+}
+
+// walk retrieves all rows under a table column, keyed by ifIndex (last OID arc)
+func (c *SNMPClient) walk(ctx context.Context, column []int) (map[int]snmpVarBind, error) {
+	result := make(map[int]snmpVarBind)
+	current := column
+
+	for {
+		varBinds, err := c.getBulk(ctx, current, snmpMaxRepeats)
+		if err != nil {
+			return nil, err
+		}
+		if len(varBinds) == 0 {
+			return result, nil
+		}
+
+		for _, vb := range varBinds {
+			if vb.Type == snmpEndOfMib || vb.Type == snmpNoSuchObj || vb.Type == snmpNoSuchInst {
+				return result, nil
+			}
+			if !oidHasPrefix(vb.OID, column) || len(vb.OID) != len(column)+1 {
+				return result, nil
+			}
+			result[vb.OID[len(column)]] = vb
+			current = vb.OID
+		}
+	}
+}
+
+// getBulk sends a GetBulkRequest and returns the response varbinds
+func (c *SNMPClient) getBulk(ctx context.Context, oid []int, maxRepetitions int) ([]snmpVarBind, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	requestID := rand.Int31()
+
+	varBind := berEncode(berSequence, append(berEncode(berOID, encodeOID(oid)), berEncode(berNull, nil)...))
+	pdu := berEncode(snmpGetBulk, concatBytes(
+		berEncode(berInteger, encodeInteger(int64(requestID))),
+		berEncode(berInteger, encodeInteger(0)), // non-repeaters
+		berEncode(berInteger, encodeInteger(int64(maxRepetitions))),
+		berEncode(berSequence, varBind),
+	))
+	packet := berEncode(berSequence, concatBytes(
+		berEncode(berInteger, encodeInteger(snmpVersion2c)),
+		berEncode(berOctetString, []byte(c.community)),
+		pdu,
+	))
+
+	// Cancellation unblocks a pending read immediately
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetReadDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	buf := make([]byte, 65535)
+	var lastErr error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, err := c.conn.Write(packet); err != nil {
+			return nil, err
+		}
+
+		deadline := time.Now().Add(c.timeout)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		c.conn.SetReadDeadline(deadline)
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		respID, varBinds, err := decodeResponse(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		if respID != int64(requestID) {
+			lastErr = fmt.Errorf("mismatched request id %d (expected %d)", respID, requestID)
+			continue
+		}
+		return varBinds, nil
+	}
+
+	return nil, lastErr
+}
+
+// ============================================================================
+// BER Encoding Helpers
+// ============================================================================
+
+// berEncode wraps content in a tag-length-value triplet
+func berEncode(tag byte, content []byte) []byte {
+	out := []byte{tag}
+	length := len(content)
+	switch {
+	case length < 0x80:
+		out = append(out, byte(length))
+	case length < 0x100:
+		out = append(out, 0x81, byte(length))
+	default:
+		out = append(out, 0x82, byte(length>>8), byte(length))
+	}
+	return append(out, content...)
+}
+
+// encodeInteger encodes a signed integer in minimal two's complement form
+func encodeInteger(value int64) []byte {
+	var out []byte
+	for {
+		out = append([]byte{byte(value)}, out...)
+		value >>= 8
+		if (value == 0 && out[0]&0x80 == 0) || (value == -1 && out[0]&0x80 != 0) {
+			return out
+		}
+	}
+}
+
+// encodeOID encodes an object identifier
+func encodeOID(oid []int) []byte {
+	out := []byte{byte(oid[0]*40 + oid[1])}
+	for _, arc := range oid[2:] {
+		var enc []byte
+		enc = append(enc, byte(arc&0x7F))
+		arc >>= 7
+		for arc > 0 {
+			enc = append([]byte{byte(arc&0x7F) | 0x80}, enc...)
+			arc >>= 7
+		}
+		out = append(out, enc...)
+	}
+	return out
+}
+
+// concatBytes joins byte slices
+func concatBytes(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// ============================================================================
+// BER Decoding Helpers
+// ============================================================================
+
+// berDecode reads one TLV and returns tag, content and the remaining bytes
+func berDecode(data []byte) (tag byte, content []byte, rest []byte, err error) {
+	if len(data) < 2 {
+		return 0, nil, nil, fmt.Errorf("truncated BER element")
+	}
+
+	tag = data[0]
+	length := int(data[1])
+	offset := 2
+	if length&0x80 != 0 {
+		numBytes := length & 0x7F
+		if numBytes == 0 || numBytes > 3 || len(data) < 2+numBytes {
+			return 0, nil, nil, fmt.Errorf("invalid BER length")
+		}
+		length = 0
+		for i := 0; i < numBytes; i++ {
+			length = length<<8 | int(data[2+i])
+		}
+		offset += numBytes
+	}
+
+	if len(data) < offset+length {
+		return 0, nil, nil, fmt.Errorf("truncated BER content")
+	}
+	return tag, data[offset : offset+length], data[offset+length:], nil
+}
+
+// decodeResponse parses an SNMP Response PDU into request ID and varbinds
+func decodeResponse(data []byte) (int64, []snmpVarBind, error) {
+	tag, message, _, err := berDecode(data)
+	if err != nil || tag != berSequence {
+		return 0, nil, fmt.Errorf("invalid SNMP message")
+	}
+
+	// Skip version and community
+	for i := 0; i < 2; i++ {
+		if _, _, message, err = berDecode(message); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	tag, pdu, _, err := berDecode(message)
+	if err != nil || tag != snmpResponse {
+		return 0, nil, fmt.Errorf("unexpected PDU type 0x%X", tag)
+	}
+
+	fields := make([][]byte, 3) // request-id, error-status, error-index
+	for i := range fields {
+		if _, fields[i], pdu, err = berDecode(pdu); err != nil {
+			return 0, nil, err
+		}
+	}
+	requestID := decodeSigned(fields[0])
+	if status := decodeSigned(fields[1]); status != 0 {
+		return requestID, nil, fmt.Errorf("SNMP error status %d", status)
+	}
+
+	_, list, _, err := berDecode(pdu)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var varBinds []snmpVarBind
+	for len(list) > 0 {
+		var item []byte
+		if _, item, list, err = berDecode(list); err != nil {
+			return 0, nil, err
+		}
+		_, oidBytes, valuePart, err := berDecode(item)
+		if err != nil {
+			return 0, nil, err
+		}
+		valueTag, value, _, err := berDecode(valuePart)
+		if err != nil {
+			return 0, nil, err
+		}
+		varBinds = append(varBinds, snmpVarBind{
+			OID:   decodeOID(oidBytes),
+			Type:  valueTag,
+			Value: value,
+		})
+	}
+
+	return requestID, varBinds, nil
+}
+
+// decodeOID decodes an object identifier
+func decodeOID(data []byte) []int {
+	if len(data) == 0 {
+		return nil
+	}
+	oid := []int{int(data[0]) / 40, int(data[0]) % 40}
+	arc := 0
+	for _, b := range data[1:] {
+		arc = arc<<7 | int(b&0x7F)
+		if b&0x80 == 0 {
+			oid = append(oid, arc)
+			arc = 0
+		}
+	}
+	return oid
+}
+
+// decodeSigned decodes a two's complement integer
+func decodeSigned(data []byte) int64 {
+	var value int64
+	for i, b := range data {
+		if i == 0 && b&0x80 != 0 {
+			value = -1
+		}
+		value = value<<8 | int64(b)
+	}
+	return value
+}
+
+// decodeUnsigned decodes Counter32/Gauge32/Counter64 values
+func decodeUnsigned(data []byte) uint64 {
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+// oidHasPrefix reports whether oid starts with prefix
+func oidHasPrefix(oid, prefix []int) bool {
+	if len(oid) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if oid[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// formatOID renders an OID in dotted notation (for debug output)
+func formatOID(oid []int) string {
+	parts := make([]string, len(oid))
+	for i, arc := range oid {
+		parts[i] = strconv.Itoa(arc)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Persisted Monitor State (counters and accumulators across restarts)
+// ============================================================================
+
+// monitorState is the content of the state file
+type monitorState struct {
+	SavedAt    time.Time            `json:"saved_at"`
+	Rates      map[string]rateState `json:"rates"`
+	Summary    *summaryState        `json:"summary,omitempty"`
+	Percentile *percentileState     `json:"percentile,omitempty"`
+}
+
+// rateState is the baseline and stats window of one interface
+type rateState struct {
+	RxBytes   uint64    `json:"rx_bytes"`
+	TxBytes   uint64    `json:"tx_bytes"`
+	RxHistory []float64 `json:"rx_history,omitempty"` // Stats window, oldest first
+	TxHistory []float64 `json:"tx_history,omitempty"`
+}
+
+// StateStore reads and writes the state file
+type StateStore struct {
+	config *StateConfig
+	mu     sync.Mutex // Serializes writes (periodic saves run in the background)
+}
+
+// NewStateStore creates a state store for STATE_FILE
+func NewStateStore(config *StateConfig) *StateStore {
+	logInfo("State", "Persisting counters to %s every %v", config.File, config.Interval)
+	return &StateStore{config: config}
+}
+
+// Load reads the saved state (nil if there is none, or it is unreadable or older than STATE_MAX_AGE)
+func (s *StateStore) Load(now time.Time) *monitorState {
+	data, err := os.ReadFile(s.config.File)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logWarn("State", "Failed to read %s: %v", s.config.File, err)
+		}
+		return nil
+	}
+
+	var state monitorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		logWarn("State", "Ignoring unreadable %s: %v", s.config.File, err)
+		return nil
+	}
+	if age := now.Sub(state.SavedAt); age > s.config.MaxAge {
+		logInfo("State", "Ignoring state saved %v ago (STATE_MAX_AGE %v)", age.Round(time.Second), s.config.MaxAge)
+		return nil
+	}
+	return &state
+}
+
+// Save writes the state through a temporary file, so a crash never leaves a truncated file
+func (s *StateStore) Save(state *monitorState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.config.File), 0755); err != nil {
+		return err
+	}
+	tmp := s.config.File + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.config.File)
+}
+
+// snapshotState captures the rate baselines and accumulators (monitoring loop goroutine)
+func (m *Monitor) snapshotState(now time.Time) *monitorState {
+	state := &monitorState{SavedAt: now, Rates: make(map[string]rateState, len(m.rateMap))}
+	for name, rate := range m.rateMap {
+		state.Rates[name] = rateState{
+			RxBytes:   rate.LastRxByte,
+			TxBytes:   rate.LastTxByte,
+			RxHistory: rate.chronological(rate.RxHistory),
+			TxHistory: rate.chronological(rate.TxHistory),
+		}
+	}
+	if m.summary != nil {
+		state.Summary = m.summary.snapshot()
+	}
+	if m.percentile != nil {
+		state.Percentile = m.percentile.snapshot()
+	}
+	return state
+}
+
+// saveState writes a snapshot in the background (monitoring loop goroutine)
+// Nothing is saved until the loaded state was resumed, so an unreachable router
+// at startup does not overwrite it with an empty one.
+func (m *Monitor) saveState(now time.Time, wait bool) {
+	if m.state == nil || m.resume != nil {
+		return
+	}
+	state := m.snapshotState(now)
+	save := func() {
+		if err := m.state.Save(state); err != nil {
+			logError("State", "Failed to save state: %v", err)
+		}
+	}
+	if wait {
+		save()
+	} else {
+		go save()
+	}
+}
+
+// resumeState continues from the loaded state with the first sample after startup
+// Rates start from the current counters (the downtime is not a sample), the stats
+// windows and accumulators continue. Counters lower than the saved ones mean the
+// router rebooted (or they were reset), so volume accounting counts them from zero.
+func (m *Monitor) resumeState(stats []InterfaceStats, now time.Time) {
+	state := m.resume
+	m.resume = nil
+
+	rebooted := make(map[string]bool)
+	resumed := 0
+	for _, stat := range stats {
+		saved, ok := state.Rates[stat.Name]
+		if !ok {
+			continue
+		}
+		if stat.RxByte < saved.RxBytes || stat.TxByte < saved.TxBytes {
+			rebooted[stat.Name] = true
+		}
+
+		n := m.statsWindowSize
+		count := min(len(saved.RxHistory), len(saved.TxHistory), n)
+		m.rateMap[stat.Name] = &InterfaceRate{
+			Name:         stat.Name,
+			LastRxByte:   stat.RxByte,
+			LastTxByte:   stat.TxByte,
+			LastTime:     now,
+			RxHistory:    resizeHistory(saved.RxHistory[len(saved.RxHistory)-count:], n),
+			TxHistory:    resizeHistory(saved.TxHistory[len(saved.TxHistory)-count:], n),
+			HistoryIndex: count % n,
+			HistoryCount: count,
+		}
+		resumed++
+	}
+
+	downtime := now.Sub(state.SavedAt).Round(time.Second)
+	logInfo("State", "Resumed %d interfaces from state saved %v ago", resumed, downtime)
+	if len(rebooted) > 0 {
+		names := make([]string, 0, len(rebooted))
+		for name := range rebooted {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		logWarn("State", "Counters went backwards on %s: router rebooted or counters reset, traffic before the reboot is lost",
+			strings.Join(names, ", "))
+	}
+
+	if m.summary != nil && state.Summary != nil {
+		if !m.summary.restore(state.Summary, rebooted, now) {
+			logInfo("State", "Daily summary period ended while stopped, starting a new one")
+		}
+	}
+	if m.percentile != nil && state.Percentile != nil {
+		if !m.percentile.restore(state.Percentile) {
+			logInfo("State", "PERCENTILE_SAMPLE_INTERVAL changed, percentile samples not resumed")
+		}
+	}
+}
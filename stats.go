@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"strconv"
 	"time"
+
+	"github.com/firadio/golang-mikrotik-interface-stats/pkg/routeros"
 )
 
 // InterfaceStats represents raw interface traffic counters from Mikrotik
@@ -12,6 +14,8 @@ type InterfaceStats struct {
 	Name   string // Interface name (e.g., vlan2622, ether1)
 	RxByte uint64 // Total received bytes
 	TxByte uint64 // Total transmitted bytes
+
+	State *InterfaceState // Link state (nil if the transport doesn't report it, e.g. SNMP, groups)
 }
 
 // InterfaceRate maintains rate calculation state for an interface
@@ -29,45 +33,50 @@ type InterfaceRate struct {
 	HistoryCount int       // Number of valid entries (0 to window size)
 }
 
+// chronological returns the valid entries of a history ring buffer, oldest first
+func (r *InterfaceRate) chronological(history []float64) []float64 {
+	result := make([]float64, 0, r.HistoryCount)
+	start := r.HistoryIndex - r.HistoryCount
+	if start < 0 {
+		start += len(history)
+	}
+	for i := 0; i < r.HistoryCount; i++ {
+		result = append(result, history[(start+i)%len(history)])
+	}
+	return result
+}
+
 // GetInterfaceStats queries the Mikrotik router for interface statistics
 // Returns raw byte counters for specified interfaces
-func (c *MikrotikClient) GetInterfaceStats(interfaces []string, debug bool) ([]InterfaceStats, error) {
+func (c *MikrotikClient) GetInterfaceStats(ctx context.Context, interfaces []string) ([]InterfaceStats, error) {
+	return queryInterfaceStats(ctx, c, interfaces)
+}
+
+// queryInterfaceStats builds the interface stats command and parses the response
+// Shared by all RouterClient transports (binary API and REST)
+func queryInterfaceStats(ctx context.Context, client RouterClient, interfaces []string) ([]InterfaceStats, error) {
 	// Build Mikrotik API command with server-side filtering
 	// This reduces network traffic by filtering on the router
 	//
 	// Command structure:
 	//   /interface/print       - Query interface data
-	//   =stats                 - Get real-time statistics (live counters)
-	//   =.proplist=...         - Only return specified properties
+	//   =.proplist=...         - Only return specified properties (counters and link state)
+	//   =stats=                - Get real-time statistics (live counters)
 	//   ?name=iface1           - Filter by interface name
 	//   ?name=iface2 ?#|       - OR operator (placed after each condition from 2nd onwards)
-	cmd := []string{
-		"/interface/print",
-		"=stats",
-		"=.proplist=name,rx-byte,tx-byte",
-	}
-
-	// Add interface filters with OR operators
-	// Pattern: ?name=iface1 ?name=iface2 ?#| ?name=iface3 ?#|
-	for i, iface := range interfaces {
-		cmd = append(cmd, "?name="+iface)
-		if i >= 1 {
-			cmd = append(cmd, "?#|") // OR operator after each interface from 2nd onwards
-		}
+	cmd, err := routeros.QueryCommand("/interface",
+		[]string{"name", "rx-byte", "tx-byte", "running", "disabled", "link-downs"},
+		routeros.Stats, routeros.OneOf("name", interfaces...))
+	if err != nil {
+		return nil, err
 	}
 
-	if debug {
-		log.Printf("DEBUG: Mikrotik API command: %v", cmd)
-	}
+	logDebug("Client", "Mikrotik API command: %v", cmd)
 
 	// Send command and read response
-	if err := c.sendCommand(cmd...); err != nil {
-		return nil, fmt.Errorf("sendCommand failed: %w", err)
-	}
-
-	responses, err := c.readResponse()
+	responses, err := client.Run(ctx, cmd...)
 	if err != nil {
-		return nil, fmt.Errorf("readResponse failed: %w", err)
+		return nil, err
 	}
 
 	// Parse responses into InterfaceStats
@@ -88,10 +97,18 @@ func (c *MikrotikClient) GetInterfaceStats(interfaces []string, debug bool) ([]I
 			return nil, fmt.Errorf("failed to parse tx-byte for %s: %w", name, err)
 		}
 
+		// link-downs is missing on some interface types and older RouterOS versions
+		linkDowns, _ := strconv.ParseUint(resp["link-downs"], 10, 64)
+
 		stats = append(stats, InterfaceStats{
 			Name:   name,
 			RxByte: rxByte,
 			TxByte: txByte,
+			State: &InterfaceState{
+				Running:   resp["running"] == "true",
+				Disabled:  resp["disabled"] == "true",
+				LinkDowns: linkDowns,
+			},
 		})
 	}
 
@@ -151,3 +168,99 @@ func FormatRate(bytesPerSec float64, rateUnit string, rateScale string) string {
 		return fmt.Sprintf("%.2f %s", value, unit)
 	}
 }
+
+// appendGroupStats adds a virtual InterfaceStats entry per group by summing member counters
+// Groups with missing members are skipped so partial sums never produce false rate spikes
+func appendGroupStats(stats []InterfaceStats, groups []InterfaceGroup) []InterfaceStats {
+	if len(groups) == 0 {
+		return stats
+	}
+
+	byName := make(map[string]InterfaceStats, len(stats))
+	for _, stat := range stats {
+		byName[stat.Name] = stat
+	}
+
+	for _, group := range groups {
+		sum := InterfaceStats{Name: group.Name}
+		complete := true
+		for _, member := range group.Members {
+			stat, ok := byName[member]
+			if !ok {
+				complete = false
+				break
+			}
+			sum.RxByte += stat.RxByte
+			sum.TxByte += stat.TxByte
+		}
+		if complete {
+			stats = append(stats, sum)
+		}
+	}
+
+	return stats
+}
+
+// InterfaceInfo holds static interface metadata from /interface/print
+type InterfaceInfo struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`  // ether, vlan, pppoe-out, ...
+	MTU        int    `json:"mtu"`   // Actual MTU (0 if unknown)
+	Speed      string `json:"speed"` // Ethernet link speed (e.g. "1Gbps", empty if unknown)
+	Running    bool   `json:"running"`
+	Disabled   bool   `json:"disabled"`
+	MACAddress string `json:"mac_address"`
+	Comment    string `json:"comment"`
+}
+
+// queryInterfaceInfo fetches metadata for the given interfaces
+// Link speed is looked up from /interface/ethernet on a best-effort basis
+func queryInterfaceInfo(ctx context.Context, client RouterClient, interfaces []string) ([]InterfaceInfo, error) {
+	cmd, err := routeros.QueryCommand("/interface",
+		[]string{"name", "type", "mtu", "actual-mtu", "running", "disabled", "mac-address", "comment"},
+		routeros.OneOf("name", interfaces...))
+	if err != nil {
+		return nil, err
+	}
+
+	// Ethernet speed is not part of /interface/print; both queries are pipelined
+	results, errs := RunAll(ctx, client, cmd, []string{"/interface/ethernet/print", "=.proplist=name,speed"})
+	responses, err := results[0], errs[0]
+	if err != nil {
+		return nil, err
+	}
+
+	speeds := make(map[string]string)
+	if rows, err := results[1], errs[1]; err == nil {
+		for _, row := range rows {
+			speeds[row["name"]] = row["speed"]
+		}
+	}
+
+	infos := make([]InterfaceInfo, 0, len(responses))
+	for _, resp := range responses {
+		name := resp["name"]
+		if name == "" {
+			continue
+		}
+
+		// actual-mtu is authoritative; mtu may be "auto" on some interface types
+		mtu, err := strconv.Atoi(resp["actual-mtu"])
+		if err != nil {
+			mtu, _ = strconv.Atoi(resp["mtu"])
+		}
+
+		infos = append(infos, InterfaceInfo{
+			Name:       name,
+			Type:       resp["type"],
+			MTU:        mtu,
+			Speed:      speeds[name],
+			Running:    resp["running"] == "true",
+			Disabled:   resp["disabled"] == "true",
+			MACAddress: resp["mac-address"],
+			Comment:    resp["comment"],
+		})
+	}
+
+	return infos, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Monitor Status (liveness/readiness)
+// ============================================================================
+
+const (
+	livenessMaxAttemptAge = 30 * time.Second // Sampling loop considered hung after this
+	readinessMaxSampleAge = 10 * time.Second // Router considered disconnected after this
+)
+
+// MonitorStatus tracks the outcome of each sampling attempt for health probes
+type MonitorStatus struct {
+	started     time.Time
+	lastAttempt time.Time // Last poll, successful or not
+	lastSuccess time.Time // Last poll that returned interface counters
+	lastError   string    // Error of the last failed poll (cleared on success)
+	failures    int       // Consecutive failed polls
+	mu          sync.RWMutex
+}
+
+// RouterStatus is the RouterOS connection state reported by /healthz and /readyz
+type RouterStatus struct {
+	Connected           bool       `json:"connected"`
+	LastSample          *time.Time `json:"last_sample,omitempty"`
+	LastSampleAgeSecs   *float64   `json:"last_sample_age_seconds,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+}
+
+// NewMonitorStatus creates a status tracker
+func NewMonitorStatus() *MonitorStatus {
+	return &MonitorStatus{started: time.Now()}
+}
+
+// RecordSuccess marks a successful poll
+func (s *MonitorStatus) RecordSuccess(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastAttempt = now
+	s.lastSuccess = now
+	s.lastError = ""
+	s.failures = 0
+}
+
+// RecordError marks a failed poll
+func (s *MonitorStatus) RecordError(now time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastAttempt = now
+	s.lastError = err.Error()
+	s.failures++
+}
+
+// Alive reports whether the sampling loop is still running
+// Before the first poll completes, the loop is given livenessMaxAttemptAge from startup
+func (s *MonitorStatus) Alive(now time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	last := s.lastAttempt
+	if last.IsZero() {
+		last = s.started
+	}
+	return now.Sub(last) <= livenessMaxAttemptAge
+}
+
+// Router returns the RouterOS connection state
+func (s *MonitorStatus) Router(now time.Time) RouterStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := RouterStatus{
+		LastError:           s.lastError,
+		ConsecutiveFailures: s.failures,
+	}
+	if !s.lastSuccess.IsZero() {
+		last := s.lastSuccess
+		age := now.Sub(last).Seconds()
+		status.LastSample = &last
+		status.LastSampleAgeSecs = &age
+		status.Connected = now.Sub(last) <= readinessMaxSampleAge
+	}
+	return status
+}
+
+// Uptime returns the time since the monitor was created
+func (s *MonitorStatus) Uptime(now time.Time) time.Duration {
+	return now.Sub(s.started)
+}
+
+// ============================================================================
+// Probe Server (PROBE_LISTEN_ADDR)
+// ============================================================================
+
+// ProbeServer answers /healthz and /readyz on their own port
+// Used by orchestrators when the web UI is disabled, or to keep probes off the public port.
+type ProbeServer struct {
+	server *http.Server
+}
+
+// NewProbeServer creates the probe server (vm is nil if VictoriaMetrics is disabled)
+func NewProbeServer(addr string, status *MonitorStatus, vm *VMClient) *ProbeServer {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(rw http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		writeHealth(rw, newHealthResponse(now, status, vm, 0), status.Alive(now))
+	})
+	mux.HandleFunc("/readyz", func(rw http.ResponseWriter, r *http.Request) {
+		resp := newHealthResponse(time.Now(), status, vm, 0)
+		writeHealth(rw, resp, resp.Router.Connected)
+	})
+
+	return &ProbeServer{server: &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}}
+}
+
+// Start starts the probe server (non-blocking)
+func (p *ProbeServer) Start() {
+	logInfo("Probes", "Serving /healthz and /readyz on %s", p.server.Addr)
+
+	go func() {
+		if err := p.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logError("Probes", "Server error: %v", err)
+		}
+	}()
+}
+
+// Stop shuts the probe server down, letting in-flight probes finish
+func (p *ProbeServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	p.server.Shutdown(ctx)
+}
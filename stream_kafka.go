@@ -0,0 +1,524 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// ============================================================================
+// Kafka Producer (STREAM_TRANSPORT=kafka)
+// ============================================================================
+//
+// A minimal producer speaking the Kafka wire protocol directly:
+//
+//   Metadata v4 (ApiKey 3)  topic partitions and their leaders (auto-creates topics)
+//   Produce v3  (ApiKey 0)  one RecordBatch (magic 2, uncompressed) per partition
+//
+// Both versions are supported by Kafka 0.11 through 4.x. Records are keyed by
+// interface and partitioned like the Java client's default partitioner
+// (murmur2(key) % partitions), so the samples of an interface stay in order.
+// Plaintext or TLS only: SASL authentication is not implemented.
+
+const (
+	kafkaAPIProduce  = 0
+	kafkaAPIMetadata = 3
+
+	kafkaErrLeaderNotAvailable = 5
+)
+
+// kafkaProducer publishes records to the partition leaders
+// Not safe for concurrent use (only the stream worker calls it).
+type kafkaProducer struct {
+	brokers  []string // Bootstrap addresses (host:port)
+	tls      *tls.Config
+	clientID string
+	acks     int16 // 1 = leader, -1 = all in-sync replicas
+	timeout  time.Duration
+
+	correlation int32
+	conns       map[int32]*kafkaConn // Broker ID -> connection
+	addrs       map[int32]string     // Broker ID -> host:port
+	partitions  map[string][]int32   // Topic -> leader broker of each partition
+}
+
+// kafkaConn is one broker connection
+type kafkaConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newKafkaProducer(config *StreamConfig) *kafkaProducer {
+	p := &kafkaProducer{
+		brokers:  config.Brokers,
+		clientID: config.ClientID,
+		acks:     int16(config.KafkaAcks),
+		timeout:  config.Timeout,
+		conns:    make(map[int32]*kafkaConn),
+	}
+	if config.TLS {
+		p.tls = &tls.Config{}
+	}
+	return p
+}
+
+// Publish sends messages grouped by topic and partition
+// On error every connection is closed and metadata is fetched again on the next call.
+func (p *kafkaProducer) Publish(messages []streamMessage) error {
+	if err := p.publish(messages); err != nil {
+		p.Close()
+		return err
+	}
+	return nil
+}
+
+func (p *kafkaProducer) publish(messages []streamMessage) error {
+	// Refresh metadata for topics not seen yet (or after an error)
+	var missing []string
+	seen := make(map[string]bool)
+	for _, m := range messages {
+		if _, ok := p.partitions[m.Topic]; !ok && !seen[m.Topic] {
+			seen[m.Topic] = true
+			missing = append(missing, m.Topic)
+		}
+	}
+	if len(missing) > 0 {
+		if err := p.refreshMetadata(missing); err != nil {
+			return err
+		}
+	}
+
+	// Leader -> topic -> partition -> messages
+	batches := make(map[int32]map[string]map[int32][]streamMessage)
+	for _, m := range messages {
+		leaders := p.partitions[m.Topic]
+		partition := int32(murmur2([]byte(m.Key))&0x7fffffff) % int32(len(leaders))
+		leader := leaders[partition]
+		if batches[leader] == nil {
+			batches[leader] = make(map[string]map[int32][]streamMessage)
+		}
+		if batches[leader][m.Topic] == nil {
+			batches[leader][m.Topic] = make(map[int32][]streamMessage)
+		}
+		batches[leader][m.Topic][partition] = append(batches[leader][m.Topic][partition], m)
+	}
+
+	for leader, topics := range batches {
+		if err := p.produce(leader, topics); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes all broker connections and forgets the metadata
+func (p *kafkaProducer) Close() error {
+	for id, c := range p.conns {
+		c.conn.Close()
+		delete(p.conns, id)
+	}
+	p.partitions = nil
+	return nil
+}
+
+// refreshMetadata loads the partition leaders of topics from the first reachable bootstrap broker
+func (p *kafkaProducer) refreshMetadata(topics []string) error {
+	var body []byte
+	body = binary.BigEndian.AppendUint32(body, uint32(len(topics)))
+	for _, topic := range topics {
+		body = appendKafkaString(body, topic)
+	}
+	body = append(body, 1) // allow_auto_topic_creation
+
+	var lastErr error
+	for _, addr := range p.brokers {
+		conn, err := p.dial(addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp, err := p.roundTrip(conn, kafkaAPIMetadata, 4, body)
+		conn.conn.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", addr, err)
+			continue
+		}
+		return p.parseMetadata(resp, topics)
+	}
+	return fmt.Errorf("metadata: %w", lastErr)
+}
+
+// parseMetadata reads a Metadata v4 response
+func (p *kafkaProducer) parseMetadata(resp []byte, topics []string) error {
+	r := &kafkaReader{buf: resp}
+	r.int32() // throttle_time_ms
+
+	addrs := make(map[int32]string)
+	for n := r.int32(); n > 0; n-- {
+		id := r.int32()
+		host := r.string()
+		port := r.int32()
+		r.string() // rack
+		addrs[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	r.string() // cluster_id
+	r.int32()  // controller_id
+
+	partitions := make(map[string][]int32)
+	for n := r.int32(); n > 0; n-- {
+		code := r.int16()
+		name := r.string()
+		r.int8() // is_internal
+		var leaders []int32
+		for m := r.int32(); m > 0; m-- {
+			r.int16() // partition error_code (e.g. replica not available: the leader still accepts writes)
+			index := r.int32()
+			leader := r.int32()
+			r.int32Array() // replica_nodes
+			r.int32Array() // isr_nodes
+			for int(index) >= len(leaders) {
+				leaders = append(leaders, -1)
+			}
+			leaders[index] = leader
+		}
+		if r.err != nil {
+			break
+		}
+		if code == kafkaErrLeaderNotAvailable {
+			return fmt.Errorf("topic %s: leader not available yet (topic being created)", name)
+		}
+		if code != 0 {
+			return fmt.Errorf("topic %s: %w", name, kafkaError(code))
+		}
+		for i, leader := range leaders {
+			if leader < 0 {
+				return fmt.Errorf("topic %s: partition %d has no leader", name, i)
+			}
+		}
+		if len(leaders) == 0 {
+			return fmt.Errorf("topic %s has no partitions", name)
+		}
+		partitions[name] = leaders
+	}
+	if r.err != nil {
+		return fmt.Errorf("malformed metadata response: %w", r.err)
+	}
+
+	if p.partitions == nil {
+		p.partitions = make(map[string][]int32)
+	}
+	for _, topic := range topics {
+		leaders, ok := partitions[topic]
+		if !ok {
+			return fmt.Errorf("topic %s missing from metadata", topic)
+		}
+		p.partitions[topic] = leaders
+	}
+	p.addrs = addrs
+	return nil
+}
+
+// produce sends one Produce request to a partition leader
+func (p *kafkaProducer) produce(leader int32, topics map[string]map[int32][]streamMessage) error {
+	conn, err := p.leader(leader)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	body = appendKafkaNullableString(body, "") // transactional_id
+	body = binary.BigEndian.AppendUint16(body, uint16(p.acks))
+	body = binary.BigEndian.AppendUint32(body, uint32(p.timeout/time.Millisecond))
+	body = binary.BigEndian.AppendUint32(body, uint32(len(topics)))
+	for topic, partitions := range topics {
+		body = appendKafkaString(body, topic)
+		body = binary.BigEndian.AppendUint32(body, uint32(len(partitions)))
+		for partition, messages := range partitions {
+			batch := encodeRecordBatch(messages)
+			body = binary.BigEndian.AppendUint32(body, uint32(partition))
+			body = binary.BigEndian.AppendUint32(body, uint32(len(batch)))
+			body = append(body, batch...)
+		}
+	}
+
+	resp, err := p.roundTrip(conn, kafkaAPIProduce, 3, body)
+	if err != nil {
+		return fmt.Errorf("produce to broker %d: %w", leader, err)
+	}
+
+	// Produce v3 response: [topic [partition error_code base_offset log_append_time]] throttle_time_ms
+	r := &kafkaReader{buf: resp}
+	for n := r.int32(); n > 0; n-- {
+		topic := r.string()
+		for m := r.int32(); m > 0; m-- {
+			partition := r.int32()
+			code := r.int16()
+			r.int64() // base_offset
+			r.int64() // log_append_time
+			if r.err == nil && code != 0 {
+				return fmt.Errorf("produce %s/%d: %w", topic, partition, kafkaError(code))
+			}
+		}
+	}
+	if r.err != nil {
+		return fmt.Errorf("malformed produce response: %w", r.err)
+	}
+	return nil
+}
+
+// leader returns the connection to a broker, dialing it if needed
+func (p *kafkaProducer) leader(id int32) (*kafkaConn, error) {
+	if conn, ok := p.conns[id]; ok {
+		return conn, nil
+	}
+	addr, ok := p.addrs[id]
+	if !ok {
+		return nil, fmt.Errorf("broker %d missing from metadata", id)
+	}
+	conn, err := p.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[id] = conn
+	return conn, nil
+}
+
+func (p *kafkaProducer) dial(addr string) (*kafkaConn, error) {
+	dialer := &net.Dialer{Timeout: p.timeout}
+	var conn net.Conn
+	var err error
+	if p.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, p.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaConn{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// roundTrip sends a request (header v1) and returns the response body after the correlation ID
+func (p *kafkaProducer) roundTrip(c *kafkaConn, apiKey, apiVersion int16, body []byte) ([]byte, error) {
+	p.correlation++
+	correlation := p.correlation
+
+	header := make([]byte, 4, 4+10+len(p.clientID))
+	header = binary.BigEndian.AppendUint16(header, uint16(apiKey))
+	header = binary.BigEndian.AppendUint16(header, uint16(apiVersion))
+	header = binary.BigEndian.AppendUint32(header, uint32(correlation))
+	header = appendKafkaString(header, p.clientID)
+	binary.BigEndian.PutUint32(header, uint32(len(header)-4+len(body)))
+
+	c.conn.SetDeadline(time.Now().Add(p.timeout))
+	defer c.conn.SetDeadline(time.Time{})
+	if _, err := c.conn.Write(append(header, body...)); err != nil {
+		return nil, err
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(c.reader, size[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(size[:])
+	if length < 4 || length > 64<<20 {
+		return nil, fmt.Errorf("invalid response size %d", length)
+	}
+	resp := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, resp); err != nil {
+		return nil, err
+	}
+	if got := int32(binary.BigEndian.Uint32(resp)); got != correlation {
+		return nil, fmt.Errorf("correlation ID mismatch (got %d, want %d)", got, correlation)
+	}
+	return resp[4:], nil
+}
+
+// encodeRecordBatch encodes messages as an uncompressed RecordBatch (magic 2)
+func encodeRecordBatch(messages []streamMessage) []byte {
+	first := messages[0].Time.UnixMilli()
+	last := first
+	var records []byte
+	for i, m := range messages {
+		ts := m.Time.UnixMilli()
+		last = max(last, ts)
+
+		var record []byte
+		record = append(record, 0) // attributes
+		record = binary.AppendVarint(record, ts-first)
+		record = binary.AppendVarint(record, int64(i)) // offset delta
+		record = binary.AppendVarint(record, int64(len(m.Key)))
+		record = append(record, m.Key...)
+		record = binary.AppendVarint(record, int64(len(m.Value)))
+		record = append(record, m.Value...)
+		record = binary.AppendVarint(record, 0) // headers
+
+		records = binary.AppendVarint(records, int64(len(record)))
+		records = append(records, record...)
+	}
+
+	// The CRC covers everything from attributes to the end
+	var tail []byte
+	tail = binary.BigEndian.AppendUint16(tail, 0) // attributes: no compression, create time
+	tail = binary.BigEndian.AppendUint32(tail, uint32(len(messages)-1))
+	tail = binary.BigEndian.AppendUint64(tail, uint64(first))
+	tail = binary.BigEndian.AppendUint64(tail, uint64(last))
+	tail = binary.BigEndian.AppendUint64(tail, ^uint64(0)) // producer_id -1
+	tail = binary.BigEndian.AppendUint16(tail, ^uint16(0)) // producer_epoch -1
+	tail = binary.BigEndian.AppendUint32(tail, ^uint32(0)) // base_sequence -1
+	tail = binary.BigEndian.AppendUint32(tail, uint32(len(messages)))
+	tail = append(tail, records...)
+
+	var batch []byte
+	batch = binary.BigEndian.AppendUint64(batch, 0)                       // base_offset
+	batch = binary.BigEndian.AppendUint32(batch, uint32(4+1+4+len(tail))) // batch_length (after this field)
+	batch = binary.BigEndian.AppendUint32(batch, ^uint32(0))              // partition_leader_epoch -1
+	batch = append(batch, 2)                                              // magic
+	batch = binary.BigEndian.AppendUint32(batch, crc32.Checksum(tail, crc32.MakeTable(crc32.Castagnoli)))
+	return append(batch, tail...)
+}
+
+// murmur2 is the hash of the Java client's default partitioner
+func murmur2(data []byte) uint32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+		r    = 24
+	)
+	length := len(data)
+	h := uint32(seed) ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
+
+func appendKafkaString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// appendKafkaNullableString encodes "" as null
+func appendKafkaNullableString(b []byte, s string) []byte {
+	if s == "" {
+		return binary.BigEndian.AppendUint16(b, ^uint16(0))
+	}
+	return appendKafkaString(b, s)
+}
+
+// kafkaReader decodes big-endian response fields, remembering the first error
+type kafkaReader struct {
+	buf []byte
+	err error
+}
+
+func (r *kafkaReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.buf) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *kafkaReader) int8() int8 {
+	if b := r.take(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (r *kafkaReader) int16() int16 {
+	if b := r.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int32() int32 {
+	if b := r.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int64() int64 {
+	if b := r.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string reads a (nullable) string; null is returned as ""
+func (r *kafkaReader) string() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.take(int(n)))
+}
+
+func (r *kafkaReader) int32Array() {
+	if n := r.int32(); n > 0 {
+		r.take(4 * int(n))
+	}
+}
+
+// kafkaErrors names the error codes a producer is likely to see
+var kafkaErrors = map[int16]string{
+	1:  "OFFSET_OUT_OF_RANGE",
+	2:  "CORRUPT_MESSAGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	17: "INVALID_TOPIC_EXCEPTION",
+	19: "NOT_ENOUGH_REPLICAS",
+	20: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	31: "CLUSTER_AUTHORIZATION_FAILED",
+	35: "UNSUPPORTED_VERSION",
+	87: "INVALID_RECORD",
+}
+
+// kafkaError converts an error code to an error
+func kafkaError(code int16) error {
+	if name, ok := kafkaErrors[code]; ok {
+		return errors.New(name)
+	}
+	return fmt.Errorf("kafka error code %d", code)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Fixtures were built independently from the Kafka protocol documentation
+// (RecordBatch v2 with CRC-32C over attributes..records, zigzag varints).
+
+// testRecordBatch is encodeRecordBatch of testRecordMessages
+var testRecordBatch = "0000000000000000" + // base_offset
+	"0000009b" + // batch_length
+	"ffffffff" + // partition_leader_epoch
+	"02" + // magic
+	"a81ea60e" + // crc (CRC-32C)
+	"0000" + // attributes
+	"00000001" + // last_offset_delta
+	"0000018bcfe5687b" + // base_timestamp
+	"0000018bcfe5687b" + // max_timestamp
+	"ffffffffffffffff" + "ffff" + "ffffffff" + // producer_id, producer_epoch, base_sequence
+	"00000002" + // records
+	"28" + "00" + "00" + "00" + "0c" + "657468657231" + "10" + "7b227278223a317d" + "00" + // length 20, attributes, ts delta 0, offset delta 0, key, value, headers
+	"a601" + "00" + "2d" + "02" + "0c" + "657468657232" + "8c01" + strings.Repeat("78", 70) + "00" // length 83, ts delta -23, offset delta 1
+
+func testRecordMessages() []streamMessage {
+	return []streamMessage{
+		{Topic: "samples", Key: "ether1", Value: []byte(`{"rx":1}`), Time: time.UnixMilli(1700000000123)},
+		{Topic: "samples", Key: "ether2", Value: bytes.Repeat([]byte{'x'}, 70), Time: time.UnixMilli(1700000000100)},
+	}
+}
+
+func TestEncodeRecordBatch(t *testing.T) {
+	got := hex.EncodeToString(encodeRecordBatch(testRecordMessages()))
+	if got != testRecordBatch {
+		t.Errorf("encodeRecordBatch:\n got %s\nwant %s", got, testRecordBatch)
+	}
+}
+
+func TestMurmur2(t *testing.T) {
+	// Values of the Java client's Utils.murmur2
+	tests := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for input, want := range tests {
+		if got := int32(murmur2([]byte(input))); got != want {
+			t.Errorf("murmur2(%q) = %d, want %d", input, got, want)
+		}
+	}
+}
+
+// fakeKafkaBroker answers requests with handle(apiKey, apiVersion, request body after the client ID)
+// Every raw request frame is recorded, including its size and header.
+type fakeKafkaBroker struct {
+	listener net.Listener
+	handle   func(apiKey, apiVersion int16, body []byte) []byte
+	requests chan []byte
+}
+
+func newFakeKafkaBroker(t *testing.T, handle func(apiKey, apiVersion int16, body []byte) []byte) *fakeKafkaBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeKafkaBroker{listener: listener, handle: handle, requests: make(chan []byte, 16)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *fakeKafkaBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		frame := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, frame); err != nil {
+			return
+		}
+		b.requests <- append(size[:], frame...)
+
+		apiKey := int16(binary.BigEndian.Uint16(frame))
+		apiVersion := int16(binary.BigEndian.Uint16(frame[2:]))
+		clientID := int(binary.BigEndian.Uint16(frame[8:]))
+		body := b.handle(apiKey, apiVersion, frame[10+clientID:])
+
+		resp := binary.BigEndian.AppendUint32(nil, uint32(4+len(body)))
+		resp = append(resp, frame[4:8]...) // correlation_id
+		conn.Write(append(resp, body...))
+	}
+}
+
+func (b *fakeKafkaBroker) port() int {
+	return b.listener.Addr().(*net.TCPAddr).Port
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestKafkaProducerPublish(t *testing.T) {
+	var broker *fakeKafkaBroker
+	var produceError int16
+	broker = newFakeKafkaBroker(t, func(apiKey, apiVersion int16, body []byte) []byte {
+		port := binary.BigEndian.AppendUint32(nil, uint32(broker.port()))
+		switch apiKey {
+		case kafkaAPIMetadata:
+			// Metadata v4: throttle, brokers [id 7, 127.0.0.1, port, rack null], cluster_id null, controller 7,
+			// topics [error 0, "samples", not internal, partitions [(error 0, 0, leader 7, [7], [7]), (error 9, 1, leader 7, [7], [])]]
+			return mustHex(t, "00000000 00000001 00000007 0009 3132372e302e302e31 "+hex.EncodeToString(port)+" ffff ffff 00000007 "+
+				"00000001 0000 0007 73616d706c6573 00 00000002 "+
+				"0000 00000000 00000007 00000001 00000007 00000001 00000007 "+
+				"0009 00000001 00000007 00000001 00000007 00000000")
+		case kafkaAPIProduce:
+			// Produce v3: [topic "samples" [partition of ether1, error, base_offset 42, log_append_time -1]], throttle 0
+			partition := (murmur2([]byte("ether1")) & 0x7fffffff) % 2
+			return mustHex(t, "00000001 0007 73616d706c6573 00000001 "+
+				hex.EncodeToString(binary.BigEndian.AppendUint32(nil, partition))+
+				hex.EncodeToString(binary.BigEndian.AppendUint16(nil, uint16(produceError)))+
+				" 000000000000002a ffffffffffffffff 00000000")
+		}
+		t.Errorf("unexpected request: API key %d v%d", apiKey, apiVersion)
+		return nil
+	})
+
+	producer := newKafkaProducer(&StreamConfig{
+		Brokers:   []string{"127.0.0.1:" + strconv.Itoa(broker.port())},
+		ClientID:  "test",
+		KafkaAcks: -1,
+		Timeout:   5 * time.Second,
+	})
+	defer producer.Close()
+
+	message := testRecordMessages()[0]
+	if err := producer.Publish([]streamMessage{message}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Metadata v4 request: size, api_key 3, api_version 4, correlation 1, client_id, topics ["samples"], allow_auto_topic_creation
+	metadata := <-broker.requests
+	if want := mustHex(t, "0000001c 0003 0004 00000001 0004 74657374 00000001 0007 73616d706c6573 01"); !bytes.Equal(metadata, want) {
+		t.Errorf("metadata request:\n got %x\nwant %x", metadata, want)
+	}
+
+	// Produce v3 request: transactional_id null, acks -1, timeout 5000 ms, [topic [partition, batch size, batch]]
+	if got, want := producer.partitions["samples"], []int32{7, 7}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("partition leaders %v, want %v", got, want)
+	}
+	batch := encodeRecordBatch([]streamMessage{message})
+	partition := int32(murmur2([]byte("ether1"))&0x7fffffff) % 2
+	var want []byte
+	want = append(want, mustHex(t, "0000 0003 00000002 0004 74657374 ffff ffff 00001388 00000001 0007 73616d706c6573 00000001")...)
+	want = binary.BigEndian.AppendUint32(want, uint32(partition))
+	want = binary.BigEndian.AppendUint32(want, uint32(len(batch)))
+	want = append(want, batch...)
+	produce := <-broker.requests
+	if len(produce) < 4 || !bytes.Equal(produce[4:], want) || int(binary.BigEndian.Uint32(produce)) != len(want) {
+		t.Errorf("produce request:\n got %x\nwant %08x%x", produce, len(want), want)
+	}
+
+	// A partition error fails the batch and drops the metadata
+	produceError = 6
+	err := producer.Publish([]streamMessage{message})
+	if err == nil || !strings.Contains(err.Error(), "NOT_LEADER_OR_FOLLOWER") {
+		t.Errorf("Publish with a partition error = %v, want NOT_LEADER_OR_FOLLOWER", err)
+	}
+	if producer.partitions != nil {
+		t.Error("metadata kept after a failed publish")
+	}
+}
+
+func TestKafkaParseMetadataErrors(t *testing.T) {
+	tests := []struct {
+		name, resp, want string
+	}{
+		{"leader not available", "00000000 00000000 ffff 00000000 00000001 0005 0007 73616d706c6573 00 00000000", "leader not available"},
+		{"topic error", "00000000 00000000 ffff 00000000 00000001 001d 0007 73616d706c6573 00 00000000", "TOPIC_AUTHORIZATION_FAILED"},
+		{"no leader", "00000000 00000000 ffff 00000000 00000001 0000 0007 73616d706c6573 00 00000001 0000 00000000 ffffffff 00000000 00000000", "has no leader"},
+		{"missing topic", "00000000 00000000 ffff 00000000 00000000", "missing from metadata"},
+		{"truncated", "00000000 00000001 0000", "malformed"},
+	}
+	for _, tt := range tests {
+		p := newKafkaProducer(&StreamConfig{})
+		err := p.parseMetadata(mustHex(t, tt.resp), []string{"samples"})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: parseMetadata = %v, want error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestAppendAvroSample(t *testing.T) {
+	up := true
+	tests := []struct {
+		name   string
+		sample streamSample
+		want   string
+	}{
+		{
+			"all fields",
+			streamSample{Timestamp: time.UnixMilli(1700000000123), Router: "r1", Interface: "ether1", Label: "WAN",
+				RxRate: 1250.5, RxBytes: 300, TxBytes: 5, LinkSpeed: 1e9, Up: &up, Labels: map[string]string{"site": "hq", "dc": "a"}},
+			"f6a1abfef962" + // timestamp (zigzag varint)
+				"0472310c657468657231" + // router, interface
+				"020657414e" + // label: union branch 1, "WAN"
+				"00000000008a9340" + "0000000000000000" + // rx_rate, tx_rate (little-endian doubles)
+				"d8040a" + // rx_bytes 300, tx_bytes 5
+				"020000000065cdcd41" + // link_speed: branch 1, 1e9
+				"0201" + // up: branch 1, true
+				"04" + "0464630261" + "0873697465046871" + "00", // labels: block of 2 (dc=a, site=hq sorted), end block
+		},
+		{
+			"optional fields unset",
+			streamSample{Timestamp: time.UnixMilli(1700000000123), Router: "r1", Interface: "ether2", RxRate: 1, TxRate: 2},
+			"f6a1abfef962" + "0472310c657468657232" + "00" + "000000000000f03f" + "0000000000000040" + "0000" + "00" + "00" + "00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hex.EncodeToString(appendAvroSample(nil, &tt.sample)); got != tt.want {
+				t.Errorf("appendAvroSample:\n got %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvroFingerprint(t *testing.T) {
+	// CRC-64-AVRO of the primitive schema "null" (Avro specification test vector)
+	if got := avroFingerprint(`"null"`); got != 0x63dd24e7cc258f8a {
+		t.Errorf("avroFingerprint(\"null\") = %#x, want 0x63dd24e7cc258f8a", got)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Daily Traffic Summary (chat notifiers)
+// ============================================================================
+
+// TrafficSummary accumulates peak/average/total per interface and posts them to
+// the chat notifiers once a day at SUMMARY_TIME
+//
+// Totals are the sum of counter increases between samples, so they are exact
+// bytes as long as the monitor is running (a counter reset loses one sample).
+type TrafficSummary struct {
+	config    *NotifyConfig
+	location  *time.Location // TIMEZONE: zone of SUMMARY_TIME
+	notifiers *Notifiers
+	isUplink  func(string) bool
+	labels    func(string) string
+
+	start      time.Time                 // Start of the current period
+	interfaces map[string]*summaryTotals // Totals per interface
+	mu         sync.Mutex
+}
+
+// summaryTotals holds one interface's figures for the current period (user perspective)
+type summaryTotals struct {
+	uploadSum, downloadSum   float64 // Sum of rates (bytes/s), for the average
+	uploadPeak, downloadPeak float64 // bytes/s
+	uploadBytes              uint64
+	downloadBytes            uint64
+	samples                  int
+
+	lastRx, lastTx uint64 // Previous raw counters
+}
+
+// NewTrafficSummary creates a daily summary; isUplink and labels come from the user configuration
+// SUMMARY_TIME is a time of day in location (the TIMEZONE zone).
+func NewTrafficSummary(config *NotifyConfig, location *time.Location, notifiers *Notifiers, isUplink func(string) bool, labels func(string) string) *TrafficSummary {
+	targets := strings.Join(config.SummaryTargets, ", ")
+	if targets == "" {
+		targets = strings.Join(notifiers.Names(), ", ")
+	}
+	logInfo("Notify", "Daily summary at %s to %s", config.SummaryTime, targets)
+
+	return &TrafficSummary{
+		config:     config,
+		location:   location,
+		notifiers:  notifiers,
+		isUplink:   isUplink,
+		labels:     labels,
+		start:      time.Now().In(location),
+		interfaces: make(map[string]*summaryTotals),
+	}
+}
+
+// Add records one sample of every interface
+func (s *TrafficSummary) Add(now time.Time, stats map[string]*RateInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, info := range stats {
+		totals, ok := s.interfaces[name]
+		if !ok {
+			totals = &summaryTotals{lastRx: info.RxBytes, lastTx: info.TxBytes}
+			s.interfaces[name] = totals
+		}
+
+		rx, tx := counterDelta(totals.lastRx, info.RxBytes), counterDelta(totals.lastTx, info.TxBytes)
+		totals.lastRx, totals.lastTx = info.RxBytes, info.TxBytes
+
+		// Uplink: TX = upload; downlink: RX = upload
+		upload, download := info.RxRate, info.TxRate
+		uploadBytes, downloadBytes := rx, tx
+		if s.isUplink(name) {
+			upload, download = info.TxRate, info.RxRate
+			uploadBytes, downloadBytes = tx, rx
+		}
+
+		totals.uploadSum += upload
+		totals.downloadSum += download
+		totals.uploadPeak = max(totals.uploadPeak, upload)
+		totals.downloadPeak = max(totals.downloadPeak, download)
+		totals.uploadBytes += uploadBytes
+		totals.downloadBytes += downloadBytes
+		totals.samples++
+	}
+}
+
+// counterDelta returns the increase of a counter (0 after a reset)
+func counterDelta(previous, current uint64) uint64 {
+	if current < previous {
+		return 0
+	}
+	return current - previous
+}
+
+// Run posts the summary every day at SUMMARY_TIME until ctx is cancelled
+func (s *TrafficSummary) Run(ctx context.Context) {
+	for {
+		next := nextDailyTime(time.Now().In(s.location), s.config.SummaryHour, s.config.SummaryMinute)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			s.notifiers.Send(s.config.SummaryTargets, s.Report(now.In(s.location)))
+		}
+	}
+}
+
+// nextDailyTime returns the next occurrence of hour:minute (in now's zone) after now
+func nextDailyTime(now time.Time, hour, minute int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Report renders the current period and starts a new one
+func (s *TrafficSummary) Report(now time.Time) Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.interfaces))
+	for name := range s.interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		totals := s.interfaces[name]
+		if totals.samples == 0 {
+			continue
+		}
+		if label := s.labels(name); label != "" {
+			fmt.Fprintf(&b, "%s (%s)\n", name, label)
+		} else {
+			fmt.Fprintf(&b, "%s\n", name)
+		}
+		n := float64(totals.samples)
+		fmt.Fprintf(&b, "  Down  avg %10s  peak %10s  total %9s\n",
+			formatAlertRate(totals.downloadSum/n*8), formatAlertRate(totals.downloadPeak*8), formatByteCount(totals.downloadBytes))
+		fmt.Fprintf(&b, "  Up    avg %10s  peak %10s  total %9s\n",
+			formatAlertRate(totals.uploadSum/n*8), formatAlertRate(totals.uploadPeak*8), formatByteCount(totals.uploadBytes))
+
+		// Keep the counters so the next period continues without a gap
+		s.interfaces[name] = &summaryTotals{lastRx: totals.lastRx, lastTx: totals.lastTx}
+	}
+	if b.Len() == 0 {
+		b.WriteString("No samples collected")
+	}
+
+	report := Notification{
+		Title: fmt.Sprintf("Traffic summary %s - %s", s.start.Format("2006-01-02 15:04"), now.Format("2006-01-02 15:04")),
+		Text:  strings.TrimRight(b.String(), "\n"),
+		Code:  true,
+	}
+	s.start = now
+	return report
+}
+
+// summaryState is the persisted form of the current period (state file)
+type summaryState struct {
+	Start      time.Time                     `json:"start"`
+	Interfaces map[string]summaryTotalsState `json:"interfaces"`
+}
+
+// summaryTotalsState is the persisted form of summaryTotals
+type summaryTotalsState struct {
+	UploadSum     float64 `json:"upload_sum"`
+	DownloadSum   float64 `json:"download_sum"`
+	UploadPeak    float64 `json:"upload_peak"`
+	DownloadPeak  float64 `json:"download_peak"`
+	UploadBytes   uint64  `json:"upload_bytes"`
+	DownloadBytes uint64  `json:"download_bytes"`
+	Samples       int     `json:"samples"`
+	LastRx        uint64  `json:"last_rx"`
+	LastTx        uint64  `json:"last_tx"`
+}
+
+// snapshot returns the current period for the state file
+func (s *TrafficSummary) snapshot() *summaryState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := &summaryState{Start: s.start, Interfaces: make(map[string]summaryTotalsState, len(s.interfaces))}
+	for name, t := range s.interfaces {
+		state.Interfaces[name] = summaryTotalsState{
+			UploadSum: t.uploadSum, DownloadSum: t.downloadSum,
+			UploadPeak: t.uploadPeak, DownloadPeak: t.downloadPeak,
+			UploadBytes: t.uploadBytes, DownloadBytes: t.downloadBytes,
+			Samples: t.samples, LastRx: t.lastRx, LastTx: t.lastTx,
+		}
+	}
+	return state
+}
+
+// restore continues a period saved before a restart
+// The bytes transferred while the monitor was down are counted by the next sample:
+// from the saved counters if they continued, from zero for the interfaces in
+// rebooted (router reboot or counter reset). A period whose summary time passed
+// in the meantime was never posted and is dropped.
+func (s *TrafficSummary) restore(state *summaryState, rebooted map[string]bool, now time.Time) bool {
+	if !nextDailyTime(state.Start.In(s.location), s.config.SummaryHour, s.config.SummaryMinute).After(now) {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.start = state.Start.In(s.location)
+	s.interfaces = make(map[string]*summaryTotals, len(state.Interfaces))
+	for name, t := range state.Interfaces {
+		totals := &summaryTotals{
+			uploadSum: t.UploadSum, downloadSum: t.DownloadSum,
+			uploadPeak: t.UploadPeak, downloadPeak: t.DownloadPeak,
+			uploadBytes: t.UploadBytes, downloadBytes: t.DownloadBytes,
+			samples: t.Samples, lastRx: t.LastRx, lastTx: t.LastTx,
+		}
+		if rebooted[name] {
+			totals.lastRx, totals.lastTx = 0, 0
+		}
+		s.interfaces[name] = totals
+	}
+	return true
+}
+
+// formatByteCount renders a byte count with a decimal unit ("1.23 GB")
+func formatByteCount(n uint64) string {
+	value := float64(n)
+	for _, unit := range []string{"B", "kB", "MB", "GB", "TB"} {
+		if value < 1000 || unit == "TB" {
+			if unit == "B" {
+				return fmt.Sprintf("%d B", n)
+			}
+			return fmt.Sprintf("%.2f %s", value, unit)
+		}
+		value /= 1000
+	}
+	return ""
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Syslog Output (RFC 5424)
+// ============================================================================
+
+// syslogFacilities maps facility names to RFC 5424 facility codes
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSeverities maps severity names to RFC 5424 severity codes
+var syslogSeverities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3, "warning": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// syslogWriter sends each Write as one RFC 5424 message
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID - - MSG
+//
+// Transports: udp (one datagram per message), tcp (octet-counting framing,
+// RFC 6587) and unix/unixgram sockets such as /dev/log. A failed write
+// reconnects once before the message is dropped.
+type syslogWriter struct {
+	network  string
+	address  string
+	priority int    // facility*8 + severity
+	hostname string // HOSTNAME field
+	appName  string // APP-NAME field
+	procID   string // PROCID field
+
+	conn    net.Conn
+	framing string // "octet" (tcp), "newline" (unix stream) or "" (datagram)
+	mu      sync.Mutex
+}
+
+// newSyslogWriter connects to the syslog collector
+func newSyslogWriter(config *LogConfig) (*syslogWriter, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	w := &syslogWriter{
+		network:  config.SyslogNetwork,
+		address:  config.SyslogAddress,
+		priority: syslogFacilities[config.SyslogFacility]*8 + syslogSeverities[config.SyslogSeverity],
+		hostname: hostname,
+		appName:  config.SyslogTag,
+		procID:   fmt.Sprint(os.Getpid()),
+	}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// connect dials the collector
+// For "unix" a datagram socket is tried first, as used by most local syslog daemons
+func (w *syslogWriter) connect() error {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	network := w.network
+	if network == "unix" {
+		network = "unixgram"
+	}
+
+	conn, err := net.DialTimeout(network, w.address, 5*time.Second)
+	if err != nil && w.network == "unix" {
+		network = "unix"
+		conn, err = net.DialTimeout(network, w.address, 5*time.Second)
+	}
+	if err != nil {
+		return fmt.Errorf("connect to syslog %s://%s: %w", w.network, w.address, err)
+	}
+
+	switch network {
+	case "tcp":
+		w.framing = "octet"
+	case "unix":
+		w.framing = "newline"
+	default:
+		w.framing = ""
+	}
+	w.conn = conn
+	return nil
+}
+
+// Write sends p (one log record, trailing newline removed) as a syslog message
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	record := strings.TrimRight(string(p), "\n")
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			if err = w.connect(); err != nil {
+				continue
+			}
+		}
+		if _, err = w.conn.Write(w.format(record)); err == nil {
+			return len(p), nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+	return 0, err
+}
+
+// format builds the RFC 5424 message, framed for stream transports
+func (w *syslogWriter) format(msg string) []byte {
+	line := fmt.Sprintf("<%d>1 %s %s %s %s - - %s",
+		w.priority,
+		time.Now().Format(time.RFC3339Nano),
+		w.hostname,
+		w.appName,
+		w.procID,
+		msg,
+	)
+
+	// Stream sockets need framing: octet counting for TCP collectors (RFC 6587),
+	// newline-terminated for local stream sockets
+	switch w.framing {
+	case "octet":
+		return []byte(fmt.Sprintf("%d %s", len(line), line))
+	case "newline":
+		return []byte(line + "\n")
+	default:
+		return []byte(line)
+	}
+}
+
+// Close closes the connection
+func (w *syslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	return w.conn.Close()
+}
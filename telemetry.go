@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/firadio/golang-mikrotik-interface-stats/pkg/routeros"
+)
+
+// ============================================================================
+// Self-Telemetry (metrics about the monitor itself)
+// ============================================================================
+
+// sampleRateWindow is the number of recent samples used for samples/sec
+const sampleRateWindow = 60
+
+// Telemetry collects internal counters exposed on /metrics and pushed to VM
+// Counters are updated lock-free from the sampling loop, collectors and the VM worker
+type Telemetry struct {
+	started time.Time
+
+	queries       atomic.Int64 // Router queries (GetInterfaceStats and Run)
+	queryErrors   atomic.Int64 // Router queries that returned an error
+	queryNanos    atomic.Int64 // Total query latency
+	lastQueryNano atomic.Int64 // Latency of the most recent query
+	reconnects    atomic.Int64 // Successful reconnections after a connection error
+	samples       atomic.Int64 // Successful interface samples
+	pollNanos     atomic.Int64 // Current poll interval (stretched while queries are slow)
+	pollBaseNanos atomic.Int64 // Configured poll interval (POLL_INTERVAL)
+	clockSkew     atomic.Int64 // Wall-clock adjustment since the first sample (nanoseconds)
+	clockJumps    atomic.Int64 // Wall-clock steps of at least clockJumpThreshold
+
+	// Timestamps of recent samples for samples/sec (ring buffer)
+	sampleTimes [sampleRateWindow]time.Time
+	sampleNext  int
+	sampleMu    sync.Mutex
+
+	// Optional sources (nil if disabled), set by NewMonitor
+	vm  *VMClient
+	web *WebServer
+}
+
+// NewTelemetry creates a telemetry collector
+func NewTelemetry() *Telemetry {
+	return &Telemetry{started: time.Now()}
+}
+
+// RecordQuery records the latency and outcome of one router query
+func (t *Telemetry) RecordQuery(duration time.Duration, err error) {
+	t.queries.Add(1)
+	t.queryNanos.Add(int64(duration))
+	t.lastQueryNano.Store(int64(duration))
+	if err != nil {
+		t.queryErrors.Add(1)
+	}
+}
+
+// RecordSample records one successful sampling tick
+func (t *Telemetry) RecordSample(now time.Time) {
+	t.samples.Add(1)
+
+	t.sampleMu.Lock()
+	t.sampleTimes[t.sampleNext] = now
+	t.sampleNext = (t.sampleNext + 1) % sampleRateWindow
+	t.sampleMu.Unlock()
+}
+
+// RecordPollInterval records the current and the configured poll interval
+func (t *Telemetry) RecordPollInterval(interval, base time.Duration) {
+	t.pollNanos.Store(int64(interval))
+	t.pollBaseNanos.Store(int64(base))
+}
+
+// RecordClock records a wall-clock step (0 if none) and the total adjustment since startup
+func (t *Telemetry) RecordClock(jump, skew time.Duration) {
+	t.clockSkew.Store(int64(skew))
+	if jump != 0 {
+		t.clockJumps.Add(1)
+	}
+}
+
+// sampleRate returns samples per second over the most recent samples
+func (t *Telemetry) sampleRate() float64 {
+	t.sampleMu.Lock()
+	defer t.sampleMu.Unlock()
+
+	var first, last time.Time
+	count := 0
+	for _, ts := range t.sampleTimes {
+		if ts.IsZero() {
+			continue
+		}
+		if first.IsZero() || ts.Before(first) {
+			first = ts
+		}
+		if ts.After(last) {
+			last = ts
+		}
+		count++
+	}
+	if count < 2 || !last.After(first) {
+		return 0
+	}
+	return float64(count-1) / last.Sub(first).Seconds()
+}
+
+// Metrics renders all internal metrics in Prometheus text format
+// With a non-zero timestamp every line carries it (for VM push); /metrics omits it
+func (t *Telemetry) Metrics(timestamp time.Time) string {
+	var buf strings.Builder
+	suffix := ""
+	if !timestamp.IsZero() {
+		suffix = fmt.Sprintf(" %d", timestamp.UnixMilli())
+	}
+	write := func(name, typ, help string, value any) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n%s %v%s\n", name, help, name, typ, name, value, suffix)
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	write("mikrotik_monitor_uptime_seconds", "gauge", "Time since the monitor started",
+		fmt.Sprintf("%.0f", time.Since(t.started).Seconds()))
+	write("mikrotik_monitor_queries_total", "counter", "Router queries issued", t.queries.Load())
+	write("mikrotik_monitor_query_errors_total", "counter", "Router queries that failed", t.queryErrors.Load())
+	write("mikrotik_monitor_query_duration_seconds_total", "counter", "Total router query latency",
+		fmt.Sprintf("%.6f", time.Duration(t.queryNanos.Load()).Seconds()))
+	write("mikrotik_monitor_query_last_duration_seconds", "gauge", "Latency of the most recent router query",
+		fmt.Sprintf("%.6f", time.Duration(t.lastQueryNano.Load()).Seconds()))
+	write("mikrotik_monitor_reconnects_total", "counter", "Reconnections to the router after connection errors", t.reconnects.Load())
+	write("mikrotik_monitor_samples_total", "counter", "Successful interface samples", t.samples.Load())
+	write("mikrotik_monitor_samples_per_second", "gauge", "Sampling rate over the last minute",
+		fmt.Sprintf("%.3f", t.sampleRate()))
+	if interval := time.Duration(t.pollNanos.Load()); interval > 0 {
+		stretched := 0
+		if interval > time.Duration(t.pollBaseNanos.Load()) {
+			stretched = 1
+		}
+		write("mikrotik_monitor_poll_interval_seconds", "gauge", "Current interval between interface samples",
+			fmt.Sprintf("%.3f", interval.Seconds()))
+		write("mikrotik_monitor_poll_interval_stretched", "gauge", "Whether slow router queries stretched the poll interval (1) or not (0)", stretched)
+	}
+	write("mikrotik_monitor_clock_skew_seconds", "gauge", "Wall-clock adjustment since startup (wall minus monotonic elapsed time, NTP steps and drift)",
+		fmt.Sprintf("%.3f", time.Duration(t.clockSkew.Load()).Seconds()))
+	write("mikrotik_monitor_clock_jumps_total", "counter", "Wall-clock steps of a second or more between samples", t.clockJumps.Load())
+
+	if t.vm != nil {
+		status := t.vm.PushStatus()
+		write("mikrotik_monitor_vm_push_failures_total", "counter", "Failed VictoriaMetrics deliveries (including retries)", status.PushFailures)
+	}
+	if t.web != nil {
+		write("mikrotik_monitor_websocket_clients", "gauge", "Connected WebSocket clients", t.web.ClientCount())
+	}
+
+	write("mikrotik_monitor_goroutines", "gauge", "Number of goroutines", runtime.NumGoroutine())
+	write("mikrotik_monitor_memory_heap_bytes", "gauge", "Bytes of allocated heap objects", mem.HeapAlloc)
+	write("mikrotik_monitor_memory_sys_bytes", "gauge", "Bytes of memory obtained from the OS", mem.Sys)
+
+	return buf.String()
+}
+
+// ============================================================================
+// Instrumented Router Client (latency, errors, reconnects)
+// ============================================================================
+
+// instrumentedClient wraps a RouterClient to record query telemetry and
+// transparently reconnect after connection errors (EOF, reset, timeout)
+//
+// A failed connection is closed and dropped; the next query dials a new one.
+// Errors reported by the router itself (e.g. !trap) keep the connection.
+// A rejected login is not retried until the credentials change (SetConfig),
+// so a wrong password does not hammer the router every second.
+type instrumentedClient struct {
+	config    *Config
+	telemetry *Telemetry
+
+	client         RouterClient // Current connection (nil while disconnected)
+	authErr        error        // Last login rejection (nil = reconnecting allowed)
+	challengeLogin bool         // RouterOS before 6.43 detected with MIKROTIK_LOGIN=auto
+	mu             sync.Mutex
+}
+
+// newInstrumentedClient wraps an established client
+func newInstrumentedClient(client RouterClient, config *Config, telemetry *Telemetry) *instrumentedClient {
+	c := &instrumentedClient{config: config, telemetry: telemetry, client: client}
+	c.noteVersion(client)
+	return c
+}
+
+// noteVersion switches reconnects to the challenge login once an old router is detected,
+// so the password is not sent in clear text before the router asks for the challenge
+func (c *instrumentedClient) noteVersion(client RouterClient) {
+	version := routerVersion(client)
+	if c.config.LoginMethod != "auto" || !version.Known() || version.PlainLogin() || c.challengeLogin {
+		return
+	}
+	c.challengeLogin = true
+	logInfo("Client", "RouterOS %s detected, reconnecting with the challenge login", version)
+}
+
+// dialConfig returns the configuration for a new connection
+func (c *instrumentedClient) dialConfig() *Config {
+	if !c.challengeLogin {
+		return c.config
+	}
+	config := *c.config
+	config.LoginMethod = "challenge"
+	return &config
+}
+
+// GetInterfaceStats queries interface counters on the current connection
+func (c *instrumentedClient) GetInterfaceStats(ctx context.Context, interfaces []string) ([]InterfaceStats, error) {
+	client, err := c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	stats, err := client.GetInterfaceStats(ctx, interfaces)
+	c.telemetry.RecordQuery(time.Since(start), err)
+	c.checkConnection(ctx, client, err)
+	return stats, err
+}
+
+// Run runs a command on the current connection
+func (c *instrumentedClient) Run(ctx context.Context, words ...string) ([]map[string]string, error) {
+	client, err := c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	rows, err := client.Run(ctx, words...)
+	c.telemetry.RecordQuery(time.Since(start), err)
+	c.checkConnection(ctx, client, err)
+	return rows, err
+}
+
+// Close closes the current connection
+func (c *instrumentedClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Close()
+	c.client = nil
+	return err
+}
+
+// current returns the active connection, dialing a new one if the previous one failed
+func (c *instrumentedClient) current(ctx context.Context) (RouterClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client != nil {
+		return c.client, nil
+	}
+	if c.authErr != nil {
+		return nil, fmt.Errorf("reconnect: %w", c.authErr)
+	}
+
+	client, err := NewRouterClient(ctx, c.dialConfig())
+	if err != nil {
+		c.telemetry.RecordQuery(0, err)
+		if errors.Is(err, routeros.ErrAuthFailed) {
+			c.authErr = err
+			logError("Client", "Login rejected, not reconnecting until MIKROTIK_PASSWORD changes: %v", err)
+		}
+		return nil, fmt.Errorf("reconnect: %w", err)
+	}
+	c.client = client
+	c.noteVersion(client)
+	c.telemetry.reconnects.Add(1)
+	logInfo("Client", "Reconnected to %s", net.JoinHostPort(c.config.Host, c.config.Port))
+	return client, nil
+}
+
+// SetConfig replaces the connection settings used for the next reconnect (configuration reload)
+// A previous login rejection is forgotten so the new credentials are tried.
+func (c *instrumentedClient) SetConfig(config *Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = config
+	c.authErr = nil
+}
+
+// checkConnection drops the connection after a connection-level error
+// Only the connection that failed is dropped (another caller may have replaced it already)
+func (c *instrumentedClient) checkConnection(ctx context.Context, client RouterClient, err error) {
+	if err == nil || errors.Is(ctx.Err(), context.Canceled) || !isConnectionError(err) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != client {
+		return
+	}
+	logWarn("Client", "Connection error, will reconnect: %v", err)
+	client.Close()
+	c.client = nil
+}
+
+// isConnectionError reports whether err means the connection is unusable
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.As(err, &netErr)
+}
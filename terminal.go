@@ -0,0 +1,779 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// Interactive Terminal UI (refresh mode)
+// ============================================================================
+//
+// In refresh mode the table is redrawn like top. When stdin is a terminal,
+// keys change the view without restarting:
+//
+//   s / r        cycle sort column / reverse sort order
+//   p or space   pause (keep the current screen while sampling continues)
+//   u / k        toggle unit (bps/Bps) / cycle scale (auto, k, M, G)
+//   e            toggle min/stddev columns
+//   g            toggle upload/download sparklines
+//   c            toggle threshold colors
+//   t            toggle the TOTAL row
+//   ↑ ↓ PgUp PgDn Home End   scroll when interfaces exceed the screen height
+//   ?            show/hide key help
+
+// terminalRow holds the display values of one interface (bytes/s, upload/download perspective)
+type terminalRow struct {
+	name                   string
+	label                  string // Display name: user label (see /api/config/labels) or interface name
+	up, down               float64
+	upAvg, downAvg         float64
+	upPeak, downPeak       float64
+	upMin, downMin         float64
+	upStdDev, downStdDev   float64
+	upUtil, downUtil       float64   // Fraction of the link speed
+	linkSpeed              float64   // Bits/s (0 if unknown)
+	upHistory, downHistory []float64 // Stats window, oldest first
+}
+
+// terminalColumn is a numeric column of the refresh-mode table
+type terminalColumn struct {
+	key     string // Name used in TERMINAL_COLUMNS
+	header  string
+	value   func(r *terminalRow) float64
+	extra   bool // Hidden unless extra stats are enabled (TERMINAL_EXTRA_STATS or 'e')
+	sum     bool // Meaningful to add up in the TOTAL row
+	percent bool // Utilization (value is a fraction), shown as "-" without a link speed
+	level   bool // Colored by TERMINAL_COLOR_WARN/CRIT (one direction's rate or utilization)
+}
+
+// terminalColumns lists all numeric columns
+var terminalColumns = []terminalColumn{
+	{key: "up", header: "Up", value: func(r *terminalRow) float64 { return r.up }, sum: true, level: true},
+	{key: "down", header: "Down", value: func(r *terminalRow) float64 { return r.down }, sum: true, level: true},
+	{key: "upavg", header: "UpAvg", value: func(r *terminalRow) float64 { return r.upAvg }, sum: true, level: true},
+	{key: "dnavg", header: "DnAvg", value: func(r *terminalRow) float64 { return r.downAvg }, sum: true, level: true},
+	{key: "uppeak", header: "UpPeak", value: func(r *terminalRow) float64 { return r.upPeak }, level: true},
+	{key: "dnpeak", header: "DnPeak", value: func(r *terminalRow) float64 { return r.downPeak }, level: true},
+	{key: "uputil", header: "Up%", value: func(r *terminalRow) float64 { return r.upUtil }, percent: true, level: true},
+	{key: "dnutil", header: "Dn%", value: func(r *terminalRow) float64 { return r.downUtil }, percent: true, level: true},
+	{key: "upmin", header: "UpMin", value: func(r *terminalRow) float64 { return r.upMin }, extra: true, level: true},
+	{key: "dnmin", header: "DnMin", value: func(r *terminalRow) float64 { return r.downMin }, extra: true, level: true},
+	{key: "upstd", header: "UpStd", value: func(r *terminalRow) float64 { return r.upStdDev }, extra: true},
+	{key: "dnstd", header: "DnStd", value: func(r *terminalRow) float64 { return r.downStdDev }, extra: true},
+	{key: "total", header: "Total", value: func(r *terminalRow) float64 { return r.up + r.down }, sum: true},
+}
+
+// defaultTerminalColumns is the layout used when TERMINAL_COLUMNS is not set
+var defaultTerminalColumns = []string{"up", "down", "upavg", "dnavg", "uppeak", "dnpeak", "uputil", "dnutil", "upmin", "dnmin", "upstd", "dnstd"}
+
+// findTerminalColumn returns the index of a column key in terminalColumns, or -1
+func findTerminalColumn(key string) int {
+	for i, column := range terminalColumns {
+		if column.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseTerminalSort converts a TERMINAL_SORT value to a sort column and direction
+// Rates sort descending so the busiest interfaces come first; ok is false for unknown values
+func parseTerminalSort(value string) (column int, desc bool, ok bool) {
+	switch value {
+	case "name", "":
+		return sortByName, false, true
+	case "upload":
+		value = "up"
+	case "download":
+		value = "down"
+	}
+	if column = findTerminalColumn(value); column < 0 {
+		return sortByName, false, false
+	}
+	return column, true, true
+}
+
+// terminalColumnKeys lists the valid TERMINAL_COLUMNS entries
+func terminalColumnKeys() []string {
+	keys := make([]string, len(terminalColumns))
+	for i, column := range terminalColumns {
+		keys[i] = column.key
+	}
+	return keys
+}
+
+const (
+	terminalNameWidth   = 10 // Minimum interface name column width ("Interface")
+	terminalMaxName     = 32 // Maximum automatic name column width
+	terminalColumnWidth = 10 // Numeric column width
+	terminalMinWidth    = 80 // Minimum separator width
+	sortByName          = -1 // sortColumn value for sorting by interface name
+)
+
+// sparkBlocks are the sparkline levels from lowest to highest
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// ANSI sequences used by the TUI
+const (
+	ansiHome      = "\033[H" // Move cursor to (1,1) without clearing (less flicker)
+	ansiReverse   = "\033[7m"
+	ansiReset     = "\033[0m"
+	ansiClearLine = "\033[K" // Clear to end of line
+	ansiClearDown = "\033[J" // Clear to end of screen
+	ansiHideCur   = "\033[?25l"
+	ansiShowCur   = "\033[?25h"
+
+	// Threshold colors; cells end with the default foreground so a reverse-video row stays reversed
+	ansiGreen   = "\033[32m"
+	ansiYellow  = "\033[33m"
+	ansiRed     = "\033[31m"
+	ansiDefault = "\033[39m"
+)
+
+// colorThreshold is a TERMINAL_COLOR_WARN/CRIT level: a fraction of the link speed or a rate
+type colorThreshold struct {
+	ratio float64 // Utilization (0 = not a utilization threshold)
+	rate  float64 // Bytes/s (0 = not a rate threshold)
+}
+
+// parseColorThreshold parses "70%" (utilization) or a rate in bits/s such as "500M" ("" = no level)
+func parseColorThreshold(value string) (colorThreshold, error) {
+	value = strings.TrimSpace(value)
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		ratio, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+		if err != nil || ratio <= 0 {
+			return colorThreshold{}, fmt.Errorf("invalid utilization: %s", value)
+		}
+		return colorThreshold{ratio: ratio / 100}, nil
+	}
+	bits, err := parseRate(value)
+	if err != nil {
+		return colorThreshold{}, err
+	}
+	return colorThreshold{rate: bits / 8}, nil
+}
+
+// set reports whether the threshold is configured
+func (c colorThreshold) set() bool {
+	return c.ratio > 0 || c.rate > 0
+}
+
+// reached reports whether rate (bytes/s) reaches the threshold
+// ok is false for a utilization threshold without a link speed (bits/s)
+func (c colorThreshold) reached(rate, linkSpeed float64) (reached, ok bool) {
+	switch {
+	case c.ratio > 0:
+		if linkSpeed <= 0 {
+			return false, false
+		}
+		return utilization(rate, linkSpeed) >= c.ratio, true
+	case c.rate > 0:
+		return rate >= c.rate, true
+	}
+	return false, true
+}
+
+// terminalColors resolves TERMINAL_COLOR: "auto" follows NO_COLOR (https://no-color.org),
+// TERM=dumb and whether stdout is a terminal
+func terminalColors(setting string) bool {
+	switch setting {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// startKeyboard switches stdin to unbuffered input and handles keys in the background
+// Does nothing if stdin is not a terminal (e.g. running under a service manager)
+func (t *TerminalOutput) startKeyboard() {
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return
+	}
+
+	restore, err := enableRawInput()
+	if err != nil {
+		logWarn("Terminal", "Keyboard controls unavailable: %v", err)
+		return
+	}
+	t.restoreInput = restore
+	t.interactive = true
+	fmt.Print(ansiHideCur)
+
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			for _, key := range parseKeys(buf[:n]) {
+				t.handleKey(key)
+			}
+		}
+	}()
+}
+
+// stopKeyboard restores the terminal input mode and cursor
+func (t *TerminalOutput) stopKeyboard() {
+	if t.restoreInput == nil {
+		return
+	}
+	t.restoreInput()
+	t.restoreInput = nil
+	fmt.Print(ansiShowCur)
+}
+
+// parseKeys splits raw input into keys; escape sequences become names such as "up" or "pgdn"
+func parseKeys(input []byte) []string {
+	sequences := map[string]string{
+		"\033[A": "up", "\033[B": "down",
+		"\033[5~": "pgup", "\033[6~": "pgdn",
+		"\033[H": "home", "\033[F": "end",
+		"\033[1~": "home", "\033[4~": "end",
+		"\033OA": "up", "\033OB": "down",
+	}
+
+	var keys []string
+	s := string(input)
+	for len(s) > 0 {
+		matched := false
+		if s[0] == '\033' {
+			for seq, name := range sequences {
+				if strings.HasPrefix(s, seq) {
+					keys = append(keys, name)
+					s = s[len(seq):]
+					matched = true
+					break
+				}
+			}
+		}
+		if !matched {
+			keys = append(keys, s[:1])
+			s = s[1:]
+		}
+	}
+	return keys
+}
+
+// handleKey applies a key press and redraws the screen
+func (t *TerminalOutput) handleKey(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch key {
+	case "s":
+		t.sortColumn = t.nextSortColumn()
+	case "r":
+		t.sortDesc = !t.sortDesc
+	case "p", " ":
+		t.paused = !t.paused
+	case "u":
+		if t.rateUnit == "bps" {
+			t.rateUnit = "Bps"
+		} else {
+			t.rateUnit = "bps"
+		}
+	case "k":
+		scales := []string{"auto", "k", "M", "G"}
+		for i, scale := range scales {
+			if scale == t.rateScale {
+				t.rateScale = scales[(i+1)%len(scales)]
+				break
+			}
+		}
+	case "e":
+		t.extraStats = !t.extraStats
+		if t.sortColumn != sortByName && terminalColumns[t.sortColumn].extra && !t.extraStats {
+			t.sortColumn = sortByName
+		}
+	case "up":
+		t.offset--
+	case "down":
+		t.offset++
+	case "pgup":
+		t.offset -= t.pageSize
+	case "pgdn":
+		t.offset += t.pageSize
+	case "home":
+		t.offset = 0
+	case "end":
+		t.offset = len(t.lastStats)
+	case "g":
+		t.sparklines = !t.sparklines
+	case "t":
+		t.showTotal = !t.showTotal
+	case "c":
+		t.colors = !t.colors && (t.colorWarn.set() || t.colorCrit.set())
+	case "?", "h":
+		t.showHelp = !t.showHelp
+	default:
+		return
+	}
+
+	t.render()
+}
+
+// nextSortColumn returns the column after the current sort column (name, then columns in display order)
+func (t *TerminalOutput) nextSortColumn() int {
+	passed := t.sortColumn == sortByName
+	for _, i := range t.columns {
+		if passed && (t.extraStats || !terminalColumns[i].extra) {
+			return i
+		}
+		if i == t.sortColumn {
+			passed = true
+		}
+	}
+	return sortByName
+}
+
+// buildRows converts rates to upload/download rows, sorted by the selected column
+func (t *TerminalOutput) buildRows(stats map[string]*RateInfo) []*terminalRow {
+	rows := make([]*terminalRow, 0, len(stats))
+	for name, info := range stats {
+		// Convert RX/TX to Upload/Download based on interface type
+		//
+		// Uplink (WAN to ISP):
+		//   - TX = Upload to internet
+		//   - RX = Download from internet
+		//   - No swap needed (matches user expectation)
+		//
+		// Downlink (LAN/VLAN to users):
+		//   - TX = Download (router sends to user)
+		//   - RX = Upload (router receives from user)
+		//   - Swap needed for user perspective
+		row := &terminalRow{name: name, label: t.userConfig.GetInterfaceLabel(name), linkSpeed: info.LinkSpeed}
+		if t.userConfig.IsUplink(name) {
+			row.up, row.down = info.TxRate, info.RxRate
+			row.upAvg, row.downAvg = info.TxAvg, info.RxAvg
+			row.upPeak, row.downPeak = info.TxPeak, info.RxPeak
+			row.upMin, row.downMin = info.TxMin, info.RxMin
+			row.upStdDev, row.downStdDev = info.TxStdDev, info.RxStdDev
+			row.upUtil, row.downUtil = info.TxUtilization, info.RxUtilization
+			row.upHistory, row.downHistory = info.TxHistory, info.RxHistory
+		} else {
+			row.up, row.down = info.RxRate, info.TxRate
+			row.upAvg, row.downAvg = info.RxAvg, info.TxAvg
+			row.upPeak, row.downPeak = info.RxPeak, info.TxPeak
+			row.upMin, row.downMin = info.RxMin, info.TxMin
+			row.upStdDev, row.downStdDev = info.RxStdDev, info.TxStdDev
+			row.upUtil, row.downUtil = info.RxUtilization, info.TxUtilization
+			row.upHistory, row.downHistory = info.RxHistory, info.TxHistory
+		}
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if t.sortDesc {
+			a, b = b, a
+		}
+		if t.sortColumn == sortByName {
+			return a.label < b.label
+		}
+		va, vb := terminalColumns[t.sortColumn].value(a), terminalColumns[t.sortColumn].value(b)
+		if va == vb {
+			return rows[i].label < rows[j].label
+		}
+		return va < vb
+	})
+	return rows
+}
+
+// visibleColumns returns the configured columns that fit into the terminal width (0 = unlimited)
+// Utilization columns are left out while no interface has a known link speed
+func (t *TerminalOutput) visibleColumns(width, nameWidth int, utilization bool) []int {
+	var columns []int
+	used := nameWidth
+	for _, i := range t.columns {
+		if (terminalColumns[i].extra && !t.extraStats) || (terminalColumns[i].percent && !utilization) {
+			continue
+		}
+		if width > 0 && used+1+terminalColumnWidth > width {
+			break
+		}
+		columns = append(columns, i)
+		used += 1 + terminalColumnWidth
+	}
+	return columns
+}
+
+// nameColumnWidth returns the configured name width, or the longest name (within limits)
+func (t *TerminalOutput) nameColumnWidth(rows []*terminalRow, width int) int {
+	if t.nameWidth > 0 {
+		return t.nameWidth
+	}
+
+	nameWidth := terminalNameWidth
+	for _, row := range rows {
+		if n := utf8.RuneCountInString(row.label); n > nameWidth {
+			nameWidth = n
+		}
+	}
+	if nameWidth > terminalMaxName {
+		nameWidth = terminalMaxName
+	}
+	// Leave room for at least the Up and Down columns on narrow terminals
+	if limit := width - 2*(1+terminalColumnWidth); width > 0 && nameWidth > limit && limit >= terminalNameWidth {
+		nameWidth = limit
+	}
+	return nameWidth
+}
+
+// truncateName shortens a name to width, keeping both ends so similar names stay distinct
+// (e.g. "vlan2622-customer-a" -> "vlan26…er-a")
+func truncateName(name string, width int) string {
+	r := []rune(name)
+	if len(r) <= width {
+		return name
+	}
+	head := (width - 1) / 2
+	tail := width - 1 - head
+	return string(r[:head]) + "…" + string(r[len(r)-tail:])
+}
+
+// render draws the refresh-mode screen from the last stats (caller holds t.mu)
+func (t *TerminalOutput) render() {
+	if t.lastStats == nil {
+		return
+	}
+
+	// Without a known terminal size (e.g. output redirected) all rows and columns are shown
+	width, height, sized := terminalSize()
+	if !sized {
+		width, height = 0, 0
+	}
+
+	rows := t.buildRows(t.lastStats)
+	nameWidth := t.nameColumnWidth(rows, width)
+	utilization := false
+	for _, row := range rows {
+		if row.linkSpeed > 0 {
+			utilization = true
+			break
+		}
+	}
+	columns := t.visibleColumns(width, nameWidth, utilization)
+	sepWidth := nameWidth + len(columns)*(1+terminalColumnWidth)
+
+	// Sparklines are added after the numeric columns if they still fit
+	graphWidth := 0
+	if t.sparklines {
+		graphWidth = t.statsWindowSize
+		if graphWidth < len("UpGraph") {
+			graphWidth = len("UpGraph")
+		}
+		if width > 0 && sepWidth+2*(1+graphWidth) > width {
+			graphWidth = 0
+		} else {
+			sepWidth += 2 * (1 + graphWidth)
+		}
+	}
+	if sepWidth < terminalMinWidth {
+		sepWidth = terminalMinWidth
+	}
+	if width > 0 && sepWidth > width {
+		sepWidth = width
+	}
+
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		b.WriteString(truncateWidth(fmt.Sprintf(format, args...), width) + ansiClearLine + "\n")
+	}
+
+	// Header: title, time/unit/window and view state
+	line("Mikrotik Interface Traffic Monitor")
+	line("%s", strings.Repeat("=", sepWidth))
+	status := fmt.Sprintf("Time: %s | Unit: %s | Window: %ds",
+		t.lastTime.Format("2006-01-02 15:04:05"), getUnitSuffix(t.rateUnit, t.rateScale), t.statsWindowSize)
+	if t.interactive || t.sortColumn != sortByName {
+		status += " | Sort: " + t.sortLabel()
+	}
+	if t.paused {
+		status += " | PAUSED"
+	}
+	line("%s", status)
+	line("%s", strings.Repeat("-", sepWidth))
+
+	header := fmt.Sprintf("%-*s", nameWidth, truncateWidth("Interface", nameWidth))
+	for _, i := range columns {
+		header += fmt.Sprintf(" %*s", terminalColumnWidth, terminalColumns[i].header)
+	}
+	if graphWidth > 0 {
+		header += fmt.Sprintf(" %-*s %-*s", graphWidth, "UpGraph", graphWidth, "DnGraph")
+	}
+	line("%s", header)
+	line("%s", strings.Repeat("-", sepWidth))
+
+	// Footer lines below the table: separator, optional percentile summary, scroll info, keys
+	footer := 3
+	if t.percentile != nil {
+		footer += len(t.percentile.Results()) + 2
+	}
+	if t.showHelp {
+		footer += 4
+	}
+	showTotal := t.showTotal && len(rows) > 1
+	if showTotal {
+		footer += 2
+	}
+
+	start, end := 0, len(rows)
+	if height > 0 {
+		t.pageSize = height - 6 - footer
+		if t.pageSize < 1 {
+			t.pageSize = 1
+		}
+		if t.offset > len(rows)-t.pageSize {
+			t.offset = len(rows) - t.pageSize
+		}
+		if t.offset < 0 {
+			t.offset = 0
+		}
+		start = t.offset
+		if start+t.pageSize < end {
+			end = start + t.pageSize
+		}
+	}
+
+	for _, row := range rows[start:end] {
+		s := padRight(truncateName(row.label, nameWidth), nameWidth)
+		colored := s
+		for _, i := range columns {
+			cell := fmt.Sprintf("%*s", terminalColumnWidth, t.formatColumn(terminalColumns[i], row))
+			s += " " + cell
+			if color := t.cellColor(terminalColumns[i], row); color != "" {
+				cell = color + cell + ansiDefault
+			}
+			colored += " " + cell
+		}
+		if graphWidth > 0 {
+			// Both graphs share one scale so upload and download are comparable
+			scale := math.Max(maxValue(row.upHistory), maxValue(row.downHistory))
+			graphs := " " + sparkline(row.upHistory, scale, graphWidth) + " " + sparkline(row.downHistory, scale, graphWidth)
+			s += graphs
+			colored += graphs
+		}
+		// A row that has to be cut loses its colors (the cut could split an escape sequence)
+		if truncated := truncateWidth(s, width); truncated != s {
+			colored = truncated
+		}
+		s = colored
+
+		// Highlight busy interfaces in reverse video
+		if t.highlightRate > 0 && (row.up >= t.highlightRate || row.down >= t.highlightRate) {
+			s = ansiReverse + s + ansiReset
+		}
+		b.WriteString(s + ansiClearLine + "\n")
+	}
+
+	line("%s", strings.Repeat("-", sepWidth))
+
+	// TOTAL row covers all rows (not only the visible page); virtual group
+	// interfaces are left out since their members are already counted
+	if showTotal {
+		total := &terminalRow{}
+		for _, row := range rows {
+			if t.groups[row.name] {
+				continue
+			}
+			total.up += row.up
+			total.down += row.down
+			total.upAvg += row.upAvg
+			total.downAvg += row.downAvg
+		}
+
+		s := padRight("TOTAL", nameWidth)
+		for _, i := range columns {
+			value := ""
+			if terminalColumns[i].sum {
+				value = formatNumeric(terminalColumns[i].value(total), t.rateUnit, t.rateScale)
+			}
+			s += fmt.Sprintf(" %*s", terminalColumnWidth, value)
+		}
+		line("%s", strings.TrimRight(s, " "))
+		line("%s", strings.Repeat("-", sepWidth))
+	}
+
+	if t.percentile != nil {
+		t.writePercentileSummary(line, sepWidth, nameWidth)
+	}
+
+	if start > 0 || end < len(rows) {
+		line("Interfaces %d-%d of %d (↑/↓ PgUp/PgDn to scroll)", start+1, end, len(rows))
+	}
+
+	switch {
+	case t.showHelp:
+		line("s: sort column   r: reverse order   p/space: pause")
+		line("u: toggle bps/Bps   k: cycle scale (auto, k, M, G)   e: min/stddev columns   g: graphs   t: total   c: colors")
+		line("↑/↓ PgUp/PgDn Home/End: scroll   ?: hide help")
+		line("Press Ctrl+C to stop")
+	case t.interactive:
+		line("Press ? for keys, Ctrl+C to stop")
+	default:
+		line("Press Ctrl+C to stop")
+	}
+
+	// Move home, overwrite in one write to reduce flicker, and clear leftovers below
+	fmt.Print(ansiHome + b.String() + ansiClearDown)
+}
+
+// formatColumn formats one cell: rates in the selected unit/scale, utilization in percent
+func (t *TerminalOutput) formatColumn(column terminalColumn, row *terminalRow) string {
+	if !column.percent {
+		return formatNumeric(column.value(row), t.rateUnit, t.rateScale)
+	}
+	if row.linkSpeed <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", column.value(row)*100)
+}
+
+// cellColor returns the threshold color of a cell ("" = uncolored)
+// Green below TERMINAL_COLOR_WARN, yellow from it, red from TERMINAL_COLOR_CRIT; utilization
+// thresholds leave interfaces without a link speed uncolored.
+func (t *TerminalOutput) cellColor(column terminalColumn, row *terminalRow) string {
+	if !t.colors || !column.level {
+		return ""
+	}
+	rate := column.value(row)
+	if column.percent {
+		if row.linkSpeed <= 0 {
+			return "" // Shown as "-"
+		}
+		rate = rate * row.linkSpeed / 8 // Back to bytes/s so rate thresholds apply too
+	}
+
+	warn, warnOK := t.colorWarn.reached(rate, row.linkSpeed)
+	crit, critOK := t.colorCrit.reached(rate, row.linkSpeed)
+	switch {
+	case !warnOK || !critOK:
+		return ""
+	case crit:
+		return ansiRed
+	case warn:
+		return ansiYellow
+	}
+	return ansiGreen
+}
+
+// sparkline renders the last width values as block characters scaled to max (newest on the right)
+func sparkline(values []float64, max float64, width int) string {
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", width-len(values)))
+	for _, v := range values {
+		level := 0
+		if max > 0 {
+			level = int(v / max * float64(len(sparkBlocks)-1))
+		}
+		if level < 0 {
+			level = 0
+		}
+		if level >= len(sparkBlocks) {
+			level = len(sparkBlocks) - 1
+		}
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+// maxValue returns the largest value (0 for an empty slice)
+func maxValue(values []float64) float64 {
+	var max float64
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+// padRight pads s with spaces to width characters
+func padRight(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// truncateWidth cuts s to width characters (0 = unlimited)
+func truncateWidth(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	if r := []rune(s); len(r) > width {
+		return string(r[:width])
+	}
+	return s
+}
+
+// sortLabel describes the current sort order for the status line
+func (t *TerminalOutput) sortLabel() string {
+	name := "Interface"
+	if t.sortColumn != sortByName {
+		name = terminalColumns[t.sortColumn].header
+	}
+	if t.sortDesc {
+		return name + " ▼"
+	}
+	return name + " ▲"
+}
+
+// writePercentileSummary prints the billing percentile per interface (refresh mode)
+func (t *TerminalOutput) writePercentileSummary(line func(format string, args ...interface{}), sepWidth, nameWidth int) {
+	results := t.percentile.Results()
+	config := t.percentile.config
+
+	if len(results) == 0 {
+		line("%gth percentile: waiting for first %v sample", config.Percentile, config.SampleInterval)
+		line("%s", strings.Repeat("-", sepWidth))
+		return
+	}
+
+	line("%gth percentile (since %s, %v samples)",
+		config.Percentile, results[0].WindowStart.Format("2006-01-02 15:04"), config.SampleInterval)
+	for _, result := range results {
+		uploadRate, downloadRate := result.RxRate, result.TxRate
+		if t.userConfig.IsUplink(result.Interface) {
+			uploadRate, downloadRate = result.TxRate, result.RxRate
+		}
+
+		line("%s %10s %10s %10s", padRight(truncateName(t.userConfig.GetInterfaceLabel(result.Interface), nameWidth), nameWidth),
+			formatNumeric(uploadRate, t.rateUnit, t.rateScale),
+			formatNumeric(downloadRate, t.rateUnit, t.rateScale),
+			fmt.Sprintf("(%d)", result.Samples))
+	}
+	line("%s", strings.Repeat("-", sepWidth))
+}
+
+// writeRefresh stores the latest stats and redraws (refresh mode)
+func (t *TerminalOutput) writeRefresh(timestamp time.Time, stats map[string]*RateInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats = t.smoother.apply(stats) // Also while paused, so the average stays current
+	if t.paused {
+		return
+	}
+	t.lastStats = stats
+	t.lastTime = timestamp
+	t.render()
+}
@@ -2,8 +2,61 @@
 
 package main
 
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
 // enableANSI is a no-op on Unix-like systems (Linux, macOS, etc.)
 // as they natively support ANSI escape codes
 func enableANSI() error {
 	return nil
 }
+
+// enableRawInput disables line buffering and echo on stdin so single key presses are read
+// Signal keys stay enabled, so Ctrl+C still stops the program
+// stty is used because the termios ioctl numbers differ between Linux and BSD/macOS
+func enableRawInput() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty(saved) }, nil
+}
+
+// disableEcho turns off echo on stdin (password prompts)
+func disableEcho() (func(), error) {
+	if _, err := stty("-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty("echo") }, nil
+}
+
+// stty runs stty against the terminal on stdin
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// winsize mirrors struct winsize for TIOCGWINSZ
+type winsize struct {
+	rows, cols, xpixel, ypixel uint16
+}
+
+// terminalSize returns the stdout terminal size in characters (ok=false if not a terminal)
+func terminalSize() (width, height int, ok bool) {
+	var ws winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdout.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 || ws.cols == 0 || ws.rows == 0 {
+		return 0, 0, false
+	}
+	return int(ws.cols), int(ws.rows), true
+}
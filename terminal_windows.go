@@ -12,10 +12,17 @@ var (
 	kernel32           = syscall.NewLazyDLL("kernel32.dll")
 	procSetConsoleMode = kernel32.NewProc("SetConsoleMode")
 	procGetConsoleMode = kernel32.NewProc("GetConsoleMode")
+
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
 )
 
 const (
 	enableVirtualTerminalProcessing = 0x0004
+
+	// Console input modes
+	enableLineInput            = 0x0002
+	enableEchoInput            = 0x0004
+	enableVirtualTerminalInput = 0x0200 // Report arrow keys as ANSI escape sequences
 )
 
 // enableANSI enables ANSI escape sequence processing on Windows
@@ -34,3 +41,56 @@ func enableANSI() error {
 
 	return nil
 }
+
+// enableRawInput disables line buffering and echo on the console input
+// Processed input stays enabled, so Ctrl+C still stops the program
+func enableRawInput() (func(), error) {
+	stdin := syscall.Handle(os.Stdin.Fd())
+
+	var mode uint32
+	if r, _, err := procGetConsoleMode.Call(uintptr(stdin), uintptr(unsafe.Pointer(&mode))); r == 0 {
+		return nil, err
+	}
+
+	raw := mode&^(enableLineInput|enableEchoInput) | enableVirtualTerminalInput
+	if r, _, err := procSetConsoleMode.Call(uintptr(stdin), uintptr(raw)); r == 0 {
+		return nil, err
+	}
+
+	return func() { procSetConsoleMode.Call(uintptr(stdin), uintptr(mode)) }, nil
+}
+
+// disableEcho turns off echo on the console input (password prompts)
+func disableEcho() (func(), error) {
+	stdin := syscall.Handle(os.Stdin.Fd())
+
+	var mode uint32
+	if r, _, err := procGetConsoleMode.Call(uintptr(stdin), uintptr(unsafe.Pointer(&mode))); r == 0 {
+		return nil, err
+	}
+
+	if r, _, err := procSetConsoleMode.Call(uintptr(stdin), uintptr(mode&^enableEchoInput)); r == 0 {
+		return nil, err
+	}
+
+	return func() { procSetConsoleMode.Call(uintptr(stdin), uintptr(mode)) }, nil
+}
+
+// consoleScreenBufferInfo mirrors CONSOLE_SCREEN_BUFFER_INFO
+type consoleScreenBufferInfo struct {
+	size              [2]int16
+	cursorPosition    [2]int16
+	attributes        uint16
+	window            [4]int16 // Left, Top, Right, Bottom
+	maximumWindowSize [2]int16
+}
+
+// terminalSize returns the visible console window size in characters (ok=false if not a console)
+func terminalSize() (width, height int, ok bool) {
+	var info consoleScreenBufferInfo
+	stdout := syscall.Handle(os.Stdout.Fd())
+	if r, _, _ := procGetConsoleScreenBufferInfo.Call(uintptr(stdout), uintptr(unsafe.Pointer(&info))); r == 0 {
+		return 0, 0, false
+	}
+	return int(info.window[2]-info.window[0]) + 1, int(info.window[3]-info.window[1]) + 1, true
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"time"
+	_ "time/tzdata" // Zone database for hosts without one (Windows, scratch containers)
+)
+
+// ============================================================================
+// Timezone (window boundaries, report periods and displayed times)
+// ============================================================================
+//
+// Days and months (reports, billing, quotas, percentiles) start at midnight in
+// the TIMEZONE zone (Config.Location), cron schedules run in it and outputs
+// print its times, so billing cutoffs do not depend on where the monitor runs.
+// The zone is passed to whatever computes calendar boundaries; the process'
+// time.Local is left alone. Sample timestamps handed to outputs carry the zone,
+// so code deriving periods from such a timestamp uses its Location().
+
+// loadLocation returns the zone named by TIMEZONE ("" and "Local" are the host zone)
+// An invalid name falls back to the host zone; Validate reports it.
+func loadLocation(name string) *time.Location {
+	if name == "" || name == "Local" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+// truncateIn rounds t down to a multiple of d counted from midnight in loc
+// time.Truncate counts from the zero time (UTC), which puts hourly windows
+// on the half hour in zones like Asia/Kolkata. Counting from the day's
+// midnight (time.Date, so the offset in effect at midnight) keeps windows on
+// local boundaries, also on the days daylight saving time starts or ends.
+func truncateIn(t time.Time, d time.Duration, loc *time.Location) time.Time {
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	if d >= 24*time.Hour {
+		return midnight
+	}
+	return midnight.Add(t.Sub(midnight) / d * d)
+}
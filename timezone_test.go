@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTruncateIn(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		d    time.Duration
+		loc  *time.Location
+		want time.Time
+	}{
+		{"hour in a half-hour zone", time.Date(2024, 6, 1, 10, 40, 0, 0, kolkata), time.Hour, kolkata, time.Date(2024, 6, 1, 10, 0, 0, 0, kolkata)},
+		{"day", time.Date(2024, 6, 1, 10, 40, 0, 0, berlin), 24 * time.Hour, berlin, time.Date(2024, 6, 1, 0, 0, 0, 0, berlin)},
+		// The offset changes from +01:00 to +02:00 at 02:00: midnight keeps the morning's offset
+		{"day when DST starts", time.Date(2024, 3, 31, 12, 0, 0, 0, berlin), 24 * time.Hour, berlin, time.Date(2024, 3, 31, 0, 0, 0, 0, berlin)},
+		{"day when DST ends", time.Date(2024, 10, 27, 23, 30, 0, 0, berlin), 24 * time.Hour, berlin, time.Date(2024, 10, 27, 0, 0, 0, 0, berlin)},
+		{"5 minutes when DST starts", time.Date(2024, 3, 31, 3, 7, 0, 0, berlin), 5 * time.Minute, berlin, time.Date(2024, 3, 31, 3, 5, 0, 0, berlin)},
+		{"other zone than t's", time.Date(2024, 6, 1, 1, 0, 0, 0, time.UTC), 24 * time.Hour, kolkata, time.Date(2024, 6, 1, 0, 0, 0, 0, kolkata)},
+	}
+	for _, tt := range tests {
+		if got := truncateIn(tt.t, tt.d, tt.loc); !got.Equal(tt.want) || got.Location() != tt.loc {
+			t.Errorf("%s: truncateIn(%v, %v) = %v, want %v", tt.name, tt.t, tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Site / Router Topology (WAN roll-ups)
+// ============================================================================
+
+// TopologyInterface is the WAN traffic of one interface (bytes/s)
+// In is received from the WAN (RX of the uplink), out is sent to it (TX).
+type TopologyInterface struct {
+	In  float64 `json:"in"`
+	Out float64 `json:"out"`
+}
+
+// TopologyRouter is the WAN roll-up of one router (bytes/s)
+type TopologyRouter struct {
+	Router     string                       `json:"router"`
+	WANIn      float64                      `json:"wan_in"`
+	WANOut     float64                      `json:"wan_out"`
+	Interfaces map[string]TopologyInterface `json:"interfaces,omitempty"` // WAN interfaces (local router only)
+	Local      bool                         `json:"local,omitempty"`      // The router of this monitor (live rates)
+}
+
+// TopologySnapshot is the site -> router -> interface view returned by /api/topology
+type TopologySnapshot struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Site      string           `json:"site"`
+	Routers   []TopologyRouter `json:"routers"` // Local router first, then the other routers of the site
+	WANIn     float64          `json:"wan_in"`  // Site roll-up
+	WANOut    float64          `json:"wan_out"`
+}
+
+// topologyWindow accumulates the router roll-up between two VM pushes
+type topologyWindow struct {
+	inSum, outSum   float64
+	inPeak, outPeak float64
+	samples         int
+}
+
+// TopologyTracker rolls the WAN interfaces of this router up per sample
+//
+// Each monitor watches one router, so the hierarchy is built from labels:
+// TOPOLOGY_SITE and TOPOLOGY_ROUTER are added to EXTRA_LABELS, which puts
+// site and router on every series, and the router roll-up is pushed as
+// mikrotik_router_wan_{in,out}_rate_{avg,peak}. The site roll-up sums the
+// latest roll-ups of all routers of the site found in VictoriaMetrics, so
+// monitors sharing one VM see each other.
+type TopologyTracker struct {
+	config   *TopologyConfig
+	uplinks  func() []string // Uplink interfaces when TOPOLOGY_WAN_INTERFACES is empty
+	vmClient *VMClient       // Other routers of the site (nil = local router only)
+
+	latest     TopologyRouter
+	latestTime time.Time
+	window     topologyWindow
+	mu         sync.Mutex
+}
+
+// NewTopologyTracker creates a topology tracker; uplinks come from the user configuration
+func NewTopologyTracker(config *TopologyConfig, uplinks func() []string, vmClient *VMClient) *TopologyTracker {
+	wan := "uplink interfaces"
+	if len(config.WANInterfaces) > 0 {
+		wan = strings.Join(config.WANInterfaces, ", ")
+	}
+	logInfo("Topology", "Topology initialized (site: %s, router: %s, WAN: %s)", config.Site, config.Router, wan)
+
+	return &TopologyTracker{
+		config:   config,
+		uplinks:  uplinks,
+		vmClient: vmClient,
+		latest:   TopologyRouter{Router: config.Router, Local: true},
+	}
+}
+
+// wanInterfaces returns the interfaces rolled up as WAN
+func (t *TopologyTracker) wanInterfaces() []string {
+	if len(t.config.WANInterfaces) > 0 {
+		return t.config.WANInterfaces
+	}
+	return t.uplinks()
+}
+
+// Add records one sample of every interface
+func (t *TopologyTracker) Add(now time.Time, stats map[string]*RateInfo) {
+	router := TopologyRouter{Router: t.config.Router, Local: true, Interfaces: make(map[string]TopologyInterface)}
+	for _, name := range t.wanInterfaces() {
+		info, ok := stats[name]
+		if !ok {
+			continue
+		}
+		router.Interfaces[name] = TopologyInterface{In: info.RxRate, Out: info.TxRate}
+		router.WANIn += info.RxRate
+		router.WANOut += info.TxRate
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.latest = router
+	t.latestTime = now
+	t.window.inSum += router.WANIn
+	t.window.outSum += router.WANOut
+	t.window.inPeak = max(t.window.inPeak, router.WANIn)
+	t.window.outPeak = max(t.window.outPeak, router.WANOut)
+	t.window.samples++
+}
+
+// Latest returns the current roll-up of this router (zero time before the first sample)
+func (t *TopologyTracker) Latest() (TopologyRouter, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.latest, t.latestTime
+}
+
+// flushWindow returns the roll-up accumulated since the last call and starts a new one
+func (t *TopologyTracker) flushWindow() topologyWindow {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	window := t.window
+	t.window = topologyWindow{}
+	return window
+}
+
+// Snapshot returns the site view: this router live and the other routers from VM
+func (t *TopologyTracker) Snapshot() *TopologySnapshot {
+	local, timestamp := t.Latest()
+	snapshot := &TopologySnapshot{
+		Timestamp: timestamp,
+		Site:      t.config.Site,
+		Routers:   []TopologyRouter{local},
+		WANIn:     local.WANIn,
+		WANOut:    local.WANOut,
+	}
+	if t.vmClient == nil {
+		return snapshot
+	}
+
+	others := t.vmClient.QuerySiteRouters(t.config.Site, t.config.Router, time.Now())
+	names := make([]string, 0, len(others))
+	for name := range others {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		router := others[name]
+		snapshot.Routers = append(snapshot.Routers, router)
+		snapshot.WANIn += router.WANIn
+		snapshot.WANOut += router.WANOut
+	}
+	return snapshot
+}
+
+// topologyMetrics renders the current router roll-up in Prometheus text format (for /metrics)
+// Rates are multiplied by rateScale; site and router come from the extra labels.
+func topologyMetrics(router TopologyRouter, rateScale float64) string {
+	var out strings.Builder
+	fmt.Fprintln(&out, "# HELP mikrotik_router_wan_in_rate Traffic received from the WAN interfaces per second")
+	fmt.Fprintln(&out, "# TYPE mikrotik_router_wan_in_rate gauge")
+	fmt.Fprintf(&out, "mikrotik_router_wan_in_rate %.2f\n", router.WANIn*rateScale)
+	fmt.Fprintln(&out, "# HELP mikrotik_router_wan_out_rate Traffic sent to the WAN interfaces per second")
+	fmt.Fprintln(&out, "# TYPE mikrotik_router_wan_out_rate gauge")
+	fmt.Fprintf(&out, "mikrotik_router_wan_out_rate %.2f\n", router.WANOut*rateScale)
+	return out.String()
+}
+
+// topologyWindowMetrics renders a VM window of the router roll-up (average and peak)
+func topologyWindowMetrics(window topologyWindow, interval time.Duration, rateScale float64, timestamp time.Time) string {
+	series := fmt.Sprintf("interval=\"%s\"", intervalLabel(interval))
+	ts := timestamp.UnixMilli()
+	n := float64(window.samples)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "mikrotik_router_wan_in_rate_avg{%s} %.2f %d\n", series, window.inSum/n*rateScale, ts)
+	fmt.Fprintf(&out, "mikrotik_router_wan_in_rate_peak{%s} %.2f %d\n", series, window.inPeak*rateScale, ts)
+	fmt.Fprintf(&out, "mikrotik_router_wan_out_rate_avg{%s} %.2f %d\n", series, window.outSum/n*rateScale, ts)
+	fmt.Fprintf(&out, "mikrotik_router_wan_out_rate_peak{%s} %.2f %d\n", series, window.outPeak*rateScale, ts)
+	return out.String()
+}
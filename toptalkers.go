@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Top Talkers (Torch)
+// ============================================================================
+
+// Talker is the traffic of one host on an interface, averaged over a torch run
+// Rates are in the interface perspective (RX = received on the interface, TX = sent).
+type Talker struct {
+	Address string  `json:"address"`
+	RxRate  float64 `json:"rx_rate"` // bytes/s
+	TxRate  float64 `json:"tx_rate"` // bytes/s
+}
+
+// Total returns the combined rate of both directions
+func (t Talker) Total() float64 {
+	return t.RxRate + t.TxRate
+}
+
+// InterfaceTalkers are the busiest sources and destinations seen on one interface
+type InterfaceTalkers struct {
+	Sources      []Talker `json:"sources"`      // By src-address
+	Destinations []Talker `json:"destinations"` // By dst-address
+}
+
+// TopTalkersSnapshot is the result of one collection cycle
+type TopTalkersSnapshot struct {
+	Timestamp  time.Time                    `json:"timestamp"`
+	Duration   time.Duration                `json:"-"` // Torch run length (reported in seconds by the API)
+	Interfaces map[string]*InterfaceTalkers `json:"interfaces"`
+}
+
+// TopTalkersCollector runs /tool/torch on selected interfaces and ranks hosts by rate
+//
+// Torch streams one section per second for the requested duration; each row is
+// a src/dst address pair with its current rx/tx rate in bits/s. The rates of a
+// host are summed per section and averaged over the sections, so a host that
+// only talked for part of the run is ranked by its average over the run.
+type TopTalkersCollector struct {
+	client RouterClient
+	config *TopTalkersConfig
+
+	latest   *TopTalkersSnapshot
+	latestMu sync.RWMutex
+}
+
+// NewTopTalkersCollector creates a new top talkers collector
+func NewTopTalkersCollector(client RouterClient, config *TopTalkersConfig) *TopTalkersCollector {
+	logInfo("TopTalkers", "Top talkers collector initialized (interfaces: %v, interval: %v, duration: %v)",
+		config.Interfaces, config.Interval, config.Duration)
+
+	return &TopTalkersCollector{
+		client: client,
+		config: config,
+	}
+}
+
+// Collect runs torch on every configured interface and stores the ranking
+func (t *TopTalkersCollector) Collect(ctx context.Context) error {
+	duration := fmt.Sprintf("=duration=%ds", int(t.config.Duration.Seconds()))
+
+	// Torch runs are pipelined, so all interfaces are sampled over the same period
+	commands := make([][]string, len(t.config.Interfaces))
+	for i, name := range t.config.Interfaces {
+		commands[i] = []string{
+			"/tool/torch",
+			"=interface=" + name,
+			"=src-address=0.0.0.0/0",
+			"=dst-address=0.0.0.0/0",
+			"=src-address6=::/0",
+			"=dst-address6=::/0",
+			duration,
+		}
+	}
+	results, errs := RunAll(ctx, t.client, commands...)
+
+	snapshot := &TopTalkersSnapshot{
+		Timestamp:  time.Now(),
+		Duration:   t.config.Duration,
+		Interfaces: make(map[string]*InterfaceTalkers),
+	}
+	var firstErr error
+	for i, name := range t.config.Interfaces {
+		if errs[i] != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("torch %s: %w", name, errs[i])
+			}
+			continue
+		}
+		snapshot.Interfaces[name] = rankTalkers(results[i], t.config.Limit)
+	}
+
+	// Keep the previous ranking if every interface failed
+	if len(snapshot.Interfaces) > 0 {
+		t.latestMu.Lock()
+		t.latest = snapshot
+		t.latestMu.Unlock()
+	}
+
+	return firstErr
+}
+
+// Latest returns the most recent snapshot (nil before the first collection)
+func (t *TopTalkersCollector) Latest() *TopTalkersSnapshot {
+	t.latestMu.RLock()
+	defer t.latestMu.RUnlock()
+	return t.latest
+}
+
+// rankTalkers aggregates torch rows by source and destination address and keeps the top limit of each
+func rankTalkers(rows []map[string]string, limit int) *InterfaceTalkers {
+	sources := make(map[string]*Talker)
+	destinations := make(map[string]*Talker)
+	sections := make(map[string]bool)
+
+	for _, row := range rows {
+		sections[row[".section"]] = true
+
+		// Rates are bits/s; IPv6 rows carry the *-address6 properties
+		rx, _ := strconv.ParseFloat(row["rx"], 64)
+		tx, _ := strconv.ParseFloat(row["tx"], 64)
+		addTalker(sources, torchAddress(row, "src-address"), rx/8, tx/8)
+		addTalker(destinations, torchAddress(row, "dst-address"), rx/8, tx/8)
+	}
+
+	// Average over the torch sections (one per second)
+	n := float64(len(sections))
+	return &InterfaceTalkers{
+		Sources:      topTalkers(sources, n, limit),
+		Destinations: topTalkers(destinations, n, limit),
+	}
+}
+
+// torchAddress returns the IPv4 or IPv6 address of a torch row ("" if neither is set)
+func torchAddress(row map[string]string, key string) string {
+	if address := row[key]; address != "" {
+		return address
+	}
+	return row[key+"6"]
+}
+
+// addTalker adds rates to the talker of an address
+func addTalker(talkers map[string]*Talker, address string, rx, tx float64) {
+	if address == "" {
+		return
+	}
+	talker, ok := talkers[address]
+	if !ok {
+		talker = &Talker{Address: address}
+		talkers[address] = talker
+	}
+	talker.RxRate += rx
+	talker.TxRate += tx
+}
+
+// topTalkers averages the summed rates over n sections and returns the busiest limit talkers
+func topTalkers(talkers map[string]*Talker, n float64, limit int) []Talker {
+	ranked := make([]Talker, 0, len(talkers))
+	for _, talker := range talkers {
+		ranked = append(ranked, Talker{
+			Address: talker.Address,
+			RxRate:  talker.RxRate / n,
+			TxRate:  talker.TxRate / n,
+		})
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Total() != ranked[j].Total() {
+			return ranked[i].Total() > ranked[j].Total()
+		}
+		return ranked[i].Address < ranked[j].Address
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
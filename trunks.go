@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// VLAN Trunks (per-parent utilization of the monitored VLANs)
+// ============================================================================
+
+// TrunkVLAN is the traffic of one monitored VLAN on its trunk (bytes/s)
+type TrunkVLAN struct {
+	Interface string  `json:"interface"`
+	VLANID    int     `json:"vlan_id"`
+	RxRate    float64 `json:"rx_rate"`
+	TxRate    float64 `json:"tx_rate"`
+}
+
+// TrunkStatus is the sum of the monitored VLANs of one parent interface
+type TrunkStatus struct {
+	Trunk         string      `json:"trunk"`
+	LinkSpeed     float64     `json:"link_speed"` // bits/s (0 = unknown)
+	RxRate        float64     `json:"rx_rate"`
+	TxRate        float64     `json:"tx_rate"`
+	RxUtilization float64     `json:"rx_utilization"` // 0-1 (0 if the speed is unknown)
+	TxUtilization float64     `json:"tx_utilization"`
+	VLANs         []TrunkVLAN `json:"vlans"` // By VLAN ID
+}
+
+// TrunkSnapshot is the trunk view returned by /api/trunks
+type TrunkSnapshot struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Trunks    []TrunkStatus `json:"trunks"` // By name
+}
+
+// trunkVLAN is the parent and ID of one VLAN interface
+type trunkVLAN struct {
+	parent string
+	id     int
+}
+
+// trunkWindow accumulates one trunk between two VM pushes
+type trunkWindow struct {
+	rxSum, txSum   float64
+	rxPeak, txPeak float64
+	linkSpeed      float64     // Latest speed (bits/s)
+	vlans          []TrunkVLAN // Latest VLANs (for mikrotik_interface_vlan_info)
+	samples        int
+}
+
+// TrunkTracker sums the monitored VLANs per parent interface
+//
+// The parent and VLAN ID of every VLAN interface come from /interface/vlan
+// (rediscovered every TRUNKS_INTERVAL). Only VLANs that are sampled count, so
+// the sum is the traffic of the monitored VLANs, not of the whole port; the
+// trunk's utilization uses the parent's link speed, which the link speed
+// collector queries even when the parent itself is not monitored.
+type TrunkTracker struct {
+	client RouterClient
+	config *TrunksConfig
+	speed  func(string) float64 // Link speed in bits/s (nil without LINK_SPEED_ENABLED)
+
+	vlans   map[string]trunkVLAN // VLAN interface -> parent and ID (all VLANs of the router)
+	latest  *TrunkSnapshot
+	windows map[string]*trunkWindow // Trunk -> window
+	mu      sync.Mutex
+}
+
+// NewTrunkTracker creates the trunk tracker; speed may be nil
+func NewTrunkTracker(client RouterClient, config *TrunksConfig, speed func(string) float64) *TrunkTracker {
+	logInfo("Trunks", "VLAN trunk tracker initialized (interval: %v)", config.Interval)
+	return &TrunkTracker{
+		client:  client,
+		config:  config,
+		speed:   speed,
+		vlans:   make(map[string]trunkVLAN),
+		windows: make(map[string]*trunkWindow),
+	}
+}
+
+// Collect reads the parent and VLAN ID of every VLAN interface
+func (t *TrunkTracker) Collect(ctx context.Context) error {
+	rows, err := t.client.Run(ctx, "/interface/vlan/print", "=.proplist=name,interface,vlan-id")
+	if err != nil {
+		return fmt.Errorf("vlan interfaces: %w", err)
+	}
+
+	vlans := make(map[string]trunkVLAN, len(rows))
+	for _, row := range rows {
+		if row["name"] == "" || row["interface"] == "" {
+			continue
+		}
+		id, _ := strconv.Atoi(row["vlan-id"])
+		vlans[row["name"]] = trunkVLAN{parent: row["interface"], id: id}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if reflect.DeepEqual(vlans, t.vlans) {
+		return nil
+	}
+	parents := make(map[string]bool)
+	for _, vlan := range vlans {
+		parents[vlan.parent] = true
+	}
+	logInfo("Trunks", "%d VLANs on %d parent interfaces", len(vlans), len(parents))
+	t.vlans = vlans
+	return nil
+}
+
+// Add records one sample of every interface
+func (t *TrunkTracker) Add(now time.Time, stats map[string]*RateInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	trunks := make(map[string]*TrunkStatus)
+	for name, info := range stats {
+		vlan, ok := t.vlans[name]
+		if !ok {
+			continue
+		}
+		trunk := trunks[vlan.parent]
+		if trunk == nil {
+			trunk = &TrunkStatus{Trunk: vlan.parent}
+			trunks[vlan.parent] = trunk
+		}
+		trunk.VLANs = append(trunk.VLANs, TrunkVLAN{Interface: name, VLANID: vlan.id, RxRate: info.RxRate, TxRate: info.TxRate})
+		trunk.RxRate += info.RxRate
+		trunk.TxRate += info.TxRate
+	}
+
+	snapshot := &TrunkSnapshot{Timestamp: now, Trunks: make([]TrunkStatus, 0, len(trunks))}
+	for _, trunk := range trunks {
+		sort.Slice(trunk.VLANs, func(i, j int) bool {
+			if trunk.VLANs[i].VLANID != trunk.VLANs[j].VLANID {
+				return trunk.VLANs[i].VLANID < trunk.VLANs[j].VLANID
+			}
+			return trunk.VLANs[i].Interface < trunk.VLANs[j].Interface
+		})
+		if t.speed != nil {
+			trunk.LinkSpeed = t.speed(trunk.Trunk)
+		}
+		trunk.RxUtilization = utilization(trunk.RxRate, trunk.LinkSpeed)
+		trunk.TxUtilization = utilization(trunk.TxRate, trunk.LinkSpeed)
+		snapshot.Trunks = append(snapshot.Trunks, *trunk)
+
+		window := t.windows[trunk.Trunk]
+		if window == nil {
+			window = &trunkWindow{}
+			t.windows[trunk.Trunk] = window
+		}
+		window.rxSum += trunk.RxRate
+		window.txSum += trunk.TxRate
+		window.rxPeak = max(window.rxPeak, trunk.RxRate)
+		window.txPeak = max(window.txPeak, trunk.TxRate)
+		window.linkSpeed = trunk.LinkSpeed
+		window.vlans = trunk.VLANs
+		window.samples++
+	}
+	sort.Slice(snapshot.Trunks, func(i, j int) bool { return snapshot.Trunks[i].Trunk < snapshot.Trunks[j].Trunk })
+	t.latest = snapshot
+}
+
+// Latest returns the most recent trunk view (nil before the first sample)
+func (t *TrunkTracker) Latest() *TrunkSnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.latest
+}
+
+// Trunks returns the parents of the sampled VLANs (queried by the link speed collector)
+func (t *TrunkTracker) Trunks() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.latest == nil {
+		return nil
+	}
+	names := make([]string, len(t.latest.Trunks))
+	for i, trunk := range t.latest.Trunks {
+		names[i] = trunk.Trunk
+	}
+	return names
+}
+
+// flushWindow returns the trunks accumulated since the last call and starts new windows
+func (t *TrunkTracker) flushWindow() map[string]trunkWindow {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	windows := make(map[string]trunkWindow, len(t.windows))
+	for name, window := range t.windows {
+		windows[name] = *window
+	}
+	t.windows = make(map[string]*trunkWindow)
+	return windows
+}
+
+// trunkMetrics renders the current trunk view in Prometheus text format (for /metrics)
+// Rates are multiplied by rateScale; utilization and link speed only for trunks with a known speed.
+func trunkMetrics(snapshot *TrunkSnapshot, rateScale float64) string {
+	var rx, tx, speed, rxUtil, txUtil, info strings.Builder
+	for _, trunk := range snapshot.Trunks {
+		series := fmt.Sprintf("trunk=\"%s\"", escapeLabelValue(trunk.Trunk))
+		fmt.Fprintf(&rx, "mikrotik_trunk_rx_rate{%s} %.2f\n", series, trunk.RxRate*rateScale)
+		fmt.Fprintf(&tx, "mikrotik_trunk_tx_rate{%s} %.2f\n", series, trunk.TxRate*rateScale)
+		if trunk.LinkSpeed > 0 {
+			fmt.Fprintf(&speed, "mikrotik_trunk_link_speed_bits{%s} %.0f\n", series, trunk.LinkSpeed)
+			fmt.Fprintf(&rxUtil, "mikrotik_trunk_rx_utilization_ratio{%s} %.4f\n", series, trunk.RxUtilization)
+			fmt.Fprintf(&txUtil, "mikrotik_trunk_tx_utilization_ratio{%s} %.4f\n", series, trunk.TxUtilization)
+		}
+		for _, vlan := range trunk.VLANs {
+			fmt.Fprintf(&info, "mikrotik_interface_vlan_info{interface=\"%s\",%s,vlan_id=\"%d\"} 1\n",
+				escapeLabelValue(vlan.Interface), series, vlan.VLANID)
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintln(&out, "# HELP mikrotik_trunk_rx_rate Receive rate summed over the monitored VLANs of the trunk per second")
+	fmt.Fprintln(&out, "# TYPE mikrotik_trunk_rx_rate gauge")
+	out.WriteString(rx.String())
+	fmt.Fprintln(&out, "# HELP mikrotik_trunk_tx_rate Transmit rate summed over the monitored VLANs of the trunk per second")
+	fmt.Fprintln(&out, "# TYPE mikrotik_trunk_tx_rate gauge")
+	out.WriteString(tx.String())
+	if speed.Len() > 0 {
+		fmt.Fprintln(&out, "# HELP mikrotik_trunk_link_speed_bits Link speed of the trunk in bits per second")
+		fmt.Fprintln(&out, "# TYPE mikrotik_trunk_link_speed_bits gauge")
+		out.WriteString(speed.String())
+		fmt.Fprintln(&out, "# HELP mikrotik_trunk_rx_utilization_ratio Receive rate of the trunk's VLANs as a fraction of its link speed")
+		fmt.Fprintln(&out, "# TYPE mikrotik_trunk_rx_utilization_ratio gauge")
+		out.WriteString(rxUtil.String())
+		fmt.Fprintln(&out, "# HELP mikrotik_trunk_tx_utilization_ratio Transmit rate of the trunk's VLANs as a fraction of its link speed")
+		fmt.Fprintln(&out, "# TYPE mikrotik_trunk_tx_utilization_ratio gauge")
+		out.WriteString(txUtil.String())
+	}
+	fmt.Fprintln(&out, "# HELP mikrotik_interface_vlan_info Parent interface and VLAN ID of a monitored VLAN (always 1)")
+	fmt.Fprintln(&out, "# TYPE mikrotik_interface_vlan_info gauge")
+	out.WriteString(info.String())
+	return out.String()
+}
+
+// trunkWindowMetrics renders a VM window of every trunk (average, peak and average utilization)
+// with the VLAN mapping as mikrotik_interface_vlan_info, for joining the interface series by trunk
+func trunkWindowMetrics(windows map[string]trunkWindow, interval time.Duration, rateScale float64, timestamp time.Time) string {
+	names := make([]string, 0, len(windows))
+	for name := range windows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ts := timestamp.UnixMilli()
+	var out strings.Builder
+	for _, name := range names {
+		window := windows[name]
+		if window.samples == 0 {
+			continue
+		}
+		series := fmt.Sprintf("trunk=\"%s\",interval=\"%s\"", escapeLabelValue(name), intervalLabel(interval))
+		n := float64(window.samples)
+		rxAvg, txAvg := window.rxSum/n, window.txSum/n
+
+		fmt.Fprintf(&out, "mikrotik_trunk_rx_rate_avg{%s} %.2f %d\n", series, rxAvg*rateScale, ts)
+		fmt.Fprintf(&out, "mikrotik_trunk_rx_rate_peak{%s} %.2f %d\n", series, window.rxPeak*rateScale, ts)
+		fmt.Fprintf(&out, "mikrotik_trunk_tx_rate_avg{%s} %.2f %d\n", series, txAvg*rateScale, ts)
+		fmt.Fprintf(&out, "mikrotik_trunk_tx_rate_peak{%s} %.2f %d\n", series, window.txPeak*rateScale, ts)
+		if window.linkSpeed > 0 {
+			fmt.Fprintf(&out, "mikrotik_trunk_rx_utilization_ratio{%s} %.4f %d\n", series, utilization(rxAvg, window.linkSpeed), ts)
+			fmt.Fprintf(&out, "mikrotik_trunk_tx_utilization_ratio{%s} %.4f %d\n", series, utilization(txAvg, window.linkSpeed), ts)
+		}
+		for _, vlan := range window.vlans {
+			fmt.Fprintf(&out, "mikrotik_interface_vlan_info{interface=\"%s\",trunk=\"%s\",vlan_id=\"%d\"} 1 %d\n",
+				escapeLabelValue(vlan.Interface), escapeLabelValue(name), vlan.VLANID, ts)
+		}
+	}
+	return out.String()
+}
@@ -1,9 +1,9 @@
 package main
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"sync"
@@ -11,53 +11,68 @@ import (
 
 // UserConfig holds user-customizable settings
 type UserConfig struct {
-	InterfaceLabels map[string]string `json:"interface_labels"` // Interface name -> Custom label
-	mu              sync.RWMutex      `json:"-"`
+	InterfaceLabels  map[string]string    `json:"interface_labels"`           // Interface name -> Custom label
+	UplinkInterfaces []string             `json:"uplink_interfaces"`          // Uplink interfaces (null = use UPLINK_INTERFACES)
+	ConfigOverrides  *ConfigOverrides     `json:"config_overrides,omitempty"` // Runtime settings changed via the admin API
+	WebUsers         map[string]*WebUser  `json:"web_users,omitempty"`        // Interface-restricted web accounts (user name -> account)
+	APITokens        map[string]*APIToken `json:"api_tokens,omitempty"`       // Scoped API tokens (token ID -> token)
+	mu               sync.RWMutex         `json:"-"`
 }
 
 // UserConfigManager manages user configuration persistence
+// Also owns the uplink/downlink classification shared by all outputs,
+// so changes made through the web UI take effect without a restart
 type UserConfigManager struct {
-	config   *UserConfig
-	filePath string
-	mu       sync.RWMutex
+	config         *UserConfig
+	filePath       string // Empty = in-memory only (persistence disabled)
+	defaultUplinks []string
+	mu             sync.RWMutex
 }
 
 const (
-	defaultDataDir      = "data"
-	userConfigFileName  = "config.json"
+	defaultDataDir     = "data"
+	userConfigFileName = "config.json"
 )
 
 // NewUserConfigManager creates a new user configuration manager
-func NewUserConfigManager() (*UserConfigManager, error) {
+// defaultUplinks (from UPLINK_INTERFACES) apply until uplinks are changed via the API
+func NewUserConfigManager(defaultUplinks []string) (*UserConfigManager, error) {
+	manager := newMemoryUserConfigManager(defaultUplinks)
+
 	// Ensure data directory exists
 	if err := os.MkdirAll(defaultDataDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
 	configPath := filepath.Join(defaultDataDir, userConfigFileName)
-
-	manager := &UserConfigManager{
-		filePath: configPath,
-		config: &UserConfig{
-			InterfaceLabels: make(map[string]string),
-		},
-	}
+	manager.filePath = configPath
 
 	// Load existing config if present
 	if err := manager.Load(); err != nil {
 		if !os.IsNotExist(err) {
-			log.Printf("[UserConfig] Warning: Failed to load config: %v", err)
+			logWarn("UserConfig", "Failed to load config: %v", err)
 		}
 		// Save default config
 		if err := manager.Save(); err != nil {
-			log.Printf("[UserConfig] Warning: Failed to save default config: %v", err)
+			logWarn("UserConfig", "Failed to save default config: %v", err)
 		}
 	}
 
-	log.Printf("[UserConfig] Loaded user configuration from: %s", configPath)
+	logInfo("UserConfig", "Loaded user configuration from: %s", configPath)
 	return manager, nil
 }
 
+// newMemoryUserConfigManager creates a manager that is never persisted
+// Used as a fallback when the data directory is not writable
+func newMemoryUserConfigManager(defaultUplinks []string) *UserConfigManager {
+	return &UserConfigManager{
+		defaultUplinks: defaultUplinks,
+		config: &UserConfig{
+			InterfaceLabels: make(map[string]string),
+		},
+	}
+}
+
 // Load reads configuration from disk
 func (m *UserConfigManager) Load() error {
 	m.mu.Lock()
@@ -68,6 +83,8 @@ func (m *UserConfigManager) Load() error {
 		return err
 	}
 
+	m.config.mu.Lock()
+	defer m.config.mu.Unlock()
 	return json.Unmarshal(data, m.config)
 }
 
@@ -76,7 +93,13 @@ func (m *UserConfigManager) Save() error {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	if m.filePath == "" {
+		return nil // In-memory only
+	}
+
+	m.config.mu.RLock()
 	data, err := json.MarshalIndent(m.config, "", "  ")
+	m.config.mu.RUnlock()
 	if err != nil {
 		return fmt.Errorf("marshal config: %w", err)
 	}
@@ -95,6 +118,17 @@ func (m *UserConfigManager) GetInterfaceLabel(interfaceName string) string {
 	return interfaceName // Return original name if no label set
 }
 
+// CustomLabel returns the label set for an interface, or "" if none (nil-safe)
+func (m *UserConfigManager) CustomLabel(interfaceName string) string {
+	if m == nil {
+		return ""
+	}
+	if label := m.GetInterfaceLabel(interfaceName); label != interfaceName {
+		return label
+	}
+	return ""
+}
+
 // SetInterfaceLabel sets custom label for an interface
 func (m *UserConfigManager) SetInterfaceLabel(interfaceName, label string) error {
 	m.config.mu.Lock()
@@ -127,3 +161,197 @@ func (m *UserConfigManager) UpdateInterfaceLabels(labels map[string]string) erro
 
 	return m.Save()
 }
+
+// IsUplink reports whether an interface is classified as uplink (TX=Upload, RX=Download)
+func (m *UserConfigManager) IsUplink(interfaceName string) bool {
+	m.config.mu.RLock()
+	defer m.config.mu.RUnlock()
+
+	uplinks := m.config.UplinkInterfaces
+	if uplinks == nil {
+		uplinks = m.defaultUplinks
+	}
+	for _, iface := range uplinks {
+		if iface == interfaceName {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUplinkInterfaces returns the current uplink interface list
+func (m *UserConfigManager) GetUplinkInterfaces() []string {
+	m.config.mu.RLock()
+	defer m.config.mu.RUnlock()
+
+	uplinks := m.config.UplinkInterfaces
+	if uplinks == nil {
+		uplinks = m.defaultUplinks
+	}
+	return append([]string{}, uplinks...)
+}
+
+// SetDefaultUplinks replaces the UPLINK_INTERFACES default (configuration reload)
+// Uplinks set via the API keep precedence.
+func (m *UserConfigManager) SetDefaultUplinks(uplinks []string) {
+	m.config.mu.Lock()
+	defer m.config.mu.Unlock()
+	m.defaultUplinks = uplinks
+}
+
+// ConfigOverrides returns a copy of the runtime configuration overrides (nil-safe)
+func (m *UserConfigManager) ConfigOverrides() ConfigOverrides {
+	if m == nil {
+		return ConfigOverrides{}
+	}
+	m.config.mu.RLock()
+	defer m.config.mu.RUnlock()
+
+	if m.config.ConfigOverrides == nil {
+		return ConfigOverrides{}
+	}
+	overrides := *m.config.ConfigOverrides
+	overrides.Interfaces = append([]string(nil), overrides.Interfaces...)
+	if overrides.Outputs != nil {
+		outputs := make(map[string]bool, len(overrides.Outputs))
+		for name, enabled := range overrides.Outputs {
+			outputs[name] = enabled
+		}
+		overrides.Outputs = outputs
+	}
+	return overrides
+}
+
+// SetConfigOverrides replaces the runtime configuration overrides and persists them
+func (m *UserConfigManager) SetConfigOverrides(overrides ConfigOverrides) error {
+	m.config.mu.Lock()
+	if overrides.IsZero() {
+		m.config.ConfigOverrides = nil
+	} else {
+		m.config.ConfigOverrides = &overrides
+	}
+	m.config.mu.Unlock()
+
+	return m.Save()
+}
+
+// SetUplinkInterfaces replaces the uplink interface list and persists it
+// A nil list reverts to the UPLINK_INTERFACES default
+func (m *UserConfigManager) SetUplinkInterfaces(uplinks []string) error {
+	m.config.mu.Lock()
+	if uplinks == nil {
+		m.config.UplinkInterfaces = nil
+	} else {
+		m.config.UplinkInterfaces = append([]string{}, uplinks...)
+	}
+	m.config.mu.Unlock()
+
+	return m.Save()
+}
+
+// WebUser returns a copy of a web account (nil-safe)
+func (m *UserConfigManager) WebUser(name string) (WebUser, bool) {
+	if m == nil {
+		return WebUser{}, false
+	}
+	m.config.mu.RLock()
+	defer m.config.mu.RUnlock()
+
+	user, ok := m.config.WebUsers[name]
+	if !ok {
+		return WebUser{}, false
+	}
+	return user.clone(), true
+}
+
+// WebUsers returns a copy of all web accounts
+func (m *UserConfigManager) WebUsers() map[string]WebUser {
+	m.config.mu.RLock()
+	defer m.config.mu.RUnlock()
+
+	users := make(map[string]WebUser, len(m.config.WebUsers))
+	for name, user := range m.config.WebUsers {
+		users[name] = user.clone()
+	}
+	return users
+}
+
+// SetWebUser creates or replaces a web account and persists it
+func (m *UserConfigManager) SetWebUser(name string, user WebUser) error {
+	m.config.mu.Lock()
+	if m.config.WebUsers == nil {
+		m.config.WebUsers = make(map[string]*WebUser)
+	}
+	user = user.clone()
+	m.config.WebUsers[name] = &user
+	m.config.mu.Unlock()
+
+	return m.Save()
+}
+
+// DeleteWebUser removes a web account and persists the change (false if it did not exist)
+func (m *UserConfigManager) DeleteWebUser(name string) (bool, error) {
+	m.config.mu.Lock()
+	_, ok := m.config.WebUsers[name]
+	delete(m.config.WebUsers, name)
+	m.config.mu.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	return true, m.Save()
+}
+
+// APITokens returns a copy of all API tokens
+func (m *UserConfigManager) APITokens() map[string]APIToken {
+	m.config.mu.RLock()
+	defer m.config.mu.RUnlock()
+
+	tokens := make(map[string]APIToken, len(m.config.APITokens))
+	for id, token := range m.config.APITokens {
+		tokens[id] = token.clone()
+	}
+	return tokens
+}
+
+// FindAPIToken returns the API token with a secret hash (nil-safe)
+func (m *UserConfigManager) FindAPIToken(hash string) (string, APIToken, bool) {
+	if m == nil {
+		return "", APIToken{}, false
+	}
+	m.config.mu.RLock()
+	defer m.config.mu.RUnlock()
+
+	for id, token := range m.config.APITokens {
+		if subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hash)) == 1 {
+			return id, token.clone(), true
+		}
+	}
+	return "", APIToken{}, false
+}
+
+// SetAPIToken stores an API token and persists it
+func (m *UserConfigManager) SetAPIToken(id string, token APIToken) error {
+	m.config.mu.Lock()
+	if m.config.APITokens == nil {
+		m.config.APITokens = make(map[string]*APIToken)
+	}
+	token = token.clone()
+	m.config.APITokens[id] = &token
+	m.config.mu.Unlock()
+
+	return m.Save()
+}
+
+// DeleteAPIToken revokes an API token and persists the change (false if it did not exist)
+func (m *UserConfigManager) DeleteAPIToken(id string) (bool, error) {
+	m.config.mu.Lock()
+	_, ok := m.config.APITokens[id]
+	delete(m.config.APITokens, id)
+	m.config.mu.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	return true, m.Save()
+}
@@ -5,8 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"math"
 	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,61 +20,179 @@ import (
 // ============================================================================
 
 // VMClient handles pushing metrics to VictoriaMetrics
+// Pushes are queued and delivered by a background worker (see vm_queue.go)
 type VMClient struct {
 	config     *VMConfig
 	httpClient *http.Client
+	queue      *vmQueue
+	closeOnce  sync.Once
+
+	extraLabels   string             // Static labels added to every pushed series (`router="core1",site="dc1"`)
+	extraMatchers string             // Same labels as query matchers (`,router="core1",site="dc1"`)
+	userConfig    *UserConfigManager // Interface labels added as label="..." (nil = none)
+	metricPrefix  string             // Metric name prefix replacing "mikrotik_" (METRIC_PREFIX)
+	rateScale     float64            // Multiplier from bytes/s to the metric unit (8 for METRIC_UNIT=bits)
+	retention     *RetentionConfig   // Rollup tier read past the raw data horizon (nil = windows only)
+
+	// Held exclusively while the retention job rewrites a series, so pushes wait
+	pushMu sync.RWMutex
+
+	// Delivery outcome for health probes
+	lastPush      time.Time // Last successful delivery
+	lastPushError string    // Error of the last failed delivery (cleared on success)
+	pushFailures  int64     // Failed delivery attempts (including retries)
+	statusMu      sync.RWMutex
+}
+
+// VMPushStatus is the VictoriaMetrics delivery state reported by /healthz and /readyz
+type VMPushStatus struct {
+	LastPush       *time.Time `json:"last_push,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	QueueLength    int        `json:"queue_length"`
+	DroppedBatches uint64     `json:"dropped_batches"`
+	PushFailures   int64      `json:"push_failures"`
 }
 
 // NewVMClient creates a new VictoriaMetrics client
-func NewVMClient(config *VMConfig) *VMClient {
-	log.Printf("[VM] VictoriaMetrics client initialized (URL: %s)", config.URL)
-	log.Printf("[VM] Data collection interval: %v", config.Interval)
+// extraLabels are attached to every pushed series and used to scope history queries
+func NewVMClient(config *VMConfig, extraLabels map[string]string) *VMClient {
+	logInfo("VM", "VictoriaMetrics client initialized (URL: %s)", config.URL)
+	logInfo("VM", "Data collection interval: %v", config.Interval)
+	if config.Protocol == "remote_write" {
+		logInfo("VM", "Push protocol: remote_write (%s)", config.RemoteWriteURL)
+	} else if config.Compression != "none" {
+		logInfo("VM", "Push compression: %s", config.Compression)
+	}
 
-	return &VMClient{
+	client := &VMClient{
 		config: config,
 		httpClient: &http.Client{
 			Timeout: config.Timeout,
 		},
+		queue:        newVMQueue(config.QueueSize, config.BatchSize),
+		extraLabels:  formatExtraLabels(extraLabels),
+		metricPrefix: defaultMetricPrefix,
+		rateScale:    1,
+	}
+	if client.extraLabels != "" {
+		client.extraMatchers = "," + client.extraLabels
+	}
+
+	// Optional disk spool for outages (replayed with original timestamps)
+	if config.SpoolDir != "" {
+		spool, err := newVMSpool(config.SpoolDir, config.SpoolMaxBytes)
+		if err != nil {
+			logWarn("VM", "Disk spool disabled: %v", err)
+		} else {
+			client.queue.spool = spool
+			logInfo("VM", "Disk spool enabled: %s (max %d MB)", config.SpoolDir, config.SpoolMaxBytes>>20)
+		}
 	}
+
+	go client.queue.run(client.deliverBatch, config.RetryCount)
+
+	return client
 }
 
-// SendMetrics sends aggregated metrics to VictoriaMetrics using Prometheus format
-func (c *VMClient) SendMetrics(window *AggregationWindow) error {
-	if window == nil || len(window.Interfaces) == 0 {
-		return nil
+// Close stops the delivery worker after one final attempt for queued batches
+func (c *VMClient) Close() {
+	c.closeOnce.Do(func() {
+		close(c.queue.stop)
+		<-c.queue.done
+	})
+}
+
+// QueueStats returns the number of queued batches and total dropped batches
+func (c *VMClient) QueueStats() (queued int, dropped uint64) {
+	return c.queue.stats()
+}
+
+// PushStatus returns the delivery state of the background worker
+func (c *VMClient) PushStatus() VMPushStatus {
+	queued, dropped := c.queue.stats()
+	status := VMPushStatus{QueueLength: queued, DroppedBatches: dropped}
+
+	c.statusMu.RLock()
+	defer c.statusMu.RUnlock()
+	if !c.lastPush.IsZero() {
+		last := c.lastPush
+		status.LastPush = &last
 	}
+	status.LastError = c.lastPushError
+	status.PushFailures = c.pushFailures
+	return status
+}
 
-	// Generate Prometheus-format metrics
-	metrics := c.generatePrometheusMetrics(window)
-	if len(metrics) == 0 {
-		return nil
+// SendTelemetryMetrics queues the monitor's own metrics (already in Prometheus format)
+func (c *VMClient) SendTelemetryMetrics(metrics string, timestamp time.Time) error {
+	c.enqueue(metrics, timestamp, "telemetry metrics")
+	return nil
+}
+
+// enqueue schedules a payload for background delivery (never blocks)
+func (c *VMClient) enqueue(metrics string, timestamp time.Time, description string) {
+	if metrics == "" {
+		return
 	}
+	metrics = injectLabels(renameMetrics(metrics, c.metricPrefix), c.extraLabels)
+	c.queue.push(&vmBatch{metrics: metrics, timestamp: timestamp, description: description})
+}
 
-	// Send to VictoriaMetrics with retry
-	for attempt := 0; attempt <= c.config.RetryCount; attempt++ {
-		if attempt > 0 {
-			log.Printf("[VM] Retry attempt %d/%d", attempt, c.config.RetryCount)
-			time.Sleep(time.Second * time.Duration(attempt))
-		}
+// deliverBatch sends one queued batch, appending queue gauges so backlog is visible in VM
+func (c *VMClient) deliverBatch(batch *vmBatch) error {
+	queued, dropped := c.queue.stats()
+	now := time.Now().Unix() * 1000 // Milliseconds
+	metrics := batch.metrics + injectLabels(renameMetrics(fmt.Sprintf(
+		"mikrotik_monitor_vm_queue_length %d %d\nmikrotik_monitor_vm_dropped_batches_total %d %d\n",
+		queued, now, dropped, now), c.metricPrefix), c.extraLabels)
 
-		err := c.sendToVM(metrics, window.EndTime)
-		if err == nil {
-			log.Printf("[VM] Successfully sent metrics for window [%s, %s) - %d interfaces",
-				window.StartTime.Format("15:04:05"),
-				window.EndTime.Format("15:04:05"),
-				len(window.Interfaces),
-			)
-			return nil
-		}
+	c.pushMu.RLock()
+	err := c.sendToVM(metrics, batch.timestamp)
+	c.pushMu.RUnlock()
 
-		log.Printf("[VM] Error sending metrics (attempt %d): %v", attempt+1, err)
+	c.statusMu.Lock()
+	if err != nil {
+		c.lastPushError = err.Error()
+		c.pushFailures++
+	} else {
+		c.lastPush = time.Now()
+		c.lastPushError = ""
 	}
+	c.statusMu.Unlock()
 
-	return fmt.Errorf("failed after %d retries", c.config.RetryCount)
+	if err != nil {
+		return err
+	}
+
+	logDebug("VM", "Successfully sent %s", batch.description)
+	return nil
+}
+
+// SendMetrics queues aggregated metrics for VictoriaMetrics using Prometheus format
+func (c *VMClient) SendMetrics(window *AggregationWindow) error {
+	if window == nil || len(window.Interfaces) == 0 {
+		return nil
+	}
+
+	// Generate Prometheus-format metrics
+	metrics := c.generatePrometheusMetrics(window)
+	c.enqueue(metrics, window.EndTime, fmt.Sprintf("window [%s, %s) - %d interfaces",
+		window.StartTime.Format("15:04:05"),
+		window.EndTime.Format("15:04:05"),
+		len(window.Interfaces),
+	))
+	return nil
 }
 
 // generatePrometheusMetrics converts aggregation window to Prometheus format
 func (c *VMClient) generatePrometheusMetrics(window *AggregationWindow) string {
+	return windowMetrics(window, c.rateScale, c.userConfig)
+}
+
+// windowMetrics converts an aggregation window to Prometheus text format with timestamps
+// Rates are multiplied by scale; userConfig adds interface labels (nil = none).
+// Shared by the VictoriaMetrics and Pushgateway outputs.
+func windowMetrics(window *AggregationWindow, scale float64, userConfig *UserConfigManager) string {
 	var buf bytes.Buffer
 	timestamp := window.EndTime.Unix() * 1000 // Milliseconds
 
@@ -83,45 +205,432 @@ func (c *VMClient) generatePrometheusMetrics(window *AggregationWindow) string {
 		rxAvg := stats.RxSum / float64(stats.Count)
 		txAvg := stats.TxSum / float64(stats.Count)
 
-		// Interface type label
+		// Series labels: interface, window interval and the user-defined label (if any)
 		intervalLabel := fmt.Sprintf("%ds", int(window.Interval.Seconds()))
+		series := fmt.Sprintf("interface=\"%s\",interval=\"%s\"%s", ifaceName, intervalLabel, interfaceLabelPair(userConfig, ifaceName))
+
+		// RX metrics (bytes/second, or bits/second with METRIC_UNIT=bits)
+		buf.WriteString(fmt.Sprintf("mikrotik_interface_rx_rate_avg{%s} %.2f %d\n",
+			series, rxAvg*scale, timestamp))
+		buf.WriteString(fmt.Sprintf("mikrotik_interface_rx_rate_peak{%s} %.2f %d\n",
+			series, stats.RxPeak*scale, timestamp))
+		buf.WriteString(fmt.Sprintf("mikrotik_interface_rx_rate_min{%s} %.2f %d\n",
+			series, stats.RxMin*scale, timestamp))
+
+		// TX metrics (bytes/second, or bits/second with METRIC_UNIT=bits)
+		buf.WriteString(fmt.Sprintf("mikrotik_interface_tx_rate_avg{%s} %.2f %d\n",
+			series, txAvg*scale, timestamp))
+		buf.WriteString(fmt.Sprintf("mikrotik_interface_tx_rate_peak{%s} %.2f %d\n",
+			series, stats.TxPeak*scale, timestamp))
+		buf.WriteString(fmt.Sprintf("mikrotik_interface_tx_rate_min{%s} %.2f %d\n",
+			series, stats.TxMin*scale, timestamp))
+
+		// Standard deviation (same unit as the rates)
+		buf.WriteString(fmt.Sprintf("mikrotik_interface_rx_rate_stddev{%s} %.2f %d\n",
+			series, stddev(stats.RxSum, stats.RxSumSq, stats.Count)*scale, timestamp))
+		buf.WriteString(fmt.Sprintf("mikrotik_interface_tx_rate_stddev{%s} %.2f %d\n",
+			series, stddev(stats.TxSum, stats.TxSumSq, stats.Count)*scale, timestamp))
+
+		// Rate histograms (VM_HISTOGRAM_BUCKETS): samples at most le Mbps, cumulative as in Prometheus
+		if stats.RxHistogram != nil {
+			writeHistogram(&buf, "mikrotik_interface_rx_rate_histogram_bucket", series, window.Buckets, stats.RxHistogram, stats.Count, timestamp)
+			writeHistogram(&buf, "mikrotik_interface_tx_rate_histogram_bucket", series, window.Buckets, stats.TxHistogram, stats.Count, timestamp)
+		}
 
-		// RX metrics (bytes/second)
-		buf.WriteString(fmt.Sprintf("mikrotik_interface_rx_rate_avg{interface=\"%s\",interval=\"%s\"} %.2f %d\n",
-			ifaceName, intervalLabel, rxAvg, timestamp))
-		buf.WriteString(fmt.Sprintf("mikrotik_interface_rx_rate_peak{interface=\"%s\",interval=\"%s\"} %.2f %d\n",
-			ifaceName, intervalLabel, stats.RxPeak, timestamp))
-		buf.WriteString(fmt.Sprintf("mikrotik_interface_rx_rate_min{interface=\"%s\",interval=\"%s\"} %.2f %d\n",
-			ifaceName, intervalLabel, stats.RxMin, timestamp))
-
-		// TX metrics (bytes/second)
-		buf.WriteString(fmt.Sprintf("mikrotik_interface_tx_rate_avg{interface=\"%s\",interval=\"%s\"} %.2f %d\n",
-			ifaceName, intervalLabel, txAvg, timestamp))
-		buf.WriteString(fmt.Sprintf("mikrotik_interface_tx_rate_peak{interface=\"%s\",interval=\"%s\"} %.2f %d\n",
-			ifaceName, intervalLabel, stats.TxPeak, timestamp))
-		buf.WriteString(fmt.Sprintf("mikrotik_interface_tx_rate_min{interface=\"%s\",interval=\"%s\"} %.2f %d\n",
-			ifaceName, intervalLabel, stats.TxMin, timestamp))
+		// Sample count and coverage (fraction of expected samples, < 1 during polling outages)
+		buf.WriteString(fmt.Sprintf("mikrotik_interface_sample_count{%s} %d %d\n",
+			series, stats.Count, timestamp))
+		buf.WriteString(fmt.Sprintf("mikrotik_interface_coverage_ratio{%s} %.3f %d\n",
+			series, window.Coverage(stats), timestamp))
+
+		// Raw counters (bytes) for rate()/increase(); no interval label, they don't depend on the window
+		counterSeries := fmt.Sprintf("interface=\"%s\"%s", ifaceName, interfaceLabelPair(userConfig, ifaceName))
+		buf.WriteString(fmt.Sprintf("mikrotik_interface_rx_bytes_total{%s} %d %d\n",
+			counterSeries, stats.RxBytes, timestamp))
+		buf.WriteString(fmt.Sprintf("mikrotik_interface_tx_bytes_total{%s} %d %d\n",
+			counterSeries, stats.TxBytes, timestamp))
+
+		// Link speed (bits/s) and average utilization over the window (0-1), only when the speed is known
+		if stats.LinkSpeed > 0 {
+			buf.WriteString(fmt.Sprintf("mikrotik_interface_link_speed_bits{%s} %.0f %d\n",
+				counterSeries, stats.LinkSpeed, timestamp))
+			buf.WriteString(fmt.Sprintf("mikrotik_interface_rx_utilization_ratio{%s} %.4f %d\n",
+				series, utilization(rxAvg, stats.LinkSpeed), timestamp))
+			buf.WriteString(fmt.Sprintf("mikrotik_interface_tx_utilization_ratio{%s} %.4f %d\n",
+				series, utilization(txAvg, stats.LinkSpeed), timestamp))
+		}
 
-		// Sample count
-		buf.WriteString(fmt.Sprintf("mikrotik_interface_sample_count{interface=\"%s\",interval=\"%s\"} %d %d\n",
-			ifaceName, intervalLabel, stats.Count, timestamp))
+		// Link state at the end of the window (1 = running and enabled)
+		if stats.State != nil {
+			buf.WriteString(fmt.Sprintf("mikrotik_interface_up{%s} %d %d\n",
+				counterSeries, stats.State.upValue(), timestamp))
+			buf.WriteString(fmt.Sprintf("mikrotik_interface_link_downs_total{%s} %d %d\n",
+				counterSeries, stats.State.LinkDowns, timestamp))
+		}
 	}
 
 	return buf.String()
 }
 
+// writeHistogram writes the cumulative buckets of a rate histogram, ending with le="+Inf" (all samples)
+// Each window carries its own counts, so sum_over_time() of the buckets over any
+// range feeds histogram_quantile() with every sample of that range.
+func writeHistogram(buf *bytes.Buffer, name, series string, bounds []float64, counts []int, total int, timestamp int64) {
+	cumulative := 0
+	for i, bound := range bounds {
+		cumulative += counts[i]
+		buf.WriteString(fmt.Sprintf("%s{%s,le=\"%s\"} %d %d\n",
+			name, series, strconv.FormatFloat(bound, 'f', -1, 64), cumulative, timestamp))
+	}
+	buf.WriteString(fmt.Sprintf("%s{%s,le=\"+Inf\"} %d %d\n", name, series, total, timestamp))
+}
+
+// stddev computes the population standard deviation from running sums
+func stddev(sum, sumSq float64, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	mean := sum / float64(count)
+	variance := sumSq/float64(count) - mean*mean
+	if variance < 0 {
+		variance = 0 // Floating point rounding
+	}
+	return math.Sqrt(variance)
+}
+
+// SendSessionMetrics sends per-session rates and session counts to VictoriaMetrics
+// Sessions are sent as instantaneous samples (no window aggregation)
+func (c *VMClient) SendSessionMetrics(snapshot *SessionSnapshot) error {
+	if snapshot == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	timestamp := snapshot.Timestamp.Unix() * 1000 // Milliseconds
+
+	for sessionType, count := range snapshot.Counts {
+		buf.WriteString(fmt.Sprintf("mikrotik_sessions_active{type=\"%s\"} %d %d\n",
+			sessionType, count, timestamp))
+	}
+
+	for _, session := range snapshot.Sessions {
+		labels := fmt.Sprintf("type=\"%s\",user=\"%s\",address=\"%s\"",
+			session.Type, escapeLabelValue(session.User), escapeLabelValue(session.Address))
+		buf.WriteString(fmt.Sprintf("mikrotik_session_upload_rate{%s} %.2f %d\n",
+			labels, session.UploadRate*c.rateScale, timestamp))
+		buf.WriteString(fmt.Sprintf("mikrotik_session_download_rate{%s} %.2f %d\n",
+			labels, session.DownloadRate*c.rateScale, timestamp))
+	}
+
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	c.enqueue(buf.String(), snapshot.Timestamp, fmt.Sprintf("session metrics (%d sessions)", len(snapshot.Sessions)))
+	return nil
+}
+
+// SendFlowMetrics sends the busiest conversations of a completed flow window to VictoriaMetrics
+// Only the top FLOW_TOP conversations are sent, which bounds the series count.
+func (c *VMClient) SendFlowMetrics(snapshot *FlowSnapshot) error {
+	if snapshot == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	timestamp := snapshot.End.Unix() * 1000 // Milliseconds
+
+	buf.WriteString(fmt.Sprintf("mikrotik_flow_conversations %d %d\n", snapshot.Total, timestamp))
+	for _, conversation := range snapshot.Conversations {
+		labels := fmt.Sprintf("src=\"%s\",dst=\"%s\",protocol=\"%s\"",
+			conversation.Source, conversation.Destination, conversation.Protocol)
+		buf.WriteString(fmt.Sprintf("mikrotik_flow_conversation_rate{%s} %.2f %d\n",
+			labels, conversation.Rate*c.rateScale, timestamp))
+		buf.WriteString(fmt.Sprintf("mikrotik_flow_conversation_packets{%s} %d %d\n",
+			labels, conversation.Packets, timestamp))
+	}
+
+	c.enqueue(buf.String(), snapshot.End, fmt.Sprintf("flow metrics (%d conversations)", len(snapshot.Conversations)))
+	return nil
+}
+
+// SendHealthMetrics sends router health metrics to VictoriaMetrics
+func (c *VMClient) SendHealthMetrics(res *SystemResource) error {
+	if res == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	timestamp := res.Timestamp.Unix() * 1000 // Milliseconds
+
+	buf.WriteString(fmt.Sprintf("mikrotik_system_cpu_load %.0f %d\n", res.CPULoad, timestamp))
+	buf.WriteString(fmt.Sprintf("mikrotik_system_free_memory_bytes %d %d\n", res.FreeMemory, timestamp))
+	buf.WriteString(fmt.Sprintf("mikrotik_system_total_memory_bytes %d %d\n", res.TotalMemory, timestamp))
+	buf.WriteString(fmt.Sprintf("mikrotik_system_uptime_seconds %d %d\n", res.Uptime, timestamp))
+
+	for sensor, value := range res.Temperatures {
+		buf.WriteString(fmt.Sprintf("mikrotik_system_temperature_celsius{sensor=\"%s\"} %.1f %d\n",
+			escapeLabelValue(sensor), value, timestamp))
+	}
+	for sensor, value := range res.Voltages {
+		buf.WriteString(fmt.Sprintf("mikrotik_system_voltage_volts{sensor=\"%s\"} %.1f %d\n",
+			escapeLabelValue(sensor), value, timestamp))
+	}
+
+	c.enqueue(buf.String(), res.Timestamp, "health metrics")
+	return nil
+}
+
+// SendPingMetrics sends latency probe results to VictoriaMetrics
+func (c *VMClient) SendPingMetrics(snapshot *PingSnapshot) error {
+	if snapshot == nil {
+		return nil
+	}
+
+	c.enqueue(pingMetrics(snapshot, true), snapshot.Timestamp, fmt.Sprintf("ping metrics (%d targets)", len(snapshot.Results)))
+	return nil
+}
+
+// SendCAPsMANMetrics sends per-AP/SSID WiFi client counts and rates to VictoriaMetrics
+func (c *VMClient) SendCAPsMANMetrics(snapshot *CAPsMANSnapshot) error {
+	if snapshot == nil || len(snapshot.Groups) == 0 {
+		return nil
+	}
+
+	c.enqueue(capsmanMetrics(snapshot, c.rateScale, true), snapshot.Timestamp, fmt.Sprintf("CAPsMAN metrics (%d groups)", len(snapshot.Groups)))
+	return nil
+}
+
+// SendNetwatchMetrics sends netwatch host states to VictoriaMetrics
+func (c *VMClient) SendNetwatchMetrics(snapshot *NetwatchSnapshot) error {
+	if snapshot == nil {
+		return nil
+	}
+
+	c.enqueue(netwatchMetrics(snapshot, true), snapshot.Timestamp, fmt.Sprintf("netwatch metrics (%d hosts)", len(snapshot.Entries)))
+	return nil
+}
+
+// SendAuditMetrics sends management session counts and login failure counters to VictoriaMetrics
+func (c *VMClient) SendAuditMetrics(snapshot *AuditSnapshot) error {
+	if snapshot == nil {
+		return nil
+	}
+
+	c.enqueue(auditMetrics(snapshot, true), snapshot.Timestamp, fmt.Sprintf("audit metrics (%d sessions)", len(snapshot.Sessions)))
+	return nil
+}
+
+// SendTopologyMetrics sends the router WAN roll-up of one VM interval to VictoriaMetrics
+func (c *VMClient) SendTopologyMetrics(window topologyWindow, timestamp time.Time) error {
+	if window.samples == 0 {
+		return nil
+	}
+
+	c.enqueue(topologyWindowMetrics(window, c.config.Interval, c.rateScale, timestamp), timestamp, "topology metrics")
+	return nil
+}
+
+// SendTrunkMetrics sends the VLAN trunk roll-ups of one VM interval to VictoriaMetrics
+func (c *VMClient) SendTrunkMetrics(windows map[string]trunkWindow, timestamp time.Time) error {
+	if len(windows) == 0 {
+		return nil
+	}
+
+	c.enqueue(trunkWindowMetrics(windows, c.config.Interval, c.rateScale, timestamp), timestamp, fmt.Sprintf("trunk metrics (%d trunks)", len(windows)))
+	return nil
+}
+
+// QuerySiteRouters returns the latest WAN roll-up of the other routers of a site (bytes/s)
+// Routers are the monitors pushing the same site label; exclude is this monitor's router.
+func (c *VMClient) QuerySiteRouters(site, exclude string, now time.Time) map[string]TopologyRouter {
+	// Window series of other monitors may use other VM_INTERVALs; a few windows of this one cover a restart gap
+	lookback := intervalLabel(3 * c.config.Interval)
+	matcher := fmt.Sprintf(`site="%s",router!="%s"`, escapeLabelValue(site), escapeLabelValue(exclude))
+
+	routers := make(map[string]TopologyRouter)
+	for _, direction := range []string{"in", "out"} {
+		query := fmt.Sprintf(`max by (router) (last_over_time(%srouter_wan_%s_rate_avg{%s}[%s]))`, c.metricPrefix, direction, matcher, lookback)
+		for name, value := range c.queryInstantBy(query, "router", now) {
+			router := routers[name]
+			router.Router = name
+			if direction == "in" {
+				router.WANIn = value / c.rateScale
+			} else {
+				router.WANOut = value / c.rateScale
+			}
+			routers[name] = router
+		}
+	}
+	return routers
+}
+
+// SendPercentileMetrics sends per-interface percentile rates to VictoriaMetrics
+// Sent whenever a percentile sample bucket completes
+func (c *VMClient) SendPercentileMetrics(results []PercentileResult, config *PercentileConfig, now time.Time) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	timestamp := now.Unix() * 1000 // Milliseconds
+	pctLabel := strconv.FormatFloat(config.Percentile, 'f', -1, 64)
+
+	for _, result := range results {
+		labels := fmt.Sprintf("interface=\"%s\",percentile=\"%s\",window=\"%s\"%s",
+			result.Interface, pctLabel, config.Window, c.interfaceLabel(result.Interface))
+		buf.WriteString(fmt.Sprintf("mikrotik_interface_rate_percentile{%s,direction=\"rx\"} %.2f %d\n",
+			labels, result.RxRate*c.rateScale, timestamp))
+		buf.WriteString(fmt.Sprintf("mikrotik_interface_rate_percentile{%s,direction=\"tx\"} %.2f %d\n",
+			labels, result.TxRate*c.rateScale, timestamp))
+	}
+
+	c.enqueue(buf.String(), now, fmt.Sprintf("percentile metrics (%d interfaces)", len(results)))
+	return nil
+}
+
+// do sends a request with the configured authentication (push and query paths)
+func (c *VMClient) do(req *http.Request) (*http.Response, error) {
+	c.authorize(req)
+	return c.httpClient.Do(req)
+}
+
+// authorize adds the configured authentication and headers to a request
+func (c *VMClient) authorize(req *http.Request) {
+	if c.config.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.AuthToken)
+	} else if c.config.AuthUser != "" {
+		req.SetBasicAuth(c.config.AuthUser, c.config.AuthPass)
+	}
+	for name, value := range c.config.AuthHeaders {
+		req.Header.Set(name, value)
+	}
+}
+
+// formatExtraLabels renders static labels as a sorted Prometheus label list (without braces)
+func formatExtraLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", name, escapeLabelValue(labels[name])))
+	}
+	return strings.Join(parts, ",")
+}
+
+// interfaceLabel returns the user-defined label of an interface as `,label="..."` (empty if unset)
+func (c *VMClient) interfaceLabel(name string) string {
+	return interfaceLabelPair(c.userConfig, name)
+}
+
+// interfaceLabelPair returns the label of an interface in userConfig as `,label="..."` (empty if unset)
+func interfaceLabelPair(userConfig *UserConfigManager, name string) string {
+	label := userConfig.CustomLabel(name)
+	if label == "" {
+		return ""
+	}
+	return fmt.Sprintf(",label=\"%s\"", escapeLabelValue(label))
+}
+
+// injectLabels adds a label list to every line of Prometheus text metrics
+func injectLabels(metrics, labels string) string {
+	if labels == "" || metrics == "" {
+		return metrics
+	}
+
+	lines := strings.Split(strings.TrimSuffix(metrics, "\n"), "\n")
+	for i, line := range lines {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if brace := strings.IndexByte(line, '{'); brace >= 0 {
+			if strings.HasPrefix(line[brace:], "{}") {
+				lines[i] = line[:brace+1] + labels + line[brace+1:]
+			} else {
+				lines[i] = line[:brace+1] + labels + "," + line[brace+1:]
+			}
+		} else if space := strings.IndexByte(line, ' '); space > 0 {
+			lines[i] = line[:space] + "{" + labels + "}" + line[space:]
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// defaultMetricPrefix is the prefix metrics are generated with (replaced by renameMetrics)
+const defaultMetricPrefix = "mikrotik_"
+
+// renameMetrics replaces the default "mikrotik_" prefix of every metric (and # HELP/# TYPE lines)
+func renameMetrics(metrics, prefix string) string {
+	if prefix == defaultMetricPrefix || metrics == "" {
+		return metrics
+	}
+
+	lines := strings.Split(metrics, "\n")
+	for i, line := range lines {
+		for _, lead := range []string{"", "# HELP ", "# TYPE "} {
+			if strings.HasPrefix(line, lead+defaultMetricPrefix) {
+				lines[i] = lead + prefix + line[len(lead)+len(defaultMetricPrefix):]
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rateScale returns the multiplier converting bytes/s to the configured METRIC_UNIT
+func rateScale(unit string) float64 {
+	if unit == "bits" {
+		return 8
+	}
+	return 1
+}
+
+// escapeLabelValue escapes a Prometheus label value (backslash, quote, newline)
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return value
+}
+
 // sendToVM sends metrics to VictoriaMetrics import API
+// Uses the text import API or Prometheus remote_write depending on VM_PROTOCOL
 func (c *VMClient) sendToVM(metrics string, timestamp time.Time) error {
-	url := c.config.URL + "/api/v1/import/prometheus"
+	var req *http.Request
+	var err error
 
-	req, err := http.NewRequest("POST", url, bytes.NewBufferString(metrics))
-	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+	if c.config.Protocol == "remote_write" {
+		body, encodeErr := encodeRemoteWrite(metrics, timestamp)
+		if encodeErr != nil {
+			return fmt.Errorf("encode remote write: %w", encodeErr)
+		}
+		req, err = http.NewRequest("POST", c.config.RemoteWriteURL, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	} else {
+		body, compressErr := compressBody([]byte(metrics), c.config.Compression)
+		if compressErr != nil {
+			return fmt.Errorf("compress body: %w", compressErr)
+		}
+		req, err = http.NewRequest("POST", c.config.URL+"/api/v1/import/prometheus", bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "text/plain")
+		if c.config.Compression != "none" {
+			req.Header.Set("Content-Encoding", c.config.Compression)
+		}
 	}
 
-	req.Header.Set("Content-Type", "text/plain")
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("send request: %w", err)
 	}
@@ -141,41 +650,60 @@ func (c *VMClient) sendToVM(metrics string, timestamp time.Time) error {
 
 // HistoryQueryParams holds parameters for historical data query
 type HistoryQueryParams struct {
-	Interface string
-	Start     time.Time
-	End       time.Time
-	Interval  string // "10s", "300s", or "auto"
+	Interfaces []string // Interface names (empty = every interface with stored data)
+	Start      time.Time
+	End        time.Time
+	Interval   string // "10s", "300s", or "auto"
+	MaxPoints  int    // Downsample to at most this many points per series (0 = no limit)
 }
 
 // HistoryDataPoint represents a single data point in historical data
 type HistoryDataPoint struct {
-	Timestamp   time.Time `json:"timestamp"`
-	UploadAvg   float64   `json:"upload_avg"`
-	DownloadAvg float64   `json:"download_avg"`
-	UploadPeak  float64   `json:"upload_peak"`
-	DownloadPeak float64  `json:"download_peak"`
+	Timestamp    time.Time `json:"timestamp"`
+	UploadAvg    float64   `json:"upload_avg"`
+	DownloadAvg  float64   `json:"download_avg"`
+	UploadPeak   float64   `json:"upload_peak"`
+	DownloadPeak float64   `json:"download_peak"`
+
+	Coverage *float64 `json:"coverage,omitempty"` // Fraction of expected samples collected (absent for older data)
+	Gap      bool     `json:"gap,omitempty"`      // No data or too few samples: not zero traffic
 }
 
+// gapCoverageThreshold is the coverage below which a data point is marked as a gap
+const gapCoverageThreshold = 0.5
+
 // HistoryResponse is the response structure for history queries
 type HistoryResponse struct {
-	Interface  string              `json:"interface"`
-	Interval   string              `json:"interval"`
-	Start      string              `json:"start"`
-	End        string              `json:"end"`
-	DataPoints []HistoryDataPoint  `json:"datapoints"`
-	Stats      *OverallStats       `json:"stats,omitempty"`
+	Interface  string             `json:"interface"`
+	Label      string             `json:"label,omitempty"`
+	Interval   string             `json:"interval"`
+	Start      string             `json:"start"`
+	End        string             `json:"end"`
+	DataPoints []HistoryDataPoint `json:"datapoints"`
+	Stats      *OverallStats      `json:"stats,omitempty"`
+}
+
+// MultiHistoryResponse holds the series of several interfaces queried together
+// The series are aligned: each has one data point per entry in Timestamps (marked as gap where no data was stored).
+type MultiHistoryResponse struct {
+	Interval   string             `json:"interval"`
+	Start      string             `json:"start"`
+	End        string             `json:"end"`
+	Timestamps []time.Time        `json:"timestamps"`
+	Interfaces []*HistoryResponse `json:"interfaces"`
 }
 
 // OverallStats holds aggregated statistics for the entire time range
 type OverallStats struct {
-	UploadAvg    float64 `json:"upload_avg"`     // Average Peak (sustained): max of avg values
-	DownloadAvg  float64 `json:"download_avg"`   // Average Peak (sustained): max of avg values
-	UploadPeak   float64 `json:"upload_peak"`    // Burst Peak (instantaneous): max of peak values
-	DownloadPeak float64 `json:"download_peak"`  // Burst Peak (instantaneous): max of peak values
+	UploadAvg    float64 `json:"upload_avg"`    // Average Peak (sustained): max of avg values
+	DownloadAvg  float64 `json:"download_avg"`  // Average Peak (sustained): max of avg values
+	UploadPeak   float64 `json:"upload_peak"`   // Burst Peak (instantaneous): max of peak values
+	DownloadPeak float64 `json:"download_peak"` // Burst Peak (instantaneous): max of peak values
 }
 
-// QueryHistory queries historical data from VictoriaMetrics
-func (c *VMClient) QueryHistory(params HistoryQueryParams) (*HistoryResponse, error) {
+// QueryHistory queries historical data for one or more interfaces from VictoriaMetrics
+// All interfaces are fetched with one range query per metric, so the cost doesn't grow with their number.
+func (c *VMClient) QueryHistory(params HistoryQueryParams) (*MultiHistoryResponse, error) {
 	// Determine query interval (for data sampling, e.g., "30m", "1h")
 	queryInterval := params.Interval
 	if queryInterval == "auto" || queryInterval == "" {
@@ -185,96 +713,470 @@ func (c *VMClient) QueryHistory(params HistoryQueryParams) (*HistoryResponse, er
 	// Now we only have one storage interval: 10s
 	storageInterval := "10s"
 
-	log.Printf("[VM] Querying history: interface=%s, query_interval=%s, storage_interval=%s, range=%s to %s",
-		params.Interface, queryInterval, storageInterval,
+	matcher := interfaceMatcher(params.Interfaces)
+	logDebug("VM", "Querying history: %s, query_interval=%s, storage_interval=%s, range=%s to %s",
+		matcher, queryInterval, storageInterval,
 		params.Start.Format("15:04:05"), params.End.Format("15:04:05"))
 
+	// Ranges starting past the raw data horizon read the rollups (RETENTION_ENABLED),
+	// with the windows filling the buckets not rolled up yet
+	rollupInterval := c.rollupInterval(params.Start)
+	intervals := fmt.Sprintf(`interval="%s"`, storageInterval)
+	if rollupInterval != "" {
+		intervals = fmt.Sprintf(`interval=~"%s|%s"`, storageInterval, rollupInterval)
+	}
+
 	// Build PromQL queries using storage interval
+	// Series are merged per interface, across the "label" label which changes when an interface is renamed
+	series := func(metric string) string {
+		query := fmt.Sprintf(`max by (interface) (%sinterface_%s{%s,interval="%s"%s})`, c.metricPrefix, metric, matcher, storageInterval, c.extraMatchers)
+		if rollupInterval != "" {
+			query = fmt.Sprintf(`max by (interface) (%sinterface_%s{%s,interval="%s"%s}) or %s`, c.metricPrefix, metric, matcher, rollupInterval, c.extraMatchers, query)
+		}
+		return query
+	}
 	queries := map[string]string{
-		"upload_avg":   fmt.Sprintf(`mikrotik_interface_tx_rate_avg{interface="%s",interval="%s"}`, params.Interface, storageInterval),
-		"download_avg": fmt.Sprintf(`mikrotik_interface_rx_rate_avg{interface="%s",interval="%s"}`, params.Interface, storageInterval),
-		"upload_peak":  fmt.Sprintf(`mikrotik_interface_tx_rate_peak{interface="%s",interval="%s"}`, params.Interface, storageInterval),
-		"download_peak": fmt.Sprintf(`mikrotik_interface_rx_rate_peak{interface="%s",interval="%s"}`, params.Interface, storageInterval),
+		"upload_avg":    series("tx_rate_avg"),
+		"download_avg":  series("rx_rate_avg"),
+		"upload_peak":   series("tx_rate_peak"),
+		"download_peak": series("rx_rate_peak"),
+		"coverage":      series("coverage_ratio"),
 	}
 
 	// Parse query interval to get step in seconds
 	queryDuration, err := time.ParseDuration(queryInterval)
 	if err != nil {
-		log.Printf("[VM] Warning: Failed to parse query interval '%s': %v, using default step", queryInterval, err)
+		logWarn("VM", "Failed to parse query interval '%s': %v, using default step", queryInterval, err)
 		queryDuration = 5 * time.Minute
 	}
 	step := int(queryDuration.Seconds())
 
-	// Query each metric
-	results := make(map[string][]vmDataPoint)
+	// Query each metric (metric -> interface -> points)
+	results := make(map[string]map[string][]vmDataPoint)
 	for metric, query := range queries {
-		log.Printf("[VM] Executing query for %s: %s (step=%ds)", metric, query, step)
+		logDebug("VM", "Executing query for %s: %s (step=%ds)", metric, query, step)
 		data, err := c.queryRange(query, params.Start, params.End, step)
 		if err != nil {
-			log.Printf("[VM] Warning: Failed to query %s: %v", metric, err)
+			logWarn("VM", "Failed to query %s: %v", metric, err)
 			continue
 		}
-		log.Printf("[VM] Query %s returned %d data points", metric, len(data))
+		logDebug("VM", "Query %s returned %d series", metric, len(data))
 		results[metric] = data
 	}
 
 	// Query overall statistics (max of peaks for the entire time range)
-	overallStats := c.queryOverallStats(params.Interface, storageInterval, params.Start, params.End)
+	overallStats := c.queryOverallStats(matcher, intervals, params.Start, params.End)
 
-	// Merge results into unified data points
-	dataPoints := c.mergeQueryResults(results)
+	// Requested interfaces keep their order; "all" lists every interface found, sorted
+	names := params.Interfaces
+	if len(names) == 0 {
+		names = seriesInterfaces(results)
+	}
 
-	return &HistoryResponse{
-		Interface:  params.Interface,
+	// Steps without any data become explicit gap points
+	timestamps := fillTimestampGrid(seriesTimestamps(results), int64(step))
+	resp := &MultiHistoryResponse{
 		Interval:   queryInterval,
 		Start:      params.Start.Format(time.RFC3339),
 		End:        params.End.Format(time.RFC3339),
-		DataPoints: dataPoints,
-		Stats:      overallStats,
+		Timestamps: make([]time.Time, len(timestamps)),
+		Interfaces: make([]*HistoryResponse, 0, len(names)),
+	}
+	for i, ts := range timestamps {
+		resp.Timestamps[i] = time.Unix(ts, 0)
+	}
+
+	for _, name := range names {
+		series := make(map[string][]vmDataPoint, len(results))
+		for metric, byInterface := range results {
+			series[metric] = byInterface[name]
+		}
+		stats := overallStats[name]
+		if stats == nil {
+			stats = &OverallStats{}
+		}
+
+		// Merge results into unified data points
+		resp.Interfaces = append(resp.Interfaces, &HistoryResponse{
+			Interface:  name,
+			Interval:   resp.Interval,
+			Start:      resp.Start,
+			End:        resp.End,
+			DataPoints: c.mergeQueryResults(series, timestamps),
+			Stats:      stats,
+		})
+	}
+
+	downsampleHistory(resp, params.MaxPoints, int64(step))
+
+	return resp, nil
+}
+
+// HistoryComparison holds one interface's series over two time ranges, aligned for overlaying
+// The previous range is the current one moved back by Offset; its data points carry the
+// timestamps of the current range so both series share Timestamps.
+type HistoryComparison struct {
+	Interface  string           `json:"interface"`
+	Label      string           `json:"label,omitempty"`
+	Interval   string           `json:"interval"`
+	Offset     int64            `json:"offset"` // Seconds between the ranges
+	Timestamps []time.Time      `json:"timestamps"`
+	Current    *HistoryResponse `json:"current"`
+	Previous   *HistoryResponse `json:"previous"`
+}
+
+// CompareHistory queries an interface over params' range and the same range offset earlier
+func (c *VMClient) CompareHistory(params HistoryQueryParams, offset time.Duration) (*HistoryComparison, error) {
+	if len(params.Interfaces) != 1 {
+		return nil, fmt.Errorf("comparison needs exactly one interface")
+	}
+
+	// Both ranges use the same step so their points line up
+	if params.Interval == "auto" || params.Interval == "" {
+		params.Interval = c.autoSelectInterval(params.Start, params.End)
+	}
+	maxPoints := params.MaxPoints
+	params.MaxPoints = 0
+
+	current, err := c.QueryHistory(params)
+	if err != nil {
+		return nil, err
+	}
+	previousParams := params
+	previousParams.Start = params.Start.Add(-offset)
+	previousParams.End = params.End.Add(-offset)
+	previous, err := c.QueryHistory(previousParams)
+	if err != nil {
+		return nil, err
+	}
+
+	step := int64(5 * 60)
+	if d, err := time.ParseDuration(params.Interval); err == nil && d >= time.Second {
+		step = int64(d.Seconds())
+	}
+	shift := int64(offset.Seconds())
+
+	// The grid covers the points of either range, so data missing in one shows as a gap
+	seen := make(map[int64]bool)
+	var grid []int64
+	for _, ts := range current.Timestamps {
+		if !seen[ts.Unix()] {
+			seen[ts.Unix()] = true
+			grid = append(grid, ts.Unix())
+		}
+	}
+	for _, ts := range previous.Timestamps {
+		if !seen[ts.Unix()+shift] {
+			seen[ts.Unix()+shift] = true
+			grid = append(grid, ts.Unix()+shift)
+		}
+	}
+	sort.Slice(grid, func(i, j int) bool { return grid[i] < grid[j] })
+	grid = fillTimestampGrid(grid, step)
+
+	aligned := &MultiHistoryResponse{
+		Interval:   current.Interval,
+		Start:      current.Start,
+		End:        current.End,
+		Timestamps: make([]time.Time, len(grid)),
+		Interfaces: []*HistoryResponse{current.Interfaces[0], previous.Interfaces[0]},
+	}
+	for i, ts := range grid {
+		aligned.Timestamps[i] = time.Unix(ts, 0)
+	}
+	current.Interfaces[0].DataPoints = alignDataPoints(current.Interfaces[0].DataPoints, 0, grid)
+	previous.Interfaces[0].DataPoints = alignDataPoints(previous.Interfaces[0].DataPoints, shift, grid)
+
+	downsampleHistory(aligned, maxPoints, step)
+
+	return &HistoryComparison{
+		Interface:  params.Interfaces[0],
+		Interval:   aligned.Interval,
+		Offset:     shift,
+		Timestamps: aligned.Timestamps,
+		Current:    aligned.Interfaces[0],
+		Previous:   aligned.Interfaces[1],
 	}, nil
 }
 
-// queryOverallStats queries aggregated statistics for the entire time range using PromQL
-func (c *VMClient) queryOverallStats(interfaceName, interval string, start, end time.Time) *OverallStats {
-	stats := &OverallStats{}
+// alignDataPoints moves points forward by shift seconds onto grid (gap points where none exists)
+func alignDataPoints(points []HistoryDataPoint, shift int64, grid []int64) []HistoryDataPoint {
+	byTime := make(map[int64]HistoryDataPoint, len(points))
+	for _, dp := range points {
+		byTime[dp.Timestamp.Unix()+shift] = dp
+	}
+
+	aligned := make([]HistoryDataPoint, len(grid))
+	for i, ts := range grid {
+		dp, ok := byTime[ts]
+		if !ok {
+			dp = HistoryDataPoint{Gap: true}
+		}
+		dp.Timestamp = time.Unix(ts, 0)
+		aligned[i] = dp
+	}
+	return aligned
+}
+
+// interfaceMatcher builds the PromQL matcher selecting the given interfaces (all if empty)
+func interfaceMatcher(names []string) string {
+	switch len(names) {
+	case 0:
+		return `interface=~".+"`
+	case 1:
+		return fmt.Sprintf(`interface="%s"`, escapeLabelValue(names[0]))
+	}
+
+	patterns := make([]string, len(names))
+	for i, name := range names {
+		patterns[i] = regexp.QuoteMeta(name)
+	}
+	return fmt.Sprintf(`interface=~"%s"`, escapeLabelValue(strings.Join(patterns, "|")))
+}
+
+// seriesInterfaces returns the sorted interface names present in query results
+func seriesInterfaces(results map[string]map[string][]vmDataPoint) []string {
+	seen := make(map[string]bool)
+	for _, byInterface := range results {
+		for name := range byInterface {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// seriesTimestamps returns the sorted union of timestamps in query results
+func seriesTimestamps(results map[string]map[string][]vmDataPoint) []int64 {
+	seen := make(map[int64]bool)
+	for _, byInterface := range results {
+		for _, points := range byInterface {
+			for _, point := range points {
+				seen[point.Timestamp] = true
+			}
+		}
+	}
+
+	timestamps := make([]int64, 0, len(seen))
+	for ts := range seen {
+		timestamps = append(timestamps, ts)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps
+}
+
+// fillTimestampGrid adds the missing steps between the first and last timestamp
+func fillTimestampGrid(timestamps []int64, step int64) []int64 {
+	if len(timestamps) < 2 || step <= 0 {
+		return timestamps
+	}
+
+	first, last := timestamps[0], timestamps[len(timestamps)-1]
+	if (last-first)/step > 1000000 {
+		return timestamps // Refuse absurd grids (would only happen with a tiny step over years)
+	}
+
+	seen := make(map[int64]bool, len(timestamps))
+	for _, ts := range timestamps {
+		seen[ts] = true
+	}
+	for ts := first + step; ts < last; ts += step {
+		if !seen[ts] {
+			timestamps = append(timestamps, ts)
+		}
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps
+}
+
+// queryOverallStats queries aggregated statistics per interface for the entire time range using PromQL
+// intervals is the interval label matcher of the series to read (windows and rollups)
+func (c *VMClient) queryOverallStats(matcher, intervals string, start, end time.Time) map[string]*OverallStats {
+	stats := make(map[string]*OverallStats)
+	rangeSeconds := int(end.Sub(start).Seconds())
 
 	// Use PromQL max_over_time to get peak statistics
 	// upload_avg/download_avg: Peak of average values (sustained peak)
 	// upload_peak/download_peak: Peak of peak values (burst peak)
 	queries := map[string]string{
-		"upload_avg":    fmt.Sprintf(`max_over_time(mikrotik_interface_tx_rate_avg{interface="%s",interval="%s"}[%ds])`, interfaceName, interval, int(end.Sub(start).Seconds())),
-		"download_avg":  fmt.Sprintf(`max_over_time(mikrotik_interface_rx_rate_avg{interface="%s",interval="%s"}[%ds])`, interfaceName, interval, int(end.Sub(start).Seconds())),
-		"upload_peak":   fmt.Sprintf(`max_over_time(mikrotik_interface_tx_rate_peak{interface="%s",interval="%s"}[%ds])`, interfaceName, interval, int(end.Sub(start).Seconds())),
-		"download_peak": fmt.Sprintf(`max_over_time(mikrotik_interface_rx_rate_peak{interface="%s",interval="%s"}[%ds])`, interfaceName, interval, int(end.Sub(start).Seconds())),
+		"upload_avg":    fmt.Sprintf(`max by (interface) (max_over_time(%sinterface_tx_rate_avg{%s,%s%s}[%ds]))`, c.metricPrefix, matcher, intervals, c.extraMatchers, rangeSeconds),
+		"download_avg":  fmt.Sprintf(`max by (interface) (max_over_time(%sinterface_rx_rate_avg{%s,%s%s}[%ds]))`, c.metricPrefix, matcher, intervals, c.extraMatchers, rangeSeconds),
+		"upload_peak":   fmt.Sprintf(`max by (interface) (max_over_time(%sinterface_tx_rate_peak{%s,%s%s}[%ds]))`, c.metricPrefix, matcher, intervals, c.extraMatchers, rangeSeconds),
+		"download_peak": fmt.Sprintf(`max by (interface) (max_over_time(%sinterface_rx_rate_peak{%s,%s%s}[%ds]))`, c.metricPrefix, matcher, intervals, c.extraMatchers, rangeSeconds),
 	}
 
-	log.Printf("[VM] Querying overall stats with interval=%s", interval)
+	logDebug("VM", "Querying overall stats with %s", intervals)
 
 	for metric, query := range queries {
-		log.Printf("[VM] Overall stats query for %s: %s", metric, query)
-		value := c.queryInstant(query, end)
-		switch metric {
-		case "upload_avg":
-			stats.UploadAvg = value
-		case "download_avg":
-			stats.DownloadAvg = value
-		case "upload_peak":
-			stats.UploadPeak = value
-		case "download_peak":
-			stats.DownloadPeak = value
+		logDebug("VM", "Overall stats query for %s: %s", metric, query)
+		for name, value := range c.queryInstant(query, end) {
+			value /= c.rateScale // Stored in METRIC_UNIT, reported in bytes/s
+			s := stats[name]
+			if s == nil {
+				s = &OverallStats{}
+				stats[name] = s
+			}
+			switch metric {
+			case "upload_avg":
+				s.UploadAvg = value
+			case "download_avg":
+				s.DownloadAvg = value
+			case "upload_peak":
+				s.UploadPeak = value
+			case "download_peak":
+				s.DownloadPeak = value
+			}
 		}
 	}
 
 	return stats
 }
 
-// queryInstant executes an instant query against VictoriaMetrics
-func (c *VMClient) queryInstant(query string, timestamp time.Time) float64 {
+// UsageStats is the traffic of one interface over a period (RX/TX naming, bytes and bytes/s)
+type UsageStats struct {
+	RxBytes, TxBytes           float64 // Counter increase over the period
+	RxAvg, TxAvg               float64 // Average rate
+	RxPeak, TxPeak             float64 // Highest 1-second rate
+	RxPercentile, TxPercentile float64 // Nth percentile of 5-minute averages
+}
+
+// QueryUsage returns totals, averages, peaks and the Nth percentile per interface over [start, end)
+// The percentile follows the burstable billing convention: 5-minute averages, top (100-N)% discarded.
+func (c *VMClient) QueryUsage(names []string, start, end time.Time, percentile float64) map[string]*UsageStats {
+	matcher := interfaceMatcher(names)
+	rangeSeconds := int(end.Sub(start).Seconds())
+	interval := intervalLabel(c.config.Interval)
+	if rollup := c.rollupInterval(start); rollup != "" {
+		interval = rollup // Periods reaching past the raw data horizon are read from the rollups
+	}
+	series := func(metric string) string {
+		return fmt.Sprintf(`%sinterface_%s{%s,interval="%s"%s}`, c.metricPrefix, metric, matcher, interval, c.extraMatchers)
+	}
+	counter := func(metric string) string {
+		return fmt.Sprintf(`%sinterface_%s{%s%s}`, c.metricPrefix, metric, matcher, c.extraMatchers)
+	}
+
+	// Volumes are summed over the series of an interface: imported history has counters of its own
+	queries := map[string]string{
+		"rx_bytes":      fmt.Sprintf(`sum by (interface) (increase(%s[%ds]))`, counter("rx_bytes_total"), rangeSeconds),
+		"tx_bytes":      fmt.Sprintf(`sum by (interface) (increase(%s[%ds]))`, counter("tx_bytes_total"), rangeSeconds),
+		"rx_avg":        fmt.Sprintf(`max by (interface) (avg_over_time(%s[%ds]))`, series("rx_rate_avg"), rangeSeconds),
+		"tx_avg":        fmt.Sprintf(`max by (interface) (avg_over_time(%s[%ds]))`, series("tx_rate_avg"), rangeSeconds),
+		"rx_peak":       fmt.Sprintf(`max by (interface) (max_over_time(%s[%ds]))`, series("rx_rate_peak"), rangeSeconds),
+		"tx_peak":       fmt.Sprintf(`max by (interface) (max_over_time(%s[%ds]))`, series("tx_rate_peak"), rangeSeconds),
+		"rx_percentile": fmt.Sprintf(`max by (interface) (quantile_over_time(%g, avg_over_time(%s[5m])[%ds:5m]))`, percentile/100, series("rx_rate_avg"), rangeSeconds),
+		"tx_percentile": fmt.Sprintf(`max by (interface) (quantile_over_time(%g, avg_over_time(%s[5m])[%ds:5m]))`, percentile/100, series("tx_rate_avg"), rangeSeconds),
+	}
+
+	usage := make(map[string]*UsageStats)
+	for metric, query := range queries {
+		logDebug("VM", "Usage query for %s: %s", metric, query)
+		for name, value := range c.queryInstant(query, end) {
+			if !strings.HasSuffix(metric, "_bytes") {
+				value /= c.rateScale // Stored in METRIC_UNIT, reported in bytes/s
+			}
+			u := usage[name]
+			if u == nil {
+				u = &UsageStats{}
+				usage[name] = u
+			}
+			switch metric {
+			case "rx_bytes":
+				u.RxBytes = value
+			case "tx_bytes":
+				u.TxBytes = value
+			case "rx_avg":
+				u.RxAvg = value
+			case "tx_avg":
+				u.TxAvg = value
+			case "rx_peak":
+				u.RxPeak = value
+			case "tx_peak":
+				u.TxPeak = value
+			case "rx_percentile":
+				u.RxPercentile = value
+			case "tx_percentile":
+				u.TxPercentile = value
+			}
+		}
+	}
+
+	return usage
+}
+
+// QueryBilling returns the volume and the 5-minute averages per interface over [start, end)
+// Averages are taken every 5 minutes over the preceding 5 minutes, as for the percentile of QueryUsage.
+func (c *VMClient) QueryBilling(names []string, start, end time.Time) (map[string]*BillingSeries, error) {
+	matcher := interfaceMatcher(names)
+	rangeSeconds := int(end.Sub(start).Seconds())
+	interval := intervalLabel(c.config.Interval)
+	if rollup := c.rollupInterval(start); rollup != "" {
+		interval = rollup // Months reaching past the raw data horizon are read from the rollups
+	}
+
+	result := make(map[string]*BillingSeries)
+	entry := func(name string) *BillingSeries {
+		s := result[name]
+		if s == nil {
+			s = &BillingSeries{Rx: make(map[int64]float64), Tx: make(map[int64]float64)}
+			result[name] = s
+		}
+		return s
+	}
+
+	for _, direction := range []string{"rx", "tx"} {
+		counter := fmt.Sprintf(`%sinterface_%s_bytes_total{%s%s}`, c.metricPrefix, direction, matcher, c.extraMatchers)
+		query := fmt.Sprintf(`sum by (interface) (increase(%s[%ds]))`, counter, rangeSeconds)
+		logDebug("VM", "Billing volume query: %s", query)
+		for name, value := range c.queryInstant(query, end) {
+			if direction == "rx" {
+				entry(name).RxBytes = value
+			} else {
+				entry(name).TxBytes = value
+			}
+		}
+
+		if end.Sub(start) < 5*time.Minute {
+			continue // No complete average yet
+		}
+		series := fmt.Sprintf(`%sinterface_%s_rate_avg{%s,interval="%s"%s}`, c.metricPrefix, direction, matcher, interval, c.extraMatchers)
+		query = fmt.Sprintf(`max by (interface) (avg_over_time(%s[5m]))`, series)
+		logDebug("VM", "Billing averages query: %s", query)
+		points, err := c.queryRange(query, start.Add(5*time.Minute), end, 300)
+		if err != nil {
+			return nil, fmt.Errorf("query %s averages: %w", direction, err)
+		}
+		for name, values := range points {
+			s := entry(name)
+			for _, point := range values {
+				if direction == "rx" {
+					s.Rx[point.Timestamp] = point.Value / c.rateScale // Stored in METRIC_UNIT
+				} else {
+					s.Tx[point.Timestamp] = point.Value / c.rateScale
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// queryInstant executes an instant query against VictoriaMetrics and returns the value per interface
+func (c *VMClient) queryInstant(query string, timestamp time.Time) map[string]float64 {
+	return c.queryInstantBy(query, "interface", timestamp)
+}
+
+// queryInstantBy executes an instant query and returns the value per value of label
+func (c *VMClient) queryInstantBy(query, label string, timestamp time.Time) map[string]float64 {
 	baseURL := fmt.Sprintf("%s/api/v1/query", c.config.URL)
 	req, err := http.NewRequest("GET", baseURL, nil)
 	if err != nil {
-		log.Printf("[VM] Error creating instant query request: %v", err)
-		return 0
+		logError("VM", "Error creating instant query request: %v", err)
+		return nil
 	}
 
 	q := req.URL.Query()
@@ -282,17 +1184,17 @@ func (c *VMClient) queryInstant(query string, timestamp time.Time) float64 {
 	q.Add("time", fmt.Sprintf("%d", timestamp.Unix()))
 	req.URL.RawQuery = q.Encode()
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		log.Printf("[VM] Error executing instant query: %v", err)
-		return 0
+		logError("VM", "Error executing instant query: %v", err)
+		return nil
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Printf("[VM] Instant query failed (%d): %s", resp.StatusCode, string(body))
-		return 0
+		logError("VM", "Instant query failed (%d): %s", resp.StatusCode, string(body))
+		return nil
 	}
 
 	var vmResp struct {
@@ -307,22 +1209,25 @@ func (c *VMClient) queryInstant(query string, timestamp time.Time) float64 {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&vmResp); err != nil {
-		log.Printf("[VM] Error decoding instant query response: %v", err)
-		return 0
+		logError("VM", "Error decoding instant query response: %v", err)
+		return nil
 	}
 
-	if vmResp.Status != "success" || len(vmResp.Data.Result) == 0 {
-		return 0
+	if vmResp.Status != "success" {
+		return nil
 	}
 
-	if len(vmResp.Data.Result[0].Value) >= 2 {
-		valueStr := vmResp.Data.Result[0].Value[1].(string)
-		var val float64
-		fmt.Sscanf(valueStr, "%f", &val)
-		return val
+	values := make(map[string]float64, len(vmResp.Data.Result))
+	for _, result := range vmResp.Data.Result {
+		if len(result.Value) >= 2 {
+			valueStr, _ := result.Value[1].(string)
+			var val float64
+			fmt.Sscanf(valueStr, "%f", &val)
+			values[result.Metric[label]] = val
+		}
 	}
 
-	return 0
+	return values
 }
 
 // vmDataPoint is internal structure for VM query results
@@ -331,8 +1236,8 @@ type vmDataPoint struct {
 	Value     float64
 }
 
-// queryRange executes a range query against VictoriaMetrics
-func (c *VMClient) queryRange(query string, start, end time.Time, step int) ([]vmDataPoint, error) {
+// queryRange executes a range query against VictoriaMetrics and returns the series per interface
+func (c *VMClient) queryRange(query string, start, end time.Time, step int) (map[string][]vmDataPoint, error) {
 	// Use the provided step parameter instead of auto-calculating
 	// This ensures the returned data points match what the frontend expects
 
@@ -351,9 +1256,9 @@ func (c *VMClient) queryRange(query string, start, end time.Time, step int) ([]v
 	q.Add("step", fmt.Sprintf("%d", step))
 	req.URL.RawQuery = q.Encode()
 
-	log.Printf("[VM] Full request URL: %s", req.URL.String())
+	logDebug("VM", "Full request URL: %s", req.URL.String())
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("send request: %w", err)
 	}
@@ -361,7 +1266,7 @@ func (c *VMClient) queryRange(query string, start, end time.Time, step int) ([]v
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Printf("[VM] HTTP error response: %s", string(body))
+		logError("VM", "HTTP error response: %s", string(body))
 		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
 	}
 
@@ -384,86 +1289,187 @@ func (c *VMClient) queryRange(query string, start, end time.Time, step int) ([]v
 	}
 
 	if err := json.Unmarshal(bodyBytes, &vmResp); err != nil {
-		log.Printf("[VM] Failed to decode response: %s", string(bodyBytes))
+		logError("VM", "Failed to decode response: %s", string(bodyBytes))
 		return nil, fmt.Errorf("decode response: %w", err)
 	}
 
-	log.Printf("[VM] VM Response status: %s, result count: %d", vmResp.Status, len(vmResp.Data.Result))
+	logDebug("VM", "VM Response status: %s, result count: %d", vmResp.Status, len(vmResp.Data.Result))
 
 	if vmResp.Status != "success" {
 		return nil, fmt.Errorf("query failed: %s", vmResp.Status)
 	}
 
 	// Extract data points
-	var dataPoints []vmDataPoint
-	if len(vmResp.Data.Result) > 0 {
-		log.Printf("[VM] First result has %d values, metric labels: %v",
-			len(vmResp.Data.Result[0].Values), vmResp.Data.Result[0].Metric)
+	series := make(map[string][]vmDataPoint, len(vmResp.Data.Result))
+	for _, result := range vmResp.Data.Result {
+		name := result.Metric["interface"]
+		logDebug("VM", "Series %s has %d values", name, len(result.Values))
 
-		for _, value := range vmResp.Data.Result[0].Values {
+		for _, value := range result.Values {
 			if len(value) >= 2 {
-				timestamp := int64(value[0].(float64))
-				valueStr := value[1].(string)
+				timestamp, _ := value[0].(float64)
+				valueStr, _ := value[1].(string)
 				var val float64
 				fmt.Sscanf(valueStr, "%f", &val)
-				dataPoints = append(dataPoints, vmDataPoint{
-					Timestamp: timestamp,
+				series[name] = append(series[name], vmDataPoint{
+					Timestamp: int64(timestamp),
 					Value:     val,
 				})
 			}
 		}
-	} else {
-		log.Printf("[VM] WARNING: Query returned 0 results. This means no data matched the query.")
+	}
+	if len(series) == 0 {
+		logWarn("VM", "Query returned 0 results. This means no data matched the query.")
 	}
 
-	return dataPoints, nil
+	return series, nil
 }
 
-// mergeQueryResults merges multiple metric results into unified data points
-func (c *VMClient) mergeQueryResults(results map[string][]vmDataPoint) []HistoryDataPoint {
-	// Build timestamp index
-	timestampMap := make(map[int64]*HistoryDataPoint)
+// mergeQueryResults merges multiple metric results into one data point per timestamp
+func (c *VMClient) mergeQueryResults(results map[string][]vmDataPoint, timestamps []int64) []HistoryDataPoint {
+	// Build timestamp index (values are converted back from METRIC_UNIT to bytes/s)
+	dataPoints := make([]HistoryDataPoint, len(timestamps))
+	index := make(map[int64]int, len(timestamps))
+	for i, ts := range timestamps {
+		dataPoints[i].Timestamp = time.Unix(ts, 0)
+		index[ts] = i
+	}
 
+	present := make([]bool, len(timestamps))
 	for metric, points := range results {
 		for _, point := range points {
-			dp, exists := timestampMap[point.Timestamp]
+			i, exists := index[point.Timestamp]
 			if !exists {
-				dp = &HistoryDataPoint{
-					Timestamp: time.Unix(point.Timestamp, 0),
-				}
-				timestampMap[point.Timestamp] = dp
+				continue
 			}
+			dp := &dataPoints[i]
+			if metric == "coverage" {
+				coverage := point.Value
+				dp.Coverage = &coverage
+				continue
+			}
+			present[i] = true
 
 			// Assign value to appropriate field
 			switch metric {
 			case "upload_avg":
-				dp.UploadAvg = point.Value
+				dp.UploadAvg = point.Value / c.rateScale
 			case "download_avg":
-				dp.DownloadAvg = point.Value
+				dp.DownloadAvg = point.Value / c.rateScale
 			case "upload_peak":
-				dp.UploadPeak = point.Value
+				dp.UploadPeak = point.Value / c.rateScale
 			case "download_peak":
-				dp.DownloadPeak = point.Value
+				dp.DownloadPeak = point.Value / c.rateScale
 			}
 		}
 	}
 
-	// Convert to sorted slice
-	var dataPoints []HistoryDataPoint
-	for _, dp := range timestampMap {
-		dataPoints = append(dataPoints, *dp)
+	// Mark steps without rate data or with too few samples, so they aren't read as zero traffic
+	for i := range dataPoints {
+		dp := &dataPoints[i]
+		dp.Gap = !present[i] || (dp.Coverage != nil && *dp.Coverage < gapCoverageThreshold)
 	}
 
-	// Sort by timestamp
-	for i := 0; i < len(dataPoints)-1; i++ {
-		for j := i + 1; j < len(dataPoints); j++ {
-			if dataPoints[i].Timestamp.After(dataPoints[j].Timestamp) {
-				dataPoints[i], dataPoints[j] = dataPoints[j], dataPoints[i]
-			}
+	return dataPoints
+}
+
+// downsampleHistory merges neighbouring points into equal time buckets so that
+// every series has at most maxPoints points. Averages are averaged and peaks keep
+// their maximum, so bursts survive downsampling. The response interval becomes
+// the bucket width.
+func downsampleHistory(resp *MultiHistoryResponse, maxPoints int, step int64) {
+	n := len(resp.Timestamps)
+	if maxPoints <= 0 || n <= maxPoints || step <= 0 {
+		return
+	}
+
+	// Bucket width is a multiple of the step covering the whole range in maxPoints buckets
+	first := resp.Timestamps[0].Unix()
+	slots := (resp.Timestamps[n-1].Unix()-first)/step + 1
+	width := step * ((slots + int64(maxPoints) - 1) / int64(maxPoints))
+	bucketStart := func(t time.Time) time.Time {
+		return time.Unix(first+(t.Unix()-first)/width*width, 0)
+	}
+
+	timestamps := make([]time.Time, 0, maxPoints)
+	for _, ts := range resp.Timestamps {
+		start := bucketStart(ts)
+		if len(timestamps) == 0 || !timestamps[len(timestamps)-1].Equal(start) {
+			timestamps = append(timestamps, start)
 		}
 	}
+	resp.Timestamps = timestamps
+	resp.Interval = fmt.Sprintf("%ds", width)
 
-	return dataPoints
+	for _, series := range resp.Interfaces {
+		series.Interval = resp.Interval
+		series.DataPoints = downsamplePoints(series.DataPoints, bucketStart)
+	}
+}
+
+// downsamplePoints merges sorted points sharing a bucket (avg of averages, max of peaks)
+// Gap points are left out of the values; a bucket is a gap only if all its points are.
+// Bucket coverage is the mean coverage of its points (gaps without coverage count as 0).
+func downsamplePoints(points []HistoryDataPoint, bucketStart func(time.Time) time.Time) []HistoryDataPoint {
+	type bucketTotals struct {
+		points, data int
+		coverage     float64
+		hasCoverage  bool
+	}
+	buckets := make([]HistoryDataPoint, 0)
+	totals := make([]bucketTotals, 0)
+
+	for _, p := range points {
+		start := bucketStart(p.Timestamp)
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Timestamp.Equal(start) {
+			buckets = append(buckets, HistoryDataPoint{Timestamp: start})
+			totals = append(totals, bucketTotals{})
+		}
+		b, t := &buckets[len(buckets)-1], &totals[len(totals)-1]
+
+		t.points++
+		switch {
+		case p.Coverage != nil:
+			t.coverage += *p.Coverage
+			t.hasCoverage = true
+		case p.Gap:
+			t.hasCoverage = true
+		default:
+			t.coverage++
+		}
+		if p.Gap {
+			continue
+		}
+
+		b.UploadAvg += p.UploadAvg
+		b.DownloadAvg += p.DownloadAvg
+		b.UploadPeak = math.Max(b.UploadPeak, p.UploadPeak)
+		b.DownloadPeak = math.Max(b.DownloadPeak, p.DownloadPeak)
+		t.data++
+	}
+
+	for i := range buckets {
+		b, t := &buckets[i], totals[i]
+		if t.data > 0 {
+			b.UploadAvg /= float64(t.data)
+			b.DownloadAvg /= float64(t.data)
+		}
+		b.Gap = t.data == 0
+		if t.hasCoverage {
+			coverage := t.coverage / float64(t.points)
+			b.Coverage = &coverage
+		}
+	}
+	return buckets
+}
+
+// rollupInterval returns the interval label of the rollups if start is past the raw data horizon
+// ("" when the aggregation windows still cover it, or without RETENTION_ENABLED)
+func (c *VMClient) rollupInterval(start time.Time) string {
+	if c.retention == nil || time.Since(start) < c.retention.RawRetention {
+		return ""
+	}
+	return intervalLabel(c.retention.RollupStep)
 }
 
 // autoSelectInterval automatically selects appropriate interval based on time range
@@ -492,7 +1498,7 @@ func (c *VMClient) QueryDebugIntervals(query string) ([]string, error) {
 	q.Add("query", query)
 	req.URL.RawQuery = q.Encode()
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("send request: %w", err)
 	}
@@ -532,19 +1538,142 @@ func (c *VMClient) QueryDebugIntervals(query string) ([]string, error) {
 	return intervals, nil
 }
 
+// ============================================================================
+// VictoriaMetrics Output
+// ============================================================================
+
+// VMOutput implements OutputWriter by aggregating samples into time windows
+// and pushing each completed window to VictoriaMetrics
+type VMOutput struct {
+	client     *VMClient
+	aggregator *TimeWindowAggregator
+	flusher    *windowFlusher
+}
+
+// NewVMOutput creates a VictoriaMetrics output
+func NewVMOutput(client *VMClient, aggregator *TimeWindowAggregator) *VMOutput {
+	o := &VMOutput{client: client, aggregator: aggregator}
+	o.flusher = newWindowFlusher(aggregator, o.send)
+	return o
+}
+
+func (o *VMOutput) WriteHeader() {}
+
+func (o *VMOutput) WriteStats(timestamp time.Time, stats map[string]*RateInfo) {
+	for _, rateInfo := range stats {
+		o.aggregator.AddSample(timestamp, rateInfo)
+	}
+
+	// Check for completed windows and send to VM
+	o.flusher.sendCompleted()
+}
+
+// send pushes one completed window to VM
+func (o *VMOutput) send(window *AggregationWindow) {
+	if err := o.client.SendMetrics(window); err != nil {
+		logError("VM", "Failed to send metrics: %v", err)
+	}
+}
+
+// Close sends the last (partial) window, then stops the delivery worker after flushing queued batches
+func (o *VMOutput) Close() {
+	o.flusher.Close()
+	o.client.Close()
+}
+
+// SetSampleInterval follows poll interval changes (see sampleIntervalSetter)
+func (o *VMOutput) SetSampleInterval(now time.Time, sampleInterval time.Duration) {
+	o.aggregator.SetSampleInterval(now, sampleInterval)
+}
+
+// ============================================================================
+// Window Flusher
+// ============================================================================
+
+// windowFlushGrace is how long after a window's end samples may still arrive
+// (a sample taken just before the boundary can reach the output slightly later)
+const windowFlushGrace = 5 * time.Second
+
+// windowFlusher completes aggregation windows on a timer
+//
+// Windows normally complete when the first sample of the next window arrives.
+// During an outage no samples arrive, so the flusher closes windows whose end
+// time has passed; at shutdown it sends the current partial window.
+type windowFlusher struct {
+	aggregator *TimeWindowAggregator
+	send       func(*AggregationWindow)
+	sendMu     sync.Mutex // Keeps windows in order between the flusher and WriteStats
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newWindowFlusher starts a flusher for aggregator, delivering windows with send
+func newWindowFlusher(aggregator *TimeWindowAggregator, send func(*AggregationWindow)) *windowFlusher {
+	f := &windowFlusher{
+		aggregator: aggregator,
+		send:       send,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go f.run()
+	return f
+}
+
+// run checks for overdue windows every second until Close
+func (f *windowFlusher) run() {
+	defer close(f.done)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case now := <-ticker.C:
+			if f.aggregator.Flush(now.Add(-windowFlushGrace)) {
+				f.sendCompleted()
+			}
+		}
+	}
+}
+
+// sendCompleted delivers all completed windows
+func (f *windowFlusher) sendCompleted() {
+	f.sendMu.Lock()
+	defer f.sendMu.Unlock()
+
+	for _, window := range f.aggregator.GetCompletedWindows() {
+		f.send(window)
+	}
+}
+
+// Close stops the timer and delivers the current (partial) window
+func (f *windowFlusher) Close() {
+	close(f.stop)
+	<-f.done
+
+	f.aggregator.FlushAll()
+	f.sendCompleted()
+}
+
 // ============================================================================
 // Time Window Aggregator
 // ============================================================================
 
 // TimeWindowAggregator handles fixed-boundary time window aggregation
 type TimeWindowAggregator struct {
-	interval time.Duration
+	interval         time.Duration
+	sampleInterval   time.Duration // Monitor poll interval (for expected sample counts)
+	histogramBuckets []float64     // Rate histogram bounds (Mbps, ascending; nil = no histograms)
 
 	// Current aggregation window
 	currentWindow *AggregationWindow
 
 	// Completed windows ready to send
 	completedWindows []*AggregationWindow
+	flushedUntil     time.Time // End of the last window closed by Flush (older samples are dropped)
 	mu               sync.Mutex
 }
 
@@ -553,44 +1682,98 @@ type AggregationWindow struct {
 	StartTime  time.Time
 	EndTime    time.Time
 	Interval   time.Duration
+	Expected   int       // Samples per interface expected in a full window (interval / sample interval)
+	Buckets    []float64 // Rate histogram bounds (Mbps, ascending; nil = no histograms)
 	Interfaces map[string]*WindowStats
+
+	expectedBefore float64   // Samples expected before expectedSince (poll interval changes)
+	expectedSince  time.Time // Last poll interval change within the window (or its start)
+}
+
+// Coverage returns the fraction of expected samples collected for an interface (0-1)
+// Polling outages and reconnects show up as coverage below 1 instead of as low traffic.
+func (w *AggregationWindow) Coverage(stats *WindowStats) float64 {
+	if w.Expected <= 0 {
+		return 1
+	}
+	return math.Min(1, float64(stats.Count)/float64(w.Expected))
 }
 
 // WindowStats holds aggregated statistics for an interface within a window
 type WindowStats struct {
-	RxSum  float64 // Sum for average calculation
-	TxSum  float64
-	RxPeak float64 // Peak value
-	TxPeak float64
-	RxMin  float64 // Minimum value
-	TxMin  float64
-	Count  int // Number of samples
+	RxSum   float64 // Sum for average calculation
+	TxSum   float64
+	RxPeak  float64 // Peak value
+	TxPeak  float64
+	RxMin   float64 // Minimum value
+	TxMin   float64
+	RxSumSq float64 // Sum of squares for standard deviation
+	TxSumSq float64
+	Count   int    // Number of samples
+	RxBytes uint64 // Latest raw RX counter
+	TxBytes uint64 // Latest raw TX counter
+
+	RxHistogram []int // Samples per histogram bucket (at most its bound, above the previous one)
+	TxHistogram []int
+
+	LinkSpeed float64         // Latest link speed (bits/s, 0 if unknown)
+	State     *InterfaceState // Latest link state (nil if unknown)
 }
 
 // NewTimeWindowAggregator creates a new time window aggregator
-func NewTimeWindowAggregator(interval time.Duration) *TimeWindowAggregator {
-	log.Printf("[Aggregator] Time window aggregator initialized")
-	log.Printf("[Aggregator] Aggregation window: %v", interval)
+// sampleInterval is the monitor's poll interval, from which each window's expected sample count follows.
+func NewTimeWindowAggregator(interval, sampleInterval time.Duration) *TimeWindowAggregator {
+	logInfo("Aggregator", "Time window aggregator initialized")
+	logInfo("Aggregator", "Aggregation window: %v (samples every %v)", interval, sampleInterval)
 
 	return &TimeWindowAggregator{
 		interval:         interval,
+		sampleInterval:   sampleInterval,
 		completedWindows: make([]*AggregationWindow, 0),
 	}
 }
 
-// AddSample adds a sample to the current aggregation window
-func (a *TimeWindowAggregator) AddSample(timestamp time.Time, interfaceName string, rxRate, txRate float64) {
+// SetSampleInterval changes the poll interval from now on
+// The current window expects samples at the previous interval up to now and at the new one after,
+// so a changed interval does not show up as missing samples.
+func (a *TimeWindowAggregator) SetSampleInterval(now time.Time, sampleInterval time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if sampleInterval == a.sampleInterval {
+		return
+	}
+	if w := a.currentWindow; w != nil && now.After(w.expectedSince) && now.Before(w.EndTime) {
+		w.expectedBefore += float64(now.Sub(w.expectedSince)) / float64(a.sampleInterval)
+		w.expectedSince = now
+		w.Expected = int(w.expectedBefore + float64(w.EndTime.Sub(now))/float64(sampleInterval))
+	}
+	a.sampleInterval = sampleInterval
+}
+
+// AddSample adds a sample (rates, raw counters and link speed) to the current aggregation window
+func (a *TimeWindowAggregator) AddSample(timestamp time.Time, info *RateInfo) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	// Samples arriving after their window was flushed would create a duplicate window;
+	// after the wall clock was stepped back, they would land in a later window
+	if timestamp.Before(a.flushedUntil) || (a.currentWindow != nil && timestamp.Before(a.currentWindow.StartTime)) {
+		logDebug("Aggregator", "Dropping late sample for %s at %s (window already closed)", info.InterfaceName, timestamp.Format("15:04:05"))
+		return
+	}
+
 	// Process aggregation window
-	a.currentWindow = a.addToWindow(a.currentWindow, a.interval, timestamp, interfaceName, rxRate, txRate)
+	a.currentWindow = a.addToWindow(a.currentWindow, a.interval, timestamp, info)
 }
 
 // addToWindow adds a sample to a specific window, creating new window if needed
-func (a *TimeWindowAggregator) addToWindow(window *AggregationWindow, interval time.Duration, timestamp time.Time, ifaceName string, rxRate, txRate float64) *AggregationWindow {
-	// Calculate window boundaries (aligned to interval)
-	windowStart := timestamp.Truncate(interval)
+func (a *TimeWindowAggregator) addToWindow(window *AggregationWindow, interval time.Duration, timestamp time.Time, info *RateInfo) *AggregationWindow {
+	rxRate, txRate := info.RxRate, info.TxRate
+
+	// Calculate window boundaries (aligned to interval from midnight in the
+	// timestamp's zone: the monitor stamps samples in the TIMEZONE zone)
+	windowStart := truncateIn(timestamp, interval, timestamp.Location())
 	windowEnd := windowStart.Add(interval)
 
 	// Create new window if needed
@@ -605,24 +1788,40 @@ func (a *TimeWindowAggregator) addToWindow(window *AggregationWindow, interval t
 			StartTime:  windowStart,
 			EndTime:    windowEnd,
 			Interval:   interval,
+			Expected:   int(interval / a.sampleInterval),
+			Buckets:    a.histogramBuckets,
 			Interfaces: make(map[string]*WindowStats),
+
+			expectedSince: windowStart,
 		}
 	}
 
 	// Get or create interface stats
-	stats, exists := window.Interfaces[ifaceName]
+	stats, exists := window.Interfaces[info.InterfaceName]
 	if !exists {
 		stats = &WindowStats{
 			RxMin: rxRate,
 			TxMin: txRate,
 		}
-		window.Interfaces[ifaceName] = stats
+		if len(window.Buckets) > 0 {
+			stats.RxHistogram = make([]int, len(window.Buckets))
+			stats.TxHistogram = make([]int, len(window.Buckets))
+		}
+		window.Interfaces[info.InterfaceName] = stats
 	}
 
 	// Update statistics
 	stats.RxSum += rxRate
 	stats.TxSum += txRate
+	stats.RxSumSq += rxRate * rxRate
+	stats.TxSumSq += txRate * txRate
 	stats.Count++
+	stats.RxBytes = info.RxBytes
+	stats.TxBytes = info.TxBytes
+	stats.LinkSpeed = info.LinkSpeed
+	stats.State = info.State
+	countHistogram(stats.RxHistogram, window.Buckets, rxRate)
+	countHistogram(stats.TxHistogram, window.Buckets, txRate)
 
 	// Update peak values
 	if rxRate > stats.RxPeak {
@@ -643,6 +1842,59 @@ func (a *TimeWindowAggregator) addToWindow(window *AggregationWindow, interval t
 	return window
 }
 
+// countHistogram counts a rate (bytes/s) in the first bucket whose bound (Mbps) is not below it
+// Rates above the last bound only count towards le="+Inf".
+func countHistogram(counts []int, bounds []float64, rate float64) {
+	if i := sort.SearchFloat64s(bounds, rate*8/1e6); i < len(counts) {
+		counts[i]++
+	}
+}
+
+// parseHistogramBuckets parses VM_HISTOGRAM_BUCKETS: ascending bounds in Mbps, e.g. "1,10,100,1000"
+func parseHistogramBuckets(value string) ([]float64, error) {
+	var bounds []float64
+	for _, entry := range parseCommaSeparated(value, "") {
+		bound, err := strconv.ParseFloat(entry, 64)
+		if err != nil || bound <= 0 || math.IsInf(bound, 0) {
+			return nil, fmt.Errorf("invalid bucket %q (expected a positive number of Mbps)", entry)
+		}
+		if len(bounds) > 0 && bound <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("buckets must be in ascending order (%q after %g)", entry, bounds[len(bounds)-1])
+		}
+		bounds = append(bounds, bound)
+	}
+	return bounds, nil
+}
+
+// Flush completes the current window if it ended at or before now, without waiting for the next sample
+// Returns true if a window was completed.
+func (a *TimeWindowAggregator) Flush(now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.currentWindow == nil || now.Before(a.currentWindow.EndTime) {
+		return false
+	}
+
+	a.completedWindows = append(a.completedWindows, a.currentWindow)
+	a.flushedUntil = a.currentWindow.EndTime
+	a.currentWindow = nil
+	return true
+}
+
+// FlushAll completes the current window even if it hasn't ended yet (used at shutdown)
+func (a *TimeWindowAggregator) FlushAll() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.currentWindow == nil {
+		return
+	}
+	a.completedWindows = append(a.completedWindows, a.currentWindow)
+	a.flushedUntil = a.currentWindow.EndTime
+	a.currentWindow = nil
+}
+
 // GetCompletedWindows returns and clears completed windows ready to send to VM
 func (a *TimeWindowAggregator) GetCompletedWindows() []*AggregationWindow {
 	a.mu.Lock()
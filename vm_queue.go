@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// VictoriaMetrics Delivery Queue
+// ============================================================================
+
+// vmBatch is one Prometheus-format payload waiting to be delivered
+type vmBatch struct {
+	metrics     string
+	timestamp   time.Time
+	description string // For logging (e.g. "window [10:00:00, 10:00:10) - 2 interfaces")
+}
+
+// vmQueue is a bounded in-memory FIFO with a drop-oldest policy
+// A background worker delivers batches so a slow VM endpoint never stalls sampling
+type vmQueue struct {
+	mu      sync.Mutex
+	items   []*vmBatch
+	max     int
+	merge   int    // Max batches sent in one request
+	dropped uint64 // Batches discarded because the queue was full
+
+	spool *vmSpool // Disk spool for undeliverable batches (nil if disabled)
+
+	notify chan struct{} // Signals the worker that items are available
+	stop   chan struct{} // Closed to stop the worker
+	done   chan struct{} // Closed when the worker has exited
+}
+
+// newVMQueue creates a queue holding at most max batches, sending up to merge of them per request
+func newVMQueue(max, merge int) *vmQueue {
+	return &vmQueue{
+		max:    max,
+		merge:  merge,
+		notify: make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// push adds a batch, discarding the oldest one if the queue is full
+func (q *vmQueue) push(batch *vmBatch) {
+	q.mu.Lock()
+	if len(q.items) >= q.max {
+		oldest := q.items[0]
+		q.items = q.items[1:]
+		q.dropped++
+		logWarn("VM", "Queue full (%d), dropped oldest batch: %s", q.max, oldest.description)
+	}
+	q.items = append(q.items, batch)
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default: // Worker already signalled
+	}
+}
+
+// peek returns the oldest batches without removing them (empty if the queue is empty)
+// It returns up to merge batches of at most spoolReplayChunk bytes together,
+// so a backlog after an outage is sent in a few requests instead of one per window.
+func (q *vmQueue) peek() []*vmBatch {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var batches []*vmBatch
+	size := 0
+	for _, batch := range q.items {
+		if len(batches) == max(q.merge, 1) || (len(batches) > 0 && size+len(batch.metrics) > spoolReplayChunk) {
+			break
+		}
+		batches = append(batches, batch)
+		size += len(batch.metrics)
+	}
+	return batches
+}
+
+// remove drops batches from the head of the queue if they are still there
+// (they may already have been discarded by push while being delivered)
+func (q *vmQueue) remove(batches []*vmBatch) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, batch := range batches {
+		if len(q.items) > 0 && q.items[0] == batch {
+			q.items = q.items[1:]
+		}
+	}
+}
+
+// mergeBatches joins batches into one request (the batch itself if there is only one)
+func mergeBatches(batches []*vmBatch) *vmBatch {
+	if len(batches) == 1 {
+		return batches[0]
+	}
+	var metrics strings.Builder
+	for _, batch := range batches {
+		metrics.WriteString(batch.metrics)
+	}
+	last := batches[len(batches)-1]
+	return &vmBatch{
+		metrics:     metrics.String(),
+		timestamp:   last.timestamp,
+		description: fmt.Sprintf("%s ... %s (%d batches)", batches[0].description, last.description, len(batches)),
+	}
+}
+
+// stats returns the current queue length and total dropped batches
+func (q *vmQueue) stats() (queued int, dropped uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items), q.dropped
+}
+
+// run delivers batches in order until stop is closed
+// Each batch is retried with linear backoff; on shutdown remaining batches get one attempt each
+//
+// With a spool, a batch that still fails is written to disk and the queue switches
+// to offline mode: new batches go straight to the spool (preserving order) until a
+// periodic replay succeeds.
+func (q *vmQueue) run(send func(*vmBatch) error, retries int) {
+	defer close(q.done)
+
+	replayTicker := time.NewTicker(spoolReplayPeriod)
+	defer replayTicker.Stop()
+
+	for {
+		batches := q.peek()
+		if len(batches) == 0 {
+			select {
+			case <-q.notify:
+				continue
+			case <-replayTicker.C:
+				q.replaySpool(send)
+				continue
+			case <-q.stop:
+				q.drain(send)
+				return
+			}
+		}
+
+		batch := mergeBatches(batches)
+		if q.spool != nil && q.spool.pending() {
+			q.spoolBatch(batch) // Offline: keep order by spooling behind older data
+		} else if err := q.deliver(batch, send, retries); err != nil {
+			if q.spool != nil {
+				logWarn("VM", "VictoriaMetrics unreachable, spooling to disk: %v", err)
+				q.spoolBatch(batch)
+			} else {
+				logWarn("VM", "Giving up on batch %s: %v", batch.description, err)
+			}
+		}
+		q.remove(batches)
+
+		select {
+		case <-q.stop:
+			q.drain(send)
+			return
+		default:
+		}
+	}
+}
+
+// deliver sends one batch with retries, aborting the backoff on shutdown
+func (q *vmQueue) deliver(batch *vmBatch, send func(*vmBatch) error, retries int) error {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			logWarn("VM", "Retry attempt %d/%d", attempt, retries)
+			select {
+			case <-time.After(time.Second * time.Duration(attempt)):
+			case <-q.stop:
+				return fmt.Errorf("shutting down: %w", err)
+			}
+		}
+
+		if err = send(batch); err == nil {
+			return nil
+		}
+		logWarn("VM", "Error sending %s (attempt %d): %v", batch.description, attempt+1, err)
+	}
+	return fmt.Errorf("failed after %d retries: %w", retries, err)
+}
+
+// drain makes a single delivery attempt for every remaining batch
+// Batches that cannot be delivered are spooled (if enabled) for the next run
+func (q *vmQueue) drain(send func(*vmBatch) error) {
+	for batches := q.peek(); len(batches) > 0; batches = q.peek() {
+		batch := mergeBatches(batches)
+		if q.spool != nil && q.spool.pending() {
+			q.spoolBatch(batch)
+		} else if err := send(batch); err != nil {
+			if q.spool != nil {
+				q.spoolBatch(batch)
+			} else {
+				logWarn("VM", "Dropping %s on shutdown: %v", batch.description, err)
+			}
+		}
+		q.remove(batches)
+	}
+}
+
+// spoolBatch writes a batch to the disk spool, counting it as dropped on failure
+func (q *vmQueue) spoolBatch(batch *vmBatch) {
+	if err := q.spool.append(batch); err != nil {
+		logError("VM", "Failed to spool %s: %v", batch.description, err)
+		q.mu.Lock()
+		q.dropped++
+		q.mu.Unlock()
+	}
+}
+
+// replaySpool attempts to deliver spooled data (called periodically while idle)
+func (q *vmQueue) replaySpool(send func(*vmBatch) error) {
+	if q.spool == nil || !q.spool.pending() {
+		return
+	}
+
+	err := q.spool.replay(func(metrics string) error {
+		return send(&vmBatch{metrics: metrics, timestamp: time.Now(), description: "spooled metrics"})
+	})
+	if err != nil {
+		logWarn("VM", "Spool replay paused: %v", err)
+		return
+	}
+	logInfo("VM", "Spool replay complete, VictoriaMetrics is reachable again")
+}
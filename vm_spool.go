@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// VictoriaMetrics Disk Spool
+// ============================================================================
+
+const (
+	spoolCurrentFile  = "current.prom" // Segment receiving appends
+	spoolSegmentGlob  = "segment-*.prom"
+	spoolReplayChunk  = 1 << 20 // Max bytes per replay request
+	spoolReplayPeriod = 30 * time.Second
+)
+
+// vmSpool persists undeliverable batches to append-only files and replays them later
+//
+// Batches are stored in Prometheus text format with their original timestamps,
+// so replayed samples land at the correct time. Files are only accessed by the
+// delivery worker goroutine, so no locking is needed.
+//
+// Replay is at-least-once: if a segment fails half way it is resent from the
+// start, and VictoriaMetrics deduplicates identical samples.
+type vmSpool struct {
+	dir      string
+	maxBytes int64
+	size     int64 // Current total size of all spool files
+}
+
+// newVMSpool opens (or creates) the spool directory and accounts for leftover files
+func newVMSpool(dir string, maxBytes int64) (*vmSpool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create spool directory: %w", err)
+	}
+
+	s := &vmSpool{dir: dir, maxBytes: maxBytes}
+	for _, path := range s.files() {
+		if info, err := os.Stat(path); err == nil {
+			s.size += info.Size()
+		}
+	}
+
+	if s.size > 0 {
+		logInfo("VM", "Spool contains %d bytes from a previous run, will replay when VictoriaMetrics is reachable", s.size)
+	}
+	return s, nil
+}
+
+// pending reports whether any spooled data is waiting for replay
+func (s *vmSpool) pending() bool {
+	return s.size > 0
+}
+
+// append writes a batch to the current segment
+func (s *vmSpool) append(batch *vmBatch) error {
+	if s.size+int64(len(batch.metrics)) > s.maxBytes {
+		return fmt.Errorf("spool full (%d bytes)", s.maxBytes)
+	}
+
+	file, err := os.OpenFile(filepath.Join(s.dir, spoolCurrentFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	n, err := file.WriteString(batch.metrics)
+	s.size += int64(n)
+	return err
+}
+
+// replay sends all spooled segments in order, deleting each once delivered
+// Stops at the first failure so remaining data stays on disk
+func (s *vmSpool) replay(send func(metrics string) error) error {
+	// Seal the current segment so new appends go to a fresh file
+	current := filepath.Join(s.dir, spoolCurrentFile)
+	if _, err := os.Stat(current); err == nil {
+		sealed := filepath.Join(s.dir, fmt.Sprintf("segment-%d.prom", time.Now().UnixNano()))
+		if err := os.Rename(current, sealed); err != nil {
+			return fmt.Errorf("seal spool segment: %w", err)
+		}
+	}
+
+	segments, _ := filepath.Glob(filepath.Join(s.dir, spoolSegmentGlob))
+	sort.Strings(segments) // Names embed a nanosecond timestamp, so this is chronological
+
+	for _, path := range segments {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", filepath.Base(path), err)
+		}
+
+		for _, chunk := range splitLines(data, spoolReplayChunk) {
+			if err := send(chunk); err != nil {
+				return err
+			}
+		}
+
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("remove %s: %w", filepath.Base(path), err)
+		}
+		s.size -= int64(len(data))
+		logInfo("VM", "Replayed spooled segment %s (%d bytes)", filepath.Base(path), len(data))
+	}
+
+	if s.size < 0 || len(s.files()) == 0 {
+		s.size = 0
+	}
+	return nil
+}
+
+// files lists all spool files (sealed segments and the current segment)
+func (s *vmSpool) files() []string {
+	files, _ := filepath.Glob(filepath.Join(s.dir, spoolSegmentGlob))
+	if _, err := os.Stat(filepath.Join(s.dir, spoolCurrentFile)); err == nil {
+		files = append(files, filepath.Join(s.dir, spoolCurrentFile))
+	}
+	return files
+}
+
+// splitLines splits data into chunks of at most max bytes on line boundaries
+func splitLines(data []byte, max int) []string {
+	var chunks []string
+	for len(data) > 0 {
+		end := len(data)
+		if end > max {
+			end = bytes.LastIndexByte(data[:max], '\n') + 1
+			if end <= 0 {
+				end = max // Single line longer than max
+			}
+		}
+		if chunk := strings.TrimSpace(string(data[:end])); chunk != "" {
+			chunks = append(chunks, chunk+"\n")
+		}
+		data = data[end:]
+	}
+	return chunks
+}
@@ -5,10 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/fs"
-	"log"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,21 +21,54 @@ import (
 // ============================================================================
 
 // Embed static files into binary (production mode)
+//
 //go:embed web
 var embeddedFS embed.FS
 
 // WebServer handles HTTP/WebSocket server for real-time monitoring
 type WebServer struct {
-	config           *WebConfig
-	uplinkInterfaces map[string]bool
-	server           *http.Server
-	vmClient         *VMClient         // For historical data queries
-	userConfig       *UserConfigManager // For user configuration management
+	config     *WebConfig
+	interfaces []string            // Monitored interfaces
+	groups     []InterfaceGroup    // Virtual interfaces (summed members)
+	members    map[string][]string // Bridge/bond -> discovered member ports
+	monitorMu  sync.RWMutex        // Guards interfaces, groups and members (configuration reload, discovery)
+	server     *http.Server
+	client     RouterClient             // For interface metadata queries
+	vmClient   *VMClient                // For historical data queries
+	userConfig *UserConfigManager       // For user configuration management
+	sessions   *SessionCollector        // For PPP/hotspot session queries (nil if disabled)
+	health     *SystemResourceCollector // For router health queries (nil if disabled)
+	percentile *PercentileTracker       // For percentile queries (nil if disabled)
+	status     *MonitorStatus           // For health probes
+	telemetry  *Telemetry               // For internal metrics on /metrics
+	events     *InterfaceEventTracker   // For interface state events (nil if disabled)
+	topTalkers *TopTalkersCollector     // For torch top talkers (nil if disabled)
+	ping       *PingCollector           // For latency probes (nil if disabled)
+	capsman    *CAPsMANCollector        // For per-AP/SSID WiFi stats (nil if disabled)
+	netwatch   *NetwatchCollector       // For router netwatch states (nil if disabled)
+	audit      *AuditCollector          // For management sessions and login failures (nil if disabled)
+	topology   *TopologyTracker         // For site/router WAN roll-ups (nil if disabled)
+	trunks     *TrunkTracker            // For VLAN trunk utilization (nil if disabled)
+	shaper     *ShaperController        // For queue limit policies and actions (nil if disabled)
+	schedule   *InterfaceScheduler      // For interface windows, overrides and actions (nil if disabled)
+	billing    *BillingGenerator        // For per-customer billing reports (nil if disabled)
+	flows      *FlowCollector           // For NetFlow/IPFIX conversations (nil if disabled)
+	hosts      *ClientCollector         // For DHCP/ARP client names (nil if disabled)
+	admin      *Monitor                 // For runtime configuration changes (nil unless WEB_ADMIN_ENABLED)
+	auth       *WebAuth                 // Authentication (nil if disabled)
+
+	extraLabels  string         // Static labels added to /metrics series
+	metricPrefix string         // Metric name prefix (METRIC_PREFIX)
+	metricUnit   string         // Rate unit of /metrics ("bytes" or "bits")
+	rateScale    float64        // Multiplier from bytes/s to metricUnit
+	location     *time.Location // TIMEZONE: days and months of the history and billing APIs
 
 	// WebSocket client management
-	clients   map[*websocket.Conn]bool
+	clients   map[*websocket.Conn]*wsClient
 	clientsMu sync.RWMutex
 	upgrader  websocket.Upgrader
+	changes   *changeFilter // Skips samples without changed rates (nil = push every sample)
+	smoother  *rateSmoother // Smoothed rates pushed to clients (nil = measured rates)
 
 	// Latest stats cache
 	latestStats   map[string]*RateInfo
@@ -50,18 +84,18 @@ func getWebFS() (http.FileSystem, bool) {
 
 	// Check if web directory exists (developer mode)
 	if stat, err := os.Stat(webDir); err == nil && stat.IsDir() {
-		log.Printf("[Web] Developer mode: Using local files from '%s/' directory", webDir)
-		log.Printf("[Web] 💡 Tip: Remove '%s/' directory to test production mode (embedded files)", webDir)
+		logInfo("Web", "Developer mode: Using local files from '%s/' directory", webDir)
+		logInfo("Web", "💡 Tip: Remove '%s/' directory to test production mode (embedded files)", webDir)
 		return http.Dir(webDir), true
 	}
 
 	// Production mode: use embedded files
-	log.Println("[Web] Production mode: Using embedded files from binary")
+	logInfo("Web", "Production mode: Using embedded files from binary")
 
 	// Strip "web" prefix from embedded FS
 	webContent, err := fs.Sub(embeddedFS, webDir)
 	if err != nil {
-		log.Printf("[Web] Warning: Failed to access embedded files: %v", err)
+		logWarn("Web", "Failed to access embedded files: %v", err)
 		return nil, false
 	}
 
@@ -69,32 +103,35 @@ func getWebFS() (http.FileSystem, bool) {
 }
 
 // NewWebServer creates a new web server
-func NewWebServer(config *WebConfig, uplinkInterfaces []string, vmClient *VMClient) *WebServer {
-	log.Printf("[Web] Web server initialized (addr: %s)", config.ListenAddr)
-
-	// Convert uplink interface list to set
-	uplinkSet := make(map[string]bool, len(uplinkInterfaces))
-	for _, iface := range uplinkInterfaces {
-		uplinkSet[iface] = true
-	}
-
-	// Initialize user configuration manager
-	userConfigMgr, err := NewUserConfigManager()
-	if err != nil {
-		log.Printf("[Web] Warning: Failed to initialize user config: %v", err)
-	}
+func NewWebServer(appConfig *Config, client RouterClient, userConfig *UserConfigManager, vmClient *VMClient, sessions *SessionCollector, health *SystemResourceCollector, percentile *PercentileTracker, status *MonitorStatus, telemetry *Telemetry) *WebServer {
+	config := appConfig.Web
+	logInfo("Web", "Web server initialized (addr: %s)", config.ListenAddr)
 
 	ws := &WebServer{
-		config:           config,
-		uplinkInterfaces: uplinkSet,
-		vmClient:         vmClient,
-		userConfig:       userConfigMgr,
-		clients:          make(map[*websocket.Conn]bool),
-		latestStats:      make(map[string]*RateInfo),
+		config:     config,
+		interfaces: appConfig.Interfaces,
+		groups:     appConfig.Groups,
+		client:     client,
+		vmClient:   vmClient,
+		userConfig: userConfig,
+		sessions:   sessions,
+		health:     health,
+		percentile: percentile,
+		status:     status,
+		telemetry:  telemetry,
+
+		extraLabels:  formatExtraLabels(appConfig.ExtraLabels),
+		metricPrefix: appConfig.MetricPrefix,
+		metricUnit:   appConfig.MetricUnit,
+		rateScale:    rateScale(appConfig.MetricUnit),
+		location:     appConfig.Location,
+		auth:         NewWebAuth(config, userConfig),
+		clients:      make(map[*websocket.Conn]*wsClient),
+		latestStats:  make(map[string]*RateInfo),
+		changes:      newChangeFilter(config.Changes),
+		smoother:     newRateSmoother(config.Smoothing),
 		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true // Allow all origins for now
-			},
+			CheckOrigin: checkWebSocketOrigin(config.CORSOrigins),
 		},
 	}
 
@@ -106,33 +143,104 @@ func NewWebServer(config *WebConfig, uplinkInterfaces []string, vmClient *VMClie
 		// Get appropriate file system (local or embedded)
 		webFS, isDev := getWebFS()
 		if webFS != nil {
-			fileServer := http.FileServer(webFS)
-			mux.Handle("/", fileServer)
+			mux.Handle("/", staticHandler(webFS, isDev))
 
 			// Log mode for clarity
 			if isDev {
-				log.Println("[Web] Static files: Hot-reload enabled (changes take effect immediately)")
+				logInfo("Web", "Static files: Hot-reload enabled (changes take effect immediately)")
 			} else {
-				log.Println("[Web] Static files: Serving from embedded binary")
+				logInfo("Web", "Static files: Serving from embedded binary")
 			}
 		} else {
-			log.Println("[Web] ERROR: Failed to initialize file system")
+			logError("Web", "Failed to initialize file system")
 		}
 	}
 
 	if config.EnableAPI {
 		mux.HandleFunc("/api/current", ws.handleCurrentStats)
+		mux.HandleFunc("/api/interfaces", ws.handleInterfaces)
 		mux.HandleFunc("/api/history", ws.handleHistoryQuery)
+		mux.HandleFunc("/api/history/compare", ws.handleHistoryCompare)
 		mux.HandleFunc("/api/config/labels", ws.handleInterfaceLabels)
+		mux.HandleFunc("/api/config/uplinks", ws.handleUplinkInterfaces)
+		mux.HandleFunc("/api/config/display", ws.handleDisplayConfig)
+		mux.HandleFunc("/api/sessions", ws.handleSessions)
+		mux.HandleFunc("/api/system", ws.handleSystemResource)
+		mux.HandleFunc("/api/percentile", ws.handlePercentile)
+		mux.HandleFunc("/api/events", ws.handleEvents)
+		mux.HandleFunc("/api/toptalkers", ws.handleTopTalkers)
+		mux.HandleFunc("/api/ping", ws.handlePing)
+		mux.HandleFunc("/api/capsman", ws.handleCAPsMAN)
+		mux.HandleFunc("/api/netwatch", ws.handleNetwatch)
+		mux.HandleFunc("/api/audit", ws.handleAudit)
+		mux.HandleFunc("/api/topology", ws.handleTopology)
+		mux.HandleFunc("/api/trunks", ws.handleTrunks)
+		mux.HandleFunc("/api/shaper", ws.handleShaper)
+		mux.HandleFunc("/api/schedule", ws.handleSchedule)
+		mux.HandleFunc("/api/billing", ws.handleBilling)
+		mux.HandleFunc("/api/flows", ws.handleFlows)
+		mux.HandleFunc("/api/clients", ws.handleClients)
+		mux.HandleFunc("/metrics", ws.handleMetrics)
+		ws.registerGrafanaRoutes(mux)
 	}
 
 	if config.EnableRealtime {
 		mux.HandleFunc("/api/realtime", ws.handleWebSocket)
 	}
 
+	// Health probes are always available (and exempt from authentication)
+	mux.HandleFunc("/healthz", ws.handleHealthz)
+	mux.HandleFunc("/readyz", ws.handleReadyz)
+
+	// Protect all routes when authentication is configured
+	var handler http.Handler = mux
+	if ws.auth != nil {
+		mux.HandleFunc("/api/login", ws.auth.handleLogin)
+		mux.HandleFunc("/api/logout", ws.auth.handleLogout)
+
+		// Changing the configuration is never possible without authentication
+		if config.AdminEnabled {
+			mux.HandleFunc("/api/admin/config", ws.handleAdminConfig)
+			mux.HandleFunc("/api/admin/users", ws.handleAdminUsers)
+			mux.HandleFunc("/api/admin/tokens", ws.handleAdminTokens)
+			mux.HandleFunc("/api/admin/schedule", ws.handleAdminSchedule)
+			logInfo("Web", "Admin API enabled: /api/admin/config, /api/admin/users, /api/admin/tokens, /api/admin/schedule")
+		}
+		handler = ws.auth.Middleware(mux)
+	}
+
+	// Compress JSON and text responses (history queries can be megabytes)
+	if config.Compression {
+		handler = compressResponses(handler)
+	}
+
+	// Per-IP rate limit, before authentication so login attempts count too
+	if config.RateLimit > 0 {
+		handler = limitRequests(newRateLimiter(config.RateLimit, config.RateBurst), handler)
+		logInfo("Web", "Rate limit: %d requests/minute per IP (burst %d)", config.RateLimit, config.RateBurst)
+	}
+
+	// Reverse proxy support: URL prefix, CORS and X-Forwarded-* from trusted proxies
+	probes := http.NewServeMux()
+	probes.HandleFunc("/healthz", ws.handleHealthz)
+	probes.HandleFunc("/readyz", ws.handleReadyz)
+	handler = withBasePath(config.BasePath, handler, probes)
+	handler = cors(config.CORSOrigins, handler)
+
+	// Panics are logged and answered with 500; access logs see the real client address
+	handler = recoverPanics(handler)
+	if config.AccessLog {
+		handler = logRequests(handler)
+	}
+	trusted, _ := parseCIDRs(config.TrustedProxies) // Validated in Config.Validate
+	handler = forwardedHeaders(trusted, handler)
+	if config.BasePath != "" {
+		logInfo("Web", "Serving under base path %s/", config.BasePath)
+	}
+
 	ws.server = &http.Server{
 		Addr:    config.ListenAddr,
-		Handler: mux,
+		Handler: handler,
 	}
 
 	return ws
@@ -140,12 +248,12 @@ func NewWebServer(config *WebConfig, uplinkInterfaces []string, vmClient *VMClie
 
 // Start starts the web server (non-blocking)
 func (w *WebServer) Start() error {
-	log.Printf("[Web] Starting web server on %s", w.config.ListenAddr)
+	logInfo("Web", "Starting web server on %s", w.config.ListenAddr)
 
 	// Start server in goroutine
 	go func() {
 		if err := w.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("[Web] Server error: %v", err)
+			logError("Web", "Server error: %v", err)
 		}
 	}()
 
@@ -154,14 +262,14 @@ func (w *WebServer) Start() error {
 
 // Stop stops the web server gracefully
 func (w *WebServer) Stop() error {
-	log.Println("[Web] Stopping web server")
+	logInfo("Web", "Stopping web server")
 
 	// Close all WebSocket connections
 	w.clientsMu.Lock()
 	for client := range w.clients {
 		client.Close()
 	}
-	w.clients = make(map[*websocket.Conn]bool)
+	w.clients = make(map[*websocket.Conn]*wsClient)
 	w.clientsMu.Unlock()
 
 	// Shutdown HTTP server
@@ -172,6 +280,19 @@ func (w *WebServer) Stop() error {
 	return nil
 }
 
+// WriteHeader implements OutputWriter (the server is started by Monitor.Start)
+func (w *WebServer) WriteHeader() {}
+
+// WriteStats implements OutputWriter by broadcasting to WebSocket clients
+func (w *WebServer) WriteStats(timestamp time.Time, stats map[string]*RateInfo) {
+	w.BroadcastStats(timestamp, stats)
+}
+
+// Close implements OutputWriter by stopping the server
+func (w *WebServer) Close() {
+	w.Stop()
+}
+
 // BroadcastStats broadcasts statistics to all connected WebSocket clients
 func (w *WebServer) BroadcastStats(timestamp time.Time, stats map[string]*RateInfo) {
 	// Update cache
@@ -184,26 +305,67 @@ func (w *WebServer) BroadcastStats(timestamp time.Time, stats map[string]*RateIn
 	if !w.config.EnableRealtime {
 		return
 	}
+	stats = w.smoother.apply(stats) // Only the stream: /api/current and /metrics keep the measured rates
+	if w.changes != nil && !w.changes.anyChanged(stats, timestamp) {
+		return
+	}
 
 	// Convert to display format
 	data := w.convertToDisplayFormat(timestamp, stats)
 
-	// Marshal to JSON
+	// Marshal to JSON once for clients using default options
 	jsonData, err := json.Marshal(data)
 	if err != nil {
-		log.Printf("[Web] Failed to marshal stats: %v", err)
+		logError("Web", "Failed to marshal stats: %v", err)
 		return
 	}
 
-	// Broadcast to all clients
+	// Broadcast to all clients, honoring per-client subscription options
 	w.clientsMu.RLock()
 	defer w.clientsMu.RUnlock()
 
-	for client := range w.clients {
-		err := client.WriteMessage(websocket.TextMessage, jsonData)
+	for _, client := range w.clients {
+		if !client.due(timestamp) {
+			continue
+		}
+
+		if client.isDefault() {
+			err = client.write(jsonData) // Reuse shared payload
+		} else {
+			err = client.writeJSON(client.filter(data))
+		}
 		if err != nil {
-			log.Printf("[Web] WebSocket write error: %v", err)
-			// Client will be removed on next read/write
+			logWarn("Web", "WebSocket write error: %v", err)
+			// Closing unblocks the read loop, which removes the client
+			client.conn.Close()
+		}
+	}
+}
+
+// BroadcastEvent pushes an interface state event to WebSocket clients subscribed to the interface
+//
+//	{"type": "interface_event", "event": {...}}
+func (w *WebServer) BroadcastEvent(event InterfaceEvent) {
+	if !w.config.EnableRealtime {
+		return
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{"type": "interface_event", "event": event})
+	if err != nil {
+		logError("Web", "Failed to marshal event: %v", err)
+		return
+	}
+
+	w.clientsMu.RLock()
+	defer w.clientsMu.RUnlock()
+
+	for _, client := range w.clients {
+		if !client.wants(event.Interface) {
+			continue
+		}
+		if err := client.write(jsonData); err != nil {
+			logWarn("Web", "WebSocket write error: %v", err)
+			client.conn.Close()
 		}
 	}
 }
@@ -219,27 +381,308 @@ func (w *WebServer) handleCurrentStats(rw http.ResponseWriter, r *http.Request)
 	timestamp := w.latestTime
 	w.latestStatsMu.RUnlock()
 
-	data := w.convertToDisplayFormat(timestamp, stats)
+	data := w.convertToDisplayFormat(timestamp, identityFrom(r).visibleStats(stats))
 
 	rw.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(rw).Encode(data)
 }
 
+// handleMetrics exposes the latest rates in Prometheus text format for scraping
+func (w *WebServer) handleMetrics(rw http.ResponseWriter, r *http.Request) {
+	w.latestStatsMu.RLock()
+	names := make([]string, 0, len(w.latestStats))
+	for name := range w.latestStats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var rx, tx, rxBytes, txBytes, speed, rxUtil, txUtil, up, linkDowns strings.Builder
+	for _, name := range names {
+		info := w.latestStats[name]
+		series := fmt.Sprintf("interface=\"%s\"", escapeLabelValue(name))
+		if label := w.userConfig.CustomLabel(name); label != "" {
+			series += fmt.Sprintf(",label=\"%s\"", escapeLabelValue(label))
+		}
+		fmt.Fprintf(&rx, "mikrotik_interface_rx_rate{%s} %.2f\n", series, info.RxRate*w.rateScale)
+		fmt.Fprintf(&tx, "mikrotik_interface_tx_rate{%s} %.2f\n", series, info.TxRate*w.rateScale)
+		fmt.Fprintf(&rxBytes, "mikrotik_interface_rx_bytes_total{%s} %d\n", series, info.RxBytes)
+		fmt.Fprintf(&txBytes, "mikrotik_interface_tx_bytes_total{%s} %d\n", series, info.TxBytes)
+		if info.LinkSpeed > 0 {
+			fmt.Fprintf(&speed, "mikrotik_interface_link_speed_bits{%s} %.0f\n", series, info.LinkSpeed)
+			fmt.Fprintf(&rxUtil, "mikrotik_interface_rx_utilization_ratio{%s} %.4f\n", series, info.RxUtilization)
+			fmt.Fprintf(&txUtil, "mikrotik_interface_tx_utilization_ratio{%s} %.4f\n", series, info.TxUtilization)
+		}
+		if info.State != nil {
+			fmt.Fprintf(&up, "mikrotik_interface_up{%s} %d\n", series, info.State.upValue())
+			fmt.Fprintf(&linkDowns, "mikrotik_interface_link_downs_total{%s} %d\n", series, info.State.LinkDowns)
+		}
+	}
+	w.latestStatsMu.RUnlock()
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "# HELP mikrotik_interface_rx_rate Current receive rate in %s per second\n", w.metricUnit)
+	fmt.Fprintln(&out, "# TYPE mikrotik_interface_rx_rate gauge")
+	fmt.Fprint(&out, injectLabels(rx.String(), w.extraLabels))
+	fmt.Fprintf(&out, "# HELP mikrotik_interface_tx_rate Current transmit rate in %s per second\n", w.metricUnit)
+	fmt.Fprintln(&out, "# TYPE mikrotik_interface_tx_rate gauge")
+	fmt.Fprint(&out, injectLabels(tx.String(), w.extraLabels))
+	fmt.Fprintln(&out, "# HELP mikrotik_interface_rx_bytes_total Total bytes received (router counter)")
+	fmt.Fprintln(&out, "# TYPE mikrotik_interface_rx_bytes_total counter")
+	fmt.Fprint(&out, injectLabels(rxBytes.String(), w.extraLabels))
+	fmt.Fprintln(&out, "# HELP mikrotik_interface_tx_bytes_total Total bytes transmitted (router counter)")
+	fmt.Fprintln(&out, "# TYPE mikrotik_interface_tx_bytes_total counter")
+	fmt.Fprint(&out, injectLabels(txBytes.String(), w.extraLabels))
+	if speed.Len() > 0 {
+		fmt.Fprintln(&out, "# HELP mikrotik_interface_link_speed_bits Link speed in bits per second")
+		fmt.Fprintln(&out, "# TYPE mikrotik_interface_link_speed_bits gauge")
+		fmt.Fprint(&out, injectLabels(speed.String(), w.extraLabels))
+		fmt.Fprintln(&out, "# HELP mikrotik_interface_rx_utilization_ratio Current receive rate as a fraction of the link speed")
+		fmt.Fprintln(&out, "# TYPE mikrotik_interface_rx_utilization_ratio gauge")
+		fmt.Fprint(&out, injectLabels(rxUtil.String(), w.extraLabels))
+		fmt.Fprintln(&out, "# HELP mikrotik_interface_tx_utilization_ratio Current transmit rate as a fraction of the link speed")
+		fmt.Fprintln(&out, "# TYPE mikrotik_interface_tx_utilization_ratio gauge")
+		fmt.Fprint(&out, injectLabels(txUtil.String(), w.extraLabels))
+	}
+	if up.Len() > 0 {
+		fmt.Fprintln(&out, "# HELP mikrotik_interface_up Whether the interface is running and enabled (1) or not (0)")
+		fmt.Fprintln(&out, "# TYPE mikrotik_interface_up gauge")
+		fmt.Fprint(&out, injectLabels(up.String(), w.extraLabels))
+		fmt.Fprintln(&out, "# HELP mikrotik_interface_link_downs_total Link-down transitions since router boot (router counter)")
+		fmt.Fprintln(&out, "# TYPE mikrotik_interface_link_downs_total counter")
+		fmt.Fprint(&out, injectLabels(linkDowns.String(), w.extraLabels))
+	}
+	if w.ping != nil {
+		if snapshot := w.ping.Latest(); snapshot != nil {
+			fmt.Fprint(&out, injectLabels(pingMetrics(snapshot, false), w.extraLabels))
+		}
+	}
+	if w.capsman != nil {
+		if snapshot := w.capsman.Latest(); snapshot != nil {
+			fmt.Fprint(&out, injectLabels(capsmanMetrics(snapshot, w.rateScale, false), w.extraLabels))
+		}
+	}
+	if w.netwatch != nil {
+		if snapshot := w.netwatch.Latest(); snapshot != nil {
+			fmt.Fprint(&out, injectLabels(netwatchMetrics(snapshot, false), w.extraLabels))
+		}
+	}
+	if w.audit != nil {
+		if snapshot := w.audit.Latest(); snapshot != nil {
+			fmt.Fprint(&out, injectLabels(auditMetrics(snapshot, false), w.extraLabels))
+		}
+	}
+	if w.topology != nil {
+		if router, timestamp := w.topology.Latest(); !timestamp.IsZero() {
+			fmt.Fprint(&out, injectLabels(topologyMetrics(router, w.rateScale), w.extraLabels))
+		}
+	}
+	if w.trunks != nil {
+		if snapshot := w.trunks.Latest(); snapshot != nil {
+			fmt.Fprint(&out, injectLabels(trunkMetrics(snapshot, w.rateScale), w.extraLabels))
+		}
+	}
+	if w.flows != nil {
+		fmt.Fprint(&out, injectLabels(flowExporterMetrics(w.flows.Exporters()), w.extraLabels))
+	}
+	fmt.Fprint(&out, injectLabels(w.telemetry.Metrics(time.Time{}), w.extraLabels))
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(rw, renameMetrics(out.String(), w.metricPrefix))
+}
+
+// ClientCount returns the number of connected WebSocket clients
+func (w *WebServer) ClientCount() int {
+	w.clientsMu.RLock()
+	defer w.clientsMu.RUnlock()
+	return len(w.clients)
+}
+
+// healthResponse is the JSON body of /healthz and /readyz
+type healthResponse struct {
+	Status           string        `json:"status"` // "ok" or "unavailable"
+	UptimeSeconds    float64       `json:"uptime_seconds"`
+	Router           RouterStatus  `json:"router"`
+	VictoriaMetrics  *VMPushStatus `json:"victoriametrics,omitempty"` // Omitted if VM is disabled
+	WebSocketClients int           `json:"websocket_clients"`
+}
+
+// healthStatus collects the current component states
+func (w *WebServer) healthStatus(now time.Time) *healthResponse {
+	return newHealthResponse(now, w.status, w.vmClient, w.ClientCount())
+}
+
+// newHealthResponse builds a probe response (vm is nil if VictoriaMetrics is disabled)
+func newHealthResponse(now time.Time, status *MonitorStatus, vm *VMClient, clients int) *healthResponse {
+	resp := &healthResponse{
+		Status:           "ok",
+		UptimeSeconds:    status.Uptime(now).Seconds(),
+		Router:           status.Router(now),
+		WebSocketClients: clients,
+	}
+	if vm != nil {
+		push := vm.PushStatus()
+		resp.VictoriaMetrics = &push
+	}
+	return resp
+}
+
+// writeHealth writes a health response with 200 or 503
+func writeHealth(rw http.ResponseWriter, resp *healthResponse, ok bool) {
+	code := http.StatusOK
+	if !ok {
+		resp.Status = "unavailable"
+		code = http.StatusServiceUnavailable
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(code)
+	json.NewEncoder(rw).Encode(resp)
+}
+
+// handleHealthz is the liveness probe: fails only if the sampling loop has stopped
+func (w *WebServer) handleHealthz(rw http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	writeHealth(rw, w.healthStatus(now), w.status.Alive(now))
+}
+
+// handleReadyz is the readiness probe: fails while no recent sample was read from RouterOS
+func (w *WebServer) handleReadyz(rw http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	resp := w.healthStatus(now)
+	writeHealth(rw, resp, resp.Router.Connected)
+}
+
+// SetInterfaces replaces the monitored interfaces and groups (configuration reload)
+func (w *WebServer) SetInterfaces(interfaces []string, groups []InterfaceGroup) {
+	w.monitorMu.Lock()
+	defer w.monitorMu.Unlock()
+	w.interfaces, w.groups = interfaces, groups
+}
+
+// SetMembers replaces the discovered member ports of the monitored bridges and bonds
+func (w *WebServer) SetMembers(members map[string][]string) {
+	w.monitorMu.Lock()
+	defer w.monitorMu.Unlock()
+	w.members = members
+}
+
+// handleInterfaces returns metadata for each monitored interface
+func (w *WebServer) handleInterfaces(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.monitorMu.RLock()
+	interfaces, monitoredGroups, members := w.interfaces, w.groups, w.members
+	w.monitorMu.RUnlock()
+
+	// Member ports are listed after the monitored interfaces
+	parents := memberParents(members)
+	if len(parents) > 0 {
+		monitored := toSet(interfaces)
+		var ports []string
+		for port := range parents {
+			if !monitored[port] {
+				ports = append(ports, port)
+			}
+		}
+		sort.Strings(ports)
+		interfaces = append(append([]string(nil), interfaces...), ports...)
+	}
+
+	// Web users only see their interfaces
+	if id := identityFrom(r); id.restricted() {
+		var visible []string
+		for _, name := range interfaces {
+			if id.canSee(name) {
+				visible = append(visible, name)
+			}
+		}
+		var visibleGroups []InterfaceGroup
+		for _, group := range monitoredGroups {
+			if id.canSee(group.Name) {
+				visibleGroups = append(visibleGroups, group)
+			}
+		}
+		interfaces, monitoredGroups = visible, visibleGroups
+	}
+
+	infos, err := queryInterfaceInfo(r.Context(), w.client, interfaces)
+	if err != nil {
+		logError("Web", "Interface query error: %v", err)
+		http.Error(rw, fmt.Sprintf("Query failed: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	type interfaceEntry struct {
+		InterfaceInfo
+		Label     string   `json:"label"`
+		Direction string   `json:"direction"`         // "uplink" or "downlink"
+		Members   []string `json:"members,omitempty"` // Group members, or member ports of a bridge/bond
+		Parent    string   `json:"parent,omitempty"`  // Bridge/bond of a member port
+	}
+
+	// Groups are reported as virtual interfaces (running if any member is running)
+	running := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		running[info.Name] = info.Running
+	}
+	groups := make([]interfaceEntry, 0, len(monitoredGroups))
+	for _, group := range monitoredGroups {
+		entry := interfaceEntry{
+			InterfaceInfo: InterfaceInfo{Name: group.Name, Type: "group"},
+			Members:       group.Members,
+		}
+		for _, member := range group.Members {
+			entry.Running = entry.Running || running[member]
+		}
+		groups = append(groups, entry)
+	}
+
+	entries := make([]interfaceEntry, 0, len(infos))
+	for _, info := range infos {
+		entry := interfaceEntry{InterfaceInfo: info, Direction: "downlink", Members: members[info.Name], Parent: parents[info.Name]}
+		entry.Label = w.userConfig.GetInterfaceLabel(info.Name)
+		if w.userConfig.IsUplink(info.Name) {
+			entry.Direction = "uplink"
+		}
+		entries = append(entries, entry)
+	}
+	for _, entry := range groups {
+		entry.Label = w.userConfig.GetInterfaceLabel(entry.Name)
+		entry.Direction = "downlink"
+		if w.userConfig.IsUplink(entry.Name) {
+			entry.Direction = "uplink"
+		}
+		entries = append(entries, entry)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(entries)
+}
+
 // handleWebSocket handles WebSocket connections
 func (w *WebServer) handleWebSocket(rw http.ResponseWriter, r *http.Request) {
 	conn, err := w.upgrader.Upgrade(rw, r, nil)
 	if err != nil {
-		log.Printf("[Web] WebSocket upgrade error: %v", err)
+		logError("Web", "WebSocket upgrade error: %v", err)
 		return
 	}
 
-	// Register client
+	// Register client (web users only receive their interfaces)
+	var visible map[string]bool
+	if id := identityFrom(r); id.restricted() {
+		visible = id.interfaces
+	}
+	client := newWSClient(conn, visible)
 	w.clientsMu.Lock()
-	w.clients[conn] = true
+	w.clients[conn] = client
 	clientCount := len(w.clients)
 	w.clientsMu.Unlock()
 
-	log.Printf("[Web] New WebSocket connection (total: %d)", clientCount)
+	logInfo("Web", "New WebSocket connection (total: %d)", clientCount)
 
 	// Send current stats immediately
 	w.latestStatsMu.RLock()
@@ -249,11 +692,12 @@ func (w *WebServer) handleWebSocket(rw http.ResponseWriter, r *http.Request) {
 
 	if len(stats) > 0 {
 		data := w.convertToDisplayFormat(timestamp, stats)
-		if jsonData, err := json.Marshal(data); err == nil {
-			conn.WriteMessage(websocket.TextMessage, jsonData)
-		}
+		client.writeJSON(client.filter(data))
 	}
 
+	// Ping the client periodically to detect half-open connections
+	go client.keepalive()
+
 	// Handle client disconnect
 	go func() {
 		defer func() {
@@ -261,19 +705,50 @@ func (w *WebServer) handleWebSocket(rw http.ResponseWriter, r *http.Request) {
 			delete(w.clients, conn)
 			clientCount := len(w.clients)
 			w.clientsMu.Unlock()
+			close(client.done)
 			conn.Close()
-			log.Printf("[Web] WebSocket disconnected (remaining: %d)", clientCount)
+			logInfo("Web", "WebSocket disconnected (remaining: %d)", clientCount)
 		}()
 
-		// Read loop (just to detect disconnect)
+		// Read loop: handle subscribe messages and detect disconnect
+		// The read deadline expires if no pong arrives within wsPongWait
+		client.prepareRead()
 		for {
-			if _, _, err := conn.ReadMessage(); err != nil {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
 				break
 			}
+			w.handleClientMessage(client, message)
 		}
 	}()
 }
 
+// handleClientMessage processes a message sent by a WebSocket client
+func (w *WebServer) handleClientMessage(client *wsClient, message []byte) {
+	var msg wsSubscribeMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		client.writeJSON(map[string]string{"type": "error", "error": "invalid JSON message"})
+		return
+	}
+
+	switch msg.Type {
+	case "subscribe":
+		client.applySubscribe(msg)
+		client.writeJSON(client.options())
+
+		// Push current stats right away so the new filter takes effect immediately
+		w.latestStatsMu.RLock()
+		stats := w.latestStats
+		timestamp := w.latestTime
+		w.latestStatsMu.RUnlock()
+		if len(stats) > 0 {
+			client.writeJSON(client.filter(w.convertToDisplayFormat(timestamp, stats)))
+		}
+	default:
+		client.writeJSON(map[string]string{"type": "error", "error": "unknown message type: " + msg.Type})
+	}
+}
+
 // ============================================================================
 // Helper Functions
 // ============================================================================
@@ -283,23 +758,61 @@ func (w *WebServer) convertToDisplayFormat(timestamp time.Time, stats map[string
 	interfaces := make(map[string]interface{})
 
 	for name, info := range stats {
-		var uploadRate, downloadRate float64
+		var uploadRate, downloadRate, uploadUtil, downloadUtil float64
 
 		// Convert RX/TX to Upload/Download based on interface type
-		if w.uplinkInterfaces[name] {
+		if w.userConfig.IsUplink(name) {
 			// Uplink: no swap
-			uploadRate = info.TxRate
-			downloadRate = info.RxRate
+			uploadRate, uploadUtil = info.TxRate, info.TxUtilization
+			downloadRate, downloadUtil = info.RxRate, info.RxUtilization
 		} else {
 			// Downlink: swap TX/RX
-			uploadRate = info.RxRate
-			downloadRate = info.TxRate
+			uploadRate, uploadUtil = info.RxRate, info.RxUtilization
+			downloadRate, downloadUtil = info.TxRate, info.TxUtilization
 		}
 
-		interfaces[name] = map[string]interface{}{
+		data := map[string]interface{}{
+			"label":         w.userConfig.GetInterfaceLabel(name),
 			"upload_rate":   uploadRate,
 			"download_rate": downloadRate,
 		}
+		// Utilization in percent, only when the link speed is known
+		if info.LinkSpeed > 0 {
+			data["link_speed"] = info.LinkSpeed
+			data["upload_utilization"] = uploadUtil * 100
+			data["download_utilization"] = downloadUtil * 100
+		}
+		if info.State != nil {
+			data["state"] = info.State.String()
+		}
+		interfaces[name] = data
+	}
+
+	// Member ports point at their bridge/bond, which gets the sum of their rates
+	// (oriented like the parent) to compare with its own counters
+	w.monitorMu.RLock()
+	members := w.members
+	w.monitorMu.RUnlock()
+	for parent, ports := range members {
+		data, ok := interfaces[parent].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var rx, tx float64
+		for _, port := range ports {
+			if info := stats[port]; info != nil {
+				rx, tx = rx+info.RxRate, tx+info.TxRate
+			}
+			if member, ok := interfaces[port].(map[string]interface{}); ok {
+				member["parent"] = parent
+			}
+		}
+		upload, download := rx, tx
+		if w.userConfig.IsUplink(parent) {
+			upload, download = tx, rx
+		}
+		data["members_upload_rate"] = upload
+		data["members_download_rate"] = download
 	}
 
 	return map[string]interface{}{
@@ -318,16 +831,21 @@ func (w *WebServer) handleHistoryQuery(rw http.ResponseWriter, r *http.Request)
 
 	// Parse query parameters
 	query := r.URL.Query()
-	interfaceName := query.Get("interface")
+	interfaces, all := parseInterfaceParam(query["interface"])
 	startStr := query.Get("start")
 	endStr := query.Get("end")
 	interval := query.Get("interval")
 
 	// Validate required parameters
-	if interfaceName == "" {
+	if len(interfaces) == 0 && !all {
 		http.Error(rw, "Missing 'interface' parameter", http.StatusBadRequest)
 		return
 	}
+	interfaces, ok := identityFrom(r).scope(interfaces, all)
+	if !ok {
+		http.Error(rw, "Forbidden", http.StatusForbidden)
+		return
+	}
 
 	// Parse time range
 	var start, end time.Time
@@ -338,29 +856,19 @@ func (w *WebServer) handleHistoryQuery(rw http.ResponseWriter, r *http.Request)
 		end = time.Now()
 		start = end.Add(-24 * time.Hour)
 	} else {
-		// Try parsing as Unix timestamp (seconds)
-		if startInt, err2 := strconv.ParseInt(startStr, 10, 64); err2 == nil {
-			start = time.Unix(startInt, 0)
-		} else {
-			// Try parsing as RFC3339
-			start, err = time.Parse(time.RFC3339, startStr)
-			if err != nil {
-				http.Error(rw, "Invalid 'start' time format", http.StatusBadRequest)
-				return
-			}
+		start, err = parseTimeParam(startStr)
+		if err != nil {
+			http.Error(rw, "Invalid 'start' time format", http.StatusBadRequest)
+			return
 		}
 
 		if endStr == "" {
 			end = time.Now()
 		} else {
-			if endInt, err2 := strconv.ParseInt(endStr, 10, 64); err2 == nil {
-				end = time.Unix(endInt, 0)
-			} else {
-				end, err = time.Parse(time.RFC3339, endStr)
-				if err != nil {
-					http.Error(rw, "Invalid 'end' time format", http.StatusBadRequest)
-					return
-				}
+			end, err = parseTimeParam(endStr)
+			if err != nil {
+				http.Error(rw, "Invalid 'end' time format", http.StatusBadRequest)
+				return
 			}
 		}
 	}
@@ -376,31 +884,171 @@ func (w *WebServer) handleHistoryQuery(rw http.ResponseWriter, r *http.Request)
 		interval = "auto"
 	}
 
+	// Optional server-side downsampling
+	maxPoints := 0
+	if value := query.Get("max_points"); value != "" {
+		maxPoints, err = strconv.Atoi(value)
+		if err != nil || maxPoints < 2 {
+			http.Error(rw, "Invalid 'max_points' (must be an integer >= 2)", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Query VictoriaMetrics
 	resp, err := w.vmClient.QueryHistory(HistoryQueryParams{
-		Interface: interfaceName,
-		Start:     start,
-		End:       end,
-		Interval:  interval,
+		Interfaces: interfaces,
+		Start:      start,
+		End:        end,
+		Interval:   interval,
+		MaxPoints:  maxPoints,
 	})
 
 	if err != nil {
-		log.Printf("[Web] History query error: %v", err)
+		logError("Web", "History query error: %v", err)
 		http.Error(rw, fmt.Sprintf("Query failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// Convert to display format (swap RX/TX if needed)
-	w.convertHistoryToDisplayFormat(resp)
+	for _, series := range resp.Interfaces {
+		series.Label = w.userConfig.CustomLabel(series.Interface)
+		w.convertHistoryToDisplayFormat(series)
+	}
+
+	// Return JSON response (a single interface keeps the original response shape)
+	rw.Header().Set("Content-Type", "application/json")
+	if len(interfaces) == 1 && !all {
+		json.NewEncoder(rw).Encode(resp.Interfaces[0])
+		return
+	}
+	json.NewEncoder(rw).Encode(resp)
+}
+
+// parseTimeParam parses a time given as a Unix timestamp (seconds) or RFC3339
+func parseTimeParam(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// compareOffsets are the named comparisons of /api/history/compare
+var compareOffsets = map[string]time.Duration{
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+// handleHistoryCompare returns an interface's history over two ranges aligned for overlaying
+//
+// Parameters: interface (one), start/end (default: today, midnight to midnight),
+// compare=day|week (default: day) or offset=<duration> (e.g. 48h), interval, max_points.
+func (w *WebServer) handleHistoryCompare(rw http.ResponseWriter, r *http.Request) {
+	if w.vmClient == nil {
+		http.Error(rw, "VictoriaMetrics not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	interfaces, all := parseInterfaceParam(query["interface"])
+	if len(interfaces) != 1 || all {
+		http.Error(rw, "Exactly one 'interface' is required", http.StatusBadRequest)
+		return
+	}
+	if !identityFrom(r).canSee(interfaces[0]) {
+		http.Error(rw, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	now := time.Now().In(w.location)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, w.location)
+	end := start.AddDate(0, 0, 1)
+	var err error
+	if value := query.Get("start"); value != "" {
+		if start, err = parseTimeParam(value); err != nil {
+			http.Error(rw, "Invalid 'start' time format", http.StatusBadRequest)
+			return
+		}
+		end = now
+	}
+	if value := query.Get("end"); value != "" {
+		if end, err = parseTimeParam(value); err != nil {
+			http.Error(rw, "Invalid 'end' time format", http.StatusBadRequest)
+			return
+		}
+	}
+	if !start.Before(end) {
+		http.Error(rw, "Start time must be before end time", http.StatusBadRequest)
+		return
+	}
+
+	offset, ok := compareOffsets[query.Get("compare")]
+	switch {
+	case query.Get("offset") != "":
+		offset, err = time.ParseDuration(query.Get("offset"))
+		if err != nil || offset <= 0 {
+			http.Error(rw, "Invalid 'offset' (must be a positive duration such as 24h)", http.StatusBadRequest)
+			return
+		}
+	case query.Get("compare") == "":
+		offset = compareOffsets["day"]
+	case !ok:
+		http.Error(rw, "Invalid 'compare' (must be 'day' or 'week')", http.StatusBadRequest)
+		return
+	}
+
+	maxPoints := 0
+	if value := query.Get("max_points"); value != "" {
+		maxPoints, err = strconv.Atoi(value)
+		if err != nil || maxPoints < 2 {
+			http.Error(rw, "Invalid 'max_points' (must be an integer >= 2)", http.StatusBadRequest)
+			return
+		}
+	}
+
+	resp, err := w.vmClient.CompareHistory(HistoryQueryParams{
+		Interfaces: interfaces,
+		Start:      start,
+		End:        end,
+		Interval:   query.Get("interval"),
+		MaxPoints:  maxPoints,
+	}, offset)
+	if err != nil {
+		logError("Web", "History comparison error: %v", err)
+		http.Error(rw, fmt.Sprintf("Query failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	resp.Label = w.userConfig.CustomLabel(resp.Interface)
+	w.convertHistoryToDisplayFormat(resp.Current)
+	w.convertHistoryToDisplayFormat(resp.Previous)
 
-	// Return JSON response
 	rw.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(rw).Encode(resp)
 }
 
+// parseInterfaceParam collects interface names from repeated and/or comma-separated parameters
+// all is true for "interface=all", which selects every interface with stored data.
+func parseInterfaceParam(values []string) (interfaces []string, all bool) {
+	seen := make(map[string]bool)
+	for _, value := range values {
+		for _, name := range parseCommaSeparated(value, "") {
+			if name == "all" {
+				all = true
+			} else if !seen[name] {
+				seen[name] = true
+				interfaces = append(interfaces, name)
+			}
+		}
+	}
+	if all {
+		return nil, true
+	}
+	return interfaces, false
+}
+
 // convertHistoryToDisplayFormat converts RX/TX to Upload/Download for history data
 func (w *WebServer) convertHistoryToDisplayFormat(resp *HistoryResponse) {
-	isUplink := w.uplinkInterfaces[resp.Interface]
+	isUplink := w.userConfig.IsUplink(resp.Interface)
 
 	for i := range resp.DataPoints {
 		dp := &resp.DataPoints[i]
@@ -416,6 +1064,383 @@ func (w *WebServer) convertHistoryToDisplayFormat(resp *HistoryResponse) {
 	}
 }
 
+// handleSessions returns the latest PPP/hotspot session snapshot
+func (w *WebServer) handleSessions(rw http.ResponseWriter, r *http.Request) {
+	if w.sessions == nil {
+		http.Error(rw, "Session collector not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	snapshot := w.sessions.Latest()
+	if snapshot == nil {
+		snapshot = &SessionSnapshot{
+			Counts:   make(map[string]int),
+			Sessions: make([]SessionRate, 0),
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(snapshot)
+}
+
+// handleSystemResource returns the latest router health snapshot
+func (w *WebServer) handleSystemResource(rw http.ResponseWriter, r *http.Request) {
+	if w.health == nil {
+		http.Error(rw, "Health collector not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	res := w.health.Latest()
+	if res == nil {
+		http.Error(rw, "No health data collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(res)
+}
+
+// handlePercentile returns the billing percentile for each interface (Upload/Download)
+func (w *WebServer) handlePercentile(rw http.ResponseWriter, r *http.Request) {
+	if w.percentile == nil {
+		http.Error(rw, "Percentile tracking not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	config := w.percentile.config
+	id := identityFrom(r)
+	interfaces := make(map[string]interface{})
+	var windowStart time.Time
+
+	for _, result := range w.percentile.Results() {
+		windowStart = result.WindowStart
+		if !id.canSee(result.Interface) {
+			continue
+		}
+
+		// Convert RX/TX to Upload/Download based on interface type
+		uploadRate, downloadRate := result.RxRate, result.TxRate
+		if w.userConfig.IsUplink(result.Interface) {
+			uploadRate, downloadRate = result.TxRate, result.RxRate
+		}
+
+		interfaces[result.Interface] = map[string]interface{}{
+			"label":         w.userConfig.GetInterfaceLabel(result.Interface),
+			"upload_rate":   uploadRate,
+			"download_rate": downloadRate,
+			"samples":       result.Samples,
+		}
+	}
+
+	data := map[string]interface{}{
+		"percentile":      config.Percentile,
+		"sample_interval": int(config.SampleInterval.Seconds()),
+		"window":          config.Window,
+		"interfaces":      interfaces,
+	}
+	if !windowStart.IsZero() {
+		data["window_start"] = windowStart.Format(time.RFC3339)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(data)
+}
+
+// handleEvents returns the latest interface state events, newest first
+func (w *WebServer) handleEvents(rw http.ResponseWriter, r *http.Request) {
+	if w.events == nil {
+		http.Error(rw, "Interface events not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	id := identityFrom(r)
+	events := w.events.Recent()
+	if id.restricted() {
+		visible := make([]InterfaceEvent, 0, len(events))
+		for _, event := range events {
+			if id.canSee(event.Interface) {
+				visible = append(visible, event)
+			}
+		}
+		events = visible
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(map[string]interface{}{"events": events})
+}
+
+// handlePing returns the latest latency probe results
+func (w *WebServer) handlePing(rw http.ResponseWriter, r *http.Request) {
+	if w.ping == nil {
+		http.Error(rw, "Latency probes not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	snapshot := w.ping.Latest()
+	if snapshot == nil {
+		http.Error(rw, "No ping results collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(snapshot)
+}
+
+// handleCAPsMAN returns the latest per-AP/SSID WiFi stats
+func (w *WebServer) handleCAPsMAN(rw http.ResponseWriter, r *http.Request) {
+	if w.capsman == nil {
+		http.Error(rw, "CAPsMAN collector not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	snapshot := w.capsman.Latest()
+	if snapshot == nil {
+		http.Error(rw, "No CAPsMAN data collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(snapshot)
+}
+
+// handleNetwatch returns the latest netwatch states
+func (w *WebServer) handleNetwatch(rw http.ResponseWriter, r *http.Request) {
+	if w.netwatch == nil {
+		http.Error(rw, "Netwatch collector not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	snapshot := w.netwatch.Latest()
+	if snapshot == nil {
+		http.Error(rw, "No netwatch data collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(snapshot)
+}
+
+// handleAudit returns the active management sessions and recent login events
+func (w *WebServer) handleAudit(rw http.ResponseWriter, r *http.Request) {
+	if w.audit == nil {
+		http.Error(rw, "Session audit not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	snapshot := w.audit.Latest()
+	if snapshot == nil {
+		http.Error(rw, "No audit data collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(snapshot)
+}
+
+// handleTopology returns the WAN roll-ups of this router and the other routers of its site
+func (w *WebServer) handleTopology(rw http.ResponseWriter, r *http.Request) {
+	if w.topology == nil {
+		http.Error(rw, "Topology not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	if _, timestamp := w.topology.Latest(); timestamp.IsZero() {
+		http.Error(rw, "No topology data collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(w.topology.Snapshot())
+}
+
+// handleTrunks returns the monitored VLANs summed per trunk with the trunk utilization
+func (w *WebServer) handleTrunks(rw http.ResponseWriter, r *http.Request) {
+	if w.trunks == nil {
+		http.Error(rw, "Trunk tracking not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	snapshot := w.trunks.Latest()
+	if snapshot == nil {
+		http.Error(rw, "No trunk data collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(snapshot)
+}
+
+// handleShaper returns the shaper policies with their usage and the recent queue changes
+func (w *WebServer) handleShaper(rw http.ResponseWriter, r *http.Request) {
+	if w.shaper == nil {
+		http.Error(rw, "Traffic shaper not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(w.shaper.Snapshot())
+}
+
+// handleBilling returns the billing report of a month (?month=2025-11, default: last month)
+// ?format=csv downloads it as CSV (rates in bits/s); JSON rates are in bytes/s.
+func (w *WebServer) handleBilling(rw http.ResponseWriter, r *http.Request) {
+	if w.billing == nil {
+		http.Error(rw, "Billing not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	now := time.Now().In(w.location)
+	month := now.AddDate(0, 0, -now.Day()) // Last day of last month
+	if value := r.URL.Query().Get("month"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01", value, w.location)
+		if err != nil {
+			http.Error(rw, "Invalid month (use YYYY-MM)", http.StatusBadRequest)
+			return
+		}
+		month = parsed
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(rw, "Invalid format (use json or csv)", http.StatusBadRequest)
+		return
+	}
+
+	report, err := w.billing.Report(month)
+	if err != nil {
+		logError("Web", "Billing report for %s failed: %v", month.Format("2006-01"), err)
+		http.Error(rw, "Billing report failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if format == "csv" {
+		rw.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "billing-"+report.Month+".csv"))
+		fmt.Fprint(rw, report.CSV())
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(report)
+}
+
+// handleSchedule returns the scheduled interfaces with their state, overrides and recent actions
+func (w *WebServer) handleSchedule(rw http.ResponseWriter, r *http.Request) {
+	if w.schedule == nil {
+		http.Error(rw, "Interface schedule not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(w.schedule.Snapshot())
+}
+
+// handleTopTalkers returns the busiest hosts per interface from the latest torch run
+// Rates are converted to Upload/Download like the realtime data; ?interface= limits the interfaces.
+func (w *WebServer) handleTopTalkers(rw http.ResponseWriter, r *http.Request) {
+	if w.topTalkers == nil {
+		http.Error(rw, "Top talkers collector not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	snapshot := w.topTalkers.Latest()
+	if snapshot == nil {
+		http.Error(rw, "No torch data collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	filter, _ := parseInterfaceParam(r.URL.Query()["interface"])
+	wanted := toSet(filter)
+	id := identityFrom(r)
+
+	interfaces := make(map[string]interface{})
+	for name, talkers := range snapshot.Interfaces {
+		if len(wanted) > 0 && !wanted[name] || !id.canSee(name) {
+			continue
+		}
+		isUplink := w.userConfig.IsUplink(name)
+		interfaces[name] = map[string]interface{}{
+			"label":        w.userConfig.GetInterfaceLabel(name),
+			"sources":      w.displayTalkers(talkers.Sources, isUplink),
+			"destinations": w.displayTalkers(talkers.Destinations, isUplink),
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(map[string]interface{}{
+		"timestamp":  snapshot.Timestamp.Format(time.RFC3339),
+		"duration":   int(snapshot.Duration.Seconds()),
+		"interfaces": interfaces,
+	})
+}
+
+// handleFlows returns the busiest conversations of the latest flow window and the exporter counters
+func (w *WebServer) handleFlows(rw http.ResponseWriter, r *http.Request) {
+	if w.flows == nil {
+		http.Error(rw, "Flow receiver not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	data := map[string]interface{}{
+		"exporters": w.flows.Exporters(),
+	}
+	if snapshot := w.flows.Latest(); snapshot != nil {
+		window := *snapshot
+		if w.hosts != nil {
+			window.Conversations = make([]FlowConversation, len(snapshot.Conversations))
+			for i, conversation := range snapshot.Conversations {
+				conversation.SourceName = w.hosts.Name(conversation.Source)
+				conversation.DestinationName = w.hosts.Name(conversation.Destination)
+				window.Conversations[i] = conversation
+			}
+		}
+		data["window"] = window
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(data)
+}
+
+// handleClients lists the active clients from DHCP leases and the ARP table
+func (w *WebServer) handleClients(rw http.ResponseWriter, r *http.Request) {
+	if w.hosts == nil {
+		http.Error(rw, "Client collector not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	clients, updated := w.hosts.List()
+	data := map[string]interface{}{
+		"clients": clients,
+		"count":   len(clients),
+	}
+	if !updated.IsZero() {
+		data["updated"] = updated.Format(time.RFC3339)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(data)
+}
+
+// displayTalkers converts talker RX/TX to Upload/Download based on interface type
+// and adds the client name of each address when known.
+func (w *WebServer) displayTalkers(talkers []Talker, isUplink bool) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(talkers))
+	for _, talker := range talkers {
+		uploadRate, downloadRate := talker.RxRate, talker.TxRate
+		if isUplink {
+			uploadRate, downloadRate = talker.TxRate, talker.RxRate
+		}
+		entry := map[string]interface{}{
+			"address":       talker.Address,
+			"upload_rate":   uploadRate,
+			"download_rate": downloadRate,
+		}
+		if name := w.hosts.Name(talker.Address); name != "" {
+			entry["name"] = name
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
 // ============================================================================
 // User Configuration API
 // ============================================================================
@@ -429,12 +1454,18 @@ func (ws *WebServer) handleInterfaceLabels(w http.ResponseWriter, r *http.Reques
 
 	switch r.Method {
 	case http.MethodGet:
-		// Return all interface labels
+		// Return all interface labels (web users: their interfaces only)
 		labels := ws.userConfig.GetAllInterfaceLabels()
+		id := identityFrom(r)
+		for name := range labels {
+			if !id.canSee(name) {
+				delete(labels, name)
+			}
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(labels); err != nil {
-			log.Printf("[Web] Error encoding interface labels: %v", err)
+			logError("Web", "Error encoding interface labels: %v", err)
 			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 			return
 		}
@@ -448,11 +1479,53 @@ func (ws *WebServer) handleInterfaceLabels(w http.ResponseWriter, r *http.Reques
 		}
 
 		if err := ws.userConfig.UpdateInterfaceLabels(labels); err != nil {
-			log.Printf("[Web] Error updating interface labels: %v", err)
+			logError("Web", "Error updating interface labels: %v", err)
+			http.Error(w, "Failed to save configuration", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleUplinkInterfaces handles GET and PUT requests for the uplink interface list
+// PUT body: {"uplink_interfaces": ["ether1"]} (null reverts to UPLINK_INTERFACES)
+// Changes apply immediately to terminal, log, WebSocket and history outputs
+func (ws *WebServer) handleUplinkInterfaces(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		id := identityFrom(r)
+		uplinks := []string{}
+		for _, name := range ws.userConfig.GetUplinkInterfaces() {
+			if id.canSee(name) {
+				uplinks = append(uplinks, name)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string][]string{
+			"uplink_interfaces": uplinks,
+		})
+
+	case http.MethodPut:
+		var body struct {
+			UplinkInterfaces []string `json:"uplink_interfaces"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		if err := ws.userConfig.SetUplinkInterfaces(body.UplinkInterfaces); err != nil {
+			logError("Web", "Error updating uplink interfaces: %v", err)
 			http.Error(w, "Failed to save configuration", http.StatusInternalServerError)
 			return
 		}
 
+		logInfo("Web", "Uplink interfaces updated: %v", ws.userConfig.GetUplinkInterfaces())
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 
@@ -460,3 +1533,19 @@ func (ws *WebServer) handleInterfaceLabels(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
+
+// handleDisplayConfig returns the dashboard display defaults (WEB_THEME, WEB_DEFAULT_RANGE, WEB_DEFAULT_UNIT)
+// Browsers apply their own choices from the settings page on top.
+func (ws *WebServer) handleDisplayConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"theme": ws.config.Theme,
+		"range": ws.config.DefaultRange,
+		"unit":  ws.config.DefaultUnit,
+	})
+}
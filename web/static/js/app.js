@@ -21,13 +21,14 @@ const MAX_DATA_POINTS = 60; // Show last 60 seconds
 const CHART_COLORS = {
     upload: 'rgb(239, 68, 68)',      // Red
     download: 'rgb(16, 185, 129)',   // Green
-    grid: 'rgba(71, 85, 105, 0.3)',
-    text: 'rgb(148, 163, 184)'
+    get grid() { return themeColors().grid; },  // Follow the theme (prefs.js)
+    get text() { return themeColors().text; }
 };
 
 function connect() {
-    const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = protocol + '//' + window.location.host + '/api/realtime';
+    // Relative to the page, so the dashboard also works under a base path (WEB_BASE_PATH)
+    const wsUrl = new URL('api/realtime', window.location.href);
+    wsUrl.protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
 
     ws = new WebSocket(wsUrl);
 
@@ -46,6 +47,12 @@ function connect() {
 
     ws.onmessage = (event) => {
         const data = JSON.parse(event.data);
+        if (data.type === 'interface_event') {
+            updateLinkState(data.event.interface, data.event.state);
+            return;
+        }
+        // Other control messages (subscribed/error) carry a type field
+        if (data.type) return;
         updateDisplay(data);
     };
 }
@@ -120,8 +127,13 @@ function updateStatus(connected) {
 // ============================================================================
 
 function formatBytes(bytes) {
-    const mbps = (bytes * 8 / 1000000).toFixed(2);
-    return mbps + ' Mbps';
+    return formatRate(bytes); // Mbps or MB/s (prefs.js)
+}
+
+function formatLinkSpeed(bits) {
+    if (bits >= 1e9) return (bits / 1e9) + 'G';
+    if (bits >= 1e6) return (bits / 1e6) + 'M';
+    return (bits / 1e3) + 'k';
 }
 
 function formatTime(date) {
@@ -133,6 +145,18 @@ function formatTime(date) {
     });
 }
 
+// Redraw charts with the new theme colors and unit when preferences change (prefs.js)
+document.addEventListener('displayprefs', () => {
+    for (const chart of [...Object.values(charts), modalChart]) {
+        if (!chart) continue;
+        for (const scale of Object.values(chart.options.scales)) {
+            scale.grid.color = CHART_COLORS.grid;
+            scale.ticks.color = CHART_COLORS.text;
+        }
+        chart.update('none');
+    }
+});
+
 function createChart(canvasId, interfaceName) {
     const ctx = document.getElementById(canvasId).getContext('2d');
 
@@ -227,7 +251,7 @@ function createChart(canvasId, interfaceName) {
                     ticks: {
                         color: CHART_COLORS.text,
                         callback: function(value) {
-                            return (value * 8 / 1000000).toFixed(0) + ' Mbps';
+                            return formatAxisRate(value);
                         },
                         font: {
                             size: 10
@@ -282,7 +306,11 @@ function updateDisplay(data) {
     // Update interfaces
     const container = document.getElementById('interfaces');
 
-    for (const [name, stats] of Object.entries(data.interfaces)) {
+    // Bridges and bonds first, so their member ports can be placed after them
+    const entries = Object.entries(data.interfaces)
+        .sort(([, a], [, b]) => (a.parent ? 1 : 0) - (b.parent ? 1 : 0));
+
+    for (const [name, stats] of entries) {
         // Track available interfaces
         availableInterfaces.add(name);
 
@@ -291,7 +319,7 @@ function updateDisplay(data) {
         // Create card if it doesn't exist
         if (!card) {
             card = createInterfaceCard(name);
-            container.appendChild(card);
+            insertInterfaceCard(container, card, stats.parent);
 
             // Create chart
             const canvasId = 'chart-' + name;
@@ -314,6 +342,32 @@ function updateDisplay(data) {
         card.querySelector('.peak-upload').textContent = formatBytes(calculatedStats.peakUpload);
         card.querySelector('.peak-download').textContent = formatBytes(calculatedStats.peakDownload);
 
+        // Link speed and utilization (only sent when the speed is known)
+        const hasSpeed = stats.link_speed > 0;
+        const speedBadge = card.querySelector('.link-speed');
+        speedBadge.hidden = !hasSpeed;
+        card.querySelector('.utilization-row').hidden = !hasSpeed;
+        if (hasSpeed) {
+            const busiest = Math.max(stats.upload_utilization, stats.download_utilization);
+            speedBadge.textContent = `${formatLinkSpeed(stats.link_speed)} · ${busiest.toFixed(0)}%`;
+            speedBadge.classList.toggle('saturated', busiest >= 90);
+            card.querySelector('.util-upload').textContent = stats.upload_utilization.toFixed(1) + '%';
+            card.querySelector('.util-download').textContent = stats.download_utilization.toFixed(1) + '%';
+        }
+
+        // Sum of the member ports (bridges and bonds with INTERFACE_MEMBERS_ENABLED)
+        const hasMembers = stats.members_upload_rate !== undefined;
+        card.querySelector('.members-row').hidden = !hasMembers;
+        if (hasMembers) {
+            card.querySelector('.members-upload').textContent = formatBytes(stats.members_upload_rate);
+            card.querySelector('.members-download').textContent = formatBytes(stats.members_download_rate);
+        }
+
+        // Link state (only sent when the router reports it)
+        if (stats.state) {
+            updateLinkState(name, stats.state);
+        }
+
         // Store combined stats for modal
         interfaceStats[name] = {
             upload_rate: stats.upload_rate,
@@ -337,6 +391,38 @@ function updateDisplay(data) {
     updateInterfaceSelector();
 }
 
+// Show a badge and dim the card while an interface is down or disabled
+function updateLinkState(name, state) {
+    const card = document.getElementById('card-' + name);
+    if (!card) return;
+
+    const badge = card.querySelector('.link-state');
+    badge.hidden = state === 'up';
+    badge.textContent = state;
+    card.classList.toggle('link-down', state !== 'up');
+}
+
+// Member ports go after their bridge/bond and its other members; other cards at the end
+function insertInterfaceCard(container, card, parent) {
+    const parentCard = parent && document.getElementById('card-' + parent);
+    if (!parentCard) {
+        container.appendChild(card);
+        return;
+    }
+
+    card.classList.add('member-card');
+    card.dataset.parent = parent;
+    const badge = card.querySelector('.member-of');
+    badge.textContent = '⤷ ' + getInterfaceDisplayName(parent);
+    badge.hidden = false;
+
+    let last = parentCard;
+    while (last.nextElementSibling && last.nextElementSibling.dataset.parent === parent) {
+        last = last.nextElementSibling;
+    }
+    last.after(card);
+}
+
 function createInterfaceCard(name) {
     const card = document.createElement('div');
     card.className = 'interface-card';
@@ -350,6 +436,9 @@ function createInterfaceCard(name) {
             <div class="interface-name-wrapper">
                 <span class="interface-name" data-interface="${name}">${displayName}</span>
                 ${hasCustomLabel ? `<span class="original-name">(${name})</span>` : ''}
+                <span class="link-speed" hidden></span>
+                <span class="link-state" hidden></span>
+                <span class="member-of" hidden></span>
                 <button class="edit-btn" data-interface="${name}" title="Edit label">✏️</button>
             </div>
             <div class="interface-actions">
@@ -386,6 +475,20 @@ function createInterfaceCard(name) {
                         <span class="stat-download peak-download">0</span>
                     </div>
                 </div>
+                <div class="stat-row utilization-row" hidden>
+                    <span class="stat-label">利用率</span>
+                    <div class="stat-values">
+                        <span class="stat-upload util-upload">0%</span>
+                        <span class="stat-download util-download">0%</span>
+                    </div>
+                </div>
+                <div class="stat-row members-row" hidden>
+                    <span class="stat-label">成员合计</span>
+                    <div class="stat-values">
+                        <span class="stat-upload members-upload">0</span>
+                        <span class="stat-download members-download">0</span>
+                    </div>
+                </div>
             </div>
         </div>
     `;
@@ -443,7 +546,7 @@ function openHistoryPanel(interfaceName) {
     const left = (screen.width - width) / 2;
     const top = (screen.height - height) / 2;
 
-    const url = `/history.html?interface=${encodeURIComponent(interfaceName)}`;
+    const url = `history.html?interface=${encodeURIComponent(interfaceName)}`;
     const features = `width=${width},height=${height},left=${left},top=${top},resizable=yes,scrollbars=yes`;
 
     window.open(url, `history_${interfaceName}`, features);
@@ -456,7 +559,7 @@ function openHistoryPanel(interfaceName) {
 // Load interface labels from server
 async function loadInterfaceLabels() {
     try {
-        const response = await fetch('/api/config/labels');
+        const response = await fetch('api/config/labels');
         if (response.ok) {
             interfaceLabels = await response.json();
         }
@@ -532,7 +635,7 @@ function makeInterfaceNameEditable(interfaceName, nameElement, editBtn) {
 
             // Save to server
             try {
-                const response = await fetch('/api/config/labels', {
+                const response = await fetch('api/config/labels', {
                     method: 'PUT',
                     headers: { 'Content-Type': 'application/json' },
                     body: JSON.stringify({ [interfaceName]: newLabel })
@@ -677,7 +780,7 @@ function openChartModal(interfaceName) {
                         ticks: {
                             color: CHART_COLORS.text,
                             callback: function(value) {
-                                return (value * 8 / 1000000).toFixed(0) + ' Mbps';
+                                return formatAxisRate(value);
                             },
                             font: {
                                 size: 12
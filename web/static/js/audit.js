@@ -0,0 +1,81 @@
+// Session Audit Page JavaScript
+
+const REFRESH_INTERVAL = 10000;
+
+window.addEventListener('DOMContentLoaded', () => {
+    loadAudit();
+    setInterval(loadAudit, REFRESH_INTERVAL);
+});
+
+async function loadAudit() {
+    try {
+        const response = await fetch('api/audit');
+        if (!response.ok) throw new Error('Failed to fetch audit data');
+
+        render(await response.json());
+    } catch (error) {
+        console.error('Error loading audit data:', error);
+        document.getElementById('auditInfo').textContent = 'Session audit unavailable';
+    }
+}
+
+function render(data) {
+    const time = new Date(data.timestamp).toLocaleString();
+    const minutes = Math.round(data.window / 60);
+    document.getElementById('auditInfo').textContent =
+        `Updated ${time} · ${data.failures} login failures from ${data.source_count} addresses in the last ${minutes} min`;
+    document.getElementById('auditSourcesTitle').textContent = `Failing Addresses (last ${minutes} min)`;
+
+    fillTable('auditSessions', ['User', 'Address', 'Via', 'Group', 'Since'], data.sessions,
+        'No active sessions', session => [
+            session.user, session.address || '-', session.via, session.group || '-', formatTime(session.since)
+        ]);
+
+    fillTable('auditSources', ['Address', 'Failures', 'Last'], data.sources || [],
+        'No login failures', source => [source.address, source.count, formatTime(source.last)], [1]);
+
+    fillTable('auditEvents', ['Time', 'Event', 'User', 'Address', 'Via'], data.events,
+        'No login messages in the router log', event => [
+            formatTime(event.time), event.kind, event.user, event.address || '-', event.via || '-'
+        ], [], event => event.kind === 'failure' ? 'failure' : '');
+}
+
+function fillTable(id, headers, items, emptyText, cells, countColumns = [], rowClass = () => '') {
+    const container = document.getElementById(id);
+    container.innerHTML = '';
+
+    if (items.length === 0) {
+        const empty = document.createElement('p');
+        empty.className = 'audit-empty';
+        empty.textContent = emptyText;
+        container.appendChild(empty);
+        return;
+    }
+
+    const table = document.createElement('table');
+    table.className = 'audit-table';
+    const head = table.createTHead().insertRow();
+    headers.forEach(header => {
+        const th = document.createElement('th');
+        th.textContent = header;
+        head.appendChild(th);
+    });
+
+    const tbody = table.createTBody();
+    items.forEach(item => {
+        const row = tbody.insertRow();
+        row.className = rowClass(item);
+        cells(item).forEach((value, i) => {
+            const td = row.insertCell();
+            if (countColumns.includes(i)) td.className = 'count';
+            td.textContent = value;
+        });
+    });
+    container.appendChild(table);
+}
+
+// Router times are zero ("0001-01-01...") when unknown
+function formatTime(value) {
+    const date = new Date(value);
+    return date.getFullYear() > 1 ? date.toLocaleString() : '-';
+}
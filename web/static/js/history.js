@@ -10,8 +10,8 @@ let currentState = null;   // 当前状态
 const CHART_COLORS = {
     upload: 'rgb(239, 68, 68)',      // Red
     download: 'rgb(16, 185, 129)',   // Green
-    grid: 'rgba(71, 85, 105, 0.3)',
-    text: 'rgb(148, 163, 184)'
+    get grid() { return themeColors().grid; },  // Follow the theme (prefs.js)
+    get text() { return themeColors().text; }
 };
 
 // ============================================================================
@@ -163,7 +163,7 @@ function alignTimestamp(date, interval, direction = 'floor') {
 // Initialization
 // ============================================================================
 
-document.addEventListener('DOMContentLoaded', () => {
+document.addEventListener('DOMContentLoaded', async () => {
     // Get interface from URL parameter
     const urlParams = new URLSearchParams(window.location.search);
     const interfaceName = urlParams.get('interface');
@@ -183,8 +183,12 @@ document.addEventListener('DOMContentLoaded', () => {
     // Setup time range buttons
     setupTimeRangeButtons();
 
-    // Initialize with default time range (24h)
-    const defaultRange = '24h';
+    // Initialize with the default time range (WEB_DEFAULT_RANGE or this browser's choice)
+    await displayPrefsReady;
+    let defaultRange = displayPrefs.range;
+    if (defaultRange === 'custom' || !document.querySelector(`.time-btn[data-range="${defaultRange}"]`)) {
+        defaultRange = '24h';
+    }
     document.querySelector(`.time-btn[data-range="${defaultRange}"]`).classList.add('active');
     onTimeRangeChange(defaultRange);
 
@@ -207,7 +211,7 @@ document.addEventListener('DOMContentLoaded', () => {
 
 async function loadAvailableInterfaces() {
     try {
-        const response = await fetch('/api/current');
+        const response = await fetch('api/current');
         const data = await response.json();
 
         const select = document.getElementById('historyInterface');
@@ -410,7 +414,7 @@ async function loadHistoricalData() {
             interval: interval
         });
 
-        const response = await fetch(`/api/history?${params}`);
+        const response = await fetch(`api/history?${params}`);
         if (!response.ok) {
             throw new Error(`HTTP ${response.status}: ${await response.text()}`);
         }
@@ -434,9 +438,18 @@ async function loadHistoricalData() {
 // Chart Display
 // ============================================================================
 
+// Redraw with the new theme colors and unit when preferences change (prefs.js)
+document.addEventListener('displayprefs', () => {
+    if (!historyChart) return;
+    for (const scale of Object.values(historyChart.options.scales)) {
+        scale.grid.color = CHART_COLORS.grid;
+        scale.ticks.color = CHART_COLORS.text;
+    }
+    historyChart.update('none');
+});
+
 function formatBytes(bytes) {
-    const mbps = (bytes * 8 / 1000000).toFixed(2);
-    return mbps + ' Mbps';
+    return formatRate(bytes); // Mbps or MB/s (prefs.js)
 }
 
 function displayHistoricalChart(data) {
@@ -471,10 +484,11 @@ function displayHistoricalChart(data) {
 
     // Prepare data
     const labels = data.datapoints.map(dp => new Date(dp.timestamp));
-    const uploadAvg = data.datapoints.map(dp => dp.upload_avg);
-    const downloadAvg = data.datapoints.map(dp => dp.download_avg);
-    const uploadPeak = data.datapoints.map(dp => dp.upload_peak);
-    const downloadPeak = data.datapoints.map(dp => dp.download_peak);
+    // Gaps (no data collected) are null so the line breaks instead of dropping to zero
+    const uploadAvg = data.datapoints.map(dp => dp.gap ? null : dp.upload_avg);
+    const downloadAvg = data.datapoints.map(dp => dp.gap ? null : dp.download_avg);
+    const uploadPeak = data.datapoints.map(dp => dp.gap ? null : dp.upload_peak);
+    const downloadPeak = data.datapoints.map(dp => dp.gap ? null : dp.download_peak);
 
     // Create chart
     historyChart = new Chart(ctx, {
@@ -594,7 +608,7 @@ function displayHistoricalChart(data) {
                     ticks: {
                         color: CHART_COLORS.text,
                         callback: function(value) {
-                            return (value * 8 / 1000000).toFixed(0) + ' Mbps';
+                            return formatAxisRate(value);
                         },
                         font: {
                             size: 10
@@ -0,0 +1,96 @@
+// ============================================================================
+// Display Preferences (theme, default history range, rate unit)
+// ============================================================================
+//
+// Defaults come from the server (/api/config/display: WEB_THEME, WEB_DEFAULT_RANGE,
+// WEB_DEFAULT_UNIT); choices made on the settings page are stored in this browser
+// only and take precedence. Loaded before the page script on every page.
+
+const PREFS_STORAGE_KEY = 'displayPrefs';
+const BUILTIN_PREFS = { theme: 'dark', range: '24h', unit: 'bits' };
+
+let serverPrefs = { ...BUILTIN_PREFS };
+let displayPrefs = { ...BUILTIN_PREFS, ...loadLocalPrefs() };
+
+function loadLocalPrefs() {
+    try {
+        return JSON.parse(localStorage.getItem(PREFS_STORAGE_KEY)) || {};
+    } catch (e) {
+        return {};
+    }
+}
+
+// Resolves once the server defaults are known (or failed to load)
+const displayPrefsReady = fetch('api/config/display')
+    .then(response => response.ok ? response.json() : {})
+    .catch(() => ({}))
+    .then(defaults => {
+        serverPrefs = { ...BUILTIN_PREFS, ...defaults };
+        setDisplayPrefs({ ...serverPrefs, ...loadLocalPrefs() });
+        return displayPrefs;
+    });
+
+// Stores the preferences that differ from the server defaults in this browser
+function saveDisplayPrefs(prefs) {
+    const local = {};
+    for (const key of Object.keys(BUILTIN_PREFS)) {
+        if (prefs[key] !== undefined && prefs[key] !== serverPrefs[key]) {
+            local[key] = prefs[key];
+        }
+    }
+    localStorage.setItem(PREFS_STORAGE_KEY, JSON.stringify(local));
+    setDisplayPrefs({ ...serverPrefs, ...local });
+}
+
+// Forgets the browser's choices (back to the server defaults)
+function resetDisplayPrefs() {
+    localStorage.removeItem(PREFS_STORAGE_KEY);
+    setDisplayPrefs({ ...serverPrefs });
+}
+
+function setDisplayPrefs(prefs) {
+    displayPrefs = prefs;
+    applyTheme();
+    document.dispatchEvent(new CustomEvent('displayprefs', { detail: displayPrefs }));
+}
+
+// "auto" follows the operating system setting
+function activeTheme() {
+    if (displayPrefs.theme === 'auto') {
+        return window.matchMedia('(prefers-color-scheme: light)').matches ? 'light' : 'dark';
+    }
+    return displayPrefs.theme === 'light' ? 'light' : 'dark';
+}
+
+function applyTheme() {
+    document.documentElement.dataset.theme = activeTheme();
+}
+
+window.matchMedia('(prefers-color-scheme: light)').addEventListener('change', () => {
+    if (displayPrefs.theme === 'auto') {
+        setDisplayPrefs(displayPrefs);
+    }
+});
+
+// Chart grid and tick colors of the active theme
+function themeColors() {
+    return activeTheme() === 'light'
+        ? { grid: 'rgba(100, 116, 139, 0.2)', text: 'rgb(71, 85, 105)' }
+        : { grid: 'rgba(71, 85, 105, 0.3)', text: 'rgb(148, 163, 184)' };
+}
+
+// Formats a rate in bytes/s as Mbps or MB/s
+function formatRate(bytesPerSecond, digits = 2) {
+    if (displayPrefs.unit === 'bytes') {
+        return (bytesPerSecond / 1000000).toFixed(digits) + ' MB/s';
+    }
+    return (bytesPerSecond * 8 / 1000000).toFixed(digits) + ' Mbps';
+}
+
+// Chart axis labels (no decimals)
+function formatAxisRate(bytesPerSecond) {
+    return formatRate(bytesPerSecond, 0);
+}
+
+// Apply the stored theme right away so pages do not flash dark before the defaults load
+applyTheme();
@@ -0,0 +1,68 @@
+// Sessions Page JavaScript
+
+const REFRESH_INTERVAL = 5000;
+let sortKey = 'download_rate';
+let sortDesc = true;
+let lastSnapshot = null;
+
+window.addEventListener('DOMContentLoaded', () => {
+    document.querySelectorAll('.session-table th').forEach(th => {
+        th.addEventListener('click', () => {
+            const key = th.dataset.sort;
+            sortDesc = sortKey === key ? !sortDesc : true;
+            sortKey = key;
+            render();
+        });
+    });
+
+    loadSessions();
+    setInterval(loadSessions, REFRESH_INTERVAL);
+});
+
+async function loadSessions() {
+    try {
+        const response = await fetch('api/sessions');
+        if (!response.ok) throw new Error('Failed to fetch sessions');
+
+        lastSnapshot = await response.json();
+        render();
+    } catch (error) {
+        console.error('Error loading sessions:', error);
+        document.getElementById('sessionCounts').textContent = 'Session collector unavailable';
+    }
+}
+
+function render() {
+    if (!lastSnapshot) return;
+
+    const counts = Object.entries(lastSnapshot.counts || {})
+        .map(([type, count]) => `${type.toUpperCase()}: ${count}`)
+        .join(' | ');
+    document.getElementById('sessionCounts').textContent = counts || 'No active sessions';
+
+    const sessions = [...(lastSnapshot.sessions || [])];
+    sessions.sort((a, b) => {
+        const av = a[sortKey];
+        const bv = b[sortKey];
+        const cmp = typeof av === 'number' ? av - bv : String(av).localeCompare(String(bv));
+        return sortDesc ? -cmp : cmp;
+    });
+
+    const tbody = document.getElementById('sessionRows');
+    tbody.innerHTML = '';
+    sessions.forEach(session => {
+        const row = document.createElement('tr');
+        [session.type, session.user, session.address, session.caller_id, session.uptime].forEach(value => {
+            const td = document.createElement('td');
+            td.textContent = value || '';
+            row.appendChild(td);
+        });
+        [session.upload_rate, session.download_rate].forEach(value => {
+            const td = document.createElement('td');
+            td.className = 'rate';
+            td.textContent = formatRate(value);
+            row.appendChild(td);
+        });
+        tbody.appendChild(row);
+    });
+}
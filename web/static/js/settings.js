@@ -2,18 +2,22 @@
 
 let interfaceLabels = {};
 let monitoredInterfaces = [];
+let uplinkInterfaces = [];
 
 // Load current settings on page load
 window.addEventListener('DOMContentLoaded', async () => {
+    displayPrefsReady.then(renderDisplayPrefs);
+
     // Load interface list first, then labels
     await loadCurrentData();
+    await loadUplinks();
     await loadLabels();
 });
 
 // Load current monitoring data to get interface list
 async function loadCurrentData() {
     try {
-        const response = await fetch('/api/current');
+        const response = await fetch('api/current');
         if (!response.ok) throw new Error('Failed to fetch current data');
 
         const data = await response.json();
@@ -25,10 +29,24 @@ async function loadCurrentData() {
     }
 }
 
+// Load uplink interface list from server
+async function loadUplinks() {
+    try {
+        const response = await fetch('api/config/uplinks');
+        if (!response.ok) throw new Error('Failed to fetch uplinks');
+
+        const data = await response.json();
+        uplinkInterfaces = data.uplink_interfaces || [];
+    } catch (error) {
+        console.error('Error loading uplinks:', error);
+        showStatus('Error loading uplink settings', true);
+    }
+}
+
 // Load existing labels from server
 async function loadLabels() {
     try {
-        const response = await fetch('/api/config/labels');
+        const response = await fetch('api/config/labels');
         if (!response.ok) throw new Error('Failed to fetch labels');
 
         interfaceLabels = await response.json();
@@ -64,6 +82,15 @@ function renderLabelEditor() {
                 placeholder="${ifaceName}"
                 onchange="markUnsaved()"
             />
+            <label class="uplink-toggle" title="Uplink: TX = Upload, RX = Download">
+                <input
+                    type="checkbox"
+                    id="uplink_${ifaceName}"
+                    ${uplinkInterfaces.includes(ifaceName) ? 'checked' : ''}
+                    onchange="markUnsaved()"
+                />
+                Uplink
+            </label>
             <button class="reset-btn" onclick="resetLabel('${ifaceName}')">Reset</button>
         `;
 
@@ -92,17 +119,29 @@ async function saveLabels() {
     saveBtn.disabled = true;
     showStatus('Saving...');
 
-    // Collect all labels from inputs
+    // Collect all labels and uplink flags from inputs
     const updatedLabels = {};
+    const updatedUplinks = [];
     monitoredInterfaces.forEach(ifaceName => {
         const input = document.getElementById(`label_${ifaceName}`);
         if (input) {
             updatedLabels[ifaceName] = input.value.trim();
         }
+        const checkbox = document.getElementById(`uplink_${ifaceName}`);
+        if (checkbox && checkbox.checked) {
+            updatedUplinks.push(ifaceName);
+        }
+    });
+
+    // Keep uplinks that are not currently monitored
+    uplinkInterfaces.forEach(ifaceName => {
+        if (!monitoredInterfaces.includes(ifaceName)) {
+            updatedUplinks.push(ifaceName);
+        }
     });
 
     try {
-        const response = await fetch('/api/config/labels', {
+        const response = await fetch('api/config/labels', {
             method: 'PUT',
             headers: {
                 'Content-Type': 'application/json',
@@ -112,7 +151,18 @@ async function saveLabels() {
 
         if (!response.ok) throw new Error('Failed to save labels');
 
+        const uplinkResponse = await fetch('api/config/uplinks', {
+            method: 'PUT',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({ uplink_interfaces: updatedUplinks })
+        });
+
+        if (!uplinkResponse.ok) throw new Error('Failed to save uplinks');
+
         interfaceLabels = updatedLabels;
+        uplinkInterfaces = updatedUplinks;
         saveBtn.textContent = 'Save Changes';
         showStatus('Settings saved successfully!');
 
@@ -149,3 +199,26 @@ function escapeHtml(text) {
     div.textContent = text;
     return div.innerHTML;
 }
+
+// ============================================================================
+// Display Preferences (this browser only, see prefs.js)
+// ============================================================================
+
+function renderDisplayPrefs() {
+    document.getElementById('prefTheme').value = displayPrefs.theme;
+    document.getElementById('prefRange').value = displayPrefs.range;
+    document.getElementById('prefUnit').value = displayPrefs.unit;
+}
+
+function saveDisplay() {
+    saveDisplayPrefs({
+        theme: document.getElementById('prefTheme').value,
+        range: document.getElementById('prefRange').value,
+        unit: document.getElementById('prefUnit').value
+    });
+}
+
+function resetDisplay() {
+    resetDisplayPrefs();
+    renderDisplayPrefs();
+}
@@ -0,0 +1,77 @@
+// Top Talkers Page JavaScript
+
+const REFRESH_INTERVAL = 10000;
+
+window.addEventListener('DOMContentLoaded', () => {
+    loadTopTalkers();
+    setInterval(loadTopTalkers, REFRESH_INTERVAL);
+});
+
+async function loadTopTalkers() {
+    try {
+        const response = await fetch('api/toptalkers');
+        if (!response.ok) throw new Error('Failed to fetch top talkers');
+
+        render(await response.json());
+    } catch (error) {
+        console.error('Error loading top talkers:', error);
+        document.getElementById('talkersInfo').textContent = 'Top talkers collector unavailable';
+    }
+}
+
+function render(data) {
+    const time = new Date(data.timestamp).toLocaleString();
+    document.getElementById('talkersInfo').textContent =
+        `Torch sample of ${data.duration}s at ${time} (average rate per host)`;
+
+    const container = document.getElementById('talkersInterfaces');
+    container.innerHTML = '';
+
+    Object.keys(data.interfaces).sort().forEach(name => {
+        const iface = data.interfaces[name];
+
+        const section = document.createElement('div');
+        section.className = 'talkers-interface';
+
+        const title = document.createElement('h2');
+        title.textContent = iface.label && iface.label !== name ? `${iface.label} (${name})` : name;
+        section.appendChild(title);
+
+        const lists = document.createElement('div');
+        lists.className = 'talkers-lists';
+        lists.appendChild(createTable('Sources', iface.sources));
+        lists.appendChild(createTable('Destinations', iface.destinations));
+        section.appendChild(lists);
+
+        container.appendChild(section);
+    });
+}
+
+function createTable(caption, talkers) {
+    const table = document.createElement('table');
+    table.className = 'talkers-table';
+    table.innerHTML = `
+        <caption>${caption}</caption>
+        <thead>
+            <tr><th>Address</th><th>Upload</th><th>Download</th></tr>
+        </thead>
+        <tbody></tbody>
+    `;
+
+    const tbody = table.querySelector('tbody');
+    talkers.forEach(talker => {
+        const row = document.createElement('tr');
+        const address = document.createElement('td');
+        // Client name from DHCP/ARP when CLIENTS_ENABLED
+        address.textContent = talker.name ? `${talker.address} (${talker.name})` : talker.address;
+        row.appendChild(address);
+        [talker.upload_rate, talker.download_rate].forEach(value => {
+            const td = document.createElement('td');
+            td.className = 'rate';
+            td.textContent = formatRate(value);
+            row.appendChild(td);
+        });
+        tbody.appendChild(row);
+    });
+    return table;
+}
@@ -0,0 +1,100 @@
+// VLAN Trunks Page JavaScript
+
+const REFRESH_INTERVAL = 5000;
+
+window.addEventListener('DOMContentLoaded', () => {
+    loadTrunks();
+    setInterval(loadTrunks, REFRESH_INTERVAL);
+});
+
+async function loadTrunks() {
+    try {
+        const response = await fetch('api/trunks');
+        if (!response.ok) throw new Error('Failed to fetch trunks');
+
+        render(await response.json());
+    } catch (error) {
+        console.error('Error loading trunks:', error);
+        document.getElementById('trunksInfo').textContent = 'Trunk tracking unavailable';
+    }
+}
+
+function render(data) {
+    const time = new Date(data.timestamp).toLocaleString();
+    document.getElementById('trunksInfo').textContent =
+        `Monitored VLANs summed per parent interface at ${time}`;
+
+    const container = document.getElementById('trunks');
+    container.innerHTML = '';
+
+    (data.trunks || []).forEach(trunk => {
+        const section = document.createElement('div');
+        section.className = 'trunk';
+
+        const title = document.createElement('h2');
+        title.textContent = trunk.trunk;
+        section.appendChild(title);
+
+        const summary = document.createElement('div');
+        summary.className = 'trunk-summary';
+        const speed = trunk.link_speed > 0 ? formatLinkSpeed(trunk.link_speed) : 'unknown';
+        [
+            `Link: ${speed}`,
+            `RX: ${formatRate(trunk.rx_rate)}${formatUtilization(trunk, trunk.rx_utilization)}`,
+            `TX: ${formatRate(trunk.tx_rate)}${formatUtilization(trunk, trunk.tx_utilization)}`
+        ].forEach(text => {
+            const span = document.createElement('span');
+            span.textContent = text;
+            summary.appendChild(span);
+        });
+        section.appendChild(summary);
+
+        const table = document.createElement('table');
+        table.className = 'trunk-table';
+        table.innerHTML = '<thead><tr><th>VLAN ID</th><th>Interface</th><th>RX</th><th>TX</th></tr></thead>';
+
+        const tbody = document.createElement('tbody');
+        trunk.vlans.forEach(vlan => {
+            tbody.appendChild(createRow([vlan.vlan_id, vlan.interface], [vlan.rx_rate, vlan.tx_rate]));
+        });
+        table.appendChild(tbody);
+
+        const tfoot = document.createElement('tfoot');
+        tfoot.appendChild(createRow(['Total', `${trunk.vlans.length} VLANs`], [trunk.rx_rate, trunk.tx_rate]));
+        table.appendChild(tfoot);
+
+        section.appendChild(table);
+        container.appendChild(section);
+    });
+
+    if (!container.children.length) {
+        container.textContent = 'No monitored VLANs';
+    }
+}
+
+function createRow(cells, rates) {
+    const row = document.createElement('tr');
+    cells.forEach(value => {
+        const td = document.createElement('td');
+        td.textContent = value;
+        row.appendChild(td);
+    });
+    rates.forEach(value => {
+        const td = document.createElement('td');
+        td.className = 'rate';
+        td.textContent = formatRate(value);
+        row.appendChild(td);
+    });
+    return row;
+}
+
+// Utilization suffix, only when the link speed is known
+function formatUtilization(trunk, ratio) {
+    return trunk.link_speed > 0 ? ` (${(ratio * 100).toFixed(1)}%)` : '';
+}
+
+function formatLinkSpeed(bits) {
+    if (bits >= 1e9) return (bits / 1e9) + 'G';
+    if (bits >= 1e6) return (bits / 1e6) + 'M';
+    return (bits / 1e3) + 'k';
+}
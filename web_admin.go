@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ============================================================================
+// Admin API (runtime configuration)
+// ============================================================================
+
+// adminConfigView is the effective runtime configuration returned by /api/admin/config
+type adminConfigView struct {
+	PollInterval    string          `json:"poll_interval"`
+	Interfaces      []string        `json:"interfaces"`
+	StatsWindowSize int             `json:"stats_window_size"`
+	AlertsEnabled   bool            `json:"alerts_enabled"`
+	AlertThresholds string          `json:"alert_thresholds"`
+	Outputs         map[string]bool `json:"outputs"` // Configured outputs -> enabled
+	Overrides       ConfigOverrides `json:"overrides"`
+}
+
+// newAdminConfigView describes a configuration and the overrides it was built with
+func newAdminConfigView(config *Config, overrides ConfigOverrides) adminConfigView {
+	view := adminConfigView{
+		PollInterval:    config.PollInterval.String(),
+		Interfaces:      config.Interfaces,
+		StatsWindowSize: config.StatsWindowSize,
+		AlertsEnabled:   config.Alerts != nil,
+		Outputs:         make(map[string]bool),
+		Overrides:       overrides,
+	}
+	if config.Alerts != nil {
+		view.AlertThresholds = config.Alerts.Thresholds
+	}
+	disabled := toSet(config.DisabledOutputs)
+	for _, name := range outputNames {
+		if outputConfigured(config, name) {
+			view.Outputs[name] = !disabled[name]
+		}
+	}
+	return view
+}
+
+// handleAdminConfig exposes and changes the runtime configuration
+// GET returns the effective configuration, PUT merges the fields present in the
+// body into the stored overrides (an empty or null value reverts that field) and
+// DELETE reverts everything to the env file settings.
+func (w *WebServer) handleAdminConfig(rw http.ResponseWriter, r *http.Request) {
+	var config *Config
+	overrides := w.userConfig.ConfigOverrides()
+
+	switch r.Method {
+	case http.MethodGet:
+		config = w.admin.CurrentConfig()
+
+	case http.MethodPut, http.MethodDelete:
+		if r.Method == http.MethodPut {
+			decoder := json.NewDecoder(r.Body)
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&overrides); err != nil {
+				http.Error(rw, "Invalid JSON body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else {
+			overrides = ConfigOverrides{}
+		}
+
+		var err error
+		config, err = w.admin.SetConfigOverrides(overrides)
+		if errors.Is(err, errOverridesNotSaved) {
+			logError("Web", "Error updating configuration overrides: %v", err)
+			http.Error(rw, "Failed to save configuration", http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			http.Error(rw, "Invalid configuration: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		logInfo("Web", "Configuration overrides updated by %s", r.RemoteAddr)
+
+	default:
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(newAdminConfigView(config, overrides))
+}
+
+// ============================================================================
+// Admin API (interface schedule overrides)
+// ============================================================================
+
+// handleAdminSchedule overrides the interface schedule
+// GET returns the schedule like /api/schedule, PUT pins an interface to a state
+// ({"interface", "state": "enabled" or "disabled", "until" (RFC 3339) or
+// "duration"; neither = until the next scheduled change}) and DELETE
+// ?interface= returns it to its windows. Changes are enforced right away.
+func (w *WebServer) handleAdminSchedule(rw http.ResponseWriter, r *http.Request) {
+	if w.schedule == nil {
+		http.Error(rw, "Interface schedule not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	by := "admin"
+	if id := identityFrom(r); id != nil && id.user != "" {
+		by = id.user
+	}
+	by += " from " + r.RemoteAddr
+
+	switch r.Method {
+	case http.MethodGet:
+
+	case http.MethodPut:
+		var body struct {
+			Interface string    `json:"interface"`
+			State     string    `json:"state"`
+			Until     time.Time `json:"until"`
+			Duration  string    `json:"duration"`
+		}
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&body); err != nil {
+			http.Error(rw, "Invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.State != "enabled" && body.State != "disabled" {
+			http.Error(rw, "'state' must be 'enabled' or 'disabled'", http.StatusBadRequest)
+			return
+		}
+		until := body.Until
+		if body.Duration != "" {
+			duration, err := time.ParseDuration(body.Duration)
+			if err != nil || duration <= 0 || !until.IsZero() {
+				http.Error(rw, "Invalid 'duration' (e.g. \"2h\", not together with 'until')", http.StatusBadRequest)
+				return
+			}
+			until = time.Now().Add(duration)
+		}
+
+		_, err := w.schedule.SetOverride(body.Interface, body.State == "disabled", until, by)
+		if errors.Is(err, errScheduleUnknownInterface) {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.schedule.Enforce(r.Context()) // Failures are in the actions
+
+	case http.MethodDelete:
+		name := r.URL.Query().Get("interface")
+		if !w.schedule.ClearOverride(name, by) {
+			http.Error(rw, "No override for interface "+name, http.StatusNotFound)
+			return
+		}
+		w.schedule.Enforce(r.Context())
+
+	default:
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(w.schedule.Snapshot())
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ============================================================================
+// Response Compression and Static Asset Caching
+// ============================================================================
+
+// compressibleTypes are content types worth compressing (images and fonts are already compressed)
+var compressibleTypes = []string{
+	"application/json",
+	"application/javascript",
+	"text/",
+	"image/svg+xml",
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(io.Discard) },
+}
+
+// compressResponses gzip/deflate-encodes text and JSON responses for clients that accept it
+// /api/history can be megabytes of JSON for long ranges; it shrinks by ~90%.
+// WebSocket upgrades and range requests are passed through untouched.
+func compressResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" || r.Header.Get("Range") != "" {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: rw, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding picks gzip or deflate from an Accept-Encoding header ("" if neither)
+func acceptedEncoding(header string) string {
+	deflate := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				continue // Explicitly refused
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gzip":
+			return "gzip"
+		case "deflate":
+			deflate = true
+		}
+	}
+	if deflate {
+		return "deflate"
+	}
+	return ""
+}
+
+// compressWriter decides on the first write whether to compress, based on the response headers
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+
+	decided bool
+	writer  io.WriteCloser // nil when the response is sent uncompressed
+}
+
+// WriteHeader starts the response, enabling compression for compressible successful responses
+func (w *compressWriter) WriteHeader(status int) {
+	if !w.decided {
+		w.decided = true
+		header := w.Header()
+		if status == http.StatusOK && header.Get("Content-Encoding") == "" && isCompressible(header.Get("Content-Type")) {
+			header.Set("Content-Encoding", w.encoding)
+			header.Add("Vary", "Accept-Encoding")
+			header.Del("Content-Length")
+			w.writer = w.newEncoder()
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write compresses the body if compression was enabled
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.writer != nil {
+		return w.writer.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush flushes buffered compressed data (used by streaming handlers)
+func (w *compressWriter) Flush() {
+	if f, ok := w.writer.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close finishes the compressed stream
+func (w *compressWriter) Close() {
+	if w.writer == nil {
+		return
+	}
+	w.writer.Close()
+	if gz, ok := w.writer.(*gzip.Writer); ok {
+		gzipWriterPool.Put(gz)
+	}
+}
+
+// newEncoder creates the encoder for the negotiated encoding
+// HTTP "deflate" is the zlib format (RFC 9110), not raw DEFLATE.
+func (w *compressWriter) newEncoder() io.WriteCloser {
+	if w.encoding == "gzip" {
+		gz := gzipWriterPool.Get().(*gzip.Writer)
+		gz.Reset(w.ResponseWriter)
+		return gz
+	}
+	return zlib.NewWriter(w.ResponseWriter)
+}
+
+// isCompressible reports whether a content type benefits from compression
+func isCompressible(contentType string) bool {
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// staticHandler serves the web UI with caching headers
+//
+// Every response carries Cache-Control: no-cache, so browsers revalidate and
+// never run a stale UI after an upgrade. Embedded files never change while the
+// binary runs, so they get an ETag (content hash) and revalidation is a cheap
+// 304. The ETag is weak because the same file may be sent gzip-encoded or not.
+// Local files (developer mode) rely on Last-Modified instead.
+func staticHandler(webFS http.FileSystem, isDev bool) http.Handler {
+	fileServer := http.FileServer(webFS)
+	var etags sync.Map // Path -> ETag
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Cache-Control", "no-cache")
+
+		if !isDev {
+			name := path.Clean("/" + r.URL.Path)
+			if strings.HasSuffix(r.URL.Path, "/") {
+				name = path.Join(name, "index.html")
+			}
+
+			etag, ok := etags.Load(name)
+			if !ok {
+				if computed := fileETag(webFS, name); computed != "" {
+					etag, _ = etags.LoadOrStore(name, computed)
+				}
+			}
+			if etag != nil {
+				// http.FileServer answers If-None-Match with 304 using this header
+				rw.Header().Set("ETag", etag.(string))
+			}
+		}
+
+		fileServer.ServeHTTP(rw, r)
+	})
+}
+
+// fileETag returns a weak ETag of a file's content hash ("" if it can't be read or is a directory)
+func fileETag(webFS http.FileSystem, name string) string {
+	file, err := webFS.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err != nil || info.IsDir() {
+		return ""
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return ""
+	}
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Grafana SimpleJSON Datasource
+// ============================================================================
+
+// grafanaMetrics are the per-interface series offered to Grafana
+var grafanaMetrics = []string{"upload_avg", "download_avg", "upload_peak", "download_peak"}
+
+// grafanaQueryRequest is the body of a SimpleJSON /query request
+type grafanaQueryRequest struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	IntervalMs    int64 `json:"intervalMs"`
+	MaxDataPoints int   `json:"maxDataPoints"`
+	Targets       []struct {
+		Target string `json:"target"`
+	} `json:"targets"`
+}
+
+// grafanaSeries is one time series in a SimpleJSON /query response
+type grafanaSeries struct {
+	Target     string           `json:"target"`
+	DataPoints [][2]interface{} `json:"datapoints"` // [value, unix milliseconds], value null for gaps
+}
+
+// registerGrafanaRoutes adds the SimpleJSON datasource endpoints under /api/grafana/
+//
+// Point a Grafana "SimpleJSON" (or Infinity in SimpleJSON mode) datasource at
+// http://host:8080/api/grafana. Targets are "<interface>:<metric>", e.g.
+// "ether1:upload_avg"; a bare interface name returns all four metrics.
+func (w *WebServer) registerGrafanaRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/grafana/", w.handleGrafanaTest)
+	mux.HandleFunc("/api/grafana/search", w.handleGrafanaSearch)
+	mux.HandleFunc("/api/grafana/query", w.handleGrafanaQuery)
+	mux.HandleFunc("/api/grafana/annotations", w.handleGrafanaAnnotations)
+}
+
+// handleGrafanaTest answers the datasource connection test
+func (w *WebServer) handleGrafanaTest(rw http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/grafana/" {
+		http.NotFound(rw, r)
+		return
+	}
+	if w.vmClient == nil {
+		http.Error(rw, "VictoriaMetrics not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	rw.Write([]byte("OK"))
+}
+
+// handleGrafanaSearch lists the available targets, filtered by the optional search string
+func (w *WebServer) handleGrafanaSearch(rw http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Target string `json:"target"`
+	}
+	if r.Method == http.MethodPost && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(rw, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	names := append([]string{}, w.interfaces...)
+	for _, group := range w.groups {
+		names = append(names, group.Name)
+	}
+
+	targets := make([]string, 0, len(names)*len(grafanaMetrics))
+	for _, name := range names {
+		for _, metric := range grafanaMetrics {
+			target := name + ":" + metric
+			if strings.Contains(target, req.Target) {
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(targets)
+}
+
+// handleGrafanaQuery returns time series for the requested targets from QueryHistory
+func (w *WebServer) handleGrafanaQuery(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if w.vmClient == nil {
+		http.Error(rw, "VictoriaMetrics not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req grafanaQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !req.Range.From.Before(req.Range.To) {
+		http.Error(rw, "Invalid time range", http.StatusBadRequest)
+		return
+	}
+
+	// Resolve targets to (interface, metrics) and query all interfaces at once
+	type target struct {
+		name      string
+		iface     string
+		metrics   []string
+		labelName bool // Bare interface target: series are named "<interface>:<metric>"
+	}
+	targets := make([]target, 0, len(req.Targets))
+	var interfaces []string
+	seen := make(map[string]bool)
+	for _, t := range req.Targets {
+		if t.Target == "" {
+			continue
+		}
+		iface, metric := splitGrafanaTarget(t.Target)
+		tg := target{name: t.Target, iface: iface, metrics: []string{metric}}
+		if metric == "" {
+			tg.metrics, tg.labelName = grafanaMetrics, true
+		}
+		targets = append(targets, tg)
+		if !seen[iface] {
+			seen[iface] = true
+			interfaces = append(interfaces, iface)
+		}
+	}
+
+	series := make([]grafanaSeries, 0)
+	if len(interfaces) > 0 {
+		// Grafana's interval is the step it would like; storage has 10s resolution
+		interval := "auto"
+		if req.IntervalMs >= 10000 {
+			interval = fmt.Sprintf("%ds", req.IntervalMs/1000)
+		}
+
+		resp, err := w.vmClient.QueryHistory(HistoryQueryParams{
+			Interfaces: interfaces,
+			Start:      req.Range.From,
+			End:        req.Range.To,
+			Interval:   interval,
+			MaxPoints:  req.MaxDataPoints,
+		})
+		if err != nil {
+			logError("Web", "Grafana query error: %v", err)
+			http.Error(rw, fmt.Sprintf("Query failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		byInterface := make(map[string]*HistoryResponse, len(resp.Interfaces))
+		for _, history := range resp.Interfaces {
+			w.convertHistoryToDisplayFormat(history)
+			byInterface[history.Interface] = history
+		}
+
+		for _, tg := range targets {
+			history := byInterface[tg.iface]
+			for _, metric := range tg.metrics {
+				name := tg.name
+				if tg.labelName {
+					name = tg.iface + ":" + metric
+				}
+				series = append(series, grafanaSeries{
+					Target:     name,
+					DataPoints: grafanaDataPoints(history, metric),
+				})
+			}
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(series)
+}
+
+// handleGrafanaAnnotations returns no annotations (required by the datasource contract)
+func (w *WebServer) handleGrafanaAnnotations(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write([]byte("[]"))
+}
+
+// splitGrafanaTarget splits "<interface>:<metric>" (metric is "" for a bare interface name)
+func splitGrafanaTarget(target string) (iface, metric string) {
+	if i := strings.LastIndex(target, ":"); i >= 0 {
+		for _, m := range grafanaMetrics {
+			if target[i+1:] == m {
+				return target[:i], m
+			}
+		}
+	}
+	return target, ""
+}
+
+// grafanaDataPoints converts one metric of a history series to [value, ms] pairs
+// Gaps are sent as null so Grafana breaks the line instead of drawing zero traffic.
+func grafanaDataPoints(history *HistoryResponse, metric string) [][2]interface{} {
+	points := make([][2]interface{}, 0)
+	if history == nil {
+		return points
+	}
+
+	for _, dp := range history.DataPoints {
+		ms := dp.Timestamp.UnixMilli()
+		if dp.Gap {
+			points = append(points, [2]interface{}{nil, ms})
+			continue
+		}
+
+		var value float64
+		switch metric {
+		case "upload_avg":
+			value = dp.UploadAvg
+		case "download_avg":
+			value = dp.DownloadAvg
+		case "upload_peak":
+			value = dp.UploadPeak
+		case "download_peak":
+			value = dp.DownloadPeak
+		}
+		points = append(points, [2]interface{}{value, ms})
+	}
+	return points
+}
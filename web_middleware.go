@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Access Logs, Panic Recovery and Rate Limiting
+// ============================================================================
+
+// statusWriter records the status and size of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int // 0 until the header is written
+	bytes  int64
+}
+
+// trackStatus wraps a response writer (or returns it if it is already wrapped)
+func trackStatus(rw http.ResponseWriter) *statusWriter {
+	if sw, ok := rw.(*statusWriter); ok {
+		return sw
+	}
+	return &statusWriter{ResponseWriter: rw}
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush passes flushes through (streaming handlers)
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes the connection through for WebSocket upgrades
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// logRequests writes an access log record per request (WEB_ACCESS_LOG)
+// Records carry the fields as attributes, so LOG_FORMAT=json gives parseable
+// access logs. Health probes are logged at debug level only.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := trackStatus(rw)
+		path := r.URL.Path // Before the base path is stripped
+		defer func() {
+			level := slog.LevelInfo
+			if isProbePath(path) {
+				level = slog.LevelDebug
+			}
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			slog.Default().Log(context.Background(), level, r.Method+" "+path,
+				"component", "Access",
+				"method", r.Method,
+				"path", path,
+				"status", status,
+				"bytes", sw.bytes,
+				"duration", time.Since(start).Round(time.Microsecond).String(),
+				"remote_ip", remoteIP(r.RemoteAddr).String())
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// recoverPanics turns a handler panic into a logged error and a 500 response
+// Without it net/http only prints the panic to stderr and drops the connection.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		sw := trackStatus(rw)
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err) // Deliberate abort, handled by net/http
+			}
+			logError("Web", "Panic serving %s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+			if sw.status == 0 {
+				http.Error(sw, "Internal server error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// isProbePath reports whether a path is a health probe (with or without base path)
+func isProbePath(path string) bool {
+	return strings.HasSuffix(path, "/healthz") || strings.HasSuffix(path, "/readyz")
+}
+
+// rateLimiter limits requests per client IP with token buckets (WEB_RATE_LIMIT)
+type rateLimiter struct {
+	rate  float64 // Tokens added per second
+	burst float64 // Bucket size
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// tokenBucket is the state of one client
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// newRateLimiter allows perMinute requests per minute and IP, with bursts of up to burst requests
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token for a client, or returns how long until one is available
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Forget clients whose bucket has refilled, so the map does not grow without bound
+	if now.Sub(l.lastSweep) > time.Minute {
+		l.lastSweep = now
+		for key, bucket := range l.buckets {
+			if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate >= l.burst {
+				delete(l.buckets, key)
+			}
+		}
+	}
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[client] = bucket
+	}
+	bucket.tokens = min(l.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// limitRequests answers 429 Too Many Requests to clients over the rate limit
+// The client is the address resolved by forwardedHeaders; health probes are exempt.
+func limitRequests(limiter *rateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if isProbePath(r.URL.Path) {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		client := remoteIP(r.RemoteAddr).String()
+		if ok, wait := limiter.allow(client, time.Now()); !ok {
+			logDebug("Web", "Rate limit exceeded by %s (%s %s)", client, r.Method, r.URL.Path)
+			rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(rw, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
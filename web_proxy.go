@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ============================================================================
+// Reverse Proxy and CORS Support
+// ============================================================================
+
+// forwardedHeaders applies X-Forwarded-For/-Proto/-Host from trusted proxies
+//
+// r.RemoteAddr becomes the client address (the right-most untrusted hop),
+// r.URL.Scheme the original scheme and r.Host the original host, so login
+// logs show real clients and session cookies are marked Secure behind TLS
+// terminating proxies. Headers from untrusted peers are ignored.
+func forwardedHeaders(trusted []*net.IPNet, next http.Handler) http.Handler {
+	if len(trusted) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !ipInNets(remoteIP(r.RemoteAddr), trusted) {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			hops := strings.Split(forwarded, ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				hop := strings.TrimSpace(hops[i])
+				if ip := net.ParseIP(hop); ip != nil {
+					r.RemoteAddr = net.JoinHostPort(hop, "0")
+					if !ipInNets(ip, trusted) {
+						break
+					}
+				}
+			}
+		}
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			r.URL.Scheme = proto
+		}
+		if host := r.Header.Get("X-Forwarded-Host"); host != "" {
+			r.Host = host
+		}
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// remoteIP extracts the IP from a host:port address
+func remoteIP(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return net.ParseIP(host)
+}
+
+// ipInNets reports whether ip belongs to one of the networks
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDRs parses IPs and CIDR ranges (a bare IP becomes a single-host network)
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// isSecureRequest reports whether the client connection uses TLS (directly or via a trusted proxy)
+func isSecureRequest(r *http.Request) bool {
+	return r.TLS != nil || r.URL.Scheme == "https"
+}
+
+// cors adds CORS headers for allowed origins and answers preflight requests
+// Preflights are handled before authentication, as browsers send them without credentials
+func cors(origins []string, next http.Handler) http.Handler {
+	if len(origins) == 0 {
+		return next
+	}
+	allowed := toSet(origins)
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !(allowed["*"] || allowed[origin]) {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		header := rw.Header()
+		header.Add("Vary", "Origin")
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Allow-Credentials", "true")
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			header.Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			header.Set("Access-Control-Max-Age", "600")
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// checkWebSocketOrigin allows same-origin WebSocket connections and configured CORS origins
+// Without WEB_CORS_ORIGINS every origin is accepted (previous behaviour)
+func checkWebSocketOrigin(origins []string) func(r *http.Request) bool {
+	allowed := toSet(origins)
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if len(allowed) == 0 || allowed["*"] || origin == "" || allowed[origin] {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
+
+// withBasePath serves handler under a URL prefix such as /mikrotik
+// The prefix is stripped before routing; /mikrotik redirects to /mikrotik/.
+// Health probes are also answered at the root for orchestrators that bypass the proxy.
+func withBasePath(basePath string, handler, probes http.Handler) http.Handler {
+	if basePath == "" {
+		return handler
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(basePath+"/", http.StripPrefix(basePath, handler))
+	mux.Handle(basePath, http.RedirectHandler(basePath+"/", http.StatusMovedPermanently))
+	mux.Handle("/healthz", probes)
+	mux.Handle("/readyz", probes)
+	return mux
+}
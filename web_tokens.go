@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Scoped API Tokens (machine access without the admin password)
+// ============================================================================
+
+// API token scopes
+const (
+	scopeReadStats   = "read:stats"   // Current rates, interfaces, sessions, system, ping, events, /metrics, WebSocket
+	scopeReadHistory = "read:history" // VictoriaMetrics history, billing and the Grafana datasource
+	scopeWriteConfig = "write:config" // Labels, uplinks, /api/admin/config and /api/admin/schedule
+
+	// scopeAdmin is required to manage web users and tokens; it is never granted
+	// to a token, only WEB_AUTH_USER and WEB_AUTH_TOKENS have it
+	scopeAdmin = "admin"
+)
+
+// apiTokenScopes are the scopes a token can be issued with
+var apiTokenScopes = map[string]bool{
+	scopeReadStats:   true,
+	scopeReadHistory: true,
+	scopeWriteConfig: true,
+}
+
+// APIToken is a token issued through /api/admin/tokens
+// Only a hash is stored: the token itself is returned once, when it is created.
+type APIToken struct {
+	Name       string    `json:"name"`
+	Hash       string    `json:"hash"` // SHA-256 of the token (hex)
+	Scopes     []string  `json:"scopes"`
+	Interfaces []string  `json:"interfaces,omitempty"` // Visible interfaces (empty = all)
+	Created    time.Time `json:"created"`
+}
+
+// clone returns a copy that does not share slices
+func (t APIToken) clone() APIToken {
+	t.Scopes = append([]string(nil), t.Scopes...)
+	t.Interfaces = append([]string(nil), t.Interfaces...)
+	return t
+}
+
+// identity returns the identity of requests made with the token
+func (t APIToken) identity() *webIdentity {
+	id := &webIdentity{user: "token:" + t.Name, scopes: toSet(t.Scopes)}
+	if len(t.Interfaces) > 0 {
+		id.interfaces = toSet(t.Interfaces)
+	}
+	return id
+}
+
+// hashToken returns the stored form of a token
+// Tokens are 256-bit random values, so a plain hash cannot be brute-forced.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes, hex-encoded
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// requiredScope returns the scope a request needs ("" = any authenticated identity)
+func requiredScope(r *http.Request) string {
+	path := r.URL.Path
+	switch {
+	case path == "/api/admin/users" || path == "/api/admin/tokens":
+		return scopeAdmin
+	case path == "/api/admin/config" || path == "/api/admin/schedule":
+		return scopeWriteConfig
+	case strings.HasPrefix(path, "/api/config/"):
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return scopeReadStats
+		}
+		return scopeWriteConfig
+	case path == "/api/history" || strings.HasPrefix(path, "/api/history/") || strings.HasPrefix(path, "/api/grafana/") || path == "/api/billing":
+		return scopeReadHistory
+	case path == "/api/logout":
+		return ""
+	case strings.HasPrefix(path, "/api/") || path == "/metrics":
+		return scopeReadStats
+	}
+	return "" // Static UI
+}
+
+// ============================================================================
+// Admin API (API tokens)
+// ============================================================================
+
+// apiTokenView is an API token as returned by /api/admin/tokens (without the hash)
+type apiTokenView struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Scopes     []string  `json:"scopes"`
+	Interfaces []string  `json:"interfaces,omitempty"`
+	Created    time.Time `json:"created"`
+	Token      string    `json:"token,omitempty"` // Only in the response to POST
+}
+
+// handleAdminTokens issues and revokes API tokens
+// GET lists the tokens, POST issues one ({"name", "scopes", "interfaces"}; the
+// token is in the response and cannot be retrieved later) and DELETE ?id=
+// revokes one with immediate effect.
+func (w *WebServer) handleAdminTokens(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		tokens := w.userConfig.APITokens()
+		views := make([]apiTokenView, 0, len(tokens))
+		for id, token := range tokens {
+			views = append(views, apiTokenView{ID: id, Name: token.Name, Scopes: token.Scopes,
+				Interfaces: token.Interfaces, Created: token.Created})
+		}
+		sort.Slice(views, func(i, j int) bool {
+			if !views[i].Created.Equal(views[j].Created) {
+				return views[i].Created.Before(views[j].Created)
+			}
+			return views[i].ID < views[j].ID
+		})
+
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]interface{}{"tokens": views})
+
+	case http.MethodPost:
+		var body struct {
+			Name       string   `json:"name"`
+			Scopes     []string `json:"scopes"`
+			Interfaces []string `json:"interfaces"`
+		}
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&body); err != nil {
+			http.Error(rw, "Invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if body.Name == "" {
+			http.Error(rw, "Missing 'name'", http.StatusBadRequest)
+			return
+		}
+		if len(body.Scopes) == 0 {
+			http.Error(rw, "At least one scope is required (read:stats, read:history, write:config)", http.StatusBadRequest)
+			return
+		}
+		for _, scope := range body.Scopes {
+			if !apiTokenScopes[scope] {
+				http.Error(rw, "Unknown scope "+scope+" (must be read:stats, read:history or write:config)", http.StatusBadRequest)
+				return
+			}
+		}
+		if len(body.Interfaces) > 0 && toSet(body.Scopes)[scopeWriteConfig] {
+			http.Error(rw, "Tokens restricted to interfaces are read-only (write:config not allowed)", http.StatusBadRequest)
+			return
+		}
+
+		id, err := randomHex(8)
+		if err != nil {
+			http.Error(rw, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+		secret, err := randomHex(32)
+		if err != nil {
+			http.Error(rw, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+		token := APIToken{
+			Name:       body.Name,
+			Hash:       hashToken(secret),
+			Scopes:     body.Scopes,
+			Interfaces: body.Interfaces,
+			Created:    time.Now().UTC().Truncate(time.Second),
+		}
+		if err := w.userConfig.SetAPIToken(id, token); err != nil {
+			logError("Web", "Error saving API token: %v", err)
+			http.Error(rw, "Failed to save configuration", http.StatusInternalServerError)
+			return
+		}
+
+		logInfo("Web", "API token %q issued (id: %s, scopes: %s)", token.Name, id, strings.Join(token.Scopes, ", "))
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusCreated)
+		json.NewEncoder(rw).Encode(apiTokenView{ID: id, Name: token.Name, Scopes: token.Scopes,
+			Interfaces: token.Interfaces, Created: token.Created, Token: secret})
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		deleted, err := w.userConfig.DeleteAPIToken(id)
+		if err != nil {
+			logError("Web", "Error revoking API token: %v", err)
+			http.Error(rw, "Failed to save configuration", http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			http.Error(rw, "Unknown token", http.StatusNotFound)
+			return
+		}
+
+		logInfo("Web", "API token %s revoked", id)
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]string{"status": "ok"})
+
+	default:
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// Web Users (read-only accounts restricted to a subset of interfaces)
+// ============================================================================
+
+// WebUser is a web account that only sees some interfaces, e.g. a customer's VLAN
+// Users are managed through /api/admin/users and stored in data/config.json.
+type WebUser struct {
+	PasswordHash string   `json:"password_hash"` // See hashPassword
+	Interfaces   []string `json:"interfaces"`    // Visible interfaces and groups
+}
+
+// clone returns a copy that does not share the interface slice
+func (u WebUser) clone() WebUser {
+	u.Interfaces = append([]string(nil), u.Interfaces...)
+	return u
+}
+
+// checkPassword compares a password against the stored hash
+func (u WebUser) checkPassword(password string) bool {
+	parts := strings.Split(u.PasswordHash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(pbkdf2SHA256([]byte(password), salt, iterations), hash) == 1
+}
+
+// passwordIterations is the PBKDF2 work factor of new password hashes
+const passwordIterations = 100000
+
+// hashPassword derives a salted hash: "pbkdf2-sha256$<iterations>$<salt>$<hash>" (base64)
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	hash := pbkdf2SHA256([]byte(password), salt, passwordIterations)
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash)), nil
+}
+
+// pbkdf2SHA256 implements PBKDF2 (RFC 8018) with HMAC-SHA256 for a single 32-byte block
+func pbkdf2SHA256(password, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1}) // Block index
+	u := mac.Sum(nil)
+	key := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+// ============================================================================
+// Request Identity
+// ============================================================================
+
+// webIdentity is who a request was authenticated as
+type webIdentity struct {
+	user       string          // Web user or "token:<name>" ("" for WEB_AUTH_USER and WEB_AUTH_TOKENS)
+	interfaces map[string]bool // Visible interfaces (nil = all)
+	scopes     map[string]bool // Granted scopes (nil = all, see requiredScope)
+}
+
+// adminIdentity is the identity of WEB_AUTH_USER and WEB_AUTH_TOKENS (everything allowed)
+var adminIdentity = &webIdentity{}
+
+// webUserScopes are the scopes of web users (read-only)
+var webUserScopes = map[string]bool{scopeReadStats: true, scopeReadHistory: true}
+
+type identityKey struct{}
+
+// withIdentity attaches the authenticated identity to a request
+func withIdentity(r *http.Request, id *webIdentity) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), identityKey{}, id))
+}
+
+// identityFrom returns the identity of a request (nil without authentication: everything visible)
+func identityFrom(r *http.Request) *webIdentity {
+	id, _ := r.Context().Value(identityKey{}).(*webIdentity)
+	return id
+}
+
+// restricted reports whether the identity only sees some interfaces (nil-safe)
+func (id *webIdentity) restricted() bool {
+	return id != nil && id.interfaces != nil
+}
+
+// canSee reports whether an interface is visible (nil-safe)
+func (id *webIdentity) canSee(name string) bool {
+	return !id.restricted() || id.interfaces[name]
+}
+
+// scope narrows requested interfaces to the visible ones
+// "all" becomes the user's interfaces; ok is false if a requested interface is hidden.
+func (id *webIdentity) scope(interfaces []string, all bool) ([]string, bool) {
+	if !id.restricted() {
+		return interfaces, true
+	}
+	if all {
+		visible := make([]string, 0, len(id.interfaces))
+		for name := range id.interfaces {
+			visible = append(visible, name)
+		}
+		sort.Strings(visible)
+		return visible, true
+	}
+	for _, name := range interfaces {
+		if !id.interfaces[name] {
+			return nil, false
+		}
+	}
+	return interfaces, true
+}
+
+// visibleStats returns the rates of the visible interfaces
+func (id *webIdentity) visibleStats(stats map[string]*RateInfo) map[string]*RateInfo {
+	if !id.restricted() {
+		return stats
+	}
+	visible := make(map[string]*RateInfo, len(id.interfaces))
+	for name, info := range stats {
+		if id.interfaces[name] {
+			visible[name] = info
+		}
+	}
+	return visible
+}
+
+// userPaths are the endpoints open to identities restricted to some interfaces
+// (web users and such API tokens), besides the static UI. They are filtered per
+// interface; everything else under /api/ (and /metrics) reports on the whole
+// router or changes its configuration.
+var userPaths = map[string]bool{
+	"/api/current":         true,
+	"/api/interfaces":      true,
+	"/api/history":         true,
+	"/api/history/compare": true,
+	"/api/percentile":      true,
+	"/api/events":          true,
+	"/api/toptalkers":      true,
+	"/api/realtime":        true,
+	"/api/config/labels":   true,
+	"/api/config/uplinks":  true,
+	"/api/config/display":  true,
+	"/api/logout":          true,
+}
+
+// allows reports whether the identity may make a request
+// It needs the scope of the endpoint, and restricted identities are read-only.
+func (id *webIdentity) allows(r *http.Request) bool {
+	if id == nil {
+		return true
+	}
+	if scope := requiredScope(r); scope != "" && id.scopes != nil && !id.scopes[scope] {
+		return false
+	}
+	if !id.restricted() {
+		return true
+	}
+	if r.URL.Path == "/api/logout" {
+		return true
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/metrics" {
+		return userPaths[r.URL.Path]
+	}
+	return true
+}
+
+// ============================================================================
+// Admin API (web users)
+// ============================================================================
+
+// webUserView is a web account as returned by /api/admin/users (without the hash)
+type webUserView struct {
+	Username   string   `json:"username"`
+	Interfaces []string `json:"interfaces"`
+}
+
+// handleAdminUsers manages web users
+// GET lists the users, PUT creates or updates one ({"username", "password",
+// "interfaces"}; the password may be omitted when updating) and DELETE
+// ?username= removes one. Changes apply to the next request of a session; open
+// WebSocket connections keep the interfaces they were opened with.
+func (w *WebServer) handleAdminUsers(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		users := w.userConfig.WebUsers()
+		views := make([]webUserView, 0, len(users))
+		for name, user := range users {
+			views = append(views, webUserView{Username: name, Interfaces: user.Interfaces})
+		}
+		sort.Slice(views, func(i, j int) bool { return views[i].Username < views[j].Username })
+
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]interface{}{"users": views})
+
+	case http.MethodPut:
+		var body struct {
+			Username   string   `json:"username"`
+			Password   string   `json:"password"`
+			Interfaces []string `json:"interfaces"`
+		}
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&body); err != nil {
+			http.Error(rw, "Invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		user, exists := w.userConfig.WebUser(body.Username)
+		switch {
+		case body.Username == "" || strings.ContainsAny(body.Username, ": \t") || body.Username == w.config.AuthUser:
+			http.Error(rw, "Invalid 'username' (must be non-empty, without ':' or spaces, and not WEB_AUTH_USER)", http.StatusBadRequest)
+			return
+		case len(body.Interfaces) == 0:
+			http.Error(rw, "At least one interface is required", http.StatusBadRequest)
+			return
+		case body.Password == "" && !exists:
+			http.Error(rw, "Missing 'password'", http.StatusBadRequest)
+			return
+		case body.Password != "" && len(body.Password) < 8:
+			http.Error(rw, "Password must be at least 8 characters", http.StatusBadRequest)
+			return
+		}
+
+		user.Interfaces = body.Interfaces
+		if body.Password != "" {
+			hash, err := hashPassword(body.Password)
+			if err != nil {
+				http.Error(rw, "Failed to hash password", http.StatusInternalServerError)
+				return
+			}
+			user.PasswordHash = hash
+		}
+		if err := w.userConfig.SetWebUser(body.Username, user); err != nil {
+			logError("Web", "Error saving web user: %v", err)
+			http.Error(rw, "Failed to save configuration", http.StatusInternalServerError)
+			return
+		}
+
+		logInfo("Web", "Web user %q saved (interfaces: %s)", body.Username, strings.Join(user.Interfaces, ", "))
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(webUserView{Username: body.Username, Interfaces: user.Interfaces})
+
+	case http.MethodDelete:
+		name := r.URL.Query().Get("username")
+		deleted, err := w.userConfig.DeleteWebUser(name)
+		if err != nil {
+			logError("Web", "Error deleting web user: %v", err)
+			http.Error(rw, "Failed to save configuration", http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			http.Error(rw, "Unknown user", http.StatusNotFound)
+			return
+		}
+
+		logInfo("Web", "Web user %q deleted", name)
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]string{"status": "ok"})
+
+	default:
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
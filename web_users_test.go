@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// RFC 7914 section 11 (PBKDF2-HMAC-SHA256, dkLen 64); pbkdf2SHA256 derives the first 32-byte block
+	tests := []struct {
+		password, salt string
+		iterations     int
+		want           string
+	}{
+		{"passwd", "salt", 1,
+			"55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000,
+			"4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2SHA256([]byte(tt.password), []byte(tt.salt), tt.iterations))
+		if got != tt.want[:64] {
+			t.Errorf("pbkdf2SHA256(%q, %q, %d) = %s, want %s", tt.password, tt.salt, tt.iterations, got, tt.want[:64])
+		}
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$100000$") {
+		t.Errorf("hashPassword() = %q, want pbkdf2-sha256$100000$<salt>$<hash>", hash)
+	}
+	if other, _ := hashPassword("correct horse"); other == hash {
+		t.Error("hashPassword() returned the same hash twice (salt not random)")
+	}
+
+	user := WebUser{PasswordHash: hash}
+	if !user.checkPassword("correct horse") {
+		t.Error("checkPassword rejected the hashed password")
+	}
+	for _, wrong := range []string{"", "correct horse ", "Correct horse", "correct"} {
+		if user.checkPassword(wrong) {
+			t.Errorf("checkPassword accepted %q", wrong)
+		}
+	}
+
+	// A hash derived independently (Python hashlib.pbkdf2_hmac("sha256", b"secret", b"0123456789abcdef", 1000))
+	known := WebUser{PasswordHash: "pbkdf2-sha256$1000$MDEyMzQ1Njc4OWFiY2RlZg$tiKWHy4FAGCWE8gn6GtKhaxD2OeeAUUWXFT/p1aaNl8"}
+	if !known.checkPassword("secret") {
+		t.Error("checkPassword rejected a known hash")
+	}
+	if known.checkPassword("Secret") {
+		t.Error("checkPassword accepted a wrong password for a known hash")
+	}
+
+	for _, malformed := range []string{
+		"",
+		"secret",
+		"sha1$1000$MDEyMzQ1Njc4OWFiY2RlZg$tiKWHy4FAGCWE8gn6GtKhaxD2OeeAUUWXFT/p1aaNl8",
+		"pbkdf2-sha256$0$MDEyMzQ1Njc4OWFiY2RlZg$tiKWHy4FAGCWE8gn6GtKhaxD2OeeAUUWXFT/p1aaNl8",
+		"pbkdf2-sha256$999$MDEyMzQ1Njc4OWFiY2RlZg$tiKWHy4FAGCWE8gn6GtKhaxD2OeeAUUWXFT/p1aaNl8",
+		"pbkdf2-sha256$1000$not base64!$tiKWHy4FAGCWE8gn6GtKhaxD2OeeAUUWXFT/p1aaNl8",
+		"pbkdf2-sha256$1000$MDEyMzQ1Njc4OWFiY2RlZg$",
+		"pbkdf2-sha256$1000$MDEyMzQ1Njc4OWFiY2RlZg$tiKWHy4FAGCWE8gn6GtKhaxD2OeeAUUWXFT/p1aaNl8$extra",
+	} {
+		if (WebUser{PasswordHash: malformed}).checkPassword("secret") {
+			t.Errorf("checkPassword accepted hash %q", malformed)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ============================================================================
+// WebSocket Client State
+// ============================================================================
+
+// Keepalive settings for detecting half-open connections
+const (
+	wsWriteWait  = 10 * time.Second      // Max time to write a message to the peer
+	wsPongWait   = 60 * time.Second      // Max time between pongs before the peer is considered dead
+	wsPingPeriod = (wsPongWait * 9) / 10 // Send pings slightly more often than pongWait
+)
+
+// wsClient holds per-connection subscription options
+//
+// Clients may send a subscribe message at any time:
+//
+//	{"type": "subscribe", "interfaces": ["vlan2622"], "interval": 5, "unit": "bps"}
+//
+// - interfaces: only push these interfaces (empty or omitted = all)
+// - interval:   push at most every N seconds (default 1)
+// - unit:       "Bps" (bytes/s, default) or "bps" (bits/s)
+type wsClient struct {
+	conn *websocket.Conn
+
+	writeMu sync.Mutex // gorilla/websocket allows only one concurrent writer
+
+	visible map[string]bool // Interfaces a web user may see (nil = all); not changed by subscribe
+
+	optsMu     sync.RWMutex
+	interfaces map[string]bool // nil = all interfaces
+	interval   time.Duration   // Minimum time between pushes
+	unit       string          // "Bps" or "bps"
+	lastSent   time.Time
+
+	done chan struct{} // Closed when the connection is torn down (stops keepalive)
+}
+
+// wsSubscribeMessage is the client -> server subscribe request
+type wsSubscribeMessage struct {
+	Type       string   `json:"type"`
+	Interfaces []string `json:"interfaces"`
+	Interval   int      `json:"interval"` // Seconds
+	Unit       string   `json:"unit"`
+}
+
+// newWSClient creates client state with default options (all interfaces, every sample, bytes/s)
+func newWSClient(conn *websocket.Conn, visible map[string]bool) *wsClient {
+	return &wsClient{
+		conn:     conn,
+		visible:  visible,
+		interval: time.Second,
+		unit:     "Bps",
+		done:     make(chan struct{}),
+	}
+}
+
+// keepalive sends periodic pings until the client is closed
+// A failed ping closes the connection so the read loop exits and the client is removed
+func (c *wsClient) keepalive() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.writeMu.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			c.writeMu.Unlock()
+			if err != nil {
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// prepareRead installs the read deadline and pong handler used to detect dead peers
+func (c *wsClient) prepareRead() {
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+}
+
+// applySubscribe updates options from a subscribe message
+func (c *wsClient) applySubscribe(msg wsSubscribeMessage) {
+	c.optsMu.Lock()
+	defer c.optsMu.Unlock()
+
+	if len(msg.Interfaces) > 0 {
+		c.interfaces = toSet(msg.Interfaces)
+	} else {
+		c.interfaces = nil
+	}
+
+	c.interval = time.Second
+	if msg.Interval > 1 {
+		c.interval = time.Duration(msg.Interval) * time.Second
+	}
+
+	c.unit = "Bps"
+	if msg.Unit == "bps" {
+		c.unit = "bps"
+	}
+}
+
+// options returns a snapshot of the subscription options
+func (c *wsClient) options() map[string]interface{} {
+	c.optsMu.RLock()
+	defer c.optsMu.RUnlock()
+
+	interfaces := make([]string, 0, len(c.interfaces))
+	for name := range c.interfaces {
+		interfaces = append(interfaces, name)
+	}
+
+	return map[string]interface{}{
+		"type":       "subscribed",
+		"interfaces": interfaces,
+		"interval":   int(c.interval.Seconds()),
+		"unit":       c.unit,
+	}
+}
+
+// due reports whether the client wants an update at timestamp and marks it as sent
+// Allows a small tolerance so 1s ticks with jitter are not skipped
+func (c *wsClient) due(timestamp time.Time) bool {
+	c.optsMu.Lock()
+	defer c.optsMu.Unlock()
+
+	if timestamp.Sub(c.lastSent) < c.interval-100*time.Millisecond {
+		return false
+	}
+	c.lastSent = timestamp
+	return true
+}
+
+// wants reports whether the client subscribed to an interface
+func (c *wsClient) wants(name string) bool {
+	c.optsMu.RLock()
+	defer c.optsMu.RUnlock()
+	return (c.visible == nil || c.visible[name]) && (c.interfaces == nil || c.interfaces[name])
+}
+
+// isDefault reports whether the client uses default options (all interfaces, bytes/s)
+func (c *wsClient) isDefault() bool {
+	c.optsMu.RLock()
+	defer c.optsMu.RUnlock()
+	return c.visible == nil && c.interfaces == nil && c.unit == "Bps"
+}
+
+// filter applies the interface filter and unit preference to a display payload
+// The payload shape matches convertToDisplayFormat
+func (c *wsClient) filter(data map[string]interface{}) map[string]interface{} {
+	c.optsMu.RLock()
+	defer c.optsMu.RUnlock()
+
+	if c.visible == nil && c.interfaces == nil && c.unit == "Bps" {
+		return data
+	}
+
+	all, _ := data["interfaces"].(map[string]interface{})
+	interfaces := make(map[string]interface{}, len(all))
+	for name, value := range all {
+		if c.visible != nil && !c.visible[name] || c.interfaces != nil && !c.interfaces[name] {
+			continue
+		}
+		if c.unit == "bps" {
+			if rates, ok := value.(map[string]interface{}); ok {
+				converted := make(map[string]interface{}, len(rates))
+				for key, v := range rates {
+					// Rates only; link speed is already in bits/s and utilization is a percentage
+					if f, ok := v.(float64); ok && strings.HasSuffix(key, "_rate") {
+						converted[key] = f * 8
+					} else {
+						converted[key] = v
+					}
+				}
+				value = converted
+			}
+		}
+		interfaces[name] = value
+	}
+
+	filtered := make(map[string]interface{}, len(data)+1)
+	for key, value := range data {
+		filtered[key] = value
+	}
+	filtered["interfaces"] = interfaces
+	filtered["unit"] = c.unit
+	return filtered
+}
+
+// writeJSON marshals and sends a message (safe for concurrent use)
+func (c *wsClient) writeJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.write(data)
+}
+
+// write sends a text message (safe for concurrent use)
+// A write deadline prevents a dead peer from blocking broadcasts
+func (c *wsClient) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
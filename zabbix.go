@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Zabbix Sender Output
+// ============================================================================
+//
+// Completed aggregation windows are sent to a Zabbix server or proxy with the
+// sender protocol (what zabbix_sender does), one value per interface and metric,
+// for trapper items (type "Zabbix trapper") configured on the receiving host:
+//
+//   host: ZABBIX_HOST ("{router}" = MIKROTIK_HOST), or the ZABBIX_HOSTS entry of the interface
+//   key:  ZABBIX_KEY_<METRIC> or ZABBIX_KEY, e.g. mikrotik.if.rx_rate[ether1]
+//
+// Templates may use {router}, {interface}, {label} and (keys) {metric}. Items
+// the server does not know are counted as failed in its reply and logged.
+// The connection is unencrypted (no TLS/PSK).
+
+// zabbixHeader starts every sender protocol message ("ZBXD" and protocol flags)
+var zabbixHeader = []byte("ZBXD\x01")
+
+// zabbixMaxResponse limits the size of a server reply
+const zabbixMaxResponse = 1 << 20
+
+// zabbixMetrics are the values that can be sent per interface, in sending order
+// Rates are multiplied by the rate scale (METRIC_UNIT); ok is false when the value is unknown.
+var zabbixMetrics = []struct {
+	name  string
+	value func(stats *WindowStats, rateScale float64) (value string, ok bool)
+}{
+	{"rx_rate", func(s *WindowStats, scale float64) (string, bool) { return zabbixRate(s.RxSum/float64(s.Count), scale) }},
+	{"tx_rate", func(s *WindowStats, scale float64) (string, bool) { return zabbixRate(s.TxSum/float64(s.Count), scale) }},
+	{"rx_peak", func(s *WindowStats, scale float64) (string, bool) { return zabbixRate(s.RxPeak, scale) }},
+	{"tx_peak", func(s *WindowStats, scale float64) (string, bool) { return zabbixRate(s.TxPeak, scale) }},
+	{"rx_bytes", func(s *WindowStats, scale float64) (string, bool) { return strconv.FormatUint(s.RxBytes, 10), true }},
+	{"tx_bytes", func(s *WindowStats, scale float64) (string, bool) { return strconv.FormatUint(s.TxBytes, 10), true }},
+	{"rx_utilization", func(s *WindowStats, scale float64) (string, bool) {
+		return zabbixRatio(utilization(s.RxSum/float64(s.Count), s.LinkSpeed), s.LinkSpeed)
+	}},
+	{"tx_utilization", func(s *WindowStats, scale float64) (string, bool) {
+		return zabbixRatio(utilization(s.TxSum/float64(s.Count), s.LinkSpeed), s.LinkSpeed)
+	}},
+}
+
+func zabbixRate(rate, scale float64) (string, bool) {
+	return strconv.FormatFloat(rate*scale, 'f', 2, 64), true
+}
+
+func zabbixRatio(ratio, linkSpeed float64) (string, bool) {
+	return strconv.FormatFloat(ratio, 'f', 4, 64), linkSpeed > 0
+}
+
+// zabbixMetricNames lists the valid ZABBIX_METRICS entries
+func zabbixMetricNames() []string {
+	names := make([]string, len(zabbixMetrics))
+	for i, metric := range zabbixMetrics {
+		names[i] = metric.name
+	}
+	return names
+}
+
+// zabbixItem is one value of a sender request
+type zabbixItem struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+	NS    int    `json:"ns"`
+}
+
+// zabbixRequest is the body of a sender request
+type zabbixRequest struct {
+	Request string       `json:"request"`
+	Data    []zabbixItem `json:"data"`
+	Clock   int64        `json:"clock"`
+	NS      int          `json:"ns"`
+}
+
+// zabbixResponse is the server's reply, e.g. info "processed: 4; failed: 0; total: 4; seconds spent: 0.000123"
+type zabbixResponse struct {
+	Response string `json:"response"`
+	Info     string `json:"info"`
+}
+
+// ZabbixOutput implements OutputWriter by aggregating samples into time windows
+// and sending each completed window as trapper item values
+type ZabbixOutput struct {
+	config     *ZabbixConfig
+	aggregator *TimeWindowAggregator
+	flusher    *windowFlusher
+	router     string             // {router} (MIKROTIK_HOST)
+	userConfig *UserConfigManager // {label}
+	rateScale  float64            // Multiplier from bytes/s to the metric unit (8 for METRIC_UNIT=bits)
+}
+
+// NewZabbixOutput creates a Zabbix sender output (sampleInterval is the poll interval)
+func NewZabbixOutput(config *ZabbixConfig, sampleInterval time.Duration, routerHost string, userConfig *UserConfigManager, rateScale float64) *ZabbixOutput {
+	o := &ZabbixOutput{
+		config:     config,
+		aggregator: NewTimeWindowAggregator(config.Interval, sampleInterval),
+		router:     routerHost,
+		userConfig: userConfig,
+		rateScale:  rateScale,
+	}
+	o.flusher = newWindowFlusher(o.aggregator, o.send)
+
+	logInfo("Zabbix", "Output initialized (%s, host: %s, items: %s, interval: %v)",
+		config.Server, config.Host, strings.Join(config.Metrics, ", "), config.Interval)
+	return o
+}
+
+func (o *ZabbixOutput) WriteHeader() {}
+
+func (o *ZabbixOutput) WriteStats(timestamp time.Time, stats map[string]*RateInfo) {
+	for _, rateInfo := range stats {
+		o.aggregator.AddSample(timestamp, rateInfo)
+	}
+
+	o.flusher.sendCompleted()
+}
+
+// Close sends the last (partial) window
+func (o *ZabbixOutput) Close() {
+	o.flusher.Close()
+}
+
+// SetSampleInterval follows poll interval changes (see sampleIntervalSetter)
+func (o *ZabbixOutput) SetSampleInterval(now time.Time, sampleInterval time.Duration) {
+	o.aggregator.SetSampleInterval(now, sampleInterval)
+}
+
+// send delivers one completed window
+func (o *ZabbixOutput) send(window *AggregationWindow) {
+	items := o.items(window)
+	if len(items) == 0 {
+		return
+	}
+
+	response, err := o.request(items, time.Now())
+	if err != nil {
+		logError("Zabbix", "Failed to send window ending %s: %v", window.EndTime.Format("15:04:05"), err)
+		return
+	}
+	if response.Response != "success" {
+		logError("Zabbix", "Server rejected %d values: %s %s", len(items), response.Response, response.Info)
+		return
+	}
+	if !strings.Contains(response.Info, "failed: 0;") {
+		logWarn("Zabbix", "Some values were not accepted (missing host or trapper item?): %s", response.Info)
+	}
+}
+
+// items builds the values of a window (interfaces by name, metrics in zabbixMetrics order)
+func (o *ZabbixOutput) items(window *AggregationWindow) []zabbixItem {
+	names := make([]string, 0, len(window.Interfaces))
+	for name := range window.Interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	enabled := toSet(o.config.Metrics)
+	clock := window.EndTime.Unix()
+	var items []zabbixItem
+	for _, name := range names {
+		stats := window.Interfaces[name]
+		if stats.Count == 0 {
+			continue
+		}
+		label := name
+		if o.userConfig != nil {
+			label = o.userConfig.GetInterfaceLabel(name)
+		}
+		host := o.config.Host
+		if mapped, ok := o.config.Hosts[name]; ok {
+			host = mapped
+		}
+		host = strings.NewReplacer("{router}", o.router, "{interface}", name, "{label}", label).Replace(host)
+
+		for _, metric := range zabbixMetrics {
+			if !enabled[metric.name] {
+				continue
+			}
+			value, ok := metric.value(stats, o.rateScale)
+			if !ok {
+				continue
+			}
+			key := o.config.Key
+			if custom, ok := o.config.Keys[metric.name]; ok {
+				key = custom
+			}
+			key = strings.NewReplacer("{router}", o.router, "{interface}", zabbixKeyParam(name),
+				"{label}", zabbixKeyParam(label), "{metric}", metric.name).Replace(key)
+			items = append(items, zabbixItem{Host: host, Key: key, Value: value, Clock: clock})
+		}
+	}
+	return items
+}
+
+// zabbixKeyParam quotes an item key parameter containing a comma, bracket, quote or space
+func zabbixKeyParam(value string) string {
+	if !strings.ContainsAny(value, ",[]\" ") {
+		return value
+	}
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+// request sends values to the server and returns its reply
+func (o *ZabbixOutput) request(items []zabbixItem, now time.Time) (*zabbixResponse, error) {
+	body, err := json.Marshal(zabbixRequest{Request: "sender data", Data: items, Clock: now.Unix(), NS: now.Nanosecond()})
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", o.config.Server, o.config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(o.config.Timeout))
+
+	if _, err := conn.Write(zabbixPacket(body)); err != nil {
+		return nil, err
+	}
+
+	reply, err := readZabbixPacket(conn)
+	if err != nil {
+		return nil, err
+	}
+	var response zabbixResponse
+	if err := json.Unmarshal(reply, &response); err != nil {
+		return nil, fmt.Errorf("invalid reply: %w", err)
+	}
+	return &response, nil
+}
+
+// zabbixPacket frames data: header, 8-byte little-endian length, data
+func zabbixPacket(data []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(zabbixHeader)
+	binary.Write(&buf, binary.LittleEndian, uint64(len(data)))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// readZabbixPacket reads one framed message (uncompressed, as replies to uncompressed requests are)
+func readZabbixPacket(r io.Reader) ([]byte, error) {
+	header := make([]byte, len(zabbixHeader)+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("read reply header: %w", err)
+	}
+	if !bytes.Equal(header[:4], zabbixHeader[:4]) {
+		return nil, fmt.Errorf("not a Zabbix reply (header %q)", header[:4])
+	}
+	if header[4]&0x02 != 0 {
+		return nil, fmt.Errorf("compressed replies are not supported")
+	}
+
+	length := binary.LittleEndian.Uint64(header[len(zabbixHeader):])
+	if length > zabbixMaxResponse {
+		return nil, fmt.Errorf("reply too large (%d bytes)", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("read reply: %w", err)
+	}
+	return data, nil
+}